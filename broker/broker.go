@@ -1,69 +1,290 @@
 package broker
 
 import (
+	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy decides what Publish does when a subscriber's buffer is full.
+type Policy int
+
+const (
+	// Drop discards the event for that subscriber and counts it as dropped.
+	Drop Policy = iota
+	// Block waits until the subscriber has room, stalling the publisher, for
+	// up to the subscription's block timeout; then it drops the event.
+	Block
+	// Spill queues events to a temporary file while the subscriber's buffer
+	// is full and delivers them from it in order, dropping events only once
+	// the file reaches its size limit.
+	Spill
+)
+
+func (p Policy) String() string {
+	switch p {
+	case Drop:
+		return "drop"
+	case Block:
+		return "block"
+	case Spill:
+		return "spill"
+	}
+	return "unknown"
+}
 
-	"github.com/mickamy/sql-tap/proxy"
+// Defaults for the Block and Spill policies.
+const (
+	DefaultBlockTimeout = 5 * time.Second
+	DefaultSpillMax     = 256 << 20 // bytes
 )
 
-// Broker implements a non-blocking fan-out pub/sub for proxy events.
-// Slow subscribers silently drop events to avoid blocking the publisher.
-type Broker struct {
+// SubscribeOption configures a single subscription.
+type SubscribeOption func(*subscription)
+
+// subscription is the configuration of a subscriber, independent of what it
+// receives.
+type subscription struct {
+	name         string
+	client       string
+	peer         string
+	policy       Policy
+	blockTimeout time.Duration
+	spillDir     string
+	spillMax     int64
+	filter       any // a func(T) bool, checked against the Broker's T by Subscribe
+}
+
+// WithPolicy sets the subscription's full-buffer policy. The default is Drop.
+// Spill writes to the system's temporary directory unless WithSpill sets
+// another.
+func WithPolicy(p Policy) SubscribeOption {
+	return func(s *subscription) {
+		s.policy = p
+	}
+}
+
+// WithBlockTimeout sets how long the Block policy stalls the publisher on
+// the subscriber before dropping the event. The default is
+// DefaultBlockTimeout.
+func WithBlockTimeout(d time.Duration) SubscribeOption {
+	return func(s *subscription) {
+		s.blockTimeout = d
+	}
+}
+
+// WithSpill sets the Spill policy, spilling to a file in dir ("" for the
+// system's temporary directory) of up to maxBytes (DefaultSpillMax if not
+// positive). Values are written with encoding/gob, so T must be encodable.
+func WithSpill(dir string, maxBytes int64) SubscribeOption {
+	return func(s *subscription) {
+		s.policy = Spill
+		s.spillDir = dir
+		s.spillMax = maxBytes
+	}
+}
+
+// WithName labels the subscription in Stats.
+func WithName(name string) SubscribeOption {
+	return func(s *subscription) {
+		s.name = name
+	}
+}
+
+// WithClient records who is behind the subscription, such as "alice@laptop".
+func WithClient(client string) SubscribeOption {
+	return func(s *subscription) {
+		s.client = client
+	}
+}
+
+// WithPeer records the network address the subscription is served to.
+func WithPeer(addr string) SubscribeOption {
+	return func(s *subscription) {
+		s.peer = addr
+	}
+}
+
+// WithFilter delivers only the values keep accepts. Publish skips the
+// subscriber for the rest, so they neither fill its buffer nor count as
+// dropped. keep runs on the publishing goroutine and must be fast. T must
+// be the Broker's value type; Subscribe panics otherwise.
+func WithFilter[T any](keep func(T) bool) SubscribeOption {
+	return func(s *subscription) {
+		s.filter = keep
+	}
+}
+
+type subscriber[T any] struct {
+	subscription
+	keep    func(T) bool // nil delivers everything
+	ch      chan T
+	done    chan struct{} // closed on unsubscribe to release a blocked Publish
+	since   time.Time
+	dropped atomic.Uint64
+	spill   *spill[T] // with the Spill policy
+}
+
+// Broker implements a fan-out pub/sub of values of type T, such as
+// proxy.Event for captured queries.
+// By default slow subscribers drop events to avoid blocking the publisher;
+// drops are counted per subscriber and reported by Stats.
+type Broker[T any] struct {
 	mu          sync.RWMutex
-	subscribers map[int]chan proxy.Event
+	subscribers map[int]*subscriber[T]
 	nextID      int
 	bufSize     int
 }
 
-func New(bufSize int) *Broker {
-	return &Broker{
-		subscribers: make(map[int]chan proxy.Event),
+// New creates a Broker whose subscribers buffer up to bufSize values.
+func New[T any](bufSize int) *Broker[T] {
+	return &Broker[T]{
+		subscribers: make(map[int]*subscriber[T]),
 		bufSize:     bufSize,
 	}
 }
 
 // Subscribe returns a channel that receives published events
 // and an unsubscribe function. The unsubscribe function is idempotent.
-func (b *Broker) Subscribe() (<-chan proxy.Event, func()) {
+func (b *Broker[T]) Subscribe(opts ...SubscribeOption) (<-chan T, func()) {
+	sub := &subscriber[T]{
+		ch:    make(chan T, b.bufSize),
+		done:  make(chan struct{}),
+		since: time.Now(),
+	}
+	for _, opt := range opts {
+		opt(&sub.subscription)
+	}
+	if sub.filter != nil {
+		keep, ok := sub.filter.(func(T) bool)
+		if !ok {
+			panic(fmt.Sprintf("broker: filter %T does not match the broker's values", sub.filter))
+		}
+		sub.keep = keep
+	}
+	if sub.blockTimeout <= 0 {
+		sub.blockTimeout = DefaultBlockTimeout
+	}
+	if sub.policy == Spill {
+		if sub.spillMax <= 0 {
+			sub.spillMax = DefaultSpillMax
+		}
+		sub.spill = &spill[T]{
+			dir:     sub.spillDir,
+			max:     sub.spillMax,
+			ch:      sub.ch,
+			done:    sub.done,
+			dropped: &sub.dropped,
+		}
+	}
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
 	id := b.nextID
 	b.nextID++
+	b.subscribers[id] = sub
 
-	ch := make(chan proxy.Event, b.bufSize)
-	b.subscribers[id] = ch
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			// Release a Publish blocked on this subscriber before taking the write lock.
+			close(sub.done)
+			if sub.spill != nil {
+				sub.spill.close()
+			}
 
-	return ch, func() {
-		b.mu.Lock()
-		defer b.mu.Unlock()
+			b.mu.Lock()
+			defer b.mu.Unlock()
 
-		if _, ok := b.subscribers[id]; ok {
 			delete(b.subscribers, id)
-			close(ch)
-		}
+			close(sub.ch)
+		})
 	}
 }
 
-// Publish sends an event to all subscribers.
-// If a subscriber's buffer is full, the event is dropped for that subscriber.
-func (b *Broker) Publish(ev proxy.Event) {
+// Publish sends an event to all subscribers whose filter accepts it.
+// If a subscriber's buffer is full, the event is dropped for that subscriber,
+// or handled as its Block or Spill policy says.
+func (b *Broker[T]) Publish(ev T) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
-	for _, ch := range b.subscribers {
+	for _, sub := range b.subscribers {
+		if sub.keep != nil && !sub.keep(ev) {
+			continue
+		}
+		if sub.spill != nil {
+			sub.spill.publish(ev)
+			continue
+		}
 		select {
-		case ch <- ev:
+		case sub.ch <- ev:
+			continue
 		default:
-			// buffer full; drop event for this subscriber
 		}
+
+		if sub.policy == Block {
+			timer := time.NewTimer(sub.blockTimeout)
+			select {
+			case sub.ch <- ev:
+			case <-sub.done:
+			case <-timer.C:
+				sub.dropped.Add(1)
+			}
+			timer.Stop()
+			continue
+		}
+		sub.dropped.Add(1)
 	}
 }
 
 // SubscriberCount returns the number of active subscribers.
-func (b *Broker) SubscriberCount() int {
+func (b *Broker[T]) SubscriberCount() int {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 
 	return len(b.subscribers)
 }
+
+// SubscriberStats describes one active subscription.
+type SubscriberStats struct {
+	ID       int
+	Name     string
+	Client   string
+	Peer     string
+	Since    time.Time // when the subscription started
+	Policy   Policy
+	Filtered bool   // subscribed WithFilter
+	Dropped  uint64 // events discarded because the buffer was full
+	Buffered int    // events waiting to be received
+	Spilled  int    // events waiting on disk, with the Spill policy
+	Capacity int
+}
+
+// Stats returns the state of every active subscription, ordered by ID.
+func (b *Broker[T]) Stats() []SubscriberStats {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	out := make([]SubscriberStats, 0, len(b.subscribers))
+	for id, sub := range b.subscribers {
+		out = append(out, SubscriberStats{
+			ID:       id,
+			Name:     sub.name,
+			Client:   sub.client,
+			Peer:     sub.peer,
+			Since:    sub.since,
+			Policy:   sub.policy,
+			Filtered: sub.keep != nil,
+			Dropped:  sub.dropped.Load(),
+			Buffered: len(sub.ch),
+			Spilled:  sub.spill.len(),
+			Capacity: cap(sub.ch),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
@@ -1,6 +1,9 @@
 package broker_test
 
 import (
+	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -12,7 +15,7 @@ import (
 func TestBroker_PublishSubscribe(t *testing.T) {
 	t.Parallel()
 
-	b := broker.New(8)
+	b := broker.New[proxy.Event](8)
 	ch, unsub := b.Subscribe()
 	defer unsub()
 
@@ -32,7 +35,7 @@ func TestBroker_PublishSubscribe(t *testing.T) {
 func TestBroker_MultipleSubscribers(t *testing.T) {
 	t.Parallel()
 
-	b := broker.New(8)
+	b := broker.New[proxy.Event](8)
 
 	ch1, unsub1 := b.Subscribe()
 	defer unsub1()
@@ -61,7 +64,7 @@ func TestBroker_MultipleSubscribers(t *testing.T) {
 func TestBroker_Unsubscribe(t *testing.T) {
 	t.Parallel()
 
-	b := broker.New(8)
+	b := broker.New[proxy.Event](8)
 	_, unsub := b.Subscribe()
 
 	if b.SubscriberCount() != 1 {
@@ -84,7 +87,7 @@ func TestBroker_Unsubscribe(t *testing.T) {
 func TestBroker_SlowSubscriberDropsEvents(t *testing.T) {
 	t.Parallel()
 
-	b := broker.New(1) // buffer size 1
+	b := broker.New[proxy.Event](1) // buffer size 1
 	ch, unsub := b.Subscribe()
 	defer unsub()
 
@@ -112,7 +115,7 @@ func TestBroker_SlowSubscriberDropsEvents(t *testing.T) {
 func TestBroker_ConcurrentPublish(t *testing.T) {
 	t.Parallel()
 
-	b := broker.New(256)
+	b := broker.New[proxy.Event](256)
 	ch, unsub := b.Subscribe()
 	defer unsub()
 
@@ -140,3 +143,254 @@ func TestBroker_ConcurrentPublish(t *testing.T) {
 		}
 	}
 }
+
+func TestBroker_StatsCountsDrops(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	_, unsub := b.Subscribe(broker.WithName("tui"))
+	defer unsub()
+
+	for i := range 3 {
+		b.Publish(proxy.Event{ID: string(rune('1' + i))})
+	}
+
+	stats := b.Stats()
+	if len(stats) != 1 {
+		t.Fatalf("expected 1 subscriber, got %d", len(stats))
+	}
+	got := stats[0]
+	if got.Name != "tui" || got.Policy != broker.Drop {
+		t.Fatalf("unexpected subscriber: %+v", got)
+	}
+	if got.Dropped != 2 || got.Buffered != 1 || got.Capacity != 1 {
+		t.Fatalf("expected 2 dropped, 1/1 buffered, got %+v", got)
+	}
+}
+
+func TestBroker_BlockPolicyWaitsForSubscriber(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	ch, unsub := b.Subscribe(broker.WithPolicy(broker.Block))
+	defer unsub()
+
+	b.Publish(proxy.Event{ID: "1"})
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(proxy.Event{ID: "2"})
+		close(published)
+	}()
+
+	select {
+	case <-published:
+		t.Fatal("publish did not block on a full subscriber")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	for _, want := range []string{"1", "2"} {
+		select {
+		case got := <-ch:
+			if got.ID != want {
+				t.Fatalf("expected event %s, got %+v", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+	}
+	<-published
+
+	if d := b.Stats()[0].Dropped; d != 0 {
+		t.Fatalf("expected no drops, got %d", d)
+	}
+}
+
+func TestBroker_UnsubscribeReleasesBlockedPublish(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	_, unsub := b.Subscribe(broker.WithPolicy(broker.Block))
+
+	b.Publish(proxy.Event{ID: "1"})
+
+	published := make(chan struct{})
+	go func() {
+		b.Publish(proxy.Event{ID: "2"})
+		close(published)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	unsub()
+
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish still blocked after unsubscribe")
+	}
+}
+
+func TestBroker_OtherTypes(t *testing.T) {
+	t.Parallel()
+
+	type snapshot struct{ qps float64 }
+	b := broker.New[snapshot](1)
+	ch, unsub := b.Subscribe(broker.WithName("stats"))
+	defer unsub()
+
+	b.Publish(snapshot{qps: 12.5})
+	b.Publish(snapshot{qps: 13}) // buffer full, dropped
+
+	if got := <-ch; got.qps != 12.5 {
+		t.Fatalf("unexpected snapshot: %+v", got)
+	}
+	if st := b.Stats(); len(st) != 1 || st[0].Name != "stats" || st[0].Dropped != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestBroker_Filter(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	ch, unsub := b.Subscribe(broker.WithFilter(func(ev proxy.Event) bool { return ev.Upstream == "replica" }))
+	defer unsub()
+
+	b.Publish(proxy.Event{ID: "1", Upstream: "primary"})
+	b.Publish(proxy.Event{ID: "2", Upstream: "replica"})
+	b.Publish(proxy.Event{ID: "3", Upstream: "primary"}) // skipped, not dropped
+
+	if got := <-ch; got.ID != "2" {
+		t.Fatalf("unexpected event: %+v", got)
+	}
+	if st := b.Stats(); len(st) != 1 || !st[0].Filtered || st[0].Dropped != 0 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+}
+
+func TestBroker_FilterWrongType(t *testing.T) {
+	t.Parallel()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a filter of another type")
+		}
+	}()
+	b := broker.New[proxy.Event](1)
+	b.Subscribe(broker.WithFilter(func(string) bool { return true }))
+}
+
+func TestBroker_BlockPolicyTimesOut(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	_, unsub := b.Subscribe(broker.WithPolicy(broker.Block), broker.WithBlockTimeout(20*time.Millisecond))
+	defer unsub()
+
+	b.Publish(proxy.Event{ID: "1"})
+	start := time.Now()
+	b.Publish(proxy.Event{ID: "2"}) // nobody receives; gives up after the timeout
+	if waited := time.Since(start); waited < 20*time.Millisecond || waited > time.Second {
+		t.Fatalf("publish waited %v, want about the 20ms timeout", waited)
+	}
+	if d := b.Stats()[0].Dropped; d != 1 {
+		t.Fatalf("expected the timed-out event dropped, got %d drops", d)
+	}
+}
+
+func TestBroker_SpillPolicyDeliversInOrder(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	b := broker.New[proxy.Event](2)
+	ch, unsub := b.Subscribe(broker.WithSpill(dir, 0))
+
+	const n = 50
+	for i := range n {
+		b.Publish(proxy.Event{ID: strconv.Itoa(i), Args: []string{"x"}})
+	}
+	st := b.Stats()[0]
+	if st.Policy != broker.Spill || st.Dropped != 0 || st.Spilled == 0 {
+		t.Fatalf("unexpected stats with a full buffer: %+v", st)
+	}
+	if files, _ := os.ReadDir(dir); len(files) != 1 {
+		t.Fatalf("expected one spill file, got %v", files)
+	}
+
+	for i := range n {
+		select {
+		case got := <-ch:
+			if got.ID != strconv.Itoa(i) || len(got.Args) != 1 {
+				t.Fatalf("event %d: got %+v", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for event %d", i)
+		}
+	}
+	// The last spilled event counts until the drainer finishes handing it over.
+	deadline := time.Now().Add(time.Second)
+	for b.Stats()[0].Spilled != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if st := b.Stats()[0]; st.Spilled != 0 || st.Dropped != 0 {
+		t.Fatalf("unexpected stats after catching up: %+v", st)
+	}
+
+	// Once caught up, events go straight to the buffer again.
+	b.Publish(proxy.Event{ID: "after"})
+	if got := <-ch; got.ID != "after" {
+		t.Fatalf("expected event after, got %+v", got)
+	}
+
+	unsub()
+	if files, _ := os.ReadDir(dir); len(files) != 0 {
+		t.Fatalf("spill file left after unsubscribe: %v", files)
+	}
+}
+
+func TestBroker_SpillPolicyDropsWhenFull(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	_, unsub := b.Subscribe(broker.WithSpill(t.TempDir(), 4<<10))
+	defer unsub()
+
+	for i := range 100 {
+		b.Publish(proxy.Event{ID: strconv.Itoa(i), Query: "SELECT '" + strings.Repeat("x", 100) + "'"})
+	}
+	st := b.Stats()[0]
+	if st.Dropped == 0 || st.Spilled == 0 || st.Spilled+int(st.Dropped)+st.Buffered != 100 {
+		t.Fatalf("expected the events beyond the spill limit dropped, got %+v", st)
+	}
+}
+
+func TestBroker_SpillPolicyDropsUnencodable(t *testing.T) {
+	t.Parallel()
+
+	type record struct {
+		ID    int
+		Extra any // gob needs the types it holds registered
+	}
+	b := broker.New[record](1)
+	ch, unsub := b.Subscribe(broker.WithSpill(t.TempDir(), 0))
+	defer unsub()
+
+	b.Publish(record{ID: 1})
+	b.Publish(record{ID: 2}) // spilled; the drainer waits on the full buffer
+	b.Publish(record{ID: 3, Extra: func() {}})
+	b.Publish(record{ID: 4})
+
+	for _, want := range []int{1, 2, 4} {
+		select {
+		case got := <-ch:
+			if got.ID != want {
+				t.Fatalf("got record %d, want %d", got.ID, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timeout waiting for record %d", want)
+		}
+	}
+	if st := b.Stats()[0]; st.Dropped != 1 {
+		t.Fatalf("expected only the unencodable record dropped, got %+v", st)
+	}
+}
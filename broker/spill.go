@@ -0,0 +1,191 @@
+package broker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+var errSpillFull = errors.New("broker: spill file full")
+
+// spill queues a Spill subscriber's values in a file while its buffer is
+// full. A goroutine, running while the file holds any, moves them into the
+// buffer in order; until it empties the file, new values queue behind them.
+// The file is one gob stream, started anew each time it empties.
+type spill[T any] struct {
+	dir     string
+	max     int64
+	ch      chan T
+	done    <-chan struct{}
+	dropped *atomic.Uint64
+
+	mu      sync.Mutex
+	f       *os.File // created on the first spill
+	buf     bytes.Buffer
+	enc     *gob.Encoder // into buf, for the stream being written
+	dec     *gob.Decoder // of the same stream
+	r, w    int64        // offsets the decoder reads and the encoder writes at
+	pending int          // values in the file
+	closed  bool
+	drainer sync.WaitGroup
+}
+
+// publish delivers v, or queues it in the file.
+func (s *spill[T]) publish(v T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	if s.pending == 0 {
+		select {
+		case s.ch <- v:
+			return
+		default:
+		}
+	}
+	if err := s.write(v); err != nil {
+		s.dropped.Add(1)
+		return
+	}
+	s.pending++
+	// The drainer exits only as it takes the last value, so none runs now.
+	if s.pending == 1 {
+		s.drainer.Go(s.drain)
+	}
+}
+
+func (s *spill[T]) write(v T) error {
+	if s.f == nil {
+		f, err := os.CreateTemp(s.dir, "sql-tap-spill-*")
+		if err != nil {
+			return fmt.Errorf("broker: spill: %w", err)
+		}
+		s.f = f
+	}
+	if s.enc == nil {
+		s.enc = gob.NewEncoder(&s.buf)
+		s.dec = gob.NewDecoder(bufio.NewReader(&fileReader{f: s.f, off: &s.r}))
+	}
+	s.buf.Reset()
+	if err := s.enc.Encode(v); err != nil {
+		// Only v is lost. The encoder counts the type definitions it wrote
+		// before failing as sent, so they join the stream; with no values
+		// queued, the stream starts over instead.
+		if s.pending == 0 {
+			s.restart()
+		} else if s.buf.Len() > 0 && s.w+int64(s.buf.Len()) <= s.max {
+			_ = s.flush()
+		}
+		return fmt.Errorf("broker: spill: %w", err)
+	}
+	if s.w+int64(s.buf.Len()) > s.max {
+		if s.w == 0 {
+			// The value dropped carried the stream's type definitions.
+			s.restart()
+		}
+		return errSpillFull
+	}
+	return s.flush()
+}
+
+// flush writes buf to the end of the stream. A failed write is cut off
+// again, so the decoder never reads part of a message.
+func (s *spill[T]) flush() error {
+	n, err := s.f.WriteAt(s.buf.Bytes(), s.w)
+	if err != nil {
+		if n > 0 {
+			_ = s.f.Truncate(s.w)
+		}
+		return fmt.Errorf("broker: spill: %w", err)
+	}
+	s.w += int64(n)
+	return nil
+}
+
+// restart empties the file; the next value written starts a new stream.
+func (s *spill[T]) restart() {
+	s.enc, s.dec = nil, nil
+	s.r, s.w = 0, 0
+	_ = s.f.Truncate(0)
+}
+
+// drain delivers the file's values until it is empty or the subscriber
+// goes away.
+func (s *spill[T]) drain() {
+	for {
+		var v T
+		s.mu.Lock()
+		err := s.dec.Decode(&v)
+		s.mu.Unlock()
+
+		if err == nil {
+			select {
+			case s.ch <- v:
+			case <-s.done:
+				return
+			}
+		} else {
+			s.dropped.Add(1)
+		}
+
+		s.mu.Lock()
+		s.pending--
+		if s.pending == 0 || err != nil {
+			// A broken stream loses the values after the one that failed.
+			s.dropped.Add(uint64(s.pending)) //nolint:gosec // not negative
+			s.pending = 0
+			s.restart()
+			s.mu.Unlock()
+			return
+		}
+		s.mu.Unlock()
+	}
+}
+
+// len returns how many values wait in the file; 0 for a nil spill.
+func (s *spill[T]) len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// close stops the drainer, which the closed done channel releases, and
+// removes the file. Values still in it are discarded with the subscriber.
+func (s *spill[T]) close() {
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+
+	s.drainer.Wait()
+	if s.f != nil {
+		_ = s.f.Close()
+		_ = os.Remove(s.f.Name())
+	}
+}
+
+// fileReader reads f sequentially from *off. The decoder reads only values
+// already written, so the end of the file is never mistaken for the end
+// of the stream.
+type fileReader struct {
+	f   *os.File
+	off *int64
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	n, err := r.f.ReadAt(p, *r.off)
+	*r.off += int64(n)
+	if n > 0 {
+		return n, nil
+	}
+	return 0, err //nolint:wrapcheck // read by the gob decoder
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// catCmd prints archive files as NDJSON, decompressing and decrypting them.
+func catCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap cat", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap cat — Print capture archives as NDJSON\n\nUsage:\n  sql-tap cat [flags] <file>...\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	keyEnv := fs.String("key-env", "SQL_TAP_ARCHIVE_KEY", "environment variable holding the key for encrypted (.enc) archives")
+	keyKMS := fs.String("key-kms", "", kmsUsage)
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	key, err := readKey(*keyEnv, *keyKMS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, path := range fs.Args() {
+		if err := catFile(path, key, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func catFile(path string, key []byte, out io.Writer) error {
+	rc, err := openArchive(path, key)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+	return nil
+}
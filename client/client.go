@@ -0,0 +1,81 @@
+// Package client connects to the gRPC API of a running sql-tapd (or sql-tap
+// agent), the same API the sql-tap TUI and subcommands use:
+//
+//	c, err := client.Dial("localhost:9091", client.WithToken(os.Getenv("SQL_TAP_TOKEN")))
+//	if err != nil { ... }
+//	defer c.Close()
+//	stream, err := c.Watch(ctx, &tapv1.WatchRequest{})
+//
+// The package, the tapv1 messages it embeds, and the proxy, broker, explain,
+// dsn, and sqlcomment packages are the module's stable API; everything under
+// internal/ may change in any release.
+package client
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+)
+
+// Option configures Dial.
+type Option func(*options)
+
+type options struct {
+	token string
+	dial  []grpc.DialOption
+}
+
+// WithToken presents token as a bearer token on every call, for daemons with
+// auth enabled. An empty token sends none.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithDialOptions adds gRPC dial options, e.g. a larger
+// grpc.MaxCallRecvMsgSize for Query calls returning many large events. The
+// connection is plaintext unless they set transport credentials.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dial = append(o.dial, opts...)
+	}
+}
+
+// Client is a connection to a sql-tapd gRPC server. It is safe for
+// concurrent use.
+type Client struct {
+	tapv1.TapServiceClient
+
+	conn *grpc.ClientConn
+}
+
+// Dial connects to the daemon at addr, e.g. "localhost:9091". The connection
+// is established lazily, on the first call.
+func Dial(addr string, opts ...Option) (*Client, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	dial := append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, o.dial...)
+	if o.token != "" {
+		dial = append(dial, grpc.WithPerRPCCredentials(auth.Token(o.token)))
+	}
+	conn, err := grpc.NewClient(addr, dial...)
+	if err != nil {
+		return nil, fmt.Errorf("client: dial %s: %w", addr, err)
+	}
+	return &Client{TapServiceClient: tapv1.NewTapServiceClient(conn), conn: conn}, nil
+}
+
+// Close closes the connection.
+func (c *Client) Close() error {
+	if err := c.conn.Close(); err != nil {
+		return fmt.Errorf("client: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package client_test
+
+import (
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestDial(t *testing.T) {
+	t.Parallel()
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(t.Context(), "tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := auth.New(map[string]auth.Role{"view-token": auth.RoleViewer})
+	srv := server.New(broker.New[proxy.Event](8), nil, server.WithAuthorizer(a))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	tests := []struct {
+		name string
+		opts []client.Option
+		want codes.Code
+	}{
+		{name: "token", opts: []client.Option{client.WithToken("view-token")}, want: codes.OK},
+		{name: "no token", want: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c, err := client.Dial(lis.Addr().String(), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = c.Close() }()
+
+			_, err = c.Info(t.Context(), &tapv1.InfoRequest{})
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("Info() code = %v, want %v (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
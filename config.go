@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// configCmd runs the config subcommands.
+func configCmd(args []string) {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Fprintf(os.Stderr, "sql-tap config — Inspect a daemon's configuration\n\nUsage:\n  sql-tap config show [flags] <addr>\n")
+		os.Exit(1)
+	}
+	configShowCmd(args[1:])
+}
+
+// configShowCmd prints a daemon's effective configuration.
+func configShowCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap config show", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap config show — Show a daemon's effective configuration, secrets masked\n\nUsage:\n  sql-tap config show [flags] <addr>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	tokenEnv := fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled (admin role)")
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	resp, err := configDaemon(fs.Arg(0), os.Getenv(*tokenEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(resp.GetYaml())
+}
+
+func configDaemon(addr, token string) (*tapv1.ConfigResponse, error) {
+	c, err := client.Dial(addr, client.WithToken(token))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the address
+	}
+	defer func() { _ = c.Close() }()
+
+	resp, err := c.Config(context.Background(), &tapv1.ConfigRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", addr, err)
+	}
+	return resp, nil
+}
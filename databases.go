@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// databasesCmd prints a daemon's per-database query statistics.
+func databasesCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap databases", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap databases — Show query statistics per database\n\nUsage:\n  sql-tap databases [flags] <addr>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	tokenEnv := fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled")
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	resp, err := databasesDaemon(fs.Arg(0), os.Getenv(*tokenEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeDatabases(os.Stdout, resp); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func databasesDaemon(addr, token string) (*tapv1.DatabasesResponse, error) {
+	c, err := client.Dial(addr, client.WithToken(token))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the address
+	}
+	defer func() { _ = c.Close() }()
+
+	resp, err := c.Databases(context.Background(), &tapv1.DatabasesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("databases %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func writeDatabases(out io.Writer, resp *tapv1.DatabasesResponse) error {
+	if len(resp.GetDatabases()) == 0 {
+		_, err := fmt.Fprintf(out, "no queries in the last %s\n", resp.GetWindow().AsDuration())
+		return err //nolint:wrapcheck // stdout write error
+	}
+	// The upstream column only matters with several upstreams.
+	upstreams := false
+	for _, db := range resp.GetDatabases() {
+		if db.GetUpstream() != "" {
+			upstreams = true
+		}
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if upstreams {
+		fmt.Fprint(w, "UPSTREAM\t")
+	}
+	fmt.Fprintln(w, "DATABASE\tQUERIES\tQPS\tERRORS\tP50\tP95\tP99\tSHARE\tUSERS")
+	for _, db := range resp.GetDatabases() {
+		if upstreams {
+			fmt.Fprintf(w, "%s\t", db.GetUpstream())
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%d\t%s\t%s\t%s\t%.1f%%\t%s\n",
+			db.GetDatabase(), db.GetCount(), db.GetQps(), db.GetErrors(),
+			roundLatency(db.GetP50()), roundLatency(db.GetP95()), roundLatency(db.GetP99()),
+			100*db.GetShare(), strings.Join(db.GetUsers(), ","))
+	}
+	return w.Flush() //nolint:wrapcheck // stdout write error
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/diff"
+)
+
+// diffCmd compares two recorded sessions by fingerprint.
+func diffCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap diff", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap diff — Compare two recorded sessions\n\nUsage:\n  sql-tap diff [flags] <before> <after>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("output", "text", "report format: text or json")
+	latencyRatio := fs.Float64("latency-ratio", diff.DefaultLatencyRatio, "report a query as slower when its p95 grows by at least this factor")
+	minLatency := fs.Duration("min-latency", diff.DefaultMinLatency, "and by at least this much")
+	callRatio := fs.Float64("call-ratio", diff.DefaultCallRatio, "report a query's call count as changed when it grows or shrinks by at least this factor")
+	keyEnv := fs.String("key-env", "SQL_TAP_ARCHIVE_KEY", "environment variable holding the key for encrypted (.enc) archives")
+	keyKMS := fs.String("key-kms", "", kmsUsage)
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *output != "text" && *output != "json" {
+		fmt.Fprintf(os.Stderr, "Error: unknown output %q (want text or json)\n", *output)
+		os.Exit(1)
+	}
+
+	key, err := readKey(*keyEnv, *keyKMS)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var sessions [2]*diff.Session
+	for i, path := range fs.Args() {
+		s, err := readSession(path, key)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		sessions[i] = s
+	}
+
+	report := diff.Compare(sessions[0], sessions[1],
+		diff.WithLatencyThreshold(*latencyRatio, *minLatency),
+		diff.WithCallRatio(*callRatio))
+
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeDiff(os.Stdout, report)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func readSession(path string, key []byte) (*diff.Session, error) {
+	rc, err := openArchive(path, key)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // archive errors name the path
+	}
+	defer func() { _ = rc.Close() }()
+	s, err := diff.Read(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return s, nil
+}
+
+func writeDiff(out io.Writer, r diff.Report) error {
+	if r.Empty() {
+		_, err := fmt.Fprintf(out, "no differences (%d queries compared)\n", r.Unchanged)
+		return err //nolint:wrapcheck // stdout write error
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if len(r.New) > 0 {
+		fmt.Fprintf(w, "New queries (%d)\n", len(r.New))
+		fmt.Fprintln(w, "  CALLS\tP50\tP95\tERRORS\tQUERY")
+		for _, q := range r.New {
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%d\t%s\n", q.Calls, roundDuration(q.P50), roundDuration(q.P95), q.Errors, q.Fingerprint)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(r.Removed) > 0 {
+		fmt.Fprintf(w, "Removed queries (%d)\n", len(r.Removed))
+		fmt.Fprintln(w, "  CALLS\tP50\tP95\tERRORS\tQUERY")
+		for _, q := range r.Removed {
+			fmt.Fprintf(w, "  %d\t%s\t%s\t%d\t%s\n", q.Calls, roundDuration(q.P50), roundDuration(q.P95), q.Errors, q.Fingerprint)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(r.Regressions) > 0 {
+		fmt.Fprintf(w, "Slower queries (%d)\n", len(r.Regressions))
+		fmt.Fprintln(w, "  P95 BEFORE\tP95 AFTER\tCHANGE\tCALLS\tQUERY")
+		for _, c := range r.Regressions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%d\t%s\n",
+				roundDuration(c.Before.P95), roundDuration(c.After.P95),
+				ratio(float64(c.After.P95), float64(c.Before.P95)), c.After.Calls, c.After.Fingerprint)
+		}
+		fmt.Fprintln(w)
+	}
+	if len(r.CallChanges) > 0 {
+		fmt.Fprintf(w, "Call count changes (%d)\n", len(r.CallChanges))
+		fmt.Fprintln(w, "  CALLS BEFORE\tCALLS AFTER\tCHANGE\tQUERY")
+		for _, c := range r.CallChanges {
+			fmt.Fprintf(w, "  %d\t%d\t%s\t%s\n",
+				c.Before.Calls, c.After.Calls, ratio(float64(c.After.Calls), float64(c.Before.Calls)), c.After.Fingerprint)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d queries unchanged\n", r.Unchanged)
+	return w.Flush() //nolint:wrapcheck // stdout write error
+}
+
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
+
+func ratio(after, before float64) string {
+	if before == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fx", after/before)
+}
@@ -4,7 +4,15 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 // DetectDriver infers the database driver name from a DSN string.
@@ -49,3 +57,80 @@ func Open(raw string) (*sql.DB, error) {
 	}
 	return db, nil
 }
+
+// Address returns the server address of a DSN, as host:port or a Unix
+// socket path, the form a proxy upstream takes. Unset parts get the
+// drivers' defaults, e.g. port 5432 or 3306; for a PostgreSQL socket
+// directory the path names the socket file in it.
+func Address(raw string) (string, error) {
+	driver, err := DetectDriver(raw)
+	if err != nil {
+		return "", err
+	}
+	if driver == "mysql" {
+		cfg, err := mysql.ParseDSN(strings.TrimPrefix(raw, "mysql://"))
+		if err != nil {
+			return "", fmt.Errorf("dsn: parse: %w", err)
+		}
+		return cfg.Addr, nil
+	}
+	cfg, err := pgconn.ParseConfig(raw)
+	if err != nil {
+		return "", fmt.Errorf("dsn: parse: %w", err)
+	}
+	port := strconv.Itoa(int(cfg.Port))
+	if strings.HasPrefix(cfg.Host, "/") {
+		return filepath.Join(cfg.Host, ".s.PGSQL."+port), nil
+	}
+	return net.JoinHostPort(cfg.Host, port), nil
+}
+
+// Masked is what Mask puts in place of a password.
+const Masked = "xxxxx"
+
+var keywordPassword = regexp.MustCompile(`\bpassword\s*=\s*('(?:\\.|[^'])*'|\S+)`)
+
+// Mask returns raw with its password replaced by Masked, so the DSN can be
+// logged or shown. A DSN that fails to parse is masked entirely; a string
+// that is not a DSN, such as host:port, is returned unchanged.
+func Mask(raw string) string {
+	lower := strings.ToLower(raw)
+	switch {
+	case raw == "":
+		return ""
+	case strings.HasPrefix(lower, "mysql://"):
+		return raw[:len("mysql://")] + maskMySQL(raw[len("mysql://"):])
+	case strings.Contains(raw, "://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return Masked
+		}
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), Masked)
+		}
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", Masked)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	driver, err := DetectDriver(raw)
+	if err != nil {
+		return raw
+	}
+	if driver == "mysql" {
+		return maskMySQL(raw)
+	}
+	return keywordPassword.ReplaceAllString(raw, "password="+Masked)
+}
+
+func maskMySQL(raw string) string {
+	cfg, err := mysql.ParseDSN(raw)
+	if err != nil {
+		return Masked
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = Masked
+	}
+	return cfg.FormatDSN()
+}
@@ -3,9 +3,12 @@ package explain
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Mode selects between EXPLAIN and EXPLAIN ANALYZE.
@@ -47,8 +50,15 @@ func (m Mode) prefix(driver Driver) string {
 }
 
 // Result holds the output of an EXPLAIN query.
+//
+// Single-column plans (Postgres, MySQL FORMAT=TREE and EXPLAIN ANALYZE) are
+// returned as text in Plan. Tabular plans, such as TiDB's or MySQL's
+// traditional format, also fill Columns and Rows, and Plan holds them
+// rendered by FormatTable.
 type Result struct {
 	Plan     string
+	Columns  []string
+	Rows     [][]string // one value per column; NULL is "NULL"
 	Duration time.Duration
 }
 
@@ -97,10 +107,7 @@ func (c *Client) Run(ctx context.Context, mode Mode, query string, args []string
 		return nil, fmt.Errorf("explain: columns: %w", err)
 	}
 
-	var lines []string
-	if len(cols) > 1 {
-		lines = append(lines, strings.Join(cols, "\t"))
-	}
+	var rowVals [][]string
 	for rows.Next() {
 		vals := make([]sql.NullString, len(cols))
 		ptrs := make([]any, len(cols))
@@ -110,20 +117,183 @@ func (c *Client) Run(ctx context.Context, mode Mode, query string, args []string
 		if err := rows.Scan(ptrs...); err != nil {
 			return nil, fmt.Errorf("explain: scan: %w", err)
 		}
-		parts := make([]string, len(cols))
+		row := make([]string, len(cols))
 		for i, v := range vals {
-			parts[i] = v.String
+			row[i] = v.String
+			if !v.Valid && len(cols) > 1 {
+				row[i] = "NULL"
+			}
 		}
-		lines = append(lines, strings.Join(parts, "\t"))
+		rowVals = append(rowVals, row)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("explain: rows: %w", err)
 	}
 
-	return &Result{
-		Plan:     strings.Join(lines, "\n"),
-		Duration: time.Since(start),
-	}, nil
+	result := &Result{Duration: time.Since(start)}
+	if len(cols) > 1 {
+		result.Columns = cols
+		result.Rows = rowVals
+		result.Plan = FormatTable(cols, rowVals)
+		return result, nil
+	}
+	lines := make([]string, len(rowVals))
+	for i, row := range rowVals {
+		lines[i] = row[0]
+	}
+	result.Plan = strings.Join(lines, "\n")
+	return result, nil
+}
+
+// FormatTable renders a tabular plan as text: a header, a dashed rule, and
+// one line per row, with columns padded to align.
+func FormatTable(columns []string, rows [][]string) string {
+	widths := make([]int, len(columns))
+	for i, c := range columns {
+		widths[i] = utf8.RuneCountInString(c)
+	}
+	for _, row := range rows {
+		for i, v := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(v))
+			}
+		}
+	}
+
+	rule := make([]string, len(columns))
+	for i, w := range widths {
+		rule[i] = strings.Repeat("-", w)
+	}
+
+	var b strings.Builder
+	writeRow := func(vals []string) {
+		for i, w := range widths {
+			v := ""
+			if i < len(vals) {
+				v = vals[i]
+			}
+			if i == len(widths)-1 {
+				b.WriteString(v) // no trailing padding
+				break
+			}
+			b.WriteString(v)
+			b.WriteString(strings.Repeat(" ", w-utf8.RuneCountInString(v)+2))
+		}
+		b.WriteByte('\n')
+	}
+	writeRow(columns)
+	writeRow(rule)
+	for _, row := range rows {
+		writeRow(row)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// Kill stops the statement running on the server backend pid, as
+// pg_cancel_backend or KILL QUERY do, or with terminate ends the backend's
+// whole session. pid is a PostgreSQL process ID or a MySQL/TiDB connection
+// ID.
+func (c *Client) Kill(ctx context.Context, pid uint32, terminate bool) error {
+	switch c.driver {
+	case MySQL, TiDB:
+		stmt := "KILL QUERY "
+		if terminate {
+			stmt = "KILL "
+		}
+		// KILL takes no placeholders; pid is an integer.
+		if _, err := c.db.ExecContext(ctx, stmt+strconv.FormatUint(uint64(pid), 10)); err != nil {
+			return fmt.Errorf("explain: kill %d: %w", pid, err)
+		}
+		return nil
+	case Postgres:
+	}
+
+	fn := "pg_cancel_backend"
+	if terminate {
+		fn = "pg_terminate_backend"
+	}
+	var ok bool
+	if err := c.db.QueryRowContext(ctx, "SELECT "+fn+"($1)", int64(pid)).Scan(&ok); err != nil {
+		return fmt.Errorf("explain: %s(%d): %w", fn, pid, err)
+	}
+	if !ok {
+		return fmt.Errorf("explain: %s(%d): no such backend", fn, pid)
+	}
+	return nil
+}
+
+// ErrUnsupported is returned for operations the client's driver does not
+// support.
+var ErrUnsupported = errors.New("not supported by this driver")
+
+// PlanJSON returns the plan of query as EXPLAIN (FORMAT JSON) prints it,
+// without running the query. Only PostgreSQL is supported so far.
+func (c *Client) PlanJSON(ctx context.Context, query string, args []string) ([]byte, error) {
+	switch c.driver {
+	case MySQL, TiDB:
+		return nil, fmt.Errorf("explain: JSON plan: %w", ErrUnsupported)
+	case Postgres:
+	}
+
+	anyArgs := make([]any, len(args))
+	for i, a := range args {
+		anyArgs[i] = a
+	}
+	var plan []byte
+	if err := c.db.QueryRowContext(ctx, "EXPLAIN (FORMAT JSON) "+query, anyArgs...).Scan(&plan); err != nil {
+		return nil, fmt.Errorf("explain: JSON plan: %w", err)
+	}
+	return plan, nil
+}
+
+// Statement is one statement's totals in pg_stat_statements, summed across
+// the roles that ran it.
+type Statement struct {
+	Query string // normalized by PostgreSQL, with $1, $2, ... for constants
+	Calls int64
+	Total time.Duration // execution time across all calls
+	Rows  int64
+}
+
+// Statements returns up to limit statements in pg_stat_statements for the
+// current database, highest total execution time first. The totals cover
+// everything since the statistics were last reset, not only what a proxy
+// saw. It needs the pg_stat_statements extension (PostgreSQL 13 or later) and
+// is not supported on MySQL or TiDB.
+func (c *Client) Statements(ctx context.Context, limit int) ([]Statement, error) {
+	switch c.driver {
+	case MySQL, TiDB:
+		return nil, fmt.Errorf("explain: pg_stat_statements: %w", ErrUnsupported)
+	case Postgres:
+	}
+
+	rows, err := c.db.QueryContext(ctx, `SELECT query, SUM(calls)::bigint, SUM(total_exec_time)::float8, SUM(rows)::bigint
+FROM pg_stat_statements
+WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+GROUP BY query
+ORDER BY 3 DESC
+LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("explain: pg_stat_statements: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []Statement
+	for rows.Next() {
+		var (
+			st Statement
+			ms float64
+		)
+		if err := rows.Scan(&st.Query, &st.Calls, &ms, &st.Rows); err != nil {
+			return nil, fmt.Errorf("explain: pg_stat_statements: scan: %w", err)
+		}
+		st.Total = time.Duration(ms * float64(time.Millisecond))
+		out = append(out, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("explain: pg_stat_statements: %w", err)
+	}
+	return out, nil
 }
 
 // Close closes the underlying database connection.
@@ -1,7 +1,16 @@
 package explain_test
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/mickamy/sql-tap/explain"
 )
@@ -27,3 +36,278 @@ func TestMode_String(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatTable(t *testing.T) {
+	t.Parallel()
+
+	got := explain.FormatTable(
+		[]string{"id", "table", "key"},
+		[][]string{
+			{"1", "users", "NULL"},
+			{"2", "orders", "idx_user_id"},
+		},
+	)
+	want := "id  table   key\n" +
+		"--  ------  -----------\n" +
+		"1   users   NULL\n" +
+		"2   orders  idx_user_id"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestClient_Run(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		driver   explain.Driver
+		mode     explain.Mode
+		wantCols []string
+		wantPlan string
+	}{
+		{
+			name:     "single column",
+			driver:   explain.MySQL,
+			mode:     explain.Analyze,
+			wantPlan: "-> Table scan on users\n-> Filter: (id = 1)",
+		},
+		{
+			name:     "tabular",
+			driver:   explain.TiDB,
+			mode:     explain.Explain,
+			wantCols: []string{"id", "estRows", "task"},
+			wantPlan: "id           estRows  task\n" +
+				"-----------  -------  ----\n" +
+				"TableReader  10.00    root\n" +
+				"└─TableScan  NULL     cop",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			db := sql.OpenDB(stubConnector{})
+			c := explain.NewClient(db, tt.driver)
+			t.Cleanup(func() { _ = c.Close() })
+
+			res, err := c.Run(t.Context(), tt.mode, "SELECT * FROM users WHERE id = ?", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(res.Columns, tt.wantCols) {
+				t.Errorf("columns = %v, want %v", res.Columns, tt.wantCols)
+			}
+			if tt.wantCols != nil && len(res.Rows) != 2 {
+				t.Errorf("expected 2 rows, got %d", len(res.Rows))
+			}
+			if res.Plan != tt.wantPlan {
+				t.Errorf("plan:\n%s\nwant:\n%s", res.Plan, tt.wantPlan)
+			}
+		})
+	}
+}
+
+// stubConnector answers EXPLAIN ANALYZE with a single text column and
+// EXPLAIN with a multi-column table containing a NULL.
+type stubConnector struct{}
+
+func (stubConnector) Connect(context.Context) (driver.Conn, error) { return stubConn{}, nil }
+func (stubConnector) Driver() driver.Driver                        { return nil }
+
+type stubConn struct{}
+
+func (stubConn) Prepare(query string) (driver.Stmt, error) { return stubStmt{query: query}, nil }
+func (stubConn) Close() error                              { return nil }
+func (stubConn) Begin() (driver.Tx, error)                 { return nil, errors.New("not supported") }
+
+type stubStmt struct{ query string }
+
+func (stubStmt) Close() error                               { return nil }
+func (stubStmt) NumInput() int                              { return -1 }
+func (stubStmt) Exec([]driver.Value) (driver.Result, error) { return nil, errors.New("not supported") }
+
+func (s stubStmt) Query([]driver.Value) (driver.Rows, error) {
+	if strings.HasPrefix(s.query, "EXPLAIN ANALYZE ") {
+		return &stubRows{cols: []string{"EXPLAIN"}, rows: [][]driver.Value{
+			{"-> Table scan on users"},
+			{"-> Filter: (id = 1)"},
+		}}, nil
+	}
+	return &stubRows{cols: []string{"id", "estRows", "task"}, rows: [][]driver.Value{
+		{"TableReader", "10.00", "root"},
+		{"└─TableScan", nil, "cop"},
+	}}, nil
+}
+
+type stubRows struct {
+	cols []string
+	rows [][]driver.Value
+	next int
+}
+
+func (r *stubRows) Columns() []string { return r.cols }
+func (r *stubRows) Close() error      { return nil }
+
+func (r *stubRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.next])
+	r.next++
+	return nil
+}
+
+func TestClient_Kill(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		driver    explain.Driver
+		pid       uint32
+		terminate bool
+		want      string
+		wantErr   bool
+	}{
+		{name: "postgres cancel", driver: explain.Postgres, pid: 4242, want: "SELECT pg_cancel_backend($1) [4242]"},
+		{name: "postgres terminate", driver: explain.Postgres, pid: 4242, terminate: true, want: "SELECT pg_terminate_backend($1) [4242]"},
+		{name: "postgres no such backend", driver: explain.Postgres, pid: 1, want: "SELECT pg_cancel_backend($1) [1]", wantErr: true},
+		{name: "mysql cancel", driver: explain.MySQL, pid: 17, want: "KILL QUERY 17 []"},
+		{name: "tidb terminate", driver: explain.TiDB, pid: 17, terminate: true, want: "KILL 17 []"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			kc := &killConnector{}
+			c := explain.NewClient(sql.OpenDB(kc), tt.driver)
+			t.Cleanup(func() { _ = c.Close() })
+
+			err := c.Kill(t.Context(), tt.pid, tt.terminate)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Kill() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if kc.stmt != tt.want {
+				t.Errorf("statement = %q, want %q", kc.stmt, tt.want)
+			}
+		})
+	}
+}
+
+// killConnector records the statement it runs; the pg_*_backend functions
+// report success for any PID but 1.
+type killConnector struct{ stmt string }
+
+func (k *killConnector) Connect(context.Context) (driver.Conn, error) { return &killConn{k: k}, nil }
+func (k *killConnector) Driver() driver.Driver                        { return nil }
+
+type killConn struct{ k *killConnector }
+
+func (c *killConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *killConn) Close() error                        { return nil }
+func (c *killConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *killConn) ExecContext(_ context.Context, q string, args []driver.NamedValue) (driver.Result, error) {
+	c.k.stmt = fmt.Sprintf("%s %v", q, namedValues(args))
+	return driver.RowsAffected(0), nil
+}
+
+func (c *killConn) QueryContext(_ context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	vals := namedValues(args)
+	c.k.stmt = fmt.Sprintf("%s %v", q, vals)
+	return &stubRows{cols: []string{"ok"}, rows: [][]driver.Value{{vals[0] != int64(1)}}}, nil
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	vals := make([]driver.Value, len(args))
+	for i, a := range args {
+		vals[i] = a.Value
+	}
+	return vals
+}
+
+func TestClient_Statements(t *testing.T) {
+	t.Parallel()
+
+	sc := &statementsConnector{}
+	c := explain.NewClient(sql.OpenDB(sc), explain.Postgres)
+	t.Cleanup(func() { _ = c.Close() })
+
+	got, err := c.Statements(t.Context(), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sc.stmt, "FROM pg_stat_statements") || !strings.HasSuffix(sc.stmt, "[50]") {
+		t.Errorf("statement = %q, want a pg_stat_statements read limited to 50", sc.stmt)
+	}
+	want := []explain.Statement{
+		{Query: "SELECT * FROM orders WHERE id = $1", Calls: 1200, Total: 1500 * time.Millisecond, Rows: 1200},
+		{Query: "UPDATE orders SET total = $1", Calls: 3, Total: 250 * time.Microsecond, Rows: 9},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("statements = %+v, want %+v", got, want)
+	}
+
+	m := explain.NewClient(sql.OpenDB(sc), explain.MySQL)
+	t.Cleanup(func() { _ = m.Close() })
+	if _, err := m.Statements(t.Context(), 50); !errors.Is(err, explain.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported on MySQL, got %v", err)
+	}
+}
+
+func TestClient_PlanJSON(t *testing.T) {
+	t.Parallel()
+
+	sc := &statementsConnector{}
+	c := explain.NewClient(sql.OpenDB(sc), explain.Postgres)
+	t.Cleanup(func() { _ = c.Close() })
+
+	plan, err := c.PlanJSON(t.Context(), "SELECT * FROM orders WHERE id = $1", []string{"42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "EXPLAIN (FORMAT JSON) SELECT * FROM orders WHERE id = $1 [42]"; sc.stmt != want {
+		t.Errorf("statement = %q, want %q", sc.stmt, want)
+	}
+	if string(plan) != `[{"Plan": {"Node Type": "Index Scan"}}]` {
+		t.Errorf("plan = %s", plan)
+	}
+
+	m := explain.NewClient(sql.OpenDB(sc), explain.MySQL)
+	t.Cleanup(func() { _ = m.Close() })
+	if _, err := m.PlanJSON(t.Context(), "SELECT 1", nil); !errors.Is(err, explain.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported on MySQL, got %v", err)
+	}
+}
+
+// statementsConnector records the query it runs and answers with two
+// pg_stat_statements rows, or a JSON plan.
+type statementsConnector struct{ stmt string }
+
+func (s *statementsConnector) Connect(context.Context) (driver.Conn, error) {
+	return &statementsConn{s: s}, nil
+}
+func (s *statementsConnector) Driver() driver.Driver { return nil }
+
+type statementsConn struct{ s *statementsConnector }
+
+func (c *statementsConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *statementsConn) Close() error              { return nil }
+func (c *statementsConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *statementsConn) QueryContext(_ context.Context, q string, args []driver.NamedValue) (driver.Rows, error) {
+	c.s.stmt = fmt.Sprintf("%s %v", q, namedValues(args))
+	if strings.HasPrefix(q, "EXPLAIN (FORMAT JSON) ") {
+		return &stubRows{cols: []string{"QUERY PLAN"}, rows: [][]driver.Value{
+			{[]byte(`[{"Plan": {"Node Type": "Index Scan"}}]`)},
+		}}, nil
+	}
+	return &stubRows{cols: []string{"query", "sum", "sum", "sum"}, rows: [][]driver.Value{
+		{"SELECT * FROM orders WHERE id = $1", int64(1200), 1500.0, int64(1200)},
+		{"UPDATE orders SET total = $1", int64(3), 0.25, int64(9)},
+	}}, nil
+}
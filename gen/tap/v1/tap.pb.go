@@ -23,36 +23,3998 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+type TrafficKind int32
+
+const (
+	TrafficKind_TRAFFIC_KIND_UNSPECIFIED TrafficKind = 0
+	// The fingerprint ran for the first time.
+	TrafficKind_TRAFFIC_KIND_NEW  TrafficKind = 1
+	TrafficKind_TRAFFIC_KIND_RISE TrafficKind = 2
+	TrafficKind_TRAFFIC_KIND_DROP TrafficKind = 3
+)
+
+// Enum value maps for TrafficKind.
+var (
+	TrafficKind_name = map[int32]string{
+		0: "TRAFFIC_KIND_UNSPECIFIED",
+		1: "TRAFFIC_KIND_NEW",
+		2: "TRAFFIC_KIND_RISE",
+		3: "TRAFFIC_KIND_DROP",
+	}
+	TrafficKind_value = map[string]int32{
+		"TRAFFIC_KIND_UNSPECIFIED": 0,
+		"TRAFFIC_KIND_NEW":         1,
+		"TRAFFIC_KIND_RISE":        2,
+		"TRAFFIC_KIND_DROP":        3,
+	}
+)
+
+func (x TrafficKind) Enum() *TrafficKind {
+	p := new(TrafficKind)
+	*p = x
+	return p
+}
+
+func (x TrafficKind) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TrafficKind) Descriptor() protoreflect.EnumDescriptor {
+	return file_tap_v1_tap_proto_enumTypes[0].Descriptor()
+}
+
+func (TrafficKind) Type() protoreflect.EnumType {
+	return &file_tap_v1_tap_proto_enumTypes[0]
+}
+
+func (x TrafficKind) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TrafficKind.Descriptor instead.
+func (TrafficKind) EnumDescriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{0}
+}
+
+// Delivery selects what the server does when a watcher falls behind.
+type Delivery int32
+
+const (
+	// Drop events the watcher cannot keep up with (default).
+	Delivery_DELIVERY_UNSPECIFIED Delivery = 0
+	// Stall event publishing until the watcher catches up, for up to a few
+	// seconds per event before dropping it. Requires the admin role when the
+	// daemon has auth enabled.
+	Delivery_DELIVERY_BLOCK Delivery = 1
+	// Queue what the watcher cannot keep up with on the daemon's disk, up to
+	// a size limit, and deliver it in order once the watcher catches up.
+	Delivery_DELIVERY_SPILL Delivery = 2
+)
+
+// Enum value maps for Delivery.
+var (
+	Delivery_name = map[int32]string{
+		0: "DELIVERY_UNSPECIFIED",
+		1: "DELIVERY_BLOCK",
+		2: "DELIVERY_SPILL",
+	}
+	Delivery_value = map[string]int32{
+		"DELIVERY_UNSPECIFIED": 0,
+		"DELIVERY_BLOCK":       1,
+		"DELIVERY_SPILL":       2,
+	}
+)
+
+func (x Delivery) Enum() *Delivery {
+	p := new(Delivery)
+	*p = x
+	return p
+}
+
+func (x Delivery) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (Delivery) Descriptor() protoreflect.EnumDescriptor {
+	return file_tap_v1_tap_proto_enumTypes[1].Descriptor()
+}
+
+func (Delivery) Type() protoreflect.EnumType {
+	return &file_tap_v1_tap_proto_enumTypes[1]
+}
+
+func (x Delivery) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use Delivery.Descriptor instead.
+func (Delivery) EnumDescriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{1}
+}
+
+type TxStatus int32
+
+const (
+	TxStatus_TX_STATUS_UNSPECIFIED TxStatus = 0
+	TxStatus_TX_STATUS_OPEN        TxStatus = 1
+	TxStatus_TX_STATUS_COMMITTED   TxStatus = 2
+	TxStatus_TX_STATUS_ROLLED_BACK TxStatus = 3
+	// A two-phase commit branch awaiting COMMIT/ROLLBACK PREPARED.
+	TxStatus_TX_STATUS_PREPARED TxStatus = 4
+)
+
+// Enum value maps for TxStatus.
+var (
+	TxStatus_name = map[int32]string{
+		0: "TX_STATUS_UNSPECIFIED",
+		1: "TX_STATUS_OPEN",
+		2: "TX_STATUS_COMMITTED",
+		3: "TX_STATUS_ROLLED_BACK",
+		4: "TX_STATUS_PREPARED",
+	}
+	TxStatus_value = map[string]int32{
+		"TX_STATUS_UNSPECIFIED": 0,
+		"TX_STATUS_OPEN":        1,
+		"TX_STATUS_COMMITTED":   2,
+		"TX_STATUS_ROLLED_BACK": 3,
+		"TX_STATUS_PREPARED":    4,
+	}
+)
+
+func (x TxStatus) Enum() *TxStatus {
+	p := new(TxStatus)
+	*p = x
+	return p
+}
+
+func (x TxStatus) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (TxStatus) Descriptor() protoreflect.EnumDescriptor {
+	return file_tap_v1_tap_proto_enumTypes[2].Descriptor()
+}
+
+func (TxStatus) Type() protoreflect.EnumType {
+	return &file_tap_v1_tap_proto_enumTypes[2]
+}
+
+func (x TxStatus) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use TxStatus.Descriptor instead.
+func (TxStatus) EnumDescriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{2}
+}
+
+type Phase struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Duration      *durationpb.Duration   `protobuf:"bytes,2,opt,name=duration,proto3" json:"duration,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Phase) Reset() {
+	*x = Phase{}
+	mi := &file_tap_v1_tap_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Phase) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Phase) ProtoMessage() {}
+
+func (x *Phase) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Phase.ProtoReflect.Descriptor instead.
+func (*Phase) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Phase) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Phase) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+type Row struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []string               `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Row) Reset() {
+	*x = Row{}
+	mi := &file_tap_v1_tap_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Row) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Row) ProtoMessage() {}
+
+func (x *Row) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Row.ProtoReflect.Descriptor instead.
+func (*Row) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Row) GetValues() []string {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+// ErrorDetail is the structured form of a failed query's error, or of a
+// notice, as reported by the server. MySQL reports only code and message.
+type ErrorDetail struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// SQLSTATE, e.g. "42P01".
+	Code string `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	// e.g. "ERROR" or "FATAL" (Postgres).
+	Severity string `protobuf:"bytes,2,opt,name=severity,proto3" json:"severity,omitempty"`
+	Message  string `protobuf:"bytes,3,opt,name=message,proto3" json:"message,omitempty"`
+	Detail   string `protobuf:"bytes,4,opt,name=detail,proto3" json:"detail,omitempty"`
+	Hint     string `protobuf:"bytes,5,opt,name=hint,proto3" json:"hint,omitempty"`
+	// 1-based character offset of the error in the query; 0 when unknown.
+	Position      int32 `protobuf:"varint,6,opt,name=position,proto3" json:"position,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorDetail) Reset() {
+	*x = ErrorDetail{}
+	mi := &file_tap_v1_tap_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorDetail) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorDetail) ProtoMessage() {}
+
+func (x *ErrorDetail) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorDetail.ProtoReflect.Descriptor instead.
+func (*ErrorDetail) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *ErrorDetail) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ErrorDetail) GetSeverity() string {
+	if x != nil {
+		return x.Severity
+	}
+	return ""
+}
+
+func (x *ErrorDetail) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ErrorDetail) GetDetail() string {
+	if x != nil {
+		return x.Detail
+	}
+	return ""
+}
+
+func (x *ErrorDetail) GetHint() string {
+	if x != nil {
+		return x.Hint
+	}
+	return ""
+}
+
+func (x *ErrorDetail) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+// Anomaly marks an event much slower than its query fingerprint's recent
+// baseline.
+type Anomaly struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Standard deviations above the baseline mean.
+	Score float64 `protobuf:"fixed64,1,opt,name=score,proto3" json:"score,omitempty"`
+	// The baseline mean latency.
+	Baseline      *durationpb.Duration `protobuf:"bytes,2,opt,name=baseline,proto3" json:"baseline,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Anomaly) Reset() {
+	*x = Anomaly{}
+	mi := &file_tap_v1_tap_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Anomaly) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Anomaly) ProtoMessage() {}
+
+func (x *Anomaly) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Anomaly.ProtoReflect.Descriptor instead.
+func (*Anomaly) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Anomaly) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Anomaly) GetBaseline() *durationpb.Duration {
+	if x != nil {
+		return x.Baseline
+	}
+	return nil
+}
+
+// NPlusOne marks an event in a burst of calls of its query fingerprint from
+// one connection, a probable N+1 pattern.
+type NPlusOne struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Calls in the burst so far, including this one.
+	Calls int64 `protobuf:"varint,1,opt,name=calls,proto3" json:"calls,omitempty"`
+	// From the burst's first call to this one.
+	Span *durationpb.Duration `protobuf:"bytes,2,opt,name=span,proto3" json:"span,omitempty"`
+	// The burst is within a transaction rather than a time window.
+	InTx          bool `protobuf:"varint,3,opt,name=in_tx,json=inTx,proto3" json:"in_tx,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NPlusOne) Reset() {
+	*x = NPlusOne{}
+	mi := &file_tap_v1_tap_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NPlusOne) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NPlusOne) ProtoMessage() {}
+
+func (x *NPlusOne) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NPlusOne.ProtoReflect.Descriptor instead.
+func (*NPlusOne) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NPlusOne) GetCalls() int64 {
+	if x != nil {
+		return x.Calls
+	}
+	return 0
+}
+
+func (x *NPlusOne) GetSpan() *durationpb.Duration {
+	if x != nil {
+		return x.Span
+	}
+	return nil
+}
+
+func (x *NPlusOne) GetInTx() bool {
+	if x != nil {
+		return x.InTx
+	}
+	return false
+}
+
+// TrafficChange describes a drastic shift in how often a query fingerprint
+// runs, on an advisory event (op 9).
+type TrafficChange struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Kind  TrafficKind            `protobuf:"varint,1,opt,name=kind,proto3,enum=tap.v1.TrafficKind" json:"kind,omitempty"`
+	// Calls in the window that revealed the change; 0 for a new fingerprint.
+	Calls int64 `protobuf:"varint,2,opt,name=calls,proto3" json:"calls,omitempty"`
+	// Average calls per window before it.
+	Baseline      float64              `protobuf:"fixed64,3,opt,name=baseline,proto3" json:"baseline,omitempty"`
+	Window        *durationpb.Duration `protobuf:"bytes,4,opt,name=window,proto3" json:"window,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TrafficChange) Reset() {
+	*x = TrafficChange{}
+	mi := &file_tap_v1_tap_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TrafficChange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TrafficChange) ProtoMessage() {}
+
+func (x *TrafficChange) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TrafficChange.ProtoReflect.Descriptor instead.
+func (*TrafficChange) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *TrafficChange) GetKind() TrafficKind {
+	if x != nil {
+		return x.Kind
+	}
+	return TrafficKind_TRAFFIC_KIND_UNSPECIFIED
+}
+
+func (x *TrafficChange) GetCalls() int64 {
+	if x != nil {
+		return x.Calls
+	}
+	return 0
+}
+
+func (x *TrafficChange) GetBaseline() float64 {
+	if x != nil {
+		return x.Baseline
+	}
+	return 0
+}
+
+func (x *TrafficChange) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+// A panic recovered from a proxy connection's handling or a pipeline stage.
+type Panic struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The code that panicked, e.g. "postgres: client relay" or "pipeline".
+	Where string `protobuf:"bytes,1,opt,name=where,proto3" json:"where,omitempty"`
+	// What it panicked with.
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	// The panicking goroutine's stack.
+	Stack         string `protobuf:"bytes,3,opt,name=stack,proto3" json:"stack,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Panic) Reset() {
+	*x = Panic{}
+	mi := &file_tap_v1_tap_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Panic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Panic) ProtoMessage() {}
+
+func (x *Panic) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Panic.ProtoReflect.Descriptor instead.
+func (*Panic) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *Panic) GetWhere() string {
+	if x != nil {
+		return x.Where
+	}
+	return ""
+}
+
+func (x *Panic) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *Panic) GetStack() string {
+	if x != nil {
+		return x.Stack
+	}
+	return ""
+}
+
+// A connection error a proxy logged that no connect or disconnect event
+// records, on an advisory event (op 9) whose error is the cause.
+type Diagnostic struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The log level: "WARN" or "ERROR".
+	Level string `protobuf:"bytes,1,opt,name=level,proto3" json:"level,omitempty"`
+	// The log message, e.g. "relay failed".
+	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Diagnostic) Reset() {
+	*x = Diagnostic{}
+	mi := &file_tap_v1_tap_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Diagnostic) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Diagnostic) ProtoMessage() {}
+
+func (x *Diagnostic) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Diagnostic.ProtoReflect.Descriptor instead.
+func (*Diagnostic) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Diagnostic) GetLevel() string {
+	if x != nil {
+		return x.Level
+	}
+	return ""
+}
+
+func (x *Diagnostic) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+// An EXPLAIN plan the daemon ran on its own for a slow statement.
+type AutoPlan struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// ID of the slow event the plan was run for.
+	ForId string `protobuf:"bytes,1,opt,name=for_id,json=forId,proto3" json:"for_id,omitempty"`
+	// EXPLAIN ANALYZE rather than EXPLAIN; only used for plain SELECT
+	// statements.
+	Analyze bool `protobuf:"varint,2,opt,name=analyze,proto3" json:"analyze,omitempty"`
+	// Text plan; for tabular plans, the columns and rows rendered as a table.
+	Plan string `protobuf:"bytes,3,opt,name=plan,proto3" json:"plan,omitempty"`
+	// Suggested CREATE INDEX statements for expensive sequential scans.
+	Indexes       []string `protobuf:"bytes,4,rep,name=indexes,proto3" json:"indexes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AutoPlan) Reset() {
+	*x = AutoPlan{}
+	mi := &file_tap_v1_tap_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AutoPlan) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AutoPlan) ProtoMessage() {}
+
+func (x *AutoPlan) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AutoPlan.ProtoReflect.Descriptor instead.
+func (*AutoPlan) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *AutoPlan) GetForId() string {
+	if x != nil {
+		return x.ForId
+	}
+	return ""
+}
+
+func (x *AutoPlan) GetAnalyze() bool {
+	if x != nil {
+		return x.Analyze
+	}
+	return false
+}
+
+func (x *AutoPlan) GetPlan() string {
+	if x != nil {
+		return x.Plan
+	}
+	return ""
+}
+
+func (x *AutoPlan) GetIndexes() []string {
+	if x != nil {
+		return x.Indexes
+	}
+	return nil
+}
+
+// TenantQuota describes a tenant that took more than its share of the
+// queries in a window.
+type TenantQuota struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The extracted field naming tenants, e.g. tenant_id, and the tenant.
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	// The tenant's queries and all queries in the window.
+	Calls int64 `protobuf:"varint,3,opt,name=calls,proto3" json:"calls,omitempty"`
+	Total int64 `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	// The share of total the tenant exceeded, in (0, 1).
+	MaxShare      float64              `protobuf:"fixed64,5,opt,name=max_share,json=maxShare,proto3" json:"max_share,omitempty"`
+	Window        *durationpb.Duration `protobuf:"bytes,6,opt,name=window,proto3" json:"window,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TenantQuota) Reset() {
+	*x = TenantQuota{}
+	mi := &file_tap_v1_tap_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TenantQuota) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TenantQuota) ProtoMessage() {}
+
+func (x *TenantQuota) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TenantQuota.ProtoReflect.Descriptor instead.
+func (*TenantQuota) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *TenantQuota) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *TenantQuota) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *TenantQuota) GetCalls() int64 {
+	if x != nil {
+		return x.Calls
+	}
+	return 0
+}
+
+func (x *TenantQuota) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *TenantQuota) GetMaxShare() float64 {
+	if x != nil {
+		return x.MaxShare
+	}
+	return 0
+}
+
+func (x *TenantQuota) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+// Violation records that a statement broke a rule of the daemon's policy.
+type Column struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// The server's type name, e.g. "int4" or "text" (PostgreSQL; the type
+	// OID for user-defined types) or "VARCHAR" (MySQL).
+	Type          string `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Column) Reset() {
+	*x = Column{}
+	mi := &file_tap_v1_tap_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Column) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Column) ProtoMessage() {}
+
+func (x *Column) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Column.ProtoReflect.Descriptor instead.
+func (*Column) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *Column) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Column) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+type Violation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The rule that matched; "default" under a deny default.
+	Rule string `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
+	// The error the client got in place of the statement's result.
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// False in dry-run mode, where the statement was forwarded anyway.
+	Blocked       bool `protobuf:"varint,3,opt,name=blocked,proto3" json:"blocked,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Violation) Reset() {
+	*x = Violation{}
+	mi := &file_tap_v1_tap_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Violation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Violation) ProtoMessage() {}
+
+func (x *Violation) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Violation.ProtoReflect.Descriptor instead.
+func (*Violation) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *Violation) GetRule() string {
+	if x != nil {
+		return x.Rule
+	}
+	return ""
+}
+
+func (x *Violation) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *Violation) GetBlocked() bool {
+	if x != nil {
+		return x.Blocked
+	}
+	return false
+}
+
+// Routing records where a proxy in replica routing mode sent a query.
+type Routing struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sent to the read replica rather than the primary.
+	Replica bool `protobuf:"varint,1,opt,name=replica,proto3" json:"replica,omitempty"`
+	// Why: "read-only", "write", "transaction", "pipelined", or
+	// "replica unavailable".
+	Reason        string `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Routing) Reset() {
+	*x = Routing{}
+	mi := &file_tap_v1_tap_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Routing) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Routing) ProtoMessage() {}
+
+func (x *Routing) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Routing.ProtoReflect.Descriptor instead.
+func (*Routing) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Routing) GetReplica() bool {
+	if x != nil {
+		return x.Replica
+	}
+	return false
+}
+
+func (x *Routing) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 type QueryEvent struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	Id           string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Op           int32                  `protobuf:"varint,2,opt,name=op,proto3" json:"op,omitempty"`
+	Query        string                 `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	Args         []string               `protobuf:"bytes,4,rep,name=args,proto3" json:"args,omitempty"`
+	StartTime    *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	Duration     *durationpb.Duration   `protobuf:"bytes,6,opt,name=duration,proto3" json:"duration,omitempty"`
+	RowsAffected int64                  `protobuf:"varint,7,opt,name=rows_affected,json=rowsAffected,proto3" json:"rows_affected,omitempty"`
+	Error        string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	TxId         string                 `protobuf:"bytes,9,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	TlsVersion   string                 `protobuf:"bytes,10,opt,name=tls_version,json=tlsVersion,proto3" json:"tls_version,omitempty"`
+	TlsCipher    string                 `protobuf:"bytes,11,opt,name=tls_cipher,json=tlsCipher,proto3" json:"tls_cipher,omitempty"`
+	ConnId       string                 `protobuf:"bytes,12,opt,name=conn_id,json=connId,proto3" json:"conn_id,omitempty"`
+	// Populated only when detailed capture is enabled for the connection.
+	Phases     []*Phase `protobuf:"bytes,13,rep,name=phases,proto3" json:"phases,omitempty"`
+	RowSamples []*Row   `protobuf:"bytes,14,rep,name=row_samples,json=rowSamples,proto3" json:"row_samples,omitempty"`
+	// Name of the upstream the event was captured from; empty with a single upstream.
+	Upstream string `protobuf:"bytes,15,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	// Labels from the daemon's tagging rules.
+	Tags []string `protobuf:"bytes,16,rep,name=tags,proto3" json:"tags,omitempty"`
+	// Distributed transaction id (Postgres gid, MySQL XA gtrid); set on
+	// two-phase commit statements only.
+	GlobalTxId string `protobuf:"bytes,17,opt,name=global_tx_id,json=globalTxId,proto3" json:"global_tx_id,omitempty"`
+	// Set when the event summarizes a Postgres cursor from DECLARE to CLOSE:
+	// query is the cursor's query, rows_affected the rows fetched, and
+	// duration the total time spent in its statements.
+	Cursor string `protobuf:"bytes,18,opt,name=cursor,proto3" json:"cursor,omitempty"`
+	// FETCH/MOVE statements folded into a cursor summary.
+	Fetches int32 `protobuf:"varint,19,opt,name=fetches,proto3" json:"fetches,omitempty"`
+	// W3C trace context from the query's sqlcommenter traceparent comment:
+	// the trace ID and the calling span's ID, both lowercase hex.
+	TraceId string `protobuf:"bytes,20,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	SpanId  string `protobuf:"bytes,21,opt,name=span_id,json=spanId,proto3" json:"span_id,omitempty"`
+	// Set on failed queries when the server reported a structured error.
+	ErrorDetail *ErrorDetail `protobuf:"bytes,22,opt,name=error_detail,json=errorDetail,proto3" json:"error_detail,omitempty"`
+	// Set when the event ran much slower than usual for its query.
+	Anomaly *Anomaly `protobuf:"bytes,23,opt,name=anomaly,proto3" json:"anomaly,omitempty"`
+	// The query with literals and placeholders normalized to ?, shared by
+	// statements that differ only in their values.
+	Fingerprint string `protobuf:"bytes,24,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	// Remote address of the client connection.
+	ClientAddr string `protobuf:"bytes,25,opt,name=client_addr,json=clientAddr,proto3" json:"client_addr,omitempty"`
+	// Database user and database the client connected with.
+	User     string `protobuf:"bytes,26,opt,name=user,proto3" json:"user,omitempty"`
+	Database string `protobuf:"bytes,27,opt,name=database,proto3" json:"database,omitempty"`
+	// Set on advisory events from the daemon's traffic detector, whose query
+	// and fingerprint are the fingerprint that changed.
+	Traffic *TrafficChange `protobuf:"bytes,28,opt,name=traffic,proto3" json:"traffic,omitempty"`
+	// HTTP route from the query's sqlcommenter comment, e.g. "GET /users/{id}".
+	Route string `protobuf:"bytes,29,opt,name=route,proto3" json:"route,omitempty"`
+	// Server process (PostgreSQL) or connection (MySQL) ID serving the
+	// client's connection, for the Kill RPC; 0 when unknown.
+	BackendPid uint32 `protobuf:"varint,30,opt,name=backend_pid,json=backendPid,proto3" json:"backend_pid,omitempty"`
+	// HTTP request from the same comment's request_id key.
+	RequestId string `protobuf:"bytes,31,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	// Set when the event is part of a probable N+1 burst.
+	NPlusOne *NPlusOne `protobuf:"bytes,32,opt,name=n_plus_one,json=nPlusOne,proto3" json:"n_plus_one,omitempty"`
+	// Authentication the server asked for when the client connected, e.g.
+	// "SCRAM-SHA-256", "md5", or "trust", and how long it took up to
+	// AuthenticationOk. PostgreSQL only.
+	AuthMethod   string               `protobuf:"bytes,33,opt,name=auth_method,json=authMethod,proto3" json:"auth_method,omitempty"`
+	AuthDuration *durationpb.Duration `protobuf:"bytes,34,opt,name=auth_duration,json=authDuration,proto3" json:"auth_duration,omitempty"`
+	// ParameterStatus values the server reported at startup, e.g.
+	// server_version and TimeZone. PostgreSQL only.
+	ServerParams map[string]string `protobuf:"bytes,35,rep,name=server_params,json=serverParams,proto3" json:"server_params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Set when the daemon routes between a primary and a read replica.
+	Routing *Routing `protobuf:"bytes,36,opt,name=routing,proto3" json:"routing,omitempty"`
+	// Wire size of the client messages that issued the query (for an
+	// extended-protocol execute, its Parse and Bind too) and of the server's
+	// reply up to the statement's completion.
+	RequestBytes  int64 `protobuf:"varint,37,opt,name=request_bytes,json=requestBytes,proto3" json:"request_bytes,omitempty"`
+	ResponseBytes int64 `protobuf:"varint,38,opt,name=response_bytes,json=responseBytes,proto3" json:"response_bytes,omitempty"`
+	// Shared by the statements a Postgres client pipelined before one Sync
+	// and by the batch summary event (op 10) sent once the Sync is answered,
+	// whose batch_size is the number of statements, including any the server
+	// skipped after an error.
+	BatchId   string `protobuf:"bytes,39,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	BatchSize int32  `protobuf:"varint,40,opt,name=batch_size,json=batchSize,proto3" json:"batch_size,omitempty"`
+	// Set on notice events (op 11): a NOTICE, WARNING, or other non-error
+	// message the Postgres server sent, such as RAISE NOTICE output. query and
+	// tx_id are those of the statement that raised it, whose id is notice_for;
+	// it is empty when the server sent the notice between statements.
+	Notice    *ErrorDetail `protobuf:"bytes,41,opt,name=notice,proto3" json:"notice,omitempty"`
+	NoticeFor string       `protobuf:"bytes,42,opt,name=notice_for,json=noticeFor,proto3" json:"notice_for,omitempty"`
+	// Set on disconnect events (op 13), which start when the connection was
+	// accepted and last its lifetime: the query, exec, and execute events it
+	// produced. Connect events (op 12) last from accept to the end of
+	// authentication and carry the error when it failed.
+	Queries int64 `protobuf:"varint,43,opt,name=queries,proto3" json:"queries,omitempty"`
+	// Set on connect events: the parameters of the client's StartupMessage,
+	// such as application_name and options, with values that may hold
+	// credentials redacted, and whether the client asked for SSL before it.
+	// An SSL request without tls_version was declined. PostgreSQL only.
+	StartupParams map[string]string `protobuf:"bytes,44,rep,name=startup_params,json=startupParams,proto3" json:"startup_params,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	SslRequested  bool              `protobuf:"varint,45,opt,name=ssl_requested,json=sslRequested,proto3" json:"ssl_requested,omitempty"`
+	// Values pulled from the query by the daemon's field extraction rules,
+	// keyed by field name, e.g. tenant_id.
+	Fields map[string]string `protobuf:"bytes,46,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Set on advisory events (op 9) from the tenant quota tracker; query is
+	// field=value and fields holds the tenant.
+	Quota *TenantQuota `protobuf:"bytes,47,opt,name=quota,proto3" json:"quota,omitempty"`
+	// Set on advisory events (op 9) from the daemon's auto-explain: query,
+	// args, fingerprint, and upstream are the slow statement's, and duration
+	// is how long EXPLAIN took.
+	Plan *AutoPlan `protobuf:"bytes,48,opt,name=plan,proto3" json:"plan,omitempty"`
+	// Set on advisory events (op 9) reporting a panic the daemon recovered
+	// from; error describes it. The event identifies the connection that was
+	// closed, or, for a panic in the daemon's pipeline, is the event that was
+	// dropped.
+	Panic *Panic `protobuf:"bytes,49,opt,name=panic,proto3" json:"panic,omitempty"`
+	// The parts of duration, for PostgreSQL statements: the time queued
+	// behind statements pipelined before it, until the server sent the first
+	// row (or completed, for statements without rows), and streaming the
+	// rows. Unset for other events.
+	QueueLatency *durationpb.Duration `protobuf:"bytes,50,opt,name=queue_latency,json=queueLatency,proto3" json:"queue_latency,omitempty"`
+	ExecLatency  *durationpb.Duration `protobuf:"bytes,51,opt,name=exec_latency,json=execLatency,proto3" json:"exec_latency,omitempty"`
+	FetchLatency *durationpb.Duration `protobuf:"bytes,52,opt,name=fetch_latency,json=fetchLatency,proto3" json:"fetch_latency,omitempty"`
+	// Call stack that issued the query, innermost frame first, from the same
+	// comment's caller key, as the sqlcomment package's Caller option adds.
+	Caller []string `protobuf:"bytes,53,rep,name=caller,proto3" json:"caller,omitempty"`
+	// Metadata an integrator embedding the proxies attached to the event, as
+	// proxy.Event.Extensions; sql-tap itself sets none.
+	Extensions map[string]string `protobuf:"bytes,54,rep,name=extensions,proto3" json:"extensions,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// On a pooler's server connections (sql-tapd -pooler), the logical
+	// client session the event ran in, numbered from 1 per connection; 0
+	// otherwise.
+	Session uint64 `protobuf:"varint,55,opt,name=session,proto3" json:"session,omitempty"`
+	// A connection error the proxy logged, on an advisory event (op 9).
+	Diagnostic *Diagnostic `protobuf:"bytes,56,opt,name=diagnostic,proto3" json:"diagnostic,omitempty"`
+	// Set when the statement broke a rule of the daemon's policy.
+	Violation *Violation `protobuf:"bytes,57,opt,name=violation,proto3" json:"violation,omitempty"`
+	// The statement's result columns as the server described them: a
+	// PostgreSQL RowDescription, for a prepared statement the one answering
+	// its Describe, or a MySQL result set's column definitions. Empty for
+	// statements that return no rows.
+	Columns []*Column `protobuf:"bytes,58,rep,name=columns,proto3" json:"columns,omitempty"`
+	// Label of the proxy listener the client connected through; empty for
+	// listeners without one.
+	Listener      string `protobuf:"bytes,59,opt,name=listener,proto3" json:"listener,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryEvent) Reset() {
+	*x = QueryEvent{}
+	mi := &file_tap_v1_tap_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryEvent) ProtoMessage() {}
+
+func (x *QueryEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryEvent.ProtoReflect.Descriptor instead.
+func (*QueryEvent) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *QueryEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetOp() int32 {
+	if x != nil {
+		return x.Op
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetRowsAffected() int64 {
+	if x != nil {
+		return x.RowsAffected
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetTxId() string {
+	if x != nil {
+		return x.TxId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetTlsVersion() string {
+	if x != nil {
+		return x.TlsVersion
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetTlsCipher() string {
+	if x != nil {
+		return x.TlsCipher
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetConnId() string {
+	if x != nil {
+		return x.ConnId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetPhases() []*Phase {
+	if x != nil {
+		return x.Phases
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetRowSamples() []*Row {
+	if x != nil {
+		return x.RowSamples
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetTags() []string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetGlobalTxId() string {
+	if x != nil {
+		return x.GlobalTxId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetCursor() string {
+	if x != nil {
+		return x.Cursor
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetFetches() int32 {
+	if x != nil {
+		return x.Fetches
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetSpanId() string {
+	if x != nil {
+		return x.SpanId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetErrorDetail() *ErrorDetail {
+	if x != nil {
+		return x.ErrorDetail
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetAnomaly() *Anomaly {
+	if x != nil {
+		return x.Anomaly
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetFingerprint() string {
+	if x != nil {
+		return x.Fingerprint
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetClientAddr() string {
+	if x != nil {
+		return x.ClientAddr
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetUser() string {
+	if x != nil {
+		return x.User
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetTraffic() *TrafficChange {
+	if x != nil {
+		return x.Traffic
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetBackendPid() uint32 {
+	if x != nil {
+		return x.BackendPid
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetNPlusOne() *NPlusOne {
+	if x != nil {
+		return x.NPlusOne
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetAuthMethod() string {
+	if x != nil {
+		return x.AuthMethod
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetAuthDuration() *durationpb.Duration {
+	if x != nil {
+		return x.AuthDuration
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetServerParams() map[string]string {
+	if x != nil {
+		return x.ServerParams
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetRouting() *Routing {
+	if x != nil {
+		return x.Routing
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetRequestBytes() int64 {
+	if x != nil {
+		return x.RequestBytes
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetResponseBytes() int64 {
+	if x != nil {
+		return x.ResponseBytes
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetBatchSize() int32 {
+	if x != nil {
+		return x.BatchSize
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetNotice() *ErrorDetail {
+	if x != nil {
+		return x.Notice
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetNoticeFor() string {
+	if x != nil {
+		return x.NoticeFor
+	}
+	return ""
+}
+
+func (x *QueryEvent) GetQueries() int64 {
+	if x != nil {
+		return x.Queries
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetStartupParams() map[string]string {
+	if x != nil {
+		return x.StartupParams
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetSslRequested() bool {
+	if x != nil {
+		return x.SslRequested
+	}
+	return false
+}
+
+func (x *QueryEvent) GetFields() map[string]string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetQuota() *TenantQuota {
+	if x != nil {
+		return x.Quota
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetPlan() *AutoPlan {
+	if x != nil {
+		return x.Plan
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetPanic() *Panic {
+	if x != nil {
+		return x.Panic
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetQueueLatency() *durationpb.Duration {
+	if x != nil {
+		return x.QueueLatency
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetExecLatency() *durationpb.Duration {
+	if x != nil {
+		return x.ExecLatency
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetFetchLatency() *durationpb.Duration {
+	if x != nil {
+		return x.FetchLatency
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetCaller() []string {
+	if x != nil {
+		return x.Caller
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetExtensions() map[string]string {
+	if x != nil {
+		return x.Extensions
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetSession() uint64 {
+	if x != nil {
+		return x.Session
+	}
+	return 0
+}
+
+func (x *QueryEvent) GetDiagnostic() *Diagnostic {
+	if x != nil {
+		return x.Diagnostic
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetViolation() *Violation {
+	if x != nil {
+		return x.Violation
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetColumns() []*Column {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *QueryEvent) GetListener() string {
+	if x != nil {
+		return x.Listener
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Delivery Delivery               `protobuf:"varint,1,opt,name=delivery,proto3,enum=tap.v1.Delivery" json:"delivery,omitempty"`
+	// Who is watching, as user@host; shown to other watchers via Stats and
+	// recorded in the daemon's audit log.
+	Client string `protobuf:"bytes,2,opt,name=client,proto3" json:"client,omitempty"`
+	// Also stream annotations and presence changes. The server first sends
+	// the current presence list and every stored annotation.
+	Collaborate bool `protobuf:"varint,3,opt,name=collaborate,proto3" json:"collaborate,omitempty"`
+	// Thin out this watcher's events; unset keeps everything.
+	Sampling *Sampling `protobuf:"bytes,4,opt,name=sampling,proto3" json:"sampling,omitempty"`
+	// Resume an interrupted watch: before live events, the server replays
+	// the stored events that completed after this time, which the watcher
+	// sets to the end (start_time + duration) of the newest event it
+	// received. Ignored when the daemon has no event store.
+	ResumeAfter *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=resume_after,json=resumeAfter,proto3" json:"resume_after,omitempty"`
+	// Deliver only the events it matches; unset delivers everything. The
+	// daemon filters before queueing, so a narrow watcher is not slowed, or
+	// made to drop events, by traffic it does not want.
+	Selector      *Selector `protobuf:"bytes,6,opt,name=selector,proto3" json:"selector,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *WatchRequest) GetDelivery() Delivery {
+	if x != nil {
+		return x.Delivery
+	}
+	return Delivery_DELIVERY_UNSPECIFIED
+}
+
+func (x *WatchRequest) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetCollaborate() bool {
+	if x != nil {
+		return x.Collaborate
+	}
+	return false
+}
+
+func (x *WatchRequest) GetSampling() *Sampling {
+	if x != nil {
+		return x.Sampling
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetResumeAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ResumeAfter
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetSelector() *Selector {
+	if x != nil {
+		return x.Selector
+	}
+	return nil
+}
+
+// Selector picks events by where they came from and what they ran. Each
+// field left empty matches every event; set fields must all match.
+type Selector struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Upstream names, as given to -tap; "" is the default upstream.
+	Upstreams []string `protobuf:"bytes,1,rep,name=upstreams,proto3" json:"upstreams,omitempty"`
+	// Op names, e.g. "Query", "Execute", "Begin".
+	Ops []string `protobuf:"bytes,2,rep,name=ops,proto3" json:"ops,omitempty"`
+	// Case-insensitive prefix of the query fingerprint, e.g. "select * from orders".
+	FingerprintPrefix string `protobuf:"bytes,3,opt,name=fingerprint_prefix,json=fingerprintPrefix,proto3" json:"fingerprint_prefix,omitempty"`
+	// Extracted field values the event must carry, e.g. tenant_id: "42".
+	Fields map[string]string `protobuf:"bytes,4,rep,name=fields,proto3" json:"fields,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Databases selected when the client connected.
+	Databases []string `protobuf:"bytes,5,rep,name=databases,proto3" json:"databases,omitempty"`
+	// Database users the client authenticated as.
+	Users         []string `protobuf:"bytes,6,rep,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Selector) Reset() {
+	*x = Selector{}
+	mi := &file_tap_v1_tap_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Selector) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Selector) ProtoMessage() {}
+
+func (x *Selector) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Selector.ProtoReflect.Descriptor instead.
+func (*Selector) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *Selector) GetUpstreams() []string {
+	if x != nil {
+		return x.Upstreams
+	}
+	return nil
+}
+
+func (x *Selector) GetOps() []string {
+	if x != nil {
+		return x.Ops
+	}
+	return nil
+}
+
+func (x *Selector) GetFingerprintPrefix() string {
+	if x != nil {
+		return x.FingerprintPrefix
+	}
+	return ""
+}
+
+func (x *Selector) GetFields() map[string]string {
+	if x != nil {
+		return x.Fields
+	}
+	return nil
+}
+
+func (x *Selector) GetDatabases() []string {
+	if x != nil {
+		return x.Databases
+	}
+	return nil
+}
+
+func (x *Selector) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+// Sampling rules for high-traffic databases. Zero fields disable their rule.
+// Failed queries are never sampled out.
+type Sampling struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Keep each event with this probability, in (0, 1].
+	Rate float64 `protobuf:"fixed64,1,opt,name=rate,proto3" json:"rate,omitempty"`
+	// Keep at most this many events per query fingerprint per second.
+	PerFingerprint int32 `protobuf:"varint,2,opt,name=per_fingerprint,json=perFingerprint,proto3" json:"per_fingerprint,omitempty"`
+	// Keep at most this many events per second overall.
+	MaxPerSecond  int32 `protobuf:"varint,3,opt,name=max_per_second,json=maxPerSecond,proto3" json:"max_per_second,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sampling) Reset() {
+	*x = Sampling{}
+	mi := &file_tap_v1_tap_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sampling) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sampling) ProtoMessage() {}
+
+func (x *Sampling) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sampling.ProtoReflect.Descriptor instead.
+func (*Sampling) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *Sampling) GetRate() float64 {
+	if x != nil {
+		return x.Rate
+	}
+	return 0
+}
+
+func (x *Sampling) GetPerFingerprint() int32 {
+	if x != nil {
+		return x.PerFingerprint
+	}
+	return 0
+}
+
+func (x *Sampling) GetMaxPerSecond() int32 {
+	if x != nil {
+		return x.MaxPerSecond
+	}
+	return 0
+}
+
+// Exactly one field is set; annotation and presence are only sent to
+// watchers that asked to collaborate.
+type WatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Event         *QueryEvent            `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	Annotation    *Annotation            `protobuf:"bytes,2,opt,name=annotation,proto3" json:"annotation,omitempty"`
+	Presence      *Presence              `protobuf:"bytes,3,opt,name=presence,proto3" json:"presence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchResponse) Reset() {
+	*x = WatchResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchResponse) ProtoMessage() {}
+
+func (x *WatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
+func (*WatchResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *WatchResponse) GetEvent() *QueryEvent {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetAnnotation() *Annotation {
+	if x != nil {
+		return x.Annotation
+	}
+	return nil
+}
+
+func (x *WatchResponse) GetPresence() *Presence {
+	if x != nil {
+		return x.Presence
+	}
+	return nil
+}
+
+// A note a watcher attached to an event, shared with everyone watching.
+type Annotation struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	EventId string                 `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// Empty when the note was cleared.
+	Text string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	// The client identity of whoever wrote it.
+	Author        string                 `protobuf:"bytes,3,opt,name=author,proto3" json:"author,omitempty"`
+	Time          *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=time,proto3" json:"time,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Annotation) Reset() {
+	*x = Annotation{}
+	mi := &file_tap_v1_tap_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Annotation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Annotation) ProtoMessage() {}
+
+func (x *Annotation) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Annotation.ProtoReflect.Descriptor instead.
+func (*Annotation) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Annotation) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *Annotation) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *Annotation) GetAuthor() string {
+	if x != nil {
+		return x.Author
+	}
+	return ""
+}
+
+func (x *Annotation) GetTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Time
+	}
+	return nil
+}
+
+// The collaborating watchers, in the order they connected.
+type Presence struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Clients       []string               `protobuf:"bytes,1,rep,name=clients,proto3" json:"clients,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Presence) Reset() {
+	*x = Presence{}
+	mi := &file_tap_v1_tap_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Presence) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Presence) ProtoMessage() {}
+
+func (x *Presence) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Presence.ProtoReflect.Descriptor instead.
+func (*Presence) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *Presence) GetClients() []string {
+	if x != nil {
+		return x.Clients
+	}
+	return nil
+}
+
+type AnnotateRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	EventId string                 `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// Replaces any earlier note on the event; empty clears it.
+	Text string `protobuf:"bytes,2,opt,name=text,proto3" json:"text,omitempty"`
+	// The author's identity, as in WatchRequest.
+	Client        string `protobuf:"bytes,3,opt,name=client,proto3" json:"client,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnnotateRequest) Reset() {
+	*x = AnnotateRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnnotateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnotateRequest) ProtoMessage() {}
+
+func (x *AnnotateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnotateRequest.ProtoReflect.Descriptor instead.
+func (*AnnotateRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *AnnotateRequest) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *AnnotateRequest) GetText() string {
+	if x != nil {
+		return x.Text
+	}
+	return ""
+}
+
+func (x *AnnotateRequest) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+type AnnotateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Annotation    *Annotation            `protobuf:"bytes,1,opt,name=annotation,proto3" json:"annotation,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AnnotateResponse) Reset() {
+	*x = AnnotateResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AnnotateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnnotateResponse) ProtoMessage() {}
+
+func (x *AnnotateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnnotateResponse.ProtoReflect.Descriptor instead.
+func (*AnnotateResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *AnnotateResponse) GetAnnotation() *Annotation {
+	if x != nil {
+		return x.Annotation
+	}
+	return nil
+}
+
+// Searches the daemon's event store. Unset fields match everything.
+type QueryRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Start time range; until is exclusive.
+	Since *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=since,proto3" json:"since,omitempty"`
+	Until *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=until,proto3" json:"until,omitempty"`
+	// SQL whose fingerprint the events must share, e.g. "SELECT * FROM users WHERE id = 1".
+	Query string `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
+	TxId  string `protobuf:"bytes,4,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	// Case-insensitive substring of the query text.
+	Contains    string               `protobuf:"bytes,5,opt,name=contains,proto3" json:"contains,omitempty"`
+	MinDuration *durationpb.Duration `protobuf:"bytes,6,opt,name=min_duration,json=minDuration,proto3" json:"min_duration,omitempty"`
+	ErrorsOnly  bool                 `protobuf:"varint,7,opt,name=errors_only,json=errorsOnly,proto3" json:"errors_only,omitempty"`
+	// Return only the most recent matches; 0 means the server's default.
+	Limit         int32 `protobuf:"varint,8,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryRequest) Reset() {
+	*x = QueryRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryRequest) ProtoMessage() {}
+
+func (x *QueryRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryRequest.ProtoReflect.Descriptor instead.
+func (*QueryRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *QueryRequest) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Until
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetTxId() string {
+	if x != nil {
+		return x.TxId
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetContains() string {
+	if x != nil {
+		return x.Contains
+	}
+	return ""
+}
+
+func (x *QueryRequest) GetMinDuration() *durationpb.Duration {
+	if x != nil {
+		return x.MinDuration
+	}
+	return nil
+}
+
+func (x *QueryRequest) GetErrorsOnly() bool {
+	if x != nil {
+		return x.ErrorsOnly
+	}
+	return false
+}
+
+func (x *QueryRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type QueryResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Matching events, oldest first.
+	Events        []*QueryEvent `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryResponse) Reset() {
+	*x = QueryResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryResponse) ProtoMessage() {}
+
+func (x *QueryResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryResponse.ProtoReflect.Descriptor instead.
+func (*QueryResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *QueryResponse) GetEvents() []*QueryEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+type ExplainRequest struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Query   string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
+	Args    []string               `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
+	Analyze bool                   `protobuf:"varint,3,opt,name=analyze,proto3" json:"analyze,omitempty"`
+	// Upstream to run EXPLAIN against; empty selects the default explain connection.
+	Upstream      string `protobuf:"bytes,4,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExplainRequest) Reset() {
+	*x = ExplainRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExplainRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainRequest) ProtoMessage() {}
+
+func (x *ExplainRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainRequest.ProtoReflect.Descriptor instead.
+func (*ExplainRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ExplainRequest) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *ExplainRequest) GetArgs() []string {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *ExplainRequest) GetAnalyze() bool {
+	if x != nil {
+		return x.Analyze
+	}
+	return false
+}
+
+func (x *ExplainRequest) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
+	}
+	return ""
+}
+
+type ExplainResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Text plan; for tabular plans, the columns and rows rendered as a table.
+	Plan string `protobuf:"bytes,1,opt,name=plan,proto3" json:"plan,omitempty"`
+	// Set when EXPLAIN returned more than one column (e.g. TiDB, MySQL's
+	// traditional format).
+	Columns []string `protobuf:"bytes,2,rep,name=columns,proto3" json:"columns,omitempty"`
+	Rows    []*Row   `protobuf:"bytes,3,rep,name=rows,proto3" json:"rows,omitempty"`
+	// Advisory tags the plan warrants, e.g. "temp/disk" for a sort spilling
+	// to disk.
+	Advisories []string `protobuf:"bytes,4,rep,name=advisories,proto3" json:"advisories,omitempty"`
+	// Suggested CREATE INDEX statements for expensive sequential scans;
+	// PostgreSQL only.
+	Indexes       []string `protobuf:"bytes,5,rep,name=indexes,proto3" json:"indexes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ExplainResponse) Reset() {
+	*x = ExplainResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ExplainResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ExplainResponse) ProtoMessage() {}
+
+func (x *ExplainResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ExplainResponse.ProtoReflect.Descriptor instead.
+func (*ExplainResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *ExplainResponse) GetPlan() string {
+	if x != nil {
+		return x.Plan
+	}
+	return ""
+}
+
+func (x *ExplainResponse) GetColumns() []string {
+	if x != nil {
+		return x.Columns
+	}
+	return nil
+}
+
+func (x *ExplainResponse) GetRows() []*Row {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *ExplainResponse) GetAdvisories() []string {
+	if x != nil {
+		return x.Advisories
+	}
+	return nil
+}
+
+func (x *ExplainResponse) GetIndexes() []string {
+	if x != nil {
+		return x.Indexes
+	}
+	return nil
+}
+
+type InfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoRequest) Reset() {
+	*x = InfoRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoRequest) ProtoMessage() {}
+
+func (x *InfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoRequest.ProtoReflect.Descriptor instead.
+func (*InfoRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{26}
+}
+
+type TagDef struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	// lipgloss color (ANSI number or hex); empty for the default color.
+	Color         string `protobuf:"bytes,2,opt,name=color,proto3" json:"color,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TagDef) Reset() {
+	*x = TagDef{}
+	mi := &file_tap_v1_tap_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TagDef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TagDef) ProtoMessage() {}
+
+func (x *TagDef) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TagDef.ProtoReflect.Descriptor instead.
+func (*TagDef) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *TagDef) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *TagDef) GetColor() string {
+	if x != nil {
+		return x.Color
+	}
+	return ""
+}
+
+type InfoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Expiry of the certificate used for client-side TLS termination; unset when TLS is disabled.
+	TlsCertNotAfter *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=tls_cert_not_after,json=tlsCertNotAfter,proto3" json:"tls_cert_not_after,omitempty"`
+	// How long before tls_cert_not_after clients should warn that the certificate expires soon.
+	TlsCertExpiryWarning *durationpb.Duration `protobuf:"bytes,4,opt,name=tls_cert_expiry_warning,json=tlsCertExpiryWarning,proto3" json:"tls_cert_expiry_warning,omitempty"`
+	// Tags the daemon's tagging rules can attach to events.
+	Tags []*TagDef `protobuf:"bytes,2,rep,name=tags,proto3" json:"tags,omitempty"`
+	// The daemon's proxies, one per tapped upstream.
+	Proxies       []*ProxyEndpoint `protobuf:"bytes,3,rep,name=proxies,proto3" json:"proxies,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InfoResponse) Reset() {
+	*x = InfoResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[28]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InfoResponse) ProtoMessage() {}
+
+func (x *InfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[28]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InfoResponse.ProtoReflect.Descriptor instead.
+func (*InfoResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{28}
+}
+
+func (x *InfoResponse) GetTlsCertNotAfter() *timestamppb.Timestamp {
+	if x != nil {
+		return x.TlsCertNotAfter
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetTlsCertExpiryWarning() *durationpb.Duration {
+	if x != nil {
+		return x.TlsCertExpiryWarning
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetTags() []*TagDef {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *InfoResponse) GetProxies() []*ProxyEndpoint {
+	if x != nil {
+		return x.Proxies
+	}
+	return nil
+}
+
+// Where applications connect to reach an upstream through sql-tapd.
+type ProxyEndpoint struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Upstream name, as given to -tap; empty with a single upstream.
+	Upstream string `protobuf:"bytes,1,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	// postgres, mysql, or tidb.
+	Driver string `protobuf:"bytes,2,opt,name=driver,proto3" json:"driver,omitempty"`
+	// The proxy's listen address, host:port or a unix socket path.
+	Listen string `protobuf:"bytes,3,opt,name=listen,proto3" json:"listen,omitempty"`
+	// A ready-to-paste DSN for the proxy: the user, database, and options of
+	// the upstream's DSN, without its password, at the listen address. Empty
+	// when the listen address cannot be written as a DSN.
+	Dsn           string `protobuf:"bytes,4,opt,name=dsn,proto3" json:"dsn,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ProxyEndpoint) Reset() {
+	*x = ProxyEndpoint{}
+	mi := &file_tap_v1_tap_proto_msgTypes[29]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ProxyEndpoint) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ProxyEndpoint) ProtoMessage() {}
+
+func (x *ProxyEndpoint) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[29]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ProxyEndpoint.ProtoReflect.Descriptor instead.
+func (*ProxyEndpoint) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{29}
+}
+
+func (x *ProxyEndpoint) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
+	}
+	return ""
+}
+
+func (x *ProxyEndpoint) GetDriver() string {
+	if x != nil {
+		return x.Driver
+	}
+	return ""
+}
+
+func (x *ProxyEndpoint) GetListen() string {
+	if x != nil {
+		return x.Listen
+	}
+	return ""
+}
+
+func (x *ProxyEndpoint) GetDsn() string {
+	if x != nil {
+		return x.Dsn
+	}
+	return ""
+}
+
+type SetVerboseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ConnId        string                 `protobuf:"bytes,1,opt,name=conn_id,json=connId,proto3" json:"conn_id,omitempty"`
+	Verbose       bool                   `protobuf:"varint,2,opt,name=verbose,proto3" json:"verbose,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SetVerboseRequest) Reset() {
+	*x = SetVerboseRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[30]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetVerboseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetVerboseRequest) ProtoMessage() {}
+
+func (x *SetVerboseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[30]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetVerboseRequest.ProtoReflect.Descriptor instead.
+func (*SetVerboseRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{30}
+}
+
+func (x *SetVerboseRequest) GetConnId() string {
+	if x != nil {
+		return x.ConnId
+	}
+	return ""
+}
+
+func (x *SetVerboseRequest) GetVerbose() bool {
+	if x != nil {
+		return x.Verbose
+	}
+	return false
+}
+
+type SetVerboseResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Connections that currently have detailed capture enabled.
+	VerboseConnIds []string `protobuf:"bytes,1,rep,name=verbose_conn_ids,json=verboseConnIds,proto3" json:"verbose_conn_ids,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *SetVerboseResponse) Reset() {
+	*x = SetVerboseResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[31]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SetVerboseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SetVerboseResponse) ProtoMessage() {}
+
+func (x *SetVerboseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[31]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SetVerboseResponse.ProtoReflect.Descriptor instead.
+func (*SetVerboseResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{31}
+}
+
+func (x *SetVerboseResponse) GetVerboseConnIds() []string {
+	if x != nil {
+		return x.VerboseConnIds
+	}
+	return nil
+}
+
+type StageLatency struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Name  string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Count uint64                 `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Total *durationpb.Duration   `protobuf:"bytes,3,opt,name=total,proto3" json:"total,omitempty"`
+	Max   *durationpb.Duration   `protobuf:"bytes,4,opt,name=max,proto3" json:"max,omitempty"`
+	P50   *durationpb.Duration   `protobuf:"bytes,5,opt,name=p50,proto3" json:"p50,omitempty"`
+	P99   *durationpb.Duration   `protobuf:"bytes,6,opt,name=p99,proto3" json:"p99,omitempty"`
+	// Observations per histogram bucket, not cumulative: bucket i counts those
+	// up to bucket_bounds[i], and a final extra bucket those above them all.
+	Buckets       []uint64               `protobuf:"varint,7,rep,packed,name=buckets,proto3" json:"buckets,omitempty"`
+	BucketBounds  []*durationpb.Duration `protobuf:"bytes,8,rep,name=bucket_bounds,json=bucketBounds,proto3" json:"bucket_bounds,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StageLatency) Reset() {
+	*x = StageLatency{}
+	mi := &file_tap_v1_tap_proto_msgTypes[32]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StageLatency) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StageLatency) ProtoMessage() {}
+
+func (x *StageLatency) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[32]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StageLatency.ProtoReflect.Descriptor instead.
+func (*StageLatency) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{32}
+}
+
+func (x *StageLatency) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *StageLatency) GetCount() uint64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *StageLatency) GetTotal() *durationpb.Duration {
+	if x != nil {
+		return x.Total
+	}
+	return nil
+}
+
+func (x *StageLatency) GetMax() *durationpb.Duration {
+	if x != nil {
+		return x.Max
+	}
+	return nil
+}
+
+func (x *StageLatency) GetP50() *durationpb.Duration {
+	if x != nil {
+		return x.P50
+	}
+	return nil
+}
+
+func (x *StageLatency) GetP99() *durationpb.Duration {
+	if x != nil {
+		return x.P99
+	}
+	return nil
+}
+
+func (x *StageLatency) GetBuckets() []uint64 {
+	if x != nil {
+		return x.Buckets
+	}
+	return nil
+}
+
+func (x *StageLatency) GetBucketBounds() []*durationpb.Duration {
+	if x != nil {
+		return x.BucketBounds
+	}
+	return nil
+}
+
+type StatsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsRequest) Reset() {
+	*x = StatsRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[33]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsRequest) ProtoMessage() {}
+
+func (x *StatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[33]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsRequest.ProtoReflect.Descriptor instead.
+func (*StatsRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{33}
+}
+
+type SubscriberStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Id    int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name  string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// "drop", "block", or "spill".
+	Policy   string `protobuf:"bytes,3,opt,name=policy,proto3" json:"policy,omitempty"`
+	Dropped  uint64 `protobuf:"varint,4,opt,name=dropped,proto3" json:"dropped,omitempty"`
+	Buffered int64  `protobuf:"varint,5,opt,name=buffered,proto3" json:"buffered,omitempty"`
+	Capacity int64  `protobuf:"varint,6,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	// The client identity the watcher sent, if any.
+	Client string                 `protobuf:"bytes,7,opt,name=client,proto3" json:"client,omitempty"`
+	Since  *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=since,proto3" json:"since,omitempty"`
+	// The watcher subscribed with a selector.
+	Filtered bool `protobuf:"varint,9,opt,name=filtered,proto3" json:"filtered,omitempty"`
+	// Events waiting on the daemon's disk, with DELIVERY_SPILL.
+	Spilled int64 `protobuf:"varint,10,opt,name=spilled,proto3" json:"spilled,omitempty"`
+	// The subscription is a Watch stream on the connection that called Stats.
+	Own           bool `protobuf:"varint,11,opt,name=own,proto3" json:"own,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SubscriberStats) Reset() {
+	*x = SubscriberStats{}
+	mi := &file_tap_v1_tap_proto_msgTypes[34]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SubscriberStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SubscriberStats) ProtoMessage() {}
+
+func (x *SubscriberStats) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[34]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SubscriberStats.ProtoReflect.Descriptor instead.
+func (*SubscriberStats) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{34}
+}
+
+func (x *SubscriberStats) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SubscriberStats) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *SubscriberStats) GetPolicy() string {
+	if x != nil {
+		return x.Policy
+	}
+	return ""
+}
+
+func (x *SubscriberStats) GetDropped() uint64 {
+	if x != nil {
+		return x.Dropped
+	}
+	return 0
+}
+
+func (x *SubscriberStats) GetBuffered() int64 {
+	if x != nil {
+		return x.Buffered
+	}
+	return 0
+}
+
+func (x *SubscriberStats) GetCapacity() int64 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *SubscriberStats) GetClient() string {
+	if x != nil {
+		return x.Client
+	}
+	return ""
+}
+
+func (x *SubscriberStats) GetSince() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Since
+	}
+	return nil
+}
+
+func (x *SubscriberStats) GetFiltered() bool {
+	if x != nil {
+		return x.Filtered
+	}
+	return false
+}
+
+func (x *SubscriberStats) GetSpilled() int64 {
+	if x != nil {
+		return x.Spilled
+	}
+	return 0
+}
+
+func (x *SubscriberStats) GetOwn() bool {
+	if x != nil {
+		return x.Own
+	}
+	return false
+}
+
+type StatsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Event processing latency per pipeline stage, sorted by name.
+	Stages []*StageLatency `protobuf:"bytes,1,rep,name=stages,proto3" json:"stages,omitempty"`
+	// Events the proxies discarded because the daemon could not keep up.
+	ProxyDropped uint64 `protobuf:"varint,2,opt,name=proxy_dropped,json=proxyDropped,proto3" json:"proxy_dropped,omitempty"`
+	// Active broker subscriptions, including the caller's own Watch stream.
+	Subscribers []*SubscriberStats `protobuf:"bytes,3,rep,name=subscribers,proto3" json:"subscribers,omitempty"`
+	// Events the daemon's sampling rules discarded before publishing.
+	SampledOut uint64 `protobuf:"varint,4,opt,name=sampled_out,json=sampledOut,proto3" json:"sampled_out,omitempty"`
+	// Upstream statements cancelled or abandoned, by cause.
+	Cancellations *Cancellations `protobuf:"bytes,5,opt,name=cancellations,proto3" json:"cancellations,omitempty"`
+	// Panics recovered from connection handling and pipeline stages, each
+	// also reported as an advisory event.
+	Panics uint64 `protobuf:"varint,6,opt,name=panics,proto3" json:"panics,omitempty"`
+	// Connection errors the proxies logged, each also reported as an
+	// advisory event.
+	Diagnostics   uint64 `protobuf:"varint,7,opt,name=diagnostics,proto3" json:"diagnostics,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatsResponse) Reset() {
+	*x = StatsResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[35]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatsResponse) ProtoMessage() {}
+
+func (x *StatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[35]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatsResponse.ProtoReflect.Descriptor instead.
+func (*StatsResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{35}
+}
+
+func (x *StatsResponse) GetStages() []*StageLatency {
+	if x != nil {
+		return x.Stages
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetProxyDropped() uint64 {
+	if x != nil {
+		return x.ProxyDropped
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetSubscribers() []*SubscriberStats {
+	if x != nil {
+		return x.Subscribers
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetSampledOut() uint64 {
+	if x != nil {
+		return x.SampledOut
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetCancellations() *Cancellations {
+	if x != nil {
+		return x.Cancellations
+	}
+	return nil
+}
+
+func (x *StatsResponse) GetPanics() uint64 {
+	if x != nil {
+		return x.Panics
+	}
+	return 0
+}
+
+func (x *StatsResponse) GetDiagnostics() uint64 {
+	if x != nil {
+		return x.Diagnostics
+	}
+	return 0
+}
+
+type Cancellations struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Cancel requests clients sent through the proxy.
+	Relayed uint64 `protobuf:"varint,1,opt,name=relayed,proto3" json:"relayed,omitempty"`
+	// Cancels sent by the Kill RPC.
+	Killed uint64 `protobuf:"varint,2,opt,name=killed,proto3" json:"killed,omitempty"`
+	// Statements the proxy cancelled for running past its query timeout.
+	TimedOut uint64 `protobuf:"varint,3,opt,name=timed_out,json=timedOut,proto3" json:"timed_out,omitempty"`
+	// Statements still running when their client disconnected.
+	Disconnected  uint64 `protobuf:"varint,4,opt,name=disconnected,proto3" json:"disconnected,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Cancellations) Reset() {
+	*x = Cancellations{}
+	mi := &file_tap_v1_tap_proto_msgTypes[36]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Cancellations) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Cancellations) ProtoMessage() {}
+
+func (x *Cancellations) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[36]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Cancellations.ProtoReflect.Descriptor instead.
+func (*Cancellations) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{36}
+}
+
+func (x *Cancellations) GetRelayed() uint64 {
+	if x != nil {
+		return x.Relayed
+	}
+	return 0
+}
+
+func (x *Cancellations) GetKilled() uint64 {
+	if x != nil {
+		return x.Killed
+	}
+	return 0
+}
+
+func (x *Cancellations) GetTimedOut() uint64 {
+	if x != nil {
+		return x.TimedOut
+	}
+	return 0
+}
+
+func (x *Cancellations) GetDisconnected() uint64 {
+	if x != nil {
+		return x.Disconnected
+	}
+	return 0
+}
+
+type Transaction struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	TxId      string                 `protobuf:"bytes,1,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
+	ConnId    string                 `protobuf:"bytes,2,opt,name=conn_id,json=connId,proto3" json:"conn_id,omitempty"`
+	Upstream  string                 `protobuf:"bytes,3,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	Status    TxStatus               `protobuf:"varint,4,opt,name=status,proto3,enum=tap.v1.TxStatus" json:"status,omitempty"`
+	StartTime *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	// Unset while the transaction is open.
+	EndTime  *timestamppb.Timestamp `protobuf:"bytes,6,opt,name=end_time,json=endTime,proto3" json:"end_time,omitempty"`
+	Duration *durationpb.Duration   `protobuf:"bytes,7,opt,name=duration,proto3" json:"duration,omitempty"`
+	Events   []*QueryEvent          `protobuf:"bytes,8,rep,name=events,proto3" json:"events,omitempty"`
+	// The daemon stopped recording events for this transaction after a cap.
+	Truncated bool `protobuf:"varint,9,opt,name=truncated,proto3" json:"truncated,omitempty"`
+	// Shared by the branches of a distributed (two-phase commit) transaction.
+	GlobalTxId    string `protobuf:"bytes,10,opt,name=global_tx_id,json=globalTxId,proto3" json:"global_tx_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Transaction) Reset() {
+	*x = Transaction{}
+	mi := &file_tap_v1_tap_proto_msgTypes[37]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Transaction) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Transaction) ProtoMessage() {}
+
+func (x *Transaction) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[37]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Transaction.ProtoReflect.Descriptor instead.
+func (*Transaction) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{37}
+}
+
+func (x *Transaction) GetTxId() string {
+	if x != nil {
+		return x.TxId
+	}
+	return ""
+}
+
+func (x *Transaction) GetConnId() string {
+	if x != nil {
+		return x.ConnId
+	}
+	return ""
+}
+
+func (x *Transaction) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
+	}
+	return ""
+}
+
+func (x *Transaction) GetStatus() TxStatus {
+	if x != nil {
+		return x.Status
+	}
+	return TxStatus_TX_STATUS_UNSPECIFIED
+}
+
+func (x *Transaction) GetStartTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartTime
+	}
+	return nil
+}
+
+func (x *Transaction) GetEndTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.EndTime
+	}
+	return nil
+}
+
+func (x *Transaction) GetDuration() *durationpb.Duration {
+	if x != nil {
+		return x.Duration
+	}
+	return nil
+}
+
+func (x *Transaction) GetEvents() []*QueryEvent {
+	if x != nil {
+		return x.Events
+	}
+	return nil
+}
+
+func (x *Transaction) GetTruncated() bool {
+	if x != nil {
+		return x.Truncated
+	}
+	return false
+}
+
+func (x *Transaction) GetGlobalTxId() string {
+	if x != nil {
+		return x.GlobalTxId
+	}
+	return ""
+}
+
+type TransactionsRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Maximum number of transactions to return, newest first; 0 for all retained.
+	Limit         int32 `protobuf:"varint,1,opt,name=limit,proto3" json:"limit,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionsRequest) Reset() {
+	*x = TransactionsRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[38]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionsRequest) ProtoMessage() {}
+
+func (x *TransactionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[38]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionsRequest.ProtoReflect.Descriptor instead.
+func (*TransactionsRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{38}
+}
+
+func (x *TransactionsRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+type TransactionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Transactions  []*Transaction         `protobuf:"bytes,1,rep,name=transactions,proto3" json:"transactions,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TransactionsResponse) Reset() {
+	*x = TransactionsResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[39]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TransactionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TransactionsResponse) ProtoMessage() {}
+
+func (x *TransactionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[39]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TransactionsResponse.ProtoReflect.Descriptor instead.
+func (*TransactionsResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{39}
+}
+
+func (x *TransactionsResponse) GetTransactions() []*Transaction {
+	if x != nil {
+		return x.Transactions
+	}
+	return nil
+}
+
+type KillRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Backend to signal, from QueryEvent.backend_pid.
+	BackendPid uint32 `protobuf:"varint,1,opt,name=backend_pid,json=backendPid,proto3" json:"backend_pid,omitempty"`
+	// End the whole session (pg_terminate_backend, KILL) rather than only its
+	// running statement (pg_cancel_backend, KILL QUERY).
+	Terminate bool `protobuf:"varint,2,opt,name=terminate,proto3" json:"terminate,omitempty"`
+	// Upstream the backend belongs to; empty selects the default connection.
+	Upstream      string `protobuf:"bytes,3,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillRequest) Reset() {
+	*x = KillRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[40]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillRequest) ProtoMessage() {}
+
+func (x *KillRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[40]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillRequest.ProtoReflect.Descriptor instead.
+func (*KillRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{40}
+}
+
+func (x *KillRequest) GetBackendPid() uint32 {
+	if x != nil {
+		return x.BackendPid
+	}
+	return 0
+}
+
+func (x *KillRequest) GetTerminate() bool {
+	if x != nil {
+		return x.Terminate
+	}
+	return false
+}
+
+func (x *KillRequest) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
+	}
+	return ""
+}
+
+type KillResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *KillResponse) Reset() {
+	*x = KillResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[41]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *KillResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*KillResponse) ProtoMessage() {}
+
+func (x *KillResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[41]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use KillResponse.ProtoReflect.Descriptor instead.
+func (*KillResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{41}
+}
+
+type RoutesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutesRequest) Reset() {
+	*x = RoutesRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[42]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutesRequest) ProtoMessage() {}
+
+func (x *RoutesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[42]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutesRequest.ProtoReflect.Descriptor instead.
+func (*RoutesRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{42}
+}
+
+type RouteStats struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Route  string                 `protobuf:"bytes,1,opt,name=route,proto3" json:"route,omitempty"`
+	Count  int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Errors int32                  `protobuf:"varint,3,opt,name=errors,proto3" json:"errors,omitempty"`
+	// Queries per second over the window.
+	Qps float64              `protobuf:"fixed64,4,opt,name=qps,proto3" json:"qps,omitempty"`
+	P50 *durationpb.Duration `protobuf:"bytes,5,opt,name=p50,proto3" json:"p50,omitempty"`
+	P95 *durationpb.Duration `protobuf:"bytes,6,opt,name=p95,proto3" json:"p95,omitempty"`
+	P99 *durationpb.Duration `protobuf:"bytes,7,opt,name=p99,proto3" json:"p99,omitempty"`
+	// Requests finished in the window, for queries tagged with a request_id,
+	// and the queries each ran.
+	Requests             int32 `protobuf:"varint,8,opt,name=requests,proto3" json:"requests,omitempty"`
+	QueriesPerRequestP50 int32 `protobuf:"varint,9,opt,name=queries_per_request_p50,json=queriesPerRequestP50,proto3" json:"queries_per_request_p50,omitempty"`
+	QueriesPerRequestP95 int32 `protobuf:"varint,10,opt,name=queries_per_request_p95,json=queriesPerRequestP95,proto3" json:"queries_per_request_p95,omitempty"`
+	QueriesPerRequestMax int32 `protobuf:"varint,11,opt,name=queries_per_request_max,json=queriesPerRequestMax,proto3" json:"queries_per_request_max,omitempty"`
+	// Requests that ran more queries than the query budget.
+	OverBudget int32 `protobuf:"varint,12,opt,name=over_budget,json=overBudget,proto3" json:"over_budget,omitempty"`
+	// The fingerprint the heaviest request ran most, and how often: the likely
+	// N+1 query.
+	TopFingerprint      string `protobuf:"bytes,13,opt,name=top_fingerprint,json=topFingerprint,proto3" json:"top_fingerprint,omitempty"`
+	TopFingerprintCalls int32  `protobuf:"varint,14,opt,name=top_fingerprint_calls,json=topFingerprintCalls,proto3" json:"top_fingerprint_calls,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *RouteStats) Reset() {
+	*x = RouteStats{}
+	mi := &file_tap_v1_tap_proto_msgTypes[43]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RouteStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RouteStats) ProtoMessage() {}
+
+func (x *RouteStats) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[43]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RouteStats.ProtoReflect.Descriptor instead.
+func (*RouteStats) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{43}
+}
+
+func (x *RouteStats) GetRoute() string {
+	if x != nil {
+		return x.Route
+	}
+	return ""
+}
+
+func (x *RouteStats) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *RouteStats) GetErrors() int32 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *RouteStats) GetQps() float64 {
+	if x != nil {
+		return x.Qps
+	}
+	return 0
+}
+
+func (x *RouteStats) GetP50() *durationpb.Duration {
+	if x != nil {
+		return x.P50
+	}
+	return nil
+}
+
+func (x *RouteStats) GetP95() *durationpb.Duration {
+	if x != nil {
+		return x.P95
+	}
+	return nil
+}
+
+func (x *RouteStats) GetP99() *durationpb.Duration {
+	if x != nil {
+		return x.P99
+	}
+	return nil
+}
+
+func (x *RouteStats) GetRequests() int32 {
+	if x != nil {
+		return x.Requests
+	}
+	return 0
+}
+
+func (x *RouteStats) GetQueriesPerRequestP50() int32 {
+	if x != nil {
+		return x.QueriesPerRequestP50
+	}
+	return 0
+}
+
+func (x *RouteStats) GetQueriesPerRequestP95() int32 {
+	if x != nil {
+		return x.QueriesPerRequestP95
+	}
+	return 0
+}
+
+func (x *RouteStats) GetQueriesPerRequestMax() int32 {
+	if x != nil {
+		return x.QueriesPerRequestMax
+	}
+	return 0
+}
+
+func (x *RouteStats) GetOverBudget() int32 {
+	if x != nil {
+		return x.OverBudget
+	}
+	return 0
+}
+
+func (x *RouteStats) GetTopFingerprint() string {
+	if x != nil {
+		return x.TopFingerprint
+	}
+	return ""
+}
+
+func (x *RouteStats) GetTopFingerprintCalls() int32 {
+	if x != nil {
+		return x.TopFingerprintCalls
+	}
+	return 0
+}
+
+type RoutesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Routes with queries in the window, busiest first.
+	Routes []*RouteStats `protobuf:"bytes,1,rep,name=routes,proto3" json:"routes,omitempty"`
+	// The span the statistics cover, ending now.
+	Window *durationpb.Duration `protobuf:"bytes,2,opt,name=window,proto3" json:"window,omitempty"`
+	// Queries per request above which a request is over budget.
+	QueryBudget   int32 `protobuf:"varint,3,opt,name=query_budget,json=queryBudget,proto3" json:"query_budget,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RoutesResponse) Reset() {
+	*x = RoutesResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[44]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RoutesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RoutesResponse) ProtoMessage() {}
+
+func (x *RoutesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[44]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RoutesResponse.ProtoReflect.Descriptor instead.
+func (*RoutesResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{44}
+}
+
+func (x *RoutesResponse) GetRoutes() []*RouteStats {
+	if x != nil {
+		return x.Routes
+	}
+	return nil
+}
+
+func (x *RoutesResponse) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *RoutesResponse) GetQueryBudget() int32 {
+	if x != nil {
+		return x.QueryBudget
+	}
+	return 0
+}
+
+type TenantsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TenantsRequest) Reset() {
+	*x = TenantsRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[45]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TenantsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TenantsRequest) ProtoMessage() {}
+
+func (x *TenantsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[45]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TenantsRequest.ProtoReflect.Descriptor instead.
+func (*TenantsRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{45}
+}
+
+type TenantStats struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Value  string                 `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Count  int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Errors int32                  `protobuf:"varint,3,opt,name=errors,proto3" json:"errors,omitempty"`
+	// Queries per second over the window.
+	Qps float64              `protobuf:"fixed64,4,opt,name=qps,proto3" json:"qps,omitempty"`
+	P50 *durationpb.Duration `protobuf:"bytes,5,opt,name=p50,proto3" json:"p50,omitempty"`
+	P95 *durationpb.Duration `protobuf:"bytes,6,opt,name=p95,proto3" json:"p95,omitempty"`
+	P99 *durationpb.Duration `protobuf:"bytes,7,opt,name=p99,proto3" json:"p99,omitempty"`
+	// The tenant's fraction of all queries in the window, with or without a
+	// tenant.
+	Share         float64 `protobuf:"fixed64,8,opt,name=share,proto3" json:"share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TenantStats) Reset() {
+	*x = TenantStats{}
+	mi := &file_tap_v1_tap_proto_msgTypes[46]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TenantStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TenantStats) ProtoMessage() {}
+
+func (x *TenantStats) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[46]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TenantStats.ProtoReflect.Descriptor instead.
+func (*TenantStats) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{46}
+}
+
+func (x *TenantStats) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+func (x *TenantStats) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *TenantStats) GetErrors() int32 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *TenantStats) GetQps() float64 {
+	if x != nil {
+		return x.Qps
+	}
+	return 0
+}
+
+func (x *TenantStats) GetP50() *durationpb.Duration {
+	if x != nil {
+		return x.P50
+	}
+	return nil
+}
+
+func (x *TenantStats) GetP95() *durationpb.Duration {
+	if x != nil {
+		return x.P95
+	}
+	return nil
+}
+
+func (x *TenantStats) GetP99() *durationpb.Duration {
+	if x != nil {
+		return x.P99
+	}
+	return nil
+}
+
+func (x *TenantStats) GetShare() float64 {
+	if x != nil {
+		return x.Share
+	}
+	return 0
+}
+
+type TenantsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The extracted field naming tenants.
+	Field string `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	// Tenants with queries in the window, busiest first.
+	Tenants []*TenantStats `protobuf:"bytes,2,rep,name=tenants,proto3" json:"tenants,omitempty"`
+	// The span the statistics cover, ending now.
+	Window *durationpb.Duration `protobuf:"bytes,3,opt,name=window,proto3" json:"window,omitempty"`
+	// Share of queries above which a tenant is reported.
+	MaxShare      float64 `protobuf:"fixed64,4,opt,name=max_share,json=maxShare,proto3" json:"max_share,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TenantsResponse) Reset() {
+	*x = TenantsResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[47]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TenantsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TenantsResponse) ProtoMessage() {}
+
+func (x *TenantsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[47]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TenantsResponse.ProtoReflect.Descriptor instead.
+func (*TenantsResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{47}
+}
+
+func (x *TenantsResponse) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *TenantsResponse) GetTenants() []*TenantStats {
+	if x != nil {
+		return x.Tenants
+	}
+	return nil
+}
+
+func (x *TenantsResponse) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
+}
+
+func (x *TenantsResponse) GetMaxShare() float64 {
+	if x != nil {
+		return x.MaxShare
+	}
+	return 0
+}
+
+type StatementsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
-	Op            int32                  `protobuf:"varint,2,opt,name=op,proto3" json:"op,omitempty"`
-	Query         string                 `protobuf:"bytes,3,opt,name=query,proto3" json:"query,omitempty"`
-	Args          []string               `protobuf:"bytes,4,rep,name=args,proto3" json:"args,omitempty"`
-	StartTime     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
-	Duration      *durationpb.Duration   `protobuf:"bytes,6,opt,name=duration,proto3" json:"duration,omitempty"`
-	RowsAffected  int64                  `protobuf:"varint,7,opt,name=rows_affected,json=rowsAffected,proto3" json:"rows_affected,omitempty"`
-	Error         string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
-	TxId          string                 `protobuf:"bytes,9,opt,name=tx_id,json=txId,proto3" json:"tx_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *QueryEvent) Reset() {
-	*x = QueryEvent{}
-	mi := &file_tap_v1_tap_proto_msgTypes[0]
+func (x *StatementsRequest) Reset() {
+	*x = StatementsRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[48]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *QueryEvent) String() string {
+func (x *StatementsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*QueryEvent) ProtoMessage() {}
+func (*StatementsRequest) ProtoMessage() {}
 
-func (x *QueryEvent) ProtoReflect() protoreflect.Message {
-	mi := &file_tap_v1_tap_proto_msgTypes[0]
+func (x *StatementsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[48]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -63,95 +4025,185 @@ func (x *QueryEvent) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use QueryEvent.ProtoReflect.Descriptor instead.
-func (*QueryEvent) Descriptor() ([]byte, []int) {
-	return file_tap_v1_tap_proto_rawDescGZIP(), []int{0}
+// Deprecated: Use StatementsRequest.ProtoReflect.Descriptor instead.
+func (*StatementsRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{48}
 }
 
-func (x *QueryEvent) GetId() string {
+// Server-side totals of one fingerprint from pg_stat_statements, covering
+// everything since the statistics were last reset.
+type ServerStatement struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The named upstream, or empty for the default one.
+	Upstream    string `protobuf:"bytes,1,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	Fingerprint string `protobuf:"bytes,2,opt,name=fingerprint,proto3" json:"fingerprint,omitempty"`
+	Calls       int64  `protobuf:"varint,3,opt,name=calls,proto3" json:"calls,omitempty"`
+	// Execution time across all calls.
+	Total         *durationpb.Duration `protobuf:"bytes,4,opt,name=total,proto3" json:"total,omitempty"`
+	Rows          int64                `protobuf:"varint,5,opt,name=rows,proto3" json:"rows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerStatement) Reset() {
+	*x = ServerStatement{}
+	mi := &file_tap_v1_tap_proto_msgTypes[49]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerStatement) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerStatement) ProtoMessage() {}
+
+func (x *ServerStatement) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[49]
 	if x != nil {
-		return x.Id
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return ""
+	return mi.MessageOf(x)
 }
 
-func (x *QueryEvent) GetOp() int32 {
+// Deprecated: Use ServerStatement.ProtoReflect.Descriptor instead.
+func (*ServerStatement) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{49}
+}
+
+func (x *ServerStatement) GetUpstream() string {
 	if x != nil {
-		return x.Op
+		return x.Upstream
 	}
-	return 0
+	return ""
 }
 
-func (x *QueryEvent) GetQuery() string {
+func (x *ServerStatement) GetFingerprint() string {
 	if x != nil {
-		return x.Query
+		return x.Fingerprint
 	}
 	return ""
 }
 
-func (x *QueryEvent) GetArgs() []string {
+func (x *ServerStatement) GetCalls() int64 {
 	if x != nil {
-		return x.Args
+		return x.Calls
 	}
-	return nil
+	return 0
 }
 
-func (x *QueryEvent) GetStartTime() *timestamppb.Timestamp {
+func (x *ServerStatement) GetTotal() *durationpb.Duration {
 	if x != nil {
-		return x.StartTime
+		return x.Total
 	}
 	return nil
 }
 
-func (x *QueryEvent) GetDuration() *durationpb.Duration {
+func (x *ServerStatement) GetRows() int64 {
 	if x != nil {
-		return x.Duration
+		return x.Rows
+	}
+	return 0
+}
+
+type StatementsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Highest total time first.
+	Statements []*ServerStatement `protobuf:"bytes,1,rep,name=statements,proto3" json:"statements,omitempty"`
+	// When the latest poll finished; unset before the first.
+	PolledAt *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=polled_at,json=polledAt,proto3" json:"polled_at,omitempty"`
+	// How often pg_stat_statements is read.
+	Interval *durationpb.Duration `protobuf:"bytes,3,opt,name=interval,proto3" json:"interval,omitempty"`
+	// The latest poll's error per failing upstream, whose statements are from
+	// the last successful one.
+	Errors        map[string]string `protobuf:"bytes,4,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StatementsResponse) Reset() {
+	*x = StatementsResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[50]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StatementsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatementsResponse) ProtoMessage() {}
+
+func (x *StatementsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[50]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatementsResponse.ProtoReflect.Descriptor instead.
+func (*StatementsResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{50}
+}
+
+func (x *StatementsResponse) GetStatements() []*ServerStatement {
+	if x != nil {
+		return x.Statements
 	}
 	return nil
 }
 
-func (x *QueryEvent) GetRowsAffected() int64 {
+func (x *StatementsResponse) GetPolledAt() *timestamppb.Timestamp {
 	if x != nil {
-		return x.RowsAffected
+		return x.PolledAt
 	}
-	return 0
+	return nil
 }
 
-func (x *QueryEvent) GetError() string {
+func (x *StatementsResponse) GetInterval() *durationpb.Duration {
 	if x != nil {
-		return x.Error
+		return x.Interval
 	}
-	return ""
+	return nil
 }
 
-func (x *QueryEvent) GetTxId() string {
+func (x *StatementsResponse) GetErrors() map[string]string {
 	if x != nil {
-		return x.TxId
+		return x.Errors
 	}
-	return ""
+	return nil
 }
 
-type WatchRequest struct {
+type ConfigRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *WatchRequest) Reset() {
-	*x = WatchRequest{}
-	mi := &file_tap_v1_tap_proto_msgTypes[1]
+func (x *ConfigRequest) Reset() {
+	*x = ConfigRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[51]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *WatchRequest) String() string {
+func (x *ConfigRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*WatchRequest) ProtoMessage() {}
+func (*ConfigRequest) ProtoMessage() {}
 
-func (x *WatchRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tap_v1_tap_proto_msgTypes[1]
+func (x *ConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[51]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -162,33 +4214,37 @@ func (x *WatchRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
-func (*WatchRequest) Descriptor() ([]byte, []int) {
-	return file_tap_v1_tap_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use ConfigRequest.ProtoReflect.Descriptor instead.
+func (*ConfigRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{51}
 }
 
-type WatchResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Event         *QueryEvent            `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+type ConfigResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The daemon's effective settings as YAML: every flag, the proxied
+	// targets, the environment variables they and the config file name, and
+	// the config file with defaults filled in. Passwords, tokens, and keys
+	// are masked.
+	Yaml          string `protobuf:"bytes,1,opt,name=yaml,proto3" json:"yaml,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *WatchResponse) Reset() {
-	*x = WatchResponse{}
-	mi := &file_tap_v1_tap_proto_msgTypes[2]
+func (x *ConfigResponse) Reset() {
+	*x = ConfigResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[52]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *WatchResponse) String() string {
+func (x *ConfigResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*WatchResponse) ProtoMessage() {}
+func (*ConfigResponse) ProtoMessage() {}
 
-func (x *WatchResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tap_v1_tap_proto_msgTypes[2]
+func (x *ConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[52]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -199,42 +4255,39 @@ func (x *WatchResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use WatchResponse.ProtoReflect.Descriptor instead.
-func (*WatchResponse) Descriptor() ([]byte, []int) {
-	return file_tap_v1_tap_proto_rawDescGZIP(), []int{2}
+// Deprecated: Use ConfigResponse.ProtoReflect.Descriptor instead.
+func (*ConfigResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{52}
 }
 
-func (x *WatchResponse) GetEvent() *QueryEvent {
+func (x *ConfigResponse) GetYaml() string {
 	if x != nil {
-		return x.Event
+		return x.Yaml
 	}
-	return nil
+	return ""
 }
 
-type ExplainRequest struct {
+type DatabasesRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Query         string                 `protobuf:"bytes,1,opt,name=query,proto3" json:"query,omitempty"`
-	Args          []string               `protobuf:"bytes,2,rep,name=args,proto3" json:"args,omitempty"`
-	Analyze       bool                   `protobuf:"varint,3,opt,name=analyze,proto3" json:"analyze,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExplainRequest) Reset() {
-	*x = ExplainRequest{}
-	mi := &file_tap_v1_tap_proto_msgTypes[3]
+func (x *DatabasesRequest) Reset() {
+	*x = DatabasesRequest{}
+	mi := &file_tap_v1_tap_proto_msgTypes[53]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExplainRequest) String() string {
+func (x *DatabasesRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExplainRequest) ProtoMessage() {}
+func (*DatabasesRequest) ProtoMessage() {}
 
-func (x *ExplainRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_tap_v1_tap_proto_msgTypes[3]
+func (x *DatabasesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[53]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -245,54 +4298,157 @@ func (x *ExplainRequest) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExplainRequest.ProtoReflect.Descriptor instead.
-func (*ExplainRequest) Descriptor() ([]byte, []int) {
-	return file_tap_v1_tap_proto_rawDescGZIP(), []int{3}
+// Deprecated: Use DatabasesRequest.ProtoReflect.Descriptor instead.
+func (*DatabasesRequest) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{53}
 }
 
-func (x *ExplainRequest) GetQuery() string {
+type DatabaseStats struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Upstream name, as given to -tap; "" is the default upstream.
+	Upstream string `protobuf:"bytes,1,opt,name=upstream,proto3" json:"upstream,omitempty"`
+	// Database selected when the clients connected.
+	Database string `protobuf:"bytes,2,opt,name=database,proto3" json:"database,omitempty"`
+	Count    int32  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	Errors   int32  `protobuf:"varint,4,opt,name=errors,proto3" json:"errors,omitempty"`
+	// Queries per second over the window.
+	Qps float64              `protobuf:"fixed64,5,opt,name=qps,proto3" json:"qps,omitempty"`
+	P50 *durationpb.Duration `protobuf:"bytes,6,opt,name=p50,proto3" json:"p50,omitempty"`
+	P95 *durationpb.Duration `protobuf:"bytes,7,opt,name=p95,proto3" json:"p95,omitempty"`
+	P99 *durationpb.Duration `protobuf:"bytes,8,opt,name=p99,proto3" json:"p99,omitempty"`
+	// The database's fraction of all queries in the window.
+	Share float64 `protobuf:"fixed64,9,opt,name=share,proto3" json:"share,omitempty"`
+	// Database users with queries in the window, sorted.
+	Users         []string `protobuf:"bytes,10,rep,name=users,proto3" json:"users,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DatabaseStats) Reset() {
+	*x = DatabaseStats{}
+	mi := &file_tap_v1_tap_proto_msgTypes[54]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DatabaseStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DatabaseStats) ProtoMessage() {}
+
+func (x *DatabaseStats) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[54]
 	if x != nil {
-		return x.Query
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DatabaseStats.ProtoReflect.Descriptor instead.
+func (*DatabaseStats) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{54}
+}
+
+func (x *DatabaseStats) GetUpstream() string {
+	if x != nil {
+		return x.Upstream
 	}
 	return ""
 }
 
-func (x *ExplainRequest) GetArgs() []string {
+func (x *DatabaseStats) GetDatabase() string {
 	if x != nil {
-		return x.Args
+		return x.Database
+	}
+	return ""
+}
+
+func (x *DatabaseStats) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *DatabaseStats) GetErrors() int32 {
+	if x != nil {
+		return x.Errors
+	}
+	return 0
+}
+
+func (x *DatabaseStats) GetQps() float64 {
+	if x != nil {
+		return x.Qps
+	}
+	return 0
+}
+
+func (x *DatabaseStats) GetP50() *durationpb.Duration {
+	if x != nil {
+		return x.P50
 	}
 	return nil
 }
 
-func (x *ExplainRequest) GetAnalyze() bool {
+func (x *DatabaseStats) GetP95() *durationpb.Duration {
 	if x != nil {
-		return x.Analyze
+		return x.P95
 	}
-	return false
+	return nil
 }
 
-type ExplainResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	Plan          string                 `protobuf:"bytes,1,opt,name=plan,proto3" json:"plan,omitempty"`
+func (x *DatabaseStats) GetP99() *durationpb.Duration {
+	if x != nil {
+		return x.P99
+	}
+	return nil
+}
+
+func (x *DatabaseStats) GetShare() float64 {
+	if x != nil {
+		return x.Share
+	}
+	return 0
+}
+
+func (x *DatabaseStats) GetUsers() []string {
+	if x != nil {
+		return x.Users
+	}
+	return nil
+}
+
+type DatabasesResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Databases with queries in the window, busiest first.
+	Databases []*DatabaseStats `protobuf:"bytes,1,rep,name=databases,proto3" json:"databases,omitempty"`
+	// The span the statistics cover, ending now.
+	Window        *durationpb.Duration `protobuf:"bytes,2,opt,name=window,proto3" json:"window,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *ExplainResponse) Reset() {
-	*x = ExplainResponse{}
-	mi := &file_tap_v1_tap_proto_msgTypes[4]
+func (x *DatabasesResponse) Reset() {
+	*x = DatabasesResponse{}
+	mi := &file_tap_v1_tap_proto_msgTypes[55]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *ExplainResponse) String() string {
+func (x *DatabasesResponse) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*ExplainResponse) ProtoMessage() {}
+func (*DatabasesResponse) ProtoMessage() {}
 
-func (x *ExplainResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tap_v1_tap_proto_msgTypes[4]
+func (x *DatabasesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_tap_v1_tap_proto_msgTypes[55]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -303,23 +4459,84 @@ func (x *ExplainResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use ExplainResponse.ProtoReflect.Descriptor instead.
-func (*ExplainResponse) Descriptor() ([]byte, []int) {
-	return file_tap_v1_tap_proto_rawDescGZIP(), []int{4}
+// Deprecated: Use DatabasesResponse.ProtoReflect.Descriptor instead.
+func (*DatabasesResponse) Descriptor() ([]byte, []int) {
+	return file_tap_v1_tap_proto_rawDescGZIP(), []int{55}
 }
 
-func (x *ExplainResponse) GetPlan() string {
+func (x *DatabasesResponse) GetDatabases() []*DatabaseStats {
 	if x != nil {
-		return x.Plan
+		return x.Databases
 	}
-	return ""
+	return nil
+}
+
+func (x *DatabasesResponse) GetWindow() *durationpb.Duration {
+	if x != nil {
+		return x.Window
+	}
+	return nil
 }
 
 var File_tap_v1_tap_proto protoreflect.FileDescriptor
 
 const file_tap_v1_tap_proto_rawDesc = "" +
 	"\n" +
-	"\x10tap/v1/tap.proto\x12\x06tap.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1egoogle/protobuf/duration.proto\"\x98\x02\n" +
+	"\x10tap/v1/tap.proto\x12\x06tap.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1egoogle/protobuf/duration.proto\"R\n" +
+	"\x05Phase\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x125\n" +
+	"\bduration\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\bduration\"\x1d\n" +
+	"\x03Row\x12\x16\n" +
+	"\x06values\x18\x01 \x03(\tR\x06values\"\x9f\x01\n" +
+	"\vErrorDetail\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1a\n" +
+	"\bseverity\x18\x02 \x01(\tR\bseverity\x12\x18\n" +
+	"\amessage\x18\x03 \x01(\tR\amessage\x12\x16\n" +
+	"\x06detail\x18\x04 \x01(\tR\x06detail\x12\x12\n" +
+	"\x04hint\x18\x05 \x01(\tR\x04hint\x12\x1a\n" +
+	"\bposition\x18\x06 \x01(\x05R\bposition\"V\n" +
+	"\aAnomaly\x12\x14\n" +
+	"\x05score\x18\x01 \x01(\x01R\x05score\x125\n" +
+	"\bbaseline\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\bbaseline\"d\n" +
+	"\bNPlusOne\x12\x14\n" +
+	"\x05calls\x18\x01 \x01(\x03R\x05calls\x12-\n" +
+	"\x04span\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x04span\x12\x13\n" +
+	"\x05in_tx\x18\x03 \x01(\bR\x04inTx\"\x9d\x01\n" +
+	"\rTrafficChange\x12'\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x13.tap.v1.TrafficKindR\x04kind\x12\x14\n" +
+	"\x05calls\x18\x02 \x01(\x03R\x05calls\x12\x1a\n" +
+	"\bbaseline\x18\x03 \x01(\x01R\bbaseline\x121\n" +
+	"\x06window\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x06window\"I\n" +
+	"\x05Panic\x12\x14\n" +
+	"\x05where\x18\x01 \x01(\tR\x05where\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x14\n" +
+	"\x05stack\x18\x03 \x01(\tR\x05stack\"<\n" +
+	"\n" +
+	"Diagnostic\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\tR\x05level\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"i\n" +
+	"\bAutoPlan\x12\x15\n" +
+	"\x06for_id\x18\x01 \x01(\tR\x05forId\x12\x18\n" +
+	"\aanalyze\x18\x02 \x01(\bR\aanalyze\x12\x12\n" +
+	"\x04plan\x18\x03 \x01(\tR\x04plan\x12\x18\n" +
+	"\aindexes\x18\x04 \x03(\tR\aindexes\"\xb5\x01\n" +
+	"\vTenantQuota\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\x12\x14\n" +
+	"\x05calls\x18\x03 \x01(\x03R\x05calls\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x03R\x05total\x12\x1b\n" +
+	"\tmax_share\x18\x05 \x01(\x01R\bmaxShare\x121\n" +
+	"\x06window\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x06window\"0\n" +
+	"\x06Column\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x02 \x01(\tR\x04type\"S\n" +
+	"\tViolation\x12\x12\n" +
+	"\x04rule\x18\x01 \x01(\tR\x04rule\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x18\n" +
+	"\ablocked\x18\x03 \x01(\bR\ablocked\";\n" +
+	"\aRouting\x12\x18\n" +
+	"\areplica\x18\x01 \x01(\bR\areplica\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\xcb\x13\n" +
 	"\n" +
 	"QueryEvent\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x0e\n" +
@@ -331,20 +4548,340 @@ const file_tap_v1_tap_proto_rawDesc = "" +
 	"\bduration\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\bduration\x12#\n" +
 	"\rrows_affected\x18\a \x01(\x03R\frowsAffected\x12\x14\n" +
 	"\x05error\x18\b \x01(\tR\x05error\x12\x13\n" +
-	"\x05tx_id\x18\t \x01(\tR\x04txId\"\x0e\n" +
-	"\fWatchRequest\"9\n" +
+	"\x05tx_id\x18\t \x01(\tR\x04txId\x12\x1f\n" +
+	"\vtls_version\x18\n" +
+	" \x01(\tR\n" +
+	"tlsVersion\x12\x1d\n" +
+	"\n" +
+	"tls_cipher\x18\v \x01(\tR\ttlsCipher\x12\x17\n" +
+	"\aconn_id\x18\f \x01(\tR\x06connId\x12%\n" +
+	"\x06phases\x18\r \x03(\v2\r.tap.v1.PhaseR\x06phases\x12,\n" +
+	"\vrow_samples\x18\x0e \x03(\v2\v.tap.v1.RowR\n" +
+	"rowSamples\x12\x1a\n" +
+	"\bupstream\x18\x0f \x01(\tR\bupstream\x12\x12\n" +
+	"\x04tags\x18\x10 \x03(\tR\x04tags\x12 \n" +
+	"\fglobal_tx_id\x18\x11 \x01(\tR\n" +
+	"globalTxId\x12\x16\n" +
+	"\x06cursor\x18\x12 \x01(\tR\x06cursor\x12\x18\n" +
+	"\afetches\x18\x13 \x01(\x05R\afetches\x12\x19\n" +
+	"\btrace_id\x18\x14 \x01(\tR\atraceId\x12\x17\n" +
+	"\aspan_id\x18\x15 \x01(\tR\x06spanId\x126\n" +
+	"\ferror_detail\x18\x16 \x01(\v2\x13.tap.v1.ErrorDetailR\verrorDetail\x12)\n" +
+	"\aanomaly\x18\x17 \x01(\v2\x0f.tap.v1.AnomalyR\aanomaly\x12 \n" +
+	"\vfingerprint\x18\x18 \x01(\tR\vfingerprint\x12\x1f\n" +
+	"\vclient_addr\x18\x19 \x01(\tR\n" +
+	"clientAddr\x12\x12\n" +
+	"\x04user\x18\x1a \x01(\tR\x04user\x12\x1a\n" +
+	"\bdatabase\x18\x1b \x01(\tR\bdatabase\x12/\n" +
+	"\atraffic\x18\x1c \x01(\v2\x15.tap.v1.TrafficChangeR\atraffic\x12\x14\n" +
+	"\x05route\x18\x1d \x01(\tR\x05route\x12\x1f\n" +
+	"\vbackend_pid\x18\x1e \x01(\rR\n" +
+	"backendPid\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x1f \x01(\tR\trequestId\x12.\n" +
+	"\n" +
+	"n_plus_one\x18  \x01(\v2\x10.tap.v1.NPlusOneR\bnPlusOne\x12\x1f\n" +
+	"\vauth_method\x18! \x01(\tR\n" +
+	"authMethod\x12>\n" +
+	"\rauth_duration\x18\" \x01(\v2\x19.google.protobuf.DurationR\fauthDuration\x12I\n" +
+	"\rserver_params\x18# \x03(\v2$.tap.v1.QueryEvent.ServerParamsEntryR\fserverParams\x12)\n" +
+	"\arouting\x18$ \x01(\v2\x0f.tap.v1.RoutingR\arouting\x12#\n" +
+	"\rrequest_bytes\x18% \x01(\x03R\frequestBytes\x12%\n" +
+	"\x0eresponse_bytes\x18& \x01(\x03R\rresponseBytes\x12\x19\n" +
+	"\bbatch_id\x18' \x01(\tR\abatchId\x12\x1d\n" +
+	"\n" +
+	"batch_size\x18( \x01(\x05R\tbatchSize\x12+\n" +
+	"\x06notice\x18) \x01(\v2\x13.tap.v1.ErrorDetailR\x06notice\x12\x1d\n" +
+	"\n" +
+	"notice_for\x18* \x01(\tR\tnoticeFor\x12\x18\n" +
+	"\aqueries\x18+ \x01(\x03R\aqueries\x12L\n" +
+	"\x0estartup_params\x18, \x03(\v2%.tap.v1.QueryEvent.StartupParamsEntryR\rstartupParams\x12#\n" +
+	"\rssl_requested\x18- \x01(\bR\fsslRequested\x126\n" +
+	"\x06fields\x18. \x03(\v2\x1e.tap.v1.QueryEvent.FieldsEntryR\x06fields\x12)\n" +
+	"\x05quota\x18/ \x01(\v2\x13.tap.v1.TenantQuotaR\x05quota\x12$\n" +
+	"\x04plan\x180 \x01(\v2\x10.tap.v1.AutoPlanR\x04plan\x12#\n" +
+	"\x05panic\x181 \x01(\v2\r.tap.v1.PanicR\x05panic\x12>\n" +
+	"\rqueue_latency\x182 \x01(\v2\x19.google.protobuf.DurationR\fqueueLatency\x12<\n" +
+	"\fexec_latency\x183 \x01(\v2\x19.google.protobuf.DurationR\vexecLatency\x12>\n" +
+	"\rfetch_latency\x184 \x01(\v2\x19.google.protobuf.DurationR\ffetchLatency\x12\x16\n" +
+	"\x06caller\x185 \x03(\tR\x06caller\x12B\n" +
+	"\n" +
+	"extensions\x186 \x03(\v2\".tap.v1.QueryEvent.ExtensionsEntryR\n" +
+	"extensions\x12\x18\n" +
+	"\asession\x187 \x01(\x04R\asession\x122\n" +
+	"\n" +
+	"diagnostic\x188 \x01(\v2\x12.tap.v1.DiagnosticR\n" +
+	"diagnostic\x12/\n" +
+	"\tviolation\x189 \x01(\v2\x11.tap.v1.ViolationR\tviolation\x12(\n" +
+	"\acolumns\x18: \x03(\v2\x0e.tap.v1.ColumnR\acolumns\x12\x1a\n" +
+	"\blistener\x18; \x01(\tR\blistener\x1a?\n" +
+	"\x11ServerParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a@\n" +
+	"\x12StartupParamsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a9\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\x1a=\n" +
+	"\x0fExtensionsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x91\x02\n" +
+	"\fWatchRequest\x12,\n" +
+	"\bdelivery\x18\x01 \x01(\x0e2\x10.tap.v1.DeliveryR\bdelivery\x12\x16\n" +
+	"\x06client\x18\x02 \x01(\tR\x06client\x12 \n" +
+	"\vcollaborate\x18\x03 \x01(\bR\vcollaborate\x12,\n" +
+	"\bsampling\x18\x04 \x01(\v2\x10.tap.v1.SamplingR\bsampling\x12=\n" +
+	"\fresume_after\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\vresumeAfter\x12,\n" +
+	"\bselector\x18\x06 \x01(\v2\x10.tap.v1.SelectorR\bselector\"\x8e\x02\n" +
+	"\bSelector\x12\x1c\n" +
+	"\tupstreams\x18\x01 \x03(\tR\tupstreams\x12\x10\n" +
+	"\x03ops\x18\x02 \x03(\tR\x03ops\x12-\n" +
+	"\x12fingerprint_prefix\x18\x03 \x01(\tR\x11fingerprintPrefix\x124\n" +
+	"\x06fields\x18\x04 \x03(\v2\x1c.tap.v1.Selector.FieldsEntryR\x06fields\x12\x1c\n" +
+	"\tdatabases\x18\x05 \x03(\tR\tdatabases\x12\x14\n" +
+	"\x05users\x18\x06 \x03(\tR\x05users\x1a9\n" +
+	"\vFieldsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"m\n" +
+	"\bSampling\x12\x12\n" +
+	"\x04rate\x18\x01 \x01(\x01R\x04rate\x12'\n" +
+	"\x0fper_fingerprint\x18\x02 \x01(\x05R\x0eperFingerprint\x12$\n" +
+	"\x0emax_per_second\x18\x03 \x01(\x05R\fmaxPerSecond\"\x9b\x01\n" +
 	"\rWatchResponse\x12(\n" +
-	"\x05event\x18\x01 \x01(\v2\x12.tap.v1.QueryEventR\x05event\"T\n" +
+	"\x05event\x18\x01 \x01(\v2\x12.tap.v1.QueryEventR\x05event\x122\n" +
+	"\n" +
+	"annotation\x18\x02 \x01(\v2\x12.tap.v1.AnnotationR\n" +
+	"annotation\x12,\n" +
+	"\bpresence\x18\x03 \x01(\v2\x10.tap.v1.PresenceR\bpresence\"\x83\x01\n" +
+	"\n" +
+	"Annotation\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x16\n" +
+	"\x06author\x18\x03 \x01(\tR\x06author\x12.\n" +
+	"\x04time\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\x04time\"$\n" +
+	"\bPresence\x12\x18\n" +
+	"\aclients\x18\x01 \x03(\tR\aclients\"X\n" +
+	"\x0fAnnotateRequest\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x12\n" +
+	"\x04text\x18\x02 \x01(\tR\x04text\x12\x16\n" +
+	"\x06client\x18\x03 \x01(\tR\x06client\"F\n" +
+	"\x10AnnotateResponse\x122\n" +
+	"\n" +
+	"annotation\x18\x01 \x01(\v2\x12.tap.v1.AnnotationR\n" +
+	"annotation\"\xae\x02\n" +
+	"\fQueryRequest\x120\n" +
+	"\x05since\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x120\n" +
+	"\x05until\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\x05until\x12\x14\n" +
+	"\x05query\x18\x03 \x01(\tR\x05query\x12\x13\n" +
+	"\x05tx_id\x18\x04 \x01(\tR\x04txId\x12\x1a\n" +
+	"\bcontains\x18\x05 \x01(\tR\bcontains\x12<\n" +
+	"\fmin_duration\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\vminDuration\x12\x1f\n" +
+	"\verrors_only\x18\a \x01(\bR\n" +
+	"errorsOnly\x12\x14\n" +
+	"\x05limit\x18\b \x01(\x05R\x05limit\";\n" +
+	"\rQueryResponse\x12*\n" +
+	"\x06events\x18\x01 \x03(\v2\x12.tap.v1.QueryEventR\x06events\"p\n" +
 	"\x0eExplainRequest\x12\x14\n" +
 	"\x05query\x18\x01 \x01(\tR\x05query\x12\x12\n" +
 	"\x04args\x18\x02 \x03(\tR\x04args\x12\x18\n" +
-	"\aanalyze\x18\x03 \x01(\bR\aanalyze\"%\n" +
+	"\aanalyze\x18\x03 \x01(\bR\aanalyze\x12\x1a\n" +
+	"\bupstream\x18\x04 \x01(\tR\bupstream\"\x9a\x01\n" +
 	"\x0fExplainResponse\x12\x12\n" +
-	"\x04plan\x18\x01 \x01(\tR\x04plan2\x80\x01\n" +
+	"\x04plan\x18\x01 \x01(\tR\x04plan\x12\x18\n" +
+	"\acolumns\x18\x02 \x03(\tR\acolumns\x12\x1f\n" +
+	"\x04rows\x18\x03 \x03(\v2\v.tap.v1.RowR\x04rows\x12\x1e\n" +
+	"\n" +
+	"advisories\x18\x04 \x03(\tR\n" +
+	"advisories\x12\x18\n" +
+	"\aindexes\x18\x05 \x03(\tR\aindexes\"\r\n" +
+	"\vInfoRequest\"2\n" +
+	"\x06TagDef\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05color\x18\x02 \x01(\tR\x05color\"\xfe\x01\n" +
+	"\fInfoResponse\x12G\n" +
+	"\x12tls_cert_not_after\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\x0ftlsCertNotAfter\x12P\n" +
+	"\x17tls_cert_expiry_warning\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x14tlsCertExpiryWarning\x12\"\n" +
+	"\x04tags\x18\x02 \x03(\v2\x0e.tap.v1.TagDefR\x04tags\x12/\n" +
+	"\aproxies\x18\x03 \x03(\v2\x15.tap.v1.ProxyEndpointR\aproxies\"m\n" +
+	"\rProxyEndpoint\x12\x1a\n" +
+	"\bupstream\x18\x01 \x01(\tR\bupstream\x12\x16\n" +
+	"\x06driver\x18\x02 \x01(\tR\x06driver\x12\x16\n" +
+	"\x06listen\x18\x03 \x01(\tR\x06listen\x12\x10\n" +
+	"\x03dsn\x18\x04 \x01(\tR\x03dsn\"F\n" +
+	"\x11SetVerboseRequest\x12\x17\n" +
+	"\aconn_id\x18\x01 \x01(\tR\x06connId\x12\x18\n" +
+	"\averbose\x18\x02 \x01(\bR\averbose\">\n" +
+	"\x12SetVerboseResponse\x12(\n" +
+	"\x10verbose_conn_ids\x18\x01 \x03(\tR\x0everboseConnIds\"\xca\x02\n" +
+	"\fStageLatency\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x04R\x05count\x12/\n" +
+	"\x05total\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x05total\x12+\n" +
+	"\x03max\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x03max\x12+\n" +
+	"\x03p50\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x03p50\x12+\n" +
+	"\x03p99\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03p99\x12\x18\n" +
+	"\abuckets\x18\a \x03(\x04R\abuckets\x12>\n" +
+	"\rbucket_bounds\x18\b \x03(\v2\x19.google.protobuf.DurationR\fbucketBounds\"\x0e\n" +
+	"\fStatsRequest\"\xb1\x02\n" +
+	"\x0fSubscriberStats\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x16\n" +
+	"\x06policy\x18\x03 \x01(\tR\x06policy\x12\x18\n" +
+	"\adropped\x18\x04 \x01(\x04R\adropped\x12\x1a\n" +
+	"\bbuffered\x18\x05 \x01(\x03R\bbuffered\x12\x1a\n" +
+	"\bcapacity\x18\x06 \x01(\x03R\bcapacity\x12\x16\n" +
+	"\x06client\x18\a \x01(\tR\x06client\x120\n" +
+	"\x05since\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\x05since\x12\x1a\n" +
+	"\bfiltered\x18\t \x01(\bR\bfiltered\x12\x18\n" +
+	"\aspilled\x18\n" +
+	" \x01(\x03R\aspilled\x12\x10\n" +
+	"\x03own\x18\v \x01(\bR\x03own\"\xb5\x02\n" +
+	"\rStatsResponse\x12,\n" +
+	"\x06stages\x18\x01 \x03(\v2\x14.tap.v1.StageLatencyR\x06stages\x12#\n" +
+	"\rproxy_dropped\x18\x02 \x01(\x04R\fproxyDropped\x129\n" +
+	"\vsubscribers\x18\x03 \x03(\v2\x17.tap.v1.SubscriberStatsR\vsubscribers\x12\x1f\n" +
+	"\vsampled_out\x18\x04 \x01(\x04R\n" +
+	"sampledOut\x12;\n" +
+	"\rcancellations\x18\x05 \x01(\v2\x15.tap.v1.CancellationsR\rcancellations\x12\x16\n" +
+	"\x06panics\x18\x06 \x01(\x04R\x06panics\x12 \n" +
+	"\vdiagnostics\x18\a \x01(\x04R\vdiagnostics\"\x82\x01\n" +
+	"\rCancellations\x12\x18\n" +
+	"\arelayed\x18\x01 \x01(\x04R\arelayed\x12\x16\n" +
+	"\x06killed\x18\x02 \x01(\x04R\x06killed\x12\x1b\n" +
+	"\ttimed_out\x18\x03 \x01(\x04R\btimedOut\x12\"\n" +
+	"\fdisconnected\x18\x04 \x01(\x04R\fdisconnected\"\x96\x03\n" +
+	"\vTransaction\x12\x13\n" +
+	"\x05tx_id\x18\x01 \x01(\tR\x04txId\x12\x17\n" +
+	"\aconn_id\x18\x02 \x01(\tR\x06connId\x12\x1a\n" +
+	"\bupstream\x18\x03 \x01(\tR\bupstream\x12(\n" +
+	"\x06status\x18\x04 \x01(\x0e2\x10.tap.v1.TxStatusR\x06status\x129\n" +
+	"\n" +
+	"start_time\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tstartTime\x125\n" +
+	"\bend_time\x18\x06 \x01(\v2\x1a.google.protobuf.TimestampR\aendTime\x125\n" +
+	"\bduration\x18\a \x01(\v2\x19.google.protobuf.DurationR\bduration\x12*\n" +
+	"\x06events\x18\b \x03(\v2\x12.tap.v1.QueryEventR\x06events\x12\x1c\n" +
+	"\ttruncated\x18\t \x01(\bR\ttruncated\x12 \n" +
+	"\fglobal_tx_id\x18\n" +
+	" \x01(\tR\n" +
+	"globalTxId\"+\n" +
+	"\x13TransactionsRequest\x12\x14\n" +
+	"\x05limit\x18\x01 \x01(\x05R\x05limit\"O\n" +
+	"\x14TransactionsResponse\x127\n" +
+	"\ftransactions\x18\x01 \x03(\v2\x13.tap.v1.TransactionR\ftransactions\"h\n" +
+	"\vKillRequest\x12\x1f\n" +
+	"\vbackend_pid\x18\x01 \x01(\rR\n" +
+	"backendPid\x12\x1c\n" +
+	"\tterminate\x18\x02 \x01(\bR\tterminate\x12\x1a\n" +
+	"\bupstream\x18\x03 \x01(\tR\bupstream\"\x0e\n" +
+	"\fKillResponse\"\x0f\n" +
+	"\rRoutesRequest\"\xa8\x04\n" +
+	"\n" +
+	"RouteStats\x12\x14\n" +
+	"\x05route\x18\x01 \x01(\tR\x05route\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x16\n" +
+	"\x06errors\x18\x03 \x01(\x05R\x06errors\x12\x10\n" +
+	"\x03qps\x18\x04 \x01(\x01R\x03qps\x12+\n" +
+	"\x03p50\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x03p50\x12+\n" +
+	"\x03p95\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03p95\x12+\n" +
+	"\x03p99\x18\a \x01(\v2\x19.google.protobuf.DurationR\x03p99\x12\x1a\n" +
+	"\brequests\x18\b \x01(\x05R\brequests\x125\n" +
+	"\x17queries_per_request_p50\x18\t \x01(\x05R\x14queriesPerRequestP50\x125\n" +
+	"\x17queries_per_request_p95\x18\n" +
+	" \x01(\x05R\x14queriesPerRequestP95\x125\n" +
+	"\x17queries_per_request_max\x18\v \x01(\x05R\x14queriesPerRequestMax\x12\x1f\n" +
+	"\vover_budget\x18\f \x01(\x05R\n" +
+	"overBudget\x12'\n" +
+	"\x0ftop_fingerprint\x18\r \x01(\tR\x0etopFingerprint\x122\n" +
+	"\x15top_fingerprint_calls\x18\x0e \x01(\x05R\x13topFingerprintCalls\"\x92\x01\n" +
+	"\x0eRoutesResponse\x12*\n" +
+	"\x06routes\x18\x01 \x03(\v2\x12.tap.v1.RouteStatsR\x06routes\x121\n" +
+	"\x06window\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x06window\x12!\n" +
+	"\fquery_budget\x18\x03 \x01(\x05R\vqueryBudget\"\x10\n" +
+	"\x0eTenantsRequest\"\x80\x02\n" +
+	"\vTenantStats\x12\x14\n" +
+	"\x05value\x18\x01 \x01(\tR\x05value\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x16\n" +
+	"\x06errors\x18\x03 \x01(\x05R\x06errors\x12\x10\n" +
+	"\x03qps\x18\x04 \x01(\x01R\x03qps\x12+\n" +
+	"\x03p50\x18\x05 \x01(\v2\x19.google.protobuf.DurationR\x03p50\x12+\n" +
+	"\x03p95\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03p95\x12+\n" +
+	"\x03p99\x18\a \x01(\v2\x19.google.protobuf.DurationR\x03p99\x12\x14\n" +
+	"\x05share\x18\b \x01(\x01R\x05share\"\xa6\x01\n" +
+	"\x0fTenantsResponse\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12-\n" +
+	"\atenants\x18\x02 \x03(\v2\x13.tap.v1.TenantStatsR\atenants\x121\n" +
+	"\x06window\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\x06window\x12\x1b\n" +
+	"\tmax_share\x18\x04 \x01(\x01R\bmaxShare\"\x13\n" +
+	"\x11StatementsRequest\"\xaa\x01\n" +
+	"\x0fServerStatement\x12\x1a\n" +
+	"\bupstream\x18\x01 \x01(\tR\bupstream\x12 \n" +
+	"\vfingerprint\x18\x02 \x01(\tR\vfingerprint\x12\x14\n" +
+	"\x05calls\x18\x03 \x01(\x03R\x05calls\x12/\n" +
+	"\x05total\x18\x04 \x01(\v2\x19.google.protobuf.DurationR\x05total\x12\x12\n" +
+	"\x04rows\x18\x05 \x01(\x03R\x04rows\"\xb8\x02\n" +
+	"\x12StatementsResponse\x127\n" +
+	"\n" +
+	"statements\x18\x01 \x03(\v2\x17.tap.v1.ServerStatementR\n" +
+	"statements\x127\n" +
+	"\tpolled_at\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\bpolledAt\x125\n" +
+	"\binterval\x18\x03 \x01(\v2\x19.google.protobuf.DurationR\binterval\x12>\n" +
+	"\x06errors\x18\x04 \x03(\v2&.tap.v1.StatementsResponse.ErrorsEntryR\x06errors\x1a9\n" +
+	"\vErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x0f\n" +
+	"\rConfigRequest\"$\n" +
+	"\x0eConfigResponse\x12\x12\n" +
+	"\x04yaml\x18\x01 \x01(\tR\x04yaml\"\x12\n" +
+	"\x10DatabasesRequest\"\xba\x02\n" +
+	"\rDatabaseStats\x12\x1a\n" +
+	"\bupstream\x18\x01 \x01(\tR\bupstream\x12\x1a\n" +
+	"\bdatabase\x18\x02 \x01(\tR\bdatabase\x12\x14\n" +
+	"\x05count\x18\x03 \x01(\x05R\x05count\x12\x16\n" +
+	"\x06errors\x18\x04 \x01(\x05R\x06errors\x12\x10\n" +
+	"\x03qps\x18\x05 \x01(\x01R\x03qps\x12+\n" +
+	"\x03p50\x18\x06 \x01(\v2\x19.google.protobuf.DurationR\x03p50\x12+\n" +
+	"\x03p95\x18\a \x01(\v2\x19.google.protobuf.DurationR\x03p95\x12+\n" +
+	"\x03p99\x18\b \x01(\v2\x19.google.protobuf.DurationR\x03p99\x12\x14\n" +
+	"\x05share\x18\t \x01(\x01R\x05share\x12\x14\n" +
+	"\x05users\x18\n" +
+	" \x03(\tR\x05users\"{\n" +
+	"\x11DatabasesResponse\x123\n" +
+	"\tdatabases\x18\x01 \x03(\v2\x15.tap.v1.DatabaseStatsR\tdatabases\x121\n" +
+	"\x06window\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\x06window*o\n" +
+	"\vTrafficKind\x12\x1c\n" +
+	"\x18TRAFFIC_KIND_UNSPECIFIED\x10\x00\x12\x14\n" +
+	"\x10TRAFFIC_KIND_NEW\x10\x01\x12\x15\n" +
+	"\x11TRAFFIC_KIND_RISE\x10\x02\x12\x15\n" +
+	"\x11TRAFFIC_KIND_DROP\x10\x03*L\n" +
+	"\bDelivery\x12\x18\n" +
+	"\x14DELIVERY_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eDELIVERY_BLOCK\x10\x01\x12\x12\n" +
+	"\x0eDELIVERY_SPILL\x10\x02*\x85\x01\n" +
+	"\bTxStatus\x12\x19\n" +
+	"\x15TX_STATUS_UNSPECIFIED\x10\x00\x12\x12\n" +
+	"\x0eTX_STATUS_OPEN\x10\x01\x12\x17\n" +
+	"\x13TX_STATUS_COMMITTED\x10\x02\x12\x19\n" +
+	"\x15TX_STATUS_ROLLED_BACK\x10\x03\x12\x16\n" +
+	"\x12TX_STATUS_PREPARED\x10\x042\xd6\x06\n" +
 	"\n" +
 	"TapService\x126\n" +
 	"\x05Watch\x12\x14.tap.v1.WatchRequest\x1a\x15.tap.v1.WatchResponse0\x01\x12:\n" +
-	"\aExplain\x12\x16.tap.v1.ExplainRequest\x1a\x17.tap.v1.ExplainResponseB|\n" +
+	"\aExplain\x12\x16.tap.v1.ExplainRequest\x1a\x17.tap.v1.ExplainResponse\x121\n" +
+	"\x04Info\x12\x13.tap.v1.InfoRequest\x1a\x14.tap.v1.InfoResponse\x12C\n" +
+	"\n" +
+	"SetVerbose\x12\x19.tap.v1.SetVerboseRequest\x1a\x1a.tap.v1.SetVerboseResponse\x124\n" +
+	"\x05Stats\x12\x14.tap.v1.StatsRequest\x1a\x15.tap.v1.StatsResponse\x12I\n" +
+	"\fTransactions\x12\x1b.tap.v1.TransactionsRequest\x1a\x1c.tap.v1.TransactionsResponse\x12=\n" +
+	"\bAnnotate\x12\x17.tap.v1.AnnotateRequest\x1a\x18.tap.v1.AnnotateResponse\x124\n" +
+	"\x05Query\x12\x14.tap.v1.QueryRequest\x1a\x15.tap.v1.QueryResponse\x127\n" +
+	"\x06Routes\x12\x15.tap.v1.RoutesRequest\x1a\x16.tap.v1.RoutesResponse\x12:\n" +
+	"\aTenants\x12\x16.tap.v1.TenantsRequest\x1a\x17.tap.v1.TenantsResponse\x12@\n" +
+	"\tDatabases\x12\x18.tap.v1.DatabasesRequest\x1a\x19.tap.v1.DatabasesResponse\x12C\n" +
+	"\n" +
+	"Statements\x12\x19.tap.v1.StatementsRequest\x1a\x1a.tap.v1.StatementsResponse\x127\n" +
+	"\x06Config\x12\x15.tap.v1.ConfigRequest\x1a\x16.tap.v1.ConfigResponse\x121\n" +
+	"\x04Kill\x12\x13.tap.v1.KillRequest\x1a\x14.tap.v1.KillResponseB|\n" +
 	"\n" +
 	"com.tap.v1B\bTapProtoP\x01Z+github.com/mickamy/sql-tap/gen/tap/v1;tapv1\xa2\x02\x03TXX\xaa\x02\x06Tap.V1\xca\x02\x06Tap\\V1\xe2\x02\x12Tap\\V1\\GPBMetadata\xea\x02\aTap::V1b\x06proto3"
 
@@ -360,29 +4897,195 @@ func file_tap_v1_tap_proto_rawDescGZIP() []byte {
 	return file_tap_v1_tap_proto_rawDescData
 }
 
-var file_tap_v1_tap_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_tap_v1_tap_proto_enumTypes = make([]protoimpl.EnumInfo, 3)
+var file_tap_v1_tap_proto_msgTypes = make([]protoimpl.MessageInfo, 62)
 var file_tap_v1_tap_proto_goTypes = []any{
-	(*QueryEvent)(nil),            // 0: tap.v1.QueryEvent
-	(*WatchRequest)(nil),          // 1: tap.v1.WatchRequest
-	(*WatchResponse)(nil),         // 2: tap.v1.WatchResponse
-	(*ExplainRequest)(nil),        // 3: tap.v1.ExplainRequest
-	(*ExplainResponse)(nil),       // 4: tap.v1.ExplainResponse
-	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
-	(*durationpb.Duration)(nil),   // 6: google.protobuf.Duration
+	(TrafficKind)(0),              // 0: tap.v1.TrafficKind
+	(Delivery)(0),                 // 1: tap.v1.Delivery
+	(TxStatus)(0),                 // 2: tap.v1.TxStatus
+	(*Phase)(nil),                 // 3: tap.v1.Phase
+	(*Row)(nil),                   // 4: tap.v1.Row
+	(*ErrorDetail)(nil),           // 5: tap.v1.ErrorDetail
+	(*Anomaly)(nil),               // 6: tap.v1.Anomaly
+	(*NPlusOne)(nil),              // 7: tap.v1.NPlusOne
+	(*TrafficChange)(nil),         // 8: tap.v1.TrafficChange
+	(*Panic)(nil),                 // 9: tap.v1.Panic
+	(*Diagnostic)(nil),            // 10: tap.v1.Diagnostic
+	(*AutoPlan)(nil),              // 11: tap.v1.AutoPlan
+	(*TenantQuota)(nil),           // 12: tap.v1.TenantQuota
+	(*Column)(nil),                // 13: tap.v1.Column
+	(*Violation)(nil),             // 14: tap.v1.Violation
+	(*Routing)(nil),               // 15: tap.v1.Routing
+	(*QueryEvent)(nil),            // 16: tap.v1.QueryEvent
+	(*WatchRequest)(nil),          // 17: tap.v1.WatchRequest
+	(*Selector)(nil),              // 18: tap.v1.Selector
+	(*Sampling)(nil),              // 19: tap.v1.Sampling
+	(*WatchResponse)(nil),         // 20: tap.v1.WatchResponse
+	(*Annotation)(nil),            // 21: tap.v1.Annotation
+	(*Presence)(nil),              // 22: tap.v1.Presence
+	(*AnnotateRequest)(nil),       // 23: tap.v1.AnnotateRequest
+	(*AnnotateResponse)(nil),      // 24: tap.v1.AnnotateResponse
+	(*QueryRequest)(nil),          // 25: tap.v1.QueryRequest
+	(*QueryResponse)(nil),         // 26: tap.v1.QueryResponse
+	(*ExplainRequest)(nil),        // 27: tap.v1.ExplainRequest
+	(*ExplainResponse)(nil),       // 28: tap.v1.ExplainResponse
+	(*InfoRequest)(nil),           // 29: tap.v1.InfoRequest
+	(*TagDef)(nil),                // 30: tap.v1.TagDef
+	(*InfoResponse)(nil),          // 31: tap.v1.InfoResponse
+	(*ProxyEndpoint)(nil),         // 32: tap.v1.ProxyEndpoint
+	(*SetVerboseRequest)(nil),     // 33: tap.v1.SetVerboseRequest
+	(*SetVerboseResponse)(nil),    // 34: tap.v1.SetVerboseResponse
+	(*StageLatency)(nil),          // 35: tap.v1.StageLatency
+	(*StatsRequest)(nil),          // 36: tap.v1.StatsRequest
+	(*SubscriberStats)(nil),       // 37: tap.v1.SubscriberStats
+	(*StatsResponse)(nil),         // 38: tap.v1.StatsResponse
+	(*Cancellations)(nil),         // 39: tap.v1.Cancellations
+	(*Transaction)(nil),           // 40: tap.v1.Transaction
+	(*TransactionsRequest)(nil),   // 41: tap.v1.TransactionsRequest
+	(*TransactionsResponse)(nil),  // 42: tap.v1.TransactionsResponse
+	(*KillRequest)(nil),           // 43: tap.v1.KillRequest
+	(*KillResponse)(nil),          // 44: tap.v1.KillResponse
+	(*RoutesRequest)(nil),         // 45: tap.v1.RoutesRequest
+	(*RouteStats)(nil),            // 46: tap.v1.RouteStats
+	(*RoutesResponse)(nil),        // 47: tap.v1.RoutesResponse
+	(*TenantsRequest)(nil),        // 48: tap.v1.TenantsRequest
+	(*TenantStats)(nil),           // 49: tap.v1.TenantStats
+	(*TenantsResponse)(nil),       // 50: tap.v1.TenantsResponse
+	(*StatementsRequest)(nil),     // 51: tap.v1.StatementsRequest
+	(*ServerStatement)(nil),       // 52: tap.v1.ServerStatement
+	(*StatementsResponse)(nil),    // 53: tap.v1.StatementsResponse
+	(*ConfigRequest)(nil),         // 54: tap.v1.ConfigRequest
+	(*ConfigResponse)(nil),        // 55: tap.v1.ConfigResponse
+	(*DatabasesRequest)(nil),      // 56: tap.v1.DatabasesRequest
+	(*DatabaseStats)(nil),         // 57: tap.v1.DatabaseStats
+	(*DatabasesResponse)(nil),     // 58: tap.v1.DatabasesResponse
+	nil,                           // 59: tap.v1.QueryEvent.ServerParamsEntry
+	nil,                           // 60: tap.v1.QueryEvent.StartupParamsEntry
+	nil,                           // 61: tap.v1.QueryEvent.FieldsEntry
+	nil,                           // 62: tap.v1.QueryEvent.ExtensionsEntry
+	nil,                           // 63: tap.v1.Selector.FieldsEntry
+	nil,                           // 64: tap.v1.StatementsResponse.ErrorsEntry
+	(*durationpb.Duration)(nil),   // 65: google.protobuf.Duration
+	(*timestamppb.Timestamp)(nil), // 66: google.protobuf.Timestamp
 }
 var file_tap_v1_tap_proto_depIdxs = []int32{
-	5, // 0: tap.v1.QueryEvent.start_time:type_name -> google.protobuf.Timestamp
-	6, // 1: tap.v1.QueryEvent.duration:type_name -> google.protobuf.Duration
-	0, // 2: tap.v1.WatchResponse.event:type_name -> tap.v1.QueryEvent
-	1, // 3: tap.v1.TapService.Watch:input_type -> tap.v1.WatchRequest
-	3, // 4: tap.v1.TapService.Explain:input_type -> tap.v1.ExplainRequest
-	2, // 5: tap.v1.TapService.Watch:output_type -> tap.v1.WatchResponse
-	4, // 6: tap.v1.TapService.Explain:output_type -> tap.v1.ExplainResponse
-	5, // [5:7] is the sub-list for method output_type
-	3, // [3:5] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	65, // 0: tap.v1.Phase.duration:type_name -> google.protobuf.Duration
+	65, // 1: tap.v1.Anomaly.baseline:type_name -> google.protobuf.Duration
+	65, // 2: tap.v1.NPlusOne.span:type_name -> google.protobuf.Duration
+	0,  // 3: tap.v1.TrafficChange.kind:type_name -> tap.v1.TrafficKind
+	65, // 4: tap.v1.TrafficChange.window:type_name -> google.protobuf.Duration
+	65, // 5: tap.v1.TenantQuota.window:type_name -> google.protobuf.Duration
+	66, // 6: tap.v1.QueryEvent.start_time:type_name -> google.protobuf.Timestamp
+	65, // 7: tap.v1.QueryEvent.duration:type_name -> google.protobuf.Duration
+	3,  // 8: tap.v1.QueryEvent.phases:type_name -> tap.v1.Phase
+	4,  // 9: tap.v1.QueryEvent.row_samples:type_name -> tap.v1.Row
+	5,  // 10: tap.v1.QueryEvent.error_detail:type_name -> tap.v1.ErrorDetail
+	6,  // 11: tap.v1.QueryEvent.anomaly:type_name -> tap.v1.Anomaly
+	8,  // 12: tap.v1.QueryEvent.traffic:type_name -> tap.v1.TrafficChange
+	7,  // 13: tap.v1.QueryEvent.n_plus_one:type_name -> tap.v1.NPlusOne
+	65, // 14: tap.v1.QueryEvent.auth_duration:type_name -> google.protobuf.Duration
+	59, // 15: tap.v1.QueryEvent.server_params:type_name -> tap.v1.QueryEvent.ServerParamsEntry
+	15, // 16: tap.v1.QueryEvent.routing:type_name -> tap.v1.Routing
+	5,  // 17: tap.v1.QueryEvent.notice:type_name -> tap.v1.ErrorDetail
+	60, // 18: tap.v1.QueryEvent.startup_params:type_name -> tap.v1.QueryEvent.StartupParamsEntry
+	61, // 19: tap.v1.QueryEvent.fields:type_name -> tap.v1.QueryEvent.FieldsEntry
+	12, // 20: tap.v1.QueryEvent.quota:type_name -> tap.v1.TenantQuota
+	11, // 21: tap.v1.QueryEvent.plan:type_name -> tap.v1.AutoPlan
+	9,  // 22: tap.v1.QueryEvent.panic:type_name -> tap.v1.Panic
+	65, // 23: tap.v1.QueryEvent.queue_latency:type_name -> google.protobuf.Duration
+	65, // 24: tap.v1.QueryEvent.exec_latency:type_name -> google.protobuf.Duration
+	65, // 25: tap.v1.QueryEvent.fetch_latency:type_name -> google.protobuf.Duration
+	62, // 26: tap.v1.QueryEvent.extensions:type_name -> tap.v1.QueryEvent.ExtensionsEntry
+	10, // 27: tap.v1.QueryEvent.diagnostic:type_name -> tap.v1.Diagnostic
+	14, // 28: tap.v1.QueryEvent.violation:type_name -> tap.v1.Violation
+	13, // 29: tap.v1.QueryEvent.columns:type_name -> tap.v1.Column
+	1,  // 30: tap.v1.WatchRequest.delivery:type_name -> tap.v1.Delivery
+	19, // 31: tap.v1.WatchRequest.sampling:type_name -> tap.v1.Sampling
+	66, // 32: tap.v1.WatchRequest.resume_after:type_name -> google.protobuf.Timestamp
+	18, // 33: tap.v1.WatchRequest.selector:type_name -> tap.v1.Selector
+	63, // 34: tap.v1.Selector.fields:type_name -> tap.v1.Selector.FieldsEntry
+	16, // 35: tap.v1.WatchResponse.event:type_name -> tap.v1.QueryEvent
+	21, // 36: tap.v1.WatchResponse.annotation:type_name -> tap.v1.Annotation
+	22, // 37: tap.v1.WatchResponse.presence:type_name -> tap.v1.Presence
+	66, // 38: tap.v1.Annotation.time:type_name -> google.protobuf.Timestamp
+	21, // 39: tap.v1.AnnotateResponse.annotation:type_name -> tap.v1.Annotation
+	66, // 40: tap.v1.QueryRequest.since:type_name -> google.protobuf.Timestamp
+	66, // 41: tap.v1.QueryRequest.until:type_name -> google.protobuf.Timestamp
+	65, // 42: tap.v1.QueryRequest.min_duration:type_name -> google.protobuf.Duration
+	16, // 43: tap.v1.QueryResponse.events:type_name -> tap.v1.QueryEvent
+	4,  // 44: tap.v1.ExplainResponse.rows:type_name -> tap.v1.Row
+	66, // 45: tap.v1.InfoResponse.tls_cert_not_after:type_name -> google.protobuf.Timestamp
+	65, // 46: tap.v1.InfoResponse.tls_cert_expiry_warning:type_name -> google.protobuf.Duration
+	30, // 47: tap.v1.InfoResponse.tags:type_name -> tap.v1.TagDef
+	32, // 48: tap.v1.InfoResponse.proxies:type_name -> tap.v1.ProxyEndpoint
+	65, // 49: tap.v1.StageLatency.total:type_name -> google.protobuf.Duration
+	65, // 50: tap.v1.StageLatency.max:type_name -> google.protobuf.Duration
+	65, // 51: tap.v1.StageLatency.p50:type_name -> google.protobuf.Duration
+	65, // 52: tap.v1.StageLatency.p99:type_name -> google.protobuf.Duration
+	65, // 53: tap.v1.StageLatency.bucket_bounds:type_name -> google.protobuf.Duration
+	66, // 54: tap.v1.SubscriberStats.since:type_name -> google.protobuf.Timestamp
+	35, // 55: tap.v1.StatsResponse.stages:type_name -> tap.v1.StageLatency
+	37, // 56: tap.v1.StatsResponse.subscribers:type_name -> tap.v1.SubscriberStats
+	39, // 57: tap.v1.StatsResponse.cancellations:type_name -> tap.v1.Cancellations
+	2,  // 58: tap.v1.Transaction.status:type_name -> tap.v1.TxStatus
+	66, // 59: tap.v1.Transaction.start_time:type_name -> google.protobuf.Timestamp
+	66, // 60: tap.v1.Transaction.end_time:type_name -> google.protobuf.Timestamp
+	65, // 61: tap.v1.Transaction.duration:type_name -> google.protobuf.Duration
+	16, // 62: tap.v1.Transaction.events:type_name -> tap.v1.QueryEvent
+	40, // 63: tap.v1.TransactionsResponse.transactions:type_name -> tap.v1.Transaction
+	65, // 64: tap.v1.RouteStats.p50:type_name -> google.protobuf.Duration
+	65, // 65: tap.v1.RouteStats.p95:type_name -> google.protobuf.Duration
+	65, // 66: tap.v1.RouteStats.p99:type_name -> google.protobuf.Duration
+	46, // 67: tap.v1.RoutesResponse.routes:type_name -> tap.v1.RouteStats
+	65, // 68: tap.v1.RoutesResponse.window:type_name -> google.protobuf.Duration
+	65, // 69: tap.v1.TenantStats.p50:type_name -> google.protobuf.Duration
+	65, // 70: tap.v1.TenantStats.p95:type_name -> google.protobuf.Duration
+	65, // 71: tap.v1.TenantStats.p99:type_name -> google.protobuf.Duration
+	49, // 72: tap.v1.TenantsResponse.tenants:type_name -> tap.v1.TenantStats
+	65, // 73: tap.v1.TenantsResponse.window:type_name -> google.protobuf.Duration
+	65, // 74: tap.v1.ServerStatement.total:type_name -> google.protobuf.Duration
+	52, // 75: tap.v1.StatementsResponse.statements:type_name -> tap.v1.ServerStatement
+	66, // 76: tap.v1.StatementsResponse.polled_at:type_name -> google.protobuf.Timestamp
+	65, // 77: tap.v1.StatementsResponse.interval:type_name -> google.protobuf.Duration
+	64, // 78: tap.v1.StatementsResponse.errors:type_name -> tap.v1.StatementsResponse.ErrorsEntry
+	65, // 79: tap.v1.DatabaseStats.p50:type_name -> google.protobuf.Duration
+	65, // 80: tap.v1.DatabaseStats.p95:type_name -> google.protobuf.Duration
+	65, // 81: tap.v1.DatabaseStats.p99:type_name -> google.protobuf.Duration
+	57, // 82: tap.v1.DatabasesResponse.databases:type_name -> tap.v1.DatabaseStats
+	65, // 83: tap.v1.DatabasesResponse.window:type_name -> google.protobuf.Duration
+	17, // 84: tap.v1.TapService.Watch:input_type -> tap.v1.WatchRequest
+	27, // 85: tap.v1.TapService.Explain:input_type -> tap.v1.ExplainRequest
+	29, // 86: tap.v1.TapService.Info:input_type -> tap.v1.InfoRequest
+	33, // 87: tap.v1.TapService.SetVerbose:input_type -> tap.v1.SetVerboseRequest
+	36, // 88: tap.v1.TapService.Stats:input_type -> tap.v1.StatsRequest
+	41, // 89: tap.v1.TapService.Transactions:input_type -> tap.v1.TransactionsRequest
+	23, // 90: tap.v1.TapService.Annotate:input_type -> tap.v1.AnnotateRequest
+	25, // 91: tap.v1.TapService.Query:input_type -> tap.v1.QueryRequest
+	45, // 92: tap.v1.TapService.Routes:input_type -> tap.v1.RoutesRequest
+	48, // 93: tap.v1.TapService.Tenants:input_type -> tap.v1.TenantsRequest
+	56, // 94: tap.v1.TapService.Databases:input_type -> tap.v1.DatabasesRequest
+	51, // 95: tap.v1.TapService.Statements:input_type -> tap.v1.StatementsRequest
+	54, // 96: tap.v1.TapService.Config:input_type -> tap.v1.ConfigRequest
+	43, // 97: tap.v1.TapService.Kill:input_type -> tap.v1.KillRequest
+	20, // 98: tap.v1.TapService.Watch:output_type -> tap.v1.WatchResponse
+	28, // 99: tap.v1.TapService.Explain:output_type -> tap.v1.ExplainResponse
+	31, // 100: tap.v1.TapService.Info:output_type -> tap.v1.InfoResponse
+	34, // 101: tap.v1.TapService.SetVerbose:output_type -> tap.v1.SetVerboseResponse
+	38, // 102: tap.v1.TapService.Stats:output_type -> tap.v1.StatsResponse
+	42, // 103: tap.v1.TapService.Transactions:output_type -> tap.v1.TransactionsResponse
+	24, // 104: tap.v1.TapService.Annotate:output_type -> tap.v1.AnnotateResponse
+	26, // 105: tap.v1.TapService.Query:output_type -> tap.v1.QueryResponse
+	47, // 106: tap.v1.TapService.Routes:output_type -> tap.v1.RoutesResponse
+	50, // 107: tap.v1.TapService.Tenants:output_type -> tap.v1.TenantsResponse
+	58, // 108: tap.v1.TapService.Databases:output_type -> tap.v1.DatabasesResponse
+	53, // 109: tap.v1.TapService.Statements:output_type -> tap.v1.StatementsResponse
+	55, // 110: tap.v1.TapService.Config:output_type -> tap.v1.ConfigResponse
+	44, // 111: tap.v1.TapService.Kill:output_type -> tap.v1.KillResponse
+	98, // [98:112] is the sub-list for method output_type
+	84, // [84:98] is the sub-list for method input_type
+	84, // [84:84] is the sub-list for extension type_name
+	84, // [84:84] is the sub-list for extension extendee
+	0,  // [0:84] is the sub-list for field type_name
 }
 
 func init() { file_tap_v1_tap_proto_init() }
@@ -395,13 +5098,14 @@ func file_tap_v1_tap_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tap_v1_tap_proto_rawDesc), len(file_tap_v1_tap_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   5,
+			NumEnums:      3,
+			NumMessages:   62,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_tap_v1_tap_proto_goTypes,
 		DependencyIndexes: file_tap_v1_tap_proto_depIdxs,
+		EnumInfos:         file_tap_v1_tap_proto_enumTypes,
 		MessageInfos:      file_tap_v1_tap_proto_msgTypes,
 	}.Build()
 	File_tap_v1_tap_proto = out.File
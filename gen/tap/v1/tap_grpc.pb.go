@@ -19,8 +19,20 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	TapService_Watch_FullMethodName   = "/tap.v1.TapService/Watch"
-	TapService_Explain_FullMethodName = "/tap.v1.TapService/Explain"
+	TapService_Watch_FullMethodName        = "/tap.v1.TapService/Watch"
+	TapService_Explain_FullMethodName      = "/tap.v1.TapService/Explain"
+	TapService_Info_FullMethodName         = "/tap.v1.TapService/Info"
+	TapService_SetVerbose_FullMethodName   = "/tap.v1.TapService/SetVerbose"
+	TapService_Stats_FullMethodName        = "/tap.v1.TapService/Stats"
+	TapService_Transactions_FullMethodName = "/tap.v1.TapService/Transactions"
+	TapService_Annotate_FullMethodName     = "/tap.v1.TapService/Annotate"
+	TapService_Query_FullMethodName        = "/tap.v1.TapService/Query"
+	TapService_Routes_FullMethodName       = "/tap.v1.TapService/Routes"
+	TapService_Tenants_FullMethodName      = "/tap.v1.TapService/Tenants"
+	TapService_Databases_FullMethodName    = "/tap.v1.TapService/Databases"
+	TapService_Statements_FullMethodName   = "/tap.v1.TapService/Statements"
+	TapService_Config_FullMethodName       = "/tap.v1.TapService/Config"
+	TapService_Kill_FullMethodName         = "/tap.v1.TapService/Kill"
 )
 
 // TapServiceClient is the client API for TapService service.
@@ -29,6 +41,18 @@ const (
 type TapServiceClient interface {
 	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[WatchResponse], error)
 	Explain(ctx context.Context, in *ExplainRequest, opts ...grpc.CallOption) (*ExplainResponse, error)
+	Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error)
+	SetVerbose(ctx context.Context, in *SetVerboseRequest, opts ...grpc.CallOption) (*SetVerboseResponse, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error)
+	Transactions(ctx context.Context, in *TransactionsRequest, opts ...grpc.CallOption) (*TransactionsResponse, error)
+	Annotate(ctx context.Context, in *AnnotateRequest, opts ...grpc.CallOption) (*AnnotateResponse, error)
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+	Routes(ctx context.Context, in *RoutesRequest, opts ...grpc.CallOption) (*RoutesResponse, error)
+	Tenants(ctx context.Context, in *TenantsRequest, opts ...grpc.CallOption) (*TenantsResponse, error)
+	Databases(ctx context.Context, in *DatabasesRequest, opts ...grpc.CallOption) (*DatabasesResponse, error)
+	Statements(ctx context.Context, in *StatementsRequest, opts ...grpc.CallOption) (*StatementsResponse, error)
+	Config(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error)
+	Kill(ctx context.Context, in *KillRequest, opts ...grpc.CallOption) (*KillResponse, error)
 }
 
 type tapServiceClient struct {
@@ -68,12 +92,144 @@ func (c *tapServiceClient) Explain(ctx context.Context, in *ExplainRequest, opts
 	return out, nil
 }
 
+func (c *tapServiceClient) Info(ctx context.Context, in *InfoRequest, opts ...grpc.CallOption) (*InfoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(InfoResponse)
+	err := c.cc.Invoke(ctx, TapService_Info_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) SetVerbose(ctx context.Context, in *SetVerboseRequest, opts ...grpc.CallOption) (*SetVerboseResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SetVerboseResponse)
+	err := c.cc.Invoke(ctx, TapService_SetVerbose_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (*StatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatsResponse)
+	err := c.cc.Invoke(ctx, TapService_Stats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Transactions(ctx context.Context, in *TransactionsRequest, opts ...grpc.CallOption) (*TransactionsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TransactionsResponse)
+	err := c.cc.Invoke(ctx, TapService_Transactions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Annotate(ctx context.Context, in *AnnotateRequest, opts ...grpc.CallOption) (*AnnotateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnnotateResponse)
+	err := c.cc.Invoke(ctx, TapService_Annotate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(QueryResponse)
+	err := c.cc.Invoke(ctx, TapService_Query_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Routes(ctx context.Context, in *RoutesRequest, opts ...grpc.CallOption) (*RoutesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RoutesResponse)
+	err := c.cc.Invoke(ctx, TapService_Routes_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Tenants(ctx context.Context, in *TenantsRequest, opts ...grpc.CallOption) (*TenantsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TenantsResponse)
+	err := c.cc.Invoke(ctx, TapService_Tenants_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Databases(ctx context.Context, in *DatabasesRequest, opts ...grpc.CallOption) (*DatabasesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DatabasesResponse)
+	err := c.cc.Invoke(ctx, TapService_Databases_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Statements(ctx context.Context, in *StatementsRequest, opts ...grpc.CallOption) (*StatementsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StatementsResponse)
+	err := c.cc.Invoke(ctx, TapService_Statements_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Config(ctx context.Context, in *ConfigRequest, opts ...grpc.CallOption) (*ConfigResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ConfigResponse)
+	err := c.cc.Invoke(ctx, TapService_Config_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *tapServiceClient) Kill(ctx context.Context, in *KillRequest, opts ...grpc.CallOption) (*KillResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(KillResponse)
+	err := c.cc.Invoke(ctx, TapService_Kill_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // TapServiceServer is the server API for TapService service.
 // All implementations must embed UnimplementedTapServiceServer
 // for forward compatibility.
 type TapServiceServer interface {
 	Watch(*WatchRequest, grpc.ServerStreamingServer[WatchResponse]) error
 	Explain(context.Context, *ExplainRequest) (*ExplainResponse, error)
+	Info(context.Context, *InfoRequest) (*InfoResponse, error)
+	SetVerbose(context.Context, *SetVerboseRequest) (*SetVerboseResponse, error)
+	Stats(context.Context, *StatsRequest) (*StatsResponse, error)
+	Transactions(context.Context, *TransactionsRequest) (*TransactionsResponse, error)
+	Annotate(context.Context, *AnnotateRequest) (*AnnotateResponse, error)
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
+	Routes(context.Context, *RoutesRequest) (*RoutesResponse, error)
+	Tenants(context.Context, *TenantsRequest) (*TenantsResponse, error)
+	Databases(context.Context, *DatabasesRequest) (*DatabasesResponse, error)
+	Statements(context.Context, *StatementsRequest) (*StatementsResponse, error)
+	Config(context.Context, *ConfigRequest) (*ConfigResponse, error)
+	Kill(context.Context, *KillRequest) (*KillResponse, error)
 	mustEmbedUnimplementedTapServiceServer()
 }
 
@@ -90,6 +246,42 @@ func (UnimplementedTapServiceServer) Watch(*WatchRequest, grpc.ServerStreamingSe
 func (UnimplementedTapServiceServer) Explain(context.Context, *ExplainRequest) (*ExplainResponse, error) {
 	return nil, status.Error(codes.Unimplemented, "method Explain not implemented")
 }
+func (UnimplementedTapServiceServer) Info(context.Context, *InfoRequest) (*InfoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Info not implemented")
+}
+func (UnimplementedTapServiceServer) SetVerbose(context.Context, *SetVerboseRequest) (*SetVerboseResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method SetVerbose not implemented")
+}
+func (UnimplementedTapServiceServer) Stats(context.Context, *StatsRequest) (*StatsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedTapServiceServer) Transactions(context.Context, *TransactionsRequest) (*TransactionsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Transactions not implemented")
+}
+func (UnimplementedTapServiceServer) Annotate(context.Context, *AnnotateRequest) (*AnnotateResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Annotate not implemented")
+}
+func (UnimplementedTapServiceServer) Query(context.Context, *QueryRequest) (*QueryResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Query not implemented")
+}
+func (UnimplementedTapServiceServer) Routes(context.Context, *RoutesRequest) (*RoutesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Routes not implemented")
+}
+func (UnimplementedTapServiceServer) Tenants(context.Context, *TenantsRequest) (*TenantsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Tenants not implemented")
+}
+func (UnimplementedTapServiceServer) Databases(context.Context, *DatabasesRequest) (*DatabasesResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Databases not implemented")
+}
+func (UnimplementedTapServiceServer) Statements(context.Context, *StatementsRequest) (*StatementsResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Statements not implemented")
+}
+func (UnimplementedTapServiceServer) Config(context.Context, *ConfigRequest) (*ConfigResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Config not implemented")
+}
+func (UnimplementedTapServiceServer) Kill(context.Context, *KillRequest) (*KillResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Kill not implemented")
+}
 func (UnimplementedTapServiceServer) mustEmbedUnimplementedTapServiceServer() {}
 func (UnimplementedTapServiceServer) testEmbeddedByValue()                    {}
 
@@ -140,6 +332,222 @@ func _TapService_Explain_Handler(srv interface{}, ctx context.Context, dec func(
 	return interceptor(ctx, in, info, handler)
 }
 
+func _TapService_Info_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InfoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Info(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Info_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Info(ctx, req.(*InfoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_SetVerbose_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetVerboseRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).SetVerbose(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_SetVerbose_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).SetVerbose(ctx, req.(*SetVerboseRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Stats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Stats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Stats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Stats(ctx, req.(*StatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Transactions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TransactionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Transactions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Transactions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Transactions(ctx, req.(*TransactionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Annotate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnnotateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Annotate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Annotate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Annotate(ctx, req.(*AnnotateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Query_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Routes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RoutesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Routes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Routes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Routes(ctx, req.(*RoutesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Tenants_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TenantsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Tenants(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Tenants_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Tenants(ctx, req.(*TenantsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Databases_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DatabasesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Databases(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Databases_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Databases(ctx, req.(*DatabasesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Statements_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatementsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Statements(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Statements_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Statements(ctx, req.(*StatementsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Config_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Config(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Config_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Config(ctx, req.(*ConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TapService_Kill_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KillRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TapServiceServer).Kill(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: TapService_Kill_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TapServiceServer).Kill(ctx, req.(*KillRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // TapService_ServiceDesc is the grpc.ServiceDesc for TapService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -151,6 +559,54 @@ var TapService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Explain",
 			Handler:    _TapService_Explain_Handler,
 		},
+		{
+			MethodName: "Info",
+			Handler:    _TapService_Info_Handler,
+		},
+		{
+			MethodName: "SetVerbose",
+			Handler:    _TapService_SetVerbose_Handler,
+		},
+		{
+			MethodName: "Stats",
+			Handler:    _TapService_Stats_Handler,
+		},
+		{
+			MethodName: "Transactions",
+			Handler:    _TapService_Transactions_Handler,
+		},
+		{
+			MethodName: "Annotate",
+			Handler:    _TapService_Annotate_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _TapService_Query_Handler,
+		},
+		{
+			MethodName: "Routes",
+			Handler:    _TapService_Routes_Handler,
+		},
+		{
+			MethodName: "Tenants",
+			Handler:    _TapService_Tenants_Handler,
+		},
+		{
+			MethodName: "Databases",
+			Handler:    _TapService_Databases_Handler,
+		},
+		{
+			MethodName: "Statements",
+			Handler:    _TapService_Statements_Handler,
+		},
+		{
+			MethodName: "Config",
+			Handler:    _TapService_Config_Handler,
+		},
+		{
+			MethodName: "Kill",
+			Handler:    _TapService_Kill_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,110 @@
+// Package advisory flags queries and plans that tend to explain latency:
+// maintenance statements, temporary tables, and sorts or hashes spilling to
+// disk.
+package advisory
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+const (
+	// TempDisk is the tag for temporary tables and disk spills.
+	TempDisk = "temp/disk"
+	// Maintenance is the tag for VACUUM, ANALYZE, REINDEX, and similar
+	// statements, which hold locks and compete for I/O while they run.
+	Maintenance = "maintenance"
+)
+
+// Defs returns the advisory tags with their TUI colors.
+func Defs() []tagger.Def {
+	return []tagger.Def{
+		{Name: TempDisk, Color: "208"},
+		{Name: Maintenance, Color: "3"},
+	}
+}
+
+var (
+	// Postgres VACUUM, ANALYZE, REINDEX, and CLUSTER; MySQL
+	// {ANALYZE | OPTIMIZE | CHECK | REPAIR} [NO_WRITE_TO_BINLOG | LOCAL] TABLE.
+	reMaintenance = regexp.MustCompile(`(?is)^\s*(?:VACUUM|ANALY[SZ]E|REINDEX|CLUSTER|(?:OPTIMIZE|CHECK|REPAIR)\s+(?:NO_WRITE_TO_BINLOG\s+|LOCAL\s+)?TABLES?)\b`)
+
+	// CREATE [GLOBAL | LOCAL] {TEMP | TEMPORARY} TABLE, and Postgres
+	// SELECT ... INTO [TEMP | TEMPORARY] [TABLE] name.
+	reTempTable = regexp.MustCompile(`(?is)^\s*CREATE\s+(?:GLOBAL\s+|LOCAL\s+)?TEMP(?:ORARY)?\s+TABLE\b|\bINTO\s+TEMP(?:ORARY)?\s+(?:TABLE\s+)?\w`)
+
+	// Postgres: "Sort Method: external merge  Disk: 1024kB", hash aggregate
+	// "Disk Usage: 2048kB", "Buffers: temp read=10 written=10". MySQL:
+	// "Using temporary" (traditional) or "<temporary>" (tree).
+	reSpill = regexp.MustCompile(`(?i)Sort Method: external|\bDisk(?: Usage)?: \d|\btemp (?:read|written)=|Using temporary|<temporary>`)
+
+	// Postgres hash joins that split into batches wrote them to disk.
+	reBatches = regexp.MustCompile(`\bBatches: (\d+)`)
+)
+
+// Query returns the advisories that apply to a query's text.
+func Query(q string) []string {
+	var tags []string
+	if reMaintenance.MatchString(q) {
+		tags = append(tags, Maintenance)
+	}
+	if reTempTable.MatchString(q) {
+		tags = append(tags, TempDisk)
+	}
+	return tags
+}
+
+// Apply adds the advisories for ev's query to its tags.
+func Apply(ev *proxy.Event) {
+	for _, a := range Query(ev.Query) {
+		if !slices.Contains(ev.Tags, a) {
+			ev.Tags = append(ev.Tags, a)
+		}
+	}
+}
+
+// Plan returns the advisories that apply to an EXPLAIN result.
+func Plan(res *explain.Result) []string {
+	if res == nil {
+		return nil
+	}
+	if reSpill.MatchString(res.Plan) || hashBatches(res.Plan) || tidbDisk(res) {
+		return []string{TempDisk}
+	}
+	return nil
+}
+
+func hashBatches(plan string) bool {
+	for _, m := range reBatches.FindAllStringSubmatch(plan, -1) {
+		if n, err := strconv.Atoi(m[1]); err == nil && n > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// tidbDisk reports whether a TiDB EXPLAIN ANALYZE table shows disk usage in
+// its "disk" column, which reads "N/A" for operators that stayed in memory.
+func tidbDisk(res *explain.Result) bool {
+	col := slices.IndexFunc(res.Columns, func(c string) bool { return strings.EqualFold(c, "disk") })
+	if col < 0 {
+		return false
+	}
+	for _, row := range res.Rows {
+		if col >= len(row) {
+			continue
+		}
+		switch v := strings.TrimSpace(row[col]); v {
+		case "", "N/A", "NULL", "0 Bytes":
+		default:
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package advisory_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/advisory"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestApply(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		tags  []string
+		want  []string
+	}{
+		{name: "plain select", query: "SELECT * FROM users", want: nil},
+		{name: "create temp table", query: "CREATE TEMP TABLE t (id int)", want: []string{advisory.TempDisk}},
+		{name: "create temporary table", query: "  create temporary table t as select 1", want: []string{advisory.TempDisk}},
+		{name: "global temporary", query: "CREATE GLOBAL TEMPORARY TABLE t (id int)", want: []string{advisory.TempDisk}},
+		{name: "select into temp", query: "SELECT * INTO TEMP t FROM users", want: []string{advisory.TempDisk}},
+		{name: "temp column", query: "SELECT temp FROM readings", want: nil},
+		{name: "create table", query: "CREATE TABLE temp_log (id int)", want: nil},
+		{name: "keeps existing tags", query: "CREATE TEMP TABLE t (id int)", tags: []string{"etl"}, want: []string{"etl", advisory.TempDisk}},
+		{name: "vacuum", query: "VACUUM (VERBOSE, ANALYZE) users", want: []string{advisory.Maintenance}},
+		{name: "analyze", query: "analyze users", want: []string{advisory.Maintenance}},
+		{name: "reindex", query: "REINDEX INDEX CONCURRENTLY users_email_idx", want: []string{advisory.Maintenance}},
+		{name: "cluster", query: "CLUSTER users USING users_pkey", want: []string{advisory.Maintenance}},
+		{name: "mysql analyze table", query: "ANALYZE NO_WRITE_TO_BINLOG TABLE users", want: []string{advisory.Maintenance}},
+		{name: "optimize table", query: "OPTIMIZE TABLE users", want: []string{advisory.Maintenance}},
+		{name: "check table", query: "CHECK TABLE users, orders", want: []string{advisory.Maintenance}},
+		{name: "explain analyze", query: "EXPLAIN ANALYZE SELECT 1", want: nil},
+		{name: "analyzer column", query: "SELECT analyzed_at FROM jobs", want: nil},
+		{name: "not duplicated", query: "CREATE TEMP TABLE t (id int)", tags: []string{advisory.TempDisk}, want: []string{advisory.TempDisk}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ev := proxy.Event{Query: tt.query, Tags: slices.Clone(tt.tags)}
+			advisory.Apply(&ev)
+			if !slices.Equal(ev.Tags, tt.want) {
+				t.Errorf("Tags = %v, want %v", ev.Tags, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		res  *explain.Result
+		want bool
+	}{
+		{name: "nil", res: nil, want: false},
+		{
+			name: "in-memory sort",
+			res:  &explain.Result{Plan: "Sort  (cost=1.0..2.0)\n  Sort Method: quicksort  Memory: 25kB"},
+			want: false,
+		},
+		{
+			name: "external sort",
+			res:  &explain.Result{Plan: "Sort  (cost=1.0..2.0)\n  Sort Method: external merge  Disk: 1024kB"},
+			want: true,
+		},
+		{
+			name: "hash aggregate spill",
+			res:  &explain.Result{Plan: "HashAggregate\n  Batches: 5  Memory Usage: 4145kB  Disk Usage: 2048kB"},
+			want: true,
+		},
+		{
+			name: "single hash batch",
+			res:  &explain.Result{Plan: "Hash\n  Buckets: 1024  Batches: 1  Memory Usage: 9kB"},
+			want: false,
+		},
+		{
+			name: "hash join batches",
+			res:  &explain.Result{Plan: "Hash\n  Buckets: 65536  Batches: 4  Memory Usage: 3073kB"},
+			want: true,
+		},
+		{
+			name: "temp buffers",
+			res:  &explain.Result{Plan: "Sort\n  Buffers: shared hit=4, temp read=10 written=10"},
+			want: true,
+		},
+		{
+			name: "mysql using temporary",
+			res:  &explain.Result{Plan: "1  SIMPLE  users  ALL  Using temporary; Using filesort"},
+			want: true,
+		},
+		{
+			name: "mysql tree temporary",
+			res:  &explain.Result{Plan: "-> Table scan on <temporary>\n    -> Aggregate using temporary table"},
+			want: true,
+		},
+		{
+			name: "tidb in memory",
+			res: &explain.Result{
+				Columns: []string{"id", "memory", "disk"},
+				Rows:    [][]string{{"Sort_4", "10 KB", "N/A"}, {"TableReader_7", "1 KB", "N/A"}},
+			},
+			want: false,
+		},
+		{
+			name: "tidb spilled",
+			res: &explain.Result{
+				Columns: []string{"id", "memory", "disk"},
+				Rows:    [][]string{{"Sort_4", "32 MB", "120 MB"}},
+			},
+			want: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := slices.Contains(advisory.Plan(tt.res), advisory.TempDisk)
+			if got != tt.want {
+				t.Errorf("Plan() has %s = %v, want %v", advisory.TempDisk, got, tt.want)
+			}
+		})
+	}
+}
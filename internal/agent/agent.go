@@ -0,0 +1,936 @@
+// Package agent runs the headless half of sql-tap: the database proxies and
+// the gRPC server that TUIs attach to. It needs no terminal, so it can run
+// under a service manager or in a container.
+package agent
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/dsn"
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/advisory"
+	"github.com/mickamy/sql-tap/internal/anomaly"
+	"github.com/mickamy/sql-tap/internal/archive"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/autoexplain"
+	"github.com/mickamy/sql-tap/internal/collab"
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/databases"
+	"github.com/mickamy/sql-tap/internal/extract"
+	"github.com/mickamy/sql-tap/internal/httpapi"
+	"github.com/mickamy/sql-tap/internal/indexadvisor"
+	"github.com/mickamy/sql-tap/internal/kms"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/nplusone"
+	"github.com/mickamy/sql-tap/internal/objstore"
+	"github.com/mickamy/sql-tap/internal/otlp"
+	"github.com/mickamy/sql-tap/internal/pgstat"
+	"github.com/mickamy/sql-tap/internal/policy"
+	"github.com/mickamy/sql-tap/internal/privacy"
+	"github.com/mickamy/sql-tap/internal/routes"
+	"github.com/mickamy/sql-tap/internal/sample"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/internal/store"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/internal/tenants"
+	"github.com/mickamy/sql-tap/internal/traffic"
+	"github.com/mickamy/sql-tap/internal/txtrack"
+	"github.com/mickamy/sql-tap/proxy"
+	"github.com/mickamy/sql-tap/proxy/postgres"
+)
+
+// Main parses args as a command line for prog (e.g. "sql-tapd" or
+// "sql-tap agent") and runs the agent until SIGINT or SIGTERM. A leading
+// start, stop, status, or upgrade runs the agent in the background, or
+// stops, reports on, or upgrades it through its pidfile, instead. It exits the process on
+// invalid flags or a fatal error.
+func Main(prog, version string, args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "start":
+			startCmd(prog, version, args[1:])
+			return
+		case "stop":
+			stopCmd(prog, args[1:])
+			return
+		case "status":
+			statusCmd(prog, args[1:])
+			return
+		case "upgrade":
+			upgradeCmd(prog, args[1:])
+			return
+		}
+	}
+
+	o := parseFlags(prog, args)
+	if o.showVersion {
+		fmt.Printf("%s %s\n", prog, version)
+		return
+	}
+
+	closeLog, err := setupLogging(o.logging)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := serve(o); err != nil {
+		slog.Error("agent stopped", "err", err)
+		closeLog()
+		os.Exit(1)
+	}
+	closeLog()
+}
+
+// serve loads the config and runs the agent, holding the pidfile while it
+// runs. A process started by an upgrade takes the pidfile over once the old
+// process has handed over.
+func serve(o *options) error {
+	cfg := &config.Config{}
+	if o.configPath != "" {
+		var err error
+		if cfg, err = config.Load(o.configPath); err != nil {
+			return err
+		}
+	}
+	tk, err := inheritedTakeover()
+	if err != nil {
+		return err
+	}
+	switch {
+	case tk != nil:
+		tk.pidFile = o.pidFile
+	case o.pidFile != "":
+		if err := writePIDFile(o.pidFile, 0); err != nil {
+			return err
+		}
+	}
+	if o.pidFile != "" {
+		defer removePIDFile(o.pidFile)
+	}
+	settings, err := effectiveConfig(o, cfg)
+	if err != nil {
+		return err
+	}
+	return run(cfg, settings, o.targets, o.sampling, o.grpcAddr, o.httpAddr, o.tlsCert, o.tlsKey, o.otlp, o.drainTimeout, tk)
+}
+
+// options are the agent's parsed and validated command line.
+type options struct {
+	targets      []target
+	sampling     sample.Config
+	grpcAddr     string
+	httpAddr     string
+	tlsCert      string
+	tlsKey       string
+	otlp         otlpOptions
+	configPath   string
+	pidFile      string
+	drainTimeout time.Duration
+	logging      logOptions
+	showVersion  bool
+
+	flags    map[string]string // every flag's value, DSNs masked, for the Config RPC
+	setFlags []string          // flags given on the command line
+}
+
+// parseFlags parses and validates args as the command line for prog,
+// exiting the process if they are invalid.
+func parseFlags(prog string, args []string) *options {
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "%s — SQL proxy daemon for sql-tap\n\nUsage:\n  %s [flags]\n  %s start [flags]\n  %s stop|status|upgrade [flags]\n\nFlags:\n", prog, prog, prog, prog)
+		fs.PrintDefaults()
+		fmt.Fprintf(os.Stderr, "\nEnvironment:\n  DATABASE_URL    DSN for EXPLAIN queries (read by default via -dsn-env)\n")
+	}
+
+	driver := fs.String("driver", "", "database driver: postgres, mysql, tidb (required unless -tap is used or -upstream is a DSN)")
+	var listen listenFlags
+	fs.Var(&listen, "listen", "client listen address, host:port or unix socket path, optionally followed by ;label=<name> and ;allow=<cidr>|<cidr>... (repeatable; required unless -tap is used)")
+	upstream := fs.String("upstream", "", "upstream database address, host:port, unix socket path, or DSN (required unless -tap is used)")
+	var taps targetFlags
+	fs.Var(&taps, "tap", "tap an additional upstream: name=<name>,driver=<driver>,listen=<addr>[,listen=<addr>...],upstream=<addr|dsn>[,dsn-env=<var>][,replica-dsn-env=<var>] (repeatable)")
+	grpcAddr := fs.String("grpc", ":9091", "gRPC server address for TUI")
+	httpAddr := fs.String("http", "", "HTTP server address for /events, /stats, /metrics, and /healthz; empty disables it")
+	dialTimeout := fs.Duration("dial-timeout", proxy.DefaultDialTimeout, "how long a client connection waits for its upstream connection")
+	dsnEnv := fs.String("dsn-env", "DATABASE_URL", "environment variable holding DSN for EXPLAIN")
+	maxConns := fs.Int("max-conns", 0, "refuse client connections past this many open at once, per proxy; 0 disables")
+	idleTimeout := fs.Duration("idle-timeout", 0, "close client connections idle for this long; 0 disables")
+	queryTimeout := fs.Duration("query-timeout", 0, "cancel statements still running after this long; 0 disables (postgres only)")
+	appNameLabel := fs.String("app-name-label", "", "append a label to each client's application_name: conn-id or client-host (postgres only)")
+	pooler := fs.String("pooler", "", "clients are a connection pooler's server connections, such as PgBouncer's: session or transaction, its pooling mode (postgres only)")
+	replicaDSNEnv := fs.String("replica-dsn-env", "", "environment variable holding a read replica DSN to route read-only statements to (postgres only, experimental)")
+	tlsCert := fs.String("tls-cert", "", "TLS certificate file for client connections (postgres only)")
+	tlsKey := fs.String("tls-key", "", "TLS private key file for client connections (postgres only)")
+	otlpEndpoint := fs.String("otlp", "", "OTLP/HTTP collector URL to export traced queries to (e.g. http://localhost:4318), or env to use OTEL_EXPORTER_OTLP_ENDPOINT")
+	otlpSignal := fs.String("otlp-signal", "traces", "what -otlp exports queries as: traces (client spans) or logs (log records)")
+	otlpUntraced := fs.Bool("otlp-untraced", false, "with -otlp, also export statements without trace context, as root spans or log records")
+	sampleSpec := fs.String("sample", "", "sample events before publishing: rate=<0..1>,per-fingerprint=<n>,max-per-second=<n> (any subset)")
+	configPath := fs.String("config", "", "YAML config file (tagging rules, policy, archives, sinks, store, auth)")
+	pidFile := fs.String("pidfile", "", "write the process ID to this file while running; refuses to start if a live process holds it")
+	drainTimeout := fs.Duration("drain-timeout", 10*time.Minute, "after an upgrade, how long the old process serves its open connections before closing them")
+	logFile := fs.String("log-file", "", "write logs to this file instead of stderr, rotating it by size")
+	logLevel := fs.String("log-level", "info", "minimum log level: debug, info, warn, or error")
+	logFormat := fs.String("log-format", "text", "log format: text or json")
+	logMaxSize := fs.Int("log-max-size", 100, "rotate -log-file before it grows past this many megabytes; 0 disables rotation")
+	logMaxFiles := fs.Int("log-max-files", 5, "number of rotated log files to keep")
+	showVersion := fs.Bool("version", false, "show version and exit")
+
+	_ = fs.Parse(args)
+
+	if *showVersion {
+		return &options{showVersion: true}
+	}
+
+	targets := []target(taps)
+	switch {
+	case len(taps) > 0 && (*driver != "" || len(listen) > 0 || *upstream != ""):
+		fmt.Fprintf(os.Stderr, "-tap cannot be combined with -driver/-listen/-upstream\n")
+		os.Exit(1)
+	case len(taps) == 0:
+		t := target{driver: *driver, listen: listen, upstream: *upstream, dsnEnv: *dsnEnv, replicaDSNEnv: *replicaDSNEnv}
+		if err := t.resolveDSN(); err != nil {
+			fmt.Fprintf(os.Stderr, "-%v\n", err)
+			os.Exit(1)
+		}
+		if t.driver == "" || len(t.listen) == 0 || t.upstream == "" {
+			fs.Usage()
+			os.Exit(1)
+		}
+		targets = []target{t}
+	}
+
+	if *dialTimeout <= 0 {
+		fmt.Fprintf(os.Stderr, "-dial-timeout must be positive\n")
+		os.Exit(1)
+	}
+	if *maxConns < 0 {
+		fmt.Fprintf(os.Stderr, "-max-conns must not be negative\n")
+		os.Exit(1)
+	}
+	if *idleTimeout < 0 {
+		fmt.Fprintf(os.Stderr, "-idle-timeout must not be negative\n")
+		os.Exit(1)
+	}
+	if *queryTimeout < 0 {
+		fmt.Fprintf(os.Stderr, "-query-timeout must not be negative\n")
+		os.Exit(1)
+	}
+	if *drainTimeout < 0 {
+		fmt.Fprintf(os.Stderr, "-drain-timeout must not be negative\n")
+		os.Exit(1)
+	}
+	switch postgres.AppNameLabel(*appNameLabel) {
+	case "", postgres.AppNameConnID, postgres.AppNameClientHost:
+	default:
+		fmt.Fprintf(os.Stderr, "-app-name-label must be conn-id or client-host\n")
+		os.Exit(1)
+	}
+	switch otlp.Signal(*otlpSignal) {
+	case otlp.SignalTraces, otlp.SignalLogs:
+	default:
+		fmt.Fprintf(os.Stderr, "-otlp-signal must be traces or logs\n")
+		os.Exit(1)
+	}
+	switch postgres.PoolMode(*pooler) {
+	case "", postgres.PoolSession, postgres.PoolTransaction:
+	default:
+		fmt.Fprintf(os.Stderr, "-pooler must be session or transaction\n")
+		os.Exit(1)
+	}
+	for i := range targets {
+		targets[i].dialTimeout = *dialTimeout
+		targets[i].maxConns = *maxConns
+		targets[i].idleTimeout = *idleTimeout
+		targets[i].queryTimeout = *queryTimeout
+		targets[i].appNameLabel = postgres.AppNameLabel(*appNameLabel)
+		targets[i].pooler = postgres.PoolMode(*pooler)
+	}
+
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fmt.Fprintf(os.Stderr, "-tls-cert and -tls-key must be set together\n")
+		os.Exit(1)
+	}
+
+	sampling, err := sample.Parse(*sampleSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	logging, err := parseLogOptions(*logFile, *logLevel, *logFormat, *logMaxSize, *logMaxFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+
+	flags := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	flags["upstream"] = dsn.Mask(flags["upstream"])
+	flags["otlp"] = dsn.Mask(flags["otlp"])
+	var setFlags []string
+	fs.Visit(func(f *flag.Flag) {
+		setFlags = append(setFlags, f.Name)
+	})
+
+	return &options{
+		targets:      targets,
+		sampling:     sampling,
+		grpcAddr:     *grpcAddr,
+		httpAddr:     *httpAddr,
+		tlsCert:      *tlsCert,
+		tlsKey:       *tlsKey,
+		otlp:         otlpOptions{endpoint: *otlpEndpoint, signal: otlp.Signal(*otlpSignal), untraced: *otlpUntraced},
+		configPath:   *configPath,
+		pidFile:      *pidFile,
+		drainTimeout: *drainTimeout,
+		logging:      logging,
+		flags:        flags,
+		setFlags:     setFlags,
+	}
+}
+
+// txHistory is how many finished transactions the daemon retains for the Transactions RPC.
+const txHistory = 1000
+
+// annotationHistory is how many shared event annotations the daemon retains.
+const annotationHistory = 1000
+
+// certExpiryWarning is how far ahead of expiry the TLS certificate is
+// reported as expiring soon, in the log and, via the Info RPC, by the TUI.
+const certExpiryWarning = 30 * 24 * time.Hour
+
+func run(cfg *config.Config, settings []byte, targets []target, sampling sample.Config, grpcAddr, httpAddr, tlsCert, tlsKey string, otlpOpts otlpOptions, drainTimeout time.Duration, tk *takeover) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Broker
+	b := broker.New[proxy.Event](256)
+
+	// Captured values are generalized or redacted before anything
+	// publishes them (optional).
+	argMode, err := privacy.ParseMode(cfg.Privacy.Args)
+	if err != nil {
+		return err //nolint:wrapcheck // validated with the config
+	}
+	if argMode != privacy.Raw {
+		slog.Info("captured values are not published as captured", "args", argMode)
+	}
+
+	// Upgrades. Once a new process took over, events go to it instead.
+	up := &upgrade{drainTimeout: drainTimeout}
+	publish := func(ev proxy.Event) {
+		if f := up.forwarding(); f != nil {
+			f.send(ev)
+			return
+		}
+		argMode.Apply(&ev)
+		b.Publish(ev)
+	}
+
+	// Detailed capture toggles, shared by the proxies and the gRPC server.
+	verbosity := proxy.NewVerbosity()
+	stages := metrics.NewStages()
+	txTracker := txtrack.New(txHistory)
+	var routeOpts []routes.Option
+	if cfg.Routes.QueryBudget > 0 {
+		routeOpts = append(routeOpts, routes.WithQueryBudget(cfg.Routes.QueryBudget))
+	}
+	routeStats := routes.New(routeOpts...)
+	dbStats := databases.New()
+	srvOpts := []server.Option{
+		server.WithVerbosity(verbosity),
+		server.WithStages(stages),
+		server.WithTxTracker(txTracker),
+		server.WithRoutes(routeStats),
+		server.WithDatabases(dbStats),
+		server.WithAuditLog(log.Default()),
+		server.WithCollab(collab.New(annotationHistory)),
+		server.WithEffectiveConfig(settings),
+	}
+
+	var sampler *sample.Sampler
+	if sampling.Enabled() {
+		sampler = sample.New(sampling)
+		srvOpts = append(srvOpts, server.WithSampler(sampler))
+		slog.Info("sampling events", "config", sampling.String())
+	}
+
+	// Tagging rules (optional)
+	tg, err := tagger.New(cfg.Tags)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Tags) > 0 {
+		slog.Info("tagging enabled", "rules", len(cfg.Tags))
+	}
+	tagDefs := slices.Concat(tg.Defs(), advisory.Defs(), indexadvisor.Defs())
+
+	// Statement policy (optional)
+	var pol proxy.Policy
+	if cfg.Policy.Enabled() {
+		p, err := policy.New(cfg.Policy)
+		if err != nil {
+			return err
+		}
+		pol = p
+		slog.Info("statement policy enabled", "rules", p.Rules(), "dry_run", p.DryRun())
+	}
+
+	// Field extraction rules (optional)
+	fields, err := extract.New(cfg.Fields)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Fields) > 0 {
+		slog.Info("field extraction enabled", "rules", len(cfg.Fields))
+	}
+
+	// Per-tenant statistics and quotas (optional)
+	var tenantStats *tenants.Tracker
+	if field := cfg.Tenants.Field; field != "" {
+		var opts []tenants.Option
+		if cfg.Tenants.MaxShare > 0 {
+			opts = append(opts, tenants.WithMaxShare(cfg.Tenants.MaxShare))
+		}
+		if cfg.Tenants.Window > 0 {
+			opts = append(opts, tenants.WithQuotaWindow(cfg.Tenants.Window))
+		}
+		if cfg.Tenants.MinCalls > 0 {
+			opts = append(opts, tenants.WithMinCalls(cfg.Tenants.MinCalls))
+		}
+		tenantStats = tenants.New(field, opts...)
+		srvOpts = append(srvOpts, server.WithTenants(tenantStats))
+		tagDefs = append(tagDefs, tenants.Defs()...)
+		slog.Info("tenant statistics enabled", "field", field, "max_share", tenantStats.MaxShare())
+	}
+
+	// Latency anomaly detection (on unless disabled)
+	var detector *anomaly.Detector
+	if !cfg.Anomaly.Disabled {
+		var opts []anomaly.Option
+		if cfg.Anomaly.Threshold > 0 {
+			opts = append(opts, anomaly.WithThreshold(cfg.Anomaly.Threshold))
+		}
+		if cfg.Anomaly.MinSamples > 0 {
+			opts = append(opts, anomaly.WithMinSamples(cfg.Anomaly.MinSamples))
+		}
+		if cfg.Anomaly.Alpha > 0 {
+			opts = append(opts, anomaly.WithAlpha(cfg.Anomaly.Alpha))
+		}
+		detector = anomaly.New(opts...)
+		tagDefs = append(tagDefs, anomaly.Defs()...)
+	}
+	// Traffic change detection (on unless disabled)
+	var rates *traffic.Detector
+	if !cfg.Traffic.Disabled {
+		var opts []traffic.Option
+		if cfg.Traffic.Window > 0 {
+			opts = append(opts, traffic.WithWindow(cfg.Traffic.Window))
+		}
+		if cfg.Traffic.Factor > 0 {
+			opts = append(opts, traffic.WithFactor(cfg.Traffic.Factor))
+		}
+		if cfg.Traffic.Warmup > 0 {
+			opts = append(opts, traffic.WithWarmup(cfg.Traffic.Warmup))
+		}
+		if cfg.Traffic.MinCalls > 0 {
+			opts = append(opts, traffic.WithMinCalls(cfg.Traffic.MinCalls))
+		}
+		rates = traffic.New(opts...)
+		tagDefs = append(tagDefs, traffic.Defs()...)
+	}
+	// N+1 query detection (on unless disabled)
+	var bursts *nplusone.Detector
+	if !cfg.NPlusOne.Disabled {
+		var opts []nplusone.Option
+		if cfg.NPlusOne.Threshold > 0 {
+			opts = append(opts, nplusone.WithThreshold(cfg.NPlusOne.Threshold))
+		}
+		if cfg.NPlusOne.Window > 0 {
+			opts = append(opts, nplusone.WithWindow(cfg.NPlusOne.Window))
+		}
+		bursts = nplusone.New(opts...)
+		tagDefs = append(tagDefs, nplusone.Defs()...)
+	}
+	if cfg.AutoExplain.Threshold > 0 {
+		tagDefs = append(tagDefs, autoexplain.Defs()...)
+	}
+	srvOpts = append(srvOpts, server.WithTagDefs(tagDefs))
+
+	// Token auth with roles (optional)
+	var authorizer *auth.Authorizer
+	if len(cfg.Auth.Tokens) > 0 {
+		tokens := make(map[string]auth.Role, len(cfg.Auth.Tokens))
+		for _, t := range cfg.Auth.Tokens {
+			tok := os.Getenv(t.TokenEnv)
+			if tok == "" {
+				return fmt.Errorf("auth: %s is not set", t.TokenEnv)
+			}
+			role, err := auth.ParseRole(t.Role)
+			if err != nil {
+				return err
+			}
+			tokens[tok] = role
+		}
+		authorizer = auth.New(tokens)
+		srvOpts = append(srvOpts, server.WithAuthorizer(authorizer))
+		slog.Info("API auth enabled", "tokens", len(tokens))
+	}
+
+	// Daily capture archives (optional), opened with the store below
+	var arcOpts []archive.Option
+	if cfg.Archive.Dir != "" {
+		if cfg.Archive.Compress {
+			arcOpts = append(arcOpts, archive.WithCompression())
+		}
+		if cfg.Archive.Retention > 0 {
+			arcOpts = append(arcOpts, archive.WithRetention(cfg.Archive.Retention))
+		}
+		if cfg.Archive.Chain {
+			arcOpts = append(arcOpts, archive.WithChain())
+		}
+		if env := cfg.Archive.KeyEnv; env != "" {
+			key, err := keyFromEnv(ctx, env, cfg.Archive.KeyKMS)
+			if err != nil {
+				return fmt.Errorf("archive: %w", err)
+			}
+			arcOpts = append(arcOpts, archive.WithEncryption(key))
+		}
+		if cfg.Archive.Upload != "" {
+			uploader, err := objstore.Open(cfg.Archive.Upload)
+			if err != nil {
+				return err
+			}
+			arcOpts = append(arcOpts, archive.WithUploader(uploader))
+			slog.Info("uploading finished archives", "to", uploader.String())
+		}
+	}
+
+	// Span or log export for queries carrying trace context (optional)
+	if otlpOpts.endpoint != "" {
+		exp, err := otlpOpts.newExporter(os.Getenv)
+		if err != nil {
+			return err
+		}
+		exported := runOTLP(ctx, b, exp, stages)
+		defer func() {
+			stop()
+			<-exported
+		}()
+		slog.Info("exporting queries over OTLP", "endpoint", dsn.Mask(exp.URL()), "signal", otlpOpts.signal,
+			"untraced", otlpOpts.untraced)
+	}
+
+	// Kafka and NATS event sinks (optional)
+	for _, c := range cfg.Sinks {
+		s, err := openSink(c)
+		if err != nil {
+			return err
+		}
+		published := runSink(ctx, b, s, c, stages)
+		defer func() {
+			stop()
+			<-published
+		}()
+		slog.Info("publishing events", "to", s.String())
+	}
+
+	// EXPLAIN clients (optional). A single unnamed target becomes the default;
+	// named targets are selected by the upstream name on each request.
+	var explainClient *explain.Client
+	runners := make(map[string]autoexplain.Runner)
+	pgSources := make(map[string]pgstat.Source)
+	for _, t := range targets {
+		c, err := t.openExplain()
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.label(), err)
+		}
+		if c == nil {
+			if t.dsnEnv != "" {
+				slog.Warn("EXPLAIN disabled", "target", t.label(), "reason", t.dsnEnv+" not set")
+			}
+			continue
+		}
+		defer func() { _ = c.Close() }()
+		runners[t.name] = c
+		if t.driver == "postgres" {
+			pgSources[t.name] = c
+		}
+		if t.name == "" {
+			explainClient = c
+		} else {
+			srvOpts = append(srvOpts, server.WithUpstreamExplainClient(t.name, c))
+		}
+		slog.Info("EXPLAIN enabled", "target", t.label())
+	}
+
+	// EXPLAIN of slow statements as they are captured (optional)
+	var explainer *autoexplain.Explainer
+	if ae := cfg.AutoExplain; ae.Threshold > 0 {
+		if len(runners) == 0 {
+			slog.Warn("auto-explain disabled", "reason", "no target has an EXPLAIN DSN")
+		} else {
+			var opts []autoexplain.Option
+			if ae.Analyze {
+				opts = append(opts, autoexplain.WithAnalyze())
+			}
+			if ae.CacheTTL > 0 {
+				opts = append(opts, autoexplain.WithCacheTTL(ae.CacheTTL))
+			}
+			if ae.Timeout > 0 {
+				opts = append(opts, autoexplain.WithTimeout(ae.Timeout))
+			}
+			explainer = autoexplain.New(ae.Threshold, runners, opts...)
+			go explainer.Run(ctx, publish)
+			slog.Info("auto-explain enabled", "threshold", ae.Threshold, "analyze", ae.Analyze)
+		}
+	}
+
+	// pg_stat_statements totals for the stats view (optional)
+	if ps := cfg.PgStat; ps.Interval > 0 {
+		if len(pgSources) == 0 {
+			slog.Warn("pg_stat_statements polling disabled", "reason", "no postgres target has an EXPLAIN DSN")
+		} else {
+			opts := []pgstat.Option{pgstat.WithInterval(ps.Interval)}
+			if ps.Limit > 0 {
+				opts = append(opts, pgstat.WithLimit(ps.Limit))
+			}
+			poller := pgstat.New(pgSources, opts...)
+			go poller.Run(ctx)
+			srvOpts = append(srvOpts, server.WithStatements(poller))
+			slog.Info("polling pg_stat_statements", "interval", ps.Interval)
+		}
+	}
+
+	// TLS termination (optional)
+	var tlsConfig *tls.Config
+	if tlsCert != "" {
+		if !slices.ContainsFunc(targets, func(t target) bool { return t.driver == "postgres" }) {
+			return errors.New("TLS termination is only supported for postgres")
+		}
+		cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+		if err != nil {
+			return fmt.Errorf("load tls certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+		notAfter := cert.Leaf.NotAfter
+		srvOpts = append(srvOpts, server.WithTLSCertNotAfter(notAfter, certExpiryWarning))
+		if remaining := time.Until(notAfter); remaining < certExpiryWarning {
+			slog.Warn("TLS certificate expires soon", "not_after", notAfter.Format(time.RFC3339), "in", remaining.Round(time.Hour).String())
+		}
+		slog.Info("TLS termination enabled", "not_after", notAfter.Format(time.RFC3339))
+	}
+
+	// Proxies. Multiple targets are merged through a Manager so every event
+	// carries the name of its upstream. Proxies that can cancel their own
+	// backends' queries serve the Kill RPC's cancels.
+	var p proxy.Proxy
+	if len(targets) == 1 && targets[0].name == "" {
+		if p, err = targets[0].newProxy(verbosity, tlsConfig, pol); err != nil {
+			return err
+		}
+		if c, ok := p.(proxy.Canceler); ok {
+			srvOpts = append(srvOpts, server.WithCanceler("", c))
+		}
+	} else {
+		m := proxy.NewManager()
+		for _, t := range targets {
+			tp, err := t.newProxy(verbosity, tlsConfig, pol)
+			if err != nil {
+				return fmt.Errorf("%s: %w", t.label(), err)
+			}
+			m.Add(t.name, tp)
+			if c, ok := tp.(proxy.Canceler); ok {
+				srvOpts = append(srvOpts, server.WithCanceler(t.name, c))
+			}
+		}
+		p = m
+	}
+	// Ready-to-paste DSNs pointing applications at the proxies.
+	endpoints := make([]server.Endpoint, len(targets))
+	for i, t := range targets {
+		endpoints[i] = server.Endpoint{Upstream: t.name, Driver: t.driver, Listen: t.listen.addr(), DSN: t.appDSN(tlsConfig != nil)}
+	}
+	srvOpts = append(srvOpts, server.WithEndpoints(endpoints))
+
+	// Takeover from the process this one replaces in an upgrade: everything
+	// else is set up, so it only hands over once this process can serve.
+	if tk != nil {
+		if err := tk.handshake(); err != nil {
+			return err
+		}
+		slog.Info("upgrade: took over", "from_pid", os.Getppid())
+	}
+
+	// The store and archive are closed early when a new process takes
+	// over, so only one process writes them at a time.
+	sinkCtx, stopSinks := context.WithCancel(ctx)
+	defer stopSinks()
+	var sinks []<-chan struct{}
+	up.stopSinks = func() {
+		stopSinks()
+		for _, done := range sinks {
+			<-done
+		}
+	}
+
+	// Daily capture archives
+	if dir := cfg.Archive.Dir; dir != "" {
+		arc, err := archive.New(dir, arcOpts...)
+		if err != nil {
+			return err
+		}
+		archived := runArchive(sinkCtx, b, arc, stages)
+		sinks = append(sinks, archived)
+		defer func() {
+			stop()
+			<-archived
+		}()
+		slog.Info("archiving captures", "dir", dir)
+	}
+
+	// Queryable event store (optional)
+	if path := cfg.Store.Path; path != "" {
+		var stOpts []store.Option
+		if env := cfg.Store.KeyEnv; env != "" {
+			key, err := keyFromEnv(ctx, env, cfg.Store.KeyKMS)
+			if err != nil {
+				return fmt.Errorf("store: %w", err)
+			}
+			stOpts = append(stOpts, store.WithKey(key))
+		}
+		st, err := store.Open(path, stOpts...)
+		if err != nil {
+			return err
+		}
+		stored := runStore(sinkCtx, b, st, stages)
+		sinks = append(sinks, stored)
+		defer func() {
+			stop()
+			<-stored
+		}()
+		srvOpts = append(srvOpts, server.WithStore(st))
+		slog.Info("storing events", "path", path, "stored", st.Len())
+	}
+
+	// gRPC server
+	grpcLis, err := proxy.Listen(ctx, grpcAddr)
+	if err != nil {
+		return fmt.Errorf("listen grpc %s: %w", grpcAddr, err)
+	}
+	srv := server.New(b, explainClient, srvOpts...)
+	go func() {
+		slog.Info("gRPC server listening", "addr", grpcAddr)
+		if err := srv.Serve(grpcLis); err != nil {
+			slog.Error("grpc serve", "err", err)
+		}
+	}()
+
+	up.stopServers = srv.Stop
+
+	// HTTP server (optional)
+	if httpAddr != "" {
+		httpLis, err := proxy.Listen(ctx, httpAddr)
+		if err != nil {
+			return fmt.Errorf("listen http %s: %w", httpAddr, err)
+		}
+		httpOpts := []httpapi.Option{httpapi.WithAuditLog(log.Default()), httpapi.WithService(srv.Service())}
+		if authorizer != nil {
+			httpOpts = append(httpOpts, httpapi.WithAuthorizer(authorizer))
+		}
+		hs := httpapi.New(b, httpOpts...)
+		defer func() { _ = hs.Close() }()
+		up.stopServers = func() {
+			srv.Stop()
+			_ = hs.Close()
+		}
+		go func() {
+			slog.Info("HTTP server listening", "addr", httpAddr)
+			if err := hs.Serve(httpLis); err != nil {
+				slog.Error("http serve", "err", err)
+			}
+		}()
+	}
+
+	// process runs one event through the pipeline. A panic in a stage drops
+	// the event and publishes a diagnostic in its place, so one bad event
+	// does not stop the pipeline. Once a new process took over, events go
+	// to its pipeline instead.
+	// A panic may come mid-extraction, so a diagnostic's fields are replaced
+	// wherever an argument could have filled them.
+	argFields := fields.ArgFields()
+	pseudonyms := privacy.NewPseudonyms()
+	redact := func(ev *proxy.Event) {
+		argMode.Apply(ev)
+		argMode.ApplyFields(ev, argFields)
+	}
+	process := func(ev proxy.Event) {
+		if f := up.forwarding(); f != nil {
+			f.send(ev)
+			return
+		}
+		guardPipeline(&ev, redact, b.Publish, func() {
+			received := time.Now()
+			// Fields are extracted first so tenants are counted on every
+			// event; those taken from an argument are replaced with it right
+			// away, and tenants are counted on their pseudonyms instead. Rates,
+			// route, database, and tenant statistics, and N+1 bursts are
+			// counted, and slow statements queued for EXPLAIN, before
+			// sampling, and advisories are never sampled out. Only rates, N+1
+			// bursts, and EXPLAIN see the captured arguments when privacy mode
+			// is on.
+			tenantEv := extractFields(&ev, fields, argMode, pseudonyms)
+			normalized := time.Now()
+			stages.Observe(metrics.StageNormalize, normalized.Sub(received))
+			if rates != nil {
+				for _, adv := range rates.Observe(ev, received) {
+					b.Publish(adv)
+				}
+			}
+			routeStats.Observe(ev, received)
+			dbStats.Observe(ev, received)
+			if tenantStats != nil {
+				for _, adv := range tenantStats.Observe(tenantEv, received) {
+					b.Publish(adv)
+				}
+			}
+			if bursts != nil {
+				bursts.Observe(&ev, received)
+			}
+			if explainer != nil {
+				explainer.Observe(ev, received)
+			}
+			observed := time.Now()
+			stages.Observe(metrics.StageObserve, observed.Sub(normalized))
+			argMode.Apply(&ev)
+			redacted := time.Now()
+			stages.Observe(metrics.StageRedact, redacted.Sub(observed))
+			if sampler != nil && !sampler.Keep(ev, received) {
+				return
+			}
+			if !ev.StartTime.IsZero() {
+				stages.Observe(metrics.StageCapture, received.Sub(ev.StartTime.Add(ev.Duration)))
+			}
+			tg.Apply(&ev)
+			advisory.Apply(&ev)
+			if detector != nil {
+				detector.Observe(&ev)
+			}
+			tagged := time.Now()
+			stages.Observe(metrics.StageTag, tagged.Sub(redacted))
+			txTracker.Observe(ev)
+			b.Publish(ev)
+			stages.Observe(metrics.StagePublish, time.Since(tagged))
+		})
+	}
+	// Events forwarded by the process this one took over from run through
+	// the pipeline with the proxies' own.
+	var handedOver <-chan proxy.Event
+	if tk != nil {
+		ch := make(chan proxy.Event, 256)
+		go tk.receive(ch)
+		handedOver = ch
+	}
+	go func() {
+		events := p.Events()
+		for {
+			select {
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				process(ev)
+			case ev, ok := <-handedOver:
+				if !ok {
+					handedOver = nil
+					continue
+				}
+				process(ev)
+			}
+		}
+	}()
+	go up.watch(ctx, stop)
+
+	for i, t := range targets {
+		slog.Info("proxying", "listen", t.listen.String(), "upstream", t.upstream, "target", t.label())
+		if dsn := endpoints[i].DSN; dsn != "" {
+			slog.Info("point your app at the proxy", "dsn", dsn, "target", t.label())
+		}
+	}
+	// The proxies report a shutdown signal as context.Canceled.
+	if err := p.ListenAndServe(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("proxy: %w", err)
+	}
+
+	srv.GracefulStop()
+	up.finish()
+	return nil
+}
+
+// extractFields fills ev's fields and replaces those taken from an argument
+// as mode says. It returns ev as tenant statistics see it: with those fields
+// as pseudonyms instead, so that tenants redacted alike are still counted
+// apart, and no tenant's value is published in statistics or advisories.
+func extractFields(ev *proxy.Event, x *extract.Extractor, mode privacy.Mode, pseudonyms *privacy.Pseudonyms) proxy.Event {
+	fromArgs := x.Apply(ev)
+	stats := *ev
+	if mode != privacy.Raw {
+		pseudonyms.ApplyFields(&stats, fromArgs)
+	}
+	mode.ApplyFields(ev, fromArgs)
+	return stats
+}
+
+// guardPipeline runs fn, which takes *ev through the pipeline. A panic in it
+// drops the event and publishes a diagnostic built from it in its place.
+// The diagnostic goes through redact first, as the panic may have come
+// before the privacy stage.
+func guardPipeline(ev *proxy.Event, redact func(*proxy.Event), publish func(proxy.Event), fn func()) {
+	defer func() {
+		if v := recover(); v != nil {
+			diag, _ := proxy.Recovered("pipeline", v, *ev)
+			redact(&diag)
+			publish(diag)
+		}
+	}()
+	fn()
+}
+
+// kmsTimeout bounds unwrapping a key at startup.
+const kmsTimeout = 30 * time.Second
+
+// keyFromEnv returns the AES-256 key held by the environment variable env,
+// unwrapping it with the KMS key kmsURI, if set.
+func keyFromEnv(ctx context.Context, env, kmsURI string) ([]byte, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil, fmt.Errorf("%s is not set", env)
+	}
+	ctx, cancel := context.WithTimeout(ctx, kmsTimeout)
+	defer cancel()
+	key, err := kms.Key(ctx, v, kmsURI)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", env, err)
+	}
+	return key, nil
+}
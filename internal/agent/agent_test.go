@@ -0,0 +1,104 @@
+package agent_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/agent"
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/extract"
+	"github.com/mickamy/sql-tap/internal/privacy"
+	"github.com/mickamy/sql-tap/internal/tenants"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestGuardPipeline_RedactsPanicDiagnostic(t *testing.T) {
+	t.Parallel()
+
+	ev := proxy.Event{
+		Op:         proxy.OpQuery,
+		Query:      "SELECT * FROM users WHERE email = $1",
+		Args:       []string{"alice@example.com"},
+		RowSamples: [][]string{{"alice@example.com", "hunter2"}},
+	}
+	redact := func(ev *proxy.Event) { privacy.Redact.Apply(ev) }
+	var published []proxy.Event
+	publish := func(ev proxy.Event) { published = append(published, ev) }
+
+	// The stage panics before the privacy stage would have run.
+	agent.GuardPipeline(&ev, redact, publish, func() { panic("boom") })
+
+	if len(published) != 1 {
+		t.Fatalf("published %d events, want the diagnostic alone", len(published))
+	}
+	diag := published[0]
+	if diag.Op != proxy.OpAdvisory || diag.Panic == nil || diag.Panic.Where != "pipeline" {
+		t.Errorf("published %+v, want a pipeline panic diagnostic", diag)
+	}
+	if !slices.Equal(diag.Args, []string{privacy.Redacted}) {
+		t.Errorf("diagnostic Args = %q, want them redacted", diag.Args)
+	}
+	for _, row := range diag.RowSamples {
+		for _, v := range row {
+			if v != privacy.Redacted {
+				t.Errorf("diagnostic RowSamples = %q, want them redacted", diag.RowSamples)
+			}
+		}
+	}
+}
+
+func TestGuardPipeline_NoPanic(t *testing.T) {
+	t.Parallel()
+
+	ev := proxy.Event{Op: proxy.OpQuery, Args: []string{"1"}}
+	var published []proxy.Event
+	ran := false
+	agent.GuardPipeline(&ev, func(*proxy.Event) { t.Error("redact called without a panic") },
+		func(ev proxy.Event) { published = append(published, ev) }, func() { ran = true })
+	if !ran || len(published) != 0 {
+		t.Errorf("ran = %v, published = %d, want the stage run and nothing published", ran, len(published))
+	}
+}
+
+// TestExtractFields_RedactedTenants counts tenants taken from an argument
+// under redaction: two tenants with even traffic stay two, and neither is
+// over its share.
+func TestExtractFields_RedactedTenants(t *testing.T) {
+	t.Parallel()
+
+	x, err := extract.New([]config.FieldRule{{Name: "tenant_id", Arg: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pseudonyms := privacy.NewPseudonyms()
+	tr := tenants.New("tenant_id", tenants.WithMinCalls(10))
+	start := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+	var advisories []proxy.Event
+	for i := range 100 {
+		ev := proxy.Event{
+			Op:    proxy.OpQuery,
+			Query: "SELECT * FROM orders WHERE tenant_id = $1",
+			Args:  []string{[]string{"acme", "globex"}[i%2]},
+		}
+		stats := agent.ExtractFields(&ev, x, privacy.Redact, pseudonyms)
+		if got := ev.Fields["tenant_id"]; got != privacy.Redacted {
+			t.Fatalf("published tenant_id = %q, want it redacted", got)
+		}
+		advisories = append(advisories, tr.Observe(stats, start.Add(time.Duration(i)*time.Millisecond))...)
+	}
+	got := tr.Tenants(start.Add(time.Second))
+	if len(got) != 2 {
+		t.Fatalf("got %d tenants, want 2: %+v", len(got), got)
+	}
+	for _, tn := range got {
+		if tn.Value == "acme" || tn.Value == "globex" || tn.Value == privacy.Redacted {
+			t.Errorf("tenant %q, want a pseudonym", tn.Value)
+		}
+	}
+	// Closes the quota window.
+	advisories = append(advisories, tr.Observe(proxy.Event{}, start.Add(tenants.DefaultQuotaWindow))...)
+	if len(advisories) != 0 {
+		t.Errorf("advisories = %+v, want none", advisories)
+	}
+}
@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// appDSN returns a DSN for applications to connect through the target's
+// proxy: the user, database, and options of the upstream DSN (from
+// -upstream or the EXPLAIN env var) pointed at the listen address. The
+// password is left out, so the DSN is safe to log and serve over the Info
+// RPC. tls reports whether the proxy terminates client TLS. It returns ""
+// for a listen address clients cannot be pointed at.
+func (t target) appDSN(tls bool) string {
+	raw := t.dsn
+	if raw == "" && t.dsnEnv != "" {
+		raw = os.Getenv(t.dsnEnv)
+	}
+	switch t.driver {
+	case "postgres":
+		return postgresAppDSN(raw, t.listen.addr(), tls)
+	case "mysql", "tidb":
+		return mysqlAppDSN(raw, t.listen.addr())
+	}
+	return ""
+}
+
+func postgresAppDSN(raw, listen string, tls bool) string {
+	u := &url.URL{Scheme: "postgres", Path: "/"}
+	if raw != "" {
+		if cfg, err := pgconn.ParseConfig(raw); err == nil {
+			if cfg.User != "" {
+				u.User = url.User(cfg.User)
+			}
+			u.Path = "/" + cfg.Database
+		}
+	}
+	q := url.Values{}
+	network, addr := proxy.Network(listen)
+	if network == "unix" {
+		// libpq and pgx take a socket directory and find .s.PGSQL.<port> in it.
+		dir, file := filepath.Split(addr)
+		port, ok := strings.CutPrefix(file, ".s.PGSQL.")
+		if !ok {
+			return ""
+		}
+		q.Set("host", filepath.Clean(dir))
+		q.Set("port", port)
+	} else {
+		u.Host = dialAddr(addr)
+	}
+	// Without TLS termination the proxy declines SSL, which sslmode=require
+	// in a copied upstream DSN would refuse.
+	if tls {
+		q.Set("sslmode", "require")
+	} else {
+		q.Set("sslmode", "disable")
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func mysqlAppDSN(raw, listen string) string {
+	cfg := mysql.NewConfig()
+	if raw != "" {
+		if parsed, err := mysql.ParseDSN(strings.TrimPrefix(raw, "mysql://")); err == nil {
+			cfg = parsed
+		}
+	}
+	cfg.Passwd = ""
+	cfg.TLSConfig = "" // the MySQL proxy does not terminate TLS
+	network, addr := proxy.Network(listen)
+	cfg.Net = network
+	if network == "tcp" {
+		addr = dialAddr(addr)
+	}
+	cfg.Addr = addr
+	return cfg.FormatDSN()
+}
+
+// dialAddr turns a TCP listen address into one a client on the same host
+// can dial: an empty or wildcard host becomes localhost.
+func dialAddr(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if host == "" || net.ParseIP(host).IsUnspecified() {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
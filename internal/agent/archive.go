@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/internal/archive"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+const (
+	// archiveFlushInterval bounds how long archived events sit in memory.
+	archiveFlushInterval = time.Second
+	// archiveMaintainInterval is how often finished days are compressed and
+	// uploaded, and expired days deleted.
+	archiveMaintainInterval = time.Hour
+)
+
+// runArchive writes every published event to a until ctx is done, timing
+// each write as the archive's sink stage, and runs maintenance at startup
+// and then periodically. The returned channel is closed once the archive has
+// been flushed and closed.
+func runArchive(ctx context.Context, b *broker.Broker[proxy.Event], a *archive.Archiver, stages *metrics.Stages) <-chan struct{} {
+	events, unsubscribe := b.Subscribe(broker.WithName("archive"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		defer func() {
+			if err := a.Close(); err != nil {
+				slog.Error("archive", "err", err)
+			}
+		}()
+
+		maintain := func() {
+			if err := a.Maintain(ctx, time.Now()); err != nil {
+				slog.Error("archive maintenance", "err", err)
+			}
+		}
+		maintain()
+
+		flush := time.NewTicker(archiveFlushInterval)
+		defer flush.Stop()
+		tick := time.NewTicker(archiveMaintainInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-events:
+				start := time.Now()
+				if err := a.Write(server.EventToProto(ev)); err != nil {
+					slog.Error("archive", "err", err)
+				}
+				stages.Observe(metrics.Sink("archive"), time.Since(start))
+			case <-flush.C:
+				if err := a.Flush(); err != nil {
+					slog.Error("archive", "err", err)
+				}
+			case <-tick.C:
+				maintain()
+			}
+		}
+	}()
+	return done
+}
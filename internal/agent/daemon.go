@@ -0,0 +1,246 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// startTimeout bounds how long start waits for the background agent to
+	// write its pidfile.
+	startTimeout = 10 * time.Second
+	// startGrace is how long start keeps watching after the pidfile appears,
+	// so a listen error right after it is still reported.
+	startGrace = 500 * time.Millisecond
+	// statusNotRunning is the exit status of status when no agent is
+	// running, as for an init script.
+	statusNotRunning = 3
+)
+
+// defaultPath returns the default location of the named daemon file, next
+// to the TUI's state file, or "" if there is no cache directory.
+func defaultPath(name string) string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sql-tap", name)
+}
+
+// startCmd runs the agent in the background with the command line in args,
+// detached from the terminal and logging to its -log-file, and returns once
+// it is up.
+func startCmd(prog, version string, args []string) {
+	o := parseFlags(prog+" start", args)
+	if o.showVersion {
+		fmt.Printf("%s %s\n", prog, version)
+		return
+	}
+	if err := start(prog, o, args); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+func start(prog string, o *options, args []string) error {
+	pidFile, logFile := o.pidFile, o.logging.file
+	if pidFile == "" {
+		pidFile = defaultPath("agent.pid")
+	}
+	if logFile == "" {
+		logFile = defaultPath("agent.log")
+	}
+	if pidFile == "" || logFile == "" {
+		return errors.New("no cache directory for the pidfile and log; set -pidfile and -log-file")
+	}
+	if pid, err := readPID(pidFile); err == nil && alive(pid) {
+		return fmt.Errorf("%s is already running (pid %d)", prog, pid)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	// Re-run this command line in the foreground, with the pidfile and log
+	// file made explicit; later flags override earlier ones.
+	argv := slices.Concat(strings.Fields(prog)[1:], args, []string{"-pidfile", pidFile, "-log-file", logFile})
+	if err := os.MkdirAll(filepath.Dir(logFile), 0o750); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	// Output that bypasses the logger, such as a panic, goes to the log too.
+	out, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	defer func() { _ = out.Close() }()
+
+	cmd := exec.CommandContext(context.Background(), exe, argv...) //nolint:gosec // re-runs this binary with the caller's own flags
+	cmd.Stdout, cmd.Stderr = out, out
+	if err := detach(cmd); err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	pid := cmd.Process.Pid
+	deadline := time.After(startTimeout)
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("%s exited during startup (%v); see %s", prog, err, logFile)
+		case <-deadline:
+			return fmt.Errorf("%s (pid %d) did not write %s within %s; see %s", prog, pid, pidFile, startTimeout, logFile)
+		case <-tick.C:
+		}
+		if got, err := readPID(pidFile); err != nil || got != pid {
+			continue
+		}
+		select {
+		case err := <-exited:
+			return fmt.Errorf("%s exited during startup (%v); see %s", prog, err, logFile)
+		case <-time.After(startGrace):
+		}
+		fmt.Printf("%s started (pid %d), logging to %s\n", prog, pid, logFile)
+		return nil
+	}
+}
+
+// stopCmd sends SIGTERM to the agent named by the pidfile and waits for it
+// to exit.
+func stopCmd(prog string, args []string) {
+	fs := flag.NewFlagSet(prog+" stop", flag.ExitOnError)
+	pidFile := fs.String("pidfile", defaultPath("agent.pid"), "pidfile of the agent to stop")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long to wait for the agent to exit")
+	_ = fs.Parse(args)
+
+	pid, err := readPID(*pidFile)
+	if err != nil || !alive(pid) {
+		fmt.Printf("%s is not running\n", prog)
+		return
+	}
+	if err := terminate(pid); err != nil {
+		fmt.Fprintf(os.Stderr, "stop pid %d: %v\n", pid, err)
+		os.Exit(1)
+	}
+	deadline := time.Now().Add(*timeout)
+	for alive(pid) {
+		if time.Now().After(deadline) {
+			fmt.Fprintf(os.Stderr, "%s (pid %d) is still running after %s\n", prog, pid, *timeout)
+			os.Exit(1)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	fmt.Printf("%s stopped (pid %d)\n", prog, pid)
+}
+
+// upgradeCmd asks the agent named by the pidfile to hand its listeners to a
+// fresh start of its executable, and waits for the new process to take over.
+func upgradeCmd(prog string, args []string) {
+	fs := flag.NewFlagSet(prog+" upgrade", flag.ExitOnError)
+	pidFile := fs.String("pidfile", defaultPath("agent.pid"), "pidfile of the agent to upgrade")
+	timeout := fs.Duration("timeout", upgradeTimeout+5*time.Second, "how long to wait for the new process to take over")
+	_ = fs.Parse(args)
+
+	pid, err := readPID(*pidFile)
+	if err != nil || !alive(pid) {
+		fmt.Fprintf(os.Stderr, "%s is not running\n", prog)
+		os.Exit(1)
+	}
+	if err := requestUpgrade(pid); err != nil {
+		fmt.Fprintf(os.Stderr, "upgrade pid %d: %v\n", pid, err)
+		os.Exit(1)
+	}
+	deadline := time.Now().Add(*timeout)
+	for {
+		if got, err := readPID(*pidFile); err == nil && got != pid && alive(got) {
+			fmt.Printf("%s upgraded (pid %d, replacing %d)\n", prog, got, pid)
+			return
+		}
+		switch {
+		case !alive(pid):
+			fmt.Fprintf(os.Stderr, "%s (pid %d) exited without handing over\n", prog, pid)
+			os.Exit(1)
+		case time.Now().After(deadline):
+			fmt.Fprintf(os.Stderr, "%s (pid %d) did not hand over within %s; see its log\n", prog, pid, *timeout)
+			os.Exit(1)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// statusCmd reports whether the agent named by the pidfile is running,
+// exiting with statusNotRunning if it is not.
+func statusCmd(prog string, args []string) {
+	fs := flag.NewFlagSet(prog+" status", flag.ExitOnError)
+	pidFile := fs.String("pidfile", defaultPath("agent.pid"), "pidfile of the agent to check")
+	_ = fs.Parse(args)
+
+	pid, err := readPID(*pidFile)
+	if err != nil || !alive(pid) {
+		fmt.Printf("%s is not running\n", prog)
+		os.Exit(statusNotRunning)
+	}
+	fmt.Printf("%s is running (pid %d)\n", prog, pid)
+}
+
+func readPID(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // callers only test for failure
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%s: invalid pid", path)
+	}
+	return pid, nil
+}
+
+// writePIDFile records this process in path, refusing if it names another
+// live process than predecessor, the process this one replaces in an upgrade
+// (0 if none). A pidfile left by an agent that died is replaced.
+func writePIDFile(path string, predecessor int) error {
+	if pid, err := readPID(path); err == nil && pid != os.Getpid() && pid != predecessor && alive(pid) {
+		return fmt.Errorf("pidfile: %s: already running (pid %d)", path, pid)
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("pidfile: %w", err)
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("pidfile: %w", err)
+	}
+	_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("pidfile: %w", err)
+	}
+	return nil
+}
+
+// removePIDFile removes path if it still names this process.
+func removePIDFile(path string) {
+	if pid, err := readPID(path); err == nil && pid == os.Getpid() {
+		_ = os.Remove(path)
+	}
+}
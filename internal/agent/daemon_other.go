@@ -0,0 +1,36 @@
+//go:build !unix
+
+package agent
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var upgradeSignals []os.Signal
+
+func detach(*exec.Cmd) error {
+	return errors.New("start is not supported on this platform; run the agent under a service manager instead")
+}
+
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+func terminate(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err //nolint:wrapcheck // reported with the pid by the caller
+	}
+	return p.Kill() //nolint:wrapcheck // reported with the pid by the caller
+}
+
+func requestUpgrade(int) error {
+	return errors.New("upgrade is not supported on this platform")
+}
@@ -0,0 +1,37 @@
+//go:build unix
+
+package agent
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// upgradeSignals ask the agent to hand its listeners to a fresh start of its
+// executable.
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// detach starts cmd in a session of its own, so it outlives the terminal
+// that started it.
+func detach(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	return nil
+}
+
+// alive reports whether a process with the given pid exists.
+func alive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// terminate asks the process to shut down as on Ctrl-C.
+func terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM) //nolint:wrapcheck // reported with the pid by the caller
+}
+
+// requestUpgrade asks the process to upgrade itself.
+func requestUpgrade(pid int) error {
+	return syscall.Kill(pid, syscall.SIGUSR2) //nolint:wrapcheck // reported with the pid by the caller
+}
@@ -0,0 +1,146 @@
+package agent
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mickamy/sql-tap/dsn"
+	"github.com/mickamy/sql-tap/internal/anomaly"
+	"github.com/mickamy/sql-tap/internal/autoexplain"
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/nplusone"
+	"github.com/mickamy/sql-tap/internal/pgstat"
+	"github.com/mickamy/sql-tap/internal/routes"
+	"github.com/mickamy/sql-tap/internal/tenants"
+	"github.com/mickamy/sql-tap/internal/traffic"
+)
+
+// Environment variable values in the effective config. Secrets are only
+// reported as set or not.
+const (
+	envSet   = "(set)"
+	envUnset = "(unset)"
+)
+
+// effective is the daemon's resolved configuration, served by the Config RPC
+// to explain why a setting does or does not apply.
+type effective struct {
+	Flags    map[string]string `yaml:"flags"`     // every flag, given or default
+	SetFlags []string          `yaml:"set_flags"` // flags given on the command line
+	Targets  []effectiveTarget `yaml:"targets"`
+	Env      map[string]string `yaml:"env"`    // variables named by flags and the config file
+	Config   config.Config     `yaml:"config"` // the config file, defaults filled in
+}
+
+type effectiveTarget struct {
+	Name          string   `yaml:"name,omitempty"`
+	Driver        string   `yaml:"driver"`
+	Listen        []string `yaml:"listen"`
+	Upstream      string   `yaml:"upstream"`
+	DSN           string   `yaml:"dsn,omitempty"`
+	DSNEnv        string   `yaml:"dsn_env,omitempty"`
+	ReplicaDSNEnv string   `yaml:"replica_dsn_env,omitempty"`
+}
+
+// effectiveConfig renders the settings o and cfg resolve to as YAML, with
+// passwords, tokens, and keys masked.
+func effectiveConfig(o *options, cfg *config.Config) ([]byte, error) {
+	e := effective{
+		Flags:    o.flags,
+		SetFlags: o.setFlags,
+		Env:      make(map[string]string),
+		Config:   withDefaults(*cfg),
+	}
+	for _, t := range o.targets {
+		e.Targets = append(e.Targets, effectiveTarget{
+			Name:          t.name,
+			Driver:        t.driver,
+			Listen:        t.listen.specs(),
+			Upstream:      t.upstream,
+			DSN:           dsn.Mask(t.dsn),
+			DSNEnv:        t.dsnEnv,
+			ReplicaDSNEnv: t.replicaDSNEnv,
+		})
+		for _, env := range []string{t.dsnEnv, t.replicaDSNEnv} {
+			if env != "" {
+				e.Env[env] = envValue(env, dsn.Mask)
+			}
+		}
+	}
+	for _, tok := range cfg.Auth.Tokens {
+		e.Env[tok.TokenEnv] = envValue(tok.TokenEnv, nil)
+	}
+	if env := cfg.Archive.KeyEnv; env != "" {
+		e.Env[env] = envValue(env, nil)
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(e); err != nil {
+		return nil, fmt.Errorf("effective config: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("effective config: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// envValue describes the environment variable env: its value passed through
+// mask, or only whether it is set when mask is nil.
+func envValue(env string, mask func(string) string) string {
+	v, ok := os.LookupEnv(env)
+	switch {
+	case !ok:
+		return envUnset
+	case mask == nil:
+		return envSet
+	}
+	return mask(v)
+}
+
+// withDefaults fills the zero fields of cfg's enabled features with the
+// defaults they run with.
+func withDefaults(cfg config.Config) config.Config {
+	if !cfg.Anomaly.Disabled {
+		a := &cfg.Anomaly
+		a.Threshold = cmp.Or(a.Threshold, anomaly.DefaultThreshold)
+		a.MinSamples = cmp.Or(a.MinSamples, anomaly.DefaultMinSamples)
+		a.Alpha = cmp.Or(a.Alpha, anomaly.DefaultAlpha)
+	}
+	if !cfg.Traffic.Disabled {
+		t := &cfg.Traffic
+		t.Window = cmp.Or(t.Window, traffic.DefaultWindow)
+		t.Factor = cmp.Or(t.Factor, traffic.DefaultFactor)
+		t.Warmup = cmp.Or(t.Warmup, traffic.DefaultWarmup)
+		t.MinCalls = cmp.Or(t.MinCalls, traffic.DefaultMinCalls)
+	}
+	if !cfg.NPlusOne.Disabled {
+		n := &cfg.NPlusOne
+		n.Threshold = cmp.Or(n.Threshold, nplusone.DefaultThreshold)
+		n.Window = cmp.Or(n.Window, nplusone.DefaultWindow)
+	}
+	cfg.Routes.QueryBudget = cmp.Or(cfg.Routes.QueryBudget, routes.DefaultQueryBudget)
+	if cfg.Tenants.Field != "" {
+		t := &cfg.Tenants
+		t.MaxShare = cmp.Or(t.MaxShare, tenants.DefaultMaxShare)
+		t.Window = cmp.Or(t.Window, tenants.DefaultQuotaWindow)
+		t.MinCalls = cmp.Or(t.MinCalls, tenants.DefaultMinCalls)
+	}
+	if cfg.AutoExplain.Threshold > 0 {
+		a := &cfg.AutoExplain
+		a.CacheTTL = cmp.Or(a.CacheTTL, autoexplain.DefaultCacheTTL)
+		a.Timeout = cmp.Or(a.Timeout, autoexplain.DefaultTimeout)
+	}
+	if cfg.PgStat.Interval > 0 {
+		cfg.PgStat.Limit = cmp.Or(cfg.PgStat.Limit, pgstat.DefaultLimit)
+	}
+	if cfg.Policy.Enabled() {
+		cfg.Policy.Default = cmp.Or(cfg.Policy.Default, "allow")
+	}
+	return cfg
+}
@@ -0,0 +1,7 @@
+package agent
+
+// Unexported pieces of the agent, exposed to its external tests.
+var (
+	GuardPipeline = guardPipeline
+	ExtractFields = extractFields
+)
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/mickamy/sql-tap/internal/logfile"
+)
+
+// logOptions configure where and how the agent logs.
+type logOptions struct {
+	file     string // empty logs to stderr
+	level    slog.Level
+	json     bool
+	maxSize  int64
+	maxFiles int
+}
+
+func parseLogOptions(file, level, format string, maxSizeMB, maxFiles int) (logOptions, error) {
+	o := logOptions{file: file, maxSize: int64(maxSizeMB) << 20, maxFiles: maxFiles}
+	if o.level.UnmarshalText([]byte(level)) != nil {
+		return logOptions{}, fmt.Errorf("-log-level must be debug, info, warn, or error, not %q", level)
+	}
+	switch format {
+	case "text":
+	case "json":
+		o.json = true
+	default:
+		return logOptions{}, errors.New("-log-format must be text or json")
+	}
+	if maxSizeMB < 0 || maxFiles < 0 {
+		return logOptions{}, errors.New("-log-max-size and -log-max-files must not be negative")
+	}
+	return o, nil
+}
+
+// setupLogging makes a handler for o the slog default, which also carries
+// everything written through the log package. The returned func closes the
+// log file, if any.
+func setupLogging(o logOptions) (func(), error) {
+	var w io.Writer = os.Stderr
+	closeLog := func() {}
+	if o.file != "" {
+		f, err := logfile.Open(o.file, o.maxSize, o.maxFiles)
+		if err != nil {
+			return nil, err
+		}
+		w = f
+		closeLog = func() { _ = f.Close() }
+	}
+
+	opts := &slog.HandlerOptions{Level: o.level}
+	var h slog.Handler
+	if o.json {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(h))
+	return closeLog, nil
+}
@@ -0,0 +1,94 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/otlp"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+const (
+	// otlpBatchSize and otlpBatchInterval bound how many events are posted
+	// at once and how long an event waits before being posted.
+	otlpBatchSize     = 512
+	otlpBatchInterval = time.Second
+)
+
+// otlpOptions configure OTLP export, from -otlp, -otlp-signal, and
+// -otlp-untraced.
+type otlpOptions struct {
+	endpoint string // a collector URL, "env" for the one OTEL_* variables name, or empty to disable export
+	signal   otlp.Signal
+	untraced bool
+}
+
+// newExporter returns the exporter o describes, configured further by the
+// standard OTEL_* variables read through getenv.
+func (o otlpOptions) newExporter(getenv func(string) string) (*otlp.Exporter, error) {
+	envEndpoint, envOpts, err := otlp.FromEnv(getenv, o.signal)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := o.endpoint
+	if endpoint == "env" {
+		if envEndpoint == "" {
+			return nil, errors.New("-otlp=env: neither OTEL_EXPORTER_OTLP_ENDPOINT nor the per-signal endpoint is set")
+		}
+		endpoint = envEndpoint
+	}
+	opts := []otlp.Option{otlp.WithSignal(o.signal)}
+	if o.untraced {
+		opts = append(opts, otlp.WithUntraced())
+	}
+	return otlp.New(endpoint, append(opts, envOpts...)...)
+}
+
+// runOTLP exports the published events e accepts until ctx is done, timing
+// each batch as the exporter's sink stage. The returned channel is closed
+// once the last batch has been posted.
+func runOTLP(ctx context.Context, b *broker.Broker[proxy.Event], e *otlp.Exporter, stages *metrics.Stages) <-chan struct{} {
+	events, unsubscribe := b.Subscribe(broker.WithName("otlp"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+
+		var batch []proxy.Event
+		export := func(ctx context.Context) {
+			if len(batch) == 0 {
+				return
+			}
+			start := time.Now()
+			if err := e.Export(ctx, batch); err != nil {
+				slog.Error("otlp: dropped events", "events", len(batch), "err", err)
+			}
+			stages.Observe(metrics.Sink("otlp"), time.Since(start))
+			batch = batch[:0]
+		}
+
+		tick := time.NewTicker(otlpBatchInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				export(context.WithoutCancel(ctx))
+				return
+			case ev := <-events:
+				if !e.Exports(ev) {
+					continue
+				}
+				if batch = append(batch, ev); len(batch) >= otlpBatchSize {
+					export(ctx)
+				}
+			case <-tick.C:
+				export(ctx)
+			}
+		}
+	}()
+	return done
+}
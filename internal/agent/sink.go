@@ -0,0 +1,110 @@
+package agent
+
+import (
+	"cmp"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/eventsink"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+const (
+	// sinkBatchSize and sinkBatchInterval are the defaults of a sink's
+	// batch_size and batch_interval.
+	sinkBatchSize     = 500
+	sinkBatchInterval = time.Second
+)
+
+// openSink opens the event sink c configures.
+func openSink(c config.Sink) (*eventsink.Sink, error) {
+	format, err := eventsink.ParseFormat(c.Format)
+	if err != nil {
+		return nil, err
+	}
+	opts := []eventsink.Option{eventsink.WithFormat(format)}
+	if c.Retries != nil {
+		opts = append(opts, eventsink.WithRetries(*c.Retries))
+	}
+	if env := c.PasswordEnv; env != "" {
+		password := os.Getenv(env)
+		if password == "" {
+			return nil, fmt.Errorf("sink: %s is not set", env)
+		}
+		opts = append(opts, eventsink.WithPassword(password))
+	}
+	if c.SASL != "" {
+		opts = append(opts, eventsink.WithSASL(c.SASL))
+	}
+	if c.TLS {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		if c.CAFile != "" {
+			pem, err := os.ReadFile(c.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("sink: %w", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("sink: %s: no PEM certificates", c.CAFile)
+			}
+		}
+		opts = append(opts, eventsink.WithTLS(tlsConfig))
+	}
+	return eventsink.Open(c.URL, opts...)
+}
+
+// runSink publishes every event to s, in batches of c's size or age, until
+// ctx is done, timing each batch as the sink's stage. The returned channel
+// is closed once the last batch has been sent and s closed.
+func runSink(
+	ctx context.Context, b *broker.Broker[proxy.Event], s *eventsink.Sink, c config.Sink, stages *metrics.Stages,
+) <-chan struct{} {
+	events, unsubscribe := b.Subscribe(broker.WithName("sink " + s.String()))
+	size := cmp.Or(c.BatchSize, sinkBatchSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		defer func() { _ = s.Close() }()
+
+		var batch []*tapv1.QueryEvent
+		send := func(ctx context.Context) {
+			if len(batch) == 0 {
+				return
+			}
+			start := time.Now()
+			if err := s.Send(ctx, batch); err != nil {
+				slog.Error("sink: dropped events", "err", err)
+			}
+			stages.Observe(metrics.Sink(s.String()), time.Since(start))
+			batch = batch[:0]
+		}
+
+		tick := time.NewTicker(cmp.Or(c.BatchInterval, sinkBatchInterval))
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				send(context.WithoutCancel(ctx))
+				return
+			case ev := <-events:
+				if batch = append(batch, server.EventToProto(ev)); len(batch) >= size {
+					send(ctx)
+				}
+			case <-tick.C:
+				send(ctx)
+			}
+		}
+	}()
+	return done
+}
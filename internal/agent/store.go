@@ -0,0 +1,55 @@
+package agent
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/internal/store"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// storeSyncInterval bounds how many seconds of events a power loss can take
+// from the store.
+const storeSyncInterval = time.Second
+
+// runStore appends every published event to st until ctx is done. It
+// subscribes with the Block policy so the store misses nothing the broker
+// publishes, and times each append as the store's sink stage. The returned
+// channel is closed once the store has been synced and closed.
+func runStore(ctx context.Context, b *broker.Broker[proxy.Event], st *store.Store, stages *metrics.Stages) <-chan struct{} {
+	events, unsubscribe := b.Subscribe(broker.WithName("store"), broker.WithPolicy(broker.Block))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer unsubscribe()
+		defer func() {
+			if err := st.Close(); err != nil {
+				slog.Error("store", "err", err)
+			}
+		}()
+
+		sync := time.NewTicker(storeSyncInterval)
+		defer sync.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev := <-events:
+				start := time.Now()
+				if err := st.Append(server.EventToProto(ev)); err != nil {
+					slog.Error("store", "err", err)
+				}
+				stages.Observe(metrics.Sink("store"), time.Since(start))
+			case <-sync.C:
+				if err := st.Sync(); err != nil {
+					slog.Error("store", "err", err)
+				}
+			}
+		}
+	}()
+	return done
+}
@@ -0,0 +1,248 @@
+package agent
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mickamy/sql-tap/dsn"
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/proxy"
+	"github.com/mickamy/sql-tap/proxy/mysql"
+	"github.com/mickamy/sql-tap/proxy/postgres"
+)
+
+// target is one listen/upstream pair tapped by the daemon.
+type target struct {
+	name     string // empty for the single-target -driver/-listen/-upstream form
+	driver   string
+	listen   listenFlags // the first is the address apps are pointed at
+	upstream string
+	dsnEnv   string // env var holding the DSN for EXPLAIN; empty disables EXPLAIN
+	dsn      string // set when the upstream was given as a DSN; opens EXPLAIN instead of dsnEnv
+
+	replicaDSNEnv string // env var holding the read replica's DSN; empty disables replica routing
+
+	dialTimeout  time.Duration         // from -dial-timeout; 0 keeps the proxy's default
+	maxConns     int                   // from -max-conns; 0 leaves connections unlimited
+	idleTimeout  time.Duration         // from -idle-timeout; 0 disables it
+	queryTimeout time.Duration         // from -query-timeout; 0 disables it
+	appNameLabel postgres.AppNameLabel // from -app-name-label; empty leaves application_name alone
+	pooler       postgres.PoolMode     // from -pooler; empty when clients are not a pooler
+}
+
+// listenFlags collects repeated -listen flags, or a -tap flag's listen
+// options, each a proxy.ListenSpec.
+type listenFlags []proxy.ListenSpec
+
+func (f *listenFlags) String() string {
+	return strings.Join(f.specs(), " ")
+}
+
+// specs returns the listen specs in the form Set parses.
+func (f listenFlags) specs() []string {
+	specs := make([]string, len(f))
+	for i, l := range f {
+		specs[i] = l.String()
+	}
+	return specs
+}
+
+// Set parses "<addr>[;label=<name>][;allow=<cidr>|<cidr>...]" (see
+// proxy.ParseListenSpec).
+func (f *listenFlags) Set(v string) error {
+	l, err := proxy.ParseListenSpec(v)
+	if err != nil {
+		return err //nolint:wrapcheck // the error names the spec
+	}
+	*f = append(*f, l)
+	return nil
+}
+
+// addr returns the first listen address, the one apps are pointed at; ""
+// when there is none.
+func (f listenFlags) addr() string {
+	if len(f) == 0 {
+		return ""
+	}
+	return f[0].Addr
+}
+
+// targetFlags collects repeated -tap flags.
+type targetFlags []target
+
+func (f *targetFlags) String() string {
+	names := make([]string, len(*f))
+	for i, t := range *f {
+		names[i] = t.name
+	}
+	return strings.Join(names, ",")
+}
+
+// Set parses "name=<name>,driver=<driver>,listen=<addr>,upstream=<addr>[,dsn-env=<var>][,replica-dsn-env=<var>]",
+// where listen may be repeated and take a listen spec's options.
+func (f *targetFlags) Set(v string) error {
+	var t target
+	for kv := range strings.SplitSeq(v, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return fmt.Errorf("invalid tap option %q (want key=value)", kv)
+		}
+		switch key {
+		case "name":
+			t.name = val
+		case "driver":
+			t.driver = val
+		case "listen":
+			if err := t.listen.Set(val); err != nil {
+				return fmt.Errorf("tap %q: %w", t.name, err)
+			}
+		case "upstream":
+			t.upstream = val
+		case "dsn-env":
+			t.dsnEnv = val
+		case "replica-dsn-env":
+			t.replicaDSNEnv = val
+		default:
+			return fmt.Errorf("unknown tap option %q", key)
+		}
+	}
+	if err := t.resolveDSN(); err != nil {
+		return fmt.Errorf("tap %q: %w", t.name, err)
+	}
+	if t.name == "" || t.driver == "" || len(t.listen) == 0 || t.upstream == "" {
+		return fmt.Errorf("tap %q: name, driver, listen, and upstream are required", t.name)
+	}
+	for _, existing := range *f {
+		if existing.name == t.name {
+			return fmt.Errorf("duplicate tap name %q", t.name)
+		}
+	}
+	*f = append(*f, t)
+	return nil
+}
+
+// resolveDSN replaces an upstream given as a DSN with the address it names
+// and keeps the DSN for EXPLAIN. The driver is taken from the DSN unless one
+// is set, which must then speak the same protocol. Any other upstream is an
+// address and is left alone.
+func (t *target) resolveDSN() error {
+	detected, err := dsn.DetectDriver(t.upstream)
+	if err != nil {
+		return nil //nolint:nilerr // not a DSN: host:port or a socket path
+	}
+	addr, err := dsn.Address(t.upstream)
+	if err != nil {
+		return fmt.Errorf("upstream: %w", err)
+	}
+	driver := "postgres"
+	if detected == "mysql" {
+		driver = "mysql"
+	}
+	switch {
+	case t.driver == "":
+		t.driver = driver
+	case t.driver == "tidb" && driver == "mysql":
+	case t.driver != driver:
+		return fmt.Errorf("upstream is a %s DSN, but the driver is %s", driver, t.driver)
+	}
+	t.dsn, t.upstream = t.upstream, addr
+	return nil
+}
+
+// label returns a human-readable name for log messages.
+func (t target) label() string {
+	if t.name == "" {
+		return t.driver
+	}
+	return t.name + " (" + t.driver + ")"
+}
+
+// newProxy builds the protocol proxy for the target's driver; pol, if not
+// nil, is the statement policy it enforces.
+func (t target) newProxy(verbosity *proxy.Verbosity, tlsConfig *tls.Config, pol proxy.Policy) (proxy.Proxy, error) {
+	switch t.driver {
+	case "postgres":
+		opts := []postgres.Option{postgres.WithVerbosity(verbosity), postgres.WithListeners(t.listen...)}
+		if tlsConfig != nil {
+			opts = append(opts, postgres.WithTLSConfig(tlsConfig))
+		}
+		if t.dialTimeout != 0 {
+			opts = append(opts, postgres.WithDialTimeout(t.dialTimeout))
+		}
+		if t.maxConns > 0 {
+			opts = append(opts, postgres.WithMaxConns(t.maxConns))
+		}
+		if t.idleTimeout > 0 {
+			opts = append(opts, postgres.WithIdleTimeout(t.idleTimeout))
+		}
+		if t.queryTimeout > 0 {
+			opts = append(opts, postgres.WithQueryTimeout(t.queryTimeout))
+		}
+		if t.appNameLabel != "" {
+			opts = append(opts, postgres.WithAppNameLabel(t.appNameLabel))
+		}
+		if t.pooler != "" {
+			opts = append(opts, postgres.WithPooler(t.pooler))
+		}
+		if pol != nil {
+			opts = append(opts, postgres.WithPolicy(pol))
+		}
+		if t.replicaDSNEnv != "" {
+			raw := os.Getenv(t.replicaDSNEnv)
+			if raw == "" {
+				return nil, fmt.Errorf("replica routing for %s: %s is not set", t.label(), t.replicaDSNEnv)
+			}
+			opts = append(opts, postgres.WithReplica(raw))
+		}
+		return postgres.New(t.listen.addr(), t.upstream, opts...), nil
+	case "mysql", "tidb":
+		if t.replicaDSNEnv != "" {
+			return nil, fmt.Errorf("replica routing for %s: only postgres is supported", t.label())
+		}
+		opts := []mysql.Option{mysql.WithVerbosity(verbosity), mysql.WithListeners(t.listen...)}
+		if t.dialTimeout != 0 {
+			opts = append(opts, mysql.WithDialTimeout(t.dialTimeout))
+		}
+		if t.maxConns > 0 {
+			opts = append(opts, mysql.WithMaxConns(t.maxConns))
+		}
+		if t.idleTimeout > 0 {
+			opts = append(opts, mysql.WithIdleTimeout(t.idleTimeout))
+		}
+		if pol != nil {
+			opts = append(opts, mysql.WithPolicy(pol))
+		}
+		return mysql.New(t.listen.addr(), t.upstream, opts...), nil
+	}
+	return nil, fmt.Errorf("unsupported driver: %s", t.driver)
+}
+
+// openExplain opens an EXPLAIN client from the target's upstream DSN or, for
+// an upstream given as an address, its DSN env var. It returns nil without
+// error when neither is set.
+func (t target) openExplain() (*explain.Client, error) {
+	raw := t.dsn
+	if raw == "" && t.dsnEnv != "" {
+		raw = os.Getenv(t.dsnEnv)
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	db, err := dsn.Open(raw)
+	if err != nil {
+		return nil, fmt.Errorf("open db for explain: %w", err)
+	}
+	var explainDriver explain.Driver
+	switch t.driver {
+	case "mysql":
+		explainDriver = explain.MySQL
+	case "tidb":
+		explainDriver = explain.TiDB
+	case "postgres":
+		explainDriver = explain.Postgres
+	}
+	return explain.NewClient(db, explainDriver), nil
+}
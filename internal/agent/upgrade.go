@@ -0,0 +1,333 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// An upgrade replaces a running agent with a fresh start of its executable,
+// typically a new binary installed at the same path, without refusing or
+// dropping client connections:
+//
+//  1. On the upgrade signal the old process starts the new one with its own
+//     command line, handing it duplicates of its listeners' sockets. Both
+//     processes then hold them, and the old one keeps accepting.
+//  2. The new process sets everything up except the store and archive, which
+//     only one process may write, and reports ready. If it fails or is not
+//     ready within upgradeTimeout, the old process kills it and carries on.
+//  3. The old process stops accepting, closes its store and archive, and
+//     tells the new one to go on. From here it forwards its events to the
+//     new process, which runs them through its pipeline as its own.
+//  4. The old process serves the connections it has until their clients
+//     close them, up to the drain timeout, and exits.
+const (
+	// upgradeFDsEnv names the environment variable with the file
+	// descriptors of the new process's ends of the handshake pipes.
+	upgradeFDsEnv = "SQL_TAP_UPGRADE_FDS"
+	// upgradeTimeout bounds how long the new process takes to be ready.
+	upgradeTimeout = 30 * time.Second
+	// forwardQueue bounds the events waiting to be forwarded; events
+	// arriving while it is full are dropped and counted as dropped events.
+	forwardQueue = 4096
+)
+
+// ExtraFiles of the new process, which the child sees from fd 3 on.
+const (
+	readyFD  = 3
+	eventsFD = 4
+	firstFD  = 5 // the listeners
+)
+
+// upgrade serves upgrade signals in the old process.
+type upgrade struct {
+	forward      atomic.Pointer[forwarder] // set once the new process took over
+	stopSinks    func()                    // closes the store and archive
+	stopServers  func()                    // stops the gRPC and HTTP servers
+	drainTimeout time.Duration
+}
+
+// forwarding returns the forwarder events go to instead of the pipeline
+// once the new process took over, or nil.
+func (u *upgrade) forwarding() *forwarder {
+	return u.forward.Load()
+}
+
+// watch starts a new process on each upgrade signal until one takes over or
+// ctx is done. After a takeover it waits for the open connections to close,
+// up to the drain timeout, and calls stop.
+func (u *upgrade) watch(ctx context.Context, stop context.CancelFunc) {
+	if len(upgradeSignals) == 0 {
+		return
+	}
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, upgradeSignals...)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigs:
+		}
+		s, err := startSuccessor()
+		if err != nil {
+			slog.Error("upgrade failed; still serving", "err", err)
+			continue
+		}
+		slog.Info("upgrade: started new process", "pid", s.cmd.Process.Pid)
+		if err := s.waitReady(ctx); err != nil {
+			s.abort()
+			slog.Error("upgrade failed; still serving", "pid", s.cmd.Process.Pid, "err", err)
+			continue
+		}
+		u.handOver(ctx, s, stop)
+		return
+	}
+}
+
+func (u *upgrade) handOver(ctx context.Context, s *successor, stop context.CancelFunc) {
+	f := newForwarder()
+	u.forward.Store(f)
+	u.stopSinks()
+	drained := proxy.Release()
+	u.stopServers()
+	if err := s.proceed(); err != nil {
+		slog.Error("upgrade: new process is gone after taking the listeners", "pid", s.cmd.Process.Pid, "err", err)
+	}
+	go f.run(s.events)
+	slog.Info("upgrade: handed over; serving open connections until they close",
+		"pid", s.cmd.Process.Pid, "drain_timeout", u.drainTimeout)
+
+	timer := time.NewTimer(u.drainTimeout)
+	defer timer.Stop()
+	select {
+	case <-drained:
+		slog.Info("upgrade: connections drained")
+	case <-timer.C:
+		slog.Warn("upgrade: drain timeout; closing open connections")
+	case <-ctx.Done():
+	}
+	stop()
+}
+
+// finish forwards the events still queued after a takeover.
+func (u *upgrade) finish() {
+	if f := u.forward.Load(); f != nil {
+		f.close()
+	}
+}
+
+// successor is the new process of an upgrade, seen from the old one.
+type successor struct {
+	cmd    *exec.Cmd
+	ready  *os.File // read end: a byte once the new process is ready
+	events *os.File // write end: a byte to proceed, then the forwarded events
+	exited chan error
+}
+
+// startSuccessor starts this executable with this process's command line,
+// handing it the listeners.
+func startSuccessor() (*successor, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	eventsR, eventsW, err := os.Pipe()
+	if err != nil {
+		closeAll(readyR, readyW)
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	listeners, listenEnv, err := proxy.ListenerFiles(firstFD)
+	if err != nil {
+		closeAll(readyR, readyW, eventsR, eventsW)
+		return nil, fmt.Errorf("upgrade: %w", err)
+	}
+	// The child's ends are its own once it has started.
+	defer closeAll(append([]*os.File{readyW, eventsR}, listeners...)...)
+
+	cmd := exec.CommandContext(context.Background(), exe, os.Args[1:]...) //nolint:gosec // re-runs this binary with its own flags
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = append([]*os.File{readyW, eventsR}, listeners...)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%d %d", upgradeFDsEnv, readyFD, eventsFD),
+		proxy.ListenFDsEnv+"="+listenEnv,
+	)
+	if err := cmd.Start(); err != nil {
+		closeAll(readyR, eventsW)
+		return nil, fmt.Errorf("upgrade: start %s: %w", exe, err)
+	}
+	s := &successor{cmd: cmd, ready: readyR, events: eventsW, exited: make(chan error, 1)}
+	go func() { s.exited <- cmd.Wait() }()
+	return s, nil
+}
+
+// waitReady waits for the new process to report ready.
+func (s *successor) waitReady(ctx context.Context) error {
+	ready := make(chan error, 1)
+	go func() {
+		_, err := s.ready.Read(make([]byte, 1))
+		ready <- err
+	}()
+	timer := time.NewTimer(upgradeTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-ready:
+		if err != nil {
+			return fmt.Errorf("upgrade: new process failed to start (see its log): %w", err)
+		}
+		return nil
+	case err := <-s.exited:
+		return fmt.Errorf("upgrade: new process exited during startup: %w", err)
+	case <-timer.C:
+		return fmt.Errorf("upgrade: new process not ready within %s", upgradeTimeout)
+	case <-ctx.Done():
+		return fmt.Errorf("upgrade: %w", ctx.Err())
+	}
+}
+
+// proceed tells the new process to open the store and archive and serve.
+func (s *successor) proceed() error {
+	_ = s.ready.Close()
+	if _, err := s.events.Write([]byte{1}); err != nil {
+		return fmt.Errorf("upgrade: %w", err)
+	}
+	return nil
+}
+
+// abort kills the new process after a failed upgrade.
+func (s *successor) abort() {
+	_ = s.cmd.Process.Kill()
+	closeAll(s.ready, s.events)
+}
+
+func closeAll(files ...*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// forwarder sends the old process's events to the new one, gob-encoded.
+type forwarder struct {
+	mu     sync.Mutex
+	events chan proxy.Event // nil once closed
+	done   chan struct{}
+}
+
+func newForwarder() *forwarder {
+	return &forwarder{events: make(chan proxy.Event, forwardQueue), done: make(chan struct{})}
+}
+
+// send queues ev without blocking.
+func (f *forwarder) send(ev proxy.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.events != nil {
+		proxy.Emit(f.events, ev)
+	}
+}
+
+// run writes the queued events to w until the forwarder is closed, then
+// closes w. Once a write fails the rest are discarded.
+func (f *forwarder) run(w io.WriteCloser) {
+	defer close(f.done)
+	defer func() { _ = w.Close() }()
+	enc := gob.NewEncoder(w)
+	var failed bool
+	for ev := range f.events {
+		if failed {
+			continue
+		}
+		if err := enc.Encode(ev); err != nil {
+			slog.Error("upgrade: forward events to the new process", "err", err)
+			failed = true
+		}
+	}
+}
+
+// close stops queueing events and waits for the queued ones to be written.
+func (f *forwarder) close() {
+	f.mu.Lock()
+	if f.events != nil {
+		close(f.events)
+		f.events = nil
+	}
+	f.mu.Unlock()
+	<-f.done
+}
+
+// takeover is the old process of an upgrade, seen from the new one.
+type takeover struct {
+	ready   *os.File // write end
+	events  *os.File // read end
+	pidFile string   // written once the old process has handed over
+}
+
+// inheritedTakeover returns the takeover this process was started for by an
+// upgrade, or nil.
+func inheritedTakeover() (*takeover, error) {
+	v, ok := os.LookupEnv(upgradeFDsEnv)
+	if !ok {
+		return nil, nil //nolint:nilnil // not started by an upgrade
+	}
+	// Processes this one starts for its own upgrades get their own.
+	_ = os.Unsetenv(upgradeFDsEnv)
+	var ready, events uintptr
+	if _, err := fmt.Sscanf(v, "%d %d", &ready, &events); err != nil {
+		return nil, fmt.Errorf("upgrade: %s=%q: %w", upgradeFDsEnv, v, err)
+	}
+	return &takeover{ready: os.NewFile(ready, "upgrade-ready"), events: os.NewFile(events, "upgrade-events")}, nil
+}
+
+// handshake reports this process ready and waits for the old one to hand
+// over, writing the pidfile once it has.
+func (t *takeover) handshake() error {
+	_, err := t.ready.Write([]byte{1})
+	_ = t.ready.Close()
+	if err != nil {
+		return fmt.Errorf("upgrade: report ready: %w", err)
+	}
+	if _, err := io.ReadFull(t.events, make([]byte, 1)); err != nil {
+		return fmt.Errorf("upgrade: previous process did not hand over: %w", err)
+	}
+	if t.pidFile != "" {
+		if err := writePIDFile(t.pidFile, os.Getppid()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// receive sends the events the old process forwards to out until it exits,
+// then closes out.
+func (t *takeover) receive(out chan<- proxy.Event) {
+	defer close(out)
+	defer func() { _ = t.events.Close() }()
+	dec := gob.NewDecoder(bufio.NewReader(t.events))
+	for {
+		var ev proxy.Event
+		if err := dec.Decode(&ev); err != nil {
+			if !errors.Is(err, io.EOF) {
+				slog.Error("upgrade: events from the previous process", "err", err)
+			}
+			return
+		}
+		out <- ev
+	}
+}
@@ -0,0 +1,147 @@
+// Package anomaly flags queries that ran much slower than their own recent
+// history. Each query fingerprint keeps an exponentially weighted mean and
+// variance of its latency; an event whose z-score against that baseline
+// crosses a threshold is tagged. Unlike a min_duration tagging rule, this
+// catches a 40ms query that usually takes 2ms and ignores a report query
+// that always takes 5s.
+package anomaly
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Tag is added to anomalous events.
+const Tag = "anomaly"
+
+// Defs returns the anomaly tag with its TUI color.
+func Defs() []tagger.Def {
+	return []tagger.Def{{Name: Tag, Color: "201"}}
+}
+
+// Defaults for the Detector options.
+const (
+	DefaultThreshold  = 3.0
+	DefaultMinSamples = 20
+	DefaultAlpha      = 0.05
+	// DefaultMinExcess ignores deviations too small to matter, so a query
+	// that jitters between 50µs and 200µs is not flagged.
+	DefaultMinExcess = time.Millisecond
+	// DefaultMaxFingerprints bounds memory on workloads with unbounded
+	// distinct queries.
+	DefaultMaxFingerprints = 10000
+)
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithThreshold sets the z-score at or above which an event is anomalous.
+func WithThreshold(z float64) Option {
+	return func(d *Detector) {
+		d.threshold = z
+	}
+}
+
+// WithMinSamples sets how many events a fingerprint needs before its
+// baseline is trusted.
+func WithMinSamples(n int) Option {
+	return func(d *Detector) {
+		d.minSamples = n
+	}
+}
+
+// WithAlpha sets the EWMA smoothing factor in (0, 1]; larger values adapt to
+// new latency levels faster.
+func WithAlpha(alpha float64) Option {
+	return func(d *Detector) {
+		d.alpha = alpha
+	}
+}
+
+type baseline struct {
+	mean, variance float64 // in seconds and seconds squared
+	n              int
+}
+
+// Detector tracks per-fingerprint latency baselines. It is not safe for
+// concurrent use.
+type Detector struct {
+	threshold  float64
+	minSamples int
+	alpha      float64
+	baselines  map[string]*baseline
+}
+
+// New returns a Detector with the default settings, adjusted by opts.
+func New(opts ...Option) *Detector {
+	d := &Detector{
+		threshold:  DefaultThreshold,
+		minSamples: DefaultMinSamples,
+		alpha:      DefaultAlpha,
+		baselines:  make(map[string]*baseline),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Observe scores ev against its fingerprint's baseline, then folds its
+// duration into the baseline. Anomalous events get ev.Anomaly and the
+// anomaly tag. Failed queries and statements without a query are skipped.
+func (d *Detector) Observe(ev *proxy.Event) {
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	default:
+		return
+	}
+	if ev.Error != "" || ev.Query == "" {
+		return
+	}
+
+	fp := ev.Fingerprint
+	if fp == "" {
+		fp = query.Fingerprint(ev.Query)
+	}
+	b, ok := d.baselines[fp]
+	if !ok {
+		if len(d.baselines) >= DefaultMaxFingerprints {
+			for k := range d.baselines { // evict an arbitrary fingerprint
+				delete(d.baselines, k)
+				break
+			}
+		}
+		d.baselines[fp] = &baseline{mean: ev.Duration.Seconds(), n: 1}
+		return
+	}
+
+	x := ev.Duration.Seconds()
+	diff := x - b.mean
+	if b.n >= d.minSamples && diff >= DefaultMinExcess.Seconds() {
+		// A perfectly steady baseline has zero variance; any real excess over
+		// it is anomalous.
+		z := math.Inf(1)
+		if sd := math.Sqrt(b.variance); sd > 0 {
+			z = diff / sd
+		}
+		if z >= d.threshold {
+			ev.Anomaly = &proxy.Anomaly{
+				Score:    min(z, math.MaxFloat32),
+				Baseline: time.Duration(b.mean * float64(time.Second)),
+			}
+			if !slices.Contains(ev.Tags, Tag) {
+				ev.Tags = append(ev.Tags, Tag)
+			}
+		}
+	}
+
+	incr := d.alpha * diff
+	b.mean += incr
+	b.variance = (1 - d.alpha) * (b.variance + diff*incr)
+	b.n++
+}
@@ -0,0 +1,101 @@
+package anomaly_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/anomaly"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// warm feeds n events alternating between lo and hi so the baseline has some
+// variance.
+func warm(d *anomaly.Detector, q string, n int, lo, hi time.Duration) {
+	for i := range n {
+		dur := lo
+		if i%2 == 1 {
+			dur = hi
+		}
+		d.Observe(&proxy.Event{Op: proxy.OpQuery, Query: q, Duration: dur})
+	}
+}
+
+func TestDetector_Observe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		query    string
+		duration time.Duration
+		want     bool
+	}{
+		{name: "typical", query: "SELECT * FROM users WHERE id = 7", duration: 2 * time.Millisecond, want: false},
+		{name: "much slower than its baseline", query: "SELECT * FROM users WHERE id = 7", duration: 40 * time.Millisecond, want: true},
+		{name: "slow but no baseline yet", query: "SELECT * FROM reports", duration: 5 * time.Second, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := anomaly.New()
+			warm(d, "SELECT * FROM users WHERE id = 1", 50, 2*time.Millisecond, 3*time.Millisecond)
+
+			ev := &proxy.Event{Op: proxy.OpQuery, Query: tt.query, Duration: tt.duration}
+			d.Observe(ev)
+			if got := ev.Anomaly != nil; got != tt.want {
+				t.Fatalf("anomalous = %v, want %v (%+v)", got, tt.want, ev.Anomaly)
+			}
+			if got := slices.Contains(ev.Tags, anomaly.Tag); got != tt.want {
+				t.Errorf("tagged = %v, want %v", got, tt.want)
+			}
+			if tt.want && (ev.Anomaly.Score < anomaly.DefaultThreshold || ev.Anomaly.Baseline < 2*time.Millisecond) {
+				t.Errorf("unexpected anomaly: %+v", ev.Anomaly)
+			}
+		})
+	}
+}
+
+func TestDetector_MinExcess(t *testing.T) {
+	t.Parallel()
+
+	// A steady 50µs query has almost no variance, but a 300µs run is not
+	// worth flagging.
+	d := anomaly.New()
+	warm(d, "SELECT 1", 50, 50*time.Microsecond, 50*time.Microsecond)
+	ev := &proxy.Event{Op: proxy.OpQuery, Query: "SELECT 1", Duration: 300 * time.Microsecond}
+	d.Observe(ev)
+	if ev.Anomaly != nil {
+		t.Fatalf("unexpected anomaly: %+v", ev.Anomaly)
+	}
+}
+
+func TestDetector_SkipsErrorsAndControl(t *testing.T) {
+	t.Parallel()
+
+	d := anomaly.New(anomaly.WithMinSamples(2))
+	warm(d, "SELECT 1", 10, time.Millisecond, time.Millisecond)
+	for _, ev := range []*proxy.Event{
+		{Op: proxy.OpQuery, Query: "SELECT 1", Duration: time.Second, Error: "canceled"},
+		{Op: proxy.OpCommit, Query: "COMMIT", Duration: time.Second},
+	} {
+		d.Observe(ev)
+		if ev.Anomaly != nil {
+			t.Errorf("unexpected anomaly on %v: %+v", ev.Op, ev.Anomaly)
+		}
+	}
+}
+
+func TestDetector_Adapts(t *testing.T) {
+	t.Parallel()
+
+	// After a sustained shift, the new latency becomes the baseline.
+	d := anomaly.New(anomaly.WithAlpha(0.5))
+	warm(d, "SELECT 1", 30, 2*time.Millisecond, 3*time.Millisecond)
+	warm(d, "SELECT 1", 30, 40*time.Millisecond, 42*time.Millisecond)
+	ev := &proxy.Event{Op: proxy.OpQuery, Query: "SELECT 1", Duration: 41 * time.Millisecond}
+	d.Observe(ev)
+	if ev.Anomaly != nil {
+		t.Fatalf("unexpected anomaly after the baseline shifted: %+v", ev.Anomaly)
+	}
+}
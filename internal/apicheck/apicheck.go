@@ -0,0 +1,211 @@
+// Package apicheck lists the exported API of a Go package, one feature per
+// line, in the spirit of the Go distribution's api/*.txt files. Its test
+// compares the module's stable packages with the lists in testdata, so that
+// a change breaking external users fails CI instead of shipping.
+//
+// Features are listed without parameter names, constant values, or
+// comments, which can change without breaking callers.
+package apicheck
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Packages are the module's stable packages, relative to its root.
+var Packages = []string{
+	"broker",
+	"client",
+	"dsn",
+	"explain",
+	"proxy",
+	"proxy/mysql",
+	"proxy/postgres",
+	"sqlcomment",
+}
+
+// Features returns the sorted exported features of the package in dir.
+// Test files are skipped.
+func Features(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, fmt.Errorf("apicheck: %w", err)
+	}
+	fset := token.NewFileSet()
+	var out []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("apicheck: %w", err)
+		}
+		for _, decl := range f.Decls {
+			switch d := decl.(type) {
+			case *ast.FuncDecl:
+				out = append(out, funcFeatures(d)...)
+			case *ast.GenDecl:
+				out = append(out, genFeatures(d)...)
+			}
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+func funcFeatures(d *ast.FuncDecl) []string {
+	if !d.Name.IsExported() {
+		return nil
+	}
+	sig := signature(d.Type)
+	if d.Recv == nil {
+		name := d.Name.Name
+		if d.Type.TypeParams != nil {
+			name += "[" + fieldList(d.Type.TypeParams, true) + "]"
+		}
+		return []string{"func " + name + sig}
+	}
+	recv := d.Recv.List[0].Type
+	if !ast.IsExported(baseName(recv)) {
+		return nil
+	}
+	return []string{fmt.Sprintf("method (%s) %s%s", types.ExprString(recv), d.Name.Name, sig)}
+}
+
+func genFeatures(d *ast.GenDecl) []string {
+	var out []string
+	var last ast.Expr // a constant's type carries over to the specs after it
+	for _, spec := range d.Specs {
+		switch s := spec.(type) {
+		case *ast.TypeSpec:
+			out = append(out, typeFeatures(s)...)
+		case *ast.ValueSpec:
+			if s.Type != nil || len(s.Values) > 0 {
+				last = s.Type
+			}
+			kind := "var"
+			typ := s.Type
+			if d.Tok == token.CONST {
+				kind, typ = "const", last
+			}
+			for _, name := range s.Names {
+				if !name.IsExported() {
+					continue
+				}
+				line := kind + " " + name.Name
+				if typ != nil {
+					line += " " + types.ExprString(typ)
+				}
+				out = append(out, line)
+			}
+		}
+	}
+	return out
+}
+
+func typeFeatures(s *ast.TypeSpec) []string {
+	if !s.Name.IsExported() {
+		return nil
+	}
+	name := s.Name.Name
+	if s.TypeParams != nil {
+		name += "[" + fieldList(s.TypeParams, true) + "]"
+	}
+	if s.Assign.IsValid() {
+		return []string{"type " + name + " = " + types.ExprString(s.Type)}
+	}
+	switch t := s.Type.(type) {
+	case *ast.StructType:
+		out := []string{"type " + name + " struct"}
+		for _, f := range t.Fields.List {
+			if len(f.Names) == 0 {
+				if ast.IsExported(baseName(f.Type)) {
+					out = append(out, fmt.Sprintf("type %s struct, embedded %s", name, types.ExprString(f.Type)))
+				}
+				continue
+			}
+			for _, n := range f.Names {
+				if n.IsExported() {
+					out = append(out, fmt.Sprintf("type %s struct, %s %s", name, n.Name, types.ExprString(f.Type)))
+				}
+			}
+		}
+		return out
+	case *ast.InterfaceType:
+		out := []string{"type " + name + " interface"}
+		for _, m := range t.Methods.List {
+			if len(m.Names) == 0 {
+				out = append(out, fmt.Sprintf("type %s interface, embedded %s", name, types.ExprString(m.Type)))
+				continue
+			}
+			if ft, ok := m.Type.(*ast.FuncType); ok && m.Names[0].IsExported() {
+				out = append(out, fmt.Sprintf("type %s interface, %s%s", name, m.Names[0].Name, signature(ft)))
+			}
+		}
+		return out
+	}
+	return []string{"type " + name + " " + types.ExprString(s.Type)}
+}
+
+// signature renders a function type's parameters and results without
+// their names.
+func signature(ft *ast.FuncType) string {
+	sig := "(" + fieldList(ft.Params, false) + ")"
+	if ft.Results == nil || len(ft.Results.List) == 0 {
+		return sig
+	}
+	res := fieldList(ft.Results, false)
+	if len(ft.Results.List) == 1 && len(ft.Results.List[0].Names) <= 1 {
+		return sig + " " + res
+	}
+	return sig + " (" + res + ")"
+}
+
+// fieldList renders fields as a comma-separated list of types, once per
+// name; type parameters keep their names, as callers use them.
+func fieldList(fl *ast.FieldList, named bool) string {
+	if fl == nil {
+		return ""
+	}
+	var parts []string
+	for _, f := range fl.List {
+		typ := types.ExprString(f.Type)
+		if len(f.Names) == 0 {
+			parts = append(parts, typ)
+			continue
+		}
+		for _, n := range f.Names {
+			if named {
+				parts = append(parts, n.Name+" "+typ)
+			} else {
+				parts = append(parts, typ)
+			}
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// baseName returns the type name of a receiver or embedded field, without
+// pointers, packages, or type arguments.
+func baseName(e ast.Expr) string {
+	switch t := e.(type) {
+	case *ast.StarExpr:
+		return baseName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.IndexExpr:
+		return baseName(t.X)
+	case *ast.IndexListExpr:
+		return baseName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
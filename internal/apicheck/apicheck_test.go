@@ -0,0 +1,122 @@
+package apicheck_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/apicheck"
+)
+
+var update = flag.Bool("update", false, "rewrite testdata with the current API")
+
+// TestStableAPI fails when a stable package loses or changes a feature, which
+// would break its users, and when it gains one that testdata does not list
+// yet, so additions are committed on purpose. After an intended change, run
+// go test ./internal/apicheck -update.
+func TestStableAPI(t *testing.T) {
+	t.Parallel()
+
+	for _, pkg := range apicheck.Packages {
+		t.Run(pkg, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := apicheck.Features(filepath.Join("..", "..", filepath.FromSlash(pkg)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", strings.ReplaceAll(pkg, "/", "_")+".txt")
+			if *update {
+				if err := os.WriteFile(golden, []byte(strings.Join(got, "\n")+"\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			data, err := os.ReadFile(golden) //nolint:gosec // fixed testdata path
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := strings.Split(strings.TrimSpace(string(data)), "\n")
+			for _, f := range want {
+				if !slices.Contains(got, f) {
+					t.Errorf("removed or changed: %s", f)
+				}
+			}
+			for _, f := range got {
+				if !slices.Contains(want, f) {
+					t.Errorf("not in %s (run with -update to record it): %s", golden, f)
+				}
+			}
+		})
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	src := `package sample
+
+type Op int
+
+const (
+	OpA Op = iota
+	OpB
+	internal
+)
+
+const Max = 10
+
+var ErrClosed error
+
+type Broker[T any] struct {
+	Name  string
+	Inner
+	n     int
+}
+
+type Inner struct{}
+
+type Sink interface {
+	Write(p []byte) (n int, err error)
+}
+
+func New[T any](size int) *Broker[T] { return nil }
+
+func (b *Broker[T]) Publish(ev T) {}
+
+func (b *Broker[T]) drain() {}
+
+type hidden struct{}
+
+func (hidden) Exported() {}
+`
+	if err := os.WriteFile(filepath.Join(dir, "sample.go"), []byte(src), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := apicheck.Features(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"const Max",
+		"const OpA Op",
+		"const OpB Op",
+		"func New[T any](int) *Broker[T]",
+		"method (*Broker[T]) Publish(T)",
+		"type Broker[T any] struct",
+		"type Broker[T any] struct, Name string",
+		"type Broker[T any] struct, embedded Inner",
+		"type Inner struct",
+		"type Op int",
+		"type Sink interface",
+		"type Sink interface, Write([]byte) (int, error)",
+		"var ErrClosed error",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Features() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
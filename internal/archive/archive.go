@@ -0,0 +1,465 @@
+// Package archive writes captured events to daily NDJSON files, optionally
+// encrypted, and keeps the archive directory tidy: finished days are gzipped,
+// optionally uploaded, and old days deleted.
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/encrypt"
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+const (
+	prefix     = "sql-tap-"
+	dayLayout  = "2006-01-02"
+	ext        = ".ndjson"
+	gzipSuffix = ".gz"
+	encSuffix  = ".enc"
+
+	// maxLineSize bounds one archived event, as export.Verify reads them.
+	maxLineSize = 16 << 20
+
+	// uploadedSuffix marks a finished day as uploaded: an empty
+	// <archive>.uploaded file sits next to it.
+	uploadedSuffix = ".uploaded"
+)
+
+// Uploader ships a finished archive off the box, e.g. an objstore.Store.
+type Uploader interface {
+	Put(ctx context.Context, key, path string) error
+}
+
+// Option configures an Archiver.
+type Option func(*Archiver)
+
+// WithCompression gzips each day's archive once the day is over.
+func WithCompression() Option {
+	return func(a *Archiver) {
+		a.compress = true
+	}
+}
+
+// WithRetention deletes archives for days older than d.
+func WithRetention(d time.Duration) Option {
+	return func(a *Archiver) {
+		a.retention = d
+	}
+}
+
+// WithUploader uploads each finished day's archive (after compression, when
+// enabled) to u, keyed by its file name. Each day is uploaded once; failed
+// uploads are retried by the next Maintain.
+func WithUploader(u Uploader) Option {
+	return func(a *Archiver) {
+		a.upload = u
+	}
+}
+
+// WithEncryption seals new archives with AES-256-GCM under key (see package
+// encrypt); their names end in .enc. Existing unencrypted archives are left
+// as they are.
+func WithEncryption(key []byte) Option {
+	return func(a *Archiver) {
+		a.key = key
+	}
+}
+
+// WithChain writes events in a hash chain (see export.ChainWriter), so
+// edits to an archive can be detected with export.Verify. Appends after a
+// restart continue the chain of the day's file.
+func WithChain() Option {
+	return func(a *Archiver) {
+		a.chain = true
+	}
+}
+
+// Archiver appends events to sql-tap-YYYY-MM-DD.ndjson in its directory,
+// one file per UTC day of the events' start times. It is not safe for
+// concurrent use.
+type Archiver struct {
+	dir       string
+	compress  bool
+	retention time.Duration
+	upload    Uploader
+	key       []byte
+	chain     bool
+
+	day string // day of the open file; empty when none is open
+	f   *os.File
+	buf flusher
+	enc *encrypt.Writer // buf, when encrypting
+	w   export.Writer
+}
+
+// flusher is the buffering layer between the export writer and the file.
+type flusher interface {
+	io.Writer
+	Flush() error
+}
+
+// New returns an Archiver writing to dir, creating it if needed.
+func New(dir string, opts ...Option) (*Archiver, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("archive: create %s: %w", dir, err)
+	}
+	a := &Archiver{dir: dir}
+	for _, o := range opts {
+		o(a)
+	}
+	if a.key != nil {
+		// Fail at startup rather than on the first event.
+		if _, err := encrypt.NewWriter(io.Discard, a.key); err != nil {
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// Write appends ev to the archive for its day. Output is buffered until Flush
+// or Close.
+func (a *Archiver) Write(ev *tapv1.QueryEvent) error {
+	t := time.Now()
+	if ev.GetStartTime() != nil {
+		t = ev.GetStartTime().AsTime()
+	}
+	if day := t.UTC().Format(dayLayout); day != a.day {
+		if err := a.open(day); err != nil {
+			return err
+		}
+	}
+	if err := a.w.Write(ev); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+func (a *Archiver) open(day string) error {
+	if err := a.Close(); err != nil {
+		return err
+	}
+	name := prefix + day + ext
+	if a.key != nil {
+		name += encSuffix
+	}
+	path := filepath.Join(a.dir, name)
+	var head string
+	if a.chain {
+		var err error
+		if head, err = lastLineHash(path, a.key); err != nil {
+			return err
+		}
+	}
+	// Read as well, for encrypt.Append to find where the file's streams end.
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600) //nolint:gosec // path is built from the configured directory
+	if err != nil {
+		return fmt.Errorf("archive: open %s: %w", path, err)
+	}
+	var buf flusher = bufio.NewWriter(f)
+	if a.key != nil {
+		if a.enc, err = encrypt.Append(f, a.key); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("archive: %w", err)
+		}
+		buf = a.enc
+	}
+	a.day = day
+	a.f = f
+	a.buf = buf
+	a.w = export.NewWriter(a.buf, export.NDJSON)
+	if a.chain {
+		a.w = export.NewChainWriter(a.buf, head)
+	}
+	return nil
+}
+
+// lastLineHash returns the export.LineHash of the last line of the archive
+// at path, or "" if it is missing or empty.
+func lastLineHash(path string, key []byte) (string, error) {
+	if info, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) || (err == nil && info.Size() == 0) {
+		return "", nil
+	}
+	rc, err := Open(path, key)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = rc.Close() }()
+	sc := bufio.NewScanner(rc)
+	sc.Buffer(nil, maxLineSize)
+	var last []byte
+	for sc.Scan() {
+		if len(sc.Bytes()) > 0 {
+			last = append(last[:0], sc.Bytes()...)
+		}
+	}
+	// A file left unfinished by a crash is finished by the append to come.
+	if err := sc.Err(); err != nil && !errors.Is(err, encrypt.ErrUnfinished) {
+		return "", fmt.Errorf("archive: read %s: %w", path, err)
+	}
+	if last == nil {
+		return "", nil
+	}
+	return export.LineHash(last), nil
+}
+
+// Flush writes buffered events to the open file.
+func (a *Archiver) Flush() error {
+	if a.buf == nil {
+		return nil
+	}
+	if err := a.buf.Flush(); err != nil {
+		return fmt.Errorf("archive: flush %s: %w", a.f.Name(), err)
+	}
+	return nil
+}
+
+// Close flushes and closes the open file, if any, ending its encrypted
+// stream. The Archiver can keep writing afterwards; the next Write reopens
+// the file for its day.
+func (a *Archiver) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	err := a.Flush()
+	if a.enc != nil && err == nil {
+		if err = a.enc.Close(); err != nil {
+			err = fmt.Errorf("archive: close %s: %w", a.f.Name(), err)
+		}
+	}
+	if cerr := a.f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("archive: close %s: %w", a.f.Name(), cerr)
+	}
+	a.day, a.f, a.buf, a.enc, a.w = "", nil, nil, nil, nil
+	return err
+}
+
+// Maintain compresses the archives of days before now's, when compression is
+// enabled, uploads them, when an Uploader is set, and deletes the archives of
+// days older than the retention period. The file being written is never
+// touched.
+func (a *Archiver) Maintain(ctx context.Context, now time.Time) error {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return fmt.Errorf("archive: read %s: %w", a.dir, err)
+	}
+	today := now.UTC().Format(dayLayout)
+	var cutoff string
+	if a.retention > 0 {
+		cutoff = now.Add(-a.retention).UTC().Format(dayLayout)
+	}
+
+	var errs []error
+	for _, e := range entries {
+		name, marker := strings.CutSuffix(e.Name(), uploadedSuffix)
+		day, compressed, ok := parseName(name)
+		if !ok || e.IsDir() || day == a.day {
+			continue
+		}
+		path := filepath.Join(a.dir, e.Name())
+		if cutoff != "" && day < cutoff {
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, fmt.Errorf("archive: remove %s: %w", path, err))
+			}
+			continue
+		}
+		if marker || day >= today {
+			continue
+		}
+		if a.compress && !compressed {
+			dst, err := a.compressFile(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			path = dst
+			name = filepath.Base(dst)
+		}
+		if a.upload != nil {
+			if err := a.uploadOnce(ctx, name, path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// uploadOnce uploads path as name unless its marker says it already was.
+func (a *Archiver) uploadOnce(ctx context.Context, name, path string) error {
+	marker := path + uploadedSuffix
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+	if err := a.finish(path); err != nil {
+		return err
+	}
+	if err := a.upload.Put(ctx, name, path); err != nil {
+		return fmt.Errorf("archive: upload %s: %w", path, err)
+	}
+	if err := os.WriteFile(marker, nil, 0o600); err != nil {
+		return fmt.Errorf("archive: mark %s uploaded: %w", path, err)
+	}
+	return nil
+}
+
+// finish ends the last stream of the encrypted archive at path if a crash
+// left it unfinished, so that it reads back whole wherever it is shipped.
+func (a *Archiver) finish(path string) error {
+	if a.key == nil || !strings.HasSuffix(path, encSuffix) {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0) //nolint:gosec // path is an archive file in the configured directory
+	if err != nil {
+		return fmt.Errorf("archive: open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	w, err := encrypt.Append(f, a.key)
+	if err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	return nil
+}
+
+// parseName extracts the day from an archive file name.
+func parseName(name string) (day string, compressed, ok bool) {
+	rest, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return "", false, false
+	}
+	rest, _ = strings.CutSuffix(rest, encSuffix)
+	rest, compressed = strings.CutSuffix(rest, gzipSuffix)
+	day, ok = strings.CutSuffix(rest, ext)
+	if !ok {
+		return "", false, false
+	}
+	if _, err := time.Parse(dayLayout, day); err != nil {
+		return "", false, false
+	}
+	return day, compressed, true
+}
+
+// compressFile replaces path with its gzipped counterpart and returns the
+// new path: day.ndjson becomes day.ndjson.gz and day.ndjson.enc becomes
+// day.ndjson.gz.enc, compressed before it is sealed again.
+func (a *Archiver) compressFile(path string) (string, error) {
+	base, encrypted := strings.CutSuffix(path, encSuffix)
+	dstPath := base + gzipSuffix
+	if encrypted {
+		dstPath += encSuffix
+		if a.key == nil {
+			return "", fmt.Errorf("archive: compress %s: archive is encrypted but no key is configured", path)
+		}
+	}
+
+	src, err := os.Open(path) //nolint:gosec // path is an archive file in the configured directory
+	if err != nil {
+		return "", fmt.Errorf("archive: open %s: %w", path, err)
+	}
+	defer func() { _ = src.Close() }()
+	var r io.Reader = src
+	if encrypted {
+		if r, err = encrypt.NewReader(src, a.key); err != nil {
+			return "", fmt.Errorf("archive: %w", err)
+		}
+	}
+
+	dst, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600) //nolint:gosec // path is an archive file in the configured directory
+	if err != nil {
+		return "", fmt.Errorf("archive: create %s: %w", dstPath, err)
+	}
+	var (
+		out flusher = bufio.NewWriter(dst)
+		enc *encrypt.Writer
+	)
+	if encrypted {
+		if enc, err = encrypt.NewWriter(dst, a.key); err != nil {
+			_ = dst.Close()
+			return "", fmt.Errorf("archive: %w", err)
+		}
+		out = enc
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, r)
+	if errors.Is(err, encrypt.ErrUnfinished) {
+		// Left so by a crash; what was written is all there is.
+		err = nil
+	}
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	if ferr := out.Flush(); err == nil {
+		err = ferr
+	}
+	if enc != nil && err == nil {
+		err = enc.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(dstPath)
+		return "", fmt.Errorf("archive: compress %s: %w", path, err)
+	}
+	if err := os.Remove(path); err != nil {
+		return "", fmt.Errorf("archive: remove %s: %w", path, err)
+	}
+	return dstPath, nil
+}
+
+// Open returns the NDJSON content of the archive file at path, decrypting
+// (.enc, with key) and decompressing (.gz) as its name says.
+func Open(path string, key []byte) (io.ReadCloser, error) {
+	f, err := os.Open(path) //nolint:gosec // path is chosen by the caller
+	if err != nil {
+		return nil, fmt.Errorf("archive: open %s: %w", path, err)
+	}
+	rc := &readCloser{Reader: f, closers: []io.Closer{f}}
+	name, encrypted := strings.CutSuffix(path, encSuffix)
+	if encrypted {
+		if key == nil {
+			_ = rc.Close()
+			return nil, fmt.Errorf("archive: %s is encrypted; a key is required", path)
+		}
+		if rc.Reader, err = encrypt.NewReader(rc.Reader, key); err != nil {
+			_ = rc.Close()
+			return nil, fmt.Errorf("archive: %w", err)
+		}
+	}
+	if strings.HasSuffix(name, gzipSuffix) {
+		zr, err := gzip.NewReader(rc.Reader)
+		if err != nil {
+			_ = rc.Close()
+			return nil, fmt.Errorf("archive: gunzip %s: %w", path, err)
+		}
+		rc.Reader = zr
+		rc.closers = append(rc.closers, zr)
+	}
+	return rc, nil
+}
+
+type readCloser struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *readCloser) Close() error {
+	var errs []error
+	for _, c := range slices.Backward(r.closers) {
+		errs = append(errs, c.Close())
+	}
+	return errors.Join(errs...)
+}
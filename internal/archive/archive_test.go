@@ -0,0 +1,346 @@
+package archive_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/archive"
+	"github.com/mickamy/sql-tap/internal/encrypt"
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+func event(id string, start time.Time) *tapv1.QueryEvent {
+	return &tapv1.QueryEvent{Id: id, Query: "SELECT 1", StartTime: timestamppb.New(start)}
+}
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+func TestArchiver_Write(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	a, err := archive.New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day1 := time.Date(2026, 3, 1, 23, 59, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Minute)
+	for _, ev := range []*tapv1.QueryEvent{event("1", day1), event("2", day1), event("3", day2)} {
+		if err := a.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"sql-tap-2026-03-01.ndjson", "sql-tap-2026-03-02.ndjson"}
+	if got := files(t, dir); !slices.Equal(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, want[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("day 1 has %d records, want 2", lines)
+	}
+
+	// Reopening appends rather than truncating.
+	if err := a.Write(event("4", day2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, want[1]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("day 2 has %d records, want 2", lines)
+	}
+}
+
+func TestArchiver_Maintain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"sql-tap-2026-02-01.ndjson.gz",
+		"sql-tap-2026-02-27.ndjson",
+		"sql-tap-2026-02-28.ndjson",
+		"notes.txt",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"id":"1"}`+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a, err := archive.New(dir, archive.WithCompression(), archive.WithRetention(7*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := a.Write(event("1", today)); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Maintain(t.Context(), today); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"notes.txt",
+		"sql-tap-2026-02-27.ndjson.gz",
+		"sql-tap-2026-02-28.ndjson.gz",
+		"sql-tap-2026-03-01.ndjson",
+	}
+	if got := files(t, dir); !slices.Equal(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "sql-tap-2026-02-28.ndjson.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"id":"1"}`+"\n" {
+		t.Errorf("decompressed = %q", data)
+	}
+}
+
+// uploader records uploaded keys and fails the first failN calls.
+type uploader struct {
+	keys  []string
+	failN int
+}
+
+func (u *uploader) Put(_ context.Context, key, path string) error {
+	if u.failN > 0 {
+		u.failN--
+		return errors.New("unavailable")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return err
+	}
+	u.keys = append(u.keys, key)
+	return nil
+}
+
+func TestArchiver_MaintainUpload(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for _, name := range []string{
+		"sql-tap-2026-02-20.ndjson.gz",
+		"sql-tap-2026-02-20.ndjson.gz.uploaded",
+		"sql-tap-2026-02-28.ndjson",
+		"sql-tap-2026-03-01.ndjson",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(`{"id":"1"}`+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	u := &uploader{failN: 1}
+	a, err := archive.New(dir, archive.WithCompression(), archive.WithRetention(7*24*time.Hour), archive.WithUploader(u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	today := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := a.Maintain(t.Context(), today); err == nil {
+		t.Fatal("Maintain succeeded, want the upload error")
+	}
+	if len(u.keys) != 0 {
+		t.Fatalf("uploaded %v after a failure, want nothing", u.keys)
+	}
+	for range 2 {
+		if err := a.Maintain(t.Context(), today); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if want := []string{"sql-tap-2026-02-28.ndjson.gz"}; !slices.Equal(u.keys, want) {
+		t.Errorf("uploaded %v, want %v", u.keys, want)
+	}
+	want := []string{
+		"sql-tap-2026-02-28.ndjson.gz",
+		"sql-tap-2026-02-28.ndjson.gz.uploaded",
+		"sql-tap-2026-03-01.ndjson",
+	}
+	if got := files(t, dir); !slices.Equal(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+}
+
+func readArchive(t *testing.T, path string, key []byte) string {
+	t.Helper()
+	rc, err := archive.Open(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rc.Close() }()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestArchiver_Encryption(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{7}, 32)
+	a, err := archive.New(dir, archive.WithCompression(), archive.WithEncryption(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	day1 := time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	for _, ev := range []*tapv1.QueryEvent{event("1", day1), event("2", day2)} {
+		ev.Query = "SELECT secret"
+		if err := a.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Maintain(t.Context(), day2); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"sql-tap-2026-02-28.ndjson.gz.enc", "sql-tap-2026-03-01.ndjson.enc"}
+	if got := files(t, dir); !slices.Equal(got, want) {
+		t.Fatalf("files = %v, want %v", got, want)
+	}
+	for _, name := range want {
+		path := filepath.Join(dir, name)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(raw, []byte("secret")) {
+			t.Errorf("%s contains plaintext", name)
+		}
+		if got := readArchive(t, path, key); !strings.Contains(got, `"query":"SELECT secret"`) {
+			t.Errorf("%s decrypts to %q", name, got)
+		}
+		if _, err := archive.Open(path, nil); err == nil {
+			t.Errorf("Open(%s) without a key succeeded", name)
+		}
+	}
+}
+
+func TestArchiver_EncryptionAfterCrash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{7}, 32)
+	day := time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(dir, "sql-tap-2026-02-28.ndjson.enc")
+
+	// The first run flushes and dies without closing the file.
+	crashed, err := archive.New(dir, archive.WithEncryption(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crashed.Write(event("1", day)); err != nil {
+		t.Fatal(err)
+	}
+	if err := crashed.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	rc, err := archive.Open(path, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(rc); !errors.Is(err, encrypt.ErrUnfinished) {
+		t.Errorf("reading the file being written: error = %v, want ErrUnfinished", err)
+	}
+	_ = rc.Close()
+
+	// The next one ships it whole after the day is over.
+	u := &uploader{}
+	a, err := archive.New(dir, archive.WithEncryption(key), archive.WithUploader(u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Maintain(t.Context(), day.Add(24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if len(u.keys) != 1 {
+		t.Fatalf("uploaded %v, want the crashed day", u.keys)
+	}
+	if got := readArchive(t, path, key); !strings.Contains(got, `"id":"1"`) {
+		t.Errorf("uploaded archive reads back as %q", got)
+	}
+}
+
+func TestArchiver_Chain(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	key := bytes.Repeat([]byte{7}, 32)
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	// Two runs append to the same day's file; the second continues the chain.
+	for _, id := range []string{"1", "2"} {
+		a, err := archive.New(dir, archive.WithEncryption(key), archive.WithChain())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, ev := range []*tapv1.QueryEvent{event(id+"a", day), event(id+"b", day)} {
+			if err := a.Write(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data := readArchive(t, filepath.Join(dir, "sql-tap-2026-03-01.ndjson.enc"), key)
+	rep, err := export.Verify(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if rep.Chained != 4 || rep.Unchained != 0 {
+		t.Errorf("report = %+v, want 4 chained lines", rep)
+	}
+}
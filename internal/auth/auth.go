@@ -0,0 +1,215 @@
+// Package auth guards the TapService gRPC API with bearer tokens, each
+// granting a role: viewer (watch and stats), analyst (plus EXPLAIN), or admin
+// (plus runtime control).
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	reflectionv1alphapb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// Role is an access level; each role includes the ones below it.
+type Role int
+
+const (
+	RoleViewer Role = iota + 1
+	RoleAnalyst
+	RoleAdmin
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleAnalyst:
+		return "analyst"
+	case RoleAdmin:
+		return "admin"
+	}
+	return fmt.Sprintf("Role(%d)", int(r))
+}
+
+// ParseRole parses "viewer", "analyst", or "admin".
+func ParseRole(s string) (Role, error) {
+	for _, r := range []Role{RoleViewer, RoleAnalyst, RoleAdmin} {
+		if strings.EqualFold(s, r.String()) {
+			return r, nil
+		}
+	}
+	return 0, fmt.Errorf("auth: unknown role %q (want viewer, analyst, or admin)", s)
+}
+
+// methodRoles is the role each RPC requires. Methods missing from the map
+// require admin, so new RPCs are locked down until classified.
+var methodRoles = map[string]Role{
+	tapv1.TapService_Watch_FullMethodName:        RoleViewer,
+	tapv1.TapService_Info_FullMethodName:         RoleViewer,
+	tapv1.TapService_Stats_FullMethodName:        RoleViewer,
+	tapv1.TapService_Transactions_FullMethodName: RoleViewer,
+	tapv1.TapService_Routes_FullMethodName:       RoleViewer,
+	tapv1.TapService_Tenants_FullMethodName:      RoleViewer,
+	tapv1.TapService_Databases_FullMethodName:    RoleViewer,
+	tapv1.TapService_Statements_FullMethodName:   RoleViewer,
+	tapv1.TapService_Annotate_FullMethodName:     RoleViewer, // shared notes, not control
+	tapv1.TapService_Query_FullMethodName:        RoleViewer,
+	tapv1.TapService_Explain_FullMethodName:      RoleAnalyst,
+	tapv1.TapService_SetVerbose_FullMethodName:   RoleAdmin,
+	tapv1.TapService_Kill_FullMethodName:         RoleAdmin,
+	tapv1.TapService_Config_FullMethodName:       RoleAdmin, // reveals the deployment's setup
+
+	// Reflection describes the API, not the traffic, but still needs a token.
+	reflectionpb.ServerReflection_ServerReflectionInfo_FullMethodName:        RoleViewer,
+	reflectionv1alphapb.ServerReflection_ServerReflectionInfo_FullMethodName: RoleViewer,
+}
+
+// publicMethods need no token, so load balancers and Kubernetes probes can
+// check health without one.
+var publicMethods = map[string]bool{
+	healthpb.Health_Check_FullMethodName: true,
+	healthpb.Health_List_FullMethodName:  true,
+	healthpb.Health_Watch_FullMethodName: true,
+}
+
+// Public reports whether the gRPC method fullMethod is served without a
+// token.
+func Public(fullMethod string) bool {
+	return publicMethods[fullMethod]
+}
+
+// Required returns the role needed to call the gRPC method fullMethod.
+func Required(fullMethod string) Role {
+	if r, ok := methodRoles[fullMethod]; ok {
+		return r
+	}
+	return RoleAdmin
+}
+
+// Authorizer maps bearer tokens to roles.
+type Authorizer struct {
+	tokens []grant
+}
+
+type grant struct {
+	token []byte
+	role  Role
+}
+
+// New returns an Authorizer accepting the given tokens.
+func New(tokens map[string]Role) *Authorizer {
+	a := &Authorizer{}
+	for tok, r := range tokens {
+		a.tokens = append(a.tokens, grant{token: []byte(tok), role: r})
+	}
+	return a
+}
+
+// role looks up the role for the request's bearer token.
+func (a *Authorizer) role(ctx context.Context) (Role, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var tok string
+	for _, v := range md.Get("authorization") {
+		if t, ok := strings.CutPrefix(v, "Bearer "); ok {
+			tok = t
+			break
+		}
+	}
+	if tok == "" {
+		return 0, status.Error(codes.Unauthenticated, "missing bearer token")
+	}
+	role, ok := a.Role(tok)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return role, nil
+}
+
+// Role returns the role token grants, for APIs besides gRPC.
+func (a *Authorizer) Role(token string) (Role, bool) {
+	// Compare against every token so timing does not reveal which matched.
+	var role Role
+	for _, g := range a.tokens {
+		if subtle.ConstantTimeCompare(g.token, []byte(token)) == 1 {
+			role = g.role
+		}
+	}
+	return role, role != 0
+}
+
+// Require rejects the call ctx belongs to unless its token grants need,
+// for parts of a method that need more than the method's role. what names
+// them in the error. A nil Authorizer allows everything.
+func (a *Authorizer) Require(ctx context.Context, need Role, what string) error {
+	if a == nil {
+		return nil
+	}
+	role, err := a.role(ctx)
+	if err != nil {
+		return err
+	}
+	if role < need {
+		return status.Errorf(codes.PermissionDenied, "%s requires the %s role; token has %s", what, need, role)
+	}
+	return nil
+}
+
+func (a *Authorizer) authorize(ctx context.Context, method string) error {
+	if Public(method) {
+		return nil
+	}
+	role, err := a.role(ctx)
+	if err != nil {
+		return err
+	}
+	if need := Required(method); role < need {
+		return status.Errorf(codes.PermissionDenied, "%s requires the %s role; token has %s", method, need, role)
+	}
+	return nil
+}
+
+// UnaryInterceptor rejects unary calls whose token lacks the method's role.
+func (a *Authorizer) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := a.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor rejects streams whose token lacks the method's role.
+func (a *Authorizer) StreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := a.authorize(ss.Context(), info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+// Token returns call credentials sending token as a bearer token. They are
+// allowed over plaintext connections, which sql-tap uses today; put the
+// gRPC port behind TLS (or an SSH tunnel) when it crosses a network.
+func Token(token string) credentials.PerRPCCredentials {
+	return bearer(token)
+}
+
+type bearer string
+
+func (b bearer) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(b)}, nil
+}
+
+func (bearer) RequireTransportSecurity() bool { return false }
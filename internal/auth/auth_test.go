@@ -0,0 +1,93 @@
+package auth_test
+
+import (
+	"testing"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+)
+
+func TestParseRole(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    auth.Role
+		wantErr bool
+	}{
+		{in: "viewer", want: auth.RoleViewer},
+		{in: "Analyst", want: auth.RoleAnalyst},
+		{in: "ADMIN", want: auth.RoleAdmin},
+		{in: "root", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := auth.ParseRole(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseRole(%q) = %v, want error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("ParseRole(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequired(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method string
+		want   auth.Role
+	}{
+		{method: tapv1.TapService_Watch_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Stats_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Explain_FullMethodName, want: auth.RoleAnalyst},
+		{method: tapv1.TapService_Annotate_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Query_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Routes_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Tenants_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Databases_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_Statements_FullMethodName, want: auth.RoleViewer},
+		{method: tapv1.TapService_SetVerbose_FullMethodName, want: auth.RoleAdmin},
+		{method: tapv1.TapService_Kill_FullMethodName, want: auth.RoleAdmin},
+		{method: tapv1.TapService_Config_FullMethodName, want: auth.RoleAdmin},
+		{method: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", want: auth.RoleViewer},
+		{method: "/tap.v1.TapService/SomethingNew", want: auth.RoleAdmin},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			t.Parallel()
+			if got := auth.Required(tt.method); got != tt.want {
+				t.Errorf("Required(%q) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublic(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		method string
+		want   bool
+	}{
+		{method: "/grpc.health.v1.Health/Check", want: true},
+		{method: "/grpc.health.v1.Health/Watch", want: true},
+		{method: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", want: false},
+		{method: tapv1.TapService_Watch_FullMethodName, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method, func(t *testing.T) {
+			t.Parallel()
+			if got := auth.Public(tt.method); got != tt.want {
+				t.Errorf("Public(%q) = %v, want %v", tt.method, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package auth
+
+import (
+	"os"
+	"os/user"
+)
+
+// Identity returns user@host for the current process, which clients send
+// with Watch so the daemon can tell watchers apart. Either half falls back
+// to "unknown" when it cannot be determined.
+func Identity() string {
+	name := "unknown"
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	} else if v := os.Getenv("USER"); v != "" {
+		name = v
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+	return name + "@" + host
+}
@@ -0,0 +1,246 @@
+// Package autoexplain runs EXPLAIN for slow statements as they are captured,
+// so their plans are at hand without asking for them from the TUI. Each
+// query fingerprint is explained at most once per cache TTL on each upstream,
+// and plans are returned as advisory events, with any indexes the
+// indexadvisor package suggests.
+//
+// EXPLAIN ANALYZE runs the statement again, so it is only used, when
+// enabled, for plain SELECT statements: no INTO, row locks, or WITH, whose
+// CTEs may modify data. Functions with side effects called by a SELECT still
+// run.
+package autoexplain
+
+import (
+	"context"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/advisory"
+	"github.com/mickamy/sql-tap/internal/indexadvisor"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Tag is added to plan events.
+const Tag = "auto-explain"
+
+// Defs returns the auto-explain tag with its TUI color.
+func Defs() []tagger.Def {
+	return []tagger.Def{{Name: Tag, Color: "33"}}
+}
+
+// Defaults for the Explainer options.
+const (
+	DefaultCacheTTL = 10 * time.Minute
+	DefaultTimeout  = 5 * time.Second
+)
+
+// queueSize bounds the statements waiting for EXPLAIN; slow statements
+// arriving while it is full are skipped.
+const queueSize = 64
+
+// maxCached bounds the cache on workloads with unbounded distinct
+// fingerprints.
+const maxCached = 10000
+
+var (
+	// Statements EXPLAIN accepts on Postgres, MySQL, and TiDB.
+	reExplainable = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*(?:SELECT|INSERT|UPDATE|DELETE|REPLACE|WITH|VALUES|TABLE)\b`)
+
+	reSelect = regexp.MustCompile(`(?is)^\s*(?:/\*.*?\*/\s*)*SELECT\b`)
+	// SELECT ... INTO creates a table or sets variables, row locks block
+	// other writers, and further statements could be anything.
+	reUnsafe = regexp.MustCompile(`(?is)\bINTO\b|\bFOR\s+(?:NO\s+KEY\s+UPDATE|UPDATE|KEY\s+SHARE|SHARE)\b|\bLOCK\s+IN\s+SHARE\s+MODE\b|;\s*\S`)
+)
+
+// Analyzable reports whether query is a plain SELECT, safe to run again for
+// EXPLAIN ANALYZE.
+func Analyzable(query string) bool {
+	return reSelect.MatchString(query) && !reUnsafe.MatchString(query)
+}
+
+// Runner runs EXPLAIN; *explain.Client is one. Runners that are also an
+// indexadvisor.Planner get index suggestions on their plans.
+type Runner interface {
+	Run(ctx context.Context, mode explain.Mode, query string, args []string) (*explain.Result, error)
+}
+
+// Option configures an Explainer.
+type Option func(*Explainer)
+
+// WithAnalyze uses EXPLAIN ANALYZE for statements Analyzable accepts.
+func WithAnalyze() Option {
+	return func(x *Explainer) {
+		x.analyze = true
+	}
+}
+
+// WithCacheTTL sets how long a fingerprint's plan is kept before a slow
+// statement is explained again.
+func WithCacheTTL(d time.Duration) Option {
+	return func(x *Explainer) {
+		x.ttl = d
+	}
+}
+
+// WithTimeout bounds each EXPLAIN.
+func WithTimeout(d time.Duration) Option {
+	return func(x *Explainer) {
+		x.timeout = d
+	}
+}
+
+type key struct {
+	upstream    string
+	fingerprint string
+}
+
+// Explainer explains slow statements in the background. It is safe for
+// concurrent use.
+type Explainer struct {
+	threshold time.Duration
+	runners   map[string]Runner
+	analyze   bool
+	ttl       time.Duration
+	timeout   time.Duration
+
+	jobs chan proxy.Event
+
+	mu     sync.Mutex
+	cached map[key]time.Time // when each fingerprint was last queued
+	nextID uint64
+}
+
+// New returns an Explainer of statements at least threshold slow, run with
+// the runner of each event's upstream ("" for the default one).
+func New(threshold time.Duration, runners map[string]Runner, opts ...Option) *Explainer {
+	x := &Explainer{
+		threshold: threshold,
+		runners:   runners,
+		ttl:       DefaultCacheTTL,
+		timeout:   DefaultTimeout,
+		jobs:      make(chan proxy.Event, queueSize),
+		cached:    make(map[key]time.Time),
+	}
+	for _, opt := range opts {
+		opt(x)
+	}
+	return x
+}
+
+// Observe queues ev for EXPLAIN if it is a slow, successful statement whose
+// fingerprint was not explained within the cache TTL. It never blocks: a
+// statement arriving while the queue is full is skipped.
+func (x *Explainer) Observe(ev proxy.Event, now time.Time) {
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel,
+		proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+		return
+	}
+	if ev.Duration < x.threshold || ev.Error != "" || ev.Fingerprint == "" || !reExplainable.MatchString(ev.Query) {
+		return
+	}
+	if _, ok := x.runners[ev.Upstream]; !ok {
+		return
+	}
+
+	k := key{upstream: ev.Upstream, fingerprint: ev.Fingerprint}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if at, ok := x.cached[k]; ok && now.Sub(at) < x.ttl {
+		return
+	}
+	if len(x.cached) >= maxCached {
+		x.evict(now)
+		if len(x.cached) >= maxCached {
+			return
+		}
+	}
+	select {
+	case x.jobs <- ev:
+		x.cached[k] = now
+	default:
+	}
+}
+
+// evict drops expired cache entries. x.mu must be held.
+func (x *Explainer) evict(now time.Time) {
+	for k, at := range x.cached {
+		if now.Sub(at) >= x.ttl {
+			delete(x.cached, k)
+		}
+	}
+}
+
+// Run explains queued statements until ctx is done, passing each plan to
+// publish as an advisory event. Failed EXPLAINs are logged, and the
+// fingerprint is not retried until the cache TTL passes; panics are
+// published as proxy.Recovered diagnostics.
+func (x *Explainer) Run(ctx context.Context, publish func(proxy.Event)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-x.jobs:
+			x.run(ctx, ev, publish)
+		}
+	}
+}
+
+// run explains ev, publishing a diagnostic in place of the plan if the
+// driver panics, so one statement does not stop the rest being explained.
+func (x *Explainer) run(ctx context.Context, ev proxy.Event, publish func(proxy.Event)) {
+	defer func() {
+		if v := recover(); v != nil {
+			diag, _ := proxy.Recovered("auto-explain", v, ev)
+			publish(diag)
+		}
+	}()
+	if adv, ok := x.explain(ctx, ev); ok {
+		publish(adv)
+	}
+}
+
+func (x *Explainer) explain(ctx context.Context, ev proxy.Event) (proxy.Event, bool) {
+	mode := explain.Explain
+	if x.analyze && Analyzable(ev.Query) {
+		mode = explain.Analyze
+	}
+	ctx, cancel := context.WithTimeout(ctx, x.timeout)
+	defer cancel()
+	res, err := x.runners[ev.Upstream].Run(ctx, mode, ev.Query, ev.Args)
+	if err != nil {
+		slog.Debug("auto-explain failed", "fingerprint", ev.Fingerprint, "upstream", ev.Upstream, "err", err)
+		return proxy.Event{}, false
+	}
+
+	tags := append([]string{Tag}, advisory.Plan(res)...)
+	var indexes []string
+	if p, ok := x.runners[ev.Upstream].(indexadvisor.Planner); ok {
+		if indexes = indexadvisor.Advise(ctx, p, ev.Query, ev.Args); len(indexes) > 0 {
+			tags = append(tags, indexadvisor.Tag)
+		}
+	}
+
+	x.mu.Lock()
+	x.nextID++
+	id := x.nextID
+	x.mu.Unlock()
+	return proxy.Event{
+		ID:          "plan-" + strconv.FormatUint(id, 10),
+		Op:          proxy.OpAdvisory,
+		Upstream:    ev.Upstream,
+		Query:       ev.Query,
+		Fingerprint: ev.Fingerprint,
+		Args:        ev.Args,
+		StartTime:   time.Now(),
+		Duration:    res.Duration,
+		Tags:        tags,
+		Plan:        &proxy.Plan{ForID: ev.ID, Analyze: mode == explain.Analyze, Text: res.Plan, Indexes: indexes},
+	}, true
+}
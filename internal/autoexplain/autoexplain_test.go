@@ -0,0 +1,212 @@
+package autoexplain_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/advisory"
+	"github.com/mickamy/sql-tap/internal/autoexplain"
+	"github.com/mickamy/sql-tap/internal/indexadvisor"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+type fakeRunner struct {
+	mu    sync.Mutex
+	calls []string
+	plan  string
+	fail  string // query to fail
+	panic string // query to panic on
+}
+
+func (f *fakeRunner) Run(_ context.Context, _ explain.Mode, query string, _ []string) (*explain.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, query)
+	if query == f.fail {
+		return nil, errors.New("syntax error")
+	}
+	if query == f.panic {
+		panic("driver bug")
+	}
+	return &explain.Result{Plan: f.plan, Duration: time.Millisecond}, nil
+}
+
+func slow(id, query string) proxy.Event {
+	return proxy.Event{ID: id, Op: proxy.OpQuery, Query: query, Fingerprint: query, Duration: time.Second}
+}
+
+// drain runs x until it published want events, or fails after a second.
+func drain(t *testing.T, x *autoexplain.Explainer, want int) []proxy.Event {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+	var (
+		mu  sync.Mutex
+		out []proxy.Event
+	)
+	done := make(chan struct{})
+	go func() {
+		x.Run(ctx, func(ev proxy.Event) {
+			mu.Lock()
+			defer mu.Unlock()
+			out = append(out, ev)
+			if len(out) == want {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+	<-done
+	mu.Lock()
+	defer mu.Unlock()
+	if len(out) != want {
+		t.Fatalf("got %d plans, want %d: %+v", len(out), want, out)
+	}
+	return out
+}
+
+func TestExplainer(t *testing.T) {
+	t.Parallel()
+
+	r := &fakeRunner{plan: "Sort  (cost=1.00..2.00)\n  Sort Method: external merge  Disk: 1024kB"}
+	x := autoexplain.New(500*time.Millisecond, map[string]autoexplain.Runner{"": r}, autoexplain.WithAnalyze())
+	now := time.Now()
+
+	x.Observe(slow("1", "SELECT * FROM orders ORDER BY total"), now)
+	x.Observe(slow("2", "SELECT * FROM orders ORDER BY total"), now) // cached
+	x.Observe(slow("3", "UPDATE orders SET total = 0"), now)
+	fast := slow("4", "SELECT 1")
+	fast.Duration = time.Millisecond
+	x.Observe(fast, now)
+	failed := slow("5", "SELECT 2")
+	failed.Error = "canceled"
+	x.Observe(failed, now)
+	x.Observe(slow("6", "SET search_path = app"), now)
+	other := slow("7", "SELECT 3")
+	other.Upstream = "replica" // no runner
+	x.Observe(other, now)
+
+	plans := drain(t, x, 2)
+	p := plans[0]
+	if p.Op != proxy.OpAdvisory || p.Plan == nil || p.Plan.ForID != "1" || !p.Plan.Analyze || p.Plan.Text != r.plan {
+		t.Fatalf("unexpected plan event: %+v (plan %+v)", p, p.Plan)
+	}
+	if len(p.Tags) != 2 || p.Tags[0] != autoexplain.Tag || p.Tags[1] != advisory.TempDisk {
+		t.Errorf("tags = %v, want %s and %s", p.Tags, autoexplain.Tag, advisory.TempDisk)
+	}
+	if u := plans[1].Plan; u.ForID != "3" || u.Analyze {
+		t.Errorf("UPDATE plan = %+v, want plain EXPLAIN for 3", u)
+	}
+
+	// After the TTL the fingerprint is explained again.
+	x.Observe(slow("8", "SELECT * FROM orders ORDER BY total"), now.Add(autoexplain.DefaultCacheTTL))
+	if plans := drain(t, x, 1); plans[0].Plan.ForID != "8" {
+		t.Errorf("plan for %s, want 8", plans[0].Plan.ForID)
+	}
+}
+
+func TestExplainer_Failure(t *testing.T) {
+	t.Parallel()
+
+	r := &fakeRunner{fail: "SELECT $1"}
+	x := autoexplain.New(time.Millisecond, map[string]autoexplain.Runner{"": r})
+	now := time.Now()
+	x.Observe(slow("1", "SELECT $1"), now)
+	x.Observe(slow("2", "SELECT 1"), now)
+	if plans := drain(t, x, 1); plans[0].Plan.ForID != "2" || plans[0].Plan.Analyze {
+		t.Fatalf("unexpected plan: %+v", plans[0].Plan)
+	}
+
+	// The failed fingerprint is not retried within the TTL.
+	x.Observe(slow("3", "SELECT $1"), now)
+	x.Observe(slow("4", "SELECT 2"), now)
+	if plans := drain(t, x, 1); plans[0].Plan.ForID != "4" {
+		t.Fatalf("plan for %s, want 4", plans[0].Plan.ForID)
+	}
+	if len(r.calls) != 3 {
+		t.Errorf("ran %d EXPLAINs, want 3", len(r.calls))
+	}
+}
+
+func TestExplainer_Panic(t *testing.T) {
+	t.Parallel()
+
+	r := &fakeRunner{panic: "SELECT 1"}
+	x := autoexplain.New(time.Millisecond, map[string]autoexplain.Runner{"": r})
+	now := time.Now()
+	x.Observe(slow("1", "SELECT 1"), now)
+	x.Observe(slow("2", "SELECT 2"), now)
+
+	out := drain(t, x, 2)
+	if out[0].Panic == nil || out[0].Panic.Where != "auto-explain" || out[0].Query != "SELECT 1" {
+		t.Fatalf("expected a panic diagnostic for the first statement, got %+v", out[0])
+	}
+	if out[1].Plan == nil || out[1].Plan.ForID != "2" {
+		t.Fatalf("expected the second statement explained, got %+v", out[1])
+	}
+}
+
+// plannerRunner is a fakeRunner that also returns JSON plans.
+type plannerRunner struct {
+	fakeRunner
+	json string
+}
+
+func (p *plannerRunner) PlanJSON(context.Context, string, []string) ([]byte, error) {
+	return []byte(p.json), nil
+}
+
+func TestExplainer_Indexes(t *testing.T) {
+	t.Parallel()
+
+	r := &plannerRunner{
+		fakeRunner: fakeRunner{plan: "Seq Scan on orders  (cost=0.00..1834.00 rows=10 width=8)"},
+		json:       `[{"Plan": {"Node Type": "Seq Scan", "Relation Name": "orders", "Total Cost": 1834, "Filter": "(customer_id = 42)"}}]`,
+	}
+	x := autoexplain.New(time.Millisecond, map[string]autoexplain.Runner{"": r})
+	x.Observe(slow("1", "SELECT * FROM orders WHERE customer_id = 42"), time.Now())
+
+	p := drain(t, x, 1)[0]
+	if want := []string{"CREATE INDEX CONCURRENTLY ON orders (customer_id);"}; !slices.Equal(p.Plan.Indexes, want) {
+		t.Errorf("indexes = %q, want %q", p.Plan.Indexes, want)
+	}
+	if !slices.Contains(p.Tags, indexadvisor.Tag) {
+		t.Errorf("tags = %v, want %s", p.Tags, indexadvisor.Tag)
+	}
+}
+
+func TestAnalyzable(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		query string
+		want  bool
+	}{
+		{query: "SELECT * FROM users WHERE id = $1", want: true},
+		{query: "  select count(*) from orders", want: true},
+		{query: "/* app */ SELECT 1", want: true},
+		{query: "SELECT * INTO archive FROM orders", want: false},
+		{query: "SELECT * FROM jobs FOR UPDATE SKIP LOCKED", want: false},
+		{query: "SELECT * FROM jobs FOR NO KEY UPDATE", want: false},
+		{query: "SELECT * FROM jobs LOCK IN SHARE MODE", want: false},
+		{query: "WITH d AS (DELETE FROM jobs RETURNING *) SELECT * FROM d", want: false},
+		{query: "SELECT 1; DROP TABLE users", want: false},
+		{query: "DELETE FROM users", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			t.Parallel()
+
+			if got := autoexplain.Analyzable(tt.query); got != tt.want {
+				t.Errorf("Analyzable(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
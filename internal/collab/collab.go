@@ -0,0 +1,132 @@
+// Package collab shares state between the people watching one daemon: who
+// is connected, and notes they attach to captured events.
+package collab
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+)
+
+// Annotation is a note one watcher attached to an event.
+type Annotation struct {
+	EventID string
+	Text    string // empty when the note was cleared
+	Author  string
+	Time    time.Time
+}
+
+// Update is pushed to every member when an annotation changes or someone
+// joins or leaves. Exactly one field is set.
+type Update struct {
+	Annotation *Annotation
+	Presence   []string // identities of all members, in join order
+}
+
+// updateBuffer is how many updates a member may fall behind before further
+// ones are dropped for it.
+const updateBuffer = 64
+
+// Hub tracks members and annotations, keeping the most recent capacity
+// annotations. Members are the subscribers of a broker of updates.
+type Hub struct {
+	mu          sync.Mutex // also orders membership changes with their presence updates
+	capacity    int
+	annotations map[string]Annotation // keyed by event ID
+	order       []string              // annotated event IDs, oldest first
+	updates     *broker.Broker[Update]
+}
+
+// New creates a Hub that keeps up to capacity annotations.
+func New(capacity int) *Hub {
+	return &Hub{
+		capacity:    max(capacity, 1),
+		annotations: make(map[string]Annotation),
+		updates:     broker.New[Update](updateBuffer),
+	}
+}
+
+// Join adds a member and announces the new presence list to everyone,
+// including the new member. The returned channel receives updates until
+// leave is called; leave is idempotent. A member that falls behind misses
+// updates rather than stalling the others.
+func (h *Hub) Join(client string) (<-chan Update, func()) {
+	h.mu.Lock()
+	ch, unsubscribe := h.updates.Subscribe(broker.WithName("collab"), broker.WithClient(client))
+	h.broadcastPresence()
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+
+			unsubscribe()
+			h.broadcastPresence()
+		})
+	}
+}
+
+// Annotate records a, replacing any earlier note on the same event (or
+// removing it when a.Text is empty), and pushes it to every member.
+func (h *Hub) Annotate(a Annotation) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.annotations[a.EventID]; ok {
+		h.order = slices.DeleteFunc(h.order, func(id string) bool { return id == a.EventID })
+		delete(h.annotations, a.EventID)
+	}
+	if a.Text != "" {
+		h.annotations[a.EventID] = a
+		h.order = append(h.order, a.EventID)
+		if len(h.order) > h.capacity {
+			delete(h.annotations, h.order[0])
+			h.order = h.order[1:]
+		}
+	}
+	h.broadcast(Update{Annotation: &a})
+}
+
+// Annotations returns the current annotations, oldest first.
+func (h *Hub) Annotations() []Annotation {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	out := make([]Annotation, len(h.order))
+	for i, id := range h.order {
+		out[i] = h.annotations[id]
+	}
+	return out
+}
+
+// Members returns the identities of everyone who has joined, in join order.
+func (h *Hub) Members() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.presence()
+}
+
+// presence lists the members' identities; broker stats are ordered by
+// subscription, that is, join order.
+func (h *Hub) presence() []string {
+	subs := h.updates.Stats()
+	out := make([]string, len(subs))
+	for i, sub := range subs {
+		out[i] = sub.Client
+	}
+	return out
+}
+
+func (h *Hub) broadcastPresence() {
+	h.broadcast(Update{Presence: h.presence()})
+}
+
+// broadcast sends u to every member without blocking. The caller holds h.mu.
+func (h *Hub) broadcast(u Update) {
+	h.updates.Publish(u)
+}
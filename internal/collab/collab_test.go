@@ -0,0 +1,90 @@
+package collab_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/collab"
+)
+
+func nextUpdate(t *testing.T, ch <-chan collab.Update) collab.Update {
+	t.Helper()
+
+	select {
+	case u := <-ch:
+		return u
+	default:
+		t.Fatal("expected an update")
+	}
+	return collab.Update{}
+}
+
+func TestHub_Presence(t *testing.T) {
+	t.Parallel()
+
+	h := collab.New(10)
+	alice, leaveAlice := h.Join("alice@laptop")
+	if u := nextUpdate(t, alice); !slices.Equal(u.Presence, []string{"alice@laptop"}) {
+		t.Fatalf("presence = %v", u.Presence)
+	}
+
+	bob, leaveBob := h.Join("bob@ci")
+	want := []string{"alice@laptop", "bob@ci"}
+	for _, ch := range []<-chan collab.Update{alice, bob} {
+		if u := nextUpdate(t, ch); !slices.Equal(u.Presence, want) {
+			t.Fatalf("presence = %v, want %v", u.Presence, want)
+		}
+	}
+
+	leaveBob()
+	leaveBob()
+	if u := nextUpdate(t, alice); !slices.Equal(u.Presence, []string{"alice@laptop"}) {
+		t.Fatalf("presence after leave = %v", u.Presence)
+	}
+	if _, ok := <-bob; ok {
+		t.Fatal("expected the channel to be closed after leaving")
+	}
+	leaveAlice()
+	if got := h.Members(); len(got) != 0 {
+		t.Fatalf("members = %v, want none", got)
+	}
+}
+
+func TestHub_Annotate(t *testing.T) {
+	t.Parallel()
+
+	h := collab.New(2)
+	ch, leave := h.Join("bob@ci")
+	defer leave()
+	nextUpdate(t, ch) // presence
+
+	h.Annotate(collab.Annotation{EventID: "1", Text: "slow", Author: "alice@laptop"})
+	u := nextUpdate(t, ch)
+	if u.Annotation == nil || u.Annotation.EventID != "1" || u.Annotation.Text != "slow" {
+		t.Fatalf("unexpected update: %+v", u)
+	}
+
+	h.Annotate(collab.Annotation{EventID: "2", Text: "n+1"})
+	h.Annotate(collab.Annotation{EventID: "1", Text: "very slow"})
+	h.Annotate(collab.Annotation{EventID: "3", Text: "retry"})
+
+	// "2" is evicted as the oldest; re-annotating "1" moved it to the end.
+	var got []string
+	for _, a := range h.Annotations() {
+		got = append(got, a.EventID)
+	}
+	if want := []string{"1", "3"}; !slices.Equal(got, want) {
+		t.Fatalf("annotations = %v, want %v", got, want)
+	}
+}
+
+func TestHub_AnnotateClear(t *testing.T) {
+	t.Parallel()
+
+	h := collab.New(10)
+	h.Annotate(collab.Annotation{EventID: "1", Text: "slow"})
+	h.Annotate(collab.Annotation{EventID: "1"})
+	if got := h.Annotations(); len(got) != 0 {
+		t.Fatalf("annotations = %v, want none", got)
+	}
+}
@@ -0,0 +1,375 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/eventsink"
+	"github.com/mickamy/sql-tap/internal/privacy"
+)
+
+// Config is the sql-tapd configuration file.
+type Config struct {
+	Tags        []TagRule   `yaml:"tags"`
+	Fields      []FieldRule `yaml:"fields"`
+	Archive     Archive     `yaml:"archive"`
+	Sinks       []Sink      `yaml:"sinks"`
+	Auth        Auth        `yaml:"auth"`
+	Anomaly     Anomaly     `yaml:"anomaly"`
+	Traffic     Traffic     `yaml:"traffic"`
+	NPlusOne    NPlusOne    `yaml:"n_plus_one"`
+	Routes      Routes      `yaml:"routes"`
+	Tenants     Tenants     `yaml:"tenants"`
+	AutoExplain AutoExplain `yaml:"auto_explain"`
+	PgStat      PgStat      `yaml:"pg_stat_statements"`
+	Store       Store       `yaml:"store"`
+	Privacy     Privacy     `yaml:"privacy"`
+	Policy      Policy      `yaml:"policy"`
+}
+
+// Routes tunes per-route statistics for queries tagged with an HTTP route.
+// Zero fields keep the defaults.
+type Routes struct {
+	QueryBudget int `yaml:"query_budget"` // queries per request above which a route is flagged (default 20)
+}
+
+// Tenants aggregates statistics per value of an extracted field and reports
+// tenants taking more than a share of the queries. An empty Field disables
+// it; zero fields keep the defaults.
+type Tenants struct {
+	Field    string        `yaml:"field"`     // name of one of the fields rules, e.g. tenant_id
+	MaxShare float64       `yaml:"max_share"` // fraction of queries, in (0, 1), a tenant may take (default 0.5)
+	Window   time.Duration `yaml:"window"`    // shares are checked per window (default 1m)
+	MinCalls int           `yaml:"min_calls"` // queries a window needs before shares are checked (default 100)
+}
+
+// AutoExplain runs EXPLAIN for statements at least Threshold slow and
+// publishes their plans. A zero Threshold disables it; zero fields keep the
+// defaults.
+type AutoExplain struct {
+	Threshold time.Duration `yaml:"threshold"` // e.g. "500ms"
+	Analyze   bool          `yaml:"analyze"`   // EXPLAIN ANALYZE plain SELECT statements, which runs them again
+	CacheTTL  time.Duration `yaml:"cache_ttl"` // a query is explained at most once per TTL (default 10m)
+	Timeout   time.Duration `yaml:"timeout"`   // how long one EXPLAIN may take (default 5s)
+}
+
+// PgStat polls pg_stat_statements on each Postgres target with an EXPLAIN
+// DSN, so the stats view can show server-side totals for captured
+// fingerprints. A zero Interval disables it; zero fields keep the defaults.
+type PgStat struct {
+	Interval time.Duration `yaml:"interval"` // e.g. "30s"
+	Limit    int           `yaml:"limit"`    // statements read per poll, by total time (default 500)
+}
+
+// Store persists every event to a queryable file. An empty Path disables it.
+type Store struct {
+	Path   string `yaml:"path"`    // e.g. /var/lib/sql-tap/events.db
+	KeyEnv string `yaml:"key_env"` // environment variable holding an AES-256 key; encrypts a new store
+	KeyKMS string `yaml:"key_kms"` // KMS key that wrapped the key in KeyEnv: aws-kms://<arn> or gcp-kms://<name>
+}
+
+// Privacy keeps captured values from leaving the daemon.
+type Privacy struct {
+	Args string `yaml:"args"` // bind arguments and sampled rows: raw (default), generalize, or redact
+}
+
+// Policy blocks statements before the proxies forward them. Rules are
+// tried in order and the first a statement matches decides; statements no
+// rule matches get Default. Without rules and with an allow default, every
+// statement runs.
+type Policy struct {
+	Default string       `yaml:"default"` // allow (default) or deny
+	DryRun  bool         `yaml:"dry_run"` // mark violating statements on their events, but forward them
+	Rules   []PolicyRule `yaml:"rules"`
+}
+
+// Enabled reports whether p can block anything.
+func (p Policy) Enabled() bool {
+	return len(p.Rules) > 0 || p.Default == "deny"
+}
+
+// PolicyRule allows or denies the statements matching all of its
+// conditions. Unset conditions match everything; a rule must set at least
+// one.
+type PolicyRule struct {
+	Name        string `yaml:"name"`        // reported on violations
+	Action      string `yaml:"action"`      // allow or deny
+	Query       string `yaml:"query"`       // regular expression matched against the query text
+	Fingerprint string `yaml:"fingerprint"` // matches queries fingerprinted like this one, e.g. "DELETE FROM users"
+	User        string `yaml:"user"`        // database user the client authenticated as
+	Database    string `yaml:"database"`    // database selected when the client connected
+}
+
+// Anomaly tunes latency anomaly detection, which is on by default. Zero
+// fields keep the detector's defaults.
+type Anomaly struct {
+	Disabled   bool    `yaml:"disabled"`
+	Threshold  float64 `yaml:"threshold"`   // z-score at which an event is flagged (default 3)
+	MinSamples int     `yaml:"min_samples"` // events a query needs before it can be flagged (default 20)
+	Alpha      float64 `yaml:"alpha"`       // EWMA smoothing factor in (0, 1] (default 0.05)
+}
+
+// Traffic tunes traffic change detection, which is on by default. Zero
+// fields keep the detector's defaults.
+type Traffic struct {
+	Disabled bool          `yaml:"disabled"`
+	Window   time.Duration `yaml:"window"`    // calls are counted per window (default 1m)
+	Factor   float64       `yaml:"factor"`    // rise or fall to report, as a multiple of the baseline (default 10)
+	Warmup   int           `yaml:"warmup"`    // windows before changes are reported (default 5)
+	MinCalls int           `yaml:"min_calls"` // calls per window, before or after, worth reporting (default 10)
+}
+
+// NPlusOne tunes N+1 query detection, which is on by default. Zero fields
+// keep the detector's defaults.
+type NPlusOne struct {
+	Disabled  bool          `yaml:"disabled"`
+	Threshold int           `yaml:"threshold"` // calls of one query that make a burst (default 5)
+	Window    time.Duration `yaml:"window"`    // longest pause between calls outside a transaction (default 100ms)
+}
+
+// Auth requires gRPC clients to present one of Tokens. Without tokens the API
+// is open to anyone who can reach it.
+type Auth struct {
+	Tokens []Token `yaml:"tokens"`
+}
+
+// Token grants Role to clients presenting the value of the environment
+// variable TokenEnv.
+type Token struct {
+	Role     string `yaml:"role"`      // viewer, analyst, or admin
+	TokenEnv string `yaml:"token_env"` // environment variable holding the token
+}
+
+// Archive configures daily capture archives. An empty Dir disables them.
+type Archive struct {
+	Dir       string        `yaml:"dir"`       // directory for sql-tap-YYYY-MM-DD.ndjson files
+	Compress  bool          `yaml:"compress"`  // gzip each day's archive once the day is over
+	Retention time.Duration `yaml:"retention"` // delete archives older than this, e.g. "720h"; 0 keeps them
+	Upload    string        `yaml:"upload"`    // upload finished days to s3://bucket[/prefix] or gs://bucket[/prefix]
+	KeyEnv    string        `yaml:"key_env"`   // environment variable holding an AES-256 key; encrypts new archives
+	KeyKMS    string        `yaml:"key_kms"`   // KMS key that wrapped the key in KeyEnv: aws-kms://<arn> or gcp-kms://<name>
+	Chain     bool          `yaml:"chain"`     // hash-chain each day's events, for sql-tap verify
+}
+
+// Sink publishes every event to a Kafka topic or NATS JetStream subject.
+// Zero fields keep the defaults.
+type Sink struct {
+	URL           string        `yaml:"url"`            // kafka://[user@]host:9092[,host:9092...]/topic or nats://[user@]host:4222/subject
+	Format        string        `yaml:"format"`         // json (default) or protobuf
+	PasswordEnv   string        `yaml:"password_env"`   // environment variable holding the URL user's password, or a NATS token without a user
+	SASL          string        `yaml:"sasl"`           // Kafka SASL mechanism for the URL's user: plain (default), scram-sha-256, or scram-sha-512
+	TLS           bool          `yaml:"tls"`            // connect over TLS
+	CAFile        string        `yaml:"ca_file"`        // PEM certificates to verify the brokers with, instead of the system's
+	BatchSize     int           `yaml:"batch_size"`     // events published at once (default 500)
+	BatchInterval time.Duration `yaml:"batch_interval"` // longest an event waits to be published (default 1s)
+	Retries       *int          `yaml:"retries"`        // retries of a failed publish before its events are dropped (default 3)
+}
+
+// TagRule attaches Tag to every event matching all of the rule's conditions.
+// Unset conditions match everything; a rule must set at least one.
+type TagRule struct {
+	Tag         string        `yaml:"tag"`
+	Color       string        `yaml:"color"`        // lipgloss color for the TUI, e.g. "5" or "#ff8800"
+	Query       string        `yaml:"query"`        // regular expression matched against the query text
+	Op          string        `yaml:"op"`           // operation name, e.g. "query" or "exec" (case-insensitive)
+	Upstream    string        `yaml:"upstream"`     // upstream name from -tap
+	MinDuration time.Duration `yaml:"min_duration"` // e.g. "500ms"
+	Error       *bool         `yaml:"error"`        // match only failed (true) or successful (false) queries
+}
+
+// FieldRule extracts the value of field Name from each query, taken from
+// exactly one source. Several rules may fill the same field; the first that
+// finds a value wins.
+type FieldRule struct {
+	Name    string `yaml:"name"`    // e.g. "tenant_id"
+	Comment string `yaml:"comment"` // key of the query's sqlcommenter comment, e.g. "tenant"
+	Query   string `yaml:"query"`   // regular expression matched against the query text; its first group is the value
+	Arg     int    `yaml:"arg"`     // 1-based position of the bind argument holding the value
+}
+
+// Load reads and validates the config file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from the -config flag
+	if err != nil {
+		return nil, fmt.Errorf("config: read %s: %w", path, err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes and validates a YAML config.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("config: parse: %w", err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	for i, r := range c.Tags {
+		if r.Tag == "" {
+			return fmt.Errorf("config: tags[%d]: tag is required", i)
+		}
+		if r.Query == "" && r.Op == "" && r.Upstream == "" && r.MinDuration == 0 && r.Error == nil {
+			return fmt.Errorf("config: tags[%d] (%s): at least one condition is required", i, r.Tag)
+		}
+	}
+	for i, r := range c.Fields {
+		if r.Name == "" {
+			return fmt.Errorf("config: fields[%d]: name is required", i)
+		}
+		if r.Arg < 0 {
+			return fmt.Errorf("config: fields[%d] (%s): arg must not be negative", i, r.Name)
+		}
+		sources := 0
+		for _, set := range []bool{r.Comment != "", r.Query != "", r.Arg > 0} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			return fmt.Errorf("config: fields[%d] (%s): exactly one of comment, query, and arg is required", i, r.Name)
+		}
+	}
+	if t := c.Tenants; t.Field == "" && (t.MaxShare != 0 || t.Window != 0 || t.MinCalls != 0) {
+		return errors.New("config: tenants: field is required")
+	}
+	if f := c.Tenants.Field; f != "" && !slices.ContainsFunc(c.Fields, func(r FieldRule) bool { return r.Name == f }) {
+		return fmt.Errorf("config: tenants: field %q is not extracted by any fields rule", f)
+	}
+	if s := c.Tenants.MaxShare; s < 0 || s >= 1 {
+		return fmt.Errorf("config: tenants: max_share %g must be in (0, 1)", s)
+	}
+	if c.Tenants.Window < 0 || c.Tenants.MinCalls < 0 {
+		return errors.New("config: tenants: window and min_calls must not be negative")
+	}
+	if a := c.AutoExplain; a.Threshold == 0 && (a.Analyze || a.CacheTTL != 0 || a.Timeout != 0) {
+		return errors.New("config: auto_explain: threshold is required")
+	}
+	if a := c.AutoExplain; a.Threshold < 0 || a.CacheTTL < 0 || a.Timeout < 0 {
+		return errors.New("config: auto_explain: threshold, cache_ttl, and timeout must not be negative")
+	}
+	if p := c.PgStat; p.Interval == 0 && p.Limit != 0 {
+		return errors.New("config: pg_stat_statements: interval is required")
+	}
+	if p := c.PgStat; p.Interval < 0 || p.Limit < 0 {
+		return errors.New("config: pg_stat_statements: interval and limit must not be negative")
+	}
+	for i, tok := range c.Auth.Tokens {
+		if tok.TokenEnv == "" {
+			return fmt.Errorf("config: auth: tokens[%d]: token_env is required", i)
+		}
+		if _, err := auth.ParseRole(tok.Role); err != nil {
+			return fmt.Errorf("config: auth: tokens[%d]: %w", i, err)
+		}
+	}
+	if c.Anomaly.Threshold < 0 || c.Anomaly.MinSamples < 0 {
+		return errors.New("config: anomaly: threshold and min_samples must not be negative")
+	}
+	if a := c.Anomaly.Alpha; a < 0 || a > 1 {
+		return fmt.Errorf("config: anomaly: alpha %g must be in (0, 1]", a)
+	}
+	if c.Traffic.Window < 0 || c.Traffic.Warmup < 0 || c.Traffic.MinCalls < 0 {
+		return errors.New("config: traffic: window, warmup, and min_calls must not be negative")
+	}
+	if f := c.Traffic.Factor; f != 0 && f <= 1 {
+		return fmt.Errorf("config: traffic: factor %g must be greater than 1", f)
+	}
+	if c.NPlusOne.Threshold < 0 || c.NPlusOne.Window < 0 {
+		return errors.New("config: n_plus_one: threshold and window must not be negative")
+	}
+	if c.Routes.QueryBudget < 0 {
+		return errors.New("config: routes: query_budget must not be negative")
+	}
+	if _, err := privacy.ParseMode(c.Privacy.Args); err != nil {
+		return fmt.Errorf("config: privacy: args: %w", err)
+	}
+	if c.Archive.Retention < 0 {
+		return errors.New("config: archive: retention must not be negative")
+	}
+	if c.Store.Path == "" && c.Store.KeyEnv != "" {
+		return errors.New("config: store: path is required")
+	}
+	if err := validateKMS(c.Store.KeyEnv, c.Store.KeyKMS); err != nil {
+		return fmt.Errorf("config: store: %w", err)
+	}
+	if err := validateKMS(c.Archive.KeyEnv, c.Archive.KeyKMS); err != nil {
+		return fmt.Errorf("config: archive: %w", err)
+	}
+	if c.Archive.Dir == "" && (c.Archive.Compress || c.Archive.Retention > 0 || c.Archive.Upload != "" || c.Archive.KeyEnv != "" || c.Archive.Chain) {
+		return errors.New("config: archive: dir is required")
+	}
+	if u := c.Archive.Upload; u != "" && !strings.HasPrefix(u, "s3://") && !strings.HasPrefix(u, "gs://") {
+		return fmt.Errorf("config: archive: upload %q must be an s3:// or gs:// URL", u)
+	}
+	if d := c.Policy.Default; d != "" && d != "allow" && d != "deny" {
+		return fmt.Errorf("config: policy: default %q must be allow or deny", d)
+	}
+	for i, r := range c.Policy.Rules {
+		if r.Name == "" {
+			return fmt.Errorf("config: policy: rules[%d]: name is required", i)
+		}
+		if r.Action != "allow" && r.Action != "deny" {
+			return fmt.Errorf("config: policy: rules[%d] (%s): action must be allow or deny", i, r.Name)
+		}
+		if r.Query == "" && r.Fingerprint == "" && r.User == "" && r.Database == "" {
+			return fmt.Errorf("config: policy: rules[%d] (%s): at least one condition is required", i, r.Name)
+		}
+	}
+	for i, s := range c.Sinks {
+		if !strings.HasPrefix(s.URL, "kafka://") && !strings.HasPrefix(s.URL, "nats://") {
+			return fmt.Errorf("config: sinks[%d]: url must be a kafka:// or nats:// URL", i)
+		}
+		if _, err := eventsink.ParseFormat(s.Format); err != nil {
+			return fmt.Errorf("config: sinks[%d]: %w", i, err)
+		}
+		kafka := strings.HasPrefix(s.URL, "kafka://")
+		hosts, _, _ := strings.Cut(strings.TrimPrefix(s.URL, "kafka://"), "/")
+		if kafka && s.PasswordEnv != "" && !strings.Contains(hosts, "@") {
+			return fmt.Errorf("config: sinks[%d]: password_env needs a user in a kafka:// URL", i)
+		}
+		if s.SASL != "" {
+			if !kafka {
+				return fmt.Errorf("config: sinks[%d]: sasl is only for kafka:// URLs", i)
+			}
+			if _, err := eventsink.ParseSASL(s.SASL); err != nil {
+				return fmt.Errorf("config: sinks[%d]: %w", i, err)
+			}
+		}
+		if s.CAFile != "" && !s.TLS {
+			return fmt.Errorf("config: sinks[%d]: ca_file needs tls", i)
+		}
+		if s.BatchSize < 0 || s.BatchInterval < 0 || s.Retries != nil && *s.Retries < 0 {
+			return fmt.Errorf("config: sinks[%d]: batch_size, batch_interval, and retries must not be negative", i)
+		}
+	}
+	return nil
+}
+
+// validateKMS checks the key_kms of a section whose key is in keyEnv.
+func validateKMS(keyEnv, keyKMS string) error {
+	if keyKMS == "" {
+		return nil
+	}
+	if keyEnv == "" {
+		return errors.New("key_kms needs key_env, holding the wrapped key")
+	}
+	if !strings.HasPrefix(keyKMS, "aws-kms://") && !strings.HasPrefix(keyKMS, "gcp-kms://") {
+		return errors.New("key_kms must be an aws-kms:// or gcp-kms:// key")
+	}
+	return nil
+}
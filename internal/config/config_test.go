@@ -0,0 +1,142 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/config"
+)
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "sql-tapd.yaml")
+	data := `
+tags:
+  - tag: reporting
+    color: "5"
+    query: "(?i)from reports_"
+  - tag: slow-auth
+    upstream: auth
+    min_duration: 250ms
+    error: false
+`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Tags) != 2 {
+		t.Fatalf("expected 2 tag rules, got %d", len(cfg.Tags))
+	}
+	if got := cfg.Tags[0]; got.Tag != "reporting" || got.Color != "5" || got.Query != "(?i)from reports_" {
+		t.Errorf("unexpected first rule: %+v", got)
+	}
+	got := cfg.Tags[1]
+	if got.MinDuration != 250*time.Millisecond || got.Upstream != "auth" || got.Error == nil || *got.Error {
+		t.Errorf("unexpected second rule: %+v", got)
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "empty", data: ""},
+		{name: "no tags", data: "tags: []\n"},
+		{name: "missing tag", data: "tags:\n  - query: select\n", wantErr: true},
+		{name: "no condition", data: "tags:\n  - tag: all\n", wantErr: true},
+		{name: "unknown field", data: "tags:\n  - tag: x\n    qurey: select\n", wantErr: true},
+		{name: "bad duration", data: "tags:\n  - tag: x\n    min_duration: soon\n", wantErr: true},
+		{name: "fields", data: "fields:\n  - name: tenant_id\n    comment: tenant\n  - name: tenant_id\n    arg: 1\n"},
+		{name: "field without name", data: "fields:\n  - comment: tenant\n", wantErr: true},
+		{name: "field without source", data: "fields:\n  - name: tenant_id\n", wantErr: true},
+		{name: "field with two sources", data: "fields:\n  - name: tenant_id\n    comment: tenant\n    query: x\n", wantErr: true},
+		{name: "field negative arg", data: "fields:\n  - name: tenant_id\n    arg: -1\n", wantErr: true},
+		{name: "tenants", data: "fields:\n  - name: tenant_id\n    comment: tenant\ntenants:\n  field: tenant_id\n  max_share: 0.3\n  window: 5m\n"},
+		{name: "tenants unknown field", data: "tenants:\n  field: tenant_id\n", wantErr: true},
+		{name: "tenants without field", data: "tenants:\n  max_share: 0.3\n", wantErr: true},
+		{name: "tenants bad share", data: "fields:\n  - name: tenant_id\n    arg: 1\ntenants:\n  field: tenant_id\n  max_share: 1\n", wantErr: true},
+		{name: "auto explain", data: "auto_explain:\n  threshold: 500ms\n  analyze: true\n  cache_ttl: 1h\n"},
+		{name: "auto explain without threshold", data: "auto_explain:\n  analyze: true\n", wantErr: true},
+		{name: "auto explain negative timeout", data: "auto_explain:\n  threshold: 1s\n  timeout: -1s\n", wantErr: true},
+		{name: "pg_stat_statements", data: "pg_stat_statements:\n  interval: 30s\n  limit: 100\n"},
+		{name: "pg_stat_statements without interval", data: "pg_stat_statements:\n  limit: 100\n", wantErr: true},
+		{name: "pg_stat_statements negative limit", data: "pg_stat_statements:\n  interval: 1m\n  limit: -1\n", wantErr: true},
+		{name: "archive", data: "archive:\n  dir: /tmp/archive\n  compress: true\n  retention: 720h\n"},
+		{name: "archive without dir", data: "archive:\n  retention: 720h\n", wantErr: true},
+		{name: "negative retention", data: "archive:\n  dir: /tmp/archive\n  retention: -1h\n", wantErr: true},
+		{name: "archive upload", data: "archive:\n  dir: /tmp/archive\n  upload: s3://bucket/sql-tap\n"},
+		{name: "upload without dir", data: "archive:\n  upload: gs://bucket\n", wantErr: true},
+		{name: "archive encryption", data: "archive:\n  dir: /tmp/archive\n  key_env: SQL_TAP_ARCHIVE_KEY\n"},
+		{name: "encryption without dir", data: "archive:\n  key_env: SQL_TAP_ARCHIVE_KEY\n", wantErr: true},
+		{name: "store encryption", data: "store:\n  path: /tmp/events.db\n  key_env: SQL_TAP_STORE_KEY\n"},
+		{name: "store encryption without path", data: "store:\n  key_env: SQL_TAP_STORE_KEY\n", wantErr: true},
+		{name: "archive kms", data: "archive:\n  dir: /tmp/archive\n  key_env: K\n  key_kms: aws-kms://alias/sql-tap\n"},
+		{name: "kms without key_env", data: "store:\n  path: /tmp/events.db\n  key_kms: gcp-kms://projects/p\n", wantErr: true},
+		{name: "kms scheme", data: "archive:\n  dir: /tmp/archive\n  key_env: K\n  key_kms: vault://x\n", wantErr: true},
+		{name: "archive chain", data: "archive:\n  dir: /tmp/archive\n  chain: true\n"},
+		{name: "chain without dir", data: "archive:\n  chain: true\n", wantErr: true},
+		{name: "auth", data: "auth:\n  tokens:\n    - role: viewer\n      token_env: VIEW\n    - role: admin\n      token_env: ADMIN\n"},
+		{name: "auth without token_env", data: "auth:\n  tokens:\n    - role: viewer\n", wantErr: true},
+		{name: "auth bad role", data: "auth:\n  tokens:\n    - role: root\n      token_env: ROOT\n", wantErr: true},
+		{name: "upload bad scheme", data: "archive:\n  dir: /tmp/archive\n  upload: https://bucket\n", wantErr: true},
+		{name: "anomaly", data: "anomaly:\n  threshold: 4\n  min_samples: 50\n  alpha: 0.1\n"},
+		{name: "anomaly disabled", data: "anomaly:\n  disabled: true\n"},
+		{name: "traffic", data: "traffic:\n  window: 5m\n  factor: 4\n  warmup: 3\n  min_calls: 50\n"},
+		{name: "traffic bad factor", data: "traffic:\n  factor: 0.5\n", wantErr: true},
+		{name: "traffic negative window", data: "traffic:\n  window: -1m\n", wantErr: true},
+		{name: "n+1", data: "n_plus_one:\n  threshold: 10\n  window: 250ms\n"},
+		{name: "n+1 disabled", data: "n_plus_one:\n  disabled: true\n"},
+		{name: "n+1 negative threshold", data: "n_plus_one:\n  threshold: -1\n", wantErr: true},
+		{name: "routes", data: "routes:\n  query_budget: 50\n"},
+		{name: "routes negative budget", data: "routes:\n  query_budget: -1\n", wantErr: true},
+		{name: "sinks", data: "sinks:\n  - url: kafka://kafka:9092/sql-events\n    format: protobuf\n  - url: nats://app@nats:4222/sql.events\n    password_env: NATS_PASSWORD\n    retries: 0\n"},
+		{name: "sink bad scheme", data: "sinks:\n  - url: amqp://rabbit/events\n", wantErr: true},
+		{name: "sink bad format", data: "sinks:\n  - url: kafka://kafka/events\n    format: avro\n", wantErr: true},
+		{name: "sink kafka password", data: "sinks:\n  - url: kafka://kafka/events\n    password_env: PASS\n", wantErr: true},
+		{name: "sink kafka sasl", data: "sinks:\n  - url: kafka://app@kafka/events\n    password_env: PASS\n    sasl: scram-sha-512\n    tls: true\n    ca_file: ca.pem\n"},
+		{name: "sink bad sasl", data: "sinks:\n  - url: kafka://app@kafka/events\n    sasl: gssapi\n", wantErr: true},
+		{name: "sink nats sasl", data: "sinks:\n  - url: nats://nats/sql.events\n    sasl: plain\n", wantErr: true},
+		{name: "sink ca without tls", data: "sinks:\n  - url: nats://nats/sql.events\n    ca_file: ca.pem\n", wantErr: true},
+		{name: "sink negative retries", data: "sinks:\n  - url: kafka://kafka/events\n    retries: -1\n", wantErr: true},
+		{name: "policy", data: "policy:\n  default: deny\n  dry_run: true\n  rules:\n    - name: reads\n      action: allow\n      query: (?i)^select\n    - name: no-truncate\n      action: deny\n      fingerprint: TRUNCATE users\n      user: app\n"},
+		{name: "policy bad default", data: "policy:\n  default: block\n", wantErr: true},
+		{name: "policy rule without name", data: "policy:\n  rules:\n    - action: deny\n      query: drop\n", wantErr: true},
+		{name: "policy bad action", data: "policy:\n  rules:\n    - name: x\n      action: block\n      query: drop\n", wantErr: true},
+		{name: "policy rule without condition", data: "policy:\n  rules:\n    - name: x\n      action: deny\n", wantErr: true},
+		{name: "store", data: "store:\n  path: /var/lib/sql-tap/events.db\n"},
+		{name: "privacy", data: "privacy:\n  args: generalize\n"},
+		{name: "privacy unknown mode", data: "privacy:\n  args: hash\n", wantErr: true},
+		{name: "anomaly bad alpha", data: "anomaly:\n  alpha: 2\n", wantErr: true},
+		{name: "anomaly negative threshold", data: "anomaly:\n  threshold: -1\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := config.Parse([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoad_Missing(t *testing.T) {
+	t.Parallel()
+
+	if _, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+}
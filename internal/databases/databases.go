@@ -0,0 +1,124 @@
+// Package databases aggregates query statistics per database, as selected
+// by each client when it connected. One server often hosts many databases,
+// and on a shared cluster the first question is usually which of them the
+// load comes from.
+//
+// Statistics cover a rolling Window, like the routes package's. Databases
+// are told apart by upstream, since two servers may each have one of the
+// same name.
+package databases
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/stats"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// The statistics cover Window, in Buckets steps of Resolution.
+const (
+	Resolution = 10 * time.Second
+	Buckets    = 30
+	Window     = Resolution * Buckets
+)
+
+// maxUsers bounds the users counted per database.
+const maxUsers = 100
+
+// Database is the statistics of one database's queries over Window.
+type Database struct {
+	Upstream string
+	Database string
+	stats.Summary
+	Share float64 // of all queries in the window
+	// Users seen on connections to the database in the window, sorted.
+	Users []string
+}
+
+// Tracker aggregates queries per upstream and database. It is safe for
+// concurrent use.
+type Tracker struct {
+	mu    sync.Mutex
+	agg   *stats.Aggregator
+	users map[string]map[string]time.Time // last query per user, by key
+}
+
+// New returns an empty Tracker.
+func New() *Tracker {
+	return &Tracker{
+		agg:   stats.New(Resolution, Buckets),
+		users: make(map[string]map[string]time.Time),
+	}
+}
+
+// Observe records ev at now. Lifecycle events are ignored.
+func (t *Tracker) Observe(ev proxy.Event, now time.Time) {
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel,
+		proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+		return
+	}
+	if ev.Query == "" {
+		return
+	}
+	k := key(ev.Upstream, ev.Database)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.agg.Observe(k, now, ev.Duration, ev.Error != "")
+	if ev.User == "" {
+		return
+	}
+	users := t.users[k]
+	if users == nil {
+		users = make(map[string]time.Time)
+		t.users[k] = users
+	}
+	if _, ok := users[ev.User]; ok || len(users) < maxUsers {
+		users[ev.User] = now
+	}
+}
+
+// Databases returns the databases with queries in the window ending at now,
+// busiest first.
+func (t *Tracker) Databases(now time.Time) []Database {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := now.Add(-Window)
+	for k, users := range t.users {
+		for u, last := range users {
+			if last.Before(since) {
+				delete(users, u)
+			}
+		}
+		if len(users) == 0 {
+			delete(t.users, k)
+		}
+	}
+
+	overall := t.agg.Overall(now)
+	keys := t.agg.Keys(now)
+	out := make([]Database, 0, len(keys))
+	for _, k := range keys {
+		upstream, database, _ := strings.Cut(k.Key, "\x00")
+		db := Database{Upstream: upstream, Database: database, Summary: k.Summary}
+		if overall.Count > 0 {
+			db.Share = float64(k.Count) / float64(overall.Count)
+		}
+		for u := range t.users[k.Key] {
+			db.Users = append(db.Users, u)
+		}
+		slices.Sort(db.Users)
+		out = append(out, db)
+	}
+	return out
+}
+
+func key(upstream, database string) string {
+	return upstream + "\x00" + database
+}
@@ -0,0 +1,63 @@
+package databases_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/databases"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func query(upstream, database, user string, d time.Duration) proxy.Event {
+	return proxy.Event{
+		Op:       proxy.OpQuery,
+		Query:    "SELECT * FROM orders",
+		Duration: d,
+		Upstream: upstream,
+		Database: database,
+		User:     user,
+	}
+}
+
+func TestTracker_Databases(t *testing.T) {
+	t.Parallel()
+
+	tr := databases.New()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for range 5 {
+		tr.Observe(query("", "billing", "app", 10*time.Millisecond), now)
+	}
+	tr.Observe(query("", "billing", "report", time.Millisecond), now)
+	for range 3 {
+		tr.Observe(query("", "auth", "app", time.Millisecond), now)
+	}
+	tr.Observe(query("replica", "billing", "app", time.Millisecond), now)
+	tr.Observe(proxy.Event{Op: proxy.OpBegin, Query: "BEGIN", Database: "auth"}, now)
+	tr.Observe(proxy.Event{Op: proxy.OpConnect, Database: "auth"}, now)
+
+	got := tr.Databases(now)
+	if len(got) != 3 {
+		t.Fatalf("got %d databases, want 3: %+v", len(got), got)
+	}
+	first := got[0]
+	if first.Upstream != "" || first.Database != "billing" || first.Count != 6 || first.P50 != 10*time.Millisecond {
+		t.Errorf("first database = %+v, want billing with 6 queries at 10ms", first)
+	}
+	if want := 6.0 / 10; first.Share != want {
+		t.Errorf("billing share = %v, want %v of all 10 queries", first.Share, want)
+	}
+	if want := []string{"app", "report"}; !slices.Equal(first.Users, want) {
+		t.Errorf("billing users = %v, want %v", first.Users, want)
+	}
+	if got[1].Database != "auth" || got[1].Count != 3 {
+		t.Errorf("second database = %+v, want auth with 3 queries", got[1])
+	}
+	if got[2].Upstream != "replica" || got[2].Database != "billing" || got[2].Count != 1 {
+		t.Errorf("third database = %+v, want billing on replica with 1 query", got[2])
+	}
+
+	if got := tr.Databases(now.Add(databases.Window + time.Second)); len(got) != 0 {
+		t.Errorf("expected no databases after the window, got %+v", got)
+	}
+}
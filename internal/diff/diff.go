@@ -0,0 +1,279 @@
+// Package diff compares two recorded capture sessions query by query. A
+// session is the NDJSON that sql-tap watch, the TUI's export, or the
+// daemon's archive writes; its statements are grouped by fingerprint, and
+// the report lists the queries only one session ran, the queries that got
+// slower, and the queries whose call count changed, e.g. across an ORM
+// upgrade.
+package diff
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Defaults for the thresholds of Compare.
+const (
+	DefaultLatencyRatio = 1.2
+	DefaultMinLatency   = time.Millisecond
+	DefaultCallRatio    = 1.5
+)
+
+// maxLineSize bounds one NDJSON record; queries with large literals or many
+// arguments run well past bufio's default.
+const maxLineSize = 16 << 20
+
+// Session is the statements of one capture, by fingerprint.
+type Session struct {
+	queries map[string]*series
+}
+
+type series struct {
+	query     string // the first statement seen, as an example
+	durations []time.Duration
+	errors    int
+}
+
+// NewSession returns an empty Session.
+func NewSession() *Session {
+	return &Session{queries: make(map[string]*series)}
+}
+
+// Read returns the session recorded as NDJSON in r.
+func Read(r io.Reader) (*Session, error) {
+	s := NewSession()
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec export.Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("diff: line %d: %w", line, err)
+		}
+		s.Add(rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+	return s, nil
+}
+
+// Add records rec. Lifecycle records, such as Begin and Commit, and records
+// without a query are ignored.
+func (s *Session) Add(rec export.Record) {
+	switch rec.Op {
+	case proxy.OpQuery.String(), proxy.OpExec.String(), proxy.OpExecute.String():
+	default:
+		return
+	}
+	if rec.Query == "" {
+		return
+	}
+	fp := rec.Fingerprint
+	if fp == "" {
+		fp = query.Fingerprint(rec.Query)
+	}
+	q, ok := s.queries[fp]
+	if !ok {
+		q = &series{query: rec.Query}
+		s.queries[fp] = q
+	}
+	q.durations = append(q.durations, time.Duration(rec.DurationMs*float64(time.Millisecond)))
+	if rec.Error != "" {
+		q.errors++
+	}
+}
+
+// Query is one fingerprint's statements within a session.
+type Query struct {
+	Fingerprint string
+	Example     string // one of the statements, with its literals
+	Calls       int
+	Errors      int
+	P50         time.Duration
+	P95         time.Duration
+	Total       time.Duration
+}
+
+// MarshalJSON encodes the durations in milliseconds, as export.Record does.
+func (q Query) MarshalJSON() ([]byte, error) {
+	//nolint:wrapcheck // encoding of plain values
+	return json.Marshal(struct {
+		Fingerprint string  `json:"fingerprint"`
+		Example     string  `json:"example"`
+		Calls       int     `json:"calls"`
+		Errors      int     `json:"errors"`
+		P50Ms       float64 `json:"p50_ms"`
+		P95Ms       float64 `json:"p95_ms"`
+		TotalMs     float64 `json:"total_ms"`
+	}{
+		Fingerprint: q.Fingerprint,
+		Example:     q.Example,
+		Calls:       q.Calls,
+		Errors:      q.Errors,
+		P50Ms:       milliseconds(q.P50),
+		P95Ms:       milliseconds(q.P95),
+		TotalMs:     milliseconds(q.Total),
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+func (s *Session) query(fp string) Query {
+	q := s.queries[fp]
+	sorted := slices.Clone(q.durations)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	return Query{
+		Fingerprint: fp,
+		Example:     q.query,
+		Calls:       len(sorted),
+		Errors:      q.errors,
+		P50:         quantile(sorted, 0.50),
+		P95:         quantile(sorted, 0.95),
+		Total:       total,
+	}
+}
+
+// Change is a fingerprint both sessions ran.
+type Change struct {
+	Before Query `json:"before"`
+	After  Query `json:"after"`
+}
+
+// Report is the difference between two sessions. Each list is ordered by
+// the size of the change, largest first.
+type Report struct {
+	New         []Query  `json:"new"`         // only in the after session
+	Removed     []Query  `json:"removed"`     // only in the before session
+	Regressions []Change `json:"regressions"` // p95 latency grew
+	CallChanges []Change `json:"call_changes"`
+	Unchanged   int      `json:"unchanged"` // fingerprints in neither list above
+}
+
+// Empty reports whether r found no difference.
+func (r Report) Empty() bool {
+	return len(r.New) == 0 && len(r.Removed) == 0 && len(r.Regressions) == 0 && len(r.CallChanges) == 0
+}
+
+// Option configures Compare.
+type Option func(*comparer)
+
+type comparer struct {
+	latencyRatio float64
+	minLatency   time.Duration
+	callRatio    float64
+}
+
+// WithLatencyThreshold reports a regression when a fingerprint's p95 grows
+// by at least ratio and by at least min, so that noise on sub-millisecond
+// queries is not reported.
+func WithLatencyThreshold(ratio float64, minDelta time.Duration) Option {
+	return func(c *comparer) {
+		c.latencyRatio = ratio
+		c.minLatency = minDelta
+	}
+}
+
+// WithCallRatio reports a call-count change when one session ran a
+// fingerprint at least ratio times as often as the other.
+func WithCallRatio(ratio float64) Option {
+	return func(c *comparer) {
+		c.callRatio = ratio
+	}
+}
+
+// Compare returns how after differs from before.
+func Compare(before, after *Session, opts ...Option) Report {
+	c := comparer{
+		latencyRatio: DefaultLatencyRatio,
+		minLatency:   DefaultMinLatency,
+		callRatio:    DefaultCallRatio,
+	}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	r := Report{New: []Query{}, Removed: []Query{}, Regressions: []Change{}, CallChanges: []Change{}}
+	for fp := range before.queries {
+		if _, ok := after.queries[fp]; !ok {
+			r.Removed = append(r.Removed, before.query(fp))
+		}
+	}
+	for fp := range after.queries {
+		if _, ok := before.queries[fp]; !ok {
+			r.New = append(r.New, after.query(fp))
+			continue
+		}
+		ch := Change{Before: before.query(fp), After: after.query(fp)}
+		changed := false
+		if c.regressed(ch) {
+			r.Regressions = append(r.Regressions, ch)
+			changed = true
+		}
+		if c.callsChanged(ch) {
+			r.CallChanges = append(r.CallChanges, ch)
+			changed = true
+		}
+		if !changed {
+			r.Unchanged++
+		}
+	}
+
+	byCalls := func(a, b Query) int {
+		return cmp.Or(cmp.Compare(b.Calls, a.Calls), cmp.Compare(a.Fingerprint, b.Fingerprint))
+	}
+	slices.SortFunc(r.New, byCalls)
+	slices.SortFunc(r.Removed, byCalls)
+	slices.SortFunc(r.Regressions, func(a, b Change) int {
+		return cmp.Or(cmp.Compare(b.After.P95-b.Before.P95, a.After.P95-a.Before.P95),
+			cmp.Compare(a.After.Fingerprint, b.After.Fingerprint))
+	})
+	slices.SortFunc(r.CallChanges, func(a, b Change) int {
+		return cmp.Or(cmp.Compare(callDelta(b), callDelta(a)), cmp.Compare(a.After.Fingerprint, b.After.Fingerprint))
+	})
+	return r
+}
+
+func (c comparer) regressed(ch Change) bool {
+	before, after := ch.Before.P95, ch.After.P95
+	return after-before >= c.minLatency && float64(after) >= float64(before)*c.latencyRatio
+}
+
+func (c comparer) callsChanged(ch Change) bool {
+	lo, hi := min(ch.Before.Calls, ch.After.Calls), max(ch.Before.Calls, ch.After.Calls)
+	return hi > lo && float64(hi) >= float64(lo)*c.callRatio
+}
+
+func callDelta(ch Change) int {
+	d := ch.After.Calls - ch.Before.Calls
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// quantile returns the nearest-rank q-quantile of sorted.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
@@ -0,0 +1,155 @@
+package diff_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/diff"
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+func session(t *testing.T, recs ...export.Record) *diff.Session {
+	t.Helper()
+
+	var b strings.Builder
+	for _, rec := range recs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Write(line)
+		b.WriteString("\n")
+	}
+	s, err := diff.Read(strings.NewReader(b.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func calls(n int, q string, ms float64) []export.Record {
+	recs := make([]export.Record, n)
+	for i := range recs {
+		recs[i] = export.Record{Op: "Query", Query: q, DurationMs: ms}
+	}
+	return recs
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+
+	const (
+		users   = "SELECT * FROM users WHERE id = 1"
+		orders  = "SELECT * FROM orders WHERE user_id = 1"
+		items   = "SELECT * FROM items WHERE order_id = 1"
+		legacy  = "SELECT * FROM legacy"
+		steady  = "SELECT now()"
+		batched = "SELECT * FROM items WHERE order_id IN (1, 2, 3)"
+	)
+	var before, after []export.Record
+	before = append(before, calls(10, users, 1)...)
+	before = append(before, calls(1, orders, 2)...)
+	before = append(before, calls(2, legacy, 1)...)
+	before = append(before, calls(5, steady, 0.1)...)
+	before = append(before, calls(1, batched, 1)...)
+	before = append(before, export.Record{Op: "Begin"}, export.Record{Op: "Commit"})
+
+	after = append(after, calls(10, users, 5)...)   // slower
+	after = append(after, calls(20, orders, 2)...)  // N+1
+	after = append(after, calls(3, items, 1)...)    // new
+	after = append(after, calls(5, steady, 0.3)...) // 3x, but under a millisecond
+	after = append(after, export.Record{Op: "Query", Query: batched, DurationMs: 1, Error: "timeout"})
+
+	r := diff.Compare(session(t, before...), session(t, after...))
+
+	if len(r.New) != 1 || r.New[0].Example != items || r.New[0].Calls != 3 {
+		t.Errorf("New = %+v, want the items query", r.New)
+	}
+	if len(r.Removed) != 1 || r.Removed[0].Example != legacy {
+		t.Errorf("Removed = %+v, want the legacy query", r.Removed)
+	}
+	if len(r.Regressions) != 1 || r.Regressions[0].After.Example != users {
+		t.Fatalf("Regressions = %+v, want the users query", r.Regressions)
+	}
+	if got := r.Regressions[0]; got.Before.P95 != time.Millisecond || got.After.P95 != 5*time.Millisecond {
+		t.Errorf("p95 = %s -> %s, want 1ms -> 5ms", got.Before.P95, got.After.P95)
+	}
+	if len(r.CallChanges) != 1 || r.CallChanges[0].Before.Calls != 1 || r.CallChanges[0].After.Calls != 20 {
+		t.Errorf("CallChanges = %+v, want orders 1 -> 20", r.CallChanges)
+	}
+	if r.Unchanged != 2 {
+		t.Errorf("Unchanged = %d, want 2", r.Unchanged)
+	}
+}
+
+func TestCompare_Thresholds(t *testing.T) {
+	t.Parallel()
+
+	const q = "SELECT 1"
+	before := session(t, calls(10, q, 10)...)
+	after := session(t, calls(12, q, 11)...)
+
+	tests := []struct {
+		name        string
+		opts        []diff.Option
+		regressions int
+		callChanges int
+	}{
+		{name: "defaults", regressions: 0, callChanges: 0},
+		{name: "tighter latency", opts: []diff.Option{diff.WithLatencyThreshold(1.05, 0)}, regressions: 1},
+		{name: "tighter calls", opts: []diff.Option{diff.WithCallRatio(1.1)}, callChanges: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			r := diff.Compare(before, after, tt.opts...)
+			if len(r.Regressions) != tt.regressions || len(r.CallChanges) != tt.callChanges {
+				t.Errorf("regressions = %d, call changes = %d, want %d and %d",
+					len(r.Regressions), len(r.CallChanges), tt.regressions, tt.callChanges)
+			}
+			if r.Empty() != (tt.regressions+tt.callChanges == 0) {
+				t.Errorf("Empty() = %v", r.Empty())
+			}
+		})
+	}
+}
+
+func TestRead(t *testing.T) {
+	t.Parallel()
+
+	in := `{"op":"Query","query":"SELECT * FROM t WHERE id = 1","fingerprint":"SELECT * FROM t WHERE id = ?","duration_ms":1.5}
+
+{"op":"Query","query":"SELECT * FROM t WHERE id = 2","duration_ms":2.5}
+`
+	s, err := diff.Read(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := diff.Compare(diff.NewSession(), s)
+	if len(r.New) != 1 {
+		t.Fatalf("expected one fingerprint, got %+v", r.New)
+	}
+	if got := r.New[0]; got.Calls != 2 || got.P95 != 2500*time.Microsecond || got.Total != 4*time.Millisecond {
+		t.Errorf("query = %+v", got)
+	}
+
+	if _, err := diff.Read(strings.NewReader("{\"op\":\"Query\"}\nnot json\n")); err == nil ||
+		!strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected a line 2 error, got %v", err)
+	}
+}
+
+func TestQuery_MarshalJSON(t *testing.T) {
+	t.Parallel()
+
+	b, err := json.Marshal(diff.Query{Fingerprint: "SELECT ?", Calls: 2, P95: 1500 * time.Microsecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"p95_ms":1.5`) || !strings.Contains(string(b), `"calls":2`) {
+		t.Errorf("json = %s", b)
+	}
+}
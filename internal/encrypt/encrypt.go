@@ -0,0 +1,358 @@
+// Package encrypt seals streams with AES-256-GCM in independently
+// authenticated frames, so encrypted files can be appended to across
+// restarts and read back incrementally.
+//
+// A stream starts with a header: the magic "STE2", the big-endian uint32
+// index of the stream in its file, and a random 7-byte nonce prefix. Each
+// frame after it is the big-endian uint32 length of its sealed payload and
+// the payload, sealed under a nonce made of the prefix, the frame's
+// big-endian uint32 number in the stream, and a final-frame flag. The
+// header, number, flag, and length are authenticated as additional data, so
+// a frame dropped, reordered, or spliced in from another stream or file
+// fails to open. Closing a Writer seals an empty final frame; a stream
+// without one reads back with ErrUnfinished after its last frame.
+//
+// A file holds one stream per writer that appended to it, in order.
+package encrypt
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+const (
+	// KeySize is the AES-256 key length in bytes.
+	KeySize = 32
+
+	// frameSize bounds the plaintext sealed into one frame.
+	frameSize = 64 << 10
+
+	magic     = "STE2"
+	prefixLen = 7
+	headerLen = len(magic) + 4 + prefixLen // magic, stream index, nonce prefix
+	nonceLen  = 12
+	tagLen    = 16
+)
+
+// ErrCorrupt reports a frame that is malformed or fails authentication: the
+// wrong key, or a damaged or tampered file.
+var ErrCorrupt = errors.New("encrypt: corrupt or tampered data, or wrong key")
+
+// ErrUnfinished reports a stream that ends without its final frame: one
+// still being written, or one cut short.
+var ErrUnfinished = errors.New("encrypt: stream ends without its final frame: still being written, or truncated")
+
+// ParseKey decodes a 32-byte key given as hex or standard base64.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if key, err := hex.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(s); err == nil && len(key) == KeySize {
+		return key, nil
+	}
+	return nil, fmt.Errorf("encrypt: key must be %d bytes, hex or base64 encoded", KeySize)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	return aead, nil
+}
+
+// stream seals and opens the frames of one stream, in order.
+type stream struct {
+	aead   cipher.AEAD
+	header [headerLen]byte
+	n      uint32 // number of the next frame
+}
+
+// params returns the nonce and additional data of the next frame, sealing
+// size bytes.
+func (s *stream) params(final bool, size int) (nonce, ad []byte) {
+	var flag byte
+	if final {
+		flag = 1
+	}
+	nonce = make([]byte, 0, nonceLen)
+	nonce = append(nonce, s.header[len(magic)+4:]...)
+	nonce = binary.BigEndian.AppendUint32(nonce, s.n)
+	nonce = append(nonce, flag)
+
+	ad = make([]byte, 0, headerLen+9)
+	ad = append(ad, s.header[:]...)
+	ad = binary.BigEndian.AppendUint32(ad, s.n)
+	ad = append(ad, flag)
+	ad = binary.BigEndian.AppendUint32(ad, uint32(size)) //nolint:gosec // bounded by frameSize
+	return nonce, ad
+}
+
+// seal appends the next frame, holding plain, to dst. Final frames are
+// empty, and frames holding data are not, so readers tell them apart by
+// size.
+func (s *stream) seal(dst, plain []byte, final bool) ([]byte, error) {
+	if s.n == math.MaxUint32 {
+		return nil, errors.New("encrypt: too many frames in one stream")
+	}
+	size := len(plain) + tagLen
+	nonce, ad := s.params(final, size)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(size)) //nolint:gosec // bounded by frameSize
+	dst = s.aead.Seal(dst, nonce, plain, ad)
+	s.n++
+	return dst, nil
+}
+
+// open returns the plaintext of the next frame's sealed payload.
+func (s *stream) open(sealed []byte, final bool) ([]byte, error) {
+	nonce, ad := s.params(final, len(sealed))
+	plain, err := s.aead.Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	s.n++
+	return plain, nil
+}
+
+// Writer buffers plaintext and writes it to the underlying writer as sealed
+// frames of a new stream. Data is only written, and durable, once a frame is
+// sealed: when the buffer fills or on Flush. Close ends the stream.
+type Writer struct {
+	w       io.Writer
+	s       stream
+	buf     []byte
+	started bool // the header has been written
+	closed  bool
+}
+
+// NewWriter returns a Writer sealing a new stream to w with key. Nothing is
+// written until the first frame.
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	return newWriter(w, key, 0)
+}
+
+func newWriter(w io.Writer, key []byte, index uint32) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	wr := &Writer{w: w, s: stream{aead: aead}, buf: make([]byte, 0, frameSize)}
+	copy(wr.s.header[:], magic)
+	binary.BigEndian.PutUint32(wr.s.header[len(magic):], index)
+	if _, err := rand.Read(wr.s.header[len(magic)+4:]); err != nil {
+		return nil, fmt.Errorf("encrypt: nonce: %w", err)
+	}
+	return wr, nil
+}
+
+// Append returns a Writer adding a new stream to the encrypted file f, open
+// for reading and writing, after the streams already in it. A last stream
+// left unfinished by a crash is finished first, without a frame whose write
+// was cut short; this is also why a truncation made while no writer had the
+// file open goes unnoticed once the next one appends.
+func Append(f *os.File, key []byte) (*Writer, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	if info.Size() == 0 {
+		return newWriter(f, key, 0)
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekEnd); err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+
+	r := &Reader{r: bufio.NewReader(io.NewSectionReader(f, 0, info.Size())), aead: aead}
+	for err == nil {
+		_, err = r.next()
+	}
+	switch {
+	case errors.Is(err, io.EOF):
+	case errors.Is(err, ErrUnfinished), r.torn:
+		if err := f.Truncate(r.off); err != nil {
+			return nil, fmt.Errorf("encrypt: %w", err)
+		}
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			return nil, fmt.Errorf("encrypt: %w", err)
+		}
+		if r.s != nil {
+			frame, err := r.s.seal(nil, nil, true)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := f.Write(frame); err != nil {
+				return nil, fmt.Errorf("encrypt: write: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("encrypt: %s: %w", f.Name(), err)
+	}
+	return newWriter(f, key, r.streams)
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("encrypt: write after close")
+	}
+	n := len(p)
+	for len(p) > 0 {
+		take := min(frameSize-len(w.buf), len(p))
+		w.buf = append(w.buf, p[:take]...)
+		p = p[take:]
+		if len(w.buf) == frameSize {
+			if err := w.Flush(); err != nil {
+				return n - len(p) - take, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush seals any buffered plaintext into a frame.
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	var dst []byte
+	if !w.started {
+		dst = w.s.header[:]
+	}
+	frame, err := w.s.seal(dst, w.buf, false)
+	if err != nil {
+		return err
+	}
+	w.started = true
+	if _, err := w.w.Write(frame); err != nil {
+		return fmt.Errorf("encrypt: write: %w", err)
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close flushes and seals the stream's final frame. It does not close the
+// underlying writer. A stream nothing was written to is left out entirely.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	if !w.started {
+		return nil
+	}
+	frame, err := w.s.seal(nil, nil, true)
+	if err != nil {
+		return err
+	}
+	if _, err := w.w.Write(frame); err != nil {
+		return fmt.Errorf("encrypt: write: %w", err)
+	}
+	return nil
+}
+
+// Reader opens the frames read from an underlying reader.
+type Reader struct {
+	r    *bufio.Reader
+	aead cipher.AEAD
+	buf  []byte // opened plaintext not yet returned
+	err  error
+
+	s       *stream // the stream being read; nil between streams
+	streams uint32  // streams begun
+	off     int64   // bytes read through the last whole header or frame
+	torn    bool    // the data ended inside a header or frame
+}
+
+// NewReader returns a Reader opening frames from r with key.
+func NewReader(r io.Reader, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{r: bufio.NewReader(r), aead: aead}, nil
+}
+
+// Read returns the plaintext of the streams in order, then io.EOF if the
+// last was finished, or ErrUnfinished if not.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.buf, r.err = r.next()
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// next opens the next frame, returning io.EOF at a clean end of the data and
+// an empty plaintext for a final frame.
+func (r *Reader) next() ([]byte, error) {
+	if r.s == nil {
+		var header [headerLen]byte
+		if _, err := io.ReadFull(r.r, header[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, io.EOF
+			}
+			r.torn = true
+			return nil, ErrCorrupt
+		}
+		if string(header[:len(magic)]) != magic || binary.BigEndian.Uint32(header[len(magic):]) != r.streams {
+			return nil, ErrCorrupt
+		}
+		r.s = &stream{aead: r.aead, header: header}
+		r.streams++
+		r.off += int64(headerLen)
+	}
+
+	var length [4]byte
+	if _, err := io.ReadFull(r.r, length[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrUnfinished
+		}
+		r.torn = true
+		return nil, ErrCorrupt
+	}
+	size := int(binary.BigEndian.Uint32(length[:]))
+	if size < tagLen || size > frameSize+tagLen {
+		return nil, ErrCorrupt
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(r.r, sealed); err != nil {
+		r.torn = true
+		return nil, ErrCorrupt
+	}
+	final := size == tagLen
+	plain, err := r.s.open(sealed, final)
+	if err != nil {
+		return nil, err
+	}
+	r.off += int64(len(length) + size)
+	if final {
+		r.s = nil
+	}
+	return plain, nil
+}
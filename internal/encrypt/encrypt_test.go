@@ -0,0 +1,317 @@
+package encrypt_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/encrypt"
+)
+
+var testKey = bytes.Repeat([]byte{0x42}, encrypt.KeySize)
+
+func TestParseKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		in      string
+		wantErr bool
+	}{
+		{name: "hex", in: hex.EncodeToString(testKey)},
+		{name: "base64", in: base64.StdEncoding.EncodeToString(testKey) + "\n"},
+		{name: "short", in: hex.EncodeToString(testKey[:16]), wantErr: true},
+		{name: "garbage", in: "not a key", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			key, err := encrypt.ParseKey(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseKey(%q) succeeded, want error", tt.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseKey(%q): %v", tt.in, err)
+			}
+			if !bytes.Equal(key, testKey) {
+				t.Errorf("ParseKey(%q) = %x, want %x", tt.in, key, testKey)
+			}
+		})
+	}
+}
+
+func seal(t *testing.T, chunks ...string) []byte {
+	t.Helper()
+	var out bytes.Buffer
+	w, err := encrypt.NewWriter(&out, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		if _, err := io.WriteString(w, c); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return out.Bytes()
+}
+
+func open(data []byte, key []byte) (string, error) {
+	r, err := encrypt.NewReader(bytes.NewReader(data), key)
+	if err != nil {
+		return "", err
+	}
+	b, err := io.ReadAll(r)
+	return string(b), err
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	big := strings.Repeat("x", 200<<10) // spans several frames
+	tests := []struct {
+		name   string
+		chunks []string
+	}{
+		{name: "empty"},
+		{name: "one frame", chunks: []string{`{"id":"1"}` + "\n"}},
+		{name: "appended frames", chunks: []string{"first\n", "second\n"}},
+		{name: "large", chunks: []string{big}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			data := seal(t, tt.chunks...)
+			if len(tt.chunks) > 0 && bytes.Contains(data, []byte(tt.chunks[0])) {
+				t.Fatal("ciphertext contains the plaintext")
+			}
+			got, err := open(data, testKey)
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if want := strings.Join(tt.chunks, ""); got != want {
+				t.Errorf("round trip returned %d bytes, want %d", len(got), len(want))
+			}
+		})
+	}
+}
+
+func TestReader_Rejects(t *testing.T) {
+	t.Parallel()
+
+	data := seal(t, "secret query\n")
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	otherKey := bytes.Repeat([]byte{0x24}, encrypt.KeySize)
+
+	tests := []struct {
+		name string
+		data []byte
+		key  []byte
+	}{
+		{name: "wrong key", data: data, key: otherKey},
+		{name: "tampered", data: tampered, key: testKey},
+		{name: "truncated", data: data[:len(data)-3], key: testKey},
+		{name: "plaintext", data: []byte(`{"id":"1"}` + "\n"), key: testKey},
+		{name: "trailing garbage", data: append(bytes.Clone(data), "STE2"...), key: testKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := open(tt.data, tt.key); !errors.Is(err, encrypt.ErrCorrupt) {
+				t.Errorf("read error = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestSealer(t *testing.T) {
+	t.Parallel()
+
+	s, err := encrypt.NewSealer(testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := s.Seal([]byte("SELECT 1"), []byte("row 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plain, err := s.Open(sealed, []byte("row 1")); err != nil || string(plain) != "SELECT 1" {
+		t.Fatalf("Open = %q, %v, want SELECT 1", plain, err)
+	}
+	if again, _ := s.Seal([]byte("SELECT 1"), []byte("row 1")); bytes.Equal(again, sealed) {
+		t.Error("sealing twice gave the same record")
+	}
+	if _, err := s.Open(sealed, []byte("row 2")); !errors.Is(err, encrypt.ErrCorrupt) {
+		t.Errorf("Open with other additional data error = %v, want ErrCorrupt", err)
+	}
+	if _, err := s.Open(sealed[:10], []byte("row 1")); !errors.Is(err, encrypt.ErrCorrupt) {
+		t.Errorf("Open of a short record error = %v, want ErrCorrupt", err)
+	}
+
+	other, err := encrypt.NewSealer(bytes.Repeat([]byte{0x43}, encrypt.KeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Hash("tx1") != s.Hash("tx1") || s.Hash("tx1") == s.Hash("tx2") || s.Hash("tx1") == other.Hash("tx1") {
+		t.Error("Hash should be stable for a key and differ between values and keys")
+	}
+}
+
+// frames splits a stream sealed by seal into its header and frames.
+func frames(t *testing.T, data []byte) (header []byte, out [][]byte) {
+	t.Helper()
+	const headerLen = 15
+	header, data = data[:headerLen], data[headerLen:]
+	for len(data) > 0 {
+		n := 4 + int(binary.BigEndian.Uint32(data))
+		out = append(out, data[:n])
+		data = data[n:]
+	}
+	return header, out
+}
+
+func TestReader_RejectsRearrangedFrames(t *testing.T) {
+	t.Parallel()
+
+	header, fs := frames(t, seal(t, "first\n", "second\n", "third\n"))
+	if len(fs) != 4 {
+		t.Fatalf("sealed %d frames, want 3 and a final one", len(fs))
+	}
+	otherHeader, other := frames(t, seal(t, "other\n"))
+	join := func(header []byte, fs ...[]byte) []byte {
+		return append(bytes.Clone(header), bytes.Join(fs, nil)...)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "dropped", data: join(header, fs[0], fs[2], fs[3])},
+		{name: "reordered", data: join(header, fs[1], fs[0], fs[2], fs[3])},
+		{name: "replayed", data: join(header, fs[0], fs[0], fs[1], fs[2], fs[3])},
+		{name: "spliced", data: join(header, fs[0], other[0], fs[2], fs[3])},
+		{name: "other header", data: join(otherHeader, fs...)},
+		{name: "data after the end", data: join(header, fs[0], fs[3], fs[1])},
+		{name: "second stream out of place", data: append(join(header, fs...), join(header, fs...)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := open(tt.data, testKey); !errors.Is(err, encrypt.ErrCorrupt) {
+				t.Errorf("read error = %v, want ErrCorrupt", err)
+			}
+		})
+	}
+}
+
+func TestReader_Unfinished(t *testing.T) {
+	t.Parallel()
+
+	data := seal(t, "first\n", "second\n")
+	_, fs := frames(t, data)
+	got, err := open(data[:len(data)-len(fs[2])], testKey)
+	if !errors.Is(err, encrypt.ErrUnfinished) {
+		t.Fatalf("read error = %v, want ErrUnfinished", err)
+	}
+	if got != "first\nsecond\n" {
+		t.Errorf("read %q before the error, want both frames", got)
+	}
+}
+
+func appendTo(t *testing.T, path string, chunks ...string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	w, err := encrypt.Append(f, testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks {
+		if _, err := io.WriteString(w, c); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) (string, error) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return open(b, testKey)
+}
+
+func TestAppend(t *testing.T) {
+	t.Parallel()
+
+	t.Run("finished", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "a.enc")
+		appendTo(t, path, "first\n")
+		appendTo(t, path)
+		appendTo(t, path, "second\n", "third\n")
+		if got, err := readFile(t, path); err != nil || got != "first\nsecond\nthird\n" {
+			t.Errorf("read %q, %v; want all three lines", got, err)
+		}
+	})
+
+	t.Run("after a crash", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "a.enc")
+		data := seal(t, "first\n", "second\n")
+		_, fs := frames(t, data)
+		// The final frame was never written, and the second only in part.
+		torn := data[:len(data)-len(fs[2])-3]
+		if err := os.WriteFile(path, torn, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		appendTo(t, path, "third\n")
+		if got, err := readFile(t, path); err != nil || got != "first\nthird\n" {
+			t.Errorf("read %q, %v; want the whole frames on both sides of the crash", got, err)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "a.enc")
+		data := seal(t, "first\n")
+		data[20] ^= 1
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = f.Close() }()
+		if _, err := encrypt.Append(f, testKey); !errors.Is(err, encrypt.ErrCorrupt) {
+			t.Errorf("Append error = %v, want ErrCorrupt", err)
+		}
+	})
+}
@@ -0,0 +1,65 @@
+package encrypt
+
+import (
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Sealer seals individual records, such as the rows of the event store,
+// each under a random nonce and bound to additional data naming the record,
+// so a sealed record copied to another place fails to open.
+type Sealer struct {
+	aead cipher.AEAD
+	mac  []byte // key of Hash
+}
+
+// NewSealer returns a Sealer using key.
+func NewSealer(key []byte) (*Sealer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	// Hashes use their own key, derived from key, so they reveal nothing
+	// about the one sealing.
+	mac, err := hkdf.Key(sha256.New, key, nil, "sql-tap record hash", sha256.Size)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt: %w", err)
+	}
+	return &Sealer{aead: aead, mac: mac}, nil
+}
+
+// Seal returns plain sealed with ad as additional data: a nonce followed by
+// the ciphertext.
+func (s *Sealer) Seal(plain, ad []byte) ([]byte, error) {
+	out := make([]byte, nonceLen, nonceLen+len(plain)+tagLen)
+	if _, err := rand.Read(out); err != nil {
+		return nil, fmt.Errorf("encrypt: nonce: %w", err)
+	}
+	return s.aead.Seal(out, out, plain, ad), nil
+}
+
+// Open returns the plaintext of a record Seal sealed with the same ad, or
+// ErrCorrupt.
+func (s *Sealer) Open(sealed, ad []byte) ([]byte, error) {
+	if len(sealed) < nonceLen+tagLen {
+		return nil, ErrCorrupt
+	}
+	plain, err := s.aead.Open(nil, sealed[:nonceLen], sealed[nonceLen:], ad)
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+	return plain, nil
+}
+
+// Hash returns a keyed hash of v, hex encoded, for looking records up by
+// a value without storing it.
+func (s *Sealer) Hash(v string) string {
+	m := hmac.New(sha256.New, s.mac)
+	m.Write([]byte(v))
+	return hex.EncodeToString(m.Sum(nil)[:16])
+}
@@ -0,0 +1,260 @@
+// Package eventsink publishes captured events to message brokers, Kafka
+// topics and NATS JetStream subjects, so that they can feed pipelines of
+// their own. Kafka is reached through franz-go and NATS through nats.go, so
+// both get their client's TLS, authentication, and delivery guarantees.
+package eventsink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+const (
+	// clientName identifies sql-tap to brokers.
+	clientName = "sql-tap"
+
+	defaultTimeout = 10 * time.Second
+	defaultRetries = 3
+
+	// firstBackoff is the pause before the first retry, doubled for each
+	// one after it up to maxBackoff.
+	firstBackoff = 100 * time.Millisecond
+	maxBackoff   = 5 * time.Second
+)
+
+// Format is the encoding of published events.
+type Format string
+
+const (
+	// FormatJSON publishes each event as a JSON object in the sql-tap watch
+	// record format.
+	FormatJSON Format = "json"
+	// FormatProtobuf publishes each event as a serialized tap.v1.QueryEvent.
+	FormatProtobuf Format = "protobuf"
+)
+
+// ParseFormat parses a user-supplied format name; "" is FormatJSON.
+func ParseFormat(s string) (Format, error) {
+	switch Format(s) {
+	case "", FormatJSON:
+		return FormatJSON, nil
+	case FormatProtobuf:
+		return FormatProtobuf, nil
+	default:
+		return "", fmt.Errorf("eventsink: unknown format %q (want json or protobuf)", s)
+	}
+}
+
+// contentType returns the MIME type published with events in f.
+func (f Format) contentType() string {
+	if f == FormatProtobuf {
+		return "application/x-protobuf"
+	}
+	return "application/json"
+}
+
+// message is one encoded event.
+type message struct {
+	id    string // the event ID, for deduplication
+	key   []byte // the connection ID, so a connection's events stay in order
+	value []byte
+	time  time.Time // when the event started
+}
+
+// publisher delivers messages to a broker.
+type publisher interface {
+	// publish delivers msgs, returning how many were delivered and, when
+	// some were not, why and which of them are worth trying again.
+	publish(ctx context.Context, msgs []message) (delivered int, retry []message, err error)
+	close() error
+}
+
+// Option configures a Sink.
+type Option func(*Sink)
+
+// WithFormat sets the encoding of published events (default FormatJSON).
+func WithFormat(f Format) Option {
+	return func(s *Sink) {
+		s.format = f
+	}
+}
+
+// WithRetries sets how many times a failed publish is retried, with
+// exponential backoff, before its events are dropped (default 3).
+func WithRetries(n int) Option {
+	return func(s *Sink) {
+		s.retries = n
+	}
+}
+
+// WithTimeout bounds connecting to the broker and each request to it
+// (default 10s).
+func WithTimeout(d time.Duration) Option {
+	return func(s *Sink) {
+		s.timeout = d
+	}
+}
+
+// WithPassword sets the password for the URL's user: the Kafka SASL
+// password, or the NATS password, or token when the URL names no user. It
+// keeps the secret out of the URL.
+func WithPassword(p string) Option {
+	return func(s *Sink) {
+		s.password = p
+	}
+}
+
+// WithSASL sets the SASL mechanism a Kafka URL's user authenticates with
+// (default SASLPlain); see ParseSASL.
+func WithSASL(mechanism string) Option {
+	return func(s *Sink) {
+		s.sasl = mechanism
+	}
+}
+
+// WithTLS connects to the brokers over TLS, configured by c.
+func WithTLS(c *tls.Config) Option {
+	return func(s *Sink) {
+		s.tls = c
+	}
+}
+
+// Sink publishes events to one Kafka topic or NATS JetStream subject.
+// Send may be called from several goroutines; batches are published one at
+// a time.
+type Sink struct {
+	url      string // redacted
+	format   Format
+	retries  int
+	timeout  time.Duration
+	password string
+	sasl     string
+	tls      *tls.Config
+
+	mu  sync.Mutex
+	pub publisher
+}
+
+// Open returns a Sink publishing to rawURL, which is one of
+//
+//	kafka://[user[:password]@]host:9092[,host:9092...]/topic
+//	nats://[user[:password]@]host:4222/subject
+//
+// A Kafka user authenticates with SASL. Connections are made on the first
+// Send.
+func Open(rawURL string, opts ...Option) (*Sink, error) {
+	s := &Sink{
+		format:  FormatJSON,
+		retries: defaultRetries,
+		timeout: defaultTimeout,
+	}
+	for _, o := range opts {
+		o(s)
+	}
+	if s.retries < 0 || s.timeout <= 0 {
+		return nil, errors.New("eventsink: retries must not be negative and timeout must be positive")
+	}
+	mech, err := ParseSASL(s.sasl)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme, _, _ := strings.Cut(rawURL, "://")
+	switch scheme {
+	case "kafka":
+		k, redacted, err := newKafka(rawURL, s.password, mech, s.tls, s.format.contentType(), s.timeout)
+		if err != nil {
+			return nil, err
+		}
+		s.url, s.pub = redacted, k
+	case "nats":
+		if s.sasl != "" {
+			return nil, errors.New("eventsink: SASL is only for kafka:// URLs")
+		}
+		n, redacted, err := newNATS(rawURL, s.password, s.tls, s.format.contentType(), s.timeout)
+		if err != nil {
+			return nil, err
+		}
+		s.url, s.pub = redacted, n
+	default:
+		return nil, fmt.Errorf("eventsink: %q is not a kafka:// or nats:// URL", rawURL)
+	}
+	return s, nil
+}
+
+// String returns the sink's URL without its password.
+func (s *Sink) String() string {
+	return s.url
+}
+
+// Send publishes events, retrying failures. Events that still could not
+// be delivered are dropped and reported in the error.
+func (s *Sink) Send(ctx context.Context, events []*tapv1.QueryEvent) error {
+	msgs := make([]message, 0, len(events))
+	for _, ev := range events {
+		value, err := s.encode(ev)
+		if err != nil {
+			return fmt.Errorf("eventsink: encode %s: %w", ev.GetId(), err)
+		}
+		msgs = append(msgs, message{
+			id:    ev.GetId(),
+			key:   []byte(ev.GetConnId()),
+			value: value,
+			time:  ev.GetStartTime().AsTime(),
+		})
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := 0
+	backoff := firstBackoff
+	for attempt := 0; ; attempt++ {
+		delivered, retry, err := s.pub.publish(ctx, msgs)
+		sent += delivered
+		if err == nil {
+			return nil
+		}
+		if len(retry) == 0 || attempt == s.retries || s.wait(ctx, backoff) != nil {
+			return fmt.Errorf("eventsink: %s: %d of %d events dropped: %w", s.url, len(events)-sent, len(events), err)
+		}
+		msgs = retry
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// Close closes the sink's connections.
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pub.close()
+}
+
+// wait pauses for d before a retry, or until ctx is done.
+func (s *Sink) wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+func (s *Sink) encode(ev *tapv1.QueryEvent) ([]byte, error) {
+	if s.format == FormatProtobuf {
+		return proto.Marshal(ev)
+	}
+	return json.Marshal(export.NewRecord(ev))
+}
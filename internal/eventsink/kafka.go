@@ -0,0 +1,176 @@
+package eventsink
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+)
+
+const defaultKafkaPort = "9092"
+
+// SASL mechanisms for Kafka sinks.
+const (
+	SASLPlain       = "plain"
+	SASLScramSHA256 = "scram-sha-256"
+	SASLScramSHA512 = "scram-sha-512"
+)
+
+// ParseSASL parses a user-supplied SASL mechanism name; "" is SASLPlain.
+func ParseSASL(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", SASLPlain:
+		return SASLPlain, nil
+	case SASLScramSHA256:
+		return SASLScramSHA256, nil
+	case SASLScramSHA512:
+		return SASLScramSHA512, nil
+	default:
+		return "", fmt.Errorf("eventsink: unknown SASL mechanism %q (want plain, scram-sha-256, or scram-sha-512)", s)
+	}
+}
+
+// kafka produces to one topic with a franz-go client. Writes are idempotent
+// and acknowledged by all in-sync replicas, and batches are compressed with
+// snappy where the brokers support it. Each message goes to a partition
+// chosen by hashing its key, as Kafka's own clients do.
+type kafka struct {
+	client      *kgo.Client
+	contentType string
+	timeout     time.Duration
+}
+
+// newKafka parses kafka://[user[:password]@]host:port[,host:port...]/topic,
+// returning it redacted too. password, if set, overrides the URL's; a user
+// authenticates with SASL mechanism mech. With tlsConfig, brokers are
+// dialed over TLS.
+func newKafka(rawURL, password, mech string, tlsConfig *tls.Config, contentType string, timeout time.Duration) (*kafka, string, error) {
+	hosts, topic, _ := strings.Cut(strings.TrimPrefix(rawURL, "kafka://"), "/")
+	var user *url.Userinfo
+	if i := strings.LastIndex(hosts, "@"); i >= 0 {
+		u, err := url.Parse("kafka://" + hosts[:i+1] + "host")
+		if err != nil {
+			return nil, "", fmt.Errorf("eventsink: %w", err)
+		}
+		user, hosts = u.User, hosts[i+1:]
+	}
+	if hosts == "" || topic == "" || strings.Contains(topic, "/") {
+		return nil, "", fmt.Errorf("eventsink: %q: want kafka://[user@]host:port[,host:port...]/topic", redactKafka(rawURL))
+	}
+	var bootstrap []string
+	for h := range strings.SplitSeq(hosts, ",") {
+		if _, _, err := net.SplitHostPort(h); err != nil {
+			h = net.JoinHostPort(h, defaultKafkaPort)
+		}
+		bootstrap = append(bootstrap, h)
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(bootstrap...),
+		kgo.DefaultProduceTopic(topic),
+		kgo.ClientID(clientName),
+		kgo.DialTimeout(timeout),
+		kgo.ProduceRequestTimeout(timeout),
+	}
+	if tlsConfig != nil {
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	if user == nil && password != "" {
+		return nil, "", fmt.Errorf("eventsink: %q: a password needs a SASL user in the URL", rawURL)
+	}
+	if user != nil {
+		if pass, ok := user.Password(); ok && password == "" {
+			password = pass
+		}
+		if password == "" {
+			return nil, "", fmt.Errorf("eventsink: %q: SASL user %s has no password", redactKafka(rawURL), user.Username())
+		}
+		opts = append(opts, kgo.SASL(saslMechanism(mech, user.Username(), password)))
+	}
+	client, err := kgo.NewClient(opts...)
+	if err != nil {
+		return nil, "", fmt.Errorf("eventsink: %w", err)
+	}
+	return &kafka{client: client, contentType: contentType, timeout: timeout}, redactKafka(rawURL), nil
+}
+
+// saslMechanism returns mech authenticating user with password.
+func saslMechanism(mech, user, password string) sasl.Mechanism {
+	switch mech {
+	case SASLScramSHA256:
+		return scram.Auth{User: user, Pass: password}.AsSha256Mechanism()
+	case SASLScramSHA512:
+		return scram.Auth{User: user, Pass: password}.AsSha512Mechanism()
+	default:
+		return plain.Auth{User: user, Pass: password}.AsMechanism()
+	}
+}
+
+// redactKafka returns rawURL with its password, if any, replaced.
+func redactKafka(rawURL string) string {
+	rest := strings.TrimPrefix(rawURL, "kafka://")
+	hosts, topic, hasTopic := strings.Cut(rest, "/")
+	i := strings.LastIndex(hosts, "@")
+	if i < 0 {
+		return rawURL
+	}
+	user, _, hasPass := strings.Cut(hosts[:i], ":")
+	if !hasPass {
+		return rawURL
+	}
+	out := "kafka://" + user + ":xxxxx@" + hosts[i+1:]
+	if hasTopic {
+		out += "/" + topic
+	}
+	return out
+}
+
+func (k *kafka) publish(ctx context.Context, msgs []message) (int, []message, error) {
+	recs := make([]*kgo.Record, len(msgs))
+	byRecord := make(map[*kgo.Record]message, len(msgs))
+	for i, m := range msgs {
+		recs[i] = &kgo.Record{
+			Key:       m.key,
+			Value:     m.value,
+			Timestamp: m.time,
+			Headers:   []kgo.RecordHeader{{Key: "content-type", Value: []byte(k.contentType)}},
+		}
+		byRecord[recs[i]] = m
+	}
+
+	// Records are timestamped with their event's start, so the client's
+	// delivery timeout, counted from the timestamp, would expire them all;
+	// the context bounds the wait instead, and Send retries what failed.
+	ctx, cancel := context.WithTimeout(ctx, k.timeout)
+	defer cancel()
+	delivered := 0
+	var retry []message
+	var firstErr error
+	for _, r := range k.client.ProduceSync(ctx, recs...) {
+		if r.Err == nil {
+			delivered++
+			continue
+		}
+		retry = append(retry, byRecord[r.Record])
+		if firstErr == nil {
+			firstErr = r.Err
+		}
+	}
+	if firstErr != nil {
+		return delivered, retry, fmt.Errorf("kafka: %w", firstErr)
+	}
+	return delivered, nil, nil
+}
+
+func (k *kafka) close() error {
+	k.client.Close()
+	return nil
+}
@@ -0,0 +1,153 @@
+package eventsink
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	natsgo "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const defaultNATSPort = "4222"
+
+// nats publishes to a JetStream subject with the nats.go client, which
+// reconnects on its own. Each message carries its event ID as Nats-Msg-Id,
+// so the stream drops the copies a retry sends of messages it already
+// stored, and a batch is published asynchronously, then waits for the
+// stream's acknowledgement of every message.
+type nats struct {
+	server      string
+	subject     string
+	opts        []natsgo.Option
+	contentType string
+	timeout     time.Duration
+
+	conn *natsgo.Conn // nil until connected
+	js   jetstream.JetStream
+}
+
+// newNATS parses nats://[user[:password]@]host:port/subject, returning it
+// redacted too. password, if set, overrides the URL's; a user without a
+// password is a token. With tlsConfig, the connection is upgraded to TLS.
+func newNATS(rawURL, password string, tlsConfig *tls.Config, contentType string, timeout time.Duration) (*nats, string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("eventsink: %w", err)
+	}
+	subject := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || subject == "" || strings.ContainsAny(subject, "/ \t*>") {
+		return nil, "", fmt.Errorf("eventsink: %q: want nats://[user[:password]@]host:port/subject", u.Redacted())
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultNATSPort)
+	}
+	n := &nats{
+		server:      "nats://" + addr,
+		subject:     subject,
+		contentType: contentType,
+		timeout:     timeout,
+		opts:        []natsgo.Option{natsgo.Name(clientName), natsgo.Timeout(timeout)},
+	}
+	if tlsConfig != nil {
+		n.opts = append(n.opts, natsgo.Secure(tlsConfig))
+	}
+	if u.User != nil {
+		pass, hasPass := u.User.Password()
+		switch {
+		case password != "":
+			n.opts = append(n.opts, natsgo.UserInfo(u.User.Username(), password))
+		case hasPass:
+			n.opts = append(n.opts, natsgo.UserInfo(u.User.Username(), pass))
+		default:
+			n.opts = append(n.opts, natsgo.Token(u.User.Username()))
+			u.User = url.User("xxxxx")
+		}
+	} else if password != "" {
+		n.opts = append(n.opts, natsgo.Token(password))
+	}
+	return n, u.Redacted(), nil
+}
+
+func (n *nats) publish(ctx context.Context, msgs []message) (int, []message, error) {
+	if n.conn == nil || n.conn.IsClosed() {
+		if err := n.connect(); err != nil {
+			return 0, msgs, err
+		}
+	}
+
+	futures := make([]jetstream.PubAckFuture, 0, len(msgs))
+	var err error
+	for _, m := range msgs {
+		msg := &natsgo.Msg{
+			Subject: n.subject,
+			Data:    m.value,
+			Header:  natsgo.Header{"Content-Type": {n.contentType}},
+		}
+		f, pubErr := n.js.PublishMsgAsync(msg, jetstream.WithMsgID(m.id))
+		if pubErr != nil {
+			err = pubErr
+			break
+		}
+		futures = append(futures, f)
+	}
+	// Messages not handed to the client are retried along with the ones
+	// the stream did not acknowledge.
+	retry := msgs[len(futures):]
+
+	ctx, cancel := context.WithTimeout(ctx, n.timeout)
+	defer cancel()
+	delivered := 0
+	for i, f := range futures {
+		select {
+		case <-f.Ok():
+			delivered++
+			continue
+		case ackErr := <-f.Err():
+			if err == nil {
+				err = ackErr
+			}
+		case <-ctx.Done():
+			if err == nil {
+				err = ctx.Err()
+			}
+		}
+		retry = append(retry, msgs[i])
+	}
+	if err == nil {
+		return delivered, nil, nil
+	}
+	if errors.Is(err, natsgo.ErrNoResponders) || errors.Is(err, jetstream.ErrNoStreamResponse) {
+		err = fmt.Errorf("no JetStream stream captures %s", n.subject)
+	}
+	return delivered, retry, fmt.Errorf("nats: %w", err)
+}
+
+func (n *nats) close() error {
+	if n.conn != nil {
+		n.conn.Close()
+		n.conn = nil
+	}
+	return nil
+}
+
+// connect dials the server and opens its JetStream context.
+func (n *nats) connect() error {
+	conn, err := natsgo.Connect(n.server, n.opts...)
+	if err != nil {
+		return fmt.Errorf("nats: connect %s: %w", n.server, err)
+	}
+	js, err := jetstream.New(conn, jetstream.WithPublishAsyncTimeout(n.timeout))
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("nats: %w", err)
+	}
+	n.conn, n.js = conn, js
+	return nil
+}
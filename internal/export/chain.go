@@ -0,0 +1,123 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// maxLineSize bounds one NDJSON record read by Verify.
+const maxLineSize = 16 << 20
+
+// ErrChainBroken is returned by Verify when a record's prev_hash does not
+// match the line before it.
+var ErrChainBroken = errors.New("export: hash chain broken")
+
+// LineHash returns the hex SHA-256 of an NDJSON line, without its newline.
+func LineHash(line []byte) string {
+	sum := sha256.Sum256(bytes.TrimSuffix(line, []byte("\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// ChainWriter writes NDJSON records in a hash chain: each record's prev_hash
+// is the LineHash of the line before it, so editing, inserting, or removing a
+// line breaks the chain at the next one. Lines at the end can still be
+// dropped or replaced unnoticed, so Head is worth recording elsewhere.
+type ChainWriter struct {
+	w    io.Writer
+	head string
+}
+
+// NewChainWriter returns a ChainWriter appending to w after a line whose
+// LineHash is head; head is "" at the start of a file.
+func NewChainWriter(w io.Writer, head string) *ChainWriter {
+	return &ChainWriter{w: w, head: head}
+}
+
+func (c *ChainWriter) Write(ev *tapv1.QueryEvent) error {
+	rec := NewRecord(ev)
+	rec.PrevHash = c.head
+	if rec.PrevHash == "" {
+		rec.PrevHash = genesis
+	}
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("export: encode json: %w", err)
+	}
+	if _, err := c.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("export: write: %w", err)
+	}
+	c.head = LineHash(line)
+	return nil
+}
+
+func (c *ChainWriter) Flush() error { return nil }
+
+// Head returns the LineHash of the last line written, or the head the
+// writer was created with.
+func (c *ChainWriter) Head() string {
+	return c.head
+}
+
+// genesis is the prev_hash of a chain's first record: 64 zeros, the length
+// of a hash, so every chained record carries one.
+const genesis = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// ChainReport is what Verify found in an NDJSON stream.
+type ChainReport struct {
+	Unchained int    // lines before the first chained one, written before chaining was enabled
+	Chained   int    // lines from the first chained one on, all verified
+	Head      string // LineHash of the last line
+}
+
+// Verify checks the hash chain of the NDJSON in r, as a ChainWriter writes
+// it. Lines without a prev_hash are accepted only before the first chained
+// one. A mismatch returns an error wrapping ErrChainBroken, with the line
+// number; the report then covers the lines before it.
+func Verify(r io.Reader) (ChainReport, error) {
+	var rep ChainReport
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineSize)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec struct {
+			PrevHash string `json:"prev_hash"`
+		}
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return rep, fmt.Errorf("export: line %d: %w", n, err)
+		}
+		switch {
+		case rec.PrevHash == "" && rep.Chained == 0:
+			rep.Unchained++
+		case rec.PrevHash == "":
+			return rep, fmt.Errorf("%w at line %d: no prev_hash", ErrChainBroken, n)
+		case rec.PrevHash != wantPrev(rep):
+			return rep, fmt.Errorf("%w at line %d: prev_hash %s, want %s", ErrChainBroken, n, rec.PrevHash, wantPrev(rep))
+		default:
+			rep.Chained++
+		}
+		rep.Head = LineHash(line)
+	}
+	if err := sc.Err(); err != nil {
+		return rep, fmt.Errorf("export: %w", err)
+	}
+	return rep, nil
+}
+
+// wantPrev returns the prev_hash the next line must carry.
+func wantPrev(rep ChainReport) string {
+	if rep.Head == "" {
+		return genesis
+	}
+	return rep.Head
+}
@@ -0,0 +1,102 @@
+package export_test
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+// chained returns sampleEvents written three times over in a hash chain.
+func chained(t *testing.T) []string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	w := export.NewChainWriter(&buf, "")
+	for range 3 {
+		for _, ev := range sampleEvents() {
+			if err := w.Write(ev); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	lines := strings.SplitAfter(buf.String(), "\n")
+	lines = lines[:len(lines)-1]
+	if got := export.LineHash([]byte(lines[len(lines)-1])); got != w.Head() {
+		t.Errorf("Head = %s, want the last line's hash %s", w.Head(), got)
+	}
+	return lines
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	unchained := `{"id":"0","query":"SELECT 0"}` + "\n"
+	tests := []struct {
+		name          string
+		edit          func(lines []string) []string
+		wantBroken    int // line number; 0 for an intact chain
+		wantUnchained int
+	}{
+		{name: "intact", edit: func(l []string) []string { return l }},
+		{
+			name: "edited",
+			edit: func(l []string) []string {
+				l[2] = strings.Replace(l[2], "SELECT", "select", 1)
+				return l
+			},
+			wantBroken: 4,
+		},
+		{name: "removed", edit: func(l []string) []string { return append(l[:1], l[2:]...) }, wantBroken: 2},
+		{name: "reordered", edit: func(l []string) []string { l[1], l[2] = l[2], l[1]; return l }, wantBroken: 2},
+		{name: "head removed", edit: func(l []string) []string { return l[1:] }, wantBroken: 1},
+		{name: "unchained line inserted", edit: func(l []string) []string { return append(l[:3], append([]string{unchained}, l[3:]...)...) }, wantBroken: 4},
+		{name: "line prepended", edit: func(l []string) []string { return append([]string{unchained}, l...) }, wantBroken: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			lines := tt.edit(chained(t))
+			rep, err := export.Verify(strings.NewReader(strings.Join(lines, "")))
+			if tt.wantBroken == 0 {
+				if err != nil {
+					t.Fatalf("Verify: %v", err)
+				}
+				if rep.Chained != len(lines) || rep.Head != export.LineHash([]byte(lines[len(lines)-1])) {
+					t.Errorf("report = %+v, want %d chained lines", rep, len(lines))
+				}
+				return
+			}
+			if !errors.Is(err, export.ErrChainBroken) || !strings.Contains(err.Error(), "line "+strconv.Itoa(tt.wantBroken)+":") {
+				t.Errorf("Verify = %v, want the chain broken at line %d", err, tt.wantBroken)
+			}
+		})
+	}
+}
+
+func TestVerify_ChainedAfterUnchained(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"id":"0","query":"SELECT 0"}` + "\n")
+	// Chaining enabled on a file with unchained lines continues from its
+	// last line, as the archiver does after a restart.
+	w := export.NewChainWriter(&buf, export.LineHash(buf.Bytes()))
+	for _, ev := range sampleEvents() {
+		if err := w.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rep, err := export.Verify(&buf)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if rep.Unchained != 1 || rep.Chained != 2 || rep.Head != w.Head() {
+		t.Errorf("report = %+v, want 1 unchained and 2 chained lines ending at %s", rep, w.Head())
+	}
+}
@@ -0,0 +1,249 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Format selects the export encoding.
+type Format string
+
+const (
+	NDJSON Format = "json"
+	CSV    Format = "csv"
+)
+
+// ParseFormat parses a user-supplied format name.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "json", "ndjson":
+		return NDJSON, nil
+	case "csv":
+		return CSV, nil
+	}
+	return "", fmt.Errorf("export: unknown format %q (want json or csv)", s)
+}
+
+// Ext returns the conventional file extension for f, including the dot.
+func (f Format) Ext() string {
+	if f == CSV {
+		return ".csv"
+	}
+	return ".ndjson"
+}
+
+// Record is the exported shape of a captured query event.
+type Record struct {
+	ID            string            `json:"id"`
+	StartTime     string            `json:"start_time"` // RFC 3339 with nanoseconds
+	Op            string            `json:"op"`
+	Query         string            `json:"query"`
+	Fingerprint   string            `json:"fingerprint,omitempty"`
+	Args          []string          `json:"args"`
+	DurationMs    float64           `json:"duration_ms"`
+	QueueMs       float64           `json:"queue_ms,omitempty"` // parts of duration_ms, PostgreSQL only
+	ExecMs        float64           `json:"exec_ms,omitempty"`
+	FetchMs       float64           `json:"fetch_ms,omitempty"`
+	RowsAffected  int64             `json:"rows_affected"`
+	RequestBytes  int64             `json:"request_bytes,omitempty"`
+	ResponseBytes int64             `json:"response_bytes,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	Notice        string            `json:"notice,omitempty"`     // "SEVERITY: message" on Notice records
+	NoticeFor     string            `json:"notice_for,omitempty"` // ID of the statement that raised the notice
+	Queries       int64             `json:"queries,omitempty"`    // statements a connection ran, on Disconnect records
+	TxID          string            `json:"tx_id,omitempty"`
+	BatchID       string            `json:"batch_id,omitempty"`
+	BatchSize     int32             `json:"batch_size,omitempty"`
+	ConnID        string            `json:"conn_id,omitempty"`
+	ClientAddr    string            `json:"client_addr,omitempty"`
+	Listener      string            `json:"listener,omitempty"` // label of the proxy listener the client used
+	User          string            `json:"user,omitempty"`
+	Database      string            `json:"database,omitempty"`
+	BackendPID    uint32            `json:"backend_pid,omitempty"`
+	Session       uint64            `json:"session,omitempty"` // logical session on a pooler's server connection
+	Upstream      string            `json:"upstream,omitempty"`
+	Violation     string            `json:"violation,omitempty"` // policy rule the statement broke
+	Blocked       bool              `json:"blocked,omitempty"`   // the policy kept the statement from the server
+	Tags          []string          `json:"tags,omitempty"`
+	Fields        map[string]string `json:"fields,omitempty"` // values from the daemon's field extraction rules
+	TraceID       string            `json:"trace_id,omitempty"`
+	SpanID        string            `json:"span_id,omitempty"`
+	Route         string            `json:"route,omitempty"`
+	RequestID     string            `json:"request_id,omitempty"`
+	Caller        []string          `json:"caller,omitempty"`     // call stack that issued the query, innermost first
+	Extensions    map[string]string `json:"extensions,omitempty"` // integrators' metadata (see proxy.Event.Extensions)
+	PrevHash      string            `json:"prev_hash,omitempty"`  // hash of the line before, in hash-chained files (see ChainWriter)
+}
+
+// NewRecord converts ev to a Record.
+func NewRecord(ev *tapv1.QueryEvent) Record {
+	args := ev.GetArgs()
+	if args == nil {
+		args = []string{}
+	}
+	r := Record{
+		ID:            ev.GetId(),
+		Op:            proxy.Op(ev.GetOp()).String(),
+		Query:         ev.GetQuery(),
+		Fingerprint:   ev.GetFingerprint(),
+		Args:          args,
+		RowsAffected:  ev.GetRowsAffected(),
+		RequestBytes:  ev.GetRequestBytes(),
+		ResponseBytes: ev.GetResponseBytes(),
+		Error:         ev.GetError(),
+		TxID:          ev.GetTxId(),
+		BatchID:       ev.GetBatchId(),
+		BatchSize:     ev.GetBatchSize(),
+		Queries:       ev.GetQueries(),
+		ConnID:        ev.GetConnId(),
+		ClientAddr:    ev.GetClientAddr(),
+		Listener:      ev.GetListener(),
+		User:          ev.GetUser(),
+		Database:      ev.GetDatabase(),
+		BackendPID:    ev.GetBackendPid(),
+		Session:       ev.GetSession(),
+		Upstream:      ev.GetUpstream(),
+		Tags:          ev.GetTags(),
+		Fields:        ev.GetFields(),
+		TraceID:       ev.GetTraceId(),
+		SpanID:        ev.GetSpanId(),
+		Route:         ev.GetRoute(),
+		RequestID:     ev.GetRequestId(),
+		Caller:        ev.GetCaller(),
+		Extensions:    ev.GetExtensions(),
+	}
+	if v := ev.GetViolation(); v != nil {
+		r.Violation = v.GetRule()
+		r.Blocked = v.GetBlocked()
+	}
+	if n := ev.GetNotice(); n != nil {
+		r.Notice = n.GetSeverity() + ": " + n.GetMessage()
+		r.NoticeFor = ev.GetNoticeFor()
+	}
+	if ev.GetStartTime() != nil {
+		r.StartTime = ev.GetStartTime().AsTime().Format(time.RFC3339Nano)
+	}
+	r.DurationMs = millis(ev.GetDuration())
+	r.QueueMs = millis(ev.GetQueueLatency())
+	r.ExecMs = millis(ev.GetExecLatency())
+	r.FetchMs = millis(ev.GetFetchLatency())
+	return r
+}
+
+// millis converts d to fractional milliseconds, 0 when unset.
+func millis(d *durationpb.Duration) float64 {
+	if d == nil {
+		return 0
+	}
+	return float64(d.AsDuration().Microseconds()) / 1000
+}
+
+// Writer encodes events one at a time.
+type Writer interface {
+	Write(ev *tapv1.QueryEvent) error
+	// Flush writes any buffered data to the underlying writer.
+	Flush() error
+}
+
+// NewWriter returns a Writer that encodes events to w in format f.
+func NewWriter(w io.Writer, f Format) Writer {
+	if f == CSV {
+		return newCSVWriter(w)
+	}
+	return &ndjsonWriter{enc: json.NewEncoder(w)}
+}
+
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (w *ndjsonWriter) Write(ev *tapv1.QueryEvent) error {
+	if err := w.enc.Encode(NewRecord(ev)); err != nil {
+		return fmt.Errorf("export: encode json: %w", err)
+	}
+	return nil
+}
+
+func (w *ndjsonWriter) Flush() error { return nil }
+
+var csvHeader = []string{
+	"id", "start_time", "op", "query", "args", "duration_ms",
+	"rows_affected", "error", "tx_id", "conn_id", "upstream", "tags", "fields",
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (w *csvWriter) Write(ev *tapv1.QueryEvent) error {
+	if !w.wroteHeader {
+		if err := w.w.Write(csvHeader); err != nil {
+			return fmt.Errorf("export: write csv header: %w", err)
+		}
+		w.wroteHeader = true
+	}
+
+	r := NewRecord(ev)
+	args, err := json.Marshal(r.Args)
+	if err != nil {
+		return fmt.Errorf("export: encode args: %w", err)
+	}
+	var fields []byte
+	if len(r.Fields) > 0 {
+		if fields, err = json.Marshal(r.Fields); err != nil {
+			return fmt.Errorf("export: encode fields: %w", err)
+		}
+	}
+	if err := w.w.Write([]string{
+		r.ID,
+		r.StartTime,
+		r.Op,
+		r.Query,
+		string(args),
+		strconv.FormatFloat(r.DurationMs, 'f', 3, 64),
+		strconv.FormatInt(r.RowsAffected, 10),
+		r.Error,
+		r.TxID,
+		r.ConnID,
+		r.Upstream,
+		strings.Join(r.Tags, ","),
+		string(fields),
+	}); err != nil {
+		return fmt.Errorf("export: write csv: %w", err)
+	}
+	return nil
+}
+
+func (w *csvWriter) Flush() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		return fmt.Errorf("export: flush csv: %w", err)
+	}
+	return nil
+}
+
+// WriteAll encodes events to w in format f.
+func WriteAll(w io.Writer, f Format, events []*tapv1.QueryEvent) error {
+	ew := NewWriter(w, f)
+	for _, ev := range events {
+		if err := ew.Write(ev); err != nil {
+			return err
+		}
+	}
+	return ew.Flush()
+}
@@ -0,0 +1,212 @@
+package export_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func sampleEvents() []*tapv1.QueryEvent {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	return []*tapv1.QueryEvent{
+		{
+			Id:           "1",
+			Op:           int32(proxy.OpQuery),
+			Query:        "SELECT * FROM users WHERE id = $1",
+			Args:         []string{"42"},
+			StartTime:    timestamppb.New(start),
+			Duration:     durationpb.New(1500 * time.Microsecond),
+			ExecLatency:  durationpb.New(time.Millisecond),
+			FetchLatency: durationpb.New(500 * time.Microsecond),
+			RowsAffected: 1,
+			TxId:         "tx-1",
+			Tags:         []string{"auth-path", "cron"},
+			Fields:       map[string]string{"tenant_id": "acme"},
+		},
+		{
+			Id:         "2",
+			Op:         int32(proxy.OpExec),
+			Query:      "INSERT INTO logs VALUES ('a,b')",
+			StartTime:  timestamppb.New(start),
+			Duration:   durationpb.New(time.Millisecond),
+			Error:      "duplicate key",
+			Extensions: map[string]string{"host": "web-1"},
+		},
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    export.Format
+		wantErr bool
+	}{
+		{in: "json", want: export.NDJSON},
+		{in: "ndjson", want: export.NDJSON},
+		{in: "CSV", want: export.CSV},
+		{in: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := export.ParseFormat(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteAll_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := export.WriteAll(&buf, export.NDJSON, sampleEvents()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"id":"1","start_time":"2026-01-02T03:04:05Z","op":"Query","query":"SELECT * FROM users WHERE id = $1","args":["42"],"duration_ms":1.5,"exec_ms":1,"fetch_ms":0.5,"rows_affected":1,"tx_id":"tx-1","tags":["auth-path","cron"],"fields":{"tenant_id":"acme"}}
+{"id":"2","start_time":"2026-01-02T03:04:05Z","op":"Exec","query":"INSERT INTO logs VALUES ('a,b')","args":[],"duration_ms":1,"rows_affected":0,"error":"duplicate key","extensions":{"host":"web-1"}}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteAll_CSV(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := export.WriteAll(&buf, export.CSV, sampleEvents()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"id,start_time,op,query,args,duration_ms,rows_affected,error,tx_id,conn_id,upstream,tags,fields",
+		`1,2026-01-02T03:04:05Z,Query,SELECT * FROM users WHERE id = $1,"[""42""]",1.500,1,,tx-1,,,"auth-path,cron","{""tenant_id"":""acme""}"`,
+		`2,2026-01-02T03:04:05Z,Exec,"INSERT INTO logs VALUES ('a,b')",[],1.000,0,duplicate key,,,,,`,
+		"",
+	}, "\n")
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteAll_CSVEmpty(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	if err := export.WriteAll(&buf, export.CSV, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("expected no output for no events, got %q", buf.String())
+	}
+}
+
+func TestTemplateWriter(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "duration and query",
+			text: "{{.Duration}} {{.Query}}",
+			want: "1.5ms SELECT * FROM users WHERE id = $1\n1ms INSERT INTO logs VALUES ('a,b')\n",
+		},
+		{
+			name: "trailing newline kept",
+			text: "{{.ID}}\n",
+			want: "1\n2\n",
+		},
+		{
+			name: "functions",
+			text: `{{ms .Duration}} {{join .Tags ","}} {{json .Args}} {{.Fields.tenant_id}}`,
+			want: "1.500 auth-path,cron [\"42\"] acme\n1.000  [] \n",
+		},
+		{
+			name: "conditionals and time",
+			text: `{{.Time.Format "15:04:05"}} {{.Op}}{{if .Error}} failed: {{.Error}}{{end}}`,
+			want: "03:04:05 Query\n03:04:05 Exec failed: duplicate key\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			w, err := export.NewTemplateWriter(&buf, tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, ev := range sampleEvents() {
+				if err := w.Write(ev); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineWriter(t *testing.T) {
+	t.Parallel()
+
+	events := append(sampleEvents(), &tapv1.QueryEvent{
+		Op:        int32(proxy.OpQuery),
+		Query:     "SELECT id\n  FROM users\n WHERE name = 'x'",
+		StartTime: timestamppb.New(time.Date(2026, 1, 2, 3, 4, 6, 0, time.UTC)),
+		Duration:  durationpb.New(1234567 * time.Nanosecond),
+		ConnId:    "c1",
+		Upstream:  "main",
+	})
+	var buf bytes.Buffer
+	w := export.NewLineWriter(&buf, time.UTC)
+	for _, ev := range events {
+		if err := w.Write(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := `03:04:05.000 Query          1.5ms SELECT * FROM users WHERE id = $1 args=["42"] rows=1 tx=tx-1 tags=auth-path,cron
+03:04:05.000 Exec             1ms INSERT INTO logs VALUES ('a,b') error="duplicate key"
+03:04:06.000 Query        1.235ms SELECT id FROM users WHERE name = 'x' conn=c1 upstream=main
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNewTemplateWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	if _, err := export.NewTemplateWriter(&bytes.Buffer{}, "{{.Query"); err == nil {
+		t.Error("expected a parse error")
+	}
+	w, err := export.NewTemplateWriter(&bytes.Buffer{}, "{{.Nope}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(sampleEvents()[0]); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
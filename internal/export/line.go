@@ -0,0 +1,82 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// LineWriter prints each event as one plain line for reading in a terminal
+// or filtering with grep: the start time, op, and duration in aligned
+// columns, the query with its whitespace collapsed, then key=value details
+// for those present:
+//
+//	03:04:05.000 Query          1.5ms SELECT * FROM users WHERE id = $1 args=["42"] rows=1 tx=tx-1
+type LineWriter struct {
+	w   io.Writer
+	loc *time.Location
+}
+
+// NewLineWriter returns a LineWriter printing to w, with times in loc.
+func NewLineWriter(w io.Writer, loc *time.Location) *LineWriter {
+	return &LineWriter{w: w, loc: loc}
+}
+
+func (w *LineWriter) Write(ev *tapv1.QueryEvent) error {
+	r := NewRecord(ev)
+	var b strings.Builder
+	start := "--:--:--.---"
+	if ev.GetStartTime() != nil {
+		start = ev.GetStartTime().AsTime().In(w.loc).Format("15:04:05.000")
+	}
+	fmt.Fprintf(&b, "%s %-10s %9s", start, r.Op, ev.GetDuration().AsDuration().Round(time.Microsecond))
+	if q := strings.Join(strings.Fields(r.Query), " "); q != "" {
+		b.WriteString(" " + q)
+	}
+
+	detail := func(key, value string) {
+		if value != "" {
+			b.WriteString(" " + key + "=" + value)
+		}
+	}
+	if len(r.Args) > 0 {
+		args, err := json.Marshal(r.Args)
+		if err != nil {
+			return fmt.Errorf("export: encode args: %w", err)
+		}
+		detail("args", string(args))
+	}
+	if r.RowsAffected > 0 {
+		detail("rows", strconv.FormatInt(r.RowsAffected, 10))
+	}
+	if proxy.Op(ev.GetOp()) == proxy.OpDisconnect {
+		detail("queries", strconv.FormatInt(r.Queries, 10))
+	}
+	detail("tx", r.TxID)
+	detail("conn", r.ConnID)
+	detail("upstream", r.Upstream)
+	if len(r.Tags) > 0 {
+		detail("tags", strings.Join(r.Tags, ","))
+	}
+	detail("violation", r.Violation)
+	if r.Notice != "" {
+		detail("notice", strconv.Quote(r.Notice))
+	}
+	if r.Error != "" {
+		detail("error", strconv.Quote(r.Error))
+	}
+	b.WriteByte('\n')
+
+	if _, err := io.WriteString(w.w, b.String()); err != nil {
+		return fmt.Errorf("export: write line: %w", err)
+	}
+	return nil
+}
+
+func (w *LineWriter) Flush() error { return nil }
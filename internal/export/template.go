@@ -0,0 +1,73 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// TemplateEvent is what a TemplateWriter's template is executed on: the
+// exported Record, with the start time and duration as Go values so they
+// format themselves ({{.Duration}} prints 1.5ms) and can be compared or
+// formatted further ({{.Time.Format "15:04:05"}}).
+type TemplateEvent struct {
+	Record
+
+	Time     time.Time
+	Duration time.Duration
+}
+
+// templateFuncs are the functions templates may call besides the built-in
+// ones.
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err //nolint:wrapcheck // reported by template execution
+	},
+	"ms": func(d time.Duration) string {
+		return fmt.Sprintf("%.3f", float64(d.Microseconds())/1000)
+	},
+}
+
+// TemplateWriter renders each event through a text/template, one line per
+// event.
+type TemplateWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+// NewTemplateWriter parses text as a text/template over a TemplateEvent and
+// returns a Writer rendering events to w with it. A newline is added after
+// each event unless the template ends with one.
+func NewTemplateWriter(w io.Writer, text string) (*TemplateWriter, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("event").Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("export: template: %w", err)
+	}
+	return &TemplateWriter{w: w, tmpl: tmpl}, nil
+}
+
+func (w *TemplateWriter) Write(ev *tapv1.QueryEvent) error {
+	data := TemplateEvent{
+		Record:   NewRecord(ev),
+		Duration: ev.GetDuration().AsDuration(),
+	}
+	if ev.GetStartTime() != nil {
+		data.Time = ev.GetStartTime().AsTime()
+	}
+	if err := w.tmpl.Execute(w.w, data); err != nil {
+		return fmt.Errorf("export: template: %w", err)
+	}
+	return nil
+}
+
+func (w *TemplateWriter) Flush() error { return nil }
@@ -0,0 +1,110 @@
+package extract
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+type rule struct {
+	name    string
+	comment string
+	query   *regexp.Regexp
+	arg     int // 1-based; 0 when the rule reads elsewhere
+}
+
+// value returns the rule's value in ev, if it finds one. comment is the
+// query's sqlcommenter comment, parsed once for all rules.
+func (r rule) value(ev *proxy.Event, comment map[string]string) (string, bool) {
+	switch {
+	case r.comment != "":
+		v, ok := comment[r.comment]
+		return v, ok && v != ""
+	case r.query != nil:
+		m := r.query.FindStringSubmatch(ev.Query)
+		if m == nil || m[1] == "" {
+			return "", false
+		}
+		return m[1], true
+	case r.arg > 0 && r.arg <= len(ev.Args):
+		return ev.Args[r.arg-1], ev.Args[r.arg-1] != ""
+	}
+	return "", false
+}
+
+// Extractor fills event fields according to config-defined rules.
+type Extractor struct {
+	rules    []rule
+	comments bool // some rule reads the sqlcommenter comment
+}
+
+// New compiles rules. A query rule's regular expression needs a capturing
+// group, whose match is the value.
+func New(rules []config.FieldRule) (*Extractor, error) {
+	x := &Extractor{}
+	for i, r := range rules {
+		cr := rule{name: r.Name, comment: r.Comment, arg: r.Arg}
+		if r.Query != "" {
+			re, err := regexp.Compile(r.Query)
+			if err != nil {
+				return nil, fmt.Errorf("extract: rule %d (%s): %w", i, r.Name, err)
+			}
+			if re.NumSubexp() == 0 {
+				return nil, fmt.Errorf("extract: rule %d (%s): query needs a capturing group", i, r.Name)
+			}
+			cr.query = re
+		}
+		x.comments = x.comments || r.Comment != ""
+		x.rules = append(x.rules, cr)
+	}
+	return x, nil
+}
+
+// ArgFields returns the fields some rule fills from a bind argument.
+func (x *Extractor) ArgFields() []string {
+	if x == nil {
+		return nil
+	}
+	var names []string
+	for _, r := range x.rules {
+		if r.arg > 0 && !slices.Contains(names, r.name) {
+			names = append(names, r.name)
+		}
+	}
+	return names
+}
+
+// Apply sets ev.Fields to the values the rules find in its query. For a
+// field filled by several rules, the first rule that finds a value wins.
+// It returns the fields filled from a bind argument, whose values are as
+// sensitive as the argument's. A nil Extractor, or an event without a
+// query, is left unchanged.
+func (x *Extractor) Apply(ev *proxy.Event) (fromArgs []string) {
+	if x == nil || ev.Query == "" {
+		return nil
+	}
+	var comment map[string]string
+	if x.comments {
+		comment = proxy.SQLComment(ev.Query)
+	}
+	for _, r := range x.rules {
+		if _, ok := ev.Fields[r.name]; ok {
+			continue
+		}
+		v, ok := r.value(ev, comment)
+		if !ok {
+			continue
+		}
+		if ev.Fields == nil {
+			ev.Fields = make(map[string]string)
+		}
+		ev.Fields[r.name] = v
+		if r.arg > 0 {
+			fromArgs = append(fromArgs, r.name)
+		}
+	}
+	return fromArgs
+}
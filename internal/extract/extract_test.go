@@ -0,0 +1,121 @@
+package extract_test
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/extract"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestExtractor_Apply(t *testing.T) {
+	t.Parallel()
+
+	x, err := extract.New([]config.FieldRule{
+		{Name: "tenant_id", Comment: "tenant"},
+		{Name: "tenant_id", Query: `(?i)\btenant_id\s*=\s*'?(\w+)`},
+		{Name: "tenant_id", Arg: 2},
+		{Name: "region", Comment: "region"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		ev       proxy.Event
+		want     map[string]string
+		fromArgs []string
+	}{
+		{
+			name: "no match",
+			ev:   proxy.Event{Query: "SELECT 1"},
+			want: nil,
+		},
+		{
+			name: "comment",
+			ev:   proxy.Event{Query: "SELECT * FROM orders /*region='eu',tenant='acme'*/"},
+			want: map[string]string{"tenant_id": "acme", "region": "eu"},
+		},
+		{
+			name: "comment wins over literal",
+			ev:   proxy.Event{Query: "SELECT * FROM orders WHERE tenant_id = 7 /*tenant='acme'*/"},
+			want: map[string]string{"tenant_id": "acme"},
+		},
+		{
+			name: "literal",
+			ev:   proxy.Event{Query: "SELECT * FROM orders WHERE tenant_id = '42'"},
+			want: map[string]string{"tenant_id": "42"},
+		},
+		{
+			name:     "bind argument",
+			ev:       proxy.Event{Query: "SELECT * FROM orders WHERE id = $1 AND tenant_id = $2", Args: []string{"1", "99"}},
+			want:     map[string]string{"tenant_id": "99"},
+			fromArgs: []string{"tenant_id"},
+		},
+		{
+			name: "missing argument",
+			ev:   proxy.Event{Query: "SELECT * FROM orders WHERE id = $1", Args: []string{"1"}},
+			want: nil,
+		},
+		{
+			name: "no query",
+			ev:   proxy.Event{Op: proxy.OpConnect, Args: []string{"1", "99"}},
+			want: nil,
+		},
+	}
+
+	if got := x.ArgFields(); !slices.Equal(got, []string{"tenant_id"}) {
+		t.Errorf("ArgFields() = %q, want [tenant_id]", got)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ev := tt.ev
+			fromArgs := x.Apply(&ev)
+			if !maps.Equal(ev.Fields, tt.want) {
+				t.Errorf("Fields = %v, want %v", ev.Fields, tt.want)
+			}
+			if !slices.Equal(fromArgs, tt.fromArgs) {
+				t.Errorf("Apply() = %q, want %q", fromArgs, tt.fromArgs)
+			}
+		})
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		rule config.FieldRule
+	}{
+		{name: "bad regexp", rule: config.FieldRule{Name: "x", Query: "("}},
+		{name: "no group", rule: config.FieldRule{Name: "x", Query: "tenant_id = \\d+"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := extract.New([]config.FieldRule{tt.rule}); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestExtractor_Nil(t *testing.T) {
+	t.Parallel()
+
+	var x *extract.Extractor
+	ev := proxy.Event{Query: "SELECT 1 /*tenant='acme'*/"}
+	x.Apply(&ev)
+	if ev.Fields != nil {
+		t.Fatal("nil extractor should be a no-op")
+	}
+}
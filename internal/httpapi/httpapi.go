@@ -0,0 +1,210 @@
+// Package httpapi serves the daemon's events over plain HTTP, for clients
+// without gRPC such as a browser's EventSource or curl. GET /events streams
+// them as Server-Sent Events, each one an export.Record as JSON, or, given
+// search parameters, answers from the event store as NDJSON. GET /stats
+// reports pipeline statistics, GET /metrics the same for Prometheus, and
+// GET /healthz that the daemon is up.
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// DefaultHeartbeat is how often an idle event stream sends an SSE comment,
+// so proxies and browsers keep the connection open.
+const DefaultHeartbeat = 15 * time.Second
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAuthorizer requires a token granting the viewer role on /events, as
+// the gRPC Watch call does. Clients send it as a bearer token or, since
+// EventSource cannot set headers, as the access_token query parameter.
+func WithAuthorizer(a *auth.Authorizer) Option {
+	return func(s *Server) {
+		s.authorizer = a
+	}
+}
+
+// WithAuditLog records streams opening and closing to l.
+func WithAuditLog(l *log.Logger) Option {
+	return func(s *Server) {
+		s.audit = l
+	}
+}
+
+// WithService answers stored-event searches and /stats through svc, the
+// daemon's TapService. Without it those requests fail with 501.
+func WithService(svc tapv1.TapServiceServer) Option {
+	return func(s *Server) {
+		s.svc = svc
+	}
+}
+
+// WithHeartbeat sets how often idle streams send a comment
+// (default DefaultHeartbeat).
+func WithHeartbeat(d time.Duration) Option {
+	return func(s *Server) {
+		s.heartbeat = d
+	}
+}
+
+// Server is the HTTP API.
+type Server struct {
+	broker     *broker.Broker[proxy.Event]
+	authorizer *auth.Authorizer
+	audit      *log.Logger
+	heartbeat  time.Duration
+	svc        tapv1.TapServiceServer
+	srv        *http.Server
+}
+
+// New returns a Server streaming the events published on b.
+func New(b *broker.Broker[proxy.Event], opts ...Option) *Server {
+	s := &Server{broker: b, heartbeat: DefaultHeartbeat}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.srv = &http.Server{
+		Handler:           s.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the API's routes.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /events", s.events)
+	mux.HandleFunc("GET /stats", s.stats)
+	mux.HandleFunc("GET /metrics", s.metrics)
+	return mux
+}
+
+// Serve accepts connections on lis until Close.
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.srv.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("httpapi: %w", err)
+	}
+	return nil
+}
+
+// Close stops the server and ends open streams.
+func (s *Server) Close() error {
+	if err := s.srv.Close(); err != nil {
+		return fmt.Errorf("httpapi: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	if isSearch(r.URL.Query()) {
+		s.search(w, r)
+		return
+	}
+	if !s.authorize(w, r, tapv1.TapService_Watch_FullMethodName) {
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	ch, unsub := s.broker.Subscribe(broker.WithName("sse " + r.RemoteAddr))
+	defer unsub()
+
+	if s.audit != nil {
+		start := time.Now()
+		s.audit.Printf("audit: event stream opened: addr=%s", r.RemoteAddr)
+		defer func() {
+			s.audit.Printf("audit: event stream closed: addr=%s after %s",
+				r.RemoteAddr, time.Since(start).Round(time.Second))
+		}()
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.heartbeat)
+	defer ticker.Stop()
+	for {
+		var err error
+		select {
+		case <-r.Context().Done():
+			return
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+			err = writeEvent(w, ev)
+		case <-ticker.C:
+			_, err = w.Write([]byte(": ping\n\n"))
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeEvent writes ev as one SSE message.
+func writeEvent(w http.ResponseWriter, ev proxy.Event) error {
+	data, err := json.Marshal(export.NewRecord(server.EventToProto(ev)))
+	if err != nil {
+		return fmt.Errorf("httpapi: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+		return fmt.Errorf("httpapi: %w", err)
+	}
+	return nil
+}
+
+// authorize checks that the request's token, when auth is enabled, allows
+// the gRPC method the route corresponds to, and writes the error response
+// when it does not.
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request, method string) bool {
+	if s.authorizer == nil {
+		return true
+	}
+	tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		tok = r.URL.Query().Get("access_token")
+	}
+	if tok == "" {
+		http.Error(w, "missing bearer token", http.StatusUnauthorized)
+		return false
+	}
+	role, ok := s.authorizer.Role(tok)
+	if !ok {
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return false
+	}
+	if need := auth.Required(method); role < need {
+		http.Error(w, fmt.Sprintf("%s requires the %s role; token has %s", r.URL.Path, need, role), http.StatusForbidden)
+		return false
+	}
+	return true
+}
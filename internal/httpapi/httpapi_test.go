@@ -0,0 +1,288 @@
+package httpapi_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/httpapi"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/internal/store"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func get(t *testing.T, url, header string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header != "" {
+		req.Header.Set("Authorization", header)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestHealthz(t *testing.T) {
+	t.Parallel()
+
+	ts := httptest.NewServer(httpapi.New(broker.New[proxy.Event](8)).Handler())
+	t.Cleanup(ts.Close)
+
+	resp := get(t, ts.URL+"/healthz", "")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "ok\n" {
+		t.Errorf("GET /healthz = %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	ts := httptest.NewServer(httpapi.New(b).Handler())
+	t.Cleanup(ts.Close)
+
+	resp := get(t, ts.URL+"/events", "")
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	// The headers arrive once the stream has subscribed.
+	for b.SubscriberCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	b.Publish(proxy.Event{ID: "1", Op: proxy.OpQuery, Query: "SELECT 1", Upstream: "primary"})
+
+	sc := bufio.NewScanner(resp.Body)
+	for sc.Scan() {
+		data, ok := strings.CutPrefix(sc.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		var rec export.Record
+		if err := json.Unmarshal([]byte(data), &rec); err != nil {
+			t.Fatalf("decode %q: %v", data, err)
+		}
+		if rec.Query != "SELECT 1" || rec.Op != "Query" || rec.Upstream != "primary" {
+			t.Errorf("record = %+v", rec)
+		}
+		return
+	}
+	t.Fatalf("stream ended without an event: %v", sc.Err())
+}
+
+func TestEventsHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	s := httpapi.New(broker.New[proxy.Event](8), httpapi.WithHeartbeat(10*time.Millisecond))
+	ts := httptest.NewServer(s.Handler())
+	t.Cleanup(ts.Close)
+
+	sc := bufio.NewScanner(get(t, ts.URL+"/events", "").Body)
+	if !sc.Scan() || sc.Text() != ": ping" {
+		t.Errorf("first line = %q, want a heartbeat comment", sc.Text())
+	}
+}
+
+func TestEventsAuth(t *testing.T) {
+	t.Parallel()
+
+	a := auth.New(map[string]auth.Role{"view-token": auth.RoleViewer})
+	ts := httptest.NewServer(httpapi.New(broker.New[proxy.Event](8), httpapi.WithAuthorizer(a)).Handler())
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{name: "no token", path: "/events", want: http.StatusUnauthorized},
+		{name: "wrong token", path: "/events", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "bearer token", path: "/events", header: "Bearer view-token", want: http.StatusOK},
+		{name: "query parameter", path: "/events?access_token=view-token", want: http.StatusOK},
+		{name: "healthz needs none", path: "/healthz", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := get(t, ts.URL+tt.path, tt.header).StatusCode; got != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	t.Parallel()
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	start := time.Now().Add(-time.Hour)
+	for i, q := range []string{"SELECT * FROM users", "SELECT * FROM orders", "UPDATE users SET name = $1"} {
+		ev := proxy.Event{ID: string(rune('1' + i)), Op: proxy.OpQuery, Query: q, StartTime: start.Add(time.Duration(i) * time.Minute)}
+		if err := st.Append(server.EventToProto(ev)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := broker.New[proxy.Event](8)
+	svc := server.New(b, nil, server.WithStore(st)).Service()
+	ts := httptest.NewServer(httpapi.New(b, httpapi.WithService(svc)).Handler())
+	t.Cleanup(ts.Close)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		want   []string // record IDs
+	}{
+		{name: "filter", query: "since=2h&filter=users", status: http.StatusOK, want: []string{"1", "3"}},
+		{name: "since", query: "since=" + url.QueryEscape(start.Add(30*time.Second).Format(time.RFC3339Nano)), status: http.StatusOK, want: []string{"2", "3"}},
+		{name: "limit", query: "limit=1", status: http.StatusOK, want: []string{"3"}},
+		{name: "bad since", query: "since=yesterday", status: http.StatusBadRequest},
+		{name: "bad limit", query: "limit=-1", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := get(t, ts.URL+"/events?"+tt.query, "")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+				t.Errorf("Content-Type = %q", ct)
+			}
+			var got []string
+			dec := json.NewDecoder(resp.Body)
+			for dec.More() {
+				var rec export.Record
+				if err := dec.Decode(&rec); err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, rec.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("records = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearch_NoStore(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	svc := server.New(b, nil).Service()
+	ts := httptest.NewServer(httpapi.New(b, httpapi.WithService(svc)).Handler())
+	t.Cleanup(ts.Close)
+
+	if got := get(t, ts.URL+"/events?since=1h", "").StatusCode; got != http.StatusNotImplemented {
+		t.Errorf("status = %d, want %d", got, http.StatusNotImplemented)
+	}
+}
+
+func TestStats(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	stages := metrics.NewStages()
+	stages.Observe(metrics.StageCapture, 2*time.Millisecond)
+	svc := server.New(b, nil, server.WithStages(stages)).Service()
+	a := auth.New(map[string]auth.Role{"view-token": auth.RoleViewer})
+	ts := httptest.NewServer(httpapi.New(b, httpapi.WithService(svc), httpapi.WithAuthorizer(a)).Handler())
+	t.Cleanup(ts.Close)
+
+	if got := get(t, ts.URL+"/stats", "").StatusCode; got != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want %d", got, http.StatusUnauthorized)
+	}
+	resp := get(t, ts.URL+"/stats", "Bearer view-token")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	var body struct {
+		Stages []struct {
+			Name  string  `json:"name"`
+			Count uint64  `json:"count"`
+			MaxMS float64 `json:"max_ms"`
+		} `json:"stages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Stages) != 1 || body.Stages[0].Name != metrics.StageCapture || body.Stages[0].Count != 1 || body.Stages[0].MaxMS <= 0 {
+		t.Errorf("stages = %+v", body.Stages)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	_, unsub := b.Subscribe(broker.WithName(`sse "x"`))
+	t.Cleanup(unsub)
+	stages := metrics.NewStages()
+	stages.Observe(metrics.StageCapture, 50*time.Microsecond)
+	stages.Observe(metrics.StageCapture, 2*time.Millisecond)
+	stages.Observe(metrics.Sink("store"), 3*time.Second)
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	svc := server.New(b, nil, server.WithStages(stages), server.WithTLSCertNotAfter(notAfter, time.Hour)).Service()
+	a := auth.New(map[string]auth.Role{"view-token": auth.RoleViewer})
+	ts := httptest.NewServer(httpapi.New(b, httpapi.WithService(svc), httpapi.WithAuthorizer(a)).Handler())
+	t.Cleanup(ts.Close)
+
+	if got := get(t, ts.URL+"/metrics", "").StatusCode; got != http.StatusUnauthorized {
+		t.Errorf("status without a token = %d, want %d", got, http.StatusUnauthorized)
+	}
+	resp := get(t, ts.URL+"/metrics", "Bearer view-token")
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("status = %d, Content-Type = %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	body, _ := io.ReadAll(resp.Body)
+	lines := strings.Split(string(body), "\n")
+	for _, want := range []string{
+		"# TYPE sql_tap_stage_duration_seconds histogram",
+		`sql_tap_stage_duration_seconds_bucket{stage="capture",le="1e-05"} 0`,
+		`sql_tap_stage_duration_seconds_bucket{stage="capture",le="0.0001"} 1`,
+		`sql_tap_stage_duration_seconds_bucket{stage="capture",le="0.01"} 2`,
+		`sql_tap_stage_duration_seconds_bucket{stage="capture",le="+Inf"} 2`,
+		`sql_tap_stage_duration_seconds_sum{stage="capture"} 0.00205`,
+		`sql_tap_stage_duration_seconds_count{stage="capture"} 2`,
+		`sql_tap_stage_duration_seconds_bucket{stage="sink:store",le="1"} 0`,
+		`sql_tap_stage_duration_seconds_bucket{stage="sink:store",le="+Inf"} 1`,
+		"sql_tap_proxy_dropped_events_total 0",
+		`sql_tap_cancellations_total{cause="killed"} 0`,
+		`sql_tap_subscriber_dropped_events_total{id="0",subscriber="sse \"x\""} 0`,
+		"# TYPE sql_tap_tls_cert_not_after_seconds gauge",
+		"sql_tap_tls_cert_not_after_seconds " + strconv.FormatInt(notAfter.Unix(), 10),
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+}
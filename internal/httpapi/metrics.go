@@ -0,0 +1,119 @@
+package httpapi
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// labelEscaper escapes a label value for the Prometheus text format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metrics answers GET /metrics with the Stats RPC in the Prometheus text
+// exposition format: a latency histogram per pipeline stage, the daemon's
+// drop, sampling, panic, and cancellation counters, and each subscriber's
+// drops and backlog. With TLS termination, the Info RPC adds the expiry of
+// the proxy's certificate.
+func (s *Server) metrics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, tapv1.TapService_Stats_FullMethodName) {
+		return
+	}
+	if s.svc == nil {
+		http.Error(w, "stats are not enabled on this server", http.StatusNotImplemented)
+		return
+	}
+	resp, err := s.svc.Stats(r.Context(), &tapv1.StatsRequest{})
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	info, err := s.svc.Info(r.Context(), &tapv1.InfoRequest{})
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	writeMetrics(bw, resp, info)
+	_ = bw.Flush()
+}
+
+func writeMetrics(w *bufio.Writer, resp *tapv1.StatsResponse, info *tapv1.InfoResponse) {
+	help := func(name, kind, text string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, text, name, kind)
+	}
+
+	const stage = "sql_tap_stage_duration_seconds"
+	help(stage, "histogram", "Time events spend in each pipeline stage.")
+	for _, st := range resp.GetStages() {
+		label := `stage="` + labelEscaper.Replace(st.GetName()) + `"`
+		var cumulative uint64
+		for i, n := range st.GetBuckets() {
+			cumulative += n
+			le := "+Inf"
+			if i < len(st.GetBucketBounds()) {
+				le = strconv.FormatFloat(st.GetBucketBounds()[i].AsDuration().Seconds(), 'g', -1, 64)
+			}
+			fmt.Fprintf(w, "%s_bucket{%s,le=%q} %d\n", stage, label, le, cumulative)
+		}
+		fmt.Fprintf(w, "%s_sum{%s} %g\n", stage, label, st.GetTotal().AsDuration().Seconds())
+		fmt.Fprintf(w, "%s_count{%s} %d\n", stage, label, st.GetCount())
+	}
+
+	counters := []struct {
+		name, help string
+		value      uint64
+	}{
+		{"sql_tap_proxy_dropped_events_total", "Events the proxies discarded because the daemon could not keep up.",
+			resp.GetProxyDropped()},
+		{"sql_tap_sampled_out_events_total", "Events the sampling rules discarded before publishing.",
+			resp.GetSampledOut()},
+		{"sql_tap_panics_total", "Panics recovered from connection handling and pipeline stages.", resp.GetPanics()},
+		{"sql_tap_diagnostics_total", "Connection errors the proxies logged.", resp.GetDiagnostics()},
+	}
+	for _, c := range counters {
+		help(c.name, "counter", c.help)
+		fmt.Fprintf(w, "%s %d\n", c.name, c.value)
+	}
+
+	const cancels = "sql_tap_cancellations_total"
+	help(cancels, "counter", "Upstream statements cancelled or abandoned, by cause.")
+	cs := resp.GetCancellations()
+	for _, c := range []struct {
+		cause string
+		value uint64
+	}{
+		{"relayed", cs.GetRelayed()},
+		{"killed", cs.GetKilled()},
+		{"timed_out", cs.GetTimedOut()},
+		{"disconnected", cs.GetDisconnected()},
+	} {
+		fmt.Fprintf(w, "%s{cause=%q} %d\n", cancels, c.cause, c.value)
+	}
+
+	// Subscribers are labelled by ID as well, since names such as a
+	// watcher's address can repeat.
+	subscriber := func(sub *tapv1.SubscriberStats) string {
+		return `id="` + strconv.FormatInt(sub.GetId(), 10) + `",subscriber="` + labelEscaper.Replace(sub.GetName()) + `"`
+	}
+	const dropped = "sql_tap_subscriber_dropped_events_total"
+	help(dropped, "counter", "Events a subscriber missed because it fell behind.")
+	for _, sub := range resp.GetSubscribers() {
+		fmt.Fprintf(w, "%s{%s} %d\n", dropped, subscriber(sub), sub.GetDropped())
+	}
+	const buffered = "sql_tap_subscriber_buffered_events"
+	help(buffered, "gauge", "Events waiting in a subscriber's buffer, including those spilled to disk.")
+	for _, sub := range resp.GetSubscribers() {
+		fmt.Fprintf(w, "%s{%s} %d\n", buffered, subscriber(sub), sub.GetBuffered()+sub.GetSpilled())
+	}
+
+	if notAfter := info.GetTlsCertNotAfter(); notAfter != nil {
+		const cert = "sql_tap_tls_cert_not_after_seconds"
+		help(cert, "gauge", "Expiry of the proxy's TLS certificate, in seconds since the Unix epoch.")
+		fmt.Fprintf(w, "%s %d\n", cert, notAfter.GetSeconds())
+	}
+}
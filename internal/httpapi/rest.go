@@ -0,0 +1,239 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+// searchParams are the /events query parameters that turn the stream into
+// a search of the event store, after the sql-tap query flags.
+var searchParams = []string{"since", "until", "sql", "tx", "filter", "min_duration", "errors", "limit"}
+
+func isSearch(q url.Values) bool {
+	for _, p := range searchParams {
+		if q.Has(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// search answers GET /events with search parameters: the stored events
+// matching them, oldest first, as NDJSON records or, with format=csv, CSV.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, tapv1.TapService_Query_FullMethodName) {
+		return
+	}
+	q := r.URL.Query()
+	format := export.NDJSON
+	if f := q.Get("format"); f != "" {
+		var err error
+		if format, err = export.ParseFormat(f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	req, err := queryRequest(q, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if s.svc == nil {
+		http.Error(w, "event search is not enabled on this server", http.StatusNotImplemented)
+		return
+	}
+	resp, err := s.svc.Query(r.Context(), req)
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	if format == export.CSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	_ = export.WriteAll(w, format, resp.GetEvents())
+}
+
+// queryRequest builds a Query RPC request from search parameters. Times are
+// RFC 3339 or a duration before now, e.g. since=2h.
+func queryRequest(q url.Values, now time.Time) (*tapv1.QueryRequest, error) {
+	req := &tapv1.QueryRequest{
+		Query:    q.Get("sql"),
+		TxId:     q.Get("tx"),
+		Contains: q.Get("filter"),
+	}
+	for _, p := range []struct {
+		name string
+		dst  **timestamppb.Timestamp
+	}{{"since", &req.Since}, {"until", &req.Until}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := parseWhen(v, now)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p.name, err)
+		}
+		*p.dst = timestamppb.New(t)
+	}
+	if v := q.Get("min_duration"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return nil, fmt.Errorf("min_duration: %w", err)
+		}
+		req.MinDuration = durationpb.New(d)
+	}
+	if v := q.Get("errors"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("errors: %w", err)
+		}
+		req.ErrorsOnly = b
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("limit: %w", err)
+		}
+		req.Limit = int32(n)
+	}
+	return req, nil
+}
+
+// parseWhen parses a duration before now ("90m") or an RFC 3339 time.
+func parseWhen(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a duration nor an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+// statsBody is the JSON for GET /stats: the Stats RPC's response with
+// snake_case names and durations in milliseconds, as export records have.
+type statsBody struct {
+	ProxyDropped  uint64        `json:"proxy_dropped"`
+	SampledOut    uint64        `json:"sampled_out"`
+	Panics        uint64        `json:"panics"`
+	Diagnostics   uint64        `json:"diagnostics"`
+	Cancellations cancellations `json:"cancellations"`
+	Stages        []stageBody   `json:"stages"`
+	Subscribers   []subscriber  `json:"subscribers"`
+}
+
+type cancellations struct {
+	Relayed      uint64 `json:"relayed"`
+	Killed       uint64 `json:"killed"`
+	TimedOut     uint64 `json:"timed_out"`
+	Disconnected uint64 `json:"disconnected"`
+}
+
+type stageBody struct {
+	Name    string  `json:"name"`
+	Count   uint64  `json:"count"`
+	TotalMS float64 `json:"total_ms"`
+	MaxMS   float64 `json:"max_ms"`
+	P50MS   float64 `json:"p50_ms"`
+	P99MS   float64 `json:"p99_ms"`
+}
+
+type subscriber struct {
+	ID       int64     `json:"id"`
+	Name     string    `json:"name"`
+	Client   string    `json:"client,omitempty"`
+	Since    time.Time `json:"since"`
+	Policy   string    `json:"policy"`
+	Dropped  uint64    `json:"dropped"`
+	Buffered int64     `json:"buffered"`
+	Spilled  int64     `json:"spilled,omitempty"`
+	Capacity int64     `json:"capacity"`
+}
+
+func ms(d *durationpb.Duration) float64 {
+	return float64(d.AsDuration()) / float64(time.Millisecond)
+}
+
+// stats answers GET /stats.
+func (s *Server) stats(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r, tapv1.TapService_Stats_FullMethodName) {
+		return
+	}
+	if s.svc == nil {
+		http.Error(w, "stats are not enabled on this server", http.StatusNotImplemented)
+		return
+	}
+	resp, err := s.svc.Stats(r.Context(), &tapv1.StatsRequest{})
+	if err != nil {
+		writeStatus(w, err)
+		return
+	}
+
+	body := statsBody{
+		ProxyDropped: resp.GetProxyDropped(),
+		SampledOut:   resp.GetSampledOut(),
+		Panics:       resp.GetPanics(),
+		Diagnostics:  resp.GetDiagnostics(),
+		Cancellations: cancellations{
+			Relayed:      resp.GetCancellations().GetRelayed(),
+			Killed:       resp.GetCancellations().GetKilled(),
+			TimedOut:     resp.GetCancellations().GetTimedOut(),
+			Disconnected: resp.GetCancellations().GetDisconnected(),
+		},
+		Stages:      make([]stageBody, len(resp.GetStages())),
+		Subscribers: make([]subscriber, len(resp.GetSubscribers())),
+	}
+	for i, st := range resp.GetStages() {
+		body.Stages[i] = stageBody{
+			Name:    st.GetName(),
+			Count:   st.GetCount(),
+			TotalMS: ms(st.GetTotal()),
+			MaxMS:   ms(st.GetMax()),
+			P50MS:   ms(st.GetP50()),
+			P99MS:   ms(st.GetP99()),
+		}
+	}
+	for i, sub := range resp.GetSubscribers() {
+		body.Subscribers[i] = subscriber{
+			ID:       sub.GetId(),
+			Name:     sub.GetName(),
+			Client:   sub.GetClient(),
+			Since:    sub.GetSince().AsTime(),
+			Policy:   sub.GetPolicy(),
+			Dropped:  sub.GetDropped(),
+			Buffered: sub.GetBuffered(),
+			Spilled:  sub.GetSpilled(),
+			Capacity: sub.GetCapacity(),
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// writeStatus writes a TapService error as the matching HTTP status.
+func writeStatus(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		code = http.StatusBadRequest
+	case codes.FailedPrecondition:
+		code = http.StatusNotImplemented
+	}
+	http.Error(w, status.Convert(err).Message(), code)
+}
@@ -0,0 +1,285 @@
+// Package indexadvisor suggests indexes from EXPLAIN plans. A sequential
+// scan costing at least a threshold whose filter compares columns to
+// constants gets a CREATE INDEX on those columns: the equality columns, then
+// at most one range column, the order a B-tree index can serve them in.
+//
+// Only PostgreSQL JSON plans are read so far. Filters with a top-level OR,
+// and comparisons on expressions rather than plain columns, get no
+// suggestion; the statements are candidates to weigh against write cost,
+// not something to apply blindly.
+package indexadvisor
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/tagger"
+)
+
+// Tag is the advisory tag for plans with suggested indexes.
+const Tag = "missing-index"
+
+// Defs returns the index suggestion tag with its TUI color.
+func Defs() []tagger.Def {
+	return []tagger.Def{{Name: Tag, Color: "141"}}
+}
+
+// DefaultMinCost is the estimated total cost from which a sequential scan is
+// worth an index, roughly a table of tens of thousands of rows.
+const DefaultMinCost = 1000.0
+
+// Suggestion is a candidate index for one sequential scan.
+type Suggestion struct {
+	Table   string // as the plan names it, with the schema when it shows one
+	Columns []string
+	Cost    float64 // the scan's estimated total cost
+	Filter  string  // the scan's filter, as the plan prints it
+}
+
+// Statement returns the CREATE INDEX statement for s. It builds the index
+// CONCURRENTLY, so writes to the table are not blocked meanwhile.
+func (s Suggestion) Statement() string {
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY ON %s (%s);", s.Table, strings.Join(s.Columns, ", "))
+}
+
+type pgNode struct {
+	NodeType  string   `json:"Node Type"`
+	Relation  string   `json:"Relation Name"`
+	Schema    string   `json:"Schema"`
+	TotalCost float64  `json:"Total Cost"`
+	Filter    string   `json:"Filter"`
+	Plans     []pgNode `json:"Plans"`
+}
+
+// Postgres returns the suggestions for a plan printed by EXPLAIN (FORMAT
+// JSON), highest cost first, for sequential scans costing at least minCost.
+func Postgres(plan []byte, minCost float64) ([]Suggestion, error) {
+	var roots []struct {
+		Plan pgNode `json:"Plan"`
+	}
+	if err := json.Unmarshal(plan, &roots); err != nil {
+		return nil, fmt.Errorf("indexadvisor: parse plan: %w", err)
+	}
+
+	var out []Suggestion
+	var walk func(n pgNode)
+	walk = func(n pgNode) {
+		if n.NodeType == "Seq Scan" && n.Relation != "" && n.Filter != "" && n.TotalCost >= minCost {
+			if cols := indexColumns(n.Filter); len(cols) > 0 {
+				table := quoteIdent(n.Relation)
+				if n.Schema != "" {
+					table = quoteIdent(n.Schema) + "." + table
+				}
+				out = append(out, Suggestion{Table: table, Columns: cols, Cost: n.TotalCost, Filter: n.Filter})
+			}
+		}
+		for _, c := range n.Plans {
+			walk(c)
+		}
+	}
+	for _, r := range roots {
+		walk(r.Plan)
+	}
+
+	slices.SortStableFunc(out, func(a, b Suggestion) int { return cmp.Compare(b.Cost, a.Cost) })
+	return dedupe(out), nil
+}
+
+// dedupe drops suggestions repeating an earlier one's table and columns: a
+// table scanned several times with the same filter needs one index.
+func dedupe(ss []Suggestion) []Suggestion {
+	seen := make(map[string]bool)
+	out := ss[:0]
+	for _, s := range ss {
+		k := s.Statement()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, s)
+	}
+	return out
+}
+
+// Planner returns JSON plans; *explain.Client is one.
+type Planner interface {
+	PlanJSON(ctx context.Context, query string, args []string) ([]byte, error)
+}
+
+// Advise returns the CREATE INDEX statements suggested for query's plan. It
+// returns nil when p's database is not supported or EXPLAIN fails, which is
+// logged.
+func Advise(ctx context.Context, p Planner, query string, args []string) []string {
+	plan, err := p.PlanJSON(ctx, query, args)
+	if errors.Is(err, explain.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		slog.Debug("index advice failed", "err", err)
+		return nil
+	}
+	ss, err := Postgres(plan, DefaultMinCost)
+	if err != nil {
+		slog.Debug("index advice failed", "err", err)
+		return nil
+	}
+	out := make([]string, len(ss))
+	for i, s := range ss {
+		out[i] = s.Statement()
+	}
+	return out
+}
+
+const ident = `"(?:[^"]|"")+"|[A-Za-z_][A-Za-z0-9_$]*`
+
+var (
+	// A column, maybe table-qualified, parenthesized, and cast as PostgreSQL
+	// prints them, e.g. ((o.status)::text = 'open'::text), then the operator
+	// and the rest of the comparison.
+	rePredicate = regexp.MustCompile(`^\(*(?:(?:` + ident + `)\.)?(` + ident + `)\)*(?:::[A-Za-z][A-Za-z ]*?(?:\[\])?)?\)*\s*(=|<=|>=|<|>|~~|IS NULL\b)\s*(.*)$`)
+
+	// Right-hand sides that stay the same for the whole scan: literals,
+	// parameters, arrays of them, and subplan results.
+	reConstant = regexp.MustCompile(`^\(*(?:'|-?\d|\$\d|ANY \(|NULL\b|true\b|false\b|CURRENT_|now\(\)|InitPlan|SubPlan)`)
+
+	reSimpleIdent = regexp.MustCompile(`^[a-z_][a-z0-9_$]*$`)
+)
+
+// indexColumns returns the columns an index for filter would cover, or nil
+// when filter has no indexable comparison.
+func indexColumns(filter string) []string {
+	parts := conjuncts(filter)
+	var eq, rng []string
+	for _, p := range parts {
+		m := rePredicate.FindStringSubmatch(trimParens(p))
+		if m == nil {
+			continue
+		}
+		col, op, rhs := m[1], m[2], m[3]
+		switch op {
+		case "IS NULL":
+			eq = append(eq, col)
+		case "=":
+			if reConstant.MatchString(rhs) {
+				eq = append(eq, col)
+			}
+		case "~~":
+			// Only a LIKE with a fixed prefix can use the index.
+			if strings.HasPrefix(rhs, "'") && !strings.HasPrefix(rhs, "'%") && !strings.HasPrefix(rhs, "'_") {
+				rng = append(rng, col)
+			}
+		default:
+			if reConstant.MatchString(rhs) {
+				rng = append(rng, col)
+			}
+		}
+	}
+	cols := uniq(eq)
+	for _, c := range rng {
+		if !slices.Contains(cols, c) {
+			cols = append(cols, c)
+			break
+		}
+	}
+	return cols
+}
+
+func uniq(ss []string) []string {
+	var out []string
+	for _, s := range ss {
+		if !slices.Contains(out, s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// conjuncts splits expr on its top-level ANDs. It returns nil when expr has
+// a top-level OR, which a single index does not serve.
+func conjuncts(expr string) []string {
+	expr = trimParens(strings.TrimSpace(expr))
+	var out []string
+	depth, start := 0, 0
+	for i := 0; i < len(expr); i++ {
+		switch expr[i] {
+		case '\'', '"':
+			i = closingQuote(expr, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ' ':
+			switch {
+			case depth > 0:
+			case strings.HasPrefix(expr[i:], " AND "):
+				out = append(out, expr[start:i])
+				start = i + len(" AND ")
+				i += len(" AND ") - 1
+			case strings.HasPrefix(expr[i:], " OR "):
+				return nil
+			}
+		}
+	}
+	return append(out, expr[start:])
+}
+
+// trimParens strips parentheses enclosing all of s.
+func trimParens(s string) string {
+	for len(s) >= 2 && s[0] == '(' && closingParen(s, 0) == len(s)-1 {
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	return s
+}
+
+// closingParen returns the index of the parenthesis closing the one at i, or
+// -1 when it is not closed.
+func closingParen(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\'', '"':
+			i = closingQuote(s, i)
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// closingQuote returns the index of the quote closing the one at i, treating
+// a doubled quote as an escaped one, or the last index when it is not closed.
+func closingQuote(s string, i int) int {
+	q := s[i]
+	for i++; i < len(s); i++ {
+		if s[i] != q {
+			continue
+		}
+		if i+1 < len(s) && s[i+1] == q {
+			i++
+			continue
+		}
+		return i
+	}
+	return len(s) - 1
+}
+
+// quoteIdent quotes name unless PostgreSQL would read it unquoted as is.
+func quoteIdent(name string) string {
+	if reSimpleIdent.MatchString(name) {
+		return name
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
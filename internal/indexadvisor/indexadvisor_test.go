@@ -0,0 +1,128 @@
+package indexadvisor_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/indexadvisor"
+)
+
+// scan returns a JSON plan of one sequential scan.
+func scan(table, filter string, cost float64) string {
+	return fmt.Sprintf(`[{"Plan": {"Node Type": "Seq Scan", "Relation Name": %q, "Alias": "o", "Total Cost": %g, "Filter": %q}}]`,
+		table, cost, filter)
+}
+
+func TestPostgres(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		plan string
+		want []string
+	}{
+		{
+			name: "equality and range",
+			plan: scan("orders", "((created_at > '2026-01-01'::date) AND ((status)::text = 'open'::text) AND (customer_id = 42))", 18334.5),
+			want: []string{"CREATE INDEX CONCURRENTLY ON orders (status, customer_id, created_at);"},
+		},
+		{
+			name: "one range column",
+			plan: scan("events", "((at >= '2026-01-01 00:00:00'::timestamp without time zone) AND (at < now()) AND (id > 10))", 5000),
+			want: []string{"CREATE INDEX CONCURRENTLY ON events (at);"},
+		},
+		{
+			name: "any and is null",
+			plan: scan("jobs", "((deleted_at IS NULL) AND (kind = ANY ('{a,b}'::text[])))", 2000),
+			want: []string{"CREATE INDEX CONCURRENTLY ON jobs (deleted_at, kind);"},
+		},
+		{
+			name: "like prefix",
+			plan: scan("users", "((email)::text ~~ 'alice%'::text)", 2000),
+			want: []string{"CREATE INDEX CONCURRENTLY ON users (email);"},
+		},
+		{
+			name: "quoted names",
+			plan: scan("Orders", `("Status" = 'x'::text)`, 2000),
+			want: []string{`CREATE INDEX CONCURRENTLY ON "Orders" ("Status");`},
+		},
+		{name: "cheap scan", plan: scan("orders", "(customer_id = 42)", 35.5)},
+		{name: "top-level or", plan: scan("orders", "((customer_id = 42) OR (status = 'open'::text))", 5000)},
+		{name: "leading wildcard", plan: scan("users", "((email)::text ~~ '%@example.com'::text)", 5000)},
+		{name: "expression", plan: scan("users", "(lower((email)::text) = 'a@b.c'::text)", 5000)},
+		{name: "column against column", plan: scan("orders", "(updated_at > created_at)", 5000)},
+		{name: "not equal", plan: scan("orders", "((status)::text <> 'done'::text)", 5000)},
+		{
+			name: "nested and repeated",
+			plan: `[{"Plan": {"Node Type": "Hash Join", "Total Cost": 9000, "Plans": [
+				{"Node Type": "Seq Scan", "Relation Name": "orders", "Total Cost": 3000, "Filter": "(customer_id = 1)"},
+				{"Node Type": "Hash", "Total Cost": 8000, "Plans": [
+					{"Node Type": "Seq Scan", "Relation Name": "items", "Schema": "shop", "Total Cost": 8000, "Filter": "(sku = 'x'::text)"}
+				]},
+				{"Node Type": "Seq Scan", "Relation Name": "orders", "Total Cost": 1500, "Filter": "(customer_id = 2)"},
+				{"Node Type": "Index Scan", "Relation Name": "users", "Total Cost": 9000, "Filter": "(id = 3)"}
+			]}}]`,
+			want: []string{
+				"CREATE INDEX CONCURRENTLY ON shop.items (sku);",
+				"CREATE INDEX CONCURRENTLY ON orders (customer_id);",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ss, err := indexadvisor.Postgres([]byte(tt.plan), indexadvisor.DefaultMinCost)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, s := range ss {
+				got = append(got, s.Statement())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPostgres_Invalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := indexadvisor.Postgres([]byte("Seq Scan on orders"), indexadvisor.DefaultMinCost); err == nil {
+		t.Fatal("expected an error for a text plan")
+	}
+}
+
+type fakePlanner struct {
+	plan string
+	err  error
+}
+
+func (f fakePlanner) PlanJSON(context.Context, string, []string) ([]byte, error) {
+	return []byte(f.plan), f.err
+}
+
+func TestAdvise(t *testing.T) {
+	t.Parallel()
+
+	got := indexadvisor.Advise(t.Context(), fakePlanner{plan: scan("orders", "(customer_id = 42)", 5000)}, "SELECT", nil)
+	if want := []string{"CREATE INDEX CONCURRENTLY ON orders (customer_id);"}; !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	for _, p := range []fakePlanner{
+		{err: fmt.Errorf("explain: JSON plan: %w", explain.ErrUnsupported)},
+		{err: errors.New("syntax error")},
+		{plan: "not json"},
+	} {
+		if got := indexadvisor.Advise(t.Context(), p, "SELECT", nil); got != nil {
+			t.Errorf("Advise with %+v = %q, want nil", p, got)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package kms unwraps data keys with a cloud key management service, so the
+// AES-256 key sealing archives and the store can be configured wrapped, and
+// is only ever in the clear in the daemon's memory. AWS KMS uses the AWS SDK's
+// default credential chain; Google Cloud KMS uses application default
+// credentials.
+package kms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	awskms "github.com/aws/aws-sdk-go-v2/service/kms"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+
+	"github.com/mickamy/sql-tap/internal/encrypt"
+)
+
+const (
+	awsScheme = "aws-kms://"
+	gcpScheme = "gcp-kms://"
+
+	gcpEndpoint = "https://cloudkms.googleapis.com"
+	gcpScope    = "https://www.googleapis.com/auth/cloudkms"
+)
+
+// Option configures Decrypt.
+type Option func(*options)
+
+type options struct {
+	client   *http.Client
+	endpoint string // KMS API base URL
+	aws      aws.CredentialsProvider
+	token    string // static OAuth2 bearer token for Google Cloud KMS
+}
+
+// WithHTTPClient sets the client used for KMS and credential requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithEndpoint sends KMS requests to url instead of the provider's public
+// endpoint, e.g. a VPC endpoint or a local emulator.
+func WithEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoint = strings.TrimRight(url, "/")
+	}
+}
+
+// WithAWSCredentials uses static AWS credentials instead of the default
+// chain.
+func WithAWSCredentials(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(o *options) {
+		o.aws = credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)
+	}
+}
+
+// WithToken uses a static OAuth2 access token for Google Cloud KMS instead
+// of application default credentials.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// Key returns the AES-256 key held in value: without uri, the key itself, as
+// encrypt.ParseKey reads it; with uri, a data key wrapped by that KMS key,
+// base64 encoded, which is unwrapped with Decrypt.
+func Key(ctx context.Context, value, uri string, opts ...Option) ([]byte, error) {
+	if uri == "" {
+		return encrypt.ParseKey(value) //nolint:wrapcheck // names the package
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("kms: wrapped key must be base64: %w", err)
+	}
+	key, err := Decrypt(ctx, uri, wrapped, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != encrypt.KeySize {
+		return nil, fmt.Errorf("kms: %s unwrapped a %d-byte key, want %d", uri, len(key), encrypt.KeySize)
+	}
+	return key, nil
+}
+
+// Decrypt returns the plaintext of ciphertext, wrapped by the KMS key at uri:
+//
+//	aws-kms://<key or alias ARN, or alias/<name>>
+//	gcp-kms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>
+//
+// An AWS key's region is taken from its ARN, or else from the default chain.
+func Decrypt(ctx context.Context, uri string, ciphertext []byte, opts ...Option) ([]byte, error) {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	switch {
+	case strings.HasPrefix(uri, awsScheme):
+		return decryptAWS(ctx, strings.TrimPrefix(uri, awsScheme), ciphertext, o)
+	case strings.HasPrefix(uri, gcpScheme):
+		return decryptGCP(ctx, strings.TrimPrefix(uri, gcpScheme), ciphertext, o)
+	default:
+		return nil, fmt.Errorf("kms: %q: want an aws-kms:// or gcp-kms:// key", uri)
+	}
+}
+
+func decryptAWS(ctx context.Context, keyID string, ciphertext []byte, o options) ([]byte, error) {
+	if keyID == "" {
+		return nil, errors.New("kms: aws-kms:// needs a key ARN or alias")
+	}
+	var cfgOpts []func(*config.LoadOptions) error
+	if o.client != nil {
+		cfgOpts = append(cfgOpts, config.WithHTTPClient(o.client))
+	}
+	if region := arnRegion(keyID); region != "" {
+		cfgOpts = append(cfgOpts, config.WithRegion(region))
+	}
+	if o.aws != nil {
+		cfgOpts = append(cfgOpts, config.WithCredentialsProvider(o.aws))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, cfgOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("kms: aws config: %w", err)
+	}
+	client := awskms.NewFromConfig(cfg, func(ko *awskms.Options) {
+		if o.endpoint != "" {
+			ko.BaseEndpoint = aws.String(o.endpoint)
+		}
+	})
+	out, err := client.Decrypt(ctx, &awskms.DecryptInput{CiphertextBlob: ciphertext, KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, fmt.Errorf("kms: decrypt with %s: %w", keyID, err)
+	}
+	return out.Plaintext, nil
+}
+
+// arnRegion returns the region of an ARN such as
+// arn:aws:kms:us-east-1:111122223333:key/..., or "" for anything else.
+func arnRegion(id string) string {
+	parts := strings.SplitN(id, ":", 6)
+	if len(parts) < 6 || parts[0] != "arn" {
+		return ""
+	}
+	return parts[3]
+}
+
+func decryptGCP(ctx context.Context, name string, ciphertext []byte, o options) ([]byte, error) {
+	if !strings.HasPrefix(name, "projects/") || !strings.Contains(name, "/cryptoKeys/") {
+		return nil, fmt.Errorf("kms: gcp-kms://%s: want projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>", name)
+	}
+	if o.client == nil {
+		o.client = http.DefaultClient
+	}
+	token := o.token
+	if token == "" {
+		creds, err := google.FindDefaultCredentials(context.WithValue(ctx, oauth2.HTTPClient, o.client), gcpScope)
+		if err != nil {
+			return nil, fmt.Errorf("kms: google credentials: %w", err)
+		}
+		t, err := creds.TokenSource.Token()
+		if err != nil {
+			return nil, fmt.Errorf("kms: google credentials: %w", err)
+		}
+		token = t.AccessToken
+	}
+
+	endpoint := o.endpoint
+	if endpoint == "" {
+		endpoint = gcpEndpoint
+	}
+	body, err := json.Marshal(map[string]string{"ciphertext": base64.StdEncoding.EncodeToString(ciphertext)})
+	if err != nil {
+		return nil, fmt.Errorf("kms: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint+"/v1/"+name+":decrypt", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("kms: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kms: decrypt with %s: %w", name, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("kms: decrypt with %s: %s: %s", name, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("kms: decrypt with %s: %w", name, err)
+	}
+	plain, err := base64.StdEncoding.DecodeString(out.Plaintext)
+	if err != nil {
+		return nil, fmt.Errorf("kms: decrypt with %s: %w", name, err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,117 @@
+package kms_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/encrypt"
+	"github.com/mickamy/sql-tap/internal/kms"
+)
+
+var (
+	dataKey = bytes.Repeat([]byte{0x42}, encrypt.KeySize)
+	wrapped = []byte("wrapped data key")
+)
+
+func TestKey_AWS(t *testing.T) {
+	t.Parallel()
+
+	const arn = "arn:aws:kms:eu-west-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Amz-Target"); got != "TrentService.Decrypt" {
+			t.Errorf("X-Amz-Target = %q", got)
+		}
+		if auth := r.Header.Get("Authorization"); !strings.Contains(auth, "/eu-west-1/kms/") {
+			t.Errorf("Authorization = %q, want a SigV4 signature for kms in the key's region", auth)
+		}
+		var in struct {
+			CiphertextBlob []byte
+			KeyId          string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+			t.Error(err)
+		}
+		if !bytes.Equal(in.CiphertextBlob, wrapped) || in.KeyId != arn {
+			t.Errorf("Decrypt(%q, %q), want the wrapped key and %s", in.CiphertextBlob, in.KeyId, arn)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		_ = json.NewEncoder(w).Encode(map[string]any{"KeyId": arn, "Plaintext": dataKey})
+	}))
+	defer srv.Close()
+
+	key, err := kms.Key(t.Context(), base64.StdEncoding.EncodeToString(wrapped)+"\n", "aws-kms://"+arn,
+		kms.WithEndpoint(srv.URL), kms.WithAWSCredentials("AKID", "secret", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, dataKey) {
+		t.Errorf("Key = %x, want %x", key, dataKey)
+	}
+}
+
+func TestKey_GCP(t *testing.T) {
+	t.Parallel()
+
+	const name = "projects/p/locations/global/keyRings/r/cryptoKeys/k"
+	plain := dataKey
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/"+name+":decrypt" || r.Header.Get("Authorization") != "Bearer tok" {
+			t.Errorf("%s %s with %q", r.Method, r.URL.Path, r.Header.Get("Authorization"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		if want := base64.StdEncoding.EncodeToString(wrapped); !strings.Contains(string(body), want) {
+			t.Errorf("body = %s, want the wrapped key", body)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plain)})
+	}))
+	defer srv.Close()
+
+	key, err := kms.Key(t.Context(), base64.StdEncoding.EncodeToString(wrapped), "gcp-kms://"+name,
+		kms.WithEndpoint(srv.URL), kms.WithToken("tok"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, dataKey) {
+		t.Errorf("Key = %x, want %x", key, dataKey)
+	}
+
+	plain = dataKey[:16]
+	if _, err := kms.Key(t.Context(), base64.StdEncoding.EncodeToString(wrapped), "gcp-kms://"+name,
+		kms.WithEndpoint(srv.URL), kms.WithToken("tok")); err == nil {
+		t.Error("a 16-byte data key was accepted")
+	}
+}
+
+func TestKey_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		value string
+		uri   string
+	}{
+		{name: "plain key too short", value: "abcd"},
+		{name: "wrapped key not base64", value: "not base64!", uri: "aws-kms://alias/x"},
+		{name: "unknown scheme", value: "AAAA", uri: "vault://transit/x"},
+		{name: "gcp key name", value: "AAAA", uri: "gcp-kms://my-key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := kms.Key(t.Context(), tt.value, tt.uri); err == nil {
+				t.Errorf("Key(%q, %q) succeeded", tt.value, tt.uri)
+			}
+		})
+	}
+
+	key, err := kms.Key(t.Context(), base64.StdEncoding.EncodeToString(dataKey), "")
+	if err != nil || !bytes.Equal(key, dataKey) {
+		t.Errorf("Key without a KMS = %x, %v; want the key itself", key, err)
+	}
+}
@@ -0,0 +1,113 @@
+// Package logfile writes a log file that rotates by size: once a write
+// would take it past the limit, path becomes path.1, path.1 becomes path.2,
+// and so on, dropping the oldest, and a new path is started.
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Writer is a size-rotated log file, safe for concurrent use.
+type Writer struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open opens path for appending, creating it and its directory if needed.
+// The file rotates before a write would take it past maxSize bytes, keeping
+// maxFiles rotated files; maxSize 0 never rotates.
+func Open(path string, maxSize int64, maxFiles int) (*Writer, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("logfile: %w", err)
+	}
+	w := &Writer{path: path, maxSize: maxSize, maxFiles: max(maxFiles, 0)}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("logfile: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("logfile: %w", err)
+	}
+	w.f, w.size = f, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past its limit.
+// A record larger than the limit still goes whole into a fresh file.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	if err != nil {
+		return n, fmt.Errorf("logfile: %w", err)
+	}
+	return n, nil
+}
+
+// rotate shifts the rotated files up by one and starts a new file. Caller
+// holds mu.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return fmt.Errorf("logfile: %w", err)
+	}
+	w.f = nil
+	if w.maxFiles == 0 {
+		_ = os.Remove(w.path)
+	} else {
+		_ = os.Remove(w.rotated(w.maxFiles))
+		for i := w.maxFiles - 1; i >= 1; i-- {
+			_ = os.Rename(w.rotated(i), w.rotated(i+1)) // missing files are fine
+		}
+		if err := os.Rename(w.path, w.rotated(1)); err != nil {
+			return fmt.Errorf("logfile: rotate: %w", err)
+		}
+	}
+	return w.open()
+}
+
+func (w *Writer) rotated(i int) string {
+	return fmt.Sprintf("%s.%d", w.path, i)
+}
+
+// Close closes the file. Later writes fail.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	if err != nil {
+		return fmt.Errorf("logfile: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package logfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/logfile"
+)
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestWriter_Rotate(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs", "agent.log")
+	w, err := logfile.Open(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, want := range map[string]string{path: "fourth\n", path + ".1": "third\n", path + ".2": "second\n"} {
+		if got := read(t, p); got != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(p), got, want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("%s.3 exists beyond the two rotated files kept (err %v)", filepath.Base(path), err)
+	}
+	if _, err := w.Write([]byte("late\n")); err == nil {
+		t.Error("Write after Close succeeded")
+	}
+}
+
+func TestWriter_Append(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "agent.log")
+	if err := os.WriteFile(path, []byte("before\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := logfile.Open(path, 12, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The existing 7 bytes count toward the limit.
+	if _, err := w.Write([]byte("after\n")); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Close()
+	if got := read(t, path); got != "after\n" {
+		t.Errorf("log = %q, want a fresh file after rotation", got)
+	}
+	if got := read(t, path+".1"); got != "before\n" {
+		t.Errorf("rotated log = %q, want the earlier contents", got)
+	}
+}
+
+func TestWriter_NoRotation(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "agent.log")
+	w, err := logfile.Open(path, 0, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		if _, err := w.Write([]byte("0123456789\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = w.Close()
+	if info, err := os.Stat(path); err != nil || info.Size() != 1100 {
+		t.Errorf("log size = %v (err %v), want 1100 bytes unrotated", info.Size(), err)
+	}
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Pipeline stage names recorded by sql-tapd.
+const (
+	StageCapture   = "capture"   // query completion until the event leaves the proxy
+	StageNormalize = "normalize" // extracting fields from the query and its SQL comment
+	StageObserve   = "observe"   // rate, route, database, and tenant statistics, N+1 detection, EXPLAIN queueing
+	StageRedact    = "redact"    // replacing captured arguments in privacy mode
+	StageTag       = "tag"       // applying tagging rules and advisories
+	StagePublish   = "publish"   // broker fan-out to all subscribers
+	StageStream    = "stream"    // proto conversion and gRPC send to one client
+)
+
+// StageSink is the prefix of the stages timing writes to an event sink, the
+// store, the archive, or the OTLP exporter; see Sink.
+const StageSink = "sink:"
+
+// Sink returns the stage name timing writes to the named sink.
+func Sink(name string) string {
+	return StageSink + name
+}
+
+// bucketBounds are the histogram bucket upper bounds. Observations above the
+// last bound land in an overflow bucket.
+var bucketBounds = [...]time.Duration{
+	time.Microsecond,
+	10 * time.Microsecond,
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// Bounds returns the upper bounds of a Histogram's buckets, in the order of
+// Snapshot.Buckets; the final bucket past them has no bound.
+func Bounds() []time.Duration {
+	return slices.Clone(bucketBounds[:])
+}
+
+// Histogram is a fixed-bucket latency histogram safe for concurrent use.
+type Histogram struct {
+	mu      sync.Mutex
+	counts  [len(bucketBounds) + 1]uint64 // the last is the overflow bucket
+	count   uint64
+	sum     time.Duration
+	maximum time.Duration
+}
+
+// NewHistogram creates an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// Observe records one duration.
+func (h *Histogram) Observe(d time.Duration) {
+	i, _ := slices.BinarySearch(bucketBounds[:], d)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.counts[i]++
+	h.count++
+	h.sum += d
+	h.maximum = max(h.maximum, d)
+}
+
+// Snapshot is a point-in-time copy of a Histogram.
+type Snapshot struct {
+	Count uint64
+	Sum   time.Duration
+	Max   time.Duration
+	P50   time.Duration
+	P99   time.Duration
+	// Buckets counts the observations per bucket of Bounds, not cumulatively;
+	// the last counts those above every bound.
+	Buckets [len(bucketBounds) + 1]uint64
+}
+
+// Snapshot returns the current totals. Quantiles are reported as the upper
+// bound of the bucket they fall in, capped at the observed maximum.
+func (h *Histogram) Snapshot() Snapshot {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return Snapshot{
+		Count:   h.count,
+		Sum:     h.sum,
+		Max:     h.maximum,
+		P50:     h.quantile(0.50),
+		P99:     h.quantile(0.99),
+		Buckets: h.counts,
+	}
+}
+
+func (h *Histogram) quantile(q float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(q * float64(h.count))
+	var seen uint64
+	for i, c := range h.counts {
+		seen += c
+		if seen > rank {
+			if i == len(bucketBounds) {
+				return h.maximum
+			}
+			return min(bucketBounds[i], h.maximum)
+		}
+	}
+	return h.maximum
+}
+
+// Stages holds one Histogram per pipeline stage.
+type Stages struct {
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+// NewStages creates an empty Stages.
+func NewStages() *Stages {
+	return &Stages{histograms: make(map[string]*Histogram)}
+}
+
+// Observe records d for the named stage. It is a no-op on a nil Stages, so
+// callers need not check whether instrumentation is enabled.
+func (s *Stages) Observe(stage string, d time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	h, ok := s.histograms[stage]
+	if !ok {
+		h = NewHistogram()
+		s.histograms[stage] = h
+	}
+	s.mu.Unlock()
+
+	h.Observe(d)
+}
+
+// StageSnapshot is the Snapshot of a single named stage.
+type StageSnapshot struct {
+	Name string
+	Snapshot
+}
+
+// Snapshot returns a snapshot of every stage that has been observed, sorted by name.
+func (s *Stages) Snapshot() []StageSnapshot {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]StageSnapshot, 0, len(s.histograms))
+	for name, h := range s.histograms {
+		out = append(out, StageSnapshot{Name: name, Snapshot: h.Snapshot()})
+	}
+	slices.SortFunc(out, func(a, b StageSnapshot) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out
+}
@@ -0,0 +1,121 @@
+package metrics_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/metrics"
+)
+
+func TestHistogram_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		observe []time.Duration
+		want    metrics.Snapshot
+	}{
+		{
+			name: "empty",
+			want: metrics.Snapshot{},
+		},
+		{
+			name:    "single",
+			observe: []time.Duration{5 * time.Millisecond},
+			want: metrics.Snapshot{
+				Count:   1,
+				Sum:     5 * time.Millisecond,
+				Max:     5 * time.Millisecond,
+				P50:     5 * time.Millisecond,
+				P99:     5 * time.Millisecond,
+				Buckets: [8]uint64{4: 1},
+			},
+		},
+		{
+			name: "quantiles use bucket upper bounds",
+			observe: []time.Duration{
+				50 * time.Microsecond, 50 * time.Microsecond, 50 * time.Microsecond,
+				50 * time.Microsecond, 50 * time.Microsecond, 50 * time.Microsecond,
+				50 * time.Microsecond, 50 * time.Microsecond, 50 * time.Microsecond,
+				20 * time.Millisecond,
+			},
+			want: metrics.Snapshot{
+				Count:   10,
+				Sum:     450*time.Microsecond + 20*time.Millisecond,
+				Max:     20 * time.Millisecond,
+				P50:     100 * time.Microsecond,
+				P99:     20 * time.Millisecond,
+				Buckets: [8]uint64{2: 9, 5: 1},
+			},
+		},
+		{
+			name:    "overflow bucket reports max",
+			observe: []time.Duration{3 * time.Second},
+			want: metrics.Snapshot{
+				Count:   1,
+				Sum:     3 * time.Second,
+				Max:     3 * time.Second,
+				P50:     3 * time.Second,
+				P99:     3 * time.Second,
+				Buckets: [8]uint64{7: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			h := metrics.NewHistogram()
+			for _, d := range tt.observe {
+				h.Observe(d)
+			}
+			if got := h.Snapshot(); got != tt.want {
+				t.Errorf("Snapshot() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBounds(t *testing.T) {
+	t.Parallel()
+
+	bounds := metrics.Bounds()
+	if len(bounds) != len(metrics.Snapshot{}.Buckets)-1 {
+		t.Fatalf("%d bounds for %d buckets, want one fewer", len(bounds), len(metrics.Snapshot{}.Buckets))
+	}
+	bounds[0] = time.Hour
+	if metrics.Bounds()[0] == time.Hour {
+		t.Error("Bounds returned the histogram's own slice")
+	}
+}
+
+func TestStages_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	s := metrics.NewStages()
+	s.Observe(metrics.StagePublish, time.Millisecond)
+	s.Observe(metrics.StageCapture, time.Millisecond)
+	s.Observe(metrics.StageCapture, time.Millisecond)
+
+	got := s.Snapshot()
+	if len(got) != 2 {
+		t.Fatalf("len = %d, want 2", len(got))
+	}
+	if got[0].Name != metrics.StageCapture || got[0].Count != 2 {
+		t.Errorf("got[0] = %+v, want capture with count 2", got[0])
+	}
+	if got[1].Name != metrics.StagePublish || got[1].Count != 1 {
+		t.Errorf("got[1] = %+v, want publish with count 1", got[1])
+	}
+}
+
+func TestStages_Nil(t *testing.T) {
+	t.Parallel()
+
+	var s *metrics.Stages
+	s.Observe(metrics.StageStream, time.Millisecond) // must not panic
+	if got := s.Snapshot(); got != nil {
+		t.Errorf("Snapshot() = %v, want nil", got)
+	}
+}
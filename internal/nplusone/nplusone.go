@@ -0,0 +1,180 @@
+// Package nplusone flags probable N+1 query patterns: the same parameterized
+// query fingerprint run again and again by one client, typically once per
+// row of an earlier result. Within a transaction every call of the
+// fingerprint counts; outside one, calls on a connection count while each
+// follows the previous within a short window. Once a burst reaches the
+// threshold, its events are tagged and annotated with the burst's size.
+package nplusone
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Tag is added to events of a burst.
+const Tag = "n+1"
+
+// Defs returns the N+1 tag with its TUI color.
+func Defs() []tagger.Def {
+	return []tagger.Def{{Name: Tag, Color: "214"}}
+}
+
+// Defaults for the Detector options.
+const (
+	DefaultThreshold = 5
+	DefaultWindow    = 100 * time.Millisecond
+	// DefaultMaxScopes bounds memory on workloads with many connections or
+	// transactions left open.
+	DefaultMaxScopes = 10000
+)
+
+const (
+	// maxFingerprintsPerScope bounds the fingerprints tracked per
+	// connection or transaction.
+	maxFingerprintsPerScope = 1000
+	// scopeIdle is how long a scope may go without queries before it can be
+	// evicted to make room.
+	scopeIdle = time.Minute
+)
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithThreshold sets how many calls of a fingerprint make a burst.
+func WithThreshold(n int) Option {
+	return func(d *Detector) {
+		d.threshold = n
+	}
+}
+
+// WithWindow sets the longest pause between calls that keeps a burst outside
+// a transaction going.
+func WithWindow(w time.Duration) Option {
+	return func(d *Detector) {
+		d.window = w
+	}
+}
+
+// scope is where calls are counted: a transaction, or a connection's
+// statements outside one.
+type scope struct {
+	upstream, conn, tx string
+}
+
+type scopeState struct {
+	last   time.Time
+	bursts map[string]*burst // by fingerprint
+}
+
+type burst struct {
+	calls       int
+	first, last time.Time
+}
+
+// Detector counts repeated fingerprints per connection and transaction. It
+// is not safe for concurrent use.
+type Detector struct {
+	threshold int
+	window    time.Duration
+	scopes    map[scope]*scopeState
+}
+
+// New returns a Detector with the default settings, adjusted by opts.
+func New(opts ...Option) *Detector {
+	d := &Detector{
+		threshold: DefaultThreshold,
+		window:    DefaultWindow,
+		scopes:    make(map[scope]*scopeState),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Observe counts ev, received at now. Events of a burst that reached the
+// threshold get ev.NPlusOne and the N+1 tag. Commits and rollbacks end
+// their transaction's bursts, and disconnects their connection's. Statements without parameters or literals,
+// such as SELECT now(), and events without a connection are not counted.
+func (d *Detector) Observe(ev *proxy.Event, now time.Time) {
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	case proxy.OpCommit, proxy.OpRollback:
+		if ev.TxID != "" {
+			delete(d.scopes, scope{upstream: ev.Upstream, conn: ev.ConnID, tx: ev.TxID})
+		}
+		return
+	case proxy.OpDisconnect:
+		delete(d.scopes, scope{upstream: ev.Upstream, conn: ev.ConnID})
+		if ev.TxID != "" {
+			delete(d.scopes, scope{upstream: ev.Upstream, conn: ev.ConnID, tx: ev.TxID})
+		}
+		return
+	default:
+		return
+	}
+	if ev.Query == "" || ev.ConnID == "" {
+		return
+	}
+	fp := ev.Fingerprint
+	if fp == "" {
+		fp = query.Fingerprint(ev.Query)
+	}
+	if !strings.Contains(fp, "?") {
+		return
+	}
+
+	k := scope{upstream: ev.Upstream, conn: ev.ConnID, tx: ev.TxID}
+	st, ok := d.scopes[k]
+	if !ok {
+		if len(d.scopes) >= DefaultMaxScopes {
+			d.evict(now)
+		}
+		st = &scopeState{bursts: make(map[string]*burst)}
+		d.scopes[k] = st
+	}
+	st.last = now
+
+	b, ok := st.bursts[fp]
+	if !ok {
+		if len(st.bursts) >= maxFingerprintsPerScope {
+			return
+		}
+		b = &burst{first: now}
+		st.bursts[fp] = b
+	} else if ev.TxID == "" && now.Sub(b.last) > d.window {
+		*b = burst{first: now}
+	}
+	b.calls++
+	b.last = now
+	if b.calls < d.threshold {
+		return
+	}
+
+	ev.NPlusOne = &proxy.NPlusOne{Calls: b.calls, Span: b.last.Sub(b.first), InTx: ev.TxID != ""}
+	if !slices.Contains(ev.Tags, Tag) {
+		ev.Tags = append(ev.Tags, Tag)
+	}
+}
+
+// evict drops the scopes idle for scopeIdle, or an arbitrary one if none
+// are.
+func (d *Detector) evict(now time.Time) {
+	for k, st := range d.scopes {
+		if now.Sub(st.last) >= scopeIdle {
+			delete(d.scopes, k)
+		}
+	}
+	if len(d.scopes) < DefaultMaxScopes {
+		return
+	}
+	for k := range d.scopes {
+		delete(d.scopes, k)
+		break
+	}
+}
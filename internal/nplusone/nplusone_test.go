@@ -0,0 +1,117 @@
+package nplusone_test
+
+import (
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/nplusone"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+var t0 = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func lookup(conn, tx string, id int) *proxy.Event {
+	return &proxy.Event{
+		Op:     proxy.OpQuery,
+		ConnID: conn,
+		TxID:   tx,
+		Query:  "SELECT * FROM orders WHERE user_id = " + strconv.Itoa(id),
+	}
+}
+
+func TestDetector_Observe(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		tx    string
+		gap   time.Duration
+		calls int
+		want  int // calls of the last event's burst; 0 for unflagged
+	}{
+		{name: "below threshold", gap: time.Millisecond, calls: 4},
+		{name: "burst on a connection", gap: time.Millisecond, calls: 7, want: 7},
+		{name: "pauses break the burst", gap: 200 * time.Millisecond, calls: 10},
+		{name: "a transaction has no window", tx: "tx-1", gap: time.Second, calls: 5, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := nplusone.New()
+			var ev *proxy.Event
+			for i := range tt.calls {
+				ev = lookup("c1", tt.tx, i)
+				d.Observe(ev, t0.Add(time.Duration(i)*tt.gap))
+			}
+			if tt.want == 0 {
+				if ev.NPlusOne != nil || slices.Contains(ev.Tags, nplusone.Tag) {
+					t.Fatalf("unexpected flag: %+v %v", ev.NPlusOne, ev.Tags)
+				}
+				return
+			}
+			if ev.NPlusOne == nil || !slices.Contains(ev.Tags, nplusone.Tag) {
+				t.Fatalf("expected a flag, got %+v %v", ev.NPlusOne, ev.Tags)
+			}
+			want := proxy.NPlusOne{Calls: tt.want, Span: time.Duration(tt.calls-1) * tt.gap, InTx: tt.tx != ""}
+			if *ev.NPlusOne != want {
+				t.Errorf("NPlusOne = %+v, want %+v", *ev.NPlusOne, want)
+			}
+		})
+	}
+}
+
+func TestDetector_Scopes(t *testing.T) {
+	t.Parallel()
+
+	d := nplusone.New(nplusone.WithThreshold(3))
+	// Interleaved connections and the statement outside the transaction
+	// are counted apart.
+	for i := range 2 {
+		d.Observe(lookup("c1", "", i), t0)
+		d.Observe(lookup("c2", "", i), t0)
+		d.Observe(lookup("c1", "tx-1", i), t0)
+	}
+	ev := lookup("c1", "tx-1", 2)
+	d.Observe(ev, t0)
+	if ev.NPlusOne == nil || ev.NPlusOne.Calls != 3 {
+		t.Fatalf("expected the third call in tx-1 to be flagged, got %+v", ev.NPlusOne)
+	}
+
+	// Committing ends the transaction's bursts.
+	d.Observe(&proxy.Event{Op: proxy.OpCommit, ConnID: "c1", TxID: "tx-1", Query: "COMMIT"}, t0)
+	ev = lookup("c1", "tx-1", 3)
+	d.Observe(ev, t0)
+	if ev.NPlusOne != nil {
+		t.Fatalf("expected a fresh count after commit, got %+v", ev.NPlusOne)
+	}
+
+	// Disconnecting ends the connection's bursts; c2's two calls are gone.
+	d.Observe(&proxy.Event{Op: proxy.OpDisconnect, ConnID: "c2"}, t0)
+	ev = lookup("c2", "", 2)
+	d.Observe(ev, t0)
+	if ev.NPlusOne != nil {
+		t.Fatalf("expected a fresh count after disconnect, got %+v", ev.NPlusOne)
+	}
+}
+
+func TestDetector_Skips(t *testing.T) {
+	t.Parallel()
+
+	d := nplusone.New(nplusone.WithThreshold(2), nplusone.WithWindow(time.Second))
+	for _, ev := range []*proxy.Event{
+		{Op: proxy.OpQuery, ConnID: "c1", Query: "SELECT now()"},
+		{Op: proxy.OpQuery, Query: "SELECT * FROM t WHERE id = 1"},
+		{Op: proxy.OpPrepare, ConnID: "c1", Query: "SELECT * FROM t WHERE id = $1"},
+	} {
+		for range 3 {
+			e := *ev
+			d.Observe(&e, t0)
+			if e.NPlusOne != nil {
+				t.Fatalf("unexpected flag on %v %q", e.Op, e.Query)
+			}
+		}
+	}
+}
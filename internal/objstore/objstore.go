@@ -0,0 +1,109 @@
+// Package objstore uploads files to object storage: Amazon S3 (and
+// S3-compatible services) and Google Cloud Storage. Credentials come from the
+// AWS SDK's default chain and Google application default credentials, the
+// same ones internal/kms uses, so no secrets need to be passed to sql-tap.
+package objstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+)
+
+// Store uploads files under a bucket and key prefix.
+type Store interface {
+	// Put uploads the file at path as key, relative to the store's prefix.
+	Put(ctx context.Context, key, path string) error
+	// String returns the store's URL, e.g. "s3://bucket/prefix".
+	String() string
+}
+
+// Option configures a Store.
+type Option func(*options)
+
+type options struct {
+	client   *http.Client
+	endpoint string // storage API base URL
+	aws      aws.CredentialsProvider
+	token    string // static OAuth2 bearer token for GCS
+}
+
+// WithHTTPClient sets the client used for storage and credential requests.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *options) {
+		o.client = c
+	}
+}
+
+// WithEndpoint sends storage requests to url instead of the provider's
+// public endpoint, e.g. a MinIO server or a GCS emulator. S3 requests to a
+// custom endpoint use path-style addressing.
+func WithEndpoint(url string) Option {
+	return func(o *options) {
+		o.endpoint = strings.TrimRight(url, "/")
+	}
+}
+
+// WithAWSCredentials uses static AWS credentials instead of the default
+// chain.
+func WithAWSCredentials(accessKeyID, secretAccessKey, sessionToken string) Option {
+	return func(o *options) {
+		o.aws = credentials.NewStaticCredentialsProvider(accessKeyID, secretAccessKey, sessionToken)
+	}
+}
+
+// WithToken uses a static OAuth2 access token for GCS instead of
+// application default credentials.
+func WithToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// Open returns the Store for rawURL: s3://bucket[/prefix] or
+// gs://bucket[/prefix].
+func Open(rawURL string, opts ...Option) (Store, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("objstore: parse %q: %w", rawURL, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("objstore: %q: bucket is required", rawURL)
+	}
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "s3":
+		return newS3(u.Host, prefix, o)
+	case "gs":
+		return newGCS(u.Host, prefix, o), nil
+	}
+	return nil, fmt.Errorf("objstore: %q: scheme must be s3 or gs", rawURL)
+}
+
+// objectKey joins the store prefix and key.
+func objectKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
+
+// checkStatus turns a non-2xx response into an error quoting the start of
+// its body.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("%s %s: %s: %s", resp.Request.Method, resp.Request.URL.Redacted(), resp.Status, strings.TrimSpace(string(msg)))
+}
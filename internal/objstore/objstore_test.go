@@ -0,0 +1,241 @@
+package objstore_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/objstore"
+)
+
+func TestOpen(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		url     string
+		want    string
+		wantErr bool
+	}{
+		{name: "s3", url: "s3://bucket/captures/", want: "s3://bucket/captures"},
+		{name: "gcs", url: "gs://bucket", want: "gs://bucket"},
+		{name: "no bucket", url: "s3:///captures", wantErr: true},
+		{name: "unknown scheme", url: "ftp://bucket", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			s, err := objstore.Open(tt.url, objstore.WithToken("t"), objstore.WithAWSCredentials("AKID", "secret", ""))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Open(%q) succeeded, want error", tt.url)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Open(%q): %v", tt.url, err)
+			}
+			if got := s.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// upload records the requests a fake storage server received.
+type upload struct {
+	mu     sync.Mutex
+	method string
+	path   string
+	query  string
+	auth   string
+	token  string // X-Amz-Security-Token
+	body   string
+}
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "day.ndjson.gz")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func storageServer(t *testing.T, got *upload) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got.mu.Lock()
+		defer got.mu.Unlock()
+		got.method, got.path, got.query = r.Method, r.URL.Path, r.URL.RawQuery
+		got.auth, got.token, got.body = r.Header.Get("Authorization"), r.Header.Get("X-Amz-Security-Token"), string(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// isolateAWS points the AWS default chain away from the machine's own
+// configuration.
+func isolateAWS(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_CONTAINER_CREDENTIALS_RELATIVE_URI", "AWS_CONTAINER_CREDENTIALS_FULL_URI",
+		"AWS_ENDPOINT_URL", "AWS_ENDPOINT_URL_S3"} {
+		t.Setenv(name, "")
+	}
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_REGION", "eu-west-1")
+}
+
+func putS3(t *testing.T, got *upload) {
+	t.Helper()
+	storage := storageServer(t, got)
+	s, err := objstore.Open("s3://captures/tap", objstore.WithEndpoint(storage.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "sql-tap-2026-01-02.ndjson.gz", writeFile(t, "payload")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+}
+
+//nolint:paralleltest // sets the environment
+func TestS3_PutWithInstanceCredentials(t *testing.T) {
+	isolateAWS(t)
+	imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/latest/api/token":
+			w.Header().Set("X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "21600")
+			_, _ = io.WriteString(w, "imds-token")
+		case r.Header.Get("X-Aws-Ec2-Metadata-Token") != "imds-token":
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/":
+			_, _ = io.WriteString(w, "tap-role")
+		case r.URL.Path == "/latest/meta-data/iam/security-credentials/tap-role":
+			_, _ = io.WriteString(w, `{"Code":"Success","AccessKeyId":"AKID","SecretAccessKey":"secret","Token":"session",`+
+				`"Expiration":"`+time.Now().Add(time.Hour).UTC().Format(time.RFC3339)+`"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(imds.Close)
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "")
+	t.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", imds.URL)
+
+	var got upload
+	putS3(t, &got)
+
+	got.mu.Lock()
+	defer got.mu.Unlock()
+	if got.method != http.MethodPut || got.path != "/captures/tap/sql-tap-2026-01-02.ndjson.gz" {
+		t.Errorf("request = %s %s, want PUT /captures/tap/sql-tap-2026-01-02.ndjson.gz", got.method, got.path)
+	}
+	if !strings.HasPrefix(got.auth, "AWS4-HMAC-SHA256 Credential=AKID/") ||
+		!strings.Contains(got.auth, "/eu-west-1/s3/") || got.token != "session" {
+		t.Errorf("Authorization = %q, token = %q, want a SigV4 signature with the instance credentials", got.auth, got.token)
+	}
+	if got.body != "payload" {
+		t.Errorf("body = %q, want %q", got.body, "payload")
+	}
+}
+
+//nolint:paralleltest // sets the environment
+func TestS3_PutWithProfile(t *testing.T) {
+	isolateAWS(t)
+	creds := filepath.Join(t.TempDir(), "credentials")
+	content := "[default]\naws_access_key_id = WRONG\naws_secret_access_key = wrong\n\n" +
+		"[tap]\naws_access_key_id = PROFILEKEY\naws_secret_access_key = secret\n"
+	if err := os.WriteFile(creds, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", creds)
+	t.Setenv("AWS_PROFILE", "tap")
+
+	var got upload
+	putS3(t, &got)
+
+	got.mu.Lock()
+	defer got.mu.Unlock()
+	if !strings.HasPrefix(got.auth, "AWS4-HMAC-SHA256 Credential=PROFILEKEY/") {
+		t.Errorf("Authorization = %q, want a SigV4 signature with the profile's key", got.auth)
+	}
+}
+
+//nolint:paralleltest // sets the environment
+func TestGCS_PutWithMetadataToken(t *testing.T) {
+	metadata := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" ||
+			r.URL.Path != "/computeMetadata/v1/instance/service-accounts/default/token" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, `{"access_token":"gce-token","expires_in":3600,"token_type":"Bearer"}`)
+	}))
+	t.Cleanup(metadata.Close)
+	// Application default credentials fall back to the metadata server
+	// when there is no credentials file.
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	t.Setenv("CLOUDSDK_CONFIG", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("STORAGE_EMULATOR_HOST", "")
+	t.Setenv("GCE_METADATA_HOST", strings.TrimPrefix(metadata.URL, "http://"))
+	var got upload
+	storage := storageServer(t, &got)
+
+	s, err := objstore.Open("gs://captures/tap", objstore.WithEndpoint(storage.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(context.Background(), "sql-tap-2026-01-02.ndjson.gz", writeFile(t, "payload")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	got.mu.Lock()
+	defer got.mu.Unlock()
+	if got.method != http.MethodPost || got.path != "/upload/storage/v1/b/captures/o" {
+		t.Errorf("request = %s %s, want POST /upload/storage/v1/b/captures/o", got.method, got.path)
+	}
+	if want := "uploadType=media&name=tap%2Fsql-tap-2026-01-02.ndjson.gz"; got.query != want {
+		t.Errorf("query = %q, want %q", got.query, want)
+	}
+	if got.auth != "Bearer gce-token" {
+		t.Errorf("Authorization = %q, want %q", got.auth, "Bearer gce-token")
+	}
+	if got.body != "payload" {
+		t.Errorf("body = %q, want %q", got.body, "payload")
+	}
+}
+
+func TestPut_ErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = io.WriteString(w, `<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+	}))
+	t.Cleanup(srv.Close)
+
+	s, err := objstore.Open("s3://captures",
+		objstore.WithEndpoint(srv.URL),
+		objstore.WithAWSCredentials("AKID", "secret", ""),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.Put(context.Background(), "x.gz", writeFile(t, "payload"))
+	if err == nil || !strings.Contains(err.Error(), "AccessDenied") {
+		t.Fatalf("Put error = %v, want the server's AccessDenied", err)
+	}
+}
@@ -0,0 +1,83 @@
+package objstore
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// defaultRegion is used when the default chain names no region.
+const defaultRegion = "us-east-1"
+
+type s3Store struct {
+	bucket string
+	prefix string
+	client *s3.Client
+}
+
+// newS3 loads the AWS SDK's default configuration: the region from
+// AWS_REGION or the shared config file (default us-east-1), a custom
+// endpoint from AWS_ENDPOINT_URL_S3 or AWS_ENDPOINT_URL, and credentials
+// from the default chain, which covers environment variables, AWS_PROFILE
+// and the shared credentials and config files, web identity tokens (IRSA),
+// SSO, the ECS container endpoint, and EC2 instance metadata.
+func newS3(bucket, prefix string, o options) (*s3Store, error) {
+	var cfgOpts []func(*config.LoadOptions) error
+	if o.client != nil {
+		cfgOpts = append(cfgOpts, config.WithHTTPClient(o.client))
+	}
+	if o.aws != nil {
+		cfgOpts = append(cfgOpts, config.WithCredentialsProvider(o.aws))
+	}
+	cfg, err := config.LoadDefaultConfig(context.Background(), cfgOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("objstore: aws config: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = defaultRegion
+	}
+	client := s3.NewFromConfig(cfg, func(so *s3.Options) {
+		if o.endpoint == "" {
+			return
+		}
+		// S3-compatible services such as MinIO serve buckets under the
+		// path and may not accept the checksums S3 itself asks for.
+		so.BaseEndpoint = aws.String(o.endpoint)
+		so.UsePathStyle = true
+		so.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+	})
+	return &s3Store{bucket: bucket, prefix: prefix, client: client}, nil
+}
+
+func (s *s3Store) String() string {
+	return strings.TrimSuffix("s3://"+s.bucket+"/"+s.prefix, "/")
+}
+
+func (s *s3Store) Put(ctx context.Context, key, path string) error {
+	f, err := os.Open(path) //nolint:gosec // path is an archive file chosen by the caller
+	if err != nil {
+		return fmt.Errorf("objstore: open %s: %w", path, err)
+	}
+	defer func() { _ = f.Close() }()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("objstore: stat %s: %w", path, err)
+	}
+
+	objKey := objectKey(s.prefix, key)
+	_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(objKey),
+		Body:          f,
+		ContentLength: aws.Int64(info.Size()),
+	})
+	if err != nil {
+		return fmt.Errorf("objstore: put %s: %w", objKey, err)
+	}
+	return nil
+}
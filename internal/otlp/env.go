@@ -0,0 +1,102 @@
+package otlp
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FromEnv reads the standard OpenTelemetry exporter variables for signal
+// through getenv, returning the endpoint they name ("" if none) and options
+// for the rest, applied after any of the caller's own:
+//
+//   - OTEL_EXPORTER_OTLP_<SIGNAL>_ENDPOINT, used as is, or else
+//     OTEL_EXPORTER_OTLP_ENDPOINT with /v1/<signal> appended
+//   - OTEL_EXPORTER_OTLP_HEADERS and OTEL_EXPORTER_OTLP_<SIGNAL>_HEADERS,
+//     comma-separated key=value pairs with URL-encoded values
+//   - OTEL_EXPORTER_OTLP_TIMEOUT and OTEL_EXPORTER_OTLP_<SIGNAL>_TIMEOUT, in
+//     milliseconds
+//   - OTEL_EXPORTER_OTLP_PROTOCOL and OTEL_EXPORTER_OTLP_<SIGNAL>_PROTOCOL,
+//     which must be http/json, the only encoding the exporter speaks
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES, the latter in the
+//     headers' format
+//
+// The per-signal variables take precedence over the general ones.
+func FromEnv(getenv func(string) string, signal Signal) (endpoint string, opts []Option, err error) {
+	upper := strings.ToUpper(string(signal))
+	get := func(name string) string {
+		if v := getenv("OTEL_EXPORTER_OTLP_" + upper + "_" + name); v != "" {
+			return v
+		}
+		return getenv("OTEL_EXPORTER_OTLP_" + name)
+	}
+
+	endpoint = getenv("OTEL_EXPORTER_OTLP_" + upper + "_ENDPOINT")
+	if base := getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint == "" && base != "" {
+		endpoint = strings.TrimSuffix(base, "/") + signal.path()
+	}
+
+	if p := get("PROTOCOL"); p != "" && p != "http/json" {
+		return "", nil, fmt.Errorf("otlp: protocol %q is not supported; use http/json", p)
+	}
+	headers := make(map[string]string)
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_HEADERS", "OTEL_EXPORTER_OTLP_" + upper + "_HEADERS"} {
+		h, err := parsePairs(getenv(name))
+		if err != nil {
+			return "", nil, fmt.Errorf("otlp: %s: %w", name, err)
+		}
+		for k, v := range h {
+			headers[k] = v
+		}
+	}
+	if len(headers) > 0 {
+		opts = append(opts, WithHeaders(headers))
+	}
+	if v := get("TIMEOUT"); v != "" {
+		ms, err := strconv.Atoi(v)
+		if err != nil || ms <= 0 {
+			return "", nil, fmt.Errorf("otlp: timeout %q: want a positive number of milliseconds", v)
+		}
+		opts = append(opts, WithTimeout(time.Duration(ms)*time.Millisecond))
+	}
+
+	attrs, err := parsePairs(getenv("OTEL_RESOURCE_ATTRIBUTES"))
+	if err != nil {
+		return "", nil, fmt.Errorf("otlp: OTEL_RESOURCE_ATTRIBUTES: %w", err)
+	}
+	if name := getenv("OTEL_SERVICE_NAME"); name != "" {
+		attrs["service.name"] = name
+	}
+	if name, ok := attrs["service.name"]; ok {
+		opts = append(opts, WithServiceName(name))
+		delete(attrs, "service.name")
+	}
+	if len(attrs) > 0 {
+		opts = append(opts, WithResourceAttributes(attrs))
+	}
+	return endpoint, opts, nil
+}
+
+// parsePairs parses a comma-separated list of key=value pairs whose values
+// are URL-encoded, the format of OTEL_EXPORTER_OTLP_HEADERS.
+func parsePairs(s string) (map[string]string, error) {
+	pairs := make(map[string]string)
+	for p := range strings.SplitSeq(s, ",") {
+		if strings.TrimSpace(p) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(p, "=")
+		k = strings.TrimSpace(k)
+		if !ok || k == "" {
+			return nil, fmt.Errorf("%q is not key=value", p)
+		}
+		v, err := url.PathUnescape(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("%q: %w", p, err)
+		}
+		pairs[k] = v
+	}
+	return pairs, nil
+}
@@ -0,0 +1,65 @@
+package otlp
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Severity numbers of the OTLP log data model.
+const (
+	severityInfo  = 9
+	severityError = 17
+)
+
+// newLogRecord returns ev as a log record whose body is the query, in the
+// trace and span that issued it, if any. Its time is when the query was
+// sent; the duration is an attribute.
+func newLogRecord(ev proxy.Event) logRecord {
+	r := logRecord{
+		TimeUnixNano:         strconv.FormatInt(ev.StartTime.UnixNano(), 10),
+		ObservedTimeUnixNano: strconv.FormatInt(ev.StartTime.Add(ev.Duration).UnixNano(), 10),
+		SeverityNumber:       severityInfo,
+		SeverityText:         "INFO",
+		Body:                 anyValue{StringValue: &ev.Query},
+		Attributes: append(queryAttrs(ev),
+			doubleAttr("sql_tap.duration_ms", float64(ev.Duration)/float64(time.Millisecond))),
+		TraceID: ev.TraceID,
+		SpanID:  ev.SpanID,
+	}
+	if ev.Error != "" {
+		r.SeverityNumber = severityError
+		r.SeverityText = "ERROR"
+		r.Attributes = append(r.Attributes, stringAttr("sql_tap.error", ev.Error))
+	}
+	return r
+}
+
+// The OTLP JSON encoding of ExportLogsServiceRequest, limited to the fields
+// sql-tap sets.
+
+type logsRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string      `json:"timeUnixNano"`
+	ObservedTimeUnixNano string      `json:"observedTimeUnixNano"`
+	SeverityNumber       int         `json:"severityNumber"`
+	SeverityText         string      `json:"severityText"`
+	Body                 anyValue    `json:"body"`
+	Attributes           []attribute `json:"attributes"`
+	TraceID              string      `json:"traceId,omitempty"`
+	SpanID               string      `json:"spanId,omitempty"`
+}
@@ -0,0 +1,387 @@
+// Package otlp exports captured queries over OTLP/HTTP with JSON encoding,
+// as client spans or log records, so queries that carry a sqlcommenter
+// traceparent appear in the distributed trace of the request that issued
+// them.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Signal is the kind of OTLP data an Exporter sends.
+type Signal string
+
+const (
+	// SignalTraces exports each query as a client span.
+	SignalTraces Signal = "traces"
+	// SignalLogs exports each query as a log record, carrying the trace
+	// context of the span that issued it.
+	SignalLogs Signal = "logs"
+)
+
+// path is the OTLP/HTTP endpoint path for the signal, appended to
+// endpoints given without one.
+func (s Signal) path() string {
+	return "/v1/" + string(s)
+}
+
+// defaultTimeout bounds each post unless WithTimeout says otherwise; it is
+// the default of OTEL_EXPORTER_OTLP_TIMEOUT.
+const defaultTimeout = 10 * time.Second
+
+// Option configures an Exporter.
+type Option func(*Exporter)
+
+// WithServiceName sets the service.name resource attribute. The default is
+// "sql-tap".
+func WithServiceName(name string) Option {
+	return func(e *Exporter) {
+		e.service = name
+	}
+}
+
+// WithResourceAttributes adds attributes to the exported resource, beside
+// service.name.
+func WithResourceAttributes(attrs map[string]string) Option {
+	return func(e *Exporter) {
+		for k, v := range attrs {
+			e.resource[k] = v
+		}
+	}
+}
+
+// WithSignal sets what the exporter sends; the default is SignalTraces.
+func WithSignal(s Signal) Option {
+	return func(e *Exporter) {
+		e.signal = s
+	}
+}
+
+// WithUntraced exports statements without trace context too: as root
+// spans of traces of their own, or as log records without trace IDs. By
+// default only queries carrying a traceparent are exported.
+func WithUntraced() Option {
+	return func(e *Exporter) {
+		e.untraced = true
+	}
+}
+
+// WithHeaders sets headers sent with each post, e.g. an API key.
+func WithHeaders(h map[string]string) Option {
+	return func(e *Exporter) {
+		for k, v := range h {
+			e.headers.Set(k, v)
+		}
+	}
+}
+
+// WithTimeout bounds each post; the default is 10 seconds.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Exporter) {
+		e.timeout = d
+	}
+}
+
+// WithHTTPClient sets the client used to post spans.
+func WithHTTPClient(c *http.Client) Option {
+	return func(e *Exporter) {
+		e.client = c
+	}
+}
+
+// Exporter posts spans or log records to an OTLP/HTTP collector.
+type Exporter struct {
+	url      string
+	signal   Signal
+	untraced bool
+	service  string
+	resource map[string]string
+	headers  http.Header
+	timeout  time.Duration
+	client   *http.Client
+}
+
+// New returns an Exporter for endpoint, e.g. "http://localhost:4318".
+// Endpoints without a path post to /v1/traces, or /v1/logs for SignalLogs.
+func New(endpoint string, opts ...Option) (*Exporter, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("otlp: parse endpoint: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("otlp: endpoint %q: scheme must be http or https", endpoint)
+	}
+	e := &Exporter{
+		signal:   SignalTraces,
+		service:  "sql-tap",
+		resource: make(map[string]string),
+		headers:  make(http.Header),
+		timeout:  defaultTimeout,
+		client:   http.DefaultClient,
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	switch e.signal {
+	case SignalTraces, SignalLogs:
+	default:
+		return nil, fmt.Errorf("otlp: unknown signal %q", e.signal)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = e.signal.path()
+	}
+	e.url = u.String()
+	return e, nil
+}
+
+// URL returns the endpoint the exporter posts to.
+func (e *Exporter) URL() string {
+	return e.url
+}
+
+// Exports reports whether Export sends ev: a statement carrying trace
+// context, or with WithUntraced, any statement.
+func (e *Exporter) Exports(ev proxy.Event) bool {
+	if ev.TraceID != "" {
+		return true
+	}
+	if !e.untraced {
+		return false
+	}
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback:
+		return true
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice,
+		proxy.OpConnect, proxy.OpDisconnect:
+	}
+	return false
+}
+
+// Export posts a span or log record for each event Exports accepts; a
+// traced query's span is a child of the span that issued it. Other events
+// are skipped.
+func (e *Exporter) Export(ctx context.Context, events []proxy.Event) error {
+	var spans []span
+	var records []logRecord
+	for _, ev := range events {
+		if !e.Exports(ev) {
+			continue
+		}
+		switch e.signal {
+		case SignalTraces:
+			spans = append(spans, newSpan(ev))
+		case SignalLogs:
+			records = append(records, newLogRecord(ev))
+		}
+	}
+	if len(spans) == 0 && len(records) == 0 {
+		return nil
+	}
+
+	res := resource{Attributes: e.resourceAttrs()}
+	sc := scope{Name: "github.com/mickamy/sql-tap"}
+	var body any
+	switch e.signal {
+	case SignalTraces:
+		body = request{ResourceSpans: []resourceSpans{{
+			Resource:   res,
+			ScopeSpans: []scopeSpans{{Scope: sc, Spans: spans}},
+		}}}
+	case SignalLogs:
+		body = logsRequest{ResourceLogs: []resourceLogs{{
+			Resource:  res,
+			ScopeLogs: []scopeLogs{{Scope: sc, LogRecords: records}},
+		}}}
+	}
+	return e.post(ctx, body)
+}
+
+func (e *Exporter) post(ctx context.Context, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("otlp: encode %s: %w", e.signal, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("otlp: build request: %w", err)
+	}
+	for k, v := range e.headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otlp: post %s: %w", e.signal, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otlp: post %s: %s: %s", e.signal, resp.Status, bytes.TrimSpace(msg))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// resourceAttrs returns service.name followed by the other resource
+// attributes, sorted by key.
+func (e *Exporter) resourceAttrs() []attribute {
+	attrs := []attribute{stringAttr("service.name", e.service)}
+	for _, k := range slices.Sorted(maps.Keys(e.resource)) {
+		if k != "service.name" {
+			attrs = append(attrs, stringAttr(k, e.resource[k]))
+		}
+	}
+	return attrs
+}
+
+// randomID returns n random bytes in hex, for span and trace IDs.
+func randomID(n int) string {
+	id := make([]byte, n)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// operationName is the statement's leading keyword, upper case, or the
+// event's op when it has no query.
+func operationName(ev proxy.Event) string {
+	q := strings.TrimLeftFunc(ev.Query, unicode.IsSpace)
+	if i := strings.IndexFunc(q, unicode.IsSpace); i >= 0 {
+		q = q[:i]
+	}
+	if q == "" {
+		return ev.Op.String()
+	}
+	return strings.ToUpper(q)
+}
+
+// queryAttrs are the attributes of a query's span or log record.
+func queryAttrs(ev proxy.Event) []attribute {
+	attrs := []attribute{
+		stringAttr("db.query.text", ev.Query),
+		stringAttr("db.operation.name", operationName(ev)),
+		intAttr("db.response.returned_rows", ev.RowsAffected),
+		stringAttr("sql_tap.op", ev.Op.String()),
+	}
+	for _, kv := range [...][2]string{
+		{"sql_tap.conn_id", ev.ConnID},
+		{"sql_tap.tx_id", ev.TxID},
+		{"sql_tap.upstream", ev.Upstream},
+	} {
+		if kv[1] != "" {
+			attrs = append(attrs, stringAttr(kv[0], kv[1]))
+		}
+	}
+	return attrs
+}
+
+// newSpan returns ev's client span: a child of the span that issued it, or
+// the root of a new trace when it carries no trace context.
+func newSpan(ev proxy.Event) span {
+	s := span{
+		TraceID:           ev.TraceID,
+		SpanID:            randomID(8),
+		ParentSpanID:      ev.SpanID,
+		Name:              operationName(ev),
+		Kind:              spanKindClient,
+		StartTimeUnixNano: strconv.FormatInt(ev.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(ev.StartTime.Add(ev.Duration).UnixNano(), 10),
+		Attributes:        queryAttrs(ev),
+	}
+	if s.TraceID == "" {
+		s.TraceID = randomID(16)
+	}
+	if ev.Error != "" {
+		s.Status = &status{Code: statusCodeError, Message: ev.Error}
+	}
+	return s
+}
+
+// The OTLP JSON encoding of ExportTraceServiceRequest, limited to the fields
+// sql-tap sets. Trace and span IDs are hex strings and 64-bit integers are
+// decimal strings, as the OTLP/JSON mapping requires.
+
+const (
+	spanKindClient  = 3
+	statusCodeError = 2
+)
+
+type request struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type resource struct {
+	Attributes []attribute `json:"attributes"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type span struct {
+	TraceID           string      `json:"traceId"`
+	SpanID            string      `json:"spanId"`
+	ParentSpanID      string      `json:"parentSpanId,omitempty"`
+	Name              string      `json:"name"`
+	Kind              int         `json:"kind"`
+	StartTimeUnixNano string      `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string      `json:"endTimeUnixNano"`
+	Attributes        []attribute `json:"attributes"`
+	Status            *status     `json:"status,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type attribute struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func stringAttr(key, v string) attribute {
+	return attribute{Key: key, Value: anyValue{StringValue: &v}}
+}
+
+func intAttr(key string, v int64) attribute {
+	s := strconv.FormatInt(v, 10)
+	return attribute{Key: key, Value: anyValue{IntValue: &s}}
+}
+
+func doubleAttr(key string, v float64) attribute {
+	return attribute{Key: key, Value: anyValue{DoubleValue: &v}}
+}
@@ -0,0 +1,322 @@
+package otlp_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/otlp"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestExporter_Export(t *testing.T) {
+	t.Parallel()
+
+	type received struct {
+		path, contentType string
+		body              map[string]any
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		got <- received{path: r.URL.Path, contentType: r.Header.Get("Content-Type"), body: body}
+	}))
+	t.Cleanup(srv.Close)
+
+	e, err := otlp.New(srv.URL, otlp.WithServiceName("orders"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Unix(1700000000, 0)
+	err = e.Export(t.Context(), []proxy.Event{
+		{Op: proxy.OpQuery, Query: "SELECT 1"}, // no trace context: skipped
+		{
+			Op:        proxy.OpExec,
+			Query:     "\n  update users set name = $1",
+			StartTime: start,
+			Duration:  2 * time.Millisecond,
+			Error:     "deadlock detected",
+			TraceID:   "4bf92f3577b34da6a3ce929d0e0e4736",
+			SpanID:    "00f067aa0ba902b7",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-got
+	if r.path != "/v1/traces" || r.contentType != "application/json" {
+		t.Errorf("posted to %s as %s", r.path, r.contentType)
+	}
+	rs := r.body["resourceSpans"].([]any)[0].(map[string]any)
+	service := rs["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	if v := service["value"].(map[string]any)["stringValue"]; v != "orders" {
+		t.Errorf("service.name = %v", v)
+	}
+	spans := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+	span := spans[0].(map[string]any)
+	for key, want := range map[string]any{
+		"traceId":           "4bf92f3577b34da6a3ce929d0e0e4736",
+		"parentSpanId":      "00f067aa0ba902b7",
+		"name":              "UPDATE",
+		"kind":              float64(3),
+		"startTimeUnixNano": "1700000000000000000",
+		"endTimeUnixNano":   "1700000000002000000",
+	} {
+		if span[key] != want {
+			t.Errorf("%s = %v, want %v", key, span[key], want)
+		}
+	}
+	if id, _ := span["spanId"].(string); len(id) != 16 {
+		t.Errorf("spanId = %q, want 16 hex digits", id)
+	}
+	if code := span["status"].(map[string]any)["code"]; code != float64(2) {
+		t.Errorf("status code = %v, want 2", code)
+	}
+}
+
+func TestExporter_ErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	t.Cleanup(srv.Close)
+
+	e, err := otlp.New(srv.URL + "/custom/traces")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Export(t.Context(), []proxy.Event{{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"}})
+	if err == nil {
+		t.Fatal("expected error for 400 response")
+	}
+}
+
+func TestNew_InvalidEndpoint(t *testing.T) {
+	t.Parallel()
+
+	if _, err := otlp.New("localhost:4318"); err == nil {
+		t.Fatal("expected error for endpoint without scheme")
+	}
+}
+
+func TestExporter_Logs(t *testing.T) {
+	t.Parallel()
+
+	type received struct {
+		path, apiKey string
+		body         map[string]any
+	}
+	got := make(chan received, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("decode body: %v", err)
+		}
+		got <- received{path: r.URL.Path, apiKey: r.Header.Get("X-Api-Key"), body: body}
+	}))
+	t.Cleanup(srv.Close)
+
+	e, err := otlp.New(srv.URL, otlp.WithSignal(otlp.SignalLogs), otlp.WithUntraced(),
+		otlp.WithHeaders(map[string]string{"X-Api-Key": "secret"}),
+		otlp.WithResourceAttributes(map[string]string{"deployment.environment": "staging"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = e.Export(t.Context(), []proxy.Event{
+		{Op: proxy.OpConnect}, // not a statement: skipped
+		{Op: proxy.OpQuery, Query: "SELECT 1", StartTime: time.Unix(1700000000, 0), Duration: 1500 * time.Microsecond},
+		{Op: proxy.OpExec, Query: "DELETE FROM carts", Error: "permission denied", TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "00f067aa0ba902b7"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-got
+	if r.path != "/v1/logs" || r.apiKey != "secret" {
+		t.Errorf("posted to %s with key %q", r.path, r.apiKey)
+	}
+	rl := r.body["resourceLogs"].([]any)[0].(map[string]any)
+	if attrs := rl["resource"].(map[string]any)["attributes"].([]any); len(attrs) != 2 {
+		t.Errorf("got resource attributes %v, want service.name and deployment.environment", attrs)
+	}
+	records := rl["scopeLogs"].([]any)[0].(map[string]any)["logRecords"].([]any)
+	if len(records) != 2 {
+		t.Fatalf("got %d log records, want 2", len(records))
+	}
+	untraced, traced := records[0].(map[string]any), records[1].(map[string]any)
+	if untraced["body"].(map[string]any)["stringValue"] != "SELECT 1" || untraced["severityText"] != "INFO" ||
+		untraced["timeUnixNano"] != "1700000000000000000" || untraced["traceId"] != nil {
+		t.Errorf("unexpected untraced record: %v", untraced)
+	}
+	if traced["traceId"] != "4bf92f3577b34da6a3ce929d0e0e4736" || traced["spanId"] != "00f067aa0ba902b7" ||
+		traced["severityNumber"] != float64(17) {
+		t.Errorf("unexpected traced record: %v", traced)
+	}
+}
+
+func TestExporter_UntracedSpans(t *testing.T) {
+	t.Parallel()
+
+	e, err := otlp.New("http://localhost:4318")
+	if err != nil {
+		t.Fatal(err)
+	}
+	untraced := proxy.Event{Op: proxy.OpQuery, Query: "SELECT 1"}
+	if e.Exports(untraced) {
+		t.Error("expected untraced queries to be skipped by default")
+	}
+	if e.URL() != "http://localhost:4318/v1/traces" {
+		t.Errorf("URL() = %q", e.URL())
+	}
+
+	e, err = otlp.New("http://localhost:4318", otlp.WithUntraced())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		ev   proxy.Event
+		want bool
+	}{
+		{untraced, true},
+		{proxy.Event{Op: proxy.OpCommit}, true},
+		{proxy.Event{Op: proxy.OpNotice}, false},
+		{proxy.Event{Op: proxy.OpAdvisory}, false},
+	}
+	for _, tt := range tests {
+		if got := e.Exports(tt.ev); got != tt.want {
+			t.Errorf("Exports(%s) = %v, want %v", tt.ev.Op, got, tt.want)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		signal   otlp.Signal
+		endpoint string
+		wantErr  bool
+	}{
+		{name: "unset", signal: otlp.SignalTraces},
+		{
+			name:     "base endpoint",
+			env:      map[string]string{"OTEL_EXPORTER_OTLP_ENDPOINT": "http://collector:4318/"},
+			signal:   otlp.SignalLogs,
+			endpoint: "http://collector:4318/v1/logs",
+		},
+		{
+			name: "signal endpoint",
+			env: map[string]string{
+				"OTEL_EXPORTER_OTLP_ENDPOINT":        "http://collector:4318",
+				"OTEL_EXPORTER_OTLP_TRACES_ENDPOINT": "https://traces.example.com/ingest",
+			},
+			signal:   otlp.SignalTraces,
+			endpoint: "https://traces.example.com/ingest",
+		},
+		{
+			name:    "grpc",
+			env:     map[string]string{"OTEL_EXPORTER_OTLP_PROTOCOL": "grpc"},
+			signal:  otlp.SignalTraces,
+			wantErr: true,
+		},
+		{
+			name:    "bad header",
+			env:     map[string]string{"OTEL_EXPORTER_OTLP_HEADERS": "api-key"},
+			signal:  otlp.SignalTraces,
+			wantErr: true,
+		},
+		{
+			name:    "bad timeout",
+			env:     map[string]string{"OTEL_EXPORTER_OTLP_TIMEOUT": "5s"},
+			signal:  otlp.SignalTraces,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			endpoint, _, err := otlp.FromEnv(func(k string) string { return tt.env[k] }, tt.signal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if endpoint != tt.endpoint {
+				t.Errorf("endpoint = %q, want %q", endpoint, tt.endpoint)
+			}
+		})
+	}
+}
+
+func TestFromEnv_Options(t *testing.T) {
+	t.Parallel()
+
+	got := make(chan *http.Request, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		got <- r
+	}))
+	t.Cleanup(srv.Close)
+
+	env := map[string]string{
+		"OTEL_EXPORTER_OTLP_ENDPOINT":       srv.URL,
+		"OTEL_EXPORTER_OTLP_HEADERS":        "authorization=Bearer%20abc,x-tenant=a",
+		"OTEL_EXPORTER_OTLP_TRACES_HEADERS": "x-tenant=b",
+		"OTEL_EXPORTER_OTLP_TIMEOUT":        "2000",
+		"OTEL_RESOURCE_ATTRIBUTES":          "service.name=ignored,host.name=db-proxy-1",
+		"OTEL_SERVICE_NAME":                 "orders-db",
+	}
+	endpoint, opts, err := otlp.FromEnv(func(k string) string { return env[k] }, otlp.SignalTraces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := otlp.New(endpoint, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Export(t.Context(), []proxy.Event{{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	r := <-got
+	if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer abc" || r.Header.Get("X-Tenant") != "b" {
+		t.Errorf("posted to %s with headers %v", r.URL.Path, r.Header)
+	}
+	var body struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string `json:"key"`
+					Value struct {
+						StringValue string `json:"stringValue"`
+					} `json:"value"`
+				} `json:"attributes"`
+			} `json:"resource"`
+		} `json:"resourceSpans"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	attrs := make(map[string]string)
+	for _, a := range body.ResourceSpans[0].Resource.Attributes {
+		attrs[a.Key] = a.Value.StringValue
+	}
+	if attrs["service.name"] != "orders-db" || attrs["host.name"] != "db-proxy-1" || len(attrs) != 2 {
+		t.Errorf("resource attributes = %v", attrs)
+	}
+}
@@ -0,0 +1,263 @@
+// Package pglog reads PostgreSQL csvlog files and matches their entries to
+// captured events, linking server-side errors, slow-statement logs, and
+// autovacuum runs to the queries they concern.
+package pglog
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Slack widens an event's time window on both sides when matching, to
+// absorb clock skew between the proxy and the server and the delay before
+// a statement's log line is written.
+const Slack = time.Second
+
+// timeLayout is csvlog's log_time format. The zone is an abbreviation, so
+// entries are read in the local zone's terms; UTC always parses.
+const timeLayout = "2006-01-02 15:04:05.999 MST"
+
+// csvlog columns, positionally; PostgreSQL 13 writes the first 23, newer
+// versions append backend_type, leader_pid, and query_id.
+const (
+	colLogTime     = 0
+	colUser        = 1
+	colDatabase    = 2
+	colPID         = 3
+	colSeverity    = 11
+	colSQLState    = 12
+	colMessage     = 13
+	colDetail      = 14
+	colHint        = 15
+	colContext     = 18
+	colQuery       = 19
+	colBackendType = 23
+
+	minColumns = 23
+)
+
+// Entry is one server log line.
+type Entry struct {
+	Time        time.Time
+	PID         uint32
+	User        string
+	Database    string
+	Severity    string // LOG, ERROR, WARNING, ...
+	SQLState    string
+	Message     string
+	Detail      string
+	Hint        string
+	Context     string
+	Query       string // the statement the entry concerns, when logged
+	BackendType string // e.g. "client backend" or "autovacuum worker"; empty before PostgreSQL 14
+}
+
+// Parse reads csvlog records from r. Lines that are not log records, such
+// as ones with an unreadable timestamp, are skipped. A malformed record,
+// such as the unfinished last line of a file being written, ends the read;
+// Parse returns the entries before it along with the error.
+func Parse(r io.Reader) ([]Entry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	var entries []Entry
+	for {
+		rec, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, fmt.Errorf("pglog: %w", err)
+		}
+		if len(rec) < minColumns {
+			continue
+		}
+		ts, err := time.ParseInLocation(timeLayout, rec[colLogTime], time.Local)
+		if err != nil {
+			continue
+		}
+		pid, _ := strconv.ParseUint(rec[colPID], 10, 32)
+		e := Entry{
+			Time:     ts,
+			PID:      uint32(pid), //nolint:gosec // parsed with bitSize 32
+			User:     rec[colUser],
+			Database: rec[colDatabase],
+			Severity: rec[colSeverity],
+			SQLState: rec[colSQLState],
+			Message:  rec[colMessage],
+			Detail:   rec[colDetail],
+			Hint:     rec[colHint],
+			Context:  rec[colContext],
+			Query:    rec[colQuery],
+		}
+		if len(rec) > colBackendType {
+			e.BackendType = rec[colBackendType]
+		}
+		entries = append(entries, e)
+	}
+}
+
+// Target is the event to find log entries for.
+type Target struct {
+	PID      uint32 // backend process ID; 0 matches autovacuum entries only
+	Start    time.Time
+	Duration time.Duration
+	Query    string
+}
+
+// statementRe finds the statement text in log_statement and
+// log_min_duration_statement messages.
+var statementRe = regexp.MustCompile(`(?s)^(?:duration: [0-9.]+ ms\s+)?(?:statement|(?:execute|parse|bind) [^:]*): (.*)$`)
+
+// autovacuumRe finds the table in an autovacuum or autoanalyze message.
+var autovacuumRe = regexp.MustCompile(`^automatic (?:aggressive )?(?:vacuum|analyze) (?:to prevent wraparound )?of table "([^"]+)"`)
+
+// Match returns the entries that concern t, oldest first: entries from t's
+// backend logged while it ran whose statement, if they name one, is t's
+// query, and autovacuum runs in the same window on a table the query
+// mentions.
+func Match(entries []Entry, t Target) []Entry {
+	from, to := t.Start.Add(-Slack), t.Start.Add(t.Duration+Slack)
+	query := normalize(t.Query)
+	var out []Entry
+	for _, e := range entries {
+		if e.Time.Before(from) || e.Time.After(to) {
+			continue
+		}
+		if m := autovacuumRe.FindStringSubmatch(e.Message); m != nil {
+			if mentionsTable(query, m[1]) {
+				out = append(out, e)
+			}
+			continue
+		}
+		if t.PID == 0 || e.PID != t.PID {
+			continue
+		}
+		if stmt := statement(e); stmt != "" && normalize(stmt) != query {
+			continue
+		}
+		out = append(out, e)
+	}
+	slices.SortStableFunc(out, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+	return out
+}
+
+// statement returns the statement e concerns, or "" if it names none.
+func statement(e Entry) string {
+	if e.Query != "" {
+		return e.Query
+	}
+	if m := statementRe.FindStringSubmatch(e.Message); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// normalize collapses whitespace and drops a trailing semicolon so the
+// logged and captured forms of a statement compare equal.
+func normalize(sql string) string {
+	return strings.TrimSuffix(strings.Join(strings.Fields(sql), " "), ";")
+}
+
+// mentionsTable reports whether query names table, given as
+// "db.schema.table" by autovacuum.
+func mentionsTable(query, table string) bool {
+	name := strings.ToLower(table[strings.LastIndexByte(table, '.')+1:])
+	q := strings.ToLower(query)
+	for i := 0; ; {
+		j := strings.Index(q[i:], name)
+		if j < 0 {
+			return false
+		}
+		start, end := i+j, i+j+len(name)
+		if (start == 0 || !isIdentByte(q[start-1])) && (end == len(q) || !isIdentByte(q[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// file is a parsed log file and the stat it was parsed at.
+type file struct {
+	size    int64
+	modTime time.Time
+	entries []Entry
+}
+
+// Correlator matches events against the log files matching a glob,
+// re-reading a file only when it has changed since the last call.
+type Correlator struct {
+	pattern string
+
+	mu    sync.Mutex
+	files map[string]file
+}
+
+// NewCorrelator returns a Correlator reading the csvlog files matching
+// pattern, e.g. "/var/lib/postgresql/data/log/*.csv".
+func NewCorrelator(pattern string) (*Correlator, error) {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("pglog: pattern %q: %w", pattern, err)
+	}
+	return &Correlator{pattern: pattern, files: make(map[string]file)}, nil
+}
+
+// Match returns the log entries concerning t across the current files.
+func (c *Correlator) Match(t Target) ([]Entry, error) {
+	paths, err := filepath.Glob(c.pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pglog: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make(map[string]file, len(paths))
+	var out []Entry
+	for _, path := range paths {
+		f, err := c.load(path)
+		if err != nil {
+			return nil, err
+		}
+		files[path] = f
+		out = append(out, Match(f.entries, t)...)
+	}
+	c.files = files // forget rotated-away files
+	slices.SortStableFunc(out, func(a, b Entry) int { return a.Time.Compare(b.Time) })
+	return out, nil
+}
+
+// load returns path's entries, from the cache if the file is unchanged.
+func (c *Correlator) load(path string) (file, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return file{}, fmt.Errorf("pglog: %w", err)
+	}
+	if f, ok := c.files[path]; ok && f.size == info.Size() && f.modTime.Equal(info.ModTime()) {
+		return f, nil
+	}
+	r, err := os.Open(path) //nolint:gosec // path comes from the user's own -pg-log glob
+	if err != nil {
+		return file{}, fmt.Errorf("pglog: %w", err)
+	}
+	defer func() { _ = r.Close() }()
+	entries, err := Parse(r)
+	if perr := (*csv.ParseError)(nil); err != nil && !errors.As(err, &perr) {
+		return file{}, err
+	}
+	return file{size: info.Size(), modTime: info.ModTime(), entries: entries}, nil
+}
@@ -0,0 +1,171 @@
+package pglog_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/pglog"
+)
+
+// csvlog lines as PostgreSQL 17 writes them: 26 columns.
+const sample = `2026-10-14 12:00:00.100 UTC,"app","shop",4242,"10.0.0.5:51234",6700a1b2.1092,3,"INSERT",2026-10-14 11:59:58 UTC,3/17,0,ERROR,23505,"duplicate key value violates unique constraint ""users_email_key""","Key (email)=(a@example.com) already exists.",,,,,"INSERT INTO users (email) VALUES ($1)",,,"myapp","client backend",,0
+2026-10-14 12:00:00.200 UTC,"app","shop",4242,"10.0.0.5:51234",6700a1b2.1092,4,"SELECT",2026-10-14 11:59:58 UTC,3/18,0,LOG,00000,"duration: 1502.331 ms  execute <unnamed>: SELECT * FROM orders WHERE user_id = $1",,,,,,,,,"myapp","client backend",,0
+2026-10-14 12:00:00.300 UTC,"app","shop",4242,"10.0.0.5:51234",6700a1b2.1092,5,"SELECT",2026-10-14 11:59:58 UTC,3/19,0,LOG,00000,"duration: 3.100 ms  statement: SELECT 1",,,,,,,,,"myapp","client backend",,0
+2026-10-14 12:00:00.400 UTC,,,5151,,6700a1b3.141f,1,,2026-10-14 12:00:00 UTC,4/2,0,LOG,00000,"automatic vacuum of table ""shop.public.orders"": index scans: 1",,,,,,,,,"","autovacuum worker",,0
+2026-10-14 12:00:00.500 UTC,,,5152,,6700a1b3.1420,1,,2026-10-14 12:00:00 UTC,4/3,0,LOG,00000,"automatic analyze of table ""shop.public.order_items""",,,,,,,,,"","autovacuum worker",,0
+2026-10-14 12:00:09.000 UTC,"app","shop",4242,"10.0.0.5:51234",6700a1b2.1092,6,"idle",2026-10-14 11:59:58 UTC,3/20,0,LOG,00000,"disconnection: session time: 0:00:11.000",,,,,,,,,"myapp","client backend",,0
+`
+
+func at(t *testing.T, s string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	entries, err := pglog.Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 6 {
+		t.Fatalf("got %d entries, want 6", len(entries))
+	}
+	e := entries[0]
+	if !e.Time.Equal(at(t, "2026-10-14T12:00:00.1Z")) || e.PID != 4242 || e.Severity != "ERROR" || e.SQLState != "23505" {
+		t.Errorf("entry = %+v", e)
+	}
+	if e.Message != `duplicate key value violates unique constraint "users_email_key"` ||
+		e.Detail != "Key (email)=(a@example.com) already exists." ||
+		e.Query != "INSERT INTO users (email) VALUES ($1)" || e.BackendType != "client backend" {
+		t.Errorf("entry = %+v", e)
+	}
+}
+
+func TestParse_TruncatedTail(t *testing.T) {
+	t.Parallel()
+
+	entries, err := pglog.Parse(strings.NewReader(sample + `2026-10-14 12:00:10.000 UTC,"app","shop",4242,"10.0.0.5:5`))
+	if err == nil {
+		t.Error("expected an error for the unfinished record")
+	}
+	if len(entries) != 6 {
+		t.Errorf("got %d entries, want the 6 before the unfinished record", len(entries))
+	}
+}
+
+func TestMatch(t *testing.T) {
+	t.Parallel()
+
+	entries, err := pglog.Parse(strings.NewReader(sample))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		target pglog.Target
+		want   []string // messages
+	}{
+		{
+			name: "error by pid and query",
+			target: pglog.Target{
+				PID: 4242, Start: at(t, "2026-10-14T12:00:00.09Z"), Duration: 5 * time.Millisecond,
+				Query: "INSERT INTO users (email)\n  VALUES ($1);",
+			},
+			want: []string{`duplicate key value violates unique constraint "users_email_key"`},
+		},
+		{
+			name: "slow statement and autovacuum on its table",
+			target: pglog.Target{
+				PID: 4242, Start: at(t, "2026-10-14T11:59:58.7Z"), Duration: 1500 * time.Millisecond,
+				Query: "SELECT * FROM orders WHERE user_id = $1",
+			},
+			want: []string{
+				"duration: 1502.331 ms  execute <unnamed>: SELECT * FROM orders WHERE user_id = $1",
+				`automatic vacuum of table "shop.public.orders": index scans: 1`,
+			},
+		},
+		{
+			name: "other backend",
+			target: pglog.Target{
+				PID: 1, Start: at(t, "2026-10-14T12:00:00.09Z"), Duration: 5 * time.Millisecond,
+				Query: "INSERT INTO users (email) VALUES ($1)",
+			},
+		},
+		{
+			name: "outside the window",
+			target: pglog.Target{
+				PID: 4242, Start: at(t, "2026-10-14T12:00:05Z"), Duration: time.Millisecond,
+				Query: "SELECT 1",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got := pglog.Match(entries, tt.target)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries %+v, want %d", len(got), got, len(tt.want))
+			}
+			for i, e := range got {
+				if e.Message != tt.want[i] {
+					t.Errorf("entry %d = %q, want %q", i, e.Message, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestCorrelator(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "postgresql.csv")
+	lines := strings.SplitAfter(sample, "\n")
+	if err := os.WriteFile(path, []byte(lines[0]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := pglog.NewCorrelator(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := pglog.Target{PID: 4242, Start: at(t, "2026-10-14T12:00:00Z"), Duration: time.Millisecond, Query: "SELECT 1"}
+	got, err := c.Match(target)
+	if err != nil || len(got) != 0 {
+		t.Fatalf("Match = %v, %v; want no entries", got, err)
+	}
+
+	// The server appends the statement's log line; the file is read again.
+	if err := os.WriteFile(path, []byte(strings.Join(lines[:3], "")), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "postgresql.1.csv"), []byte(lines[2]), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	got, err = c.Match(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Message != "duration: 3.100 ms  statement: SELECT 1" {
+		t.Errorf("Match = %+v, want the statement's line from both files", got)
+	}
+}
+
+func TestNewCorrelator_BadPattern(t *testing.T) {
+	t.Parallel()
+
+	if _, err := pglog.NewCorrelator("[log"); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
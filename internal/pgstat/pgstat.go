@@ -0,0 +1,185 @@
+// Package pgstat polls pg_stat_statements on each upstream and keys the
+// totals by query fingerprint (see the query package), so they line up with
+// the captured queries' statistics. The server's totals cover everything
+// since its statistics were last reset, including traffic from before the
+// tap started and from clients that bypass it.
+package pgstat
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/query"
+)
+
+// Defaults for the Poller options.
+const (
+	DefaultInterval = 30 * time.Second
+	DefaultLimit    = 500
+	DefaultTimeout  = 10 * time.Second
+)
+
+// Source reads pg_stat_statements; *explain.Client is one.
+type Source interface {
+	Statements(ctx context.Context, limit int) ([]explain.Statement, error)
+}
+
+// Entry is the server-side totals of one fingerprint on one upstream.
+// PostgreSQL normalizes some statements sharing a fingerprint apart, such as
+// IN lists of different lengths; their totals are summed.
+type Entry struct {
+	Upstream    string // "" for the default one
+	Fingerprint string
+	Calls       int64
+	Total       time.Duration
+	Rows        int64
+}
+
+// Mean returns the average execution time of a call.
+func (e Entry) Mean() time.Duration {
+	if e.Calls == 0 {
+		return 0
+	}
+	return e.Total / time.Duration(e.Calls)
+}
+
+// Snapshot is the result of the latest poll.
+type Snapshot struct {
+	Entries []Entry           // highest total time first
+	At      time.Time         // when the poll finished; zero before the first one
+	Errors  map[string]string // the last poll's error per failing upstream
+}
+
+// Option configures a Poller.
+type Option func(*Poller)
+
+// WithInterval sets how often pg_stat_statements is read.
+func WithInterval(d time.Duration) Option {
+	return func(p *Poller) {
+		p.interval = d
+	}
+}
+
+// WithLimit sets how many statements are read from each upstream per poll.
+func WithLimit(n int) Option {
+	return func(p *Poller) {
+		p.limit = n
+	}
+}
+
+// Poller reads pg_stat_statements from its sources in the background. It is
+// safe for concurrent use.
+type Poller struct {
+	sources  map[string]Source
+	interval time.Duration
+	limit    int
+
+	mu   sync.Mutex
+	snap Snapshot
+}
+
+// New returns a Poller of sources, keyed by upstream name ("" for the default
+// one), with the default settings adjusted by opts.
+func New(sources map[string]Source, opts ...Option) *Poller {
+	p := &Poller{
+		sources:  sources,
+		interval: DefaultInterval,
+		limit:    DefaultLimit,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Interval returns how often pg_stat_statements is read.
+func (p *Poller) Interval() time.Duration {
+	return p.interval
+}
+
+// Run polls immediately and then every interval until ctx is done.
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll reads every source once and replaces the snapshot. A source that
+// fails keeps its entries from the previous poll, and its error is reported
+// in the snapshot until it succeeds again.
+func (p *Poller) Poll(ctx context.Context) {
+	p.mu.Lock()
+	prev := p.snap
+	p.mu.Unlock()
+
+	next := Snapshot{Errors: make(map[string]string)}
+	for upstream, src := range p.sources {
+		entries, err := p.read(ctx, upstream, src)
+		if err != nil {
+			if _, failing := prev.Errors[upstream]; !failing {
+				slog.Warn("pg_stat_statements poll failed", "upstream", upstream, "err", err)
+			}
+			next.Errors[upstream] = err.Error()
+			for _, e := range prev.Entries {
+				if e.Upstream == upstream {
+					next.Entries = append(next.Entries, e)
+				}
+			}
+			continue
+		}
+		next.Entries = append(next.Entries, entries...)
+	}
+	slices.SortFunc(next.Entries, func(a, b Entry) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Upstream, b.Upstream), cmp.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	next.At = time.Now()
+
+	p.mu.Lock()
+	p.snap = next
+	p.mu.Unlock()
+}
+
+func (p *Poller) read(ctx context.Context, upstream string, src Source) ([]Entry, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	sts, err := src.Statements(ctx, p.limit)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // logged and reported per upstream
+	}
+
+	index := make(map[string]int) // fingerprint to its position in out
+	var out []Entry
+	for _, st := range sts {
+		fp := query.Fingerprint(st.Query)
+		i, ok := index[fp]
+		if !ok {
+			i = len(out)
+			index[fp] = i
+			out = append(out, Entry{Upstream: upstream, Fingerprint: fp})
+		}
+		e := &out[i]
+		e.Calls += st.Calls
+		e.Total += st.Total
+		e.Rows += st.Rows
+	}
+	return out, nil
+}
+
+// Snapshot returns the result of the latest poll.
+func (p *Poller) Snapshot() Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.snap
+}
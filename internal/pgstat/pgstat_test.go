@@ -0,0 +1,101 @@
+package pgstat_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/explain"
+	"github.com/mickamy/sql-tap/internal/pgstat"
+)
+
+type fakeSource struct {
+	mu    sync.Mutex
+	sts   []explain.Statement
+	err   error
+	limit int
+}
+
+func (f *fakeSource) Statements(_ context.Context, limit int) ([]explain.Statement, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.limit = limit
+	return f.sts, f.err
+}
+
+func TestPoller(t *testing.T) {
+	t.Parallel()
+
+	primary := &fakeSource{sts: []explain.Statement{
+		{Query: "SELECT * FROM orders WHERE id IN ($1, $2)", Calls: 10, Total: 20 * time.Millisecond, Rows: 20},
+		{Query: "SELECT * FROM orders WHERE id IN ($1, $2, $3)", Calls: 5, Total: 10 * time.Millisecond, Rows: 15},
+		{Query: "UPDATE orders SET total = $1 WHERE id = $2", Calls: 2, Total: 50 * time.Millisecond, Rows: 2},
+	}}
+	replica := &fakeSource{sts: []explain.Statement{
+		{Query: "SELECT count(*) FROM users", Calls: 1, Total: time.Millisecond, Rows: 1},
+	}}
+	p := pgstat.New(map[string]pgstat.Source{"": primary, "replica": replica}, pgstat.WithLimit(50))
+	if !p.Snapshot().At.IsZero() {
+		t.Fatal("expected no poll yet")
+	}
+
+	p.Poll(t.Context())
+	snap := p.Snapshot()
+	if primary.limit != 50 || len(snap.Errors) != 0 || snap.At.IsZero() {
+		t.Fatalf("limit %d, errors %v, at %v", primary.limit, snap.Errors, snap.At)
+	}
+	want := []pgstat.Entry{
+		{Fingerprint: "UPDATE orders SET total = ? WHERE id = ?", Calls: 2, Total: 50 * time.Millisecond, Rows: 2},
+		{Fingerprint: "SELECT * FROM orders WHERE id IN (?)", Calls: 15, Total: 30 * time.Millisecond, Rows: 35},
+		{Upstream: "replica", Fingerprint: "SELECT count(*) FROM users", Calls: 1, Total: time.Millisecond, Rows: 1},
+	}
+	if len(snap.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d: %+v", len(snap.Entries), len(want), snap.Entries)
+	}
+	for i, e := range snap.Entries {
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+	if got := snap.Entries[1].Mean(); got != 2*time.Millisecond {
+		t.Errorf("mean = %v, want 2ms", got)
+	}
+
+	// A failing upstream keeps its previous entries and reports the error.
+	replica.mu.Lock()
+	replica.err = errors.New(`relation "pg_stat_statements" does not exist`)
+	replica.mu.Unlock()
+	p.Poll(t.Context())
+	snap = p.Snapshot()
+	if len(snap.Entries) != 3 || snap.Errors["replica"] == "" {
+		t.Fatalf("entries %+v, errors %v", snap.Entries, snap.Errors)
+	}
+}
+
+func TestPoller_Run(t *testing.T) {
+	t.Parallel()
+
+	src := &fakeSource{sts: []explain.Statement{{Query: "SELECT 1", Calls: 1}}}
+	p := pgstat.New(map[string]pgstat.Source{"": src}, pgstat.WithInterval(time.Hour))
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for p.Snapshot().At.IsZero() {
+		if time.Now().After(deadline) {
+			t.Fatal("Run did not poll immediately")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+	if got := p.Snapshot().Entries; len(got) != 1 || got[0].Fingerprint != "SELECT ?" {
+		t.Fatalf("unexpected entries: %+v", got)
+	}
+}
@@ -0,0 +1,155 @@
+// Package policy decides which statements the proxies let through, from
+// allow and deny rules in the config file.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// DefaultRule names the violation of statements no rule matched, under a
+// deny default.
+const DefaultRule = "default"
+
+type rule struct {
+	name        string
+	deny        bool
+	query       *regexp.Regexp
+	fingerprint string
+	user        string
+	database    string
+}
+
+// match reports whether ev meets all of the rule's conditions; fp returns
+// the fingerprint of ev.Query.
+func (r rule) match(ev proxy.Event, fp func() string) bool {
+	if r.user != "" && r.user != ev.User {
+		return false
+	}
+	if r.database != "" && r.database != ev.Database {
+		return false
+	}
+	if r.query != nil && !r.query.MatchString(ev.Query) {
+		return false
+	}
+	if r.fingerprint != "" && !strings.EqualFold(r.fingerprint, fp()) {
+		return false
+	}
+	return true
+}
+
+// Policy implements proxy.Policy over config-defined rules. The first rule
+// a statement matches decides whether it may run; statements no rule
+// matches get the default.
+type Policy struct {
+	rules  []rule
+	deny   bool // the default
+	dryRun bool
+}
+
+var _ proxy.Policy = (*Policy)(nil)
+
+// New compiles cfg.
+func New(cfg config.Policy) (*Policy, error) {
+	p := &Policy{deny: cfg.Default == "deny", dryRun: cfg.DryRun}
+	for i, r := range cfg.Rules {
+		cr := rule{
+			name:     r.Name,
+			deny:     r.Action == "deny",
+			user:     r.User,
+			database: r.Database,
+		}
+		if r.Query != "" {
+			re, err := regexp.Compile(r.Query)
+			if err != nil {
+				return nil, fmt.Errorf("policy: rule %d (%s): %w", i, r.Name, err)
+			}
+			cr.query = re
+		}
+		if r.Fingerprint != "" {
+			cr.fingerprint = query.Fingerprint(r.Fingerprint)
+		}
+		p.rules = append(p.rules, cr)
+	}
+	return p, nil
+}
+
+// Rules returns how many rules p has.
+func (p *Policy) Rules() int {
+	return len(p.rules)
+}
+
+// DryRun reports whether p only reports violations.
+func (p *Policy) DryRun() bool {
+	return p.dryRun
+}
+
+// Check returns the violation of the first rule ev matches, or of the
+// default, if that denies it. Text of several statements is checked one
+// statement at a time, and denied if any one is; rules see each statement
+// with its comments removed.
+func (p *Policy) Check(ev proxy.Event) *proxy.Violation {
+	stmts := statements(ev.Query)
+	if len(stmts) == 0 {
+		return p.check(ev)
+	}
+	for _, stmt := range stmts {
+		ev.Query = stmt
+		if v := p.check(ev); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// statements returns the statements in sql as PostgreSQL reads them and
+// as MySQL does. Rules do not know which server they guard, and the two
+// disagree on quoting and comments, so text either would split is checked
+// both ways.
+func statements(sql string) []string {
+	stmts := query.Statements(sql, query.Postgres)
+	for _, stmt := range query.Statements(sql, query.MySQL) {
+		if !slices.Contains(stmts, stmt) {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
+
+// check returns the violation of the first rule the single statement ev
+// matches, or of the default.
+func (p *Policy) check(ev proxy.Event) *proxy.Violation {
+	var fp string
+	fingerprint := func() string {
+		if fp == "" {
+			fp = query.Fingerprint(ev.Query)
+		}
+		return fp
+	}
+	for _, r := range p.rules {
+		if r.match(ev, fingerprint) {
+			if !r.deny {
+				return nil
+			}
+			return p.violation(r.name)
+		}
+	}
+	if p.deny {
+		return p.violation(DefaultRule)
+	}
+	return nil
+}
+
+func (p *Policy) violation(rule string) *proxy.Violation {
+	return &proxy.Violation{
+		Rule:    rule,
+		Message: fmt.Sprintf("sql-tap: statement blocked by policy rule %q", rule),
+		Blocked: !p.dryRun,
+	}
+}
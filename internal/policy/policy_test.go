@@ -0,0 +1,95 @@
+package policy_test
+
+import (
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/policy"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	t.Parallel()
+
+	p, err := policy.New(config.Policy{Rules: []config.PolicyRule{
+		{Name: "migrator", Action: "allow", User: "migrate"},
+		{Name: "no-drop", Action: "deny", Query: `(?i)^\s*drop\s`},
+		{Name: "no-user-wipe", Action: "deny", Fingerprint: "delete from users"},
+		{Name: "read-only-reports", Action: "deny", Database: "reports", Query: `(?i)^\s*(insert|update|delete)\s`},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ev   proxy.Event
+		want string // rule; empty for no violation
+	}{
+		{name: "no match", ev: proxy.Event{Query: "SELECT 1"}},
+		{name: "query regexp", ev: proxy.Event{Query: "DROP TABLE users"}, want: "no-drop"},
+		{name: "earlier allow wins", ev: proxy.Event{Query: "DROP TABLE users", User: "migrate"}},
+		{name: "fingerprint", ev: proxy.Event{Query: "DELETE  FROM users /* cleanup */"}, want: "no-user-wipe"},
+		{name: "fingerprint differs", ev: proxy.Event{Query: "DELETE FROM users WHERE id = 1"}},
+		{name: "database", ev: proxy.Event{Query: "UPDATE daily SET n = 1", Database: "reports"}, want: "read-only-reports"},
+		{name: "other database", ev: proxy.Event{Query: "UPDATE daily SET n = 1", Database: "app"}},
+		{name: "after another statement", ev: proxy.Event{Query: "SELECT 1; DROP TABLE users"}, want: "no-drop"},
+		{name: "after a comment", ev: proxy.Event{Query: "/* x */ DROP TABLE users"}, want: "no-drop"},
+		{name: "after a line comment", ev: proxy.Event{Query: "-- x\nDROP TABLE users"}, want: "no-drop"},
+		{name: "mysql executable comment", ev: proxy.Event{Query: "SELECT 1; /*!50000 DROP TABLE users */"}, want: "no-drop"},
+		{name: "hidden from one dialect", ev: proxy.Event{Query: `SELECT 'a\''; DROP TABLE users; -- '`}, want: "no-drop"},
+		{name: "semicolon in a literal", ev: proxy.Event{Query: "SELECT '; DROP TABLE users'"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			v := p.Check(tt.ev)
+			switch {
+			case tt.want == "" && v != nil:
+				t.Errorf("Check = %+v, want no violation", v)
+			case tt.want != "" && v == nil:
+				t.Errorf("Check = nil, want rule %s", tt.want)
+			case tt.want != "" && (v.Rule != tt.want || !v.Blocked || v.Message == ""):
+				t.Errorf("Check = %+v, want a blocking violation of %s", v, tt.want)
+			}
+		})
+	}
+}
+
+func TestPolicy_Default(t *testing.T) {
+	t.Parallel()
+
+	p, err := policy.New(config.Policy{
+		Default: "deny",
+		DryRun:  true,
+		Rules:   []config.PolicyRule{{Name: "reads", Action: "allow", Query: `(?i)^select\b`}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := p.Check(proxy.Event{Query: "SELECT 1"}); v != nil {
+		t.Errorf("allowed query: Check = %+v, want nil", v)
+	}
+	v := p.Check(proxy.Event{Query: "UPDATE t SET x = 1"})
+	if v == nil || v.Rule != policy.DefaultRule || v.Blocked {
+		t.Errorf("Check = %+v, want an unblocked violation of the default", v)
+	}
+	if v := p.Check(proxy.Event{Query: "SELECT 1; SELECT 2;"}); v != nil {
+		t.Errorf("allowed statements: Check = %+v, want nil", v)
+	}
+	for _, q := range []string{"SELECT 1; UPDATE t SET x = 1", "SELECT 1; /* x */ UPDATE t SET x = 1"} {
+		if v := p.Check(proxy.Event{Query: q}); v == nil || v.Rule != policy.DefaultRule {
+			t.Errorf("Check(%q) = %+v, want a violation of the default", q, v)
+		}
+	}
+}
+
+func TestPolicy_InvalidRegexp(t *testing.T) {
+	t.Parallel()
+
+	if _, err := policy.New(config.Policy{Rules: []config.PolicyRule{{Name: "bad", Action: "deny", Query: "("}}}); err == nil {
+		t.Fatal("expected error for invalid regexp")
+	}
+}
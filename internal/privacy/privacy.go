@@ -0,0 +1,203 @@
+// Package privacy keeps captured values from leaving the daemon. Bind
+// arguments and sampled result rows are either replaced outright or
+// generalized: numbers become their order of magnitude, email addresses
+// their domain, and other strings their length, so the shape of the traffic
+// (which queries see large IDs, empty strings, or one customer's domain)
+// stays visible while the values themselves do not.
+package privacy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Mode selects what happens to captured values.
+type Mode int
+
+const (
+	Raw        Mode = iota // values are kept as captured
+	Generalize             // values are replaced by Value
+	Redact                 // values are replaced by Redacted
+)
+
+// Redacted replaces every value in Redact mode.
+const Redacted = "[redacted]"
+
+// ParseMode parses "raw", "generalize", or "redact"; "" is Raw.
+func ParseMode(s string) (Mode, error) {
+	switch strings.ToLower(s) {
+	case "", "raw":
+		return Raw, nil
+	case "generalize":
+		return Generalize, nil
+	case "redact":
+		return Redact, nil
+	}
+	return Raw, fmt.Errorf("privacy: unknown mode %q (want raw, generalize, or redact)", s)
+}
+
+func (m Mode) String() string {
+	switch m {
+	case Raw:
+		return "raw"
+	case Generalize:
+		return "generalize"
+	case Redact:
+		return "redact"
+	}
+	return "Mode(" + strconv.Itoa(int(m)) + ")"
+}
+
+// Apply replaces ev's bind arguments and sampled rows as m says. NULLs are
+// kept in every mode.
+func (m Mode) Apply(ev *proxy.Event) {
+	if m == Raw {
+		return
+	}
+	ev.Args = m.values(ev.Args)
+	if ev.RowSamples != nil {
+		rows := make([][]string, len(ev.RowSamples))
+		for i, row := range ev.RowSamples {
+			rows[i] = m.values(row)
+		}
+		ev.RowSamples = rows
+	}
+}
+
+// ApplyFields replaces the named fields of ev as m says, for fields
+// extracted from its bind arguments.
+func (m Mode) ApplyFields(ev *proxy.Event, names []string) {
+	if m == Raw || len(names) == 0 || ev.Fields == nil {
+		return
+	}
+	fields := maps.Clone(ev.Fields)
+	for _, name := range names {
+		if v, ok := fields[name]; ok {
+			fields[name] = m.values([]string{v})[0]
+		}
+	}
+	ev.Fields = fields
+}
+
+// Pseudonyms replaces values with keyed hashes: equal values get the same
+// pseudonym and different ones, in practice, different pseudonyms, so
+// statistics can still tell values apart. The key is random and never
+// leaves the process, so pseudonyms cannot be reversed by hashing guesses,
+// and they change when the daemon restarts.
+type Pseudonyms struct {
+	key []byte
+}
+
+// NewPseudonyms returns Pseudonyms with a new random key.
+func NewPseudonyms() *Pseudonyms {
+	key := make([]byte, sha256.Size)
+	_, _ = rand.Read(key) // never fails
+	return &Pseudonyms{key: key}
+}
+
+// Value returns the pseudonym of v, e.g. "~3fa2c1d04b7e9a61". NULL is kept.
+func (p *Pseudonyms) Value(v string) string {
+	if v == "NULL" {
+		return v
+	}
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(v))
+	return "~" + hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// ApplyFields replaces the named fields of ev with their pseudonyms. Like
+// Mode.ApplyFields, it leaves the fields map it was given unchanged.
+func (p *Pseudonyms) ApplyFields(ev *proxy.Event, names []string) {
+	if len(names) == 0 || ev.Fields == nil {
+		return
+	}
+	fields := maps.Clone(ev.Fields)
+	for _, name := range names {
+		if v, ok := fields[name]; ok {
+			fields[name] = p.Value(v)
+		}
+	}
+	ev.Fields = fields
+}
+
+// values returns a copy of vs with each value replaced; the proxies may
+// still hold the original slice.
+func (m Mode) values(vs []string) []string {
+	if vs == nil {
+		return nil
+	}
+	out := make([]string, len(vs))
+	for i, v := range vs {
+		switch {
+		case v == "NULL":
+			out[i] = v
+		case m == Redact:
+			out[i] = Redacted
+		default:
+			out[i] = Value(v)
+		}
+	}
+	return out
+}
+
+var (
+	numberRe = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+	emailRe  = regexp.MustCompile(`^[^@\s]+@([^@\s]+\.[^@\s]+)$`)
+)
+
+// Value generalizes one captured value:
+//
+//   - a number becomes the power-of-ten range it falls in, e.g. 4217 is
+//     "[1000,10000)" and -3.5 is "(-10,-1]"; 0 stays 0
+//   - an email address keeps only its domain, e.g. "*@example.com"
+//   - true and false are kept
+//   - any other string becomes its length, e.g. "[12 chars]"
+func Value(v string) string {
+	switch {
+	case numberRe.MatchString(v):
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return magnitude(f)
+		}
+	case strings.EqualFold(v, "true") || strings.EqualFold(v, "false"):
+		return v
+	}
+	if m := emailRe.FindStringSubmatch(v); m != nil {
+		return "*@" + strings.ToLower(m[1])
+	}
+	return fmt.Sprintf("[%d chars]", utf8.RuneCountInString(v))
+}
+
+func magnitude(f float64) string {
+	if f == 0 {
+		return "0"
+	}
+	a := f
+	if a < 0 {
+		a = -a
+	}
+	if a < 1 {
+		if f < 0 {
+			return "(-1,0)"
+		}
+		return "(0,1)"
+	}
+	lo := 1.0
+	for lo*10 <= a {
+		lo *= 10
+	}
+	l, h := strconv.FormatFloat(lo, 'f', -1, 64), strconv.FormatFloat(lo*10, 'f', -1, 64)
+	if f < 0 {
+		return "(-" + h + ",-" + l + "]"
+	}
+	return "[" + l + "," + h + ")"
+}
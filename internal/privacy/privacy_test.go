@@ -0,0 +1,152 @@
+package privacy_test
+
+import (
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/privacy"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestValue(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "0", want: "0"},
+		{in: "7", want: "[1,10)"},
+		{in: "10", want: "[10,100)"},
+		{in: "4217", want: "[1000,10000)"},
+		{in: "999", want: "[100,1000)"},
+		{in: "-3.5", want: "(-10,-1]"},
+		{in: "0.25", want: "(0,1)"},
+		{in: "1e6", want: "[1000000,10000000)"},
+		{in: "alice@Example.com", want: "*@example.com"},
+		{in: "true", want: "true"},
+		{in: "FALSE", want: "FALSE"},
+		{in: "hello world!", want: "[12 chars]"},
+		{in: "", want: "[0 chars]"},
+		{in: "héllo", want: "[5 chars]"},
+		{in: "0x1f", want: "[4 chars]"},
+		{in: "NaN", want: "[3 chars]"},
+		{in: "a@b", want: "[3 chars]"},
+		{in: "550e8400-e29b-41d4-a716-446655440000", want: "[36 chars]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+
+			if got := privacy.Value(tt.in); got != tt.want {
+				t.Errorf("Value(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMode_Apply(t *testing.T) {
+	t.Parallel()
+
+	event := func() proxy.Event {
+		return proxy.Event{
+			Op:         proxy.OpExecute,
+			Args:       []string{"42", "bob@example.org", "NULL"},
+			RowSamples: [][]string{{"1", "secret"}},
+		}
+	}
+	tests := []struct {
+		mode privacy.Mode
+		args []string
+		row  []string
+	}{
+		{mode: privacy.Raw, args: []string{"42", "bob@example.org", "NULL"}, row: []string{"1", "secret"}},
+		{mode: privacy.Generalize, args: []string{"[10,100)", "*@example.org", "NULL"}, row: []string{"[1,10)", "[6 chars]"}},
+		{mode: privacy.Redact, args: []string{"[redacted]", "[redacted]", "NULL"}, row: []string{"[redacted]", "[redacted]"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			t.Parallel()
+
+			ev := event()
+			orig := ev.Args
+			tt.mode.Apply(&ev)
+			if !slices.Equal(ev.Args, tt.args) {
+				t.Errorf("args = %q, want %q", ev.Args, tt.args)
+			}
+			if !slices.Equal(ev.RowSamples[0], tt.row) {
+				t.Errorf("row = %q, want %q", ev.RowSamples[0], tt.row)
+			}
+			if orig[0] != "42" {
+				t.Errorf("the captured slice was modified: %q", orig)
+			}
+		})
+	}
+}
+
+func TestMode_ApplyFields(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mode privacy.Mode
+		want map[string]string
+	}{
+		{mode: privacy.Raw, want: map[string]string{"tenant_id": "4217", "region": "eu"}},
+		{mode: privacy.Generalize, want: map[string]string{"tenant_id": "[1000,10000)", "region": "eu"}},
+		{mode: privacy.Redact, want: map[string]string{"tenant_id": "[redacted]", "region": "eu"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode.String(), func(t *testing.T) {
+			t.Parallel()
+
+			orig := map[string]string{"tenant_id": "4217", "region": "eu"}
+			ev := proxy.Event{Fields: orig}
+			tt.mode.ApplyFields(&ev, []string{"tenant_id", "missing"})
+			if !maps.Equal(ev.Fields, tt.want) {
+				t.Errorf("Fields = %v, want %v", ev.Fields, tt.want)
+			}
+			if orig["tenant_id"] != "4217" {
+				t.Errorf("the extracted map was modified: %v", orig)
+			}
+		})
+	}
+}
+
+func TestPseudonyms(t *testing.T) {
+	t.Parallel()
+
+	p := privacy.NewPseudonyms()
+	orig := map[string]string{"tenant_id": "acme", "region": "eu"}
+	ev := proxy.Event{Fields: orig}
+	p.ApplyFields(&ev, []string{"tenant_id", "missing"})
+	got := ev.Fields["tenant_id"]
+	if got == "acme" || got != p.Value("acme") || ev.Fields["region"] != "eu" {
+		t.Errorf("Fields = %v, want tenant_id as its pseudonym and region kept", ev.Fields)
+	}
+	if orig["tenant_id"] != "acme" {
+		t.Errorf("the extracted map was modified: %v", orig)
+	}
+	if p.Value("globex") == got {
+		t.Errorf("acme and globex share the pseudonym %q", got)
+	}
+	if q := privacy.NewPseudonyms(); q.Value("acme") == got {
+		t.Errorf("two keys give acme the same pseudonym %q", got)
+	}
+	if v := p.Value("NULL"); v != "NULL" {
+		t.Errorf("Value(NULL) = %q, want NULL", v)
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	t.Parallel()
+
+	for in, want := range map[string]privacy.Mode{"": privacy.Raw, "raw": privacy.Raw, "Generalize": privacy.Generalize, "redact": privacy.Redact} {
+		if got, err := privacy.ParseMode(in); err != nil || got != want {
+			t.Errorf("ParseMode(%q) = %v, %v; want %v", in, got, err, want)
+		}
+	}
+	if _, err := privacy.ParseMode("hash"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,107 @@
+package query
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Fingerprint normalizes sql so that queries differing only in literal values
+// share a fingerprint: string and numeric literals and placeholders become ?,
+// lists of them collapse to a single ?, comments are dropped, and runs of
+// whitespace become one space.
+func Fingerprint(sql string) string {
+	out := make([]byte, 0, len(sql))
+	space := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+			continue
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if j := strings.IndexByte(sql[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(sql)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if j := strings.Index(sql[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(sql)
+			}
+			space = true
+			continue
+		}
+
+		if space && len(out) > 0 {
+			out = append(out, ' ')
+		}
+		space = false
+		switch {
+		case c == '\'':
+			i = skipString(sql, i)
+			out = appendParam(out)
+		case c == '$' && i+1 < len(sql) && isDigit(sql[i+1]):
+			i++
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+			out = appendParam(out)
+		case c == '?':
+			i++
+			out = appendParam(out)
+		case isDigit(c) && !endsWithIdent(out):
+			for i < len(sql) && (isDigit(sql[i]) || sql[i] == '.') {
+				i++
+			}
+			out = appendParam(out)
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return string(out)
+}
+
+// skipString returns the index just past the single-quoted literal starting
+// at i, treating a doubled quote as an escaped one.
+func skipString(sql string, i int) int {
+	for i++; i < len(sql); i++ {
+		if sql[i] != '\'' {
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == '\'' {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(sql)
+}
+
+// appendParam appends ?, or drops the separator when it would extend a list
+// of them: "IN (?, ?, ?)" becomes "IN (?)".
+func appendParam(out []byte) []byte {
+	trimmed := bytes.TrimRight(out, " ")
+	if rest, ok := bytes.CutSuffix(trimmed, []byte(",")); ok {
+		if rest = bytes.TrimRight(rest, " "); bytes.HasSuffix(rest, []byte("?")) {
+			return rest
+		}
+	}
+	return append(out, '?')
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// endsWithIdent reports whether out ends inside an identifier, so digits that
+// follow (as in t1) are kept.
+func endsWithIdent(out []byte) bool {
+	if len(out) == 0 {
+		return false
+	}
+	c := out[len(out)-1]
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c)
+}
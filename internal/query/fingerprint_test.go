@@ -0,0 +1,38 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/query"
+)
+
+func TestFingerprint(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{name: "no literals", sql: "SELECT * FROM users", want: "SELECT * FROM users"},
+		{name: "numbers", sql: "SELECT * FROM users WHERE id = 42 AND score > 1.5", want: "SELECT * FROM users WHERE id = ? AND score > ?"},
+		{name: "strings", sql: "SELECT * FROM users WHERE name = 'O''Brien'", want: "SELECT * FROM users WHERE name = ?"},
+		{name: "postgres placeholders", sql: "SELECT * FROM users WHERE id = $1 AND org = $12", want: "SELECT * FROM users WHERE id = ? AND org = ?"},
+		{name: "mysql placeholders", sql: "SELECT * FROM users WHERE id = ?", want: "SELECT * FROM users WHERE id = ?"},
+		{name: "in list", sql: "SELECT * FROM users WHERE id IN (1, 2, 3)", want: "SELECT * FROM users WHERE id IN (?)"},
+		{name: "values tuples", sql: "INSERT INTO t (a, b) VALUES ($1, $2), ($3, $4)", want: "INSERT INTO t (a, b) VALUES (?), (?)"},
+		{name: "identifiers with digits", sql: "SELECT col1 FROM t2", want: "SELECT col1 FROM t2"},
+		{name: "whitespace", sql: "SELECT\n\t*\n  FROM   users ", want: "SELECT * FROM users"},
+		{name: "comments", sql: "SELECT 1 -- note\nFROM t /*traceparent='00-abc-def-01'*/", want: "SELECT ? FROM t"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := query.Fingerprint(tt.sql); got != tt.want {
+				t.Errorf("Fingerprint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
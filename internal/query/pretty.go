@@ -0,0 +1,256 @@
+package query
+
+import "strings"
+
+// Pretty reformats a single-line statement, as ORMs send them, for reading:
+// each clause starts a line, select list items and the conditions joined by
+// AND or OR in WHERE, HAVING, and ON get a line each, and subqueries are
+// indented. Only whitespace changes. Statements that already span lines are
+// returned as they are, since their author laid them out.
+func Pretty(sql string) string {
+	sql = strings.TrimSpace(sql)
+	if strings.ContainsAny(sql, "\n\r") {
+		return sql
+	}
+	p := printer{toks: tokenize(sql)}
+	p.frames = []frame{{block: true}}
+	p.print()
+	return p.out.String()
+}
+
+// token is a lexical unit of a statement. space records whether whitespace
+// preceded it, which emit keeps as one space unless the token starts a line.
+type token struct {
+	text  string
+	word  bool // keyword or identifier
+	space bool
+}
+
+// tokenize splits sql into words, literals, comments, and single-character
+// punctuation, keeping each token's text as written.
+func tokenize(sql string) []token {
+	var toks []token
+	space := false
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t':
+			space = true
+			i++
+			continue
+		case c == '\'':
+			i = skipString(sql, i)
+		case c == '"' || c == '`':
+			if j := strings.IndexByte(sql[i+1:], c); j >= 0 {
+				i += j + 2
+			} else {
+				i = len(sql)
+			}
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			i = len(sql)
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if j := strings.Index(sql[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(sql)
+			}
+		case isWordByte(c):
+			for i < len(sql) && isWordByte(sql[i]) {
+				i++
+			}
+			toks = append(toks, token{text: sql[start:i], word: true, space: space})
+			space = false
+			continue
+		default:
+			i++
+		}
+		toks = append(toks, token{text: sql[start:i], space: space})
+		space = false
+	}
+	return toks
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c == '$' || c == '.' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c >= 0x80
+}
+
+// frame is an open parenthesis, or the statement itself at the bottom of the
+// stack. Only blocks, the statement and its subqueries, get clause breaks.
+type frame struct {
+	block   bool
+	clause  string // the block's current clause keyword, upper case
+	between bool   // inside BETWEEN ... AND, whose AND is not a condition
+}
+
+type printer struct {
+	toks   []token
+	frames []frame
+	out    strings.Builder
+	fresh  bool // at the start of a line, after its indent
+}
+
+// clauses start a line. leads are words that start a clause when followed
+// by one of its words, as ORDER does in ORDER BY, and follows are the words
+// that may continue a clause keyword.
+var (
+	clauses = map[string]bool{
+		"SELECT": true, "FROM": true, "WHERE": true, "HAVING": true, "LIMIT": true, "OFFSET": true,
+		"VALUES": true, "SET": true, "RETURNING": true, "UNION": true, "INTERSECT": true, "EXCEPT": true,
+		"WINDOW": true, "JOIN": true,
+	}
+	leads = map[string][]string{
+		"GROUP":   {"BY"},
+		"ORDER":   {"BY"},
+		"ON":      {"CONFLICT"},
+		"FOR":     {"UPDATE", "SHARE", "NO"},
+		"LEFT":    {"JOIN", "OUTER"},
+		"RIGHT":   {"JOIN", "OUTER"},
+		"FULL":    {"JOIN", "OUTER"},
+		"INNER":   {"JOIN"},
+		"CROSS":   {"JOIN"},
+		"NATURAL": {"JOIN", "LEFT", "RIGHT", "FULL", "INNER"},
+	}
+	follows = map[string]bool{
+		"BY": true, "CONFLICT": true, "UPDATE": true, "SHARE": true, "NO": true, "KEY": true, "ALL": true,
+		"JOIN": true, "OUTER": true, "LEFT": true, "RIGHT": true, "FULL": true, "INNER": true,
+	}
+)
+
+func (p *printer) print() {
+	keyword := 0 // tokens before this are part of the clause keyword just started
+	for i, t := range p.toks {
+		top := &p.frames[len(p.frames)-1]
+		upper := strings.ToUpper(t.text)
+		switch {
+		case t.text == "(":
+			p.emit(t)
+			sub := p.startsQuery(i + 1)
+			p.frames = append(p.frames, frame{block: sub})
+			if sub {
+				p.newline(0)
+			}
+			continue
+		case t.text == ")" && len(p.frames) > 1:
+			closing := p.frames[len(p.frames)-1]
+			p.frames = p.frames[:len(p.frames)-1]
+			if closing.block {
+				p.newline(0)
+			}
+			p.emit(t)
+			continue
+		case !top.block || i < keyword:
+		case t.word && p.clauseLen(i) > 0:
+			n := p.clauseLen(i)
+			keyword = i + n
+			p.newline(0)
+			words := make([]string, n)
+			for j := range words {
+				words[j] = strings.ToUpper(p.toks[i+j].text)
+			}
+			*top = frame{block: true, clause: strings.Join(words, " ")}
+		case t.word && upper == "BETWEEN":
+			top.between = true
+		case t.word && upper == "AND" && top.between:
+			top.between = false
+		case t.word && (upper == "AND" || upper == "OR") && conditions(top.clause):
+			p.newline(1)
+		case t.word && upper == "ON" && strings.HasSuffix(top.clause, "JOIN"):
+			top.clause = "ON"
+		case t.text == "," && top.clause == "SELECT":
+			p.emit(t)
+			p.newline(1)
+			continue
+		}
+		p.emit(t)
+		if top.block && top.clause == "SELECT" && i+1 == keyword && p.longList(i+1) {
+			p.newline(1)
+		}
+	}
+}
+
+// clauseLen returns the number of words of the clause keyword starting at
+// i, or 0 when no clause starts there.
+func (p *printer) clauseLen(i int) int {
+	upper := strings.ToUpper(p.toks[i].text)
+	if clauses[upper] {
+		return 1
+	}
+	next, ok := leads[upper]
+	if !ok || i+1 >= len(p.toks) || !p.toks[i+1].word {
+		return 0
+	}
+	matched := false
+	for _, w := range next {
+		matched = matched || strings.EqualFold(p.toks[i+1].text, w)
+	}
+	if !matched {
+		return 0
+	}
+	n := 2
+	for i+n < len(p.toks) && p.toks[i+n].word && follows[strings.ToUpper(p.toks[i+n].text)] {
+		n++
+	}
+	return n
+}
+
+// startsQuery reports whether the tokens from i begin a subquery.
+func (p *printer) startsQuery(i int) bool {
+	if i >= len(p.toks) || !p.toks[i].word {
+		return false
+	}
+	upper := strings.ToUpper(p.toks[i].text)
+	return upper == "SELECT" || upper == "WITH"
+}
+
+// longList reports whether the select list from i has more than one item,
+// so it is worth a line per item.
+func (p *printer) longList(i int) bool {
+	depth := 0
+	for ; i < len(p.toks); i++ {
+		switch t := p.toks[i]; {
+		case t.text == "(":
+			depth++
+		case t.text == ")":
+			if depth == 0 {
+				return false
+			}
+			depth--
+		case depth > 0:
+		case t.text == ",":
+			return true
+		case t.word && strings.EqualFold(t.text, "FROM"):
+			return false
+		}
+	}
+	return false
+}
+
+// conditions reports whether AND and OR in clause join conditions.
+func conditions(clause string) bool {
+	return clause == "WHERE" || clause == "HAVING" || clause == "ON"
+}
+
+// newline starts a line indented for the current block, plus extra levels.
+// It does nothing at the start of a line.
+func (p *printer) newline(extra int) {
+	if p.out.Len() == 0 || p.fresh {
+		return
+	}
+	level := extra
+	for _, f := range p.frames[1:] {
+		if f.block {
+			level++
+		}
+	}
+	p.out.WriteString("\n" + strings.Repeat("  ", level))
+	p.fresh = true
+}
+
+func (p *printer) emit(t token) {
+	if t.space && !p.fresh {
+		p.out.WriteByte(' ')
+	}
+	p.out.WriteString(t.text)
+	p.fresh = false
+}
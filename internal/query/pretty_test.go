@@ -0,0 +1,97 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/query"
+)
+
+func TestPretty(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{
+			name: "short",
+			sql:  "SELECT 1",
+			want: "SELECT 1",
+		},
+		{
+			name: "orm select",
+			sql: `SELECT "users"."id", "users"."name", COUNT(*) FROM "users" LEFT OUTER JOIN "orders" ON "orders"."user_id" = "users"."id" ` +
+				`AND "orders"."deleted_at" IS NULL WHERE "users"."active" AND "users"."created_at" BETWEEN $1 AND $2 ` +
+				`GROUP BY "users"."id" ORDER BY "users"."name" DESC LIMIT 10 OFFSET 20`,
+			want: `SELECT
+  "users"."id",
+  "users"."name",
+  COUNT(*)
+FROM "users"
+LEFT OUTER JOIN "orders" ON "orders"."user_id" = "users"."id"
+  AND "orders"."deleted_at" IS NULL
+WHERE "users"."active"
+  AND "users"."created_at" BETWEEN $1 AND $2
+GROUP BY "users"."id"
+ORDER BY "users"."name" DESC
+LIMIT 10
+OFFSET 20`,
+		},
+		{
+			name: "subquery",
+			sql:  "SELECT * FROM users WHERE id IN (SELECT user_id FROM admins WHERE active) OR id = 1",
+			want: `SELECT *
+FROM users
+WHERE id IN (
+  SELECT user_id
+  FROM admins
+  WHERE active
+)
+  OR id = 1`,
+		},
+		{
+			name: "literals and functions untouched",
+			sql:  "SELECT EXTRACT(YEAR FROM ts), count(a, b) FROM t WHERE name = 'a, FROM b AND c' -- trailing, WHERE",
+			want: `SELECT
+  EXTRACT(YEAR FROM ts),
+  count(a, b)
+FROM t
+WHERE name = 'a, FROM b AND c' -- trailing, WHERE`,
+		},
+		{
+			name: "upsert",
+			sql:  "INSERT INTO t (a, b) VALUES ($1, $2) ON CONFLICT (a) DO UPDATE SET b = EXCLUDED.b RETURNING id",
+			want: `INSERT INTO t (a, b)
+VALUES ($1, $2)
+ON CONFLICT (a) DO UPDATE
+SET b = EXCLUDED.b
+RETURNING id`,
+		},
+		{
+			name: "cte and locking",
+			sql:  "WITH x AS (SELECT id FROM a) SELECT id FROM x FOR UPDATE SKIP LOCKED",
+			want: `WITH x AS (
+  SELECT id
+  FROM a
+)
+SELECT id
+FROM x
+FOR UPDATE SKIP LOCKED`,
+		},
+		{
+			name: "already laid out",
+			sql:  "SELECT a,\n       b FROM t",
+			want: "SELECT a,\n       b FROM t",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := query.Pretty(tt.sql); got != tt.want {
+				t.Errorf("Pretty(%q) =\n%s\nwant\n%s", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package query
+
+import "strings"
+
+// writeWords are keywords and functions that make an otherwise read-only
+// statement write or lock rows: data-modifying CTEs, SELECT INTO, row
+// locks (FOR UPDATE, FOR SHARE, LOCK IN SHARE MODE), and sequence changes.
+var writeWords = map[string]bool{
+	"INSERT":   true,
+	"UPDATE":   true,
+	"DELETE":   true,
+	"MERGE":    true,
+	"INTO":     true,
+	"SHARE":    true,
+	"NEXTVAL":  true,
+	"SETVAL":   true,
+	"TRUNCATE": true,
+}
+
+// ReadOnly reports whether sql is a single statement that cannot write: a
+// SELECT, WITH, VALUES, TABLE, or SHOW without data-modifying clauses, or a
+// BEGIN or START TRANSACTION marked READ ONLY. It errs toward false; calls
+// to functions with side effects other than nextval and setval go
+// undetected.
+func ReadOnly(sql string) bool {
+	fp := strings.TrimSpace(Fingerprint(sql))
+	fp = strings.TrimSpace(strings.TrimSuffix(fp, ";"))
+	if fp == "" || strings.Contains(fp, ";") {
+		return false
+	}
+	words := strings.FieldsFunc(strings.ToUpper(fp), func(r rune) bool {
+		return r != '_' && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	})
+	if len(words) == 0 {
+		return false
+	}
+	switch words[0] {
+	case "BEGIN", "START":
+		return strings.Contains(strings.Join(words, " "), "READ ONLY")
+	case "SHOW":
+		return true
+	case "SELECT", "WITH", "VALUES", "TABLE":
+		for _, w := range words[1:] {
+			if writeWords[w] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,49 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/query"
+)
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		sql  string
+		want bool
+	}{
+		{name: "select", sql: "SELECT * FROM users WHERE id = $1", want: true},
+		{name: "lowercase with semicolon", sql: "select 1;", want: true},
+		{name: "leading comment", sql: "/* route='GET /users' */ SELECT name FROM users", want: true},
+		{name: "cte", sql: "WITH recent AS (SELECT * FROM orders) SELECT count(*) FROM recent", want: true},
+		{name: "show", sql: "SHOW TimeZone", want: true},
+		{name: "values", sql: "VALUES (1), (2)", want: true},
+		{name: "keyword in string", sql: "SELECT * FROM logs WHERE msg = 'DELETE me'", want: true},
+		{name: "begin read only", sql: "BEGIN READ ONLY", want: true},
+		{name: "start transaction read only", sql: "START TRANSACTION ISOLATION LEVEL REPEATABLE READ, READ ONLY", want: true},
+		{name: "begin", sql: "BEGIN", want: false},
+		{name: "begin read write", sql: "BEGIN READ WRITE", want: false},
+		{name: "insert", sql: "INSERT INTO users (name) VALUES ($1)", want: false},
+		{name: "update", sql: "UPDATE users SET name = $1", want: false},
+		{name: "data-modifying cte", sql: "WITH gone AS (DELETE FROM users RETURNING id) SELECT count(*) FROM gone", want: false},
+		{name: "select into", sql: "SELECT * INTO archive FROM users", want: false},
+		{name: "for update", sql: "SELECT * FROM jobs FOR UPDATE SKIP LOCKED", want: false},
+		{name: "for share", sql: "SELECT * FROM jobs FOR KEY SHARE", want: false},
+		{name: "nextval", sql: "SELECT nextval('users_id_seq')", want: false},
+		{name: "several statements", sql: "SELECT 1; DELETE FROM users", want: false},
+		{name: "explain", sql: "EXPLAIN ANALYZE DELETE FROM users", want: false},
+		{name: "empty", sql: "  ", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := query.ReadOnly(tt.sql); got != tt.want {
+				t.Errorf("ReadOnly(%q) = %v, want %v", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
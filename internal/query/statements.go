@@ -0,0 +1,186 @@
+package query
+
+import "strings"
+
+// Dialect selects how Statements reads quoting and comments.
+type Dialect int
+
+const (
+	Postgres Dialect = iota
+	MySQL
+)
+
+// Statements splits sql at the semicolons the dialect's server would end
+// statements at, and returns the statements with comments removed and
+// surrounding whitespace trimmed; empty statements are left out. Literals
+// and quoted identifiers are kept as written. PostgreSQL's comments nest,
+// E'...' strings take backslash escapes, and dollar quotes ($tag$...$tag$) are
+// literals; MySQL's strings take backslash escapes, "--" starts a comment
+// only before whitespace, "#" starts one too, and the contents of an
+// executable comment (/*! ... */) are kept as the code they are.
+func Statements(sql string, d Dialect) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+		exec  bool // inside a MySQL executable comment
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ';':
+			flush()
+			i++
+		case c == '\'' || c == '"' || c == '`' && d == MySQL:
+			j := skipQuoted(sql, i, backslashes(sql, i, d))
+			cur.WriteString(sql[i:j])
+			i = j
+		case lineComment(sql, i, d):
+			if j := strings.IndexByte(sql[i:], '\n'); j >= 0 {
+				i += j
+			} else {
+				i = len(sql)
+			}
+			cur.WriteByte(' ')
+		case d == MySQL && strings.HasPrefix(sql[i:], "/*!"):
+			// The optional version the contents require is dropped with the
+			// marker; running them on any version errs toward seeing them.
+			i += 3
+			for i < len(sql) && isDigit(sql[i]) {
+				i++
+			}
+			exec = true
+			cur.WriteByte(' ')
+		case exec && strings.HasPrefix(sql[i:], "*/"):
+			exec = false
+			i += 2
+			cur.WriteByte(' ')
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipBlockComment(sql, i, d == Postgres)
+			cur.WriteByte(' ')
+		case c == '$' && d == Postgres:
+			j := skipDollarQuoted(sql, i)
+			cur.WriteString(sql[i:j])
+			i = j
+		default:
+			cur.WriteByte(c)
+			i++
+		}
+	}
+	flush()
+	return stmts
+}
+
+// backslashes reports whether the literal or identifier quoted at i takes
+// backslash escapes.
+func backslashes(sql string, i int, d Dialect) bool {
+	switch d {
+	case Postgres:
+		// An escape string constant: E'...', where E does not end a longer
+		// identifier.
+		if sql[i] != '\'' || i == 0 || sql[i-1] != 'E' && sql[i-1] != 'e' {
+			return false
+		}
+		return i == 1 || !isIdentByte(sql[i-2])
+	case MySQL:
+		return sql[i] != '`'
+	}
+	return false
+}
+
+// skipQuoted returns the index just past the literal or identifier quoted
+// at i, treating a doubled quote, and with backslash a backslash, as
+// escaping the next byte. An unterminated one runs to the end of sql.
+func skipQuoted(sql string, i int, backslash bool) int {
+	q := sql[i]
+	for i++; i < len(sql); i++ {
+		switch sql[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case q:
+			if i+1 < len(sql) && sql[i+1] == q {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(sql)
+}
+
+// lineComment reports whether a comment running to the end of the line
+// starts at i.
+func lineComment(sql string, i int, d Dialect) bool {
+	if d == MySQL && sql[i] == '#' {
+		return true
+	}
+	if !strings.HasPrefix(sql[i:], "--") {
+		return false
+	}
+	if d == MySQL && i+2 < len(sql) {
+		c := sql[i+2]
+		return c == ' ' || c < ' ' || c == 0x7f
+	}
+	return true
+}
+
+// skipBlockComment returns the index just past the /* comment at i, which
+// nests when nested is set. An unterminated one runs to the end of sql.
+func skipBlockComment(sql string, i int, nested bool) int {
+	depth := 0
+	for i < len(sql) {
+		switch {
+		case strings.HasPrefix(sql[i:], "/*"):
+			if depth == 0 || nested {
+				depth++
+			}
+			i += 2
+		case strings.HasPrefix(sql[i:], "*/"):
+			depth--
+			i += 2
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return len(sql)
+}
+
+// skipDollarQuoted returns the index just past the dollar-quoted literal
+// at i, or past the $ alone when none starts there: a parameter such as $1
+// or a $ inside an identifier.
+func skipDollarQuoted(sql string, i int) int {
+	if i > 0 && isIdentByte(sql[i-1]) {
+		return i + 1
+	}
+	j := i + 1
+	if j < len(sql) && isDigit(sql[j]) {
+		return i + 1
+	}
+	for j < len(sql) && sql[j] != '$' && isIdentByte(sql[j]) {
+		j++
+	}
+	if j >= len(sql) || sql[j] != '$' {
+		return i + 1
+	}
+	tag := sql[i : j+1]
+	if k := strings.Index(sql[j+1:], tag); k >= 0 {
+		return j + 1 + k + len(tag)
+	}
+	return len(sql)
+}
+
+// isIdentByte reports whether c can continue an identifier; bytes of
+// multibyte characters count, as PostgreSQL and MySQL allow them.
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c >= 0x80
+}
@@ -0,0 +1,54 @@
+package query_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/query"
+)
+
+func TestStatements(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		sql     string
+		dialect query.Dialect
+		want    []string
+	}{
+		{name: "single", sql: "SELECT 1", want: []string{"SELECT 1"}},
+		{name: "trailing semicolon", sql: " SELECT 1 ; ", want: []string{"SELECT 1"}},
+		{name: "several", sql: "SELECT 1; DROP TABLE users", want: []string{"SELECT 1", "DROP TABLE users"}},
+		{name: "only comments", sql: "/* x */ -- y", want: nil},
+		{name: "leading comment", sql: "/* x */ DROP TABLE users", want: []string{"DROP TABLE users"}},
+		{name: "line comment", sql: "SELECT 1 -- ; not a statement\n; DROP TABLE t", want: []string{"SELECT 1", "DROP TABLE t"}},
+		{name: "semicolon in literal", sql: "SELECT 'a;b', \"c;d\"", want: []string{"SELECT 'a;b', \"c;d\""}},
+		{name: "doubled quote", sql: "SELECT 'it''s; fine'", want: []string{"SELECT 'it''s; fine'"}},
+		{name: "standard string", sql: `SELECT 'a\'; DROP TABLE t`, want: []string{`SELECT 'a\'`, "DROP TABLE t"}},
+		{name: "escape string", sql: `SELECT E'\''; DROP TABLE t; --'`, want: []string{`SELECT E'\''`, "DROP TABLE t"}},
+		{name: "nested comment", sql: "/* /* */ ' */ ; DROP TABLE t; -- '", want: []string{"DROP TABLE t"}},
+		{name: "dollar quote", sql: "SELECT $f$a;b$f$, $$c;d$$", want: []string{"SELECT $f$a;b$f$, $$c;d$$"}},
+		{name: "parameter", sql: "SELECT $1; DELETE FROM t", want: []string{"SELECT $1", "DELETE FROM t"}},
+		{
+			name:    "mysql backslash",
+			sql:     `SELECT 'a\''; DROP TABLE t; -- '`,
+			dialect: query.MySQL,
+			want:    []string{`SELECT 'a\''`, "DROP TABLE t"},
+		},
+		{name: "mysql double dash", sql: "SELECT 1--1; DROP TABLE t", dialect: query.MySQL, want: []string{"SELECT 1--1", "DROP TABLE t"}},
+		{name: "mysql hash", sql: "SELECT 1 # ; x\n; DROP TABLE t", dialect: query.MySQL, want: []string{"SELECT 1", "DROP TABLE t"}},
+		{name: "mysql executable", sql: "SELECT 1; /*!50000 DROP TABLE t */", dialect: query.MySQL, want: []string{"SELECT 1", "DROP TABLE t"}},
+		{name: "mysql dollar", sql: "SELECT $a$; DROP TABLE t; $a$", dialect: query.MySQL, want: []string{"SELECT $a$", "DROP TABLE t", "$a$"}},
+		{name: "mysql backtick", sql: "SELECT `a;b` FROM t", dialect: query.MySQL, want: []string{"SELECT `a;b` FROM t"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := query.Statements(tt.sql, tt.dialect); !slices.Equal(got, tt.want) {
+				t.Errorf("Statements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+// Package replay re-runs recorded statements against a database and checks
+// each outcome against the recording: a regression harness built from
+// captured traffic. Statements run with their captured bind arguments, one
+// at a time in start order, and a statement diverges when it fails where
+// the recording succeeded, succeeds where it failed, or affects a different
+// number of rows.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// maxLineSize bounds one NDJSON record, as in diff.
+const maxLineSize = 16 << 20
+
+// Read returns the records in the NDJSON r.
+func Read(r io.Reader) ([]export.Record, error) {
+	var recs []export.Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, maxLineSize)
+	for line := 1; sc.Scan(); line++ {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var rec export.Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("replay: line %d: %w", line, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	return recs, nil
+}
+
+// Executor runs one statement. read reports that the statement returns
+// rows, whose count is then the result, rather than affecting them.
+type Executor interface {
+	Execute(ctx context.Context, query string, args []any, read bool) (rows int64, err error)
+}
+
+// Conn is the part of *sql.Conn, *sql.DB, and *sql.Tx an Executor needs.
+type Conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// SQL returns an Executor running statements on c. Use a single *sql.Conn
+// so session state, such as an open transaction, carries between them.
+func SQL(c Conn) Executor {
+	return sqlExecutor{c: c}
+}
+
+type sqlExecutor struct{ c Conn }
+
+func (e sqlExecutor) Execute(ctx context.Context, query string, args []any, read bool) (int64, error) {
+	if !read {
+		res, err := e.c.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0, err //nolint:wrapcheck // compared with the recorded error, not returned
+		}
+		n, _ := res.RowsAffected()
+		return n, nil
+	}
+	rows, err := e.c.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // compared with the recorded error, not returned
+	}
+	defer func() { _ = rows.Close() }()
+	var n int64
+	for rows.Next() {
+		n++
+	}
+	return n, rows.Err() //nolint:wrapcheck // compared with the recorded error, not returned
+}
+
+// Option configures Run.
+type Option func(*replayer)
+
+// WithWrites replays statements that may write, and the transaction
+// statements around them. By default only statements query.ReadOnly
+// accepts run, so replaying against a live database changes nothing.
+func WithWrites() Option {
+	return func(r *replayer) {
+		r.writes = true
+	}
+}
+
+// WithConn replays only the statements of the recorded connection id, so
+// one client's session, transactions included, runs as it did.
+func WithConn(id string) Option {
+	return func(r *replayer) {
+		r.conn = &id
+	}
+}
+
+// WithRewrite renames schemas, databases, and table prefixes in each
+// statement before it runs, so a production capture can replay against a
+// differently named test environment.
+func WithRewrite(rules ...Rule) Option {
+	return func(r *replayer) {
+		r.rules = append(r.rules, rules...)
+	}
+}
+
+// WithSynthetic replaces captured arguments with synthetic values, so
+// replaying writes does not copy real data into a test environment. Each
+// argument's generator comes from the rule for the column it is bound to;
+// arguments without one are sent as captured unless a "*" rule covers
+// them. Values derive from seed and the captured value alone, so equal
+// values stay equal and keys still join across statements and runs.
+func WithSynthetic(seed string, rules ...Synth) Option {
+	return func(r *replayer) {
+		r.synth = newSynthesizer(seed, rules)
+	}
+}
+
+// WithNullArg sends captured arguments equal to s as NULL. The MySQL proxy
+// records NULL arguments as "NULL".
+func WithNullArg(s string) Option {
+	return func(r *replayer) {
+		r.null = &s
+	}
+}
+
+// WithTimeout bounds each statement (default DefaultTimeout).
+func WithTimeout(d time.Duration) Option {
+	return func(r *replayer) {
+		r.timeout = d
+	}
+}
+
+// DefaultTimeout bounds each replayed statement.
+const DefaultTimeout = 30 * time.Second
+
+type replayer struct {
+	writes  bool
+	conn    *string // nil replays every connection
+	null    *string
+	rules   []Rule
+	synth   *synthesizer // nil sends arguments as captured
+	timeout time.Duration
+}
+
+// Divergence is a statement whose replay did not match its recording.
+type Divergence struct {
+	Record export.Record `json:"record"`
+	Reason string        `json:"reason"` // "error", "success", or "rows"
+	Rows   int64         `json:"rows"`   // rows the replay returned or affected
+	Error  string        `json:"error,omitempty"`
+	// Query is the statement as replayed, when WithRewrite changed it.
+	Query string `json:"query,omitempty"`
+}
+
+// Report is the outcome of Run.
+type Report struct {
+	Replayed    int          `json:"replayed"`
+	Skipped     int          `json:"skipped"` // writes without WithWrites, statements blocked by policy, and records without a statement
+	Divergences []Divergence `json:"divergences"`
+}
+
+// ErrNoConn is returned by Run when WithConn names a connection without
+// recorded statements.
+var ErrNoConn = errors.New("replay: no statements recorded for the connection")
+
+// Run replays recs through e in start order and reports the divergences.
+// It stops early only when ctx is done.
+func Run(ctx context.Context, e Executor, recs []export.Record, opts ...Option) (Report, error) {
+	r := replayer{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&r)
+	}
+
+	recs = slices.Clone(recs)
+	if r.conn != nil {
+		recs = slices.DeleteFunc(recs, func(rec export.Record) bool { return rec.ConnID != *r.conn })
+		if len(recs) == 0 {
+			return Report{}, fmt.Errorf("%w %q", ErrNoConn, *r.conn)
+		}
+	}
+	slices.SortStableFunc(recs, func(a, b export.Record) int {
+		return startTime(a).Compare(startTime(b))
+	})
+
+	rep := Report{Divergences: []Divergence{}}
+	for _, rec := range recs {
+		if err := ctx.Err(); err != nil {
+			return rep, fmt.Errorf("replay: %w", err)
+		}
+		read, ok := r.plan(rec)
+		if !ok {
+			rep.Skipped++
+			continue
+		}
+		q := Rewrite(rec.Query, r.rules)
+		sctx, cancel := context.WithTimeout(ctx, r.timeout)
+		rows, err := e.Execute(sctx, q, r.args(rec.Query, rec.Args), read)
+		cancel()
+		rep.Replayed++
+
+		d := Divergence{Record: rec, Rows: rows}
+		if q != rec.Query {
+			d.Query = q
+		}
+		if err != nil {
+			d.Error = err.Error()
+		}
+		switch {
+		case err != nil && rec.Error == "":
+			d.Reason = "error"
+		case err == nil && rec.Error != "":
+			d.Reason = "success"
+		case err == nil && rows != rec.RowsAffected && !isLifecycle(rec.Op):
+			d.Reason = "rows"
+		default:
+			continue
+		}
+		rep.Divergences = append(rep.Divergences, d)
+	}
+	return rep, nil
+}
+
+// plan reports whether rec is replayed and, if so, whether it returns rows.
+func (r replayer) plan(rec export.Record) (read, ok bool) {
+	if rec.Query == "" || rec.Blocked {
+		return false, false
+	}
+	switch rec.Op {
+	case proxy.OpQuery.String(), proxy.OpExec.String(), proxy.OpExecute.String():
+		if query.ReadOnly(rec.Query) {
+			return true, true
+		}
+		return false, r.writes
+	case proxy.OpBegin.String(), proxy.OpCommit.String(), proxy.OpRollback.String():
+		return false, r.writes
+	}
+	return false, false
+}
+
+func isLifecycle(op string) bool {
+	return op == proxy.OpBegin.String() || op == proxy.OpCommit.String() || op == proxy.OpRollback.String()
+}
+
+// args returns the captured arguments of query to send, as synthetic
+// values under WithSynthetic.
+func (r replayer) args(query string, captured []string) []any {
+	var cols []string
+	if r.synth != nil {
+		cols = argColumns(query, len(captured))
+	}
+	args := make([]any, len(captured))
+	for i, a := range captured {
+		if r.null != nil && a == *r.null {
+			continue // nil
+		}
+		if r.synth != nil {
+			v, ok := r.synth.value(r.synth.generator(cols[i]), a)
+			if !ok {
+				continue // nil
+			}
+			a = v
+		}
+		args[i] = a
+	}
+	return args
+}
+
+func startTime(rec export.Record) time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, rec.StartTime)
+	return t
+}
@@ -0,0 +1,174 @@
+package replay_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/replay"
+)
+
+// fakeExecutor answers from a table keyed by query text and records what
+// ran.
+type fakeExecutor struct {
+	rows map[string]int64
+	errs map[string]error
+	ran  []string
+	args [][]any
+}
+
+func (f *fakeExecutor) Execute(_ context.Context, query string, args []any, _ bool) (int64, error) {
+	f.ran = append(f.ran, query)
+	f.args = append(f.args, args)
+	if err := f.errs[query]; err != nil {
+		return 0, err
+	}
+	return f.rows[query], nil
+}
+
+const session = `{"id":"2","start_time":"2026-10-14T12:00:01Z","op":"Execute","query":"SELECT * FROM users WHERE id = $1","args":["7"],"rows_affected":1,"conn_id":"9"}
+{"id":"1","start_time":"2026-10-14T12:00:00Z","op":"Begin","query":"BEGIN","args":[],"rows_affected":0}
+
+{"id":"3","start_time":"2026-10-14T12:00:02Z","op":"Exec","query":"UPDATE users SET name = 'x' WHERE id = 7","args":[],"rows_affected":1}
+{"id":"4","start_time":"2026-10-14T12:00:03Z","op":"Query","query":"SELECT * FROM missing","args":[],"rows_affected":0,"error":"relation \"missing\" does not exist"}
+{"id":"5","start_time":"2026-10-14T12:00:04Z","op":"Query","query":"SELECT count(*) FROM orders","args":[],"rows_affected":1,"conn_id":"9"}
+{"id":"6","start_time":"2026-10-14T12:00:05Z","op":"Commit","query":"COMMIT","args":[],"rows_affected":0}
+`
+
+func read(t *testing.T) []export.Record {
+	t.Helper()
+	recs, err := replay.Read(strings.NewReader(session))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return recs
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		opts        []replay.Option
+		rows        map[string]int64
+		errs        map[string]error
+		wantRan     int
+		wantSkipped int
+		want        map[string]string // record ID -> reason
+	}{
+		{
+			name:        "matches the recording",
+			rows:        map[string]int64{"SELECT * FROM users WHERE id = $1": 1, "SELECT count(*) FROM orders": 1},
+			errs:        map[string]error{"SELECT * FROM missing": errors.New("no such table")},
+			wantRan:     3,
+			wantSkipped: 3,
+			want:        map[string]string{},
+		},
+		{
+			name:        "divergences",
+			rows:        map[string]int64{"SELECT count(*) FROM orders": 1},
+			errs:        map[string]error{"SELECT count(*) FROM orders": errors.New("permission denied")},
+			wantRan:     3,
+			wantSkipped: 3,
+			want:        map[string]string{"2": "rows", "4": "success", "5": "error"},
+		},
+		{
+			name:    "with writes",
+			opts:    []replay.Option{replay.WithWrites()},
+			rows:    map[string]int64{"SELECT * FROM users WHERE id = $1": 1, "UPDATE users SET name = 'x' WHERE id = 7": 1, "SELECT count(*) FROM orders": 1},
+			errs:    map[string]error{"SELECT * FROM missing": errors.New("no such table")},
+			wantRan: 6,
+			want:    map[string]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := &fakeExecutor{rows: tt.rows, errs: tt.errs}
+			rep, err := replay.Run(t.Context(), e, read(t), tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rep.Replayed != tt.wantRan || rep.Skipped != tt.wantSkipped {
+				t.Errorf("replayed %d, skipped %d; want %d, %d", rep.Replayed, rep.Skipped, tt.wantRan, tt.wantSkipped)
+			}
+			got := make(map[string]string, len(rep.Divergences))
+			for _, d := range rep.Divergences {
+				got[d.Record.ID] = d.Reason
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("divergences = %v, want %v", got, tt.want)
+			}
+			for id, reason := range tt.want {
+				if got[id] != reason {
+					t.Errorf("record %s diverged for %q, want %q", id, got[id], reason)
+				}
+			}
+		})
+	}
+}
+
+func TestRun_Order(t *testing.T) {
+	t.Parallel()
+
+	e := &fakeExecutor{}
+	if _, err := replay.Run(t.Context(), e, read(t), replay.WithWrites()); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.ran) == 0 || e.ran[0] != "BEGIN" || e.ran[len(e.ran)-1] != "COMMIT" {
+		t.Errorf("ran %q, want the recording's start order", e.ran)
+	}
+	if len(e.args[1]) != 1 || e.args[1][0] != "7" {
+		t.Errorf("args = %v, want the captured bind argument", e.args[1])
+	}
+}
+
+func TestRun_NullArg(t *testing.T) {
+	t.Parallel()
+
+	recs := []export.Record{{Op: "Execute", Query: "SELECT * FROM t WHERE a = ? AND b = ?", Args: []string{"NULL", "1"}}}
+	e := &fakeExecutor{}
+	if _, err := replay.Run(t.Context(), e, recs, replay.WithNullArg("NULL")); err != nil {
+		t.Fatal(err)
+	}
+	if args := e.args[0]; args[0] != nil || args[1] != "1" {
+		t.Errorf("args = %v, want NULL then the captured value", args)
+	}
+}
+
+func TestRun_Blocked(t *testing.T) {
+	t.Parallel()
+
+	recs := []export.Record{
+		{Op: "Exec", Query: "DROP TABLE users", Violation: "no-drop", Blocked: true, Error: "blocked"},
+		{Op: "Exec", Query: "TRUNCATE sessions", Violation: "no-truncate"},
+	}
+	e := &fakeExecutor{}
+	rep, err := replay.Run(t.Context(), e, recs, replay.WithWrites())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.ran) != 1 || e.ran[0] != "TRUNCATE sessions" || rep.Skipped != 1 {
+		t.Errorf("ran %q (report %+v), want only the statement the server ran", e.ran, rep)
+	}
+}
+
+func TestRun_Conn(t *testing.T) {
+	t.Parallel()
+
+	e := &fakeExecutor{rows: map[string]int64{"SELECT * FROM users WHERE id = $1": 1, "SELECT count(*) FROM orders": 1}}
+	rep, err := replay.Run(t.Context(), e, read(t), replay.WithConn("9"), replay.WithWrites())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.ran) != 2 || e.ran[0] != "SELECT * FROM users WHERE id = $1" || rep.Replayed != 2 || rep.Skipped != 0 {
+		t.Errorf("ran %q (report %+v), want only connection 9's statements", e.ran, rep)
+	}
+
+	if _, err := replay.Run(t.Context(), e, read(t), replay.WithConn("10")); !errors.Is(err, replay.ErrNoConn) {
+		t.Errorf("err = %v, want ErrNoConn", err)
+	}
+}
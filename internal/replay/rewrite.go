@@ -0,0 +1,175 @@
+package replay
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RuleKind selects what a Rule renames.
+type RuleKind int
+
+const (
+	// RenameSchema renames a schema (PostgreSQL) or database (MySQL) where
+	// it qualifies a name, as in prod.users, and in USE statements.
+	RenameSchema RuleKind = iota + 1
+	// RenamePrefix replaces the prefix of every identifier that has it, as
+	// with tables named prod_users and prod_orders.
+	RenamePrefix
+)
+
+// Rule maps a name in captured statements to the one a test environment
+// uses. Unquoted identifiers match From case-insensitively, as both
+// databases fold them; quoted ones exactly.
+type Rule struct {
+	Kind     RuleKind
+	From, To string
+}
+
+// String returns r in the form ParseRule accepts.
+func (r Rule) String() string {
+	kind := "prefix"
+	if r.Kind == RenameSchema {
+		kind = "schema"
+	}
+	return kind + ":" + r.From + "=" + r.To
+}
+
+// ParseRule parses "schema:<from>=<to>" or "prefix:<from>=<to>".
+func ParseRule(s string) (Rule, error) {
+	kind, mapping, ok := strings.Cut(s, ":")
+	from, to, ok2 := strings.Cut(mapping, "=")
+	if !ok || !ok2 || from == "" || to == "" {
+		return Rule{}, fmt.Errorf("replay: invalid rename %q (want schema:<from>=<to> or prefix:<from>=<to>)", s)
+	}
+	switch kind {
+	case "schema":
+		return Rule{Kind: RenameSchema, From: from, To: to}, nil
+	case "prefix":
+		return Rule{Kind: RenamePrefix, From: from, To: to}, nil
+	}
+	return Rule{}, fmt.Errorf("replay: unknown rename kind %q (want schema or prefix)", kind)
+}
+
+// Rewrite applies rules to the identifiers in sql, leaving string literals,
+// dollar-quoted bodies, and comments alone. The first rule matching an
+// identifier wins.
+func Rewrite(sql string, rules []Rule) string {
+	if len(rules) == 0 {
+		return sql
+	}
+	var out strings.Builder
+	out.Grow(len(sql))
+	prev := "" // the last bare word, to spot USE <name>
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		var j int
+		switch {
+		case c == '\'':
+			j = skipQuoted(sql, i, '\'')
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			j = len(sql)
+			if k := strings.IndexByte(sql[i:], '\n'); k >= 0 {
+				j = i + k
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			j = len(sql)
+			if k := strings.Index(sql[i+2:], "*/"); k >= 0 {
+				j = i + k + 4
+			}
+		case c == '$':
+			j = skipDollar(sql, i)
+		case c == '"' || c == '`':
+			j = skipQuoted(sql, i, c)
+			out.WriteString(rename(sql[i:j], sql[j:], prev, rules))
+			prev = ""
+			i = j
+			continue
+		case isIdentStart(c):
+			for j = i + 1; j < len(sql) && isIdentPart(sql[j]); j++ {
+			}
+			out.WriteString(rename(sql[i:j], sql[j:], prev, rules))
+			prev = sql[i:j]
+			i = j
+			continue
+		default:
+			j = i + 1
+		}
+		out.WriteString(sql[i:j])
+		i = j
+	}
+	return out.String()
+}
+
+// rename returns the identifier tok, bare or quoted, after the first rule
+// matching it. rest is the text after it and prev the bare word before.
+func rename(tok, rest, prev string, rules []Rule) string {
+	name, quote := tok, ""
+	if c := tok[0]; c == '"' || c == '`' {
+		quote = string(c)
+		name = strings.TrimSuffix(tok[1:], quote)
+	}
+	qualifier := strings.HasPrefix(strings.TrimLeft(rest, " \t\r\n"), ".")
+	for _, r := range rules {
+		switch r.Kind {
+		case RenameSchema:
+			if (qualifier || strings.EqualFold(prev, "USE")) && match(name, r.From, quote != "") {
+				return quote + r.To + quote
+			}
+		case RenamePrefix:
+			if len(name) >= len(r.From) && match(name[:len(r.From)], r.From, quote != "") {
+				return quote + r.To + name[len(r.From):] + quote
+			}
+		}
+	}
+	return tok
+}
+
+func match(name, want string, quoted bool) bool {
+	if quoted {
+		return name == want
+	}
+	return strings.EqualFold(name, want)
+}
+
+// skipQuoted returns the index just past the literal or quoted identifier
+// starting at i, treating a doubled quote as an escaped one.
+func skipQuoted(sql string, i int, quote byte) int {
+	for i++; i < len(sql); i++ {
+		if sql[i] != quote {
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			i++
+			continue
+		}
+		return i + 1
+	}
+	return len(sql)
+}
+
+// skipDollar returns the index just past the PostgreSQL dollar-quoted
+// string starting at i, or past the $ when it opens a placeholder instead.
+func skipDollar(sql string, i int) int {
+	j := i + 1
+	for j < len(sql) && isIdentPart(sql[j]) && sql[j] != '$' {
+		j++
+	}
+	if j >= len(sql) || sql[j] != '$' || j > i+1 && isDigit(sql[i+1]) {
+		return i + 1
+	}
+	tag := sql[i : j+1]
+	if k := strings.Index(sql[j+1:], tag); k >= 0 {
+		return j + 1 + k + len(tag)
+	}
+	return len(sql)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || isDigit(c) || c == '$'
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
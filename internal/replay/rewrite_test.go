@@ -0,0 +1,89 @@
+package replay_test
+
+import (
+	"testing"
+
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/replay"
+)
+
+func TestRewrite(t *testing.T) {
+	t.Parallel()
+
+	schema := replay.Rule{Kind: replay.RenameSchema, From: "prod", To: "staging"}
+	prefix := replay.Rule{Kind: replay.RenamePrefix, From: "prod_", To: "test_"}
+	tests := []struct {
+		name string
+		sql  string
+		want string
+	}{
+		{name: "qualifier", sql: "SELECT * FROM prod.users WHERE id = $1", want: "SELECT * FROM staging.users WHERE id = $1"},
+		{name: "unqualified name kept", sql: "SELECT prod FROM t", want: "SELECT prod FROM t"},
+		{name: "case-insensitive", sql: "SELECT * FROM PROD . users", want: "SELECT * FROM staging . users"},
+		{name: "quoted", sql: `SELECT * FROM "prod"."users", "Prod".x`, want: `SELECT * FROM "staging"."users", "Prod".x`},
+		{name: "backquoted", sql: "SELECT * FROM `prod`.`users`", want: "SELECT * FROM `staging`.`users`"},
+		{name: "use", sql: "USE prod", want: "USE staging"},
+		{name: "prefix", sql: "SELECT o.id FROM prod_orders o JOIN prod_users u ON u.id = o.user_id",
+			want: "SELECT o.id FROM test_orders o JOIN test_users u ON u.id = o.user_id"},
+		{name: "prefix qualifier", sql: "SELECT prod_users.id FROM prod.prod_users",
+			want: "SELECT test_users.id FROM staging.test_users"},
+		{name: "literals and comments kept", sql: "SELECT 'prod.users', $x$prod.t$x$ FROM prod.t -- prod.t\n/* prod_t */",
+			want: "SELECT 'prod.users', $x$prod.t$x$ FROM staging.t -- prod.t\n/* prod_t */"},
+		{name: "placeholders", sql: "SELECT $1, $2 FROM prod_t", want: "SELECT $1, $2 FROM test_t"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := replay.Rewrite(tt.sql, []replay.Rule{schema, prefix}); got != tt.want {
+				t.Errorf("Rewrite(%q)\n got %q\nwant %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    replay.Rule
+		wantErr bool
+	}{
+		{in: "schema:prod=staging", want: replay.Rule{Kind: replay.RenameSchema, From: "prod", To: "staging"}},
+		{in: "prefix:prod_=test_", want: replay.Rule{Kind: replay.RenamePrefix, From: "prod_", To: "test_"}},
+		{in: "table:prod=test", wantErr: true},
+		{in: "schema:prod", wantErr: true},
+		{in: "schema:=test", wantErr: true},
+		{in: "prod=test", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := replay.ParseRule(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("ParseRule(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+			}
+			if err == nil && got.String() != tt.in {
+				t.Errorf("String() = %q, want %q", got.String(), tt.in)
+			}
+		})
+	}
+}
+
+func TestRun_Rewrite(t *testing.T) {
+	t.Parallel()
+
+	recs := []export.Record{{Op: "Query", Query: "SELECT * FROM prod.users", RowsAffected: 1}}
+	e := &fakeExecutor{}
+	rule := replay.Rule{Kind: replay.RenameSchema, From: "prod", To: "test"}
+	rep, err := replay.Run(t.Context(), e, recs, replay.WithRewrite(rule))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(e.ran) != 1 || e.ran[0] != "SELECT * FROM test.users" {
+		t.Errorf("ran %q, want the renamed statement", e.ran)
+	}
+	if len(rep.Divergences) != 1 || rep.Divergences[0].Query != "SELECT * FROM test.users" {
+		t.Errorf("divergences = %+v, want the replayed statement on the rows divergence", rep.Divergences)
+	}
+}
@@ -0,0 +1,382 @@
+package replay
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator names how WithSynthetic replaces a captured argument.
+type Generator string
+
+const (
+	// GenKeep sends the captured value unchanged.
+	GenKeep Generator = "keep"
+	// GenShape replaces each letter with a letter of the same case and each
+	// digit with a digit, keeping length, punctuation, and the sign and
+	// magnitude of numbers. Dates and timestamps move by up to a year
+	// instead, and true and false stay as they are.
+	GenShape Generator = "shape"
+	// GenEmail replaces the local part of an address as GenShape does and
+	// the domain with example.com.
+	GenEmail Generator = "email"
+	// GenUUID replaces the value with a UUID.
+	GenUUID Generator = "uuid"
+	// GenNull sends NULL.
+	GenNull Generator = "null"
+)
+
+func parseGenerator(s string) (Generator, error) {
+	switch g := Generator(s); g {
+	case GenKeep, GenShape, GenEmail, GenUUID, GenNull:
+		return g, nil
+	}
+	return "", fmt.Errorf("replay: unknown generator %q (want keep, shape, email, uuid, or null)", s)
+}
+
+// Synth assigns a Generator to the arguments bound to Column. Column "*"
+// covers arguments with no rule of their own, including those whose column
+// cannot be told from the statement.
+type Synth struct {
+	Column    string
+	Generator Generator
+}
+
+// String returns s in the form ParseSynth accepts.
+func (s Synth) String() string {
+	return s.Column + "=" + string(s.Generator)
+}
+
+// ParseSynth parses "<column>=<generator>", such as "email=email" or
+// "*=shape".
+func ParseSynth(s string) (Synth, error) {
+	col, gen, ok := strings.Cut(s, "=")
+	if !ok || col == "" {
+		return Synth{}, fmt.Errorf("replay: invalid synthesize rule %q (want <column>=<generator>)", s)
+	}
+	g, err := parseGenerator(gen)
+	if err != nil {
+		return Synth{}, err
+	}
+	return Synth{Column: col, Generator: g}, nil
+}
+
+// synthesizer replaces captured arguments with values derived from the
+// seed. The same seed, generator, and captured value always give the same
+// result, whatever the column, so a key written by one statement still
+// matches where a later one reads or joins on it.
+type synthesizer struct {
+	seed  []byte
+	rules map[string]Generator // lower-cased column -> generator
+}
+
+func newSynthesizer(seed string, rules []Synth) *synthesizer {
+	s := &synthesizer{seed: []byte(seed), rules: make(map[string]Generator, len(rules))}
+	for _, r := range rules {
+		if _, ok := s.rules[strings.ToLower(r.Column)]; !ok {
+			s.rules[strings.ToLower(r.Column)] = r.Generator
+		}
+	}
+	return s
+}
+
+// generator returns the generator for an argument bound to column, which
+// is "" when unknown.
+func (s *synthesizer) generator(column string) Generator {
+	if g, ok := s.rules[strings.ToLower(column)]; ok && column != "" {
+		return g
+	}
+	if g, ok := s.rules["*"]; ok {
+		return g
+	}
+	return GenKeep
+}
+
+// value returns the synthetic replacement for v under g; ok is false for
+// NULL.
+func (s *synthesizer) value(g Generator, v string) (string, bool) {
+	switch g {
+	case GenKeep:
+		return v, true
+	case GenNull:
+		return "", false
+	case GenUUID:
+		r := s.stream(g, v)
+		b := make([]byte, 16)
+		for i := range b {
+			b[i] = r.byte()
+		}
+		b[6] = b[6]&0x0f | 0x40 // version 4
+		b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+		return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), true
+	case GenEmail:
+		local, _, _ := strings.Cut(v, "@")
+		return shape(s.stream(g, v), local) + "@example.com", true
+	case GenShape:
+	}
+	if strings.EqualFold(v, "true") || strings.EqualFold(v, "false") {
+		return v, true
+	}
+	r := s.stream(g, v)
+	if t, ok := shiftTime(r, v); ok {
+		return t, true
+	}
+	return shape(r, v), true
+}
+
+// timeLayouts are the date and timestamp forms GenShape shifts rather than
+// scrambles, as drivers send them.
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// shiftTime moves a date or timestamp by up to a year either way, keeping
+// its layout, so the result is still valid and close to the original.
+func shiftTime(r *stream, v string) (string, bool) {
+	for _, layout := range timeLayouts {
+		t, err := time.Parse(layout, v)
+		if err != nil {
+			continue
+		}
+		days := int(binary.BigEndian.Uint16([]byte{r.byte(), r.byte()}))%731 - 365
+		return t.AddDate(0, 0, days).Format(layout), true
+	}
+	return "", false
+}
+
+// shape replaces the letters and digits of v from r. A digit run keeps a
+// leading non-zero digit non-zero, so numbers keep their magnitude.
+func shape(r *stream, v string) string {
+	out := []byte(v)
+	for i, c := range out {
+		leading := i == 0 || !isDigit(out[i-1])
+		switch {
+		case c >= 'a' && c <= 'z':
+			out[i] = 'a' + r.byte()%26
+		case c >= 'A' && c <= 'Z':
+			out[i] = 'A' + r.byte()%26
+		case c == '0' && leading:
+		case isDigit(c) && leading:
+			out[i] = '1' + r.byte()%9
+		case isDigit(c):
+			out[i] = '0' + r.byte()%10
+		}
+	}
+	return string(out)
+}
+
+// stream is a deterministic byte sequence keyed by the seed.
+type stream struct {
+	key   []byte
+	block []byte
+	n     uint64
+}
+
+func (s *synthesizer) stream(g Generator, v string) *stream {
+	m := hmac.New(sha256.New, s.seed)
+	m.Write([]byte(g))
+	m.Write([]byte{0})
+	m.Write([]byte(v))
+	return &stream{key: m.Sum(nil)}
+}
+
+func (r *stream) byte() byte {
+	if len(r.block) == 0 {
+		m := hmac.New(sha256.New, r.key)
+		_ = binary.Write(m, binary.BigEndian, r.n)
+		r.block = m.Sum(nil)
+		r.n++
+	}
+	b := r.block[0]
+	r.block = r.block[1:]
+	return b
+}
+
+// argColumns returns the column each of a statement's n placeholders is
+// bound to, or "" where the statement does not say: INSERT column lists,
+// SET col = $1, and comparisons such as col = $1, col < ?, and col IN ($1,
+// $2). PostgreSQL $n placeholders and MySQL ? placeholders are both
+// understood.
+func argColumns(sql string, n int) []string {
+	cols := make([]string, n)
+	toks := tokenize(sql)
+	next := 0 // index of the next ? placeholder
+	var insertCols []string
+	depth, item := 0, 0 // within an INSERT's VALUES
+	inValues := false
+	for k, t := range toks {
+		switch {
+		case t.kind == tokIdent && strings.EqualFold(t.text, "INSERT"):
+			insertCols, inValues = insertColumns(toks[k:]), false
+		case t.kind == tokIdent && strings.EqualFold(t.text, "VALUES") && insertCols != nil:
+			inValues, depth = true, 0
+		case inValues && t.text == "(":
+			depth++
+			if depth == 1 {
+				item = 0
+			}
+		case inValues && t.text == ")":
+			depth--
+		case inValues && depth == 1 && t.text == ",":
+			item++
+		case inValues && depth == 0 && t.kind == tokIdent:
+			inValues = false // ON CONFLICT, RETURNING, and the like
+		}
+		if t.kind != tokParam {
+			continue
+		}
+		idx := next
+		if t.text != "?" {
+			p, err := strconv.Atoi(t.text[1:])
+			if err != nil {
+				continue
+			}
+			idx = p - 1
+		} else {
+			next++
+		}
+		if idx < 0 || idx >= n {
+			continue
+		}
+		col := ""
+		if inValues && depth == 1 && item < len(insertCols) && isItem(toks, k) {
+			col = insertCols[item]
+		} else {
+			col = comparedColumn(toks, k)
+		}
+		if col != "" && cols[idx] == "" {
+			cols[idx] = col
+		}
+	}
+	return cols
+}
+
+// insertColumns returns the column list of the INSERT starting toks, or
+// nil when it has none.
+func insertColumns(toks []token) []string {
+	k := 1
+	for k < len(toks) && toks[k].text != "(" {
+		if strings.EqualFold(toks[k].text, "VALUES") || strings.EqualFold(toks[k].text, "SELECT") {
+			return nil
+		}
+		k++
+	}
+	var cols []string
+	for k++; k < len(toks) && toks[k].text != ")"; k++ {
+		if toks[k].kind == tokIdent {
+			cols = append(cols, toks[k].text)
+		}
+	}
+	return cols
+}
+
+// isItem reports whether the placeholder at toks[k] is a whole item of a
+// VALUES row, not part of an expression.
+func isItem(toks []token, k int) bool {
+	before, after := toks[k-1].text, ""
+	if k+1 < len(toks) {
+		after = toks[k+1].text
+	}
+	return (before == "(" || before == ",") && (after == ")" || after == ",")
+}
+
+// comparisonOps precede a placeholder compared with, or assigned to, the
+// column before them.
+var comparisonOps = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, ">": true, "<=": true, ">=": true,
+	"LIKE": true, "ILIKE": true,
+}
+
+// comparedColumn returns the column the placeholder at toks[k] is compared
+// with, or "".
+func comparedColumn(toks []token, k int) string {
+	j := k - 1
+	// Walk back over the list of an IN (..., ...).
+	for j >= 1 && (toks[j].text == "," && toks[j-1].kind == tokParam) {
+		j -= 2
+	}
+	if j >= 1 && toks[j].text == "(" && strings.EqualFold(toks[j-1].text, "IN") {
+		j -= 2
+	} else if j >= 0 && comparisonOps[strings.ToUpper(toks[j].text)] {
+		j--
+	} else {
+		return ""
+	}
+	if j >= 0 && toks[j].kind == tokIdent {
+		return toks[j].text
+	}
+	return ""
+}
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota + 1
+	tokParam
+	tokOther
+)
+
+type token struct {
+	kind tokenKind
+	text string // unquoted for identifiers
+}
+
+// tokenize splits sql into identifiers, placeholders, and operator and
+// punctuation tokens, dropping literals, comments, and whitespace.
+func tokenize(sql string) []token {
+	var toks []token
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		j := i + 1
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		case c == '\'':
+			j = skipQuoted(sql, i, '\'')
+			toks = append(toks, token{kind: tokOther, text: "'"})
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			j = len(sql)
+			if k := strings.IndexByte(sql[i:], '\n'); k >= 0 {
+				j = i + k
+			}
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			j = len(sql)
+			if k := strings.Index(sql[i+2:], "*/"); k >= 0 {
+				j = i + k + 4
+			}
+		case c == '$' && j < len(sql) && isDigit(sql[j]):
+			for j < len(sql) && isDigit(sql[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokParam, text: sql[i:j]})
+		case c == '$':
+			j = skipDollar(sql, i)
+			toks = append(toks, token{kind: tokOther, text: "$"})
+		case c == '?':
+			toks = append(toks, token{kind: tokParam, text: "?"})
+		case c == '"' || c == '`':
+			j = skipQuoted(sql, i, c)
+			toks = append(toks, token{kind: tokIdent, text: strings.Trim(sql[i:j], string(c))})
+		case isIdentStart(c):
+			for j < len(sql) && isIdentPart(sql[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: sql[i:j]})
+		case strings.ContainsRune("<>!=", rune(c)):
+			for j < len(sql) && strings.ContainsRune("<>=", rune(sql[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokOther, text: sql[i:j]})
+		default:
+			toks = append(toks, token{kind: tokOther, text: sql[i:j]})
+		}
+		i = j
+	}
+	return toks
+}
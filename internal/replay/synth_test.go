@@ -0,0 +1,109 @@
+package replay_test
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/replay"
+)
+
+var writes = []export.Record{
+	{Op: "Execute", Query: "INSERT INTO users (id, email, name, born, active, token) VALUES ($1, $2, $3, $4, $5, $6)",
+		Args: []string{"42", "alice@corp.example", "Alice", "1990-04-01", "true", "s3cret"}},
+	{Op: "Execute", Query: "SELECT * FROM users u WHERE u.id = $1", Args: []string{"42"}},
+	{Op: "Exec", Query: "UPDATE users SET name = ? WHERE email = ? AND id IN (?, ?)",
+		Args: []string{"Bob", "alice@corp.example", "42", "7"}},
+}
+
+func synthesize(t *testing.T, seed string) [][]any {
+	t.Helper()
+	rules := []replay.Synth{
+		{Column: "*", Generator: replay.GenShape},
+		{Column: "email", Generator: replay.GenEmail},
+		{Column: "token", Generator: replay.GenNull},
+	}
+	e := &fakeExecutor{}
+	if _, err := replay.Run(t.Context(), e, writes, replay.WithWrites(), replay.WithSynthetic(seed, rules...)); err != nil {
+		t.Fatal(err)
+	}
+	return e.args
+}
+
+func TestRun_Synthetic(t *testing.T) {
+	t.Parallel()
+
+	args := synthesize(t, "seed")
+	insert, sel, update := args[0], args[1], args[2]
+
+	id, _ := insert[0].(string)
+	if id == "42" || !regexp.MustCompile(`^[1-9][0-9]$`).MatchString(id) {
+		t.Errorf("id = %q, want another two-digit number", id)
+	}
+	if sel[0] != id || update[2] != id {
+		t.Errorf("later statements got id %v and %v, want %q as written", sel[0], update[2], id)
+	}
+	email, _ := insert[1].(string)
+	if !strings.HasSuffix(email, "@example.com") || strings.HasPrefix(email, "alice") || update[1] != email {
+		t.Errorf("email = %q (then %v), want a stable address at example.com", email, update[1])
+	}
+	if name, _ := insert[2].(string); name == "Alice" || !regexp.MustCompile(`^[A-Z][a-z]{4}$`).MatchString(name) {
+		t.Errorf("name = %q, want another capitalized five-letter word", name)
+	}
+	born, _ := insert[3].(string)
+	if d, err := time.Parse("2006-01-02", born); err != nil || born == "1990-04-01" || d.Sub(time.Date(1990, 4, 1, 0, 0, 0, 0, time.UTC)).Abs() > 366*24*time.Hour {
+		t.Errorf("born = %q, want a nearby valid date", born)
+	}
+	if insert[4] != "true" || insert[5] != nil {
+		t.Errorf("active, token = %v, %v; want true kept and the token sent as NULL", insert[4], insert[5])
+	}
+
+	if again := synthesize(t, "seed"); !slices.EqualFunc(again, args, slices.Equal) {
+		t.Errorf("second run = %v, want %v", again, args)
+	}
+	if other := synthesize(t, "other"); other[0][0] == id && other[0][2] == insert[2] {
+		t.Error("a different seed gave the same values")
+	}
+}
+
+func TestRun_SyntheticKeepsUnlisted(t *testing.T) {
+	t.Parallel()
+
+	e := &fakeExecutor{}
+	rule := replay.Synth{Column: "email", Generator: replay.GenEmail}
+	if _, err := replay.Run(t.Context(), e, writes, replay.WithWrites(), replay.WithSynthetic("seed", rule)); err != nil {
+		t.Fatal(err)
+	}
+	if e.args[0][0] != "42" || e.args[0][2] != "Alice" || e.args[0][1] == "alice@corp.example" {
+		t.Errorf("args = %v, want only the email replaced", e.args[0])
+	}
+}
+
+func TestParseSynth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    replay.Synth
+		wantErr bool
+	}{
+		{in: "email=email", want: replay.Synth{Column: "email", Generator: replay.GenEmail}},
+		{in: "*=shape", want: replay.Synth{Column: "*", Generator: replay.GenShape}},
+		{in: "id=uuid", want: replay.Synth{Column: "id", Generator: replay.GenUUID}},
+		{in: "id=random", wantErr: true},
+		{in: "=shape", wantErr: true},
+		{in: "email", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			t.Parallel()
+			got, err := replay.ParseSynth(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("ParseSynth(%q) = %+v, %v; want %+v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+// Package report renders a snapshot of captured events, with their
+// aggregate statistics and query plans, as a self-contained Markdown or HTML
+// document for attaching to a bug ticket.
+package report
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Format selects the document type.
+type Format string
+
+const (
+	Markdown Format = "md"
+	HTML     Format = "html"
+)
+
+// Ext returns the conventional file extension for f, including the dot.
+func (f Format) Ext() string {
+	return "." + string(f)
+}
+
+const (
+	// MaxEvents is how many events a report lists, the newest; the
+	// statistics cover them all.
+	MaxEvents = 500
+	// maxFingerprints is how many fingerprints the statistics table ranks.
+	maxFingerprints = 20
+)
+
+// Plan is a query plan shown in a report.
+type Plan struct {
+	Query string
+	Plan  string
+	Note  string // where the plan came from, e.g. "EXPLAIN ANALYZE"
+}
+
+// Snapshot is what a report shows.
+type Snapshot struct {
+	Source string // the daemon the events came from
+	Taken  time.Time
+	Filter string // the search filter that selected Events; empty for all
+	Events []*tapv1.QueryEvent
+	Plans  []Plan // besides the auto-explain plans among Events
+}
+
+// Summary is the aggregate statistics of a snapshot's statements.
+type Summary struct {
+	Statements   int
+	Errors       int
+	Total        time.Duration
+	P50, P95     time.Duration
+	P99          time.Duration
+	First, Last  time.Time
+	Fingerprints []FingerprintStats // by total time, at most maxFingerprints
+}
+
+// FingerprintStats is the aggregate of one fingerprint's statements.
+type FingerprintStats struct {
+	Fingerprint string
+	Calls       int
+	Errors      int
+	Total       time.Duration
+	Mean        time.Duration
+	P95         time.Duration
+}
+
+// isStatement reports whether ev ran a statement, the events the
+// statistics cover.
+func isStatement(ev *tapv1.QueryEvent) bool {
+	switch proxy.Op(ev.GetOp()) {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+		return ev.GetQuery() != ""
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel, proxy.OpAdvisory,
+		proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+	}
+	return false
+}
+
+// Summarize aggregates the statements among events.
+func Summarize(events []*tapv1.QueryEvent) Summary {
+	var s Summary
+	var all []time.Duration
+	byFP := make(map[string][]*tapv1.QueryEvent)
+	for _, ev := range events {
+		if t := ev.GetStartTime(); t != nil {
+			at := t.AsTime()
+			if s.First.IsZero() || at.Before(s.First) {
+				s.First = at
+			}
+			if at.After(s.Last) {
+				s.Last = at
+			}
+		}
+		if !isStatement(ev) {
+			continue
+		}
+		d := ev.GetDuration().AsDuration()
+		s.Statements++
+		s.Total += d
+		if ev.GetError() != "" {
+			s.Errors++
+		}
+		all = append(all, d)
+		fp := ev.GetFingerprint()
+		if fp == "" { // from a daemon that predates fingerprinting
+			fp = query.Fingerprint(ev.GetQuery())
+		}
+		byFP[fp] = append(byFP[fp], ev)
+	}
+	slices.Sort(all)
+	s.P50, s.P95, s.P99 = quantile(all, 0.50), quantile(all, 0.95), quantile(all, 0.99)
+
+	for fp, evs := range byFP {
+		f := FingerprintStats{Fingerprint: fp, Calls: len(evs)}
+		ds := make([]time.Duration, len(evs))
+		for i, ev := range evs {
+			ds[i] = ev.GetDuration().AsDuration()
+			f.Total += ds[i]
+			if ev.GetError() != "" {
+				f.Errors++
+			}
+		}
+		slices.Sort(ds)
+		f.Mean = f.Total / time.Duration(f.Calls)
+		f.P95 = quantile(ds, 0.95)
+		s.Fingerprints = append(s.Fingerprints, f)
+	}
+	slices.SortFunc(s.Fingerprints, func(a, b FingerprintStats) int {
+		return cmp.Or(cmp.Compare(b.Total, a.Total), cmp.Compare(a.Fingerprint, b.Fingerprint))
+	})
+	if len(s.Fingerprints) > maxFingerprints {
+		s.Fingerprints = s.Fingerprints[:maxFingerprints]
+	}
+	return s
+}
+
+// quantile returns the q-th quantile of sorted, nearest rank.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// plans returns the snapshot's plans followed by the auto-explain plans
+// among its events.
+func (s Snapshot) plans() []Plan {
+	plans := slices.Clone(s.Plans)
+	for _, ev := range s.Events {
+		p := ev.GetPlan()
+		if p == nil || p.GetPlan() == "" {
+			continue
+		}
+		note := "auto-explain of a slow statement"
+		if p.GetAnalyze() {
+			note = "auto-explain (ANALYZE) of a slow statement"
+		}
+		plan := p.GetPlan()
+		if idx := p.GetIndexes(); len(idx) > 0 {
+			plan += "\n\nSuggested indexes:\n" + strings.Join(idx, "\n")
+		}
+		plans = append(plans, Plan{Query: ev.GetQuery(), Plan: plan, Note: note})
+	}
+	return plans
+}
+
+// listed returns the events a report lists, the newest MaxEvents, and how
+// many older ones it leaves out.
+func (s Snapshot) listed() ([]*tapv1.QueryEvent, int) {
+	if len(s.Events) <= MaxEvents {
+		return s.Events, 0
+	}
+	return s.Events[len(s.Events)-MaxEvents:], len(s.Events) - MaxEvents
+}
+
+// Write renders s to w as a document in format f.
+func Write(w io.Writer, f Format, s Snapshot) error {
+	var err error
+	if f == HTML {
+		err = writeHTML(w, s)
+	} else {
+		err = writeMarkdown(w, s)
+	}
+	if err != nil {
+		return fmt.Errorf("report: %w", err)
+	}
+	return nil
+}
+
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	case d >= time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d.Microseconds())/1000)
+	}
+	return fmt.Sprintf("%dµs", d.Microseconds())
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format("2006-01-02 15:04:05.000 MST")
+}
+
+func eventTime(ev *tapv1.QueryEvent) string {
+	if ev.GetStartTime() == nil {
+		return "-"
+	}
+	return ev.GetStartTime().AsTime().In(time.Local).Format("15:04:05.000")
+}
+
+// oneLine collapses the whitespace of a query.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func writeMarkdown(w io.Writer, s Snapshot) error {
+	var b strings.Builder
+	sum := Summarize(s.Events)
+	cell := func(v string) string {
+		return strings.ReplaceAll(oneLine(v), "|", `\|`)
+	}
+	// A code fence longer than any backtick run in the text.
+	fence := func(text string) string {
+		n := 3
+		for run := strings.Repeat("`", n); strings.Contains(text, run); run += "`" {
+			n++
+		}
+		return strings.Repeat("`", n)
+	}
+
+	fmt.Fprintf(&b, "# sql-tap snapshot\n\n")
+	fmt.Fprintf(&b, "- Source: %s\n- Taken: %s\n", cmp.Or(s.Source, "-"), formatTime(s.Taken))
+	if s.Filter != "" {
+		fmt.Fprintf(&b, "- Filter: `%s`\n", strings.ReplaceAll(s.Filter, "`", "'"))
+	}
+	fmt.Fprintf(&b, "- Events: %d, from %s to %s\n\n", len(s.Events), formatTime(sum.First), formatTime(sum.Last))
+
+	fmt.Fprintf(&b, "## Statistics\n\n")
+	fmt.Fprintf(&b, "%d statements, %d failed, %s in total; p50 %s, p95 %s, p99 %s.\n\n",
+		sum.Statements, sum.Errors, formatDuration(sum.Total),
+		formatDuration(sum.P50), formatDuration(sum.P95), formatDuration(sum.P99))
+	if len(sum.Fingerprints) > 0 {
+		b.WriteString("| Calls | Errors | Total | Mean | p95 | Fingerprint |\n")
+		b.WriteString("|------:|-------:|------:|-----:|----:|-------------|\n")
+		for _, f := range sum.Fingerprints {
+			fmt.Fprintf(&b, "| %d | %d | %s | %s | %s | %s |\n",
+				f.Calls, f.Errors, formatDuration(f.Total), formatDuration(f.Mean), formatDuration(f.P95), cell(f.Fingerprint))
+		}
+		b.WriteString("\n")
+	}
+
+	if plans := s.plans(); len(plans) > 0 {
+		b.WriteString("## Plans\n\n")
+		for _, p := range plans {
+			if p.Note != "" {
+				fmt.Fprintf(&b, "%s:\n\n", p.Note)
+			}
+			f := fence(p.Query)
+			fmt.Fprintf(&b, "%ssql\n%s\n%s\n\n", f, p.Query, f)
+			f = fence(p.Plan)
+			fmt.Fprintf(&b, "%s\n%s\n%s\n\n", f, p.Plan, f)
+		}
+	}
+
+	events, omitted := s.listed()
+	b.WriteString("## Events\n\n")
+	if omitted > 0 {
+		fmt.Fprintf(&b, "The newest %d; %d older events are left out.\n\n", len(events), omitted)
+	}
+	b.WriteString("| Time | Op | Duration | Rows | Query | Args | Error |\n")
+	b.WriteString("|------|----|---------:|-----:|-------|------|-------|\n")
+	for _, ev := range events {
+		fmt.Fprintf(&b, "| %s | %s | %s | %d | %s | %s | %s |\n",
+			eventTime(ev), proxy.Op(ev.GetOp()), formatDuration(ev.GetDuration().AsDuration()), ev.GetRowsAffected(),
+			cell(ev.GetQuery()), cell(strings.Join(ev.GetArgs(), ", ")), cell(ev.GetError()))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err //nolint:wrapcheck // wrapped by Write
+}
+
+// htmlEvent is an event as the HTML template shows it.
+type htmlEvent struct {
+	Time, Op, Duration string
+	Rows               int64
+	Query, Args, Error string
+}
+
+var htmlTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>sql-tap snapshot</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #1f2328; }
+table { border-collapse: collapse; margin-bottom: 1.5em; font-size: 0.9em; }
+th, td { border: 1px solid #d0d7de; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f6f8fa; }
+td.num { text-align: right; white-space: nowrap; }
+td.error { color: #cf222e; }
+code, pre { font-family: ui-monospace, Menlo, Consolas, monospace; }
+pre { background: #f6f8fa; padding: 0.8em; overflow-x: auto; }
+</style>
+</head>
+<body>
+<h1>sql-tap snapshot</h1>
+<ul>
+<li>Source: {{.Source}}</li>
+<li>Taken: {{.Taken}}</li>
+{{- if .Filter}}
+<li>Filter: <code>{{.Filter}}</code></li>
+{{- end}}
+<li>Events: {{.Count}}, from {{.First}} to {{.Last}}</li>
+</ul>
+<h2>Statistics</h2>
+<p>{{.Summary.Statements}} statements, {{.Summary.Errors}} failed, {{.Total}} in total; p50 {{.P50}}, p95 {{.P95}}, p99 {{.P99}}.</p>
+{{- if .Fingerprints}}
+<table>
+<tr><th>Calls</th><th>Errors</th><th>Total</th><th>Mean</th><th>p95</th><th>Fingerprint</th></tr>
+{{- range .Fingerprints}}
+<tr><td class="num">{{.Calls}}</td><td class="num">{{.Errors}}</td><td class="num">{{.Total}}</td><td class="num">{{.Mean}}</td><td class="num">{{.P95}}</td><td><code>{{.Fingerprint}}</code></td></tr>
+{{- end}}
+</table>
+{{- end}}
+{{- if .Plans}}
+<h2>Plans</h2>
+{{- range .Plans}}
+{{- if .Note}}
+<p>{{.Note}}:</p>
+{{- end}}
+<pre>{{.Query}}</pre>
+<pre>{{.Plan}}</pre>
+{{- end}}
+{{- end}}
+<h2>Events</h2>
+{{- if .Omitted}}
+<p>The newest {{len .Events}}; {{.Omitted}} older events are left out.</p>
+{{- end}}
+<table>
+<tr><th>Time</th><th>Op</th><th>Duration</th><th>Rows</th><th>Query</th><th>Args</th><th>Error</th></tr>
+{{- range .Events}}
+<tr><td class="num">{{.Time}}</td><td>{{.Op}}</td><td class="num">{{.Duration}}</td><td class="num">{{.Rows}}</td><td><code>{{.Query}}</code></td><td>{{.Args}}</td><td class="error">{{.Error}}</td></tr>
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// htmlFingerprint is a fingerprint's statistics as the HTML template shows
+// them.
+type htmlFingerprint struct {
+	Calls, Errors    int
+	Total, Mean, P95 string
+	Fingerprint      string
+}
+
+func writeHTML(w io.Writer, s Snapshot) error {
+	sum := Summarize(s.Events)
+	events, omitted := s.listed()
+	data := struct {
+		Source, Taken, Filter string
+		Count                 int
+		First, Last           string
+		Summary               Summary
+		Total, P50, P95, P99  string
+		Fingerprints          []htmlFingerprint
+		Plans                 []Plan
+		Events                []htmlEvent
+		Omitted               int
+	}{
+		Source:  cmp.Or(s.Source, "-"),
+		Taken:   formatTime(s.Taken),
+		Filter:  s.Filter,
+		Count:   len(s.Events),
+		First:   formatTime(sum.First),
+		Last:    formatTime(sum.Last),
+		Summary: sum,
+		Total:   formatDuration(sum.Total),
+		P50:     formatDuration(sum.P50),
+		P95:     formatDuration(sum.P95),
+		P99:     formatDuration(sum.P99),
+		Plans:   s.plans(),
+		Omitted: omitted,
+	}
+	for _, f := range sum.Fingerprints {
+		data.Fingerprints = append(data.Fingerprints, htmlFingerprint{
+			Calls: f.Calls, Errors: f.Errors,
+			Total: formatDuration(f.Total), Mean: formatDuration(f.Mean), P95: formatDuration(f.P95),
+			Fingerprint: f.Fingerprint,
+		})
+	}
+	for _, ev := range events {
+		data.Events = append(data.Events, htmlEvent{
+			Time:     eventTime(ev),
+			Op:       proxy.Op(ev.GetOp()).String(),
+			Duration: formatDuration(ev.GetDuration().AsDuration()),
+			Rows:     ev.GetRowsAffected(),
+			Query:    oneLine(ev.GetQuery()),
+			Args:     strings.Join(ev.GetArgs(), ", "),
+			Error:    ev.GetError(),
+		})
+	}
+	return htmlTemplate.Execute(w, data) //nolint:wrapcheck // wrapped by Write
+}
@@ -0,0 +1,153 @@
+package report_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/report"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func event(op proxy.Op, q string, d time.Duration, at time.Time) *tapv1.QueryEvent {
+	return &tapv1.QueryEvent{
+		Op:        int32(op),
+		Query:     q,
+		StartTime: timestamppb.New(at),
+		Duration:  durationpb.New(d),
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	base := time.Unix(1700000000, 0)
+	failed := event(proxy.OpExec, "UPDATE t SET x = 2", 10*time.Millisecond, base.Add(3*time.Second))
+	failed.Error = "deadlock detected"
+	events := []*tapv1.QueryEvent{
+		event(proxy.OpBegin, "BEGIN", 0, base),
+		event(proxy.OpQuery, "SELECT * FROM t WHERE id = 1", time.Millisecond, base.Add(time.Second)),
+		event(proxy.OpQuery, "SELECT * FROM t WHERE id = 2", 3*time.Millisecond, base.Add(2*time.Second)),
+		failed,
+		event(proxy.OpCommit, "COMMIT", 0, base.Add(4*time.Second)),
+	}
+
+	s := report.Summarize(events)
+	if s.Statements != 3 || s.Errors != 1 || s.Total != 14*time.Millisecond {
+		t.Errorf("Statements, Errors, Total = %d, %d, %v, want 3, 1, 14ms", s.Statements, s.Errors, s.Total)
+	}
+	if s.P50 != 3*time.Millisecond || s.P99 != 10*time.Millisecond {
+		t.Errorf("P50, P99 = %v, %v, want 3ms, 10ms", s.P50, s.P99)
+	}
+	if !s.First.Equal(base) || !s.Last.Equal(base.Add(4*time.Second)) {
+		t.Errorf("First, Last = %v, %v", s.First, s.Last)
+	}
+	if len(s.Fingerprints) != 2 {
+		t.Fatalf("Fingerprints = %+v, want 2", s.Fingerprints)
+	}
+	if f := s.Fingerprints[0]; f.Calls != 1 || f.Errors != 1 || !strings.HasPrefix(f.Fingerprint, "UPDATE") {
+		t.Errorf("Fingerprints[0] = %+v, want the update", f)
+	}
+	if f := s.Fingerprints[1]; f.Calls != 2 || f.Mean != 2*time.Millisecond || f.P95 != 3*time.Millisecond {
+		t.Errorf("Fingerprints[1] = %+v, want the select twice", f)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+
+	base := time.Unix(1700000000, 0)
+	slow := event(proxy.OpQuery, "SELECT * FROM orders WHERE note = '<b>|x'", 2*time.Second, base)
+	slow.Args = []string{"42"}
+	advisory := event(proxy.OpAdvisory, "SELECT * FROM orders WHERE note = '<b>|x'", 0, base.Add(time.Second))
+	advisory.Plan = &tapv1.AutoPlan{Plan: "Seq Scan on orders", Indexes: []string{"CREATE INDEX ON orders (note)"}}
+	snap := report.Snapshot{
+		Source: "localhost:9091",
+		Taken:  base.Add(time.Minute),
+		Filter: "orders",
+		Events: []*tapv1.QueryEvent{slow, advisory},
+		Plans:  []report.Plan{{Query: "SELECT 1", Plan: "Result", Note: "EXPLAIN"}},
+	}
+
+	tests := []struct {
+		format report.Format
+		want   []string
+		absent []string
+	}{
+		{
+			format: report.Markdown,
+			want: []string{
+				"# sql-tap snapshot",
+				"- Source: localhost:9091",
+				"- Filter: `orders`",
+				"1 statements, 0 failed, 2.00s in total",
+				`'<b>\|x'`,
+				"EXPLAIN:",
+				"```\nResult\n```",
+				"auto-explain of a slow statement:",
+				"Seq Scan on orders\n\nSuggested indexes:\nCREATE INDEX ON orders (note)",
+				"| Query | 2.00s | 0 |",
+			},
+		},
+		{
+			format: report.HTML,
+			want: []string{
+				"<!DOCTYPE html>",
+				"<li>Filter: <code>orders</code></li>",
+				"&lt;b&gt;|x",
+				"<pre>Result</pre>",
+				"<td>Advisory</td>",
+			},
+			absent: []string{"<b>|x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			t.Parallel()
+
+			var b strings.Builder
+			if err := report.Write(&b, tt.format, snap); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(b.String(), want) {
+					t.Errorf("report lacks %q:\n%s", want, b.String())
+				}
+			}
+			for _, absent := range tt.absent {
+				if strings.Contains(b.String(), absent) {
+					t.Errorf("report contains %q:\n%s", absent, b.String())
+				}
+			}
+		})
+	}
+}
+
+func TestWrite_MaxEvents(t *testing.T) {
+	t.Parallel()
+
+	base := time.Unix(1700000000, 0)
+	events := make([]*tapv1.QueryEvent, report.MaxEvents+5)
+	for i := range events {
+		events[i] = event(proxy.OpQuery, "SELECT 1", time.Millisecond, base.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	var b strings.Builder
+	if err := report.Write(&b, report.Markdown, report.Snapshot{Events: events}); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !strings.Contains(b.String(), "505 statements") {
+		t.Error("statistics should cover every event")
+	}
+	if !strings.Contains(b.String(), "The newest 500; 5 older events are left out.") {
+		t.Errorf("report should note the events left out:\n%s", b.String()[:500])
+	}
+	if got := strings.Count(b.String(), "| Query | 1.00ms |"); got != report.MaxEvents {
+		t.Errorf("listed %d events, want %d", got, report.MaxEvents)
+	}
+}
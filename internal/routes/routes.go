@@ -0,0 +1,240 @@
+// Package routes aggregates query statistics per HTTP route, as tagged by
+// the sqlcomment package's middleware and extracted into proxy.Event.Route
+// by the proxies. It answers which endpoints issue the most, the slowest, or
+// the most failing queries.
+//
+// Queries that also carry a request ID are grouped into requests, giving
+// each route a distribution of queries per request. Routes whose requests
+// run more queries than a budget are flagged, as they are likely issuing N+1
+// queries.
+package routes
+
+import (
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/stats"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// The statistics cover Window, in Buckets steps of Resolution.
+const (
+	Resolution = 10 * time.Second
+	Buckets    = 30
+	Window     = Resolution * Buckets
+)
+
+// DefaultQueryBudget is the queries per request above which a request is
+// over budget.
+const DefaultQueryBudget = 20
+
+const (
+	// requestIdle is how long after its last query a request is taken to
+	// have finished.
+	requestIdle = 2 * time.Second
+
+	// maxOpenRequests, maxFingerprintsPerRequest, and maxRequestsPerRoute
+	// bound memory. New requests beyond maxOpenRequests are not counted, and
+	// the oldest finished requests of a route are dropped first.
+	maxOpenRequests           = 10000
+	maxFingerprintsPerRequest = 100
+	maxRequestsPerRoute       = 1000
+)
+
+// Route is the statistics of one route's queries over Window.
+type Route struct {
+	Route string
+	stats.Summary
+
+	// Requests finished in the window and the queries each ran.
+	Requests   int
+	QueriesP50 int
+	QueriesP95 int
+	QueriesMax int
+	OverBudget int // requests that ran more than the budget
+
+	// The fingerprint the heaviest request ran most, and how often.
+	TopFingerprint string
+	TopCalls       int
+}
+
+// Flagged reports whether any request of the route went over budget.
+func (r Route) Flagged() bool {
+	return r.OverBudget > 0
+}
+
+// Option configures a Tracker.
+type Option func(*Tracker)
+
+// WithQueryBudget sets the queries per request above which a request is
+// over budget.
+func WithQueryBudget(n int) Option {
+	return func(t *Tracker) {
+		t.budget = n
+	}
+}
+
+// Tracker aggregates the queries of routed events. It is safe for concurrent
+// use.
+type Tracker struct {
+	mu       sync.Mutex
+	agg      *stats.Aggregator
+	budget   int
+	open     map[string]*request // by request ID
+	finished map[string][]finished
+}
+
+type request struct {
+	route   string
+	queries int
+	last    time.Time
+	calls   map[string]int // by fingerprint
+}
+
+type finished struct {
+	at       time.Time
+	queries  int
+	topFP    string
+	topCalls int
+}
+
+// New returns an empty Tracker.
+func New(opts ...Option) *Tracker {
+	t := &Tracker{
+		agg:      stats.New(Resolution, Buckets),
+		budget:   DefaultQueryBudget,
+		open:     make(map[string]*request),
+		finished: make(map[string][]finished),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// QueryBudget returns the queries per request above which a request is over
+// budget.
+func (t *Tracker) QueryBudget() int {
+	return t.budget
+}
+
+// Observe records ev under its route, at now. Events without a route and
+// lifecycle events are ignored.
+func (t *Tracker) Observe(ev proxy.Event, now time.Time) {
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	default:
+		return
+	}
+	if ev.Route == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.agg.Observe(ev.Route, now, ev.Duration, ev.Error != "")
+	if ev.RequestID == "" {
+		return
+	}
+
+	r, ok := t.open[ev.RequestID]
+	if !ok {
+		if len(t.open) >= maxOpenRequests {
+			t.finish(now)
+		}
+		if len(t.open) >= maxOpenRequests {
+			return
+		}
+		r = &request{route: ev.Route, calls: make(map[string]int)}
+		t.open[ev.RequestID] = r
+	}
+	r.queries++
+	r.last = now
+	fp := ev.Fingerprint
+	if fp == "" {
+		fp = query.Fingerprint(ev.Query)
+	}
+	if _, ok := r.calls[fp]; ok || len(r.calls) < maxFingerprintsPerRequest {
+		r.calls[fp]++
+	}
+}
+
+// finish moves the requests idle since before now-requestIdle to finished.
+func (t *Tracker) finish(now time.Time) {
+	for id, r := range t.open {
+		if now.Sub(r.last) < requestIdle {
+			continue
+		}
+		delete(t.open, id)
+		f := finished{at: r.last, queries: r.queries}
+		for fp, n := range r.calls {
+			if n > f.topCalls || (n == f.topCalls && fp < f.topFP) {
+				f.topFP, f.topCalls = fp, n
+			}
+		}
+		done := append(t.finished[r.route], f)
+		if len(done) > maxRequestsPerRoute {
+			done = slices.Delete(done, 0, len(done)-maxRequestsPerRoute)
+		}
+		t.finished[r.route] = done
+	}
+}
+
+// Routes returns the routes with queries in the window ending at now,
+// busiest first.
+func (t *Tracker) Routes(now time.Time) []Route {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.finish(now)
+	since := now.Add(-Window)
+	for route, done := range t.finished {
+		done = slices.DeleteFunc(done, func(f finished) bool { return f.at.Before(since) })
+		if len(done) == 0 {
+			delete(t.finished, route)
+			continue
+		}
+		t.finished[route] = done
+	}
+
+	keys := t.agg.Keys(now)
+	out := make([]Route, len(keys))
+	for i, k := range keys {
+		out[i] = t.route(k)
+	}
+	return out
+}
+
+func (t *Tracker) route(k stats.KeySummary) Route {
+	r := Route{Route: k.Key, Summary: k.Summary}
+	done := t.finished[k.Key]
+	if len(done) == 0 {
+		return r
+	}
+	counts := make([]int, len(done))
+	heaviest := done[0]
+	for i, f := range done {
+		counts[i] = f.queries
+		if f.queries > t.budget {
+			r.OverBudget++
+		}
+		if f.queries > heaviest.queries {
+			heaviest = f
+		}
+	}
+	slices.Sort(counts)
+	r.Requests = len(counts)
+	r.QueriesP50 = counts[quantileIndex(len(counts), 0.50)]
+	r.QueriesP95 = counts[quantileIndex(len(counts), 0.95)]
+	r.QueriesMax = counts[len(counts)-1]
+	r.TopFingerprint, r.TopCalls = heaviest.topFP, heaviest.topCalls
+	return r
+}
+
+// quantileIndex is the nearest-rank index of quantile q among n sorted
+// values.
+func quantileIndex(n int, q float64) int {
+	return min(max(int(math.Ceil(q*float64(n)))-1, 0), n-1)
+}
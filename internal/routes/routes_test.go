@@ -0,0 +1,81 @@
+package routes_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/routes"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tr := routes.New()
+	for i := range 3 {
+		tr.Observe(proxy.Event{Op: proxy.OpQuery, Route: "GET /users/{id}", Duration: time.Duration(i+1) * time.Millisecond}, now)
+	}
+	tr.Observe(proxy.Event{Op: proxy.OpExec, Route: "POST /orders", Error: "deadlock"}, now)
+	tr.Observe(proxy.Event{Op: proxy.OpQuery}, now)                        // no route
+	tr.Observe(proxy.Event{Op: proxy.OpBegin, Route: "POST /orders"}, now) // lifecycle
+
+	got := tr.Routes(now)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 routes, got %+v", got)
+	}
+	if got[0].Route != "GET /users/{id}" || got[0].Count != 3 || got[0].P50 != 2*time.Millisecond {
+		t.Errorf("unexpected busiest route: %+v", got[0])
+	}
+	if got[1].Route != "POST /orders" || got[1].Count != 1 || got[1].Errors != 1 {
+		t.Errorf("unexpected second route: %+v", got[1])
+	}
+
+	if got := tr.Routes(now.Add(routes.Window)); len(got) != 0 {
+		t.Errorf("expected the window to have moved past every route, got %+v", got)
+	}
+}
+
+func TestTracker_QueriesPerRequest(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	tr := routes.New(routes.WithQueryBudget(5))
+	query := func(route, req, q string) {
+		tr.Observe(proxy.Event{Op: proxy.OpQuery, Route: route, RequestID: req, Query: q}, now)
+	}
+	// GET /orders loads each order's items one by one.
+	for i := range 3 {
+		req := string(rune('a' + i))
+		query("GET /orders", req, "SELECT * FROM orders")
+		for range 2 + 4*i {
+			query("GET /orders", req, "SELECT * FROM items WHERE order_id = 1")
+		}
+	}
+	query("GET /health", "h", "SELECT 1")
+
+	// Requests still running are not counted yet.
+	if got := tr.Routes(now); got[0].Requests != 0 {
+		t.Fatalf("expected no finished requests, got %+v", got[0])
+	}
+
+	got := tr.Routes(now.Add(time.Minute))
+	if len(got) != 2 {
+		t.Fatalf("expected 2 routes, got %+v", got)
+	}
+	orders := got[0]
+	if orders.Route != "GET /orders" || orders.Requests != 3 {
+		t.Fatalf("unexpected route: %+v", orders)
+	}
+	// 3, 7, and 11 queries.
+	if orders.QueriesP50 != 7 || orders.QueriesP95 != 11 || orders.QueriesMax != 11 || orders.OverBudget != 2 || !orders.Flagged() {
+		t.Errorf("unexpected distribution: p50 %d p95 %d max %d over %d",
+			orders.QueriesP50, orders.QueriesP95, orders.QueriesMax, orders.OverBudget)
+	}
+	if orders.TopFingerprint != "SELECT * FROM items WHERE order_id = ?" || orders.TopCalls != 10 {
+		t.Errorf("unexpected top fingerprint: %q x%d", orders.TopFingerprint, orders.TopCalls)
+	}
+	if health := got[1]; health.Requests != 1 || health.Flagged() {
+		t.Errorf("unexpected route: %+v", health)
+	}
+}
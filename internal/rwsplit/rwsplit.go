@@ -0,0 +1,156 @@
+// Package rwsplit classifies statements as reads or writes and keeps rolling
+// statistics of each class, overall and per connection: how much of a
+// workload a read replica could take, and, with replica routing on, how much
+// it does.
+package rwsplit
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/stats"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Class is the kind of a statement.
+type Class int
+
+const (
+	// Read is a statement that cannot write, as query.ReadOnly decides.
+	Read Class = iota
+	// Write is everything else: DML, DDL, and statements the classifier
+	// cannot vouch for.
+	Write
+)
+
+func (c Class) String() string {
+	if c == Read {
+		return "read"
+	}
+	return "write"
+}
+
+// Classify returns the class of a statement run as op, and false for events
+// that do not run one.
+func Classify(op proxy.Op, q string) (Class, bool) {
+	switch op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel, proxy.OpAdvisory,
+		proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+		return 0, false
+	}
+	if q == "" {
+		return 0, false
+	}
+	if query.ReadOnly(q) {
+		return Read, true
+	}
+	return Write, true
+}
+
+// Split is the traffic of each class in a window.
+type Split struct {
+	Reads  stats.Summary
+	Writes stats.Summary
+	// ReplicaReads and ReplicaWrites count the statements the proxy routed
+	// to a read replica. Writes there mean the router misjudged them.
+	ReplicaReads  int
+	ReplicaWrites int
+}
+
+// Total returns the number of statements of either class.
+func (s Split) Total() int {
+	return s.Reads.Count + s.Writes.Count
+}
+
+// ReadShare returns the fraction of statements that were reads, 0 to 1.
+func (s Split) ReadShare() float64 {
+	if s.Total() == 0 {
+		return 0
+	}
+	return float64(s.Reads.Count) / float64(s.Total())
+}
+
+// ConnSplit is the split of one connection.
+type ConnSplit struct {
+	ConnID string
+	Split
+}
+
+// Tracker keeps the split of the last window of statements. It is not safe
+// for concurrent use.
+type Tracker struct {
+	classes [2]*stats.Aggregator // by Class, keyed by connection
+	replica [2]*stats.Aggregator // the replica-routed subset of classes
+}
+
+// New returns a Tracker over a window of buckets intervals of resolution.
+func New(resolution time.Duration, buckets int) *Tracker {
+	t := &Tracker{}
+	for i := range t.classes {
+		t.classes[i] = stats.New(resolution, buckets)
+		t.replica[i] = stats.New(resolution, buckets)
+	}
+	return t
+}
+
+// Observe records ev at time at, if it ran a statement.
+func (t *Tracker) Observe(ev *tapv1.QueryEvent, at time.Time) {
+	c, ok := Classify(proxy.Op(ev.GetOp()), ev.GetQuery())
+	if !ok {
+		return
+	}
+	d, failed := ev.GetDuration().AsDuration(), ev.GetError() != ""
+	t.classes[c].Observe(ev.GetConnId(), at, d, failed)
+	if ev.GetRouting().GetReplica() {
+		t.replica[c].Observe(ev.GetConnId(), at, d, failed)
+	}
+}
+
+// Overall returns the split of all connections as of now.
+func (t *Tracker) Overall(now time.Time) Split {
+	return Split{
+		Reads:         t.classes[Read].Overall(now),
+		Writes:        t.classes[Write].Overall(now),
+		ReplicaReads:  t.replica[Read].Overall(now).Count,
+		ReplicaWrites: t.replica[Write].Overall(now).Count,
+	}
+}
+
+// Conns returns the split of each connection seen in the window as of now,
+// busiest first.
+func (t *Tracker) Conns(now time.Time) []ConnSplit {
+	byConn := make(map[string]*ConnSplit)
+	conn := func(id string) *ConnSplit {
+		cs, ok := byConn[id]
+		if !ok {
+			cs = &ConnSplit{ConnID: id}
+			byConn[id] = cs
+		}
+		return cs
+	}
+	for _, k := range t.classes[Read].Keys(now) {
+		conn(k.Key).Reads = k.Summary
+	}
+	for _, k := range t.classes[Write].Keys(now) {
+		conn(k.Key).Writes = k.Summary
+	}
+	for _, k := range t.replica[Read].Keys(now) {
+		conn(k.Key).ReplicaReads = k.Count
+	}
+	for _, k := range t.replica[Write].Keys(now) {
+		conn(k.Key).ReplicaWrites = k.Count
+	}
+
+	out := make([]ConnSplit, 0, len(byConn))
+	for _, cs := range byConn {
+		out = append(out, *cs)
+	}
+	slices.SortFunc(out, func(a, b ConnSplit) int {
+		return cmp.Or(cmp.Compare(b.Total(), a.Total()), cmp.Compare(a.ConnID, b.ConnID))
+	})
+	return out
+}
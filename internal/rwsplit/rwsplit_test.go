@@ -0,0 +1,101 @@
+package rwsplit_test
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/rwsplit"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestClassify(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		op    proxy.Op
+		query string
+		want  rwsplit.Class
+		ok    bool
+	}{
+		{name: "select", op: proxy.OpQuery, query: "SELECT * FROM users", want: rwsplit.Read, ok: true},
+		{name: "show", op: proxy.OpExec, query: "SHOW search_path", want: rwsplit.Read, ok: true},
+		{name: "insert", op: proxy.OpExec, query: "INSERT INTO users VALUES (1)", want: rwsplit.Write, ok: true},
+		{name: "ddl", op: proxy.OpExec, query: "CREATE TABLE t (id int)", want: rwsplit.Write, ok: true},
+		{name: "writing cte", op: proxy.OpQuery, query: "WITH d AS (DELETE FROM t RETURNING *) SELECT * FROM d", want: rwsplit.Write, ok: true},
+		{name: "select for update", op: proxy.OpExecute, query: "SELECT * FROM t FOR UPDATE", want: rwsplit.Write, ok: true},
+		{name: "execute", op: proxy.OpExecute, query: "SELECT $1", want: rwsplit.Read, ok: true},
+		{name: "empty", op: proxy.OpQuery},
+		{name: "prepare", op: proxy.OpPrepare, query: "SELECT 1"},
+		{name: "begin", op: proxy.OpBegin, query: "BEGIN"},
+		{name: "connect", op: proxy.OpConnect},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := rwsplit.Classify(tt.op, tt.query)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("Classify = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestTracker(t *testing.T) {
+	t.Parallel()
+
+	tr := rwsplit.New(time.Second, 10)
+	base := time.Unix(1700000000, 0)
+	observe := func(conn, q string, d time.Duration, replica bool) {
+		ev := &tapv1.QueryEvent{
+			ConnId:   conn,
+			Op:       int32(proxy.OpQuery),
+			Query:    q,
+			Duration: durationpb.New(d),
+		}
+		if replica {
+			ev.Routing = &tapv1.Routing{Replica: true}
+		}
+		tr.Observe(ev, base)
+	}
+	for range 3 {
+		observe("a", "SELECT 1", time.Millisecond, true)
+	}
+	observe("a", "UPDATE t SET x = 1", 5*time.Millisecond, false)
+	observe("b", "DELETE FROM t", 2*time.Millisecond, true)
+	tr.Observe(&tapv1.QueryEvent{ConnId: "b", Op: int32(proxy.OpBegin), Query: "BEGIN"}, base)
+
+	s := tr.Overall(base)
+	if s.Reads.Count != 3 || s.Writes.Count != 2 {
+		t.Fatalf("Reads, Writes = %d, %d, want 3, 2", s.Reads.Count, s.Writes.Count)
+	}
+	if s.ReadShare() != 0.6 {
+		t.Errorf("ReadShare = %v, want 0.6", s.ReadShare())
+	}
+	if s.ReplicaReads != 3 || s.ReplicaWrites != 1 {
+		t.Errorf("ReplicaReads, ReplicaWrites = %d, %d, want 3, 1", s.ReplicaReads, s.ReplicaWrites)
+	}
+	if s.Writes.P99 != 5*time.Millisecond {
+		t.Errorf("Writes.P99 = %v, want 5ms", s.Writes.P99)
+	}
+
+	conns := tr.Conns(base)
+	if len(conns) != 2 || conns[0].ConnID != "a" || conns[1].ConnID != "b" {
+		t.Fatalf("Conns = %+v, want a then b", conns)
+	}
+	if a := conns[0]; a.Reads.Count != 3 || a.Writes.Count != 1 || a.ReplicaReads != 3 || a.ReplicaWrites != 0 {
+		t.Errorf("conn a = %+v", a)
+	}
+	if b := conns[1]; b.Reads.Count != 0 || b.Writes.Count != 1 || b.ReplicaWrites != 1 {
+		t.Errorf("conn b = %+v", b)
+	}
+
+	if s := tr.Overall(base.Add(time.Minute)); s.Total() != 0 || s.ReadShare() != 0 {
+		t.Errorf("after the window: Total, ReadShare = %d, %v, want 0, 0", s.Total(), s.ReadShare())
+	}
+}
@@ -0,0 +1,150 @@
+// Package sample thins out the event stream from busy databases: keep a
+// random fraction of events, cap events per query fingerprint, or cap events
+// overall, per second.
+package sample
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Config selects which events a Sampler keeps. Zero fields disable their
+// rule; a zero Config keeps everything.
+type Config struct {
+	Rate           float64 // keep each event with this probability, in (0, 1]
+	PerFingerprint int     // keep at most this many events per query fingerprint per second
+	MaxPerSecond   int     // keep at most this many events per second overall
+}
+
+// Enabled reports whether any rule is set.
+func (c Config) Enabled() bool {
+	return (c.Rate > 0 && c.Rate < 1) || c.PerFingerprint > 0 || c.MaxPerSecond > 0
+}
+
+func (c Config) String() string {
+	var parts []string
+	if c.Rate > 0 && c.Rate < 1 {
+		parts = append(parts, "rate="+strconv.FormatFloat(c.Rate, 'g', -1, 64))
+	}
+	if c.PerFingerprint > 0 {
+		parts = append(parts, "per-fingerprint="+strconv.Itoa(c.PerFingerprint))
+	}
+	if c.MaxPerSecond > 0 {
+		parts = append(parts, "max-per-second="+strconv.Itoa(c.MaxPerSecond))
+	}
+	return strings.Join(parts, ",")
+}
+
+// Proto converts c for a WatchRequest, returning nil when no rule is set.
+func (c Config) Proto() *tapv1.Sampling {
+	if !c.Enabled() {
+		return nil
+	}
+	return &tapv1.Sampling{
+		Rate:           c.Rate,
+		PerFingerprint: int32(c.PerFingerprint), //nolint:gosec // per-second limits stay far below MaxInt32
+		MaxPerSecond:   int32(c.MaxPerSecond),   //nolint:gosec // per-second limits stay far below MaxInt32
+	}
+}
+
+// Parse parses "rate=<0..1>,per-fingerprint=<n>,max-per-second=<n>", with
+// every key optional. An empty spec disables sampling.
+func Parse(spec string) (Config, error) {
+	var c Config
+	if strings.TrimSpace(spec) == "" {
+		return c, nil
+	}
+	for kv := range strings.SplitSeq(spec, ",") {
+		key, val, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		if !ok {
+			return Config{}, fmt.Errorf("sample: invalid option %q (want key=value)", kv)
+		}
+		var err error
+		switch key {
+		case "rate":
+			c.Rate, err = strconv.ParseFloat(val, 64)
+			if err != nil || c.Rate <= 0 || c.Rate > 1 {
+				return Config{}, fmt.Errorf("sample: rate %q must be a number in (0, 1]", val)
+			}
+		case "per-fingerprint":
+			c.PerFingerprint, err = parseLimit(key, val)
+		case "max-per-second":
+			c.MaxPerSecond, err = parseLimit(key, val)
+		default:
+			return Config{}, fmt.Errorf("sample: unknown option %q", key)
+		}
+		if err != nil {
+			return Config{}, err
+		}
+	}
+	return c, nil
+}
+
+func parseLimit(key, val string) (int, error) {
+	n, err := strconv.Atoi(val)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("sample: %s %q must be a positive integer", key, val)
+	}
+	return n, nil
+}
+
+// Sampler applies a Config to events. Keep is not safe for concurrent use;
+// Shed is.
+type Sampler struct {
+	cfg    Config
+	window time.Time // start of the current one-second window
+	total  int
+	perFP  map[string]int
+	shed   atomic.Uint64
+}
+
+// New returns a Sampler for cfg.
+func New(cfg Config) *Sampler {
+	return &Sampler{cfg: cfg, perFP: make(map[string]int)}
+}
+
+// Keep reports whether ev, seen at now, passes the sampling rules. Failed
+// queries always pass: they are rare and what you are usually looking for.
+func (s *Sampler) Keep(ev proxy.Event, now time.Time) bool {
+	if ev.Error != "" || !s.cfg.Enabled() {
+		return true
+	}
+	if s.cfg.Rate > 0 && s.cfg.Rate < 1 && rand.Float64() >= s.cfg.Rate { //nolint:gosec // sampling, not security
+		s.shed.Add(1)
+		return false
+	}
+
+	if now.Sub(s.window) >= time.Second {
+		s.window = now.Truncate(time.Second)
+		s.total = 0
+		clear(s.perFP)
+	}
+	if s.cfg.MaxPerSecond > 0 && s.total >= s.cfg.MaxPerSecond {
+		s.shed.Add(1)
+		return false
+	}
+	if s.cfg.PerFingerprint > 0 {
+		fp := ev.Fingerprint
+		if fp == "" {
+			fp = query.Fingerprint(ev.Query)
+		}
+		if s.perFP[fp] >= s.cfg.PerFingerprint {
+			s.shed.Add(1)
+			return false
+		}
+		s.perFP[fp]++
+	}
+	s.total++
+	return true
+}
+
+// Shed returns how many events Keep has rejected.
+func (s *Sampler) Shed() uint64 { return s.shed.Load() }
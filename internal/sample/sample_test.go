@@ -0,0 +1,110 @@
+package sample_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/sample"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		spec    string
+		want    sample.Config
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: sample.Config{}},
+		{name: "all", spec: "rate=0.25, per-fingerprint=5,max-per-second=100", want: sample.Config{Rate: 0.25, PerFingerprint: 5, MaxPerSecond: 100}},
+		{name: "rate out of range", spec: "rate=1.5", wantErr: true},
+		{name: "zero limit", spec: "max-per-second=0", wantErr: true},
+		{name: "unknown key", spec: "burst=3", wantErr: true},
+		{name: "missing value", spec: "rate", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := sample.Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_String(t *testing.T) {
+	t.Parallel()
+
+	c := sample.Config{Rate: 0.1, MaxPerSecond: 50}
+	if got, want := c.String(), "rate=0.1,max-per-second=50"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestSampler_MaxPerSecond(t *testing.T) {
+	t.Parallel()
+
+	s := sample.New(sample.Config{MaxPerSecond: 2})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	ev := proxy.Event{Query: "SELECT 1"}
+
+	var kept int
+	for range 5 {
+		if s.Keep(ev, now) {
+			kept++
+		}
+	}
+	if kept != 2 {
+		t.Fatalf("kept %d events in one second, want 2", kept)
+	}
+	if !s.Keep(proxy.Event{Query: "SELECT 1", Error: "boom"}, now) {
+		t.Error("expected failed queries to bypass sampling")
+	}
+	if !s.Keep(ev, now.Add(time.Second)) {
+		t.Error("expected the limit to reset in the next second")
+	}
+	if s.Shed() != 3 {
+		t.Errorf("Shed() = %d, want 3", s.Shed())
+	}
+}
+
+func TestSampler_PerFingerprint(t *testing.T) {
+	t.Parallel()
+
+	s := sample.New(sample.Config{PerFingerprint: 1})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !s.Keep(proxy.Event{Query: "SELECT * FROM users WHERE id = 1"}, now) {
+		t.Fatal("expected the first query to be kept")
+	}
+	if s.Keep(proxy.Event{Query: "SELECT * FROM users WHERE id = 2"}, now) {
+		t.Error("expected a second query with the same fingerprint to be shed")
+	}
+	if !s.Keep(proxy.Event{Query: "SELECT * FROM orders"}, now) {
+		t.Error("expected a different fingerprint to be kept")
+	}
+}
+
+func TestSampler_Rate(t *testing.T) {
+	t.Parallel()
+
+	s := sample.New(sample.Config{Rate: 0.5})
+	now := time.Now()
+	var kept int
+	for range 10000 {
+		if s.Keep(proxy.Event{Query: "SELECT 1"}, now) {
+			kept++
+		}
+	}
+	// Far outside any plausible binomial deviation for n=10000, p=0.5.
+	if kept < 4000 || kept > 6000 {
+		t.Errorf("kept %d of 10000 at rate 0.5", kept)
+	}
+}
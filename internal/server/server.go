@@ -0,0 +1,1230 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/explain"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/advisory"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/collab"
+	"github.com/mickamy/sql-tap/internal/databases"
+	"github.com/mickamy/sql-tap/internal/indexadvisor"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/pgstat"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/routes"
+	"github.com/mickamy/sql-tap/internal/sample"
+	"github.com/mickamy/sql-tap/internal/store"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/internal/tenants"
+	"github.com/mickamy/sql-tap/internal/txtrack"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Server exposes a gRPC TapService for TUI clients to connect to.
+type Server struct {
+	grpcServer *grpc.Server
+	health     *health.Server
+	svc        *tapService
+}
+
+// Option configures a Server.
+type Option func(*tapService)
+
+// WithTLSCertNotAfter reports the expiry of the proxy's TLS certificate via
+// the Info RPC, with warn as how far ahead of it clients should warn.
+func WithTLSCertNotAfter(t time.Time, warn time.Duration) Option {
+	return func(s *tapService) {
+		s.tlsCertNotAfter = t
+		s.tlsCertWarn = warn
+	}
+}
+
+// WithVerbosity enables the SetVerbose RPC, which toggles detailed capture
+// for individual connections in v.
+func WithVerbosity(v *proxy.Verbosity) Option {
+	return func(s *tapService) {
+		s.verbosity = v
+	}
+}
+
+// WithUpstreamExplainClient routes Explain requests for the named upstream to
+// c instead of the default explain client.
+func WithUpstreamExplainClient(upstream string, c *explain.Client) Option {
+	return func(s *tapService) {
+		if s.upstreamExplain == nil {
+			s.upstreamExplain = make(map[string]*explain.Client)
+		}
+		s.upstreamExplain[upstream] = c
+	}
+}
+
+// WithCanceler serves Kill requests that cancel a query on the named upstream
+// ("" for the default) through c, which signals the backends of its own
+// connections without the explain client's login. Terminating a backend,
+// and cancelling one c does not know, still go through the explain client.
+func WithCanceler(upstream string, c proxy.Canceler) Option {
+	return func(s *tapService) {
+		if s.cancelers == nil {
+			s.cancelers = make(map[string]proxy.Canceler)
+		}
+		s.cancelers[upstream] = c
+	}
+}
+
+// WithStages records per-stage event latency into stages, reported by the Stats RPC.
+func WithStages(stages *metrics.Stages) Option {
+	return func(s *tapService) {
+		s.stages = stages
+	}
+}
+
+// WithSampler reports how many events the daemon-wide sampler shed via the
+// Stats RPC.
+func WithSampler(sm *sample.Sampler) Option {
+	return func(s *tapService) {
+		s.sampler = sm
+	}
+}
+
+// WithTagDefs reports the daemon's configured tags via the Info RPC.
+func WithTagDefs(defs []tagger.Def) Option {
+	return func(s *tapService) {
+		s.tagDefs = defs
+	}
+}
+
+// Endpoint is where applications connect to reach an upstream through the
+// daemon, reported by the Info RPC.
+type Endpoint struct {
+	Upstream string // "" with a single upstream
+	Driver   string
+	Listen   string
+	DSN      string // without the password; "" when the listen address cannot be written as a DSN
+}
+
+// WithEndpoints reports the daemon's proxies via the Info RPC.
+func WithEndpoints(endpoints []Endpoint) Option {
+	return func(s *tapService) {
+		s.endpoints = endpoints
+	}
+}
+
+// WithTxTracker enables the Transactions RPC, served from tr.
+func WithTxTracker(tr *txtrack.Tracker) Option {
+	return func(s *tapService) {
+		s.txTracker = tr
+	}
+}
+
+// WithAuthorizer requires every call to carry a bearer token whose role
+// allows the method.
+func WithAuthorizer(a *auth.Authorizer) Option {
+	return func(s *tapService) {
+		s.authorizer = a
+	}
+}
+
+// WithSpill sets where watchers asking for DELIVERY_SPILL spill to: a
+// file in dir ("" for the system's temporary directory) of up to maxBytes
+// each (broker.DefaultSpillMax if not positive).
+func WithSpill(dir string, maxBytes int64) Option {
+	return func(s *tapService) {
+		s.spillDir = dir
+		s.spillMax = maxBytes
+	}
+}
+
+// WithAuditLog records each watcher's connect and disconnect, with the
+// client identity it sent, to l.
+func WithAuditLog(l *log.Logger) Option {
+	return func(s *tapService) {
+		s.audit = l
+	}
+}
+
+// WithCollab enables shared annotations and presence for watchers that ask
+// to collaborate, and the Annotate RPC.
+func WithCollab(h *collab.Hub) Option {
+	return func(s *tapService) {
+		s.collab = h
+	}
+}
+
+// WithStore answers the Query RPC from st.
+func WithStore(st *store.Store) Option {
+	return func(s *tapService) {
+		s.store = st
+	}
+}
+
+// WithRoutes enables the Routes RPC, served from tr.
+func WithRoutes(tr *routes.Tracker) Option {
+	return func(s *tapService) {
+		s.routes = tr
+	}
+}
+
+// WithTenants enables the Tenants RPC, served from tr.
+func WithTenants(tr *tenants.Tracker) Option {
+	return func(s *tapService) {
+		s.tenants = tr
+	}
+}
+
+// WithDatabases enables the Databases RPC, served from tr.
+func WithDatabases(tr *databases.Tracker) Option {
+	return func(s *tapService) {
+		s.databases = tr
+	}
+}
+
+// WithStatements enables the Statements RPC, served from p.
+func WithStatements(p *pgstat.Poller) Option {
+	return func(s *tapService) {
+		s.statements = p
+	}
+}
+
+// WithEffectiveConfig enables the Config RPC, which returns doc: the
+// daemon's resolved settings as YAML, with secrets already masked.
+func WithEffectiveConfig(doc []byte) Option {
+	return func(s *tapService) {
+		s.effectiveConfig = doc
+	}
+}
+
+// New creates a new Server backed by the given Broker.
+// explainClient may be nil if EXPLAIN is not configured.
+func New(b *broker.Broker[proxy.Event], explainClient *explain.Client, opts ...Option) *Server {
+	svc := &tapService{broker: b, explainClient: explainClient}
+	for _, opt := range opts {
+		opt(svc)
+	}
+	var serverOpts []grpc.ServerOption
+	if a := svc.authorizer; a != nil {
+		serverOpts = append(serverOpts,
+			grpc.UnaryInterceptor(a.UnaryInterceptor()),
+			grpc.StreamInterceptor(a.StreamInterceptor()),
+		)
+	}
+	gs := grpc.NewServer(serverOpts...)
+	tapv1.RegisterTapServiceServer(gs, svc)
+
+	// grpc.health.v1 and server reflection let grpcurl, load balancers, and
+	// Kubernetes probes talk to the agent without compiled stubs. Health
+	// reports SERVING for the server as a whole ("") and for TapService.
+	hs := health.NewServer()
+	hs.SetServingStatus(tapv1.TapService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(gs, hs)
+	reflection.Register(gs)
+
+	return &Server{grpcServer: gs, health: hs, svc: svc}
+}
+
+// Service returns the TapService implementation for in-process callers,
+// such as the HTTP API. Calls through it skip the authorizer.
+func (s *Server) Service() tapv1.TapServiceServer {
+	return s.svc
+}
+
+// Serve starts the gRPC server on the given listener.
+func (s *Server) Serve(lis net.Listener) error {
+	if err := s.grpcServer.Serve(lis); err != nil {
+		return fmt.Errorf("server: serve: %w", err)
+	}
+	return nil
+}
+
+// Stop immediately stops the server, closing all active connections.
+func (s *Server) Stop() {
+	s.health.Shutdown()
+	s.grpcServer.Stop()
+}
+
+// GracefulStop gracefully stops the server. Health checks report
+// NOT_SERVING while open calls drain.
+func (s *Server) GracefulStop() {
+	s.health.Shutdown()
+	s.grpcServer.GracefulStop()
+}
+
+type tapService struct {
+	tapv1.UnimplementedTapServiceServer
+
+	broker          *broker.Broker[proxy.Event]
+	explainClient   *explain.Client
+	upstreamExplain map[string]*explain.Client
+	cancelers       map[string]proxy.Canceler
+	tlsCertNotAfter time.Time
+	tlsCertWarn     time.Duration
+	verbosity       *proxy.Verbosity
+	stages          *metrics.Stages
+	tagDefs         []tagger.Def
+	endpoints       []Endpoint
+	txTracker       *txtrack.Tracker
+	authorizer      *auth.Authorizer
+	spillDir        string
+	spillMax        int64
+	audit           *log.Logger
+	collab          *collab.Hub
+	sampler         *sample.Sampler
+	store           *store.Store
+	routes          *routes.Tracker
+	tenants         *tenants.Tracker
+	databases       *databases.Tracker
+	statements      *pgstat.Poller
+	effectiveConfig []byte
+}
+
+func (s *tapService) Watch(req *tapv1.WatchRequest, stream grpc.ServerStreamingServer[tapv1.WatchResponse]) error {
+	ctx := stream.Context()
+
+	sampling, err := samplingFromProto(req.GetSampling())
+	if err != nil {
+		return err
+	}
+	var sampler *sample.Sampler
+	if sampling.Enabled() {
+		sampler = sample.New(sampling)
+	}
+
+	client := cleanText(req.GetClient(), maxClientLen)
+	opts := []broker.SubscribeOption{broker.WithClient(client)}
+	addr := "unknown"
+	if p, ok := peer.FromContext(ctx); ok {
+		addr = p.Addr.String()
+		opts = append(opts, broker.WithName("watch "+addr), broker.WithPeer(addr))
+	}
+	switch req.GetDelivery() {
+	case tapv1.Delivery_DELIVERY_UNSPECIFIED:
+	case tapv1.Delivery_DELIVERY_BLOCK:
+		// A blocking watcher stalls every other one, and the pipeline.
+		if err := s.authorizer.Require(ctx, auth.RoleAdmin, "blocking delivery"); err != nil {
+			return err //nolint:wrapcheck // a gRPC status
+		}
+		opts = append(opts, broker.WithPolicy(broker.Block))
+	case tapv1.Delivery_DELIVERY_SPILL:
+		opts = append(opts, broker.WithSpill(s.spillDir, s.spillMax))
+	default:
+		return status.Errorf(codes.InvalidArgument, "unknown delivery %v", req.GetDelivery())
+	}
+	sel, err := selectorFromProto(req.GetSelector())
+	if err != nil {
+		return err
+	}
+	if sel != nil {
+		opts = append(opts, broker.WithFilter(func(ev proxy.Event) bool {
+			return sel.match(ev.Upstream, ev.Database, ev.User, ev.Op, ev.Fingerprint, ev.Fields)
+		}))
+	}
+	ch, unsub := s.broker.Subscribe(opts...)
+	defer unsub()
+
+	if s.audit != nil {
+		start := time.Now()
+		s.audit.Printf("audit: watcher connected: client=%q addr=%s", client, addr)
+		defer func() {
+			s.audit.Printf("audit: watcher disconnected: client=%q addr=%s after %s",
+				client, addr, time.Since(start).Round(time.Second))
+		}()
+	}
+
+	// updates stays nil, and never fires, unless the watcher collaborates.
+	var updates <-chan collab.Update
+	if req.GetCollaborate() && s.collab != nil {
+		var leave func()
+		updates, leave = s.collab.Join(client)
+		defer leave()
+		for _, a := range s.collab.Annotations() {
+			if err := stream.Send(&tapv1.WatchResponse{Annotation: annotationToProto(a)}); err != nil {
+				return fmt.Errorf("server: watch send: %w", err)
+			}
+		}
+	}
+
+	replayed, err := s.replay(req.GetResumeAfter(), sel, stream)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("server: watch: %w", ctx.Err())
+		case ev, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			if k := keyOf(ev.Upstream, ev.ConnID, ev.ID, ev.StartTime); replayed[k] {
+				delete(replayed, k)
+				continue
+			}
+			start := time.Now()
+			if sampler != nil && !sampler.Keep(ev, start) {
+				continue
+			}
+			if err := stream.Send(&tapv1.WatchResponse{
+				Event: EventToProto(ev),
+			}); err != nil {
+				return fmt.Errorf("server: watch send: %w", err)
+			}
+			s.stages.Observe(metrics.StageStream, time.Since(start))
+		case u := <-updates:
+			resp := &tapv1.WatchResponse{}
+			if u.Annotation != nil {
+				resp.Annotation = annotationToProto(*u.Annotation)
+			} else {
+				resp.Presence = &tapv1.Presence{Clients: u.Presence}
+			}
+			if err := stream.Send(resp); err != nil {
+				return fmt.Errorf("server: watch send: %w", err)
+			}
+		}
+	}
+}
+
+// maxReplay bounds the stored events replayed to a resuming watcher.
+const maxReplay = 10000
+
+// eventKey identifies an event across the store and the broker.
+type eventKey struct {
+	upstream, conn, id string
+	start              int64
+}
+
+func keyOf(upstream, conn, id string, start time.Time) eventKey {
+	return eventKey{upstream: upstream, conn: conn, id: id, start: start.UnixNano()}
+}
+
+// replay sends a resuming watcher the stored events that completed after
+// resumeAfter. The watcher's subscription is already open, so events
+// published since may arrive live as well; the returned keys let the caller
+// skip them.
+func (s *tapService) replay(resumeAfter *timestamppb.Timestamp, sel *selector, stream grpc.ServerStreamingServer[tapv1.WatchResponse]) (map[eventKey]bool, error) {
+	if resumeAfter == nil || s.store == nil {
+		return nil, nil
+	}
+	events, err := s.store.CompletedAfter(resumeAfter.AsTime(), maxReplay)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "replay: %v", err)
+	}
+	replayed := make(map[eventKey]bool, len(events))
+	for _, ev := range events {
+		if sel != nil && !sel.match(ev.GetUpstream(), ev.GetDatabase(), ev.GetUser(), proxy.Op(ev.GetOp()), ev.GetFingerprint(), ev.GetFields()) {
+			continue
+		}
+		if err := stream.Send(&tapv1.WatchResponse{Event: ev}); err != nil {
+			return nil, fmt.Errorf("server: watch send: %w", err)
+		}
+		replayed[keyOf(ev.GetUpstream(), ev.GetConnId(), ev.GetId(), ev.GetStartTime().AsTime())] = true
+	}
+	return replayed, nil
+}
+
+// samplingFromProto validates a watcher's sampling rules.
+func samplingFromProto(p *tapv1.Sampling) (sample.Config, error) {
+	if p.GetRate() < 0 || p.GetRate() > 1 || p.GetPerFingerprint() < 0 || p.GetMaxPerSecond() < 0 {
+		return sample.Config{}, status.Error(codes.InvalidArgument, "sampling: rate must be in (0, 1] and limits must not be negative")
+	}
+	return sample.Config{
+		Rate:           p.GetRate(),
+		PerFingerprint: int(p.GetPerFingerprint()),
+		MaxPerSecond:   int(p.GetMaxPerSecond()),
+	}, nil
+}
+
+// selector is a watcher's event selection; see tapv1.Selector.
+type selector struct {
+	upstreams map[string]bool // nil matches every upstream
+	databases map[string]bool
+	users     map[string]bool
+	ops       map[proxy.Op]bool
+	prefix    string
+	fields    map[string]string
+}
+
+// selectorFromProto validates a watcher's selector. It returns nil when p
+// selects everything.
+func selectorFromProto(p *tapv1.Selector) (*selector, error) {
+	if len(p.GetUpstreams()) == 0 && len(p.GetDatabases()) == 0 && len(p.GetUsers()) == 0 &&
+		len(p.GetOps()) == 0 && p.GetFingerprintPrefix() == "" && len(p.GetFields()) == 0 {
+		return nil, nil
+	}
+	sel := &selector{
+		upstreams: nameSet(p.GetUpstreams()),
+		databases: nameSet(p.GetDatabases()),
+		users:     nameSet(p.GetUsers()),
+		fields:    p.GetFields(),
+	}
+	if p.GetFingerprintPrefix() != "" {
+		sel.prefix = query.Fingerprint(p.GetFingerprintPrefix())
+	}
+	for _, name := range p.GetOps() {
+		op, ok := proxy.ParseOp(name)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "selector: unknown op %q", name)
+		}
+		if sel.ops == nil {
+			sel.ops = make(map[proxy.Op]bool)
+		}
+		sel.ops[op] = true
+	}
+	return sel, nil
+}
+
+// nameSet returns names as a set, or nil when there are none.
+func nameSet(names []string) map[string]bool {
+	if len(names) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(names))
+	for _, n := range names {
+		set[n] = true
+	}
+	return set
+}
+
+// match reports whether an event from upstream, on a connection to database
+// as user, of op with fingerprint fp and extracted fields is selected.
+func (sel *selector) match(upstream, database, user string, op proxy.Op, fp string, fields map[string]string) bool {
+	if sel.upstreams != nil && !sel.upstreams[upstream] {
+		return false
+	}
+	if sel.databases != nil && !sel.databases[database] {
+		return false
+	}
+	if sel.users != nil && !sel.users[user] {
+		return false
+	}
+	if sel.ops != nil && !sel.ops[op] {
+		return false
+	}
+	for name, want := range sel.fields {
+		if v, ok := fields[name]; !ok || v != want {
+			return false
+		}
+	}
+	return len(fp) >= len(sel.prefix) && strings.EqualFold(fp[:len(sel.prefix)], sel.prefix)
+}
+
+// Limits, in bytes, on the client identities and annotations watchers send.
+const (
+	maxClientLen     = 128
+	maxAnnotationLen = 1024
+)
+
+// cleanText drops control characters from text a client sent and truncates
+// it to n bytes, so it is safe to log and display.
+func cleanText(text string, n int) string {
+	var b strings.Builder
+	for _, r := range text {
+		if r == utf8.RuneError || r < 0x20 || r == 0x7f {
+			continue
+		}
+		if b.Len()+utf8.RuneLen(r) > n {
+			break
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (s *tapService) Annotate(_ context.Context, req *tapv1.AnnotateRequest) (*tapv1.AnnotateResponse, error) {
+	if s.collab == nil {
+		return nil, status.Error(codes.FailedPrecondition, "annotations are not enabled on this server")
+	}
+	if req.GetEventId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "event_id is required")
+	}
+	a := collab.Annotation{
+		EventID: req.GetEventId(),
+		Text:    cleanText(req.GetText(), maxAnnotationLen),
+		Author:  cleanText(req.GetClient(), maxClientLen),
+		Time:    time.Now(),
+	}
+	s.collab.Annotate(a)
+	if s.audit != nil {
+		s.audit.Printf("audit: annotation on event %q by %q: %q", a.EventID, a.Author, a.Text)
+	}
+	return &tapv1.AnnotateResponse{Annotation: annotationToProto(a)}, nil
+}
+
+// Query result limits: the default, and the most one call may return so a
+// response stays well inside gRPC's message size limit.
+const (
+	defaultQueryLimit = 1000
+	maxQueryLimit     = 5000
+)
+
+func (s *tapService) Query(_ context.Context, req *tapv1.QueryRequest) (*tapv1.QueryResponse, error) {
+	if s.store == nil {
+		return nil, status.Error(codes.FailedPrecondition, "event store is not enabled on this server")
+	}
+	limit := int(req.GetLimit())
+	switch {
+	case limit < 0:
+		return nil, status.Error(codes.InvalidArgument, "limit must not be negative")
+	case limit == 0:
+		limit = defaultQueryLimit
+	case limit > maxQueryLimit:
+		limit = maxQueryLimit
+	}
+	f := store.FilterFromRequest(req)
+	f.Limit = limit
+	events, err := s.store.Query(f)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "query: %v", err)
+	}
+	return &tapv1.QueryResponse{Events: events}, nil
+}
+
+func (s *tapService) Routes(_ context.Context, _ *tapv1.RoutesRequest) (*tapv1.RoutesResponse, error) {
+	if s.routes == nil {
+		return nil, status.Error(codes.FailedPrecondition, "route statistics are not enabled on this server")
+	}
+	rs := s.routes.Routes(time.Now())
+	out := make([]*tapv1.RouteStats, len(rs))
+	for i, r := range rs {
+		out[i] = routeToProto(r)
+	}
+	return &tapv1.RoutesResponse{
+		Routes:      out,
+		Window:      durationpb.New(routes.Window),
+		QueryBudget: int32(s.routes.QueryBudget()), //nolint:gosec // configured, small
+	}, nil
+}
+
+//nolint:gosec // counts are bounded by the window's traffic
+func routeToProto(r routes.Route) *tapv1.RouteStats {
+	return &tapv1.RouteStats{
+		Route:                sanitizeUTF8(r.Route),
+		Count:                int32(r.Count),
+		Errors:               int32(r.Errors),
+		Qps:                  r.QPS,
+		P50:                  durationpb.New(r.P50),
+		P95:                  durationpb.New(r.P95),
+		P99:                  durationpb.New(r.P99),
+		Requests:             int32(r.Requests),
+		QueriesPerRequestP50: int32(r.QueriesP50),
+		QueriesPerRequestP95: int32(r.QueriesP95),
+		QueriesPerRequestMax: int32(r.QueriesMax),
+		OverBudget:           int32(r.OverBudget),
+		TopFingerprint:       sanitizeUTF8(r.TopFingerprint),
+		TopFingerprintCalls:  int32(r.TopCalls),
+	}
+}
+
+func (s *tapService) Tenants(_ context.Context, _ *tapv1.TenantsRequest) (*tapv1.TenantsResponse, error) {
+	if s.tenants == nil {
+		return nil, status.Error(codes.FailedPrecondition, "tenant statistics are not enabled on this server")
+	}
+	ts := s.tenants.Tenants(time.Now())
+	out := make([]*tapv1.TenantStats, len(ts))
+	for i, t := range ts {
+		out[i] = tenantToProto(t)
+	}
+	return &tapv1.TenantsResponse{
+		Field:    s.tenants.Field(),
+		Tenants:  out,
+		Window:   durationpb.New(tenants.Window),
+		MaxShare: s.tenants.MaxShare(),
+	}, nil
+}
+
+//nolint:gosec // counts are bounded by the window's traffic
+func tenantToProto(t tenants.Tenant) *tapv1.TenantStats {
+	return &tapv1.TenantStats{
+		Value:  sanitizeUTF8(t.Value),
+		Count:  int32(t.Count),
+		Errors: int32(t.Errors),
+		Qps:    t.QPS,
+		P50:    durationpb.New(t.P50),
+		P95:    durationpb.New(t.P95),
+		P99:    durationpb.New(t.P99),
+		Share:  t.Share,
+	}
+}
+
+func (s *tapService) Databases(_ context.Context, _ *tapv1.DatabasesRequest) (*tapv1.DatabasesResponse, error) {
+	if s.databases == nil {
+		return nil, status.Error(codes.FailedPrecondition, "database statistics are not enabled on this server")
+	}
+	dbs := s.databases.Databases(time.Now())
+	out := make([]*tapv1.DatabaseStats, len(dbs))
+	for i, db := range dbs {
+		out[i] = databaseToProto(db)
+	}
+	return &tapv1.DatabasesResponse{
+		Databases: out,
+		Window:    durationpb.New(databases.Window),
+	}, nil
+}
+
+//nolint:gosec // counts are bounded by the window's traffic
+func databaseToProto(db databases.Database) *tapv1.DatabaseStats {
+	users := make([]string, len(db.Users))
+	for i, u := range db.Users {
+		users[i] = sanitizeUTF8(u)
+	}
+	return &tapv1.DatabaseStats{
+		Upstream: db.Upstream,
+		Database: sanitizeUTF8(db.Database),
+		Count:    int32(db.Count),
+		Errors:   int32(db.Errors),
+		Qps:      db.QPS,
+		P50:      durationpb.New(db.P50),
+		P95:      durationpb.New(db.P95),
+		P99:      durationpb.New(db.P99),
+		Share:    db.Share,
+		Users:    users,
+	}
+}
+
+func (s *tapService) Statements(_ context.Context, _ *tapv1.StatementsRequest) (*tapv1.StatementsResponse, error) {
+	if s.statements == nil {
+		return nil, status.Error(codes.FailedPrecondition, "pg_stat_statements polling is not enabled on this server")
+	}
+	snap := s.statements.Snapshot()
+	out := make([]*tapv1.ServerStatement, len(snap.Entries))
+	for i, e := range snap.Entries {
+		out[i] = &tapv1.ServerStatement{
+			Upstream:    e.Upstream,
+			Fingerprint: sanitizeUTF8(e.Fingerprint),
+			Calls:       e.Calls,
+			Total:       durationpb.New(e.Total),
+			Rows:        e.Rows,
+		}
+	}
+	resp := &tapv1.StatementsResponse{
+		Statements: out,
+		Interval:   durationpb.New(s.statements.Interval()),
+		Errors:     snap.Errors,
+	}
+	if !snap.At.IsZero() {
+		resp.PolledAt = timestamppb.New(snap.At)
+	}
+	return resp, nil
+}
+
+func (s *tapService) Config(_ context.Context, _ *tapv1.ConfigRequest) (*tapv1.ConfigResponse, error) {
+	if s.effectiveConfig == nil {
+		return nil, status.Error(codes.FailedPrecondition, "the effective config is not available on this server")
+	}
+	return &tapv1.ConfigResponse{Yaml: string(s.effectiveConfig)}, nil
+}
+
+func annotationToProto(a collab.Annotation) *tapv1.Annotation {
+	return &tapv1.Annotation{
+		EventId: a.EventID,
+		Text:    a.Text,
+		Author:  a.Author,
+		Time:    timestamppb.New(a.Time),
+	}
+}
+
+// clientFor returns the explain connection for upstream, which also serves
+// the Kill RPC.
+func (s *tapService) clientFor(upstream string) (*explain.Client, error) {
+	client := s.explainClient
+	if c, ok := s.upstreamExplain[upstream]; ok {
+		client = c
+	}
+	if client == nil {
+		if upstream != "" {
+			return nil, status.Errorf(codes.FailedPrecondition, "EXPLAIN is not configured for upstream %q", upstream)
+		}
+		return nil, status.Error(codes.FailedPrecondition, "EXPLAIN is not configured (set DATABASE_URL)")
+	}
+	return client, nil
+}
+
+func (s *tapService) Explain(ctx context.Context, req *tapv1.ExplainRequest) (*tapv1.ExplainResponse, error) {
+	client, err := s.clientFor(req.GetUpstream())
+	if err != nil {
+		return nil, err
+	}
+
+	mode := explain.Explain
+	if req.GetAnalyze() {
+		mode = explain.Analyze
+	}
+
+	result, err := client.Run(ctx, mode, req.GetQuery(), req.GetArgs())
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, status.Error(codes.Canceled, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "explain: %v", err)
+	}
+
+	advisories := advisory.Plan(result)
+	indexes := indexadvisor.Advise(ctx, client, req.GetQuery(), req.GetArgs())
+	if len(indexes) > 0 {
+		advisories = append(advisories, indexadvisor.Tag)
+	}
+	return &tapv1.ExplainResponse{
+		Plan:       sanitizeUTF8(result.Plan),
+		Columns:    result.Columns,
+		Rows:       rowsToProto(result.Rows),
+		Advisories: advisories,
+		Indexes:    indexes,
+	}, nil
+}
+
+func (s *tapService) Kill(ctx context.Context, req *tapv1.KillRequest) (*tapv1.KillResponse, error) {
+	if req.GetBackendPid() == 0 {
+		return nil, status.Error(codes.InvalidArgument, "backend_pid is required")
+	}
+	if c, ok := s.cancelers[req.GetUpstream()]; ok && !req.GetTerminate() {
+		err := c.Cancel(ctx, req.GetBackendPid())
+		if err == nil {
+			proxy.CountCancel(proxy.CancelKilled)
+			return &tapv1.KillResponse{}, nil
+		}
+		if !errors.Is(err, proxy.ErrUnknownBackend) {
+			return nil, status.Errorf(codes.Internal, "kill: %v", err)
+		}
+	}
+	client, err := s.clientFor(req.GetUpstream())
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Kill(ctx, req.GetBackendPid(), req.GetTerminate()); err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return nil, status.Error(codes.Canceled, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "kill: %v", err)
+	}
+	proxy.CountCancel(proxy.CancelKilled)
+	return &tapv1.KillResponse{}, nil
+}
+
+func (s *tapService) Info(_ context.Context, _ *tapv1.InfoRequest) (*tapv1.InfoResponse, error) {
+	resp := &tapv1.InfoResponse{}
+	if !s.tlsCertNotAfter.IsZero() {
+		resp.TlsCertNotAfter = timestamppb.New(s.tlsCertNotAfter)
+		resp.TlsCertExpiryWarning = durationpb.New(s.tlsCertWarn)
+	}
+	for _, d := range s.tagDefs {
+		resp.Tags = append(resp.Tags, &tapv1.TagDef{Name: d.Name, Color: d.Color})
+	}
+	for _, e := range s.endpoints {
+		resp.Proxies = append(resp.Proxies, &tapv1.ProxyEndpoint{Upstream: e.Upstream, Driver: e.Driver, Listen: e.Listen, Dsn: e.DSN})
+	}
+	return resp, nil
+}
+
+func (s *tapService) SetVerbose(_ context.Context, req *tapv1.SetVerboseRequest) (*tapv1.SetVerboseResponse, error) {
+	if s.verbosity == nil {
+		return nil, status.Error(codes.FailedPrecondition, "detailed capture is not supported by this server")
+	}
+	if req.GetConnId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "conn_id is required")
+	}
+	if err := s.verbosity.Set(req.GetConnId(), req.GetVerbose()); err != nil {
+		return nil, status.Errorf(codes.NotFound, "connection %s is not open", req.GetConnId())
+	}
+	return &tapv1.SetVerboseResponse{VerboseConnIds: s.verbosity.List()}, nil
+}
+
+func (s *tapService) Stats(ctx context.Context, _ *tapv1.StatsRequest) (*tapv1.StatsResponse, error) {
+	snaps := s.stages.Snapshot()
+	bounds := make([]*durationpb.Duration, 0, len(metrics.Bounds()))
+	for _, b := range metrics.Bounds() {
+		bounds = append(bounds, durationpb.New(b))
+	}
+	stages := make([]*tapv1.StageLatency, len(snaps))
+	for i, st := range snaps {
+		stages[i] = &tapv1.StageLatency{
+			Name:         st.Name,
+			Count:        st.Count,
+			Total:        durationpb.New(st.Sum),
+			Max:          durationpb.New(st.Max),
+			P50:          durationpb.New(st.P50),
+			P99:          durationpb.New(st.P99),
+			Buckets:      st.Buckets[:],
+			BucketBounds: bounds,
+		}
+	}
+	// The caller's own Watch streams share its connection, and so its address.
+	var caller string
+	if p, ok := peer.FromContext(ctx); ok {
+		caller = p.Addr.String()
+	}
+	subs := s.broker.Stats()
+	subscribers := make([]*tapv1.SubscriberStats, len(subs))
+	for i, sub := range subs {
+		subscribers[i] = &tapv1.SubscriberStats{
+			Id:       int64(sub.ID),
+			Name:     sub.Name,
+			Client:   sub.Client,
+			Since:    timestamppb.New(sub.Since),
+			Policy:   sub.Policy.String(),
+			Dropped:  sub.Dropped,
+			Buffered: int64(sub.Buffered),
+			Capacity: int64(sub.Capacity),
+			Filtered: sub.Filtered,
+			Spilled:  int64(sub.Spilled),
+			Own:      caller != "" && sub.Peer == caller,
+		}
+	}
+	var sampledOut uint64
+	if s.sampler != nil {
+		sampledOut = s.sampler.Shed()
+	}
+	cancels := proxy.CancelCounts()
+	return &tapv1.StatsResponse{
+		Stages:       stages,
+		ProxyDropped: proxy.DroppedEvents(),
+		Panics:       proxy.Panics(),
+		Diagnostics:  proxy.Diagnostics(),
+		SampledOut:   sampledOut,
+		Subscribers:  subscribers,
+		Cancellations: &tapv1.Cancellations{
+			Relayed:      cancels.Relayed,
+			Killed:       cancels.Killed,
+			TimedOut:     cancels.TimedOut,
+			Disconnected: cancels.Disconnected,
+		},
+	}, nil
+}
+
+func (s *tapService) Transactions(_ context.Context, req *tapv1.TransactionsRequest) (*tapv1.TransactionsResponse, error) {
+	if s.txTracker == nil {
+		return nil, status.Error(codes.FailedPrecondition, "transaction tracking is not enabled on this server")
+	}
+	txs := s.txTracker.Transactions(int(req.GetLimit()))
+	out := make([]*tapv1.Transaction, len(txs))
+	for i, tx := range txs {
+		out[i] = txToProto(tx)
+	}
+	return &tapv1.TransactionsResponse{Transactions: out}, nil
+}
+
+func txToProto(tx txtrack.Tx) *tapv1.Transaction {
+	events := make([]*tapv1.QueryEvent, len(tx.Events))
+	for i, ev := range tx.Events {
+		events[i] = EventToProto(ev)
+	}
+	out := &tapv1.Transaction{
+		TxId:       tx.ID,
+		GlobalTxId: tx.GlobalID,
+		ConnId:     tx.ConnID,
+		Upstream:   tx.Upstream,
+		Status:     txStatusToProto(tx.Status),
+		StartTime:  timestamppb.New(tx.StartTime),
+		Duration:   durationpb.New(tx.Duration()),
+		Events:     events,
+		Truncated:  tx.Truncated,
+	}
+	if !tx.EndTime.IsZero() {
+		out.EndTime = timestamppb.New(tx.EndTime)
+	}
+	return out
+}
+
+func txStatusToProto(s txtrack.Status) tapv1.TxStatus {
+	switch s {
+	case txtrack.StatusOpen:
+		return tapv1.TxStatus_TX_STATUS_OPEN
+	case txtrack.StatusCommitted:
+		return tapv1.TxStatus_TX_STATUS_COMMITTED
+	case txtrack.StatusRolledBack:
+		return tapv1.TxStatus_TX_STATUS_ROLLED_BACK
+	case txtrack.StatusPrepared:
+		return tapv1.TxStatus_TX_STATUS_PREPARED
+	}
+	return tapv1.TxStatus_TX_STATUS_UNSPECIFIED
+}
+
+// EventToProto converts a captured event to its wire form, replacing invalid
+// UTF-8 in the query, args, and error. Events that did not pass through
+// proxy.Emit are fingerprinted here.
+func EventToProto(ev proxy.Event) *tapv1.QueryEvent {
+	fp := ev.Fingerprint
+	if fp == "" && ev.Query != "" {
+		fp = query.Fingerprint(ev.Query)
+	}
+	args := make([]string, len(ev.Args))
+	for i, a := range ev.Args {
+		args[i] = sanitizeUTF8(a)
+	}
+	var caller []string
+	for _, f := range ev.Caller {
+		caller = append(caller, sanitizeUTF8(f))
+	}
+	return &tapv1.QueryEvent{
+		Id:            ev.ID,
+		Op:            int32(ev.Op),
+		Query:         sanitizeUTF8(ev.Query),
+		Fingerprint:   sanitizeUTF8(fp),
+		Args:          args,
+		StartTime:     timestamppb.New(ev.StartTime),
+		Duration:      durationpb.New(ev.Duration),
+		QueueLatency:  optionalDuration(ev.QueueLatency),
+		ExecLatency:   optionalDuration(ev.ExecLatency),
+		FetchLatency:  optionalDuration(ev.FetchLatency),
+		RowsAffected:  ev.RowsAffected,
+		RequestBytes:  ev.RequestBytes,
+		ResponseBytes: ev.ResponseBytes,
+		Error:         sanitizeUTF8(ev.Error),
+		TxId:          ev.TxID,
+		GlobalTxId:    ev.GlobalTxID,
+		TlsVersion:    ev.TLSVersion,
+		TlsCipher:     ev.TLSCipher,
+		ConnId:        ev.ConnID,
+		ClientAddr:    ev.ClientAddr,
+		Listener:      ev.Listener,
+		User:          sanitizeUTF8(ev.User),
+		Database:      sanitizeUTF8(ev.Database),
+		BackendPid:    ev.BackendPID,
+		Session:       ev.Session,
+		AuthMethod:    ev.AuthMethod,
+		AuthDuration:  optionalDuration(ev.AuthDuration),
+		ServerParams:  paramsToProto(ev.ServerParams),
+		StartupParams: paramsToProto(ev.StartupParams),
+		SslRequested:  ev.SSLRequested,
+		Upstream:      ev.Upstream,
+		Phases:        phasesToProto(ev.Phases),
+		RowSamples:    rowsToProto(ev.RowSamples),
+		Columns:       columnsToProto(ev.Columns),
+		Tags:          ev.Tags,
+		Fields:        paramsToProto(ev.Fields),
+		Cursor:        ev.Cursor,
+		Fetches:       int32(ev.Fetches), //nolint:gosec // fetch counts stay far below MaxInt32
+		BatchId:       ev.BatchID,
+		BatchSize:     int32(ev.BatchSize), //nolint:gosec // batch sizes stay far below MaxInt32
+		TraceId:       ev.TraceID,
+		SpanId:        ev.SpanID,
+		Route:         sanitizeUTF8(ev.Route),
+		RequestId:     sanitizeUTF8(ev.RequestID),
+		Caller:        caller,
+		Extensions:    paramsToProto(ev.Extensions),
+		ErrorDetail:   errorDetailToProto(ev.ErrorDetail),
+		Notice:        errorDetailToProto(ev.Notice),
+		NoticeFor:     ev.NoticeFor,
+		Queries:       ev.Queries,
+		Anomaly:       anomalyToProto(ev.Anomaly),
+		NPlusOne:      nPlusOneToProto(ev.NPlusOne),
+		Traffic:       trafficToProto(ev.Traffic),
+		Quota:         quotaToProto(ev.Quota),
+		Plan:          planToProto(ev.Plan),
+		Panic:         panicToProto(ev.Panic),
+		Diagnostic:    diagnosticToProto(ev.Diagnostic),
+		Routing:       routingToProto(ev.Routing),
+		Violation:     violationToProto(ev.Violation),
+	}
+}
+
+func violationToProto(v *proxy.Violation) *tapv1.Violation {
+	if v == nil {
+		return nil
+	}
+	return &tapv1.Violation{Rule: v.Rule, Message: v.Message, Blocked: v.Blocked}
+}
+
+func routingToProto(r *proxy.Routing) *tapv1.Routing {
+	if r == nil {
+		return nil
+	}
+	return &tapv1.Routing{Replica: r.Replica, Reason: r.Reason}
+}
+
+// optionalDuration leaves out durations the event does not carry.
+func optionalDuration(d time.Duration) *durationpb.Duration {
+	if d == 0 {
+		return nil
+	}
+	return durationpb.New(d)
+}
+
+func paramsToProto(params map[string]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(params))
+	for k, v := range params {
+		out[sanitizeUTF8(k)] = sanitizeUTF8(v)
+	}
+	return out
+}
+
+func trafficToProto(c *proxy.TrafficChange) *tapv1.TrafficChange {
+	if c == nil {
+		return nil
+	}
+	kind := tapv1.TrafficKind_TRAFFIC_KIND_UNSPECIFIED
+	switch c.Kind {
+	case proxy.TrafficNew:
+		kind = tapv1.TrafficKind_TRAFFIC_KIND_NEW
+	case proxy.TrafficRise:
+		kind = tapv1.TrafficKind_TRAFFIC_KIND_RISE
+	case proxy.TrafficDrop:
+		kind = tapv1.TrafficKind_TRAFFIC_KIND_DROP
+	}
+	return &tapv1.TrafficChange{
+		Kind:     kind,
+		Calls:    int64(c.Calls),
+		Baseline: c.Baseline,
+		Window:   durationpb.New(c.Window),
+	}
+}
+
+func quotaToProto(q *proxy.Quota) *tapv1.TenantQuota {
+	if q == nil {
+		return nil
+	}
+	return &tapv1.TenantQuota{
+		Field:    q.Field,
+		Value:    sanitizeUTF8(q.Value),
+		Calls:    int64(q.Calls),
+		Total:    int64(q.Total),
+		MaxShare: q.MaxShare,
+		Window:   durationpb.New(q.Window),
+	}
+}
+
+func panicToProto(p *proxy.Panic) *tapv1.Panic {
+	if p == nil {
+		return nil
+	}
+	return &tapv1.Panic{
+		Where: p.Where,
+		Value: sanitizeUTF8(p.Value),
+		Stack: sanitizeUTF8(p.Stack),
+	}
+}
+
+func diagnosticToProto(d *proxy.Diagnostic) *tapv1.Diagnostic {
+	if d == nil {
+		return nil
+	}
+	return &tapv1.Diagnostic{
+		Level:   d.Level.String(),
+		Message: sanitizeUTF8(d.Message),
+	}
+}
+
+func planToProto(p *proxy.Plan) *tapv1.AutoPlan {
+	if p == nil {
+		return nil
+	}
+	return &tapv1.AutoPlan{
+		ForId:   p.ForID,
+		Analyze: p.Analyze,
+		Plan:    sanitizeUTF8(p.Text),
+		Indexes: p.Indexes,
+	}
+}
+
+func anomalyToProto(a *proxy.Anomaly) *tapv1.Anomaly {
+	if a == nil {
+		return nil
+	}
+	return &tapv1.Anomaly{
+		Score:    a.Score,
+		Baseline: durationpb.New(a.Baseline),
+	}
+}
+
+func nPlusOneToProto(n *proxy.NPlusOne) *tapv1.NPlusOne {
+	if n == nil {
+		return nil
+	}
+	return &tapv1.NPlusOne{
+		Calls: int64(n.Calls),
+		Span:  durationpb.New(n.Span),
+		InTx:  n.InTx,
+	}
+}
+
+func errorDetailToProto(d *proxy.ErrorDetail) *tapv1.ErrorDetail {
+	if d == nil {
+		return nil
+	}
+	return &tapv1.ErrorDetail{
+		Code:     d.Code,
+		Severity: d.Severity,
+		Message:  sanitizeUTF8(d.Message),
+		Detail:   sanitizeUTF8(d.Detail),
+		Hint:     sanitizeUTF8(d.Hint),
+		Position: int32(d.Position), //nolint:gosec // parsed from an int32 protocol field
+	}
+}
+
+func phasesToProto(phases []proxy.Phase) []*tapv1.Phase {
+	if len(phases) == 0 {
+		return nil
+	}
+	out := make([]*tapv1.Phase, len(phases))
+	for i, ph := range phases {
+		out[i] = &tapv1.Phase{Name: ph.Name, Duration: durationpb.New(ph.Duration)}
+	}
+	return out
+}
+
+func rowsToProto(rows [][]string) []*tapv1.Row {
+	if len(rows) == 0 {
+		return nil
+	}
+	out := make([]*tapv1.Row, len(rows))
+	for i, row := range rows {
+		vals := make([]string, len(row))
+		for j, v := range row {
+			vals[j] = sanitizeUTF8(v)
+		}
+		out[i] = &tapv1.Row{Values: vals}
+	}
+	return out
+}
+
+func columnsToProto(cols []proxy.Column) []*tapv1.Column {
+	if len(cols) == 0 {
+		return nil
+	}
+	out := make([]*tapv1.Column, len(cols))
+	for i, c := range cols {
+		out[i] = &tapv1.Column{Name: sanitizeUTF8(c.Name), Type: c.Type}
+	}
+	return out
+}
+
+// sanitizeUTF8 replaces invalid UTF-8 bytes with the Unicode replacement character.
+func sanitizeUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size <= 1 {
+			b.WriteRune('\uFFFD')
+			i++
+		} else {
+			b.WriteRune(r)
+			i += size
+		}
+	}
+	return b.String()
+}
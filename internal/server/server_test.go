@@ -0,0 +1,1671 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mickamy/sql-tap/broker"
+	"github.com/mickamy/sql-tap/explain"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/collab"
+	"github.com/mickamy/sql-tap/internal/databases"
+	"github.com/mickamy/sql-tap/internal/metrics"
+	"github.com/mickamy/sql-tap/internal/pgstat"
+	"github.com/mickamy/sql-tap/internal/routes"
+	"github.com/mickamy/sql-tap/internal/sample"
+	"github.com/mickamy/sql-tap/internal/server"
+	"github.com/mickamy/sql-tap/internal/store"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/internal/tenants"
+	"github.com/mickamy/sql-tap/internal/txtrack"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func startServer(t *testing.T, b *broker.Broker[proxy.Event], opts ...server.Option) tapv1.TapServiceClient {
+	t.Helper()
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(t.Context(), "tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := server.New(b, nil, opts...)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return tapv1.NewTapServiceClient(conn)
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+
+	ctx := t.Context()
+	stream, err := client.Watch(ctx, &tapv1.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait briefly for the subscription to be registered.
+	time.Sleep(50 * time.Millisecond)
+
+	b.Publish(proxy.Event{
+		ID:    "1",
+		Op:    proxy.OpQuery,
+		Query: "SELECT 1",
+	})
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev := resp.GetEvent()
+	if ev.GetId() != "1" {
+		t.Fatalf("expected id 1, got %q", ev.GetId())
+	}
+	if ev.GetQuery() != "SELECT 1" {
+		t.Fatalf("expected query SELECT 1, got %q", ev.GetQuery())
+	}
+	if ev.GetOp() != int32(proxy.OpQuery) {
+		t.Fatalf("expected op %d, got %d", proxy.OpQuery, ev.GetOp())
+	}
+}
+
+func TestWatch_MultipleEvents(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+
+	ctx := t.Context()
+	stream, err := client.Watch(ctx, &tapv1.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+
+	for i := range 3 {
+		b.Publish(proxy.Event{
+			ID:    string(rune('A' + i)),
+			Op:    proxy.OpQuery,
+			Query: "SELECT " + string(rune('A'+i)),
+		})
+	}
+
+	for range 3 {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetEvent() == nil {
+			t.Fatal("expected event, got nil")
+		}
+	}
+}
+
+func TestExplain_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b) // explainClient is nil
+
+	ctx := t.Context()
+	_, err := client.Explain(ctx, &tapv1.ExplainRequest{
+		Query: "SELECT 1",
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected gRPC status error, got %v", err)
+	}
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", st.Code())
+	}
+}
+
+func TestKill_Validation(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8)) // explainClient is nil
+
+	if _, err := client.Kill(t.Context(), &tapv1.KillRequest{}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument without a PID, got %v", err)
+	}
+	if _, err := client.Kill(t.Context(), &tapv1.KillRequest{BackendPid: 42}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition without an explain connection, got %v", err)
+	}
+}
+
+// fakeCanceler knows the backends in pids.
+type fakeCanceler struct {
+	pids map[uint32]bool
+
+	mu        sync.Mutex
+	cancelled []uint32
+}
+
+func (f *fakeCanceler) Cancel(_ context.Context, pid uint32) error {
+	if !f.pids[pid] {
+		return proxy.ErrUnknownBackend
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cancelled = append(f.cancelled, pid)
+	return nil
+}
+
+func (f *fakeCanceler) calls() []uint32 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.cancelled)
+}
+
+func TestKill_Canceler(t *testing.T) {
+	t.Parallel()
+
+	c := &fakeCanceler{pids: map[uint32]bool{42: true}}
+	client := startServer(t, broker.New[proxy.Event](8), server.WithCanceler("", c)) // explainClient is nil
+
+	if _, err := client.Kill(t.Context(), &tapv1.KillRequest{BackendPid: 42}); err != nil {
+		t.Fatalf("cancel through the proxy: %v", err)
+	}
+	if got := c.calls(); !slices.Equal(got, []uint32{42}) {
+		t.Errorf("cancelled = %v, want [42]", got)
+	}
+	stats, err := client.Stats(t.Context(), &tapv1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.GetCancellations().GetKilled() == 0 {
+		t.Errorf("cancellations = %v, want the kill counted", stats.GetCancellations())
+	}
+
+	// Terminating, and cancelling a backend the proxy does not serve, need
+	// the explain connection.
+	for _, req := range []*tapv1.KillRequest{
+		{BackendPid: 42, Terminate: true},
+		{BackendPid: 7},
+	} {
+		if _, err := client.Kill(t.Context(), req); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("Kill(%v) = %v, want FailedPrecondition", req, err)
+		}
+	}
+	if got := c.calls(); len(got) != 1 {
+		t.Errorf("cancelled = %v, want only the first request", got)
+	}
+}
+
+func TestWatch_Upstream(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+
+	ctx := t.Context()
+	stream, err := client.Watch(ctx, &tapv1.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait briefly for the subscription to be registered.
+	time.Sleep(50 * time.Millisecond)
+
+	b.Publish(proxy.Event{ID: "1", Op: proxy.OpQuery, Query: "SELECT 1", Upstream: "replica"})
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetEvent().GetUpstream(); got != "replica" {
+		t.Fatalf("expected upstream replica, got %q", got)
+	}
+}
+
+func TestExplain_UnknownUpstream(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+
+	_, err := client.Explain(t.Context(), &tapv1.ExplainRequest{
+		Query:    "SELECT 1",
+		Upstream: "replica",
+	})
+	st, ok := status.FromError(err)
+	if !ok {
+		t.Fatalf("expected gRPC status error, got %v", err)
+	}
+	if st.Code() != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", st.Code())
+	}
+	if !strings.Contains(st.Message(), "replica") {
+		t.Fatalf("expected message to name the upstream, got %q", st.Message())
+	}
+}
+
+func TestInfo_TLSCertNotAfter(t *testing.T) {
+	t.Parallel()
+
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		opts []server.Option
+		want time.Time
+	}{
+		{name: "tls disabled", opts: nil},
+		{name: "tls enabled", opts: []server.Option{server.WithTLSCertNotAfter(notAfter, 72*time.Hour)}, want: notAfter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			client := startServer(t, broker.New[proxy.Event](8), tt.opts...)
+			resp, err := client.Info(t.Context(), &tapv1.InfoRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want.IsZero() {
+				if resp.GetTlsCertNotAfter() != nil {
+					t.Fatalf("expected no cert expiry, got %v", resp.GetTlsCertNotAfter().AsTime())
+				}
+				return
+			}
+			if got := resp.GetTlsCertNotAfter().AsTime(); !got.Equal(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			if got := resp.GetTlsCertExpiryWarning().AsDuration(); got != 72*time.Hour {
+				t.Errorf("expiry warning = %v, want 72h", got)
+			}
+		})
+	}
+}
+
+func TestInfo_TagDefs(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8), server.WithTagDefs([]tagger.Def{
+		{Name: "reporting", Color: "5"},
+		{Name: "cron"},
+	}))
+	resp, err := client.Info(t.Context(), &tapv1.InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := resp.GetTags()
+	if len(tags) != 2 || tags[0].GetName() != "reporting" || tags[0].GetColor() != "5" || tags[1].GetName() != "cron" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+}
+
+func TestInfo_Endpoints(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8), server.WithEndpoints([]server.Endpoint{
+		{Upstream: "primary", Driver: "postgres", Listen: ":5433", DSN: "postgres://app@localhost:5433/shop?sslmode=disable"},
+		{Upstream: "analytics", Driver: "mysql", Listen: "/tmp/tap.sock"},
+	}))
+	resp, err := client.Info(t.Context(), &tapv1.InfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies := resp.GetProxies()
+	if len(proxies) != 2 || proxies[0].GetUpstream() != "primary" || proxies[0].GetDsn() != "postgres://app@localhost:5433/shop?sslmode=disable" ||
+		proxies[1].GetDriver() != "mysql" || proxies[1].GetListen() != "/tmp/tap.sock" || proxies[1].GetDsn() != "" {
+		t.Fatalf("unexpected proxies: %v", proxies)
+	}
+}
+
+func TestSetVerbose(t *testing.T) {
+	t.Parallel()
+
+	v := proxy.NewVerbosity()
+	v.Open("2")
+	client := startServer(t, broker.New[proxy.Event](8), server.WithVerbosity(v))
+
+	ctx := t.Context()
+	_, err := client.SetVerbose(ctx, &tapv1.SetVerboseRequest{ConnId: "3", Verbose: true})
+	if st, ok := status.FromError(err); !ok || st.Code() != codes.NotFound {
+		t.Fatalf("expected NotFound for a connection that is not open, got %v", err)
+	}
+
+	resp, err := client.SetVerbose(ctx, &tapv1.SetVerboseRequest{ConnId: "2", Verbose: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetVerboseConnIds(); len(got) != 1 || got[0] != "2" {
+		t.Fatalf("unexpected verbose conns: %v", got)
+	}
+	if !v.Verbose("2") {
+		t.Fatal("expected conn 2 to be verbose")
+	}
+
+	resp, err = client.SetVerbose(ctx, &tapv1.SetVerboseRequest{ConnId: "2", Verbose: false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetVerboseConnIds(); len(got) != 0 {
+		t.Fatalf("expected no verbose conns, got %v", got)
+	}
+}
+
+func TestSetVerbose_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+
+	_, err := client.SetVerbose(t.Context(), &tapv1.SetVerboseRequest{ConnId: "1", Verbose: true})
+	if st, ok := status.FromError(err); !ok || st.Code() != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestStats_StreamStage(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b, server.WithStages(metrics.NewStages()))
+
+	ctx := t.Context()
+	stream, err := client.Watch(ctx, &tapv1.WatchRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait briefly for the subscription to be registered.
+	time.Sleep(50 * time.Millisecond)
+
+	b.Publish(proxy.Event{ID: "1", Op: proxy.OpQuery, Query: "SELECT 1"})
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stage is recorded right after Send returns, which may race Recv.
+	var stages []*tapv1.StageLatency
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		resp, err := client.Stats(ctx, &tapv1.StatsRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stages = resp.GetStages(); len(stages) > 0 {
+			break
+		}
+	}
+	if len(stages) != 1 || stages[0].GetName() != metrics.StageStream || stages[0].GetCount() != 1 {
+		t.Fatalf("unexpected stages: %v", stages)
+	}
+}
+
+func TestStats_Subscribers(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	client := startServer(t, b)
+
+	ctx := t.Context()
+	if _, err := client.Watch(ctx, &tapv1.WatchRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait briefly for the subscription to be registered.
+	time.Sleep(50 * time.Millisecond)
+
+	// Nobody calls Recv, so the server fills the stream and then the broker buffer drops.
+	for range 1000 {
+		b.Publish(proxy.Event{ID: "1", Op: proxy.OpQuery, Query: "SELECT 1"})
+	}
+
+	resp, err := client.Stats(ctx, &tapv1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := resp.GetSubscribers()
+	if len(subs) != 1 {
+		t.Fatalf("expected 1 subscriber, got %v", subs)
+	}
+	if subs[0].GetPolicy() != "drop" || subs[0].GetCapacity() != 1 {
+		t.Fatalf("unexpected subscriber: %v", subs[0])
+	}
+	if !strings.HasPrefix(subs[0].GetName(), "watch ") {
+		t.Fatalf("expected subscriber named after its peer, got %q", subs[0].GetName())
+	}
+	if len(resp.GetStages()) != 0 {
+		t.Fatalf("expected no stages without WithStages, got %v", resp.GetStages())
+	}
+}
+
+func TestStats_OwnSubscriber(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	client := startServer(t, b)
+	other := startServer(t, b)
+
+	for _, c := range []tapv1.TapServiceClient{client, other} {
+		if _, err := c.Watch(t.Context(), &tapv1.WatchRequest{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	resp, err := client.Stats(t.Context(), &tapv1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var own int
+	for _, sub := range resp.GetSubscribers() {
+		if sub.GetOwn() {
+			own++
+		}
+	}
+	if len(resp.GetSubscribers()) != 2 || own != 1 {
+		t.Fatalf("expected 2 subscribers, 1 of them the caller's, got %v", resp.GetSubscribers())
+	}
+}
+
+func TestWatch_Spill(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](1)
+	client := startServer(t, b, server.WithSpill(t.TempDir(), 0))
+
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{Delivery: tapv1.Delivery_DELIVERY_SPILL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	// Nobody reads until all are published: the buffer of 1 spills the rest.
+	const n = 500
+	for i := range n {
+		b.Publish(proxy.Event{ID: strconv.Itoa(i), Op: proxy.OpQuery, Query: "SELECT 1"})
+	}
+	if st := b.Stats(); st[0].Policy != broker.Spill || st[0].Dropped != 0 {
+		t.Fatalf("unexpected subscriber: %+v", st[0])
+	}
+	for i := range n {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.GetEvent().GetId(); got != strconv.Itoa(i) {
+			t.Fatalf("event %d: got id %q", i, got)
+		}
+	}
+}
+
+func TestWatch_BlockRequiresAdmin(t *testing.T) {
+	t.Parallel()
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(t.Context(), "tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := auth.New(map[string]auth.Role{"view-token": auth.RoleViewer, "admin-token": auth.RoleAdmin})
+	b := broker.New[proxy.Event](8)
+	srv := server.New(b, nil, server.WithAuthorizer(a))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	tests := []struct {
+		token    string
+		delivery tapv1.Delivery
+		want     codes.Code
+	}{
+		{token: "view-token", delivery: tapv1.Delivery_DELIVERY_BLOCK, want: codes.PermissionDenied},
+		{token: "view-token", delivery: tapv1.Delivery_DELIVERY_SPILL, want: codes.OK},
+		{token: "admin-token", delivery: tapv1.Delivery_DELIVERY_BLOCK, want: codes.OK},
+		{token: "admin-token", delivery: tapv1.Delivery(7), want: codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token+" "+tt.delivery.String(), func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := grpc.NewClient(lis.Addr().String(),
+				grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithPerRPCCredentials(auth.Token(tt.token)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+
+			stream, err := tapv1.NewTapServiceClient(conn).Watch(t.Context(), &tapv1.WatchRequest{Delivery: tt.delivery})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// An accepted stream receives events; keep publishing until one
+			// arrives, as the subscription registers asynchronously.
+			done := make(chan struct{})
+			defer close(done)
+			go func() {
+				for {
+					select {
+					case <-done:
+						return
+					case <-time.After(10 * time.Millisecond):
+						b.Publish(proxy.Event{ID: "1", Op: proxy.OpQuery})
+					}
+				}
+			}()
+			_, err = stream.Recv()
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %v, want %v (err: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for the server's goroutines to log into.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatch_ClientIdentity(t *testing.T) {
+	t.Parallel()
+
+	var audit syncBuffer
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b, server.WithAuditLog(log.New(&audit, "", 0)))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	stream, err := client.Watch(ctx, &tapv1.WatchRequest{Client: "alice@laptop\x1b[2J"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the subscription to register before publishing.
+	for b.SubscriberCount() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	b.Publish(proxy.Event{ID: "1", Op: proxy.OpQuery, Query: "SELECT 1"})
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := client.Stats(t.Context(), &tapv1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	subs := resp.GetSubscribers()
+	if len(subs) != 1 {
+		t.Fatalf("expected 1 subscriber, got %v", subs)
+	}
+	if got := subs[0].GetClient(); got != "alice@laptop[2J" {
+		t.Errorf("client = %q, want control characters stripped", got)
+	}
+	if subs[0].GetSince() == nil || subs[0].GetSince().AsTime().After(time.Now()) {
+		t.Errorf("unexpected since: %v", subs[0].GetSince())
+	}
+
+	cancel()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if strings.Contains(audit.String(), "disconnected") {
+			break
+		}
+	}
+	out := audit.String()
+	for _, want := range []string{
+		`watcher connected: client="alice@laptop[2J"`,
+		`watcher disconnected: client="alice@laptop[2J"`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("audit log missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestWatch_Collaborate(t *testing.T) {
+	t.Parallel()
+
+	hub := collab.New(10)
+	hub.Annotate(collab.Annotation{EventID: "1", Text: "already here", Author: "carol@desk"})
+	client := startServer(t, broker.New[proxy.Event](8), server.WithCollab(hub))
+	ctx := t.Context()
+
+	alice, err := client.Watch(ctx, &tapv1.WatchRequest{Client: "alice@laptop", Collaborate: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stored annotations and the presence list arrive first, in either order.
+	var gotStored, gotPresence bool
+	for !gotStored || !gotPresence {
+		resp, err := alice.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case resp.GetAnnotation().GetText() == "already here":
+			gotStored = true
+		case len(resp.GetPresence().GetClients()) == 1:
+			gotPresence = true
+		default:
+			t.Fatalf("unexpected response: %v", resp)
+		}
+	}
+
+	if _, err := client.Watch(ctx, &tapv1.WatchRequest{Client: "bob@ci", Collaborate: true}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := alice.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetPresence().GetClients(); len(got) != 2 || got[1] != "bob@ci" {
+		t.Fatalf("presence = %v, want alice and bob", got)
+	}
+
+	if _, err := client.Annotate(ctx, &tapv1.AnnotateRequest{EventId: "2", Text: "look\nhere", Client: "bob@ci"}); err != nil {
+		t.Fatal(err)
+	}
+	for {
+		resp, err := alice.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a := resp.GetAnnotation(); a != nil {
+			if a.GetEventId() != "2" || a.GetText() != "lookhere" || a.GetAuthor() != "bob@ci" {
+				t.Fatalf("unexpected annotation: %v", a)
+			}
+			break
+		}
+	}
+
+	_, err = client.Annotate(ctx, &tapv1.AnnotateRequest{Text: "no event"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestAnnotate_Disabled(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+	_, err := client.Annotate(t.Context(), &tapv1.AnnotateRequest{EventId: "1", Text: "x"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestWatch_Sampling(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](16)
+	client := startServer(t, b)
+
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{
+		Sampling: sample.Config{MaxPerSecond: 2}.Proto(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b.SubscriberCount() == 0 {
+		time.Sleep(5 * time.Millisecond)
+	}
+	for i := range 5 {
+		b.Publish(proxy.Event{ID: string(rune('a' + i)), Op: proxy.OpQuery, Query: "SELECT 1"})
+	}
+	b.Publish(proxy.Event{ID: "err", Op: proxy.OpQuery, Query: "SELECT 1", Error: "boom"})
+
+	// The limit keeps two events a second; the failure always gets through.
+	// A second boundary mid-burst can let one more through.
+	var ids []string
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, resp.GetEvent().GetId())
+		if resp.GetEvent().GetId() == "err" {
+			break
+		}
+	}
+	if len(ids) < 3 || len(ids) > 5 || ids[0] != "a" || ids[1] != "b" {
+		t.Fatalf("received %v, want a, b, (c, d,) err", ids)
+	}
+}
+
+func TestWatch_InvalidSampling(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](1))
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{
+		Sampling: &tapv1.Sampling{Rate: 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestStats_SampledOut(t *testing.T) {
+	t.Parallel()
+
+	sm := sample.New(sample.Config{MaxPerSecond: 1})
+	now := time.Now()
+	sm.Keep(proxy.Event{Query: "SELECT 1"}, now)
+	sm.Keep(proxy.Event{Query: "SELECT 1"}, now)
+
+	client := startServer(t, broker.New[proxy.Event](1), server.WithSampler(sm))
+	resp, err := client.Stats(t.Context(), &tapv1.StatsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetSampledOut() != 1 {
+		t.Fatalf("sampled_out = %d, want 1", resp.GetSampledOut())
+	}
+}
+
+func TestQuery(t *testing.T) {
+	t.Parallel()
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, q := range []string{"SELECT * FROM users WHERE id = 1", "SELECT * FROM orders", "SELECT * FROM users WHERE id = 2"} {
+		ev := proxy.Event{ID: string(rune('1' + i)), Op: proxy.OpQuery, Query: q, StartTime: start.Add(time.Duration(i) * time.Second)}
+		if err := st.Append(server.EventToProto(ev)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := startServer(t, broker.New[proxy.Event](1), server.WithStore(st))
+	resp, err := client.Query(t.Context(), &tapv1.QueryRequest{Query: "SELECT * FROM users WHERE id = 42"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if evs := resp.GetEvents(); len(evs) != 2 || evs[0].GetId() != "1" || evs[1].GetId() != "3" {
+		t.Fatalf("unexpected events: %v", evs)
+	}
+
+	if _, err := client.Query(t.Context(), &tapv1.QueryRequest{Limit: -1}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestWatch_Resume(t *testing.T) {
+	t.Parallel()
+
+	st, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = st.Close() })
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	stored := make([]proxy.Event, 3)
+	for i := range stored {
+		stored[i] = proxy.Event{ID: string(rune('1' + i)), Op: proxy.OpQuery, Query: "SELECT 1", StartTime: start.Add(time.Duration(i) * time.Second)}
+		if err := st.Append(server.EventToProto(stored[i])); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b, server.WithStore(st))
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{ResumeAfter: timestamppb.New(start)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b.SubscriberCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Published while the replay ran: sent once, not again live.
+	b.Publish(stored[2])
+	b.Publish(proxy.Event{ID: "4", Op: proxy.OpQuery, Query: "SELECT 1", StartTime: start.Add(3 * time.Second)})
+
+	var got []string
+	for range 3 {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, resp.GetEvent().GetId())
+	}
+	if !slices.Equal(got, []string{"2", "3", "4"}) {
+		t.Errorf("events = %v, want the stored 2 and 3, then live 4", got)
+	}
+}
+
+func TestQuery_Disabled(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](1))
+	if _, err := client.Query(t.Context(), &tapv1.QueryRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(10)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.Observe(proxy.Event{ID: "1", TxID: "a", Op: proxy.OpBegin, StartTime: start})
+	tr.Observe(proxy.Event{ID: "2", TxID: "a", Op: proxy.OpExec, Query: "UPDATE t", StartTime: start.Add(time.Millisecond)})
+	tr.Observe(proxy.Event{ID: "3", TxID: "a", Op: proxy.OpCommit, StartTime: start.Add(5 * time.Millisecond)})
+
+	client := startServer(t, broker.New[proxy.Event](8), server.WithTxTracker(tr))
+	resp, err := client.Transactions(t.Context(), &tapv1.TransactionsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txs := resp.GetTransactions()
+	if len(txs) != 1 {
+		t.Fatalf("expected 1 transaction, got %d", len(txs))
+	}
+	tx := txs[0]
+	if tx.GetTxId() != "a" || tx.GetStatus() != tapv1.TxStatus_TX_STATUS_COMMITTED {
+		t.Fatalf("unexpected transaction: %v", tx)
+	}
+	if len(tx.GetEvents()) != 3 || tx.GetEvents()[1].GetQuery() != "UPDATE t" {
+		t.Fatalf("unexpected events: %v", tx.GetEvents())
+	}
+	if d := tx.GetDuration().AsDuration(); d != 5*time.Millisecond {
+		t.Fatalf("duration = %v, want 5ms", d)
+	}
+}
+
+func TestTransactions_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+
+	_, err := client.Transactions(t.Context(), &tapv1.TransactionsRequest{})
+	if st, ok := status.FromError(err); !ok || st.Code() != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	t.Parallel()
+
+	tr := routes.New()
+	now := time.Now()
+	tr.Observe(proxy.Event{Op: proxy.OpQuery, Route: "GET /users/{id}", Duration: time.Millisecond}, now)
+	tr.Observe(proxy.Event{Op: proxy.OpQuery, Route: "GET /users/{id}", Duration: time.Millisecond, Error: "timeout"}, now)
+
+	client := startServer(t, broker.New[proxy.Event](8), server.WithRoutes(tr))
+	resp, err := client.Routes(t.Context(), &tapv1.RoutesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs := resp.GetRoutes()
+	if len(rs) != 1 || rs[0].GetRoute() != "GET /users/{id}" || rs[0].GetCount() != 2 || rs[0].GetErrors() != 1 {
+		t.Fatalf("unexpected routes: %v", rs)
+	}
+	if resp.GetWindow().AsDuration() != routes.Window || resp.GetQueryBudget() != routes.DefaultQueryBudget {
+		t.Errorf("window = %v, budget = %d", resp.GetWindow().AsDuration(), resp.GetQueryBudget())
+	}
+}
+
+func TestRoutes_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+	if _, err := client.Routes(t.Context(), &tapv1.RoutesRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	t.Parallel()
+
+	tr := tenants.New("tenant_id", tenants.WithMaxShare(0.3))
+	now := time.Now()
+	for _, tenant := range []string{"acme", "acme", "globex", ""} {
+		ev := proxy.Event{Op: proxy.OpQuery, Query: "SELECT 1", Duration: time.Millisecond}
+		if tenant != "" {
+			ev.Fields = map[string]string{"tenant_id": tenant}
+		}
+		tr.Observe(ev, now)
+	}
+
+	client := startServer(t, broker.New[proxy.Event](8), server.WithTenants(tr))
+	resp, err := client.Tenants(t.Context(), &tapv1.TenantsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := resp.GetTenants()
+	if len(ts) != 2 || ts[0].GetValue() != "acme" || ts[0].GetCount() != 2 || ts[0].GetShare() != 0.5 {
+		t.Fatalf("unexpected tenants: %v", ts)
+	}
+	if resp.GetField() != "tenant_id" || resp.GetMaxShare() != 0.3 || resp.GetWindow().AsDuration() != tenants.Window {
+		t.Errorf("field = %q, max share = %v, window = %v", resp.GetField(), resp.GetMaxShare(), resp.GetWindow().AsDuration())
+	}
+}
+
+func TestTenants_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+	if _, err := client.Tenants(t.Context(), &tapv1.TenantsRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestDatabases(t *testing.T) {
+	t.Parallel()
+
+	tr := databases.New()
+	now := time.Now()
+	for _, db := range []string{"billing", "billing", "billing", "auth"} {
+		tr.Observe(proxy.Event{Op: proxy.OpQuery, Query: "SELECT 1", Database: db, User: "app", Duration: time.Millisecond}, now)
+	}
+
+	client := startServer(t, broker.New[proxy.Event](8), server.WithDatabases(tr))
+	resp, err := client.Databases(t.Context(), &tapv1.DatabasesRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dbs := resp.GetDatabases()
+	if len(dbs) != 2 || dbs[0].GetDatabase() != "billing" || dbs[0].GetCount() != 3 || dbs[0].GetShare() != 0.75 {
+		t.Fatalf("unexpected databases: %v", dbs)
+	}
+	if users := dbs[0].GetUsers(); len(users) != 1 || users[0] != "app" {
+		t.Errorf("users = %v, want [app]", users)
+	}
+	if resp.GetWindow().AsDuration() != databases.Window {
+		t.Errorf("window = %v", resp.GetWindow().AsDuration())
+	}
+}
+
+func TestDatabases_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+	if _, err := client.Databases(t.Context(), &tapv1.DatabasesRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+type statementsSource []explain.Statement
+
+func (s statementsSource) Statements(context.Context, int) ([]explain.Statement, error) {
+	return s, nil
+}
+
+func TestStatements(t *testing.T) {
+	t.Parallel()
+
+	p := pgstat.New(map[string]pgstat.Source{"": statementsSource{
+		{Query: "SELECT * FROM orders WHERE id = $1", Calls: 40, Total: 80 * time.Millisecond, Rows: 40},
+	}}, pgstat.WithInterval(time.Minute))
+	client := startServer(t, broker.New[proxy.Event](8), server.WithStatements(p))
+
+	resp, err := client.Statements(t.Context(), &tapv1.StatementsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetPolledAt() != nil || len(resp.GetStatements()) != 0 {
+		t.Fatalf("expected nothing before the first poll, got %v", resp)
+	}
+
+	p.Poll(t.Context())
+	resp, err = client.Statements(t.Context(), &tapv1.StatementsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sts := resp.GetStatements()
+	if len(sts) != 1 || sts[0].GetFingerprint() != "SELECT * FROM orders WHERE id = ?" || sts[0].GetCalls() != 40 ||
+		sts[0].GetTotal().AsDuration() != 80*time.Millisecond || sts[0].GetRows() != 40 {
+		t.Fatalf("unexpected statements: %v", sts)
+	}
+	if resp.GetPolledAt() == nil || resp.GetInterval().AsDuration() != time.Minute {
+		t.Errorf("polled at %v, interval %v", resp.GetPolledAt(), resp.GetInterval().AsDuration())
+	}
+}
+
+func TestStatements_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+	if _, err := client.Statements(t.Context(), &tapv1.StatementsRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Parallel()
+
+	doc := []byte("flags:\n  grpc: :9091\n")
+	client := startServer(t, broker.New[proxy.Event](8), server.WithEffectiveConfig(doc))
+	resp, err := client.Config(t.Context(), &tapv1.ConfigRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.GetYaml() != string(doc) {
+		t.Fatalf("yaml = %q, want %q", resp.GetYaml(), doc)
+	}
+}
+
+func TestConfig_NotConfigured(t *testing.T) {
+	t.Parallel()
+
+	client := startServer(t, broker.New[proxy.Event](8))
+	if _, err := client.Config(t.Context(), &tapv1.ConfigRequest{}); status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("expected FailedPrecondition, got %v", err)
+	}
+}
+
+func TestAuthorizer_Roles(t *testing.T) {
+	t.Parallel()
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(t.Context(), "tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := auth.New(map[string]auth.Role{
+		"view-token":    auth.RoleViewer,
+		"analyst-token": auth.RoleAnalyst,
+		"admin-token":   auth.RoleAdmin,
+	})
+	verbosity := proxy.NewVerbosity()
+	verbosity.Open("1")
+	srv := server.New(broker.New[proxy.Event](8), nil, server.WithAuthorizer(a), server.WithVerbosity(verbosity))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	client := func(t *testing.T, token string) tapv1.TapServiceClient {
+		t.Helper()
+		opts := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+		if token != "" {
+			opts = append(opts, grpc.WithPerRPCCredentials(auth.Token(token)))
+		}
+		conn, err := grpc.NewClient(lis.Addr().String(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return tapv1.NewTapServiceClient(conn)
+	}
+	stats := func(t *testing.T, c tapv1.TapServiceClient) error {
+		t.Helper()
+		_, err := c.Stats(t.Context(), &tapv1.StatsRequest{})
+		return err
+	}
+	explain := func(t *testing.T, c tapv1.TapServiceClient) error {
+		t.Helper()
+		_, err := c.Explain(t.Context(), &tapv1.ExplainRequest{Query: "SELECT 1"})
+		return err
+	}
+	setVerbose := func(t *testing.T, c tapv1.TapServiceClient) error {
+		t.Helper()
+		_, err := c.SetVerbose(t.Context(), &tapv1.SetVerboseRequest{ConnId: "1", Verbose: true})
+		return err
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		call  func(*testing.T, tapv1.TapServiceClient) error
+		want  codes.Code
+	}{
+		{name: "no token", call: stats, want: codes.Unauthenticated},
+		{name: "unknown token", token: "nope", call: stats, want: codes.Unauthenticated},
+		{name: "viewer stats", token: "view-token", call: stats, want: codes.OK},
+		{name: "viewer explain", token: "view-token", call: explain, want: codes.PermissionDenied},
+		// Allowed through; fails because EXPLAIN is not configured.
+		{name: "analyst explain", token: "analyst-token", call: explain, want: codes.FailedPrecondition},
+		{name: "analyst set verbose", token: "analyst-token", call: setVerbose, want: codes.PermissionDenied},
+		{name: "admin set verbose", token: "admin-token", call: setVerbose, want: codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			err := tt.call(t, client(t, tt.token))
+			if got := status.Code(err); got != tt.want {
+				t.Fatalf("code = %v, want %v (err: %v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHealthAndReflection(t *testing.T) {
+	t.Parallel()
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(t.Context(), "tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := auth.New(map[string]auth.Role{"view-token": auth.RoleViewer})
+	srv := server.New(broker.New[proxy.Event](8), nil, server.WithAuthorizer(a))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	dial := func(t *testing.T, opts ...grpc.DialOption) *grpc.ClientConn {
+		t.Helper()
+		opts = append(opts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err := grpc.NewClient(lis.Addr().String(), opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+
+	// Probes carry no token.
+	hc := healthpb.NewHealthClient(dial(t))
+	for _, svc := range []string{"", tapv1.TapService_ServiceDesc.ServiceName} {
+		resp, err := hc.Check(t.Context(), &healthpb.HealthCheckRequest{Service: svc})
+		if err != nil {
+			t.Fatalf("Check(%q): %v", svc, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q) = %v, want SERVING", svc, resp.GetStatus())
+		}
+	}
+
+	listServices := func(t *testing.T, conn *grpc.ClientConn) ([]string, error) {
+		t.Helper()
+		stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(t.Context())
+		if err != nil {
+			return nil, err
+		}
+		req := &reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+		}
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, s := range resp.GetListServicesResponse().GetService() {
+			names = append(names, s.GetName())
+		}
+		return names, nil
+	}
+	if _, err := listServices(t, dial(t)); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("reflection without a token: err = %v, want Unauthenticated", err)
+	}
+	names, err := listServices(t, dial(t, grpc.WithPerRPCCredentials(auth.Token("view-token"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Contains(names, tapv1.TapService_ServiceDesc.ServiceName) {
+		t.Errorf("services = %v, want %s listed", names, tapv1.TapService_ServiceDesc.ServiceName)
+	}
+}
+
+func TestEventToProto_ErrorDetail(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Error: `relation "users" does not exist`,
+		ErrorDetail: &proxy.ErrorDetail{
+			Code:     "42P01",
+			Severity: "ERROR",
+			Message:  `relation "users" does not exist`,
+			Hint:     "Check the search_path.",
+			Position: 15,
+		},
+	})
+	d := ev.GetErrorDetail()
+	if d.GetCode() != "42P01" || d.GetSeverity() != "ERROR" || d.GetPosition() != 15 || d.GetHint() != "Check the search_path." {
+		t.Fatalf("unexpected error detail: %v", d)
+	}
+
+	if got := server.EventToProto(proxy.Event{}).GetErrorDetail(); got != nil {
+		t.Fatalf("expected no error detail, got %v", got)
+	}
+}
+
+func TestEventToProto_Anomaly(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Anomaly: &proxy.Anomaly{Score: 5.5, Baseline: 2 * time.Millisecond},
+	})
+	a := ev.GetAnomaly()
+	if a.GetScore() != 5.5 || a.GetBaseline().AsDuration() != 2*time.Millisecond {
+		t.Fatalf("unexpected anomaly: %v", a)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetAnomaly(); got != nil {
+		t.Fatalf("expected no anomaly, got %v", got)
+	}
+}
+
+func TestEventToProto_NPlusOne(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		NPlusOne: &proxy.NPlusOne{Calls: 12, Span: 40 * time.Millisecond, InTx: true},
+	})
+	n := ev.GetNPlusOne()
+	if n.GetCalls() != 12 || n.GetSpan().AsDuration() != 40*time.Millisecond || !n.GetInTx() {
+		t.Fatalf("unexpected n+1: %v", n)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetNPlusOne(); got != nil {
+		t.Fatalf("expected no n+1, got %v", got)
+	}
+}
+
+func TestEventToProto_Quota(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:    proxy.OpAdvisory,
+		Quota: &proxy.Quota{Field: "tenant_id", Value: "acme", Calls: 80, Total: 100, MaxShare: 0.5, Window: time.Minute},
+	})
+	q := ev.GetQuota()
+	if q.GetField() != "tenant_id" || q.GetValue() != "acme" || q.GetCalls() != 80 || q.GetTotal() != 100 ||
+		q.GetMaxShare() != 0.5 || q.GetWindow().AsDuration() != time.Minute {
+		t.Fatalf("unexpected quota: %v", q)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetQuota(); got != nil {
+		t.Fatalf("expected no quota, got %v", got)
+	}
+}
+
+func TestEventToProto_Panic(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:    proxy.OpAdvisory,
+		Error: "panic in pipeline: boom",
+		Panic: &proxy.Panic{Where: "pipeline", Value: "boom", Stack: "goroutine 1 [running]:"},
+	})
+	p := ev.GetPanic()
+	if p.GetWhere() != "pipeline" || p.GetValue() != "boom" || p.GetStack() != "goroutine 1 [running]:" {
+		t.Fatalf("unexpected panic: %v", p)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetPanic(); got != nil {
+		t.Fatalf("expected no panic, got %v", got)
+	}
+}
+
+func TestEventToProto_Diagnostic(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:         proxy.OpAdvisory,
+		Error:      "postgres: replica cancel: connection refused",
+		Diagnostic: &proxy.Diagnostic{Level: slog.LevelWarn, Message: "replica cancel failed"},
+	})
+	d := ev.GetDiagnostic()
+	if d.GetLevel() != "WARN" || d.GetMessage() != "replica cancel failed" {
+		t.Fatalf("unexpected diagnostic: %v", d)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetDiagnostic(); got != nil {
+		t.Fatalf("expected no diagnostic, got %v", got)
+	}
+}
+
+func TestEventToProto_Plan(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:   proxy.OpAdvisory,
+		Plan: &proxy.Plan{ForID: "42", Analyze: true, Text: "Seq Scan on orders", Indexes: []string{"CREATE INDEX CONCURRENTLY ON orders (id);"}},
+	})
+	p := ev.GetPlan()
+	if p.GetForId() != "42" || !p.GetAnalyze() || p.GetPlan() != "Seq Scan on orders" || len(p.GetIndexes()) != 1 {
+		t.Fatalf("unexpected plan: %v", p)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetPlan(); got != nil {
+		t.Fatalf("expected no plan, got %v", got)
+	}
+}
+
+func TestEventToProto_Latency(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:           proxy.OpExecute,
+		Duration:     5 * time.Millisecond,
+		QueueLatency: time.Millisecond,
+		ExecLatency:  3 * time.Millisecond,
+		FetchLatency: time.Millisecond,
+	})
+	got := []time.Duration{
+		ev.GetQueueLatency().AsDuration(), ev.GetExecLatency().AsDuration(), ev.GetFetchLatency().AsDuration(),
+	}
+	want := []time.Duration{time.Millisecond, 3 * time.Millisecond, time.Millisecond}
+	if !slices.Equal(got, want) {
+		t.Fatalf("latency = %v, want %v", got, want)
+	}
+	if got := server.EventToProto(proxy.Event{}); got.GetQueueLatency() != nil || got.GetExecLatency() != nil || got.GetFetchLatency() != nil {
+		t.Fatalf("expected no latency breakdown, got %v %v %v",
+			got.GetQueueLatency(), got.GetExecLatency(), got.GetFetchLatency())
+	}
+}
+
+func TestEventToProto_Extensions(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{Op: proxy.OpQuery, Extensions: map[string]string{"deploy": "v1.2.3"}})
+	if got := ev.GetExtensions(); got["deploy"] != "v1.2.3" || len(got) != 1 {
+		t.Fatalf("extensions = %v, want deploy=v1.2.3", got)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetExtensions(); got != nil {
+		t.Fatalf("expected no extensions, got %v", got)
+	}
+}
+
+func TestEventToProto_ConnMetadata(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Query:      "SELECT * FROM users WHERE id = 1",
+		ClientAddr: "10.0.0.5:51234",
+		Listener:   "lan",
+		User:       "app",
+		Database:   "shop",
+		Route:      "GET /users/{id}",
+		BackendPID: 4242,
+	})
+	if ev.GetFingerprint() != "SELECT * FROM users WHERE id = ?" {
+		t.Errorf("fingerprint = %q", ev.GetFingerprint())
+	}
+	if ev.GetClientAddr() != "10.0.0.5:51234" || ev.GetUser() != "app" || ev.GetDatabase() != "shop" {
+		t.Errorf("unexpected conn metadata: %q %q %q", ev.GetClientAddr(), ev.GetUser(), ev.GetDatabase())
+	}
+	if ev.GetListener() != "lan" {
+		t.Errorf("listener = %q", ev.GetListener())
+	}
+	if ev.GetRoute() != "GET /users/{id}" {
+		t.Errorf("route = %q", ev.GetRoute())
+	}
+	if ev.GetBackendPid() != 4242 {
+		t.Errorf("backend pid = %d", ev.GetBackendPid())
+	}
+
+	if ev.GetAuthDuration() != nil || ev.GetServerParams() != nil {
+		t.Errorf("unexpected startup metadata: %v %v", ev.GetAuthDuration(), ev.GetServerParams())
+	}
+
+	// A fingerprint set by proxy.Emit is passed through.
+	if got := server.EventToProto(proxy.Event{Query: "SELECT 1", Fingerprint: "fp"}).GetFingerprint(); got != "fp" {
+		t.Errorf("fingerprint = %q, want fp", got)
+	}
+}
+
+func TestEventToProto_Startup(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		AuthMethod:   "SCRAM-SHA-256",
+		AuthDuration: 3 * time.Millisecond,
+		ServerParams: map[string]string{"server_version": "17.2", "TimeZone": "UTC"},
+	})
+	if ev.GetAuthMethod() != "SCRAM-SHA-256" {
+		t.Errorf("auth method = %q", ev.GetAuthMethod())
+	}
+	if got := ev.GetAuthDuration().AsDuration(); got != 3*time.Millisecond {
+		t.Errorf("auth duration = %v, want 3ms", got)
+	}
+	if got := ev.GetServerParams(); got["server_version"] != "17.2" || got["TimeZone"] != "UTC" {
+		t.Errorf("server params = %v", got)
+	}
+}
+
+func TestEventToProto_Bytes(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{RequestBytes: 120, ResponseBytes: 3 << 20})
+	if ev.GetRequestBytes() != 120 || ev.GetResponseBytes() != 3<<20 {
+		t.Errorf("bytes = %d/%d, want 120/%d", ev.GetRequestBytes(), ev.GetResponseBytes(), 3<<20)
+	}
+}
+
+func TestEventToProto_Batch(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{Op: proxy.OpBatch, BatchID: "b1", BatchSize: 3})
+	if ev.GetOp() != int32(proxy.OpBatch) || ev.GetBatchId() != "b1" || ev.GetBatchSize() != 3 {
+		t.Errorf("batch = op %d, %q x%d", ev.GetOp(), ev.GetBatchId(), ev.GetBatchSize())
+	}
+}
+
+func TestEventToProto_Notice(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:        proxy.OpNotice,
+		Notice:    &proxy.ErrorDetail{Severity: "WARNING", Message: "nonstandard use of \\' in a string literal"},
+		NoticeFor: "7",
+	})
+	if ev.GetNotice().GetSeverity() != "WARNING" || ev.GetNoticeFor() != "7" || ev.GetErrorDetail() != nil {
+		t.Errorf("notice = %v for %q, error detail %v", ev.GetNotice(), ev.GetNoticeFor(), ev.GetErrorDetail())
+	}
+}
+
+func TestEventToProto_Connect(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:            proxy.OpConnect,
+		StartupParams: map[string]string{"application_name": "api", "options": "-c search_path=app"},
+		SSLRequested:  true,
+	})
+	if ev.GetStartupParams()["options"] != "-c search_path=app" || len(ev.GetStartupParams()) != 2 || !ev.GetSslRequested() {
+		t.Errorf("startup = %v, ssl requested %v", ev.GetStartupParams(), ev.GetSslRequested())
+	}
+}
+
+func TestEventToProto_Disconnect(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{Op: proxy.OpDisconnect, ConnID: "c1", Queries: 12, Duration: time.Minute})
+	if ev.GetOp() != int32(proxy.OpDisconnect) || ev.GetQueries() != 12 || ev.GetDuration().AsDuration() != time.Minute {
+		t.Errorf("disconnect = %v", ev)
+	}
+}
+
+func TestWatch_SelectorFields(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{Selector: &tapv1.Selector{
+		Fields: map[string]string{"tenant_id": "42"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b.SubscriberCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, ev := range []proxy.Event{
+		{ID: "1", Op: proxy.OpQuery},
+		{ID: "2", Op: proxy.OpQuery, Fields: map[string]string{"tenant_id": "7"}},
+		{ID: "3", Op: proxy.OpQuery, Fields: map[string]string{"tenant_id": "42", "region": "eu"}},
+	} {
+		b.Publish(ev)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetEvent(); got.GetId() != "3" || got.GetFields()["region"] != "eu" {
+		t.Errorf("first event = %v, want 3, the only one selected", got)
+	}
+}
+
+func TestWatch_SelectorDatabase(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{Selector: &tapv1.Selector{
+		Databases: []string{"billing", "orders"},
+		Users:     []string{"app"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b.SubscriberCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for _, ev := range []proxy.Event{
+		{ID: "1", Op: proxy.OpQuery, Database: "auth", User: "app"},
+		{ID: "2", Op: proxy.OpQuery, Database: "orders", User: "admin"},
+		{ID: "3", Op: proxy.OpQuery},
+		{ID: "4", Op: proxy.OpQuery, Database: "orders", User: "app"},
+	} {
+		b.Publish(ev)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetEvent(); got.GetId() != "4" || got.GetDatabase() != "orders" {
+		t.Errorf("first event = %v, want 4, the only one selected", got)
+	}
+}
+
+func TestEventToProto_Routing(t *testing.T) {
+	t.Parallel()
+
+	r := server.EventToProto(proxy.Event{Routing: &proxy.Routing{Replica: true, Reason: "read-only"}}).GetRouting()
+	if !r.GetReplica() || r.GetReason() != "read-only" {
+		t.Errorf("routing = %v", r)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetRouting(); got != nil {
+		t.Errorf("expected no routing, got %v", got)
+	}
+}
+
+func TestEventToProto_Violation(t *testing.T) {
+	t.Parallel()
+
+	v := server.EventToProto(proxy.Event{Violation: &proxy.Violation{Rule: "no-drop", Message: "blocked", Blocked: true}}).GetViolation()
+	if v.GetRule() != "no-drop" || v.GetMessage() != "blocked" || !v.GetBlocked() {
+		t.Errorf("violation = %v", v)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetViolation(); got != nil {
+		t.Errorf("expected no violation, got %v", got)
+	}
+}
+
+func TestEventToProto_Columns(t *testing.T) {
+	t.Parallel()
+
+	cols := server.EventToProto(proxy.Event{Columns: []proxy.Column{
+		{Name: "id", Type: "int8"},
+		{Name: "na\xffme", Type: "text"},
+	}}).GetColumns()
+	if len(cols) != 2 || cols[0].GetName() != "id" || cols[0].GetType() != "int8" || cols[1].GetName() != "na\uFFFDme" {
+		t.Errorf("columns = %v", cols)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetColumns(); got != nil {
+		t.Errorf("expected no columns, got %v", got)
+	}
+}
+
+func TestEventToProto_Traffic(t *testing.T) {
+	t.Parallel()
+
+	ev := server.EventToProto(proxy.Event{
+		Op:      proxy.OpAdvisory,
+		Query:   "SELECT * FROM users WHERE id = ?",
+		Traffic: &proxy.TrafficChange{Kind: proxy.TrafficRise, Calls: 400, Baseline: 30, Window: time.Minute},
+	})
+	c := ev.GetTraffic()
+	if c.GetKind() != tapv1.TrafficKind_TRAFFIC_KIND_RISE || c.GetCalls() != 400 || c.GetBaseline() != 30 || c.GetWindow().AsDuration() != time.Minute {
+		t.Fatalf("unexpected traffic change: %v", c)
+	}
+	if got := server.EventToProto(proxy.Event{}).GetTraffic(); got != nil {
+		t.Fatalf("expected no traffic change, got %v", got)
+	}
+}
+
+func TestWatch_Selector(t *testing.T) {
+	t.Parallel()
+
+	b := broker.New[proxy.Event](8)
+	client := startServer(t, b)
+	stream, err := client.Watch(t.Context(), &tapv1.WatchRequest{Selector: &tapv1.Selector{
+		Upstreams:         []string{"replica"},
+		Ops:               []string{"Query", "Execute"},
+		FingerprintPrefix: "select * from orders where id = 1",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for b.SubscriberCount() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	orders := "SELECT * FROM orders WHERE id = ? AND status = ?"
+	for _, ev := range []proxy.Event{
+		{ID: "1", Op: proxy.OpQuery, Upstream: "primary", Fingerprint: orders},
+		{ID: "2", Op: proxy.OpBegin, Upstream: "replica", Fingerprint: "BEGIN"},
+		{ID: "3", Op: proxy.OpQuery, Upstream: "replica", Fingerprint: "SELECT * FROM users"},
+		{ID: "4", Op: proxy.OpExecute, Upstream: "replica", Fingerprint: orders},
+	} {
+		b.Publish(ev)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.GetEvent().GetId(); got != "4" {
+		t.Errorf("first event = %s, want 4, the only one selected", got)
+	}
+	if st := b.Stats(); len(st) != 1 || !st[0].Filtered {
+		t.Errorf("stats = %+v, want a filtered subscription", st)
+	}
+
+	bad, err := client.Watch(t.Context(), &tapv1.WatchRequest{Selector: &tapv1.Selector{Ops: []string{"Select"}}})
+	if err == nil {
+		_, err = bad.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument for an unknown op, got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+// Package stats maintains rolling latency percentiles, throughput, and error
+// rates over a sliding window, overall and per key (e.g. query fingerprint).
+package stats
+
+import (
+	"cmp"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+)
+
+const (
+	// DefaultResolution and DefaultBuckets give a one-minute window in
+	// one-second steps.
+	DefaultResolution = time.Second
+	DefaultBuckets    = 60
+
+	// maxSamples bounds the latencies kept per bucket of a series. Beyond it
+	// samples are replaced at random (reservoir sampling), so percentiles
+	// stay representative of busy buckets.
+	maxSamples = 512
+
+	// maxKeys bounds the per-key series; keys idle for the whole window are
+	// dropped first.
+	maxKeys = 1000
+)
+
+// Aggregator accumulates observations into fixed-width time buckets. It is
+// not safe for concurrent use.
+type Aggregator struct {
+	resolution time.Duration
+	buckets    int
+	overall    *series
+	keys       map[string]*series
+}
+
+// New returns an Aggregator whose window is buckets steps of resolution.
+func New(resolution time.Duration, buckets int) *Aggregator {
+	return &Aggregator{
+		resolution: resolution,
+		buckets:    buckets,
+		overall:    newSeries(buckets),
+		keys:       make(map[string]*series),
+	}
+}
+
+// Observe records one query under key at time at.
+func (a *Aggregator) Observe(key string, at time.Time, d time.Duration, failed bool) {
+	slot := a.slot(at)
+	a.overall.observe(slot, d, failed)
+
+	s, ok := a.keys[key]
+	if !ok {
+		if len(a.keys) >= maxKeys {
+			a.prune(slot)
+		}
+		if len(a.keys) >= maxKeys {
+			return
+		}
+		s = newSeries(a.buckets)
+		a.keys[key] = s
+	}
+	s.observe(slot, d, failed)
+}
+
+func (a *Aggregator) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(a.resolution)
+}
+
+// prune drops the keys with no observations in the window ending at slot.
+func (a *Aggregator) prune(slot int64) {
+	for k, s := range a.keys {
+		if s.last <= slot-int64(a.buckets) {
+			delete(a.keys, k)
+		}
+	}
+}
+
+// Summary describes the window ending at the time it was computed for.
+type Summary struct {
+	Count     int
+	Errors    int
+	QPS       float64
+	ErrorRate float64 // failed fraction of Count, 0 to 1
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+
+	// Per-bucket series, oldest first, for sparklines.
+	Counts      []int
+	ErrorCounts []int
+	P99s        []time.Duration
+}
+
+// KeySummary is the Summary of one key.
+type KeySummary struct {
+	Key string
+	Summary
+}
+
+// Overall summarizes every observation in the window ending at now.
+func (a *Aggregator) Overall(now time.Time) Summary {
+	return a.overall.summary(a.slot(now), a.buckets, a.resolution)
+}
+
+// Keys summarizes each key with observations in the window ending at now,
+// busiest first.
+func (a *Aggregator) Keys(now time.Time) []KeySummary {
+	slot := a.slot(now)
+	a.prune(slot)
+	out := make([]KeySummary, 0, len(a.keys))
+	for k, s := range a.keys {
+		out = append(out, KeySummary{Key: k, Summary: s.summary(slot, a.buckets, a.resolution)})
+	}
+	slices.SortFunc(out, func(x, y KeySummary) int {
+		return cmp.Or(
+			cmp.Compare(y.Count, x.Count),
+			cmp.Compare(y.P99, x.P99),
+			cmp.Compare(x.Key, y.Key),
+		)
+	})
+	return out
+}
+
+// series is a ring of buckets indexed by slot modulo its length.
+type series struct {
+	buckets []bucket
+	last    int64 // newest slot observed
+}
+
+type bucket struct {
+	slot    int64 // which slot the bucket currently holds
+	count   int
+	errors  int
+	samples []time.Duration
+}
+
+func newSeries(n int) *series {
+	s := &series{buckets: make([]bucket, n)}
+	for i := range s.buckets {
+		s.buckets[i].slot = -1
+	}
+	return s
+}
+
+func (s *series) observe(slot int64, d time.Duration, failed bool) {
+	if slot <= s.last-int64(len(s.buckets)) {
+		return // older than the window
+	}
+	b := &s.buckets[slot%int64(len(s.buckets))]
+	if b.slot != slot {
+		*b = bucket{slot: slot, samples: b.samples[:0]}
+	}
+	s.last = max(s.last, slot)
+
+	b.count++
+	if failed {
+		b.errors++
+	}
+	if len(b.samples) < maxSamples {
+		b.samples = append(b.samples, d)
+	} else if i := rand.IntN(b.count); i < maxSamples { //nolint:gosec // sampling, not security
+		b.samples[i] = d
+	}
+}
+
+// summary covers the n slots ending at slot.
+func (s *series) summary(slot int64, n int, resolution time.Duration) Summary {
+	sum := Summary{
+		Counts:      make([]int, n),
+		ErrorCounts: make([]int, n),
+		P99s:        make([]time.Duration, n),
+	}
+	var all []time.Duration
+	for i := range n {
+		want := slot - int64(n-1-i)
+		if want < 0 {
+			continue
+		}
+		b := &s.buckets[want%int64(len(s.buckets))]
+		if b.slot != want || b.count == 0 {
+			continue
+		}
+		sum.Counts[i] = b.count
+		sum.ErrorCounts[i] = b.errors
+		sum.Count += b.count
+		sum.Errors += b.errors
+
+		sorted := slices.Clone(b.samples)
+		slices.Sort(sorted)
+		sum.P99s[i] = quantile(sorted, 0.99)
+		all = append(all, sorted...)
+	}
+	if sum.Count == 0 {
+		return sum
+	}
+	slices.Sort(all)
+	sum.P50 = quantile(all, 0.50)
+	sum.P95 = quantile(all, 0.95)
+	sum.P99 = quantile(all, 0.99)
+	sum.QPS = float64(sum.Count) / (time.Duration(n) * resolution).Seconds()
+	sum.ErrorRate = float64(sum.Errors) / float64(sum.Count)
+	return sum
+}
+
+// quantile returns the nearest-rank q-quantile of sorted.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(q*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
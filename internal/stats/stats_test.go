@@ -0,0 +1,89 @@
+package stats_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/stats"
+)
+
+func TestAggregator_Overall(t *testing.T) {
+	t.Parallel()
+
+	a := stats.New(time.Second, 10)
+	base := time.Unix(1700000000, 0)
+	for i := range 100 {
+		a.Observe("q", base.Add(time.Duration(i%5)*time.Second), time.Duration(i+1)*time.Millisecond, i%10 == 0)
+	}
+
+	s := a.Overall(base.Add(4 * time.Second))
+	if s.Count != 100 || s.Errors != 10 {
+		t.Fatalf("Count, Errors = %d, %d, want 100, 10", s.Count, s.Errors)
+	}
+	if s.QPS != 10 {
+		t.Errorf("QPS = %v, want 10", s.QPS)
+	}
+	if s.ErrorRate != 0.1 {
+		t.Errorf("ErrorRate = %v, want 0.1", s.ErrorRate)
+	}
+	if s.P50 != 50*time.Millisecond || s.P95 != 95*time.Millisecond || s.P99 != 99*time.Millisecond {
+		t.Errorf("P50, P95, P99 = %v, %v, %v", s.P50, s.P95, s.P99)
+	}
+	if len(s.Counts) != 10 {
+		t.Fatalf("len(Counts) = %d, want 10", len(s.Counts))
+	}
+	// Observations span the last five buckets of the window.
+	for i, c := range s.Counts {
+		want := 0
+		if i >= 5 {
+			want = 20
+		}
+		if c != want {
+			t.Errorf("Counts[%d] = %d, want %d", i, c, want)
+		}
+	}
+}
+
+func TestAggregator_WindowSlides(t *testing.T) {
+	t.Parallel()
+
+	a := stats.New(time.Second, 10)
+	base := time.Unix(1700000000, 0)
+	a.Observe("old", base, time.Second, false)
+	a.Observe("new", base.Add(15*time.Second), time.Millisecond, true)
+
+	s := a.Overall(base.Add(15 * time.Second))
+	if s.Count != 1 || s.P99 != time.Millisecond {
+		t.Errorf("Count, P99 = %d, %v, want 1, 1ms", s.Count, s.P99)
+	}
+
+	keys := a.Keys(base.Add(15 * time.Second))
+	if len(keys) != 1 || keys[0].Key != "new" || keys[0].Errors != 1 {
+		t.Errorf("Keys = %+v", keys)
+	}
+
+	if s := a.Overall(base.Add(time.Minute)); s.Count != 0 || s.QPS != 0 {
+		t.Errorf("idle window: Count, QPS = %d, %v", s.Count, s.QPS)
+	}
+}
+
+func TestAggregator_KeysOrder(t *testing.T) {
+	t.Parallel()
+
+	a := stats.New(time.Second, 60)
+	now := time.Unix(1700000000, 0)
+	for range 3 {
+		a.Observe("busy", now, time.Millisecond, false)
+	}
+	a.Observe("slow", now, time.Second, false)
+	a.Observe("fast", now, time.Microsecond, false)
+
+	keys := a.Keys(now)
+	var got []string
+	for _, k := range keys {
+		got = append(got, k.Key)
+	}
+	if len(got) != 3 || got[0] != "busy" || got[1] != "slow" || got[2] != "fast" {
+		t.Errorf("order = %v, want [busy slow fast]", got)
+	}
+}
@@ -0,0 +1,663 @@
+// Package store persists captured events to a local SQLite database and
+// answers historical queries over them. Events are inserted as they arrive,
+// each in its own transaction, so a crash loses at most the events not yet
+// synced, never the database.
+//
+// Each row keeps the fields queries filter on beside the event: start and
+// completion time, duration, whether the event failed, and its fingerprint
+// and transaction, indexed by start time, fingerprint, and transaction. The
+// event itself is its protobuf encoding, compressed with DEFLATE against a
+// preset dictionary: recent events, stored in the dicts table and refreshed
+// as traffic changes, so a row only holds what sets it apart from them.
+// With a key, the compressed event and the dictionaries are sealed with
+// AES-256-GCM, bound to their rows, and the fingerprint and transaction are
+// stored as keyed hashes, so only times, durations, and failure flags are
+// left in the clear.
+//
+// The meta table records the codec. Stores created before compression have
+// none; their events stay readable, and are appended to, uncompressed.
+package store
+
+import (
+	"bytes"
+	"compress/flate"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	_ "modernc.org/sqlite" // registers the "sqlite" driver
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/encrypt"
+	"github.com/mickamy/sql-tap/internal/query"
+)
+
+// format is the schema version recorded in the meta table.
+const format = "1"
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value BLOB NOT NULL
+);
+CREATE TABLE IF NOT EXISTS events (
+	seq         INTEGER PRIMARY KEY, -- append order, from 1
+	start       INTEGER NOT NULL,    -- Unix nanoseconds
+	end         INTEGER NOT NULL,    -- start + duration
+	duration    INTEGER NOT NULL,    -- nanoseconds
+	failed      INTEGER NOT NULL,
+	fingerprint TEXT NOT NULL,
+	tx_id       TEXT NOT NULL,
+	event       BLOB NOT NULL        -- tap.v1.QueryEvent, compressed, then sealed with a key
+);
+CREATE TABLE IF NOT EXISTS dicts (
+	id   INTEGER PRIMARY KEY, -- from 1
+	dict BLOB NOT NULL        -- sealed with a key
+);
+CREATE INDEX IF NOT EXISTS events_start ON events (start);
+CREATE INDEX IF NOT EXISTS events_fingerprint ON events (fingerprint, start);
+CREATE INDEX IF NOT EXISTS events_tx ON events (tx_id, start) WHERE tx_id <> '';
+`
+
+// codecDeflate is the codec of compressed stores: each event is a uvarint
+// dictionary ID, 0 for none, and the event compressed with DEFLATE against
+// that dictionary.
+const codecDeflate = "deflate"
+
+const (
+	// dictSize bounds a dictionary, the most DEFLATE looks back.
+	dictSize = 32 << 10
+	// dictRefresh is how many events are compressed against a dictionary
+	// before the recent events replace it.
+	dictRefresh = 50_000
+)
+
+// keyCheck is sealed into the meta table of an encrypted store, so the
+// wrong key is told apart from a damaged row.
+const keyCheck = "sql-tap store key check"
+
+// ErrCorrupt is returned when the file is not a store, or a row does not
+// decode.
+var ErrCorrupt = errors.New("store: corrupt file")
+
+// ErrKey is returned when a store is opened with the wrong key, without the
+// key it was written with, or with a key it was not written with.
+var ErrKey = errors.New("store: key mismatch")
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithReadOnly opens an existing store for queries only, leaving the file
+// untouched; it may be open in a daemon that is still appending.
+func WithReadOnly() Option {
+	return func(s *Store) {
+		s.readOnly = true
+	}
+}
+
+// WithKey seals the events of a new store with key, an AES-256 key, and
+// opens those of an existing store written with it.
+func WithKey(key []byte) Option {
+	return func(s *Store) {
+		s.key = key
+	}
+}
+
+// fingerprint returns the fingerprint ev is indexed under: its own, or for
+// events without one, that of its query.
+func fingerprint(ev *tapv1.QueryEvent) string {
+	if fp := ev.GetFingerprint(); fp != "" {
+		return fp
+	}
+	return query.Fingerprint(ev.GetQuery())
+}
+
+// Store is an append-only SQLite table of events. It is safe for concurrent
+// use.
+type Store struct {
+	path     string
+	readOnly bool
+	key      []byte
+	sealer   *encrypt.Sealer // nil without a key
+	db       *sql.DB
+
+	mu   sync.Mutex // serializes Append, which numbers rows
+	next int64      // seq of the next row
+	n    int        // rows, as of open plus Append
+
+	compressed bool // the store's codec is codecDeflate
+	// Append's compression state, guarded by mu: the dictionary in use and
+	// its compressor, the events since it was stored, and the most recent
+	// events, the next dictionary.
+	dict      int64
+	zw        *flate.Writer
+	zbuf      bytes.Buffer
+	sinceDict int
+	recent    []byte
+
+	dictMu sync.Mutex
+	dicts  map[int64][]byte // dictionaries read so far, by ID
+}
+
+// Open opens the store at path, creating it unless read-only.
+func Open(path string, opts ...Option) (*Store, error) {
+	s := &Store{path: path, dicts: make(map[int64][]byte)}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.key != nil {
+		sealer, err := encrypt.NewSealer(s.key)
+		if err != nil {
+			return nil, fmt.Errorf("store: %w", err)
+		}
+		s.sealer = sealer
+	}
+
+	pragmas := "_pragma=busy_timeout(5000)"
+	if s.readOnly {
+		pragmas += "&mode=ro"
+	} else {
+		pragmas += "&_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)"
+	}
+	db, err := sql.Open("sqlite", "file:"+escapePath(path)+"?"+pragmas)
+	if err != nil {
+		return nil, fmt.Errorf("store: open %s: %w", path, err)
+	}
+	s.db = db
+	if err := s.init(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// escapePath escapes what SQLite would read as part of a URI in path.
+func escapePath(path string) string {
+	return strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+}
+
+// init checks, or for a new file creates, the schema and the key check,
+// and loads the row count.
+func (s *Store) init() error {
+	var tables int
+	if err := s.db.QueryRow(`SELECT count(*) FROM sqlite_master WHERE type = 'table'`).Scan(&tables); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrCorrupt, s.path, err)
+	}
+	meta := map[string][]byte{}
+	if tables > 0 {
+		rows, err := s.db.Query(`SELECT key, value FROM meta`)
+		if err != nil {
+			return fmt.Errorf("%w: %s is not an event store", ErrCorrupt, s.path)
+		}
+		for rows.Next() {
+			var k string
+			var v []byte
+			if err := rows.Scan(&k, &v); err != nil {
+				_ = rows.Close()
+				return fmt.Errorf("%w: %s: %w", ErrCorrupt, s.path, err)
+			}
+			meta[k] = v
+		}
+		_ = rows.Close()
+		if string(meta["format"]) != format {
+			return fmt.Errorf("%w: %s: unknown format %q", ErrCorrupt, s.path, meta["format"])
+		}
+		switch codec := string(meta["codec"]); codec {
+		case "":
+		case codecDeflate:
+			s.compressed = true
+		default:
+			return fmt.Errorf("%w: %s: unknown codec %q", ErrCorrupt, s.path, codec)
+		}
+	}
+
+	if tables == 0 {
+		if s.readOnly {
+			return fmt.Errorf("%w: %s is not an event store", ErrCorrupt, s.path)
+		}
+		if err := s.create(); err != nil {
+			return err
+		}
+	} else if err := s.checkKey(meta["key_check"]); err != nil {
+		return err
+	}
+
+	var last sql.NullInt64
+	if err := s.db.QueryRow(`SELECT count(*), max(seq) FROM events`).Scan(&s.n, &last); err != nil {
+		return fmt.Errorf("store: %s: %w", s.path, err)
+	}
+	s.next = last.Int64 + 1
+	if s.compressed && !s.readOnly {
+		return s.loadDict()
+	}
+	return nil
+}
+
+// loadDict resumes compressing against the newest dictionary, if any.
+func (s *Store) loadDict() error {
+	var id sql.NullInt64
+	if err := s.db.QueryRow(`SELECT max(id) FROM dicts`).Scan(&id); err != nil {
+		return fmt.Errorf("store: %s: %w", s.path, err)
+	}
+	if !id.Valid {
+		return nil
+	}
+	dict, err := s.lookupDict(id.Int64)
+	if err != nil {
+		return err
+	}
+	return s.useDict(id.Int64, dict)
+}
+
+// create sets up the schema of a new store in one transaction.
+func (s *Store) create() error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("store: create %s: %w", s.path, err)
+	}
+	defer func() { _ = tx.Rollback() }()
+	if _, err := tx.Exec(schema); err != nil {
+		return fmt.Errorf("store: create %s: %w", s.path, err)
+	}
+	if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES ('format', ?), ('codec', ?)`,
+		[]byte(format), []byte(codecDeflate)); err != nil {
+		return fmt.Errorf("store: create %s: %w", s.path, err)
+	}
+	if s.sealer != nil {
+		check, err := s.sealer.Seal([]byte(keyCheck), []byte("key_check"))
+		if err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES ('key_check', ?)`, check); err != nil {
+			return fmt.Errorf("store: create %s: %w", s.path, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("store: create %s: %w", s.path, err)
+	}
+	s.compressed = true
+	return nil
+}
+
+// checkKey compares the key the store was opened with to the one its key
+// check was sealed with, if any.
+func (s *Store) checkKey(check []byte) error {
+	switch {
+	case check == nil && s.sealer == nil:
+		return nil
+	case check == nil:
+		return fmt.Errorf("%w: %s is not encrypted; use a new file to encrypt events", ErrKey, s.path)
+	case s.sealer == nil:
+		return fmt.Errorf("%w: %s is encrypted; its key is required", ErrKey, s.path)
+	}
+	if plain, err := s.sealer.Open(check, []byte("key_check")); err != nil || string(plain) != keyCheck {
+		return fmt.Errorf("%w: %s was encrypted with another key", ErrKey, s.path)
+	}
+	return nil
+}
+
+// indexed returns what v, a fingerprint or transaction ID, is stored as.
+func (s *Store) indexed(v string) string {
+	if s.sealer == nil || v == "" {
+		return v
+	}
+	return s.sealer.Hash(v)
+}
+
+// ad is the additional data a row's event is sealed with.
+func ad(seq int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte("event "), uint64(seq)) //nolint:gosec // from 1
+}
+
+// dictAD is the additional data a dictionary is sealed with.
+func dictAD(id int64) []byte {
+	return binary.BigEndian.AppendUint64([]byte("dict "), uint64(id)) //nolint:gosec // from 1
+}
+
+// useDict makes dict, stored as id, the one Append compresses against.
+// Caller holds mu, or is opening the store.
+func (s *Store) useDict(id int64, dict []byte) error {
+	zw, err := flate.NewWriterDict(&s.zbuf, flate.DefaultCompression, dict)
+	if err != nil {
+		return fmt.Errorf("store: %w", err)
+	}
+	s.dict, s.zw, s.sinceDict = id, zw, 0
+	return nil
+}
+
+// storeDict stores the recent events as the next dictionary and compresses
+// against it from now on. Caller holds mu.
+func (s *Store) storeDict() error {
+	dict := slices.Clone(s.recent)
+	id := s.dict + 1
+	b := dict
+	if s.sealer != nil {
+		var err error
+		if b, err = s.sealer.Seal(dict, dictAD(id)); err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+	}
+	if _, err := s.db.Exec(`INSERT INTO dicts (id, dict) VALUES (?, ?)`, id, b); err != nil {
+		return fmt.Errorf("store: append to %s: %w", s.path, err)
+	}
+	s.dictMu.Lock()
+	s.dicts[id] = dict
+	s.dictMu.Unlock()
+	return s.useDict(id, dict)
+}
+
+// compress returns the event column of the marshaled event b. Caller holds
+// mu.
+func (s *Store) compress(b []byte) ([]byte, error) {
+	if s.sinceDict >= dictRefresh || (s.dict == 0 && len(s.recent) >= dictSize) {
+		if err := s.storeDict(); err != nil {
+			return nil, err
+		}
+	}
+	s.recent = append(s.recent, b...)
+	if over := len(s.recent) - dictSize; over > 0 {
+		s.recent = s.recent[:copy(s.recent, s.recent[over:])]
+	}
+	s.sinceDict++
+
+	s.zbuf.Reset()
+	s.zbuf.Write(binary.AppendUvarint(nil, uint64(s.dict))) //nolint:gosec // from 0
+	if s.zw == nil {
+		zw, err := flate.NewWriter(&s.zbuf, flate.DefaultCompression)
+		if err != nil {
+			return nil, fmt.Errorf("store: %w", err)
+		}
+		s.zw = zw
+	} else {
+		s.zw.Reset(&s.zbuf)
+	}
+	if _, err := s.zw.Write(b); err != nil {
+		return nil, fmt.Errorf("store: compress: %w", err)
+	}
+	if err := s.zw.Close(); err != nil {
+		return nil, fmt.Errorf("store: compress: %w", err)
+	}
+	return slices.Clone(s.zbuf.Bytes()), nil
+}
+
+// lookupDict returns the dictionary stored as id.
+func (s *Store) lookupDict(id int64) ([]byte, error) {
+	s.dictMu.Lock()
+	defer s.dictMu.Unlock()
+	if dict, ok := s.dicts[id]; ok {
+		return dict, nil
+	}
+	var dict []byte
+	if err := s.db.QueryRow(`SELECT dict FROM dicts WHERE id = ?`, id).Scan(&dict); err != nil {
+		return nil, fmt.Errorf("%w: %s: dictionary %d: %w", ErrCorrupt, s.path, id, err)
+	}
+	if s.sealer != nil {
+		var err error
+		if dict, err = s.sealer.Open(dict, dictAD(id)); err != nil {
+			return nil, fmt.Errorf("%w: %s: dictionary %d: %w", ErrCorrupt, s.path, id, err)
+		}
+	}
+	s.dicts[id] = dict
+	return dict, nil
+}
+
+// decompress returns the marshaled event of a compressed event column.
+func (s *Store) decompress(b []byte) ([]byte, error) {
+	id, n := binary.Uvarint(b)
+	if n <= 0 {
+		return nil, errors.New("bad dictionary ID")
+	}
+	var dict []byte
+	if id > 0 {
+		var err error
+		if dict, err = s.lookupDict(int64(id)); err != nil { //nolint:gosec // a row ID
+			return nil, err
+		}
+	}
+	zr := flate.NewReaderDict(bytes.NewReader(b[n:]), dict)
+	defer func() { _ = zr.Close() }()
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress: %w", err)
+	}
+	return plain, nil
+}
+
+// Append inserts ev, compressed unless the store predates compression. The
+// row is committed to the write-ahead log before Append returns; call Sync
+// to make it durable.
+func (s *Store) Append(ev *tapv1.QueryEvent) error {
+	if s.readOnly {
+		return fmt.Errorf("store: %s is read-only", s.path)
+	}
+	b, err := proto.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("store: marshal: %w", err)
+	}
+	start := ev.GetStartTime().AsTime().UnixNano()
+	dur := ev.GetDuration().AsDuration()
+	failed := ev.GetError() != ""
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seq := s.next
+	if s.compressed {
+		if b, err = s.compress(b); err != nil {
+			return err
+		}
+	}
+	if s.sealer != nil {
+		if b, err = s.sealer.Seal(b, ad(seq)); err != nil {
+			return fmt.Errorf("store: %w", err)
+		}
+	}
+	_, err = s.db.Exec(`INSERT INTO events (seq, start, end, duration, failed, fingerprint, tx_id, event)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		seq, start, start+int64(dur), int64(dur), failed, s.indexed(fingerprint(ev)), s.indexed(ev.GetTxId()), b)
+	if err != nil {
+		return fmt.Errorf("store: append to %s: %w", s.path, err)
+	}
+	s.next++
+	s.n++
+	return nil
+}
+
+// Sync commits appended events to stable storage, moving the write-ahead
+// log into the database.
+func (s *Store) Sync() error {
+	if s.readOnly {
+		return nil
+	}
+	if _, err := s.db.Exec(`PRAGMA wal_checkpoint(PASSIVE)`); err != nil {
+		return fmt.Errorf("store: sync %s: %w", s.path, err)
+	}
+	return nil
+}
+
+// Close syncs (unless read-only) and closes the database.
+func (s *Store) Close() error {
+	err := s.Sync()
+	if cerr := s.db.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("store: close %s: %w", s.path, cerr)
+	}
+	return err
+}
+
+// Len returns the number of stored events: those there were at open, plus
+// those appended since through s.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
+
+func (s *Store) String() string { return s.path }
+
+// Filter selects events for Query. Zero fields match everything.
+type Filter struct {
+	Since, Until time.Time     // start time range; Until is exclusive
+	Fingerprint  string        // query.Fingerprint of the statements wanted
+	TxID         string        // events of one transaction
+	Contains     string        // case-insensitive substring of the query text
+	MinDuration  time.Duration // at least this slow
+	ErrorsOnly   bool          // only failed queries
+	Limit        int           // keep only the most recent Limit matches; 0 keeps all
+}
+
+// FilterFromRequest converts a Query RPC request, fingerprinting its SQL.
+func FilterFromRequest(req *tapv1.QueryRequest) Filter {
+	f := Filter{
+		TxID:        req.GetTxId(),
+		Contains:    req.GetContains(),
+		MinDuration: req.GetMinDuration().AsDuration(),
+		ErrorsOnly:  req.GetErrorsOnly(),
+		Limit:       int(req.GetLimit()),
+	}
+	if req.GetSince() != nil {
+		f.Since = req.GetSince().AsTime()
+	}
+	if req.GetUntil() != nil {
+		f.Until = req.GetUntil().AsTime()
+	}
+	if q := req.GetQuery(); q != "" {
+		f.Fingerprint = query.Fingerprint(q)
+	}
+	return f
+}
+
+// Query returns the events matching f, oldest first.
+func (s *Store) Query(f Filter) ([]*tapv1.QueryEvent, error) {
+	var (
+		where []string
+		args  []any
+	)
+	cond := func(c string, v any) {
+		where = append(where, c)
+		args = append(args, v)
+	}
+	if !f.Since.IsZero() {
+		cond("start >= ?", f.Since.UnixNano())
+	}
+	if !f.Until.IsZero() {
+		cond("start < ?", f.Until.UnixNano())
+	}
+	if f.MinDuration > 0 {
+		cond("duration >= ?", int64(f.MinDuration))
+	}
+	if f.ErrorsOnly {
+		where = append(where, "failed")
+	}
+	if f.Fingerprint != "" {
+		cond("fingerprint = ?", s.indexed(f.Fingerprint))
+	}
+	if f.TxID != "" {
+		cond("tx_id = ?", s.indexed(f.TxID))
+	}
+	q := `SELECT seq, event FROM events`
+	if len(where) > 0 {
+		q += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	// Newest first so Limit can stop early, then reversed. Contains is
+	// checked on the decoded event, so it cannot limit the rows read.
+	q += ` ORDER BY start DESC, seq DESC`
+	if f.Limit > 0 && f.Contains == "" {
+		q += fmt.Sprintf(` LIMIT %d`, f.Limit)
+	}
+
+	needle := strings.ToLower(f.Contains)
+	var out []*tapv1.QueryEvent
+	err := s.scan(q, args, func(ev *tapv1.QueryEvent) bool {
+		if needle != "" && !strings.Contains(strings.ToLower(ev.GetQuery()), needle) {
+			return true
+		}
+		out = append(out, ev)
+		return f.Limit == 0 || len(out) < f.Limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// CompletedAfter returns the events appended after the last one that
+// completed at or before t, oldest first. Events are appended as they
+// complete, so these are what a watcher whose newest event completed at t
+// has missed. When limit > 0, only the most recent limit are returned.
+func (s *Store) CompletedAfter(t time.Time, limit int) ([]*tapv1.QueryEvent, error) {
+	// Walk back from the newest row, which is where a watcher resumes.
+	var from int64
+	rows, err := s.db.Query(`SELECT seq FROM events WHERE end <= ? ORDER BY seq DESC LIMIT 1`, t.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("store: query %s: %w", s.path, err)
+	}
+	if rows.Next() {
+		err = rows.Scan(&from)
+	}
+	_ = rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("store: query %s: %w", s.path, err)
+	}
+
+	q := `SELECT seq, event FROM events WHERE seq > ? ORDER BY seq DESC`
+	if limit > 0 {
+		q += fmt.Sprintf(` LIMIT %d`, limit)
+	}
+	var out []*tapv1.QueryEvent
+	if err := s.scan(q, []any{from}, func(ev *tapv1.QueryEvent) bool {
+		out = append(out, ev)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// scan runs q, which selects seq and event, passing each decoded event to
+// fn until it returns false.
+func (s *Store) scan(q string, args []any, fn func(*tapv1.QueryEvent) bool) error {
+	rows, err := s.db.Query(q, args...)
+	if err != nil {
+		return fmt.Errorf("store: query %s: %w", s.path, err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			seq int64
+			b   []byte
+		)
+		if err := rows.Scan(&seq, &b); err != nil {
+			return fmt.Errorf("store: query %s: %w", s.path, err)
+		}
+		if s.sealer != nil {
+			if b, err = s.sealer.Open(b, ad(seq)); err != nil {
+				return fmt.Errorf("%w: %s: row %d: %w", ErrCorrupt, s.path, seq, err)
+			}
+		}
+		if s.compressed {
+			if b, err = s.decompress(b); err != nil {
+				return fmt.Errorf("%w: %s: row %d: %w", ErrCorrupt, s.path, seq, err)
+			}
+		}
+		ev := &tapv1.QueryEvent{}
+		if err := proto.Unmarshal(b, ev); err != nil {
+			return fmt.Errorf("%w: %s: row %d: %w", ErrCorrupt, s.path, seq, err)
+		}
+		if !fn(ev) {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("store: query %s: %w", s.path, err)
+	}
+	return nil
+}
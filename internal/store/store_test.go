@@ -0,0 +1,521 @@
+package store_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/store"
+)
+
+var base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func event(id string, offset time.Duration, q string, dur time.Duration) *tapv1.QueryEvent {
+	return &tapv1.QueryEvent{
+		Id:        id,
+		Query:     q,
+		StartTime: timestamppb.New(base.Add(offset)),
+		Duration:  durationpb.New(dur),
+	}
+}
+
+// seed writes a small history and reopens it, so queries run on what was
+// stored.
+func seed(t *testing.T) *store.Store {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs := []*tapv1.QueryEvent{
+		event("1", 0, "SELECT * FROM users WHERE id = 1", time.Millisecond),
+		event("3", 2*time.Second, "UPDATE users SET name = 'x' WHERE id = 1", 5*time.Millisecond),
+		// Arrives after 3 but started before it.
+		event("2", time.Second, "SELECT * FROM users WHERE id = 2", 200*time.Millisecond),
+		event("4", 3*time.Second, "SELECT * FROM orders", time.Millisecond),
+	}
+	evs[1].TxId = "tx1"
+	evs[3].TxId = "tx1"
+	evs[3].Error = "canceled"
+	for _, ev := range evs {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestStore_Query(t *testing.T) {
+	t.Parallel()
+
+	s := seed(t)
+	tests := []struct {
+		name   string
+		filter store.Filter
+		want   []string
+	}{
+		{name: "all in start order", filter: store.Filter{}, want: []string{"1", "2", "3", "4"}},
+		{name: "time range", filter: store.Filter{Since: base.Add(time.Second), Until: base.Add(3 * time.Second)}, want: []string{"2", "3"}},
+		{name: "fingerprint", filter: store.Filter{Fingerprint: query.Fingerprint("SELECT * FROM users WHERE id = 99")}, want: []string{"1", "2"}},
+		{name: "tx", filter: store.Filter{TxID: "tx1"}, want: []string{"3", "4"}},
+		{name: "contains", filter: store.Filter{Contains: "ORDERS"}, want: []string{"4"}},
+		{name: "min duration", filter: store.Filter{MinDuration: 5 * time.Millisecond}, want: []string{"2", "3"}},
+		{name: "errors", filter: store.Filter{ErrorsOnly: true}, want: []string{"4"}},
+		{name: "limit keeps newest", filter: store.Filter{Limit: 2}, want: []string{"3", "4"}},
+		{name: "tx and time", filter: store.Filter{TxID: "tx1", Until: base.Add(3 * time.Second)}, want: []string{"3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			evs, err := s.Query(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, ev := range evs {
+				got = append(got, ev.GetId())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Query(%+v) = %v, want %v", tt.filter, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_CompletedAfter(t *testing.T) {
+	t.Parallel()
+
+	s := seed(t)
+	tests := []struct {
+		name  string
+		after time.Time
+		limit int
+		want  []string
+	}{
+		// Event 3 completed at 2.005s; 2, appended after it, at 1.2s.
+		{name: "after the first", after: base.Add(time.Millisecond), want: []string{"3", "2", "4"}},
+		{name: "after a later arrival", after: base.Add(1200 * time.Millisecond), want: []string{"4"}},
+		{name: "limited", after: base.Add(time.Millisecond), limit: 2, want: []string{"2", "4"}},
+		{name: "after the last", after: base.Add(3 * time.Second).Add(time.Millisecond)},
+		{name: "before everything", after: base.Add(-time.Hour), want: []string{"1", "3", "2", "4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			evs, err := s.CompletedAfter(tt.after, tt.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, ev := range evs {
+				got = append(got, ev.GetId())
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("CompletedAfter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStore_AppendAfterReopen(t *testing.T) {
+	t.Parallel()
+
+	s := seed(t)
+	if err := s.Append(event("5", 4*time.Second, "SELECT 1", time.Millisecond)); err != nil {
+		t.Fatal(err)
+	}
+	if s.Len() != 5 {
+		t.Fatalf("Len() = %d, want 5", s.Len())
+	}
+	evs, err := s.Query(store.Filter{Since: base.Add(4 * time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(evs) != 1 || evs[0].GetId() != "5" {
+		t.Fatalf("unexpected events: %v", evs)
+	}
+}
+
+func TestStore_NotAStore(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	if err := os.WriteFile(path, []byte(`{"id":"1"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(path); !errors.Is(err, store.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt, got %v", err)
+	}
+
+	// Another program's database.
+	path = filepath.Join(t.TempDir(), "other.db")
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE notes (body TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	_ = db.Close()
+	if _, err := store.Open(path); !errors.Is(err, store.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt for another database, got %v", err)
+	}
+}
+
+// traffic returns n events shaped like an application's: a few statements
+// repeated with different arguments.
+func traffic(n int) []*tapv1.QueryEvent {
+	shapes := []string{
+		"SELECT id, email, name, created_at FROM users WHERE id = $1",
+		"SELECT o.id, o.total, o.status FROM orders o WHERE o.user_id = $1 ORDER BY o.created_at DESC LIMIT 20",
+		"UPDATE sessions SET last_seen_at = $1 WHERE token = $2",
+		"INSERT INTO audit_log (user_id, action, payload) VALUES ($1, $2, $3)",
+	}
+	evs := make([]*tapv1.QueryEvent, n)
+	for i := range evs {
+		q := shapes[i%len(shapes)]
+		ev := event(fmt.Sprintf("%08x-%04x", i*7919, i), time.Duration(i)*time.Millisecond, q, time.Duration(i%50)*time.Microsecond)
+		ev.Op = 4
+		ev.ConnId = fmt.Sprintf("conn-%d", i%16)
+		ev.Args = []string{strconv.Itoa(1000 + i*31), fmt.Sprintf("user%d@example.com", i%97)}
+		ev.RowsAffected = int64(i % 20)
+		ev.Fingerprint = query.Fingerprint(q)
+		if i%10 == 0 {
+			ev.TxId = fmt.Sprintf("tx-%d", i/10)
+		}
+		if i%100 == 0 {
+			ev.Error = "deadlock detected"
+		}
+		evs[i] = ev
+	}
+	return evs
+}
+
+func TestStore_Traffic(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs := traffic(2000)
+	for _, ev := range evs {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.Close()
+
+	s, err = store.Open(path, store.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+
+	all, err := s.Query(store.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(all, evs, func(a, b *tapv1.QueryEvent) bool { return proto.Equal(a, b) }) {
+		t.Fatalf("Query returned %d events, not the %d appended", len(all), len(evs))
+	}
+	tests := []struct {
+		name   string
+		filter store.Filter
+		want   int
+	}{
+		{name: "fingerprint", filter: store.Filter{Fingerprint: evs[1].GetFingerprint()}, want: 500},
+		{name: "tx", filter: store.Filter{TxID: "tx-150"}, want: 1},
+		{name: "errors", filter: store.Filter{ErrorsOnly: true}, want: 20},
+		{name: "newest", filter: store.Filter{Limit: 300}, want: 300},
+		{name: "contains with limit", filter: store.Filter{Contains: "audit_log", Limit: 10}, want: 10},
+	}
+	for _, tt := range tests {
+		got, err := s.Query(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) != tt.want {
+			t.Errorf("%s: %d events, want %d", tt.name, len(got), tt.want)
+		}
+	}
+	if got, _ := s.Query(store.Filter{TxID: "tx-150"}); len(got) == 1 && !proto.Equal(got[0], evs[1500]) {
+		t.Errorf("tx-150 = %v, want %v", got[0], evs[1500])
+	}
+	if got, _ := s.Query(store.Filter{Contains: "audit_log", Limit: 1}); len(got) != 1 || !proto.Equal(got[0], evs[1999]) {
+		t.Errorf("newest audit_log insert = %v, want %v", got, evs[1999])
+	}
+}
+
+func TestStore_Encrypted(t *testing.T) {
+	t.Parallel()
+
+	key := bytes.Repeat([]byte{7}, 32)
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := store.Open(path, store.WithKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Enough events for a compression dictionary, which is sealed too.
+	evs := traffic(400)
+	for _, ev := range evs {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"user5@example.com", "audit_log", evs[0].GetFingerprint(), "tx-3"} {
+		if bytes.Contains(b, []byte(secret)) {
+			t.Errorf("%q is on disk in the clear", secret)
+		}
+	}
+
+	s, err = store.Open(path, store.WithReadOnly(), store.WithKey(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	got, err := s.Query(store.Filter{Fingerprint: evs[3].GetFingerprint()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 100 || !proto.Equal(got[0], evs[3]) {
+		t.Errorf("fingerprint query = %d events, want 100 starting with %v", len(got), evs[3])
+	}
+	if got, _ := s.Query(store.Filter{TxID: "tx-3"}); len(got) != 1 || !proto.Equal(got[0], evs[30]) {
+		t.Errorf("tx query = %v, want %v", got, evs[30])
+	}
+
+	other := bytes.Repeat([]byte{8}, 32)
+	for name, opts := range map[string][]store.Option{
+		"no key":    {store.WithReadOnly()},
+		"wrong key": {store.WithReadOnly(), store.WithKey(other)},
+	} {
+		if _, err := store.Open(path, opts...); !errors.Is(err, store.ErrKey) {
+			t.Errorf("%s: Open error = %v, want ErrKey", name, err)
+		}
+	}
+
+	plain := filepath.Join(t.TempDir(), "plain.db")
+	p, err := store.Open(plain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = p.Close()
+	if _, err := store.Open(plain, store.WithKey(key)); !errors.Is(err, store.ErrKey) {
+		t.Errorf("keyed Open of a plain store error = %v, want ErrKey", err)
+	}
+}
+
+// TestStore_ReadOnlyWhileAppending reads a store that grows while it is
+// open, and opens it read-only beside the writer.
+func TestStore_ReadOnlyWhileAppending(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = s.Close() }()
+	evs := traffic(20)
+	for _, ev := range evs[:10] {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, _ := s.Query(store.Filter{}); len(got) != 10 {
+		t.Fatalf("Query = %d events, want 10", len(got))
+	}
+	for _, ev := range evs[10:] {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := s.Query(store.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.EqualFunc(got, evs, func(a, b *tapv1.QueryEvent) bool { return proto.Equal(a, b) }) {
+		t.Errorf("Query = %d events, want the 20 appended", len(got))
+	}
+
+	ro, err := store.Open(path, store.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ro.Close() }()
+	if got, err := ro.CompletedAfter(base, 5); err != nil || len(got) != 5 || !proto.Equal(got[4], evs[19]) {
+		t.Errorf("CompletedAfter = %d events (err %v), want the last 5", len(got), err)
+	}
+}
+
+// TestStore_Compressed reads the event column directly: it holds the event
+// compressed against a stored dictionary, several times smaller than the
+// event.
+func TestStore_Compressed(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs := traffic(2000)
+	for _, ev := range evs[:1000] {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Reopened, the store compresses against the dictionary it stored.
+	_ = s.Close()
+	if s, err = store.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	for _, ev := range evs[1000:] {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.Close()
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	var codec []byte
+	if err := db.QueryRow(`SELECT value FROM meta WHERE key = 'codec'`).Scan(&codec); err != nil || string(codec) != "deflate" {
+		t.Fatalf("codec = %q (err %v), want deflate", codec, err)
+	}
+
+	var stored, marshaled int
+	for i, ev := range evs {
+		var b []byte
+		if err := db.QueryRow(`SELECT event FROM events WHERE seq = ?`, i+1).Scan(&b); err != nil {
+			t.Fatal(err)
+		}
+		want, err := proto.Marshal(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored += len(b)
+		marshaled += len(want)
+
+		id, n := binary.Uvarint(b)
+		if n <= 0 {
+			t.Fatalf("row %d: no dictionary ID", i+1)
+		}
+		var dict []byte
+		if id > 0 {
+			if err := db.QueryRow(`SELECT dict FROM dicts WHERE id = ?`, id).Scan(&dict); err != nil {
+				t.Fatalf("row %d: dictionary %d: %v", i+1, id, err)
+			}
+		}
+		got, err := io.ReadAll(flate.NewReaderDict(bytes.NewReader(b[n:]), dict))
+		if err != nil {
+			t.Fatalf("row %d: %v", i+1, err)
+		}
+		dec := &tapv1.QueryEvent{}
+		if err := proto.Unmarshal(got, dec); err != nil || !proto.Equal(dec, ev) {
+			t.Fatalf("row %d decodes to %v (err %v), want %v", i+1, dec, err, ev)
+		}
+	}
+	if stored*3 > marshaled {
+		t.Errorf("stored %d bytes of %d marshaled, want under a third", stored, marshaled)
+	}
+}
+
+// TestStore_Uncompressed opens a store from before compression, which has
+// no codec: its events stay plain, old and new.
+func TestStore_Uncompressed(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = s.Close()
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = db.Close() }()
+	if _, err := db.Exec(`DELETE FROM meta WHERE key = 'codec'`); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = store.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs := traffic(20)
+	for _, ev := range evs {
+		if err := s.Append(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_ = s.Close()
+
+	var b []byte
+	if err := db.QueryRow(`SELECT event FROM events WHERE seq = 1`).Scan(&b); err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := proto.Marshal(evs[0]); !bytes.Equal(b, want) {
+		t.Errorf("row 1 = %x, want the plain event %x", b, want)
+	}
+	s, err = store.Open(path, store.WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Query(store.Filter{})
+	_ = s.Close()
+	if err != nil || !slices.EqualFunc(got, evs, func(a, b *tapv1.QueryEvent) bool { return proto.Equal(a, b) }) {
+		t.Errorf("Query = %d events (err %v), want the 20 appended", len(got), err)
+	}
+
+	if _, err := db.Exec(`INSERT INTO meta (key, value) VALUES ('codec', 'zstd')`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(path); !errors.Is(err, store.ErrCorrupt) {
+		t.Errorf("Open with an unknown codec error = %v, want ErrCorrupt", err)
+	}
+}
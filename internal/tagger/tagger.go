@@ -0,0 +1,105 @@
+package tagger
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Def describes a tag and how the TUI should color it.
+type Def struct {
+	Name  string
+	Color string
+}
+
+type rule struct {
+	tag         string
+	query       *regexp.Regexp
+	op          string
+	upstream    string
+	minDuration time.Duration
+	err         *bool
+}
+
+func (r rule) match(ev proxy.Event) bool {
+	if r.query != nil && !r.query.MatchString(ev.Query) {
+		return false
+	}
+	if r.op != "" && !strings.EqualFold(r.op, ev.Op.String()) {
+		return false
+	}
+	if r.upstream != "" && r.upstream != ev.Upstream {
+		return false
+	}
+	if ev.Duration < r.minDuration {
+		return false
+	}
+	if r.err != nil && *r.err != (ev.Error != "") {
+		return false
+	}
+	return true
+}
+
+// Tagger attaches tags to events according to config-defined rules.
+type Tagger struct {
+	rules []rule
+	defs  []Def
+}
+
+// New compiles rules. Several rules may share a tag; the first non-empty
+// color given for a tag wins.
+func New(rules []config.TagRule) (*Tagger, error) {
+	t := &Tagger{}
+	for i, r := range rules {
+		cr := rule{
+			tag:         r.Tag,
+			op:          r.Op,
+			upstream:    r.Upstream,
+			minDuration: r.MinDuration,
+			err:         r.Error,
+		}
+		if r.Query != "" {
+			re, err := regexp.Compile(r.Query)
+			if err != nil {
+				return nil, fmt.Errorf("tagger: rule %d (%s): %w", i, r.Tag, err)
+			}
+			cr.query = re
+		}
+		t.rules = append(t.rules, cr)
+
+		idx := slices.IndexFunc(t.defs, func(d Def) bool { return d.Name == r.Tag })
+		switch {
+		case idx < 0:
+			t.defs = append(t.defs, Def{Name: r.Tag, Color: r.Color})
+		case t.defs[idx].Color == "":
+			t.defs[idx].Color = r.Color
+		}
+	}
+	return t, nil
+}
+
+// Apply sets ev.Tags to the tags of all matching rules, in rule order and
+// without duplicates. A nil Tagger leaves ev unchanged.
+func (t *Tagger) Apply(ev *proxy.Event) {
+	if t == nil {
+		return
+	}
+	for _, r := range t.rules {
+		if r.match(*ev) && !slices.Contains(ev.Tags, r.tag) {
+			ev.Tags = append(ev.Tags, r.tag)
+		}
+	}
+}
+
+// Defs returns every configured tag in first-appearance order.
+func (t *Tagger) Defs() []Def {
+	if t == nil {
+		return nil
+	}
+	return t.defs
+}
@@ -0,0 +1,91 @@
+package tagger_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/config"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestTagger_Apply(t *testing.T) {
+	t.Parallel()
+
+	failed := true
+	tg, err := tagger.New([]config.TagRule{
+		{Tag: "reporting", Color: "5", Query: `(?i)\bfrom reports_`},
+		{Tag: "auth-path", Upstream: "auth"},
+		{Tag: "slow", MinDuration: 100 * time.Millisecond},
+		{Tag: "writes", Op: "exec"},
+		{Tag: "failed", Error: &failed},
+		{Tag: "reporting", Query: `(?i)^insert into report_log`, Color: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ev   proxy.Event
+		want []string
+	}{
+		{
+			name: "no match",
+			ev:   proxy.Event{Op: proxy.OpQuery, Query: "SELECT 1", Duration: time.Millisecond},
+			want: nil,
+		},
+		{
+			name: "query regexp",
+			ev:   proxy.Event{Op: proxy.OpQuery, Query: "SELECT * FROM reports_daily"},
+			want: []string{"reporting"},
+		},
+		{
+			name: "several rules",
+			ev:   proxy.Event{Op: proxy.OpExec, Upstream: "auth", Query: "UPDATE users", Duration: time.Second, Error: "boom"},
+			want: []string{"auth-path", "slow", "writes", "failed"},
+		},
+		{
+			name: "shared tag not duplicated",
+			ev:   proxy.Event{Op: proxy.OpExec, Query: "INSERT INTO report_log SELECT * FROM reports_daily"},
+			want: []string{"reporting", "writes"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ev := tt.ev
+			tg.Apply(&ev)
+			if !slices.Equal(ev.Tags, tt.want) {
+				t.Errorf("Tags = %v, want %v", ev.Tags, tt.want)
+			}
+		})
+	}
+
+	defs := tg.Defs()
+	if len(defs) != 5 || defs[0] != (tagger.Def{Name: "reporting", Color: "5"}) {
+		t.Errorf("unexpected defs: %+v", defs)
+	}
+}
+
+func TestTagger_InvalidRegexp(t *testing.T) {
+	t.Parallel()
+
+	if _, err := tagger.New([]config.TagRule{{Tag: "bad", Query: "("}}); err == nil {
+		t.Fatal("expected error for invalid regexp")
+	}
+}
+
+func TestTagger_Nil(t *testing.T) {
+	t.Parallel()
+
+	var tg *tagger.Tagger
+	ev := proxy.Event{Query: "SELECT 1"}
+	tg.Apply(&ev)
+	if ev.Tags != nil || tg.Defs() != nil {
+		t.Fatal("nil tagger should be a no-op")
+	}
+}
@@ -0,0 +1,237 @@
+// Package tenants aggregates query statistics per tenant, the value of an
+// extracted field such as tenant_id (see the extract package), and reports
+// tenants that take more than a share of all queries. One tenant crowding
+// out the rest is a common cause of latency on a shared multi-tenant
+// database.
+//
+// Statistics cover a rolling Window, like the routes package's. Quotas are
+// checked per fixed window instead: when one closes having seen enough
+// queries, each tenant above the share is returned as an advisory event,
+// unless it was already above it in the window before.
+package tenants
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/stats"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Tag is added to advisory events.
+const Tag = "tenant-quota"
+
+// Defs returns the tenant quota tag with its TUI color.
+func Defs() []tagger.Def {
+	return []tagger.Def{{Name: Tag, Color: "99"}}
+}
+
+// The statistics cover Window, in Buckets steps of Resolution.
+const (
+	Resolution = 10 * time.Second
+	Buckets    = 30
+	Window     = Resolution * Buckets
+)
+
+// Defaults for the Tracker options.
+const (
+	DefaultMaxShare    = 0.5
+	DefaultQuotaWindow = time.Minute
+	// DefaultMinCalls keeps quiet periods, when a handful of queries from one
+	// tenant are most of the traffic, from being reported.
+	DefaultMinCalls = 100
+)
+
+// maxTenants bounds the per-window counts on workloads with unbounded
+// distinct values; the statistics are bounded by the stats package.
+const maxTenants = 10000
+
+// Tenant is the statistics of one tenant's queries over Window.
+type Tenant struct {
+	Value string
+	stats.Summary
+	Share float64 // of all queries in the window, tenant or not
+}
+
+// Option configures a Tracker.
+type Option func(*Tracker)
+
+// WithMaxShare sets the share of queries, in (0, 1), above which a tenant is
+// reported.
+func WithMaxShare(f float64) Option {
+	return func(t *Tracker) {
+		t.maxShare = f
+	}
+}
+
+// WithQuotaWindow sets the length of the windows shares are checked in.
+func WithQuotaWindow(d time.Duration) Option {
+	return func(t *Tracker) {
+		t.window = d
+	}
+}
+
+// WithMinCalls sets the queries a window needs before shares are checked.
+func WithMinCalls(n int) Option {
+	return func(t *Tracker) {
+		t.minCalls = n
+	}
+}
+
+// Tracker aggregates queries per value of one extracted field. It is safe
+// for concurrent use.
+type Tracker struct {
+	field    string
+	maxShare float64
+	window   time.Duration
+	minCalls int
+
+	mu     sync.Mutex
+	agg    *stats.Aggregator
+	start  time.Time      // of the current quota window
+	calls  map[string]int // per tenant in the current quota window
+	total  int            // all queries in the current quota window
+	over   map[string]bool
+	nextID uint64
+}
+
+// New returns a Tracker of the tenants named by field, with the default
+// settings adjusted by opts.
+func New(field string, opts ...Option) *Tracker {
+	t := &Tracker{
+		field:    field,
+		maxShare: DefaultMaxShare,
+		window:   DefaultQuotaWindow,
+		minCalls: DefaultMinCalls,
+		agg:      stats.New(Resolution, Buckets),
+		calls:    make(map[string]int),
+		over:     make(map[string]bool),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Field returns the extracted field naming tenants.
+func (t *Tracker) Field() string {
+	return t.field
+}
+
+// MaxShare returns the share of queries above which a tenant is reported.
+func (t *Tracker) MaxShare() float64 {
+	return t.maxShare
+}
+
+// Observe records ev at now and returns advisory events for the tenants
+// over their share in any quota windows that closed before now. Queries
+// without the field count toward the total only; lifecycle events are
+// ignored.
+func (t *Tracker) Observe(ev proxy.Event, now time.Time) []proxy.Event {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var out []proxy.Event
+	if t.start.IsZero() {
+		t.start = now.Truncate(t.window)
+	}
+	if !now.Before(t.start.Add(t.window)) {
+		out = t.closeWindow(t.start.Add(t.window))
+		// Windows without queries have no tenant over its share.
+		if now.Sub(t.start) >= 2*t.window {
+			clear(t.over)
+		}
+		t.start = now.Truncate(t.window)
+	}
+
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	default:
+		return out
+	}
+	if ev.Query == "" {
+		return out
+	}
+	value := ev.Fields[t.field]
+	// Queries without a tenant are kept under "" so the overall statistics
+	// include them; Tenants leaves the key out.
+	t.agg.Observe(value, now, ev.Duration, ev.Error != "")
+	t.total++
+	if value != "" {
+		if _, ok := t.calls[value]; ok || len(t.calls) < maxTenants {
+			t.calls[value]++
+		}
+	}
+	return out
+}
+
+// closeWindow returns advisories, at end, for the tenants newly over their
+// share in the window that just ended, and resets the counts.
+func (t *Tracker) closeWindow(end time.Time) []proxy.Event {
+	var out []proxy.Event
+	over := make(map[string]bool)
+	if t.total >= t.minCalls {
+		for value, calls := range t.calls {
+			if float64(calls) <= t.maxShare*float64(t.total) {
+				continue
+			}
+			over[value] = true
+			if t.over[value] {
+				continue
+			}
+			out = append(out, t.advisory(proxy.Quota{
+				Field:    t.field,
+				Value:    value,
+				Calls:    calls,
+				Total:    t.total,
+				MaxShare: t.maxShare,
+				Window:   t.window,
+			}, end))
+		}
+	}
+	slices.SortFunc(out, func(a, b proxy.Event) int { return cmp.Compare(a.Quota.Value, b.Quota.Value) })
+	for i := range out {
+		t.nextID++
+		out[i].ID = "tenant-" + strconv.FormatUint(t.nextID, 10)
+	}
+	t.over = over
+	clear(t.calls)
+	t.total = 0
+	return out
+}
+
+func (t *Tracker) advisory(q proxy.Quota, at time.Time) proxy.Event {
+	return proxy.Event{
+		Op:        proxy.OpAdvisory,
+		Query:     q.Field + "=" + q.Value,
+		StartTime: at,
+		Tags:      []string{Tag},
+		Fields:    map[string]string{q.Field: q.Value},
+		Quota:     &q,
+	}
+}
+
+// Tenants returns the tenants with queries in the window ending at now,
+// busiest first.
+func (t *Tracker) Tenants(now time.Time) []Tenant {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	overall := t.agg.Overall(now)
+	var out []Tenant
+	for _, k := range t.agg.Keys(now) {
+		if k.Key == "" {
+			continue
+		}
+		tn := Tenant{Value: k.Key, Summary: k.Summary}
+		if overall.Count > 0 {
+			tn.Share = float64(k.Count) / float64(overall.Count)
+		}
+		out = append(out, tn)
+	}
+	return out
+}
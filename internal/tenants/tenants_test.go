@@ -0,0 +1,101 @@
+package tenants_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/tenants"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func query(tenant string, d time.Duration) proxy.Event {
+	ev := proxy.Event{Op: proxy.OpQuery, Query: "SELECT * FROM orders", Duration: d}
+	if tenant != "" {
+		ev.Fields = map[string]string{"tenant_id": tenant}
+	}
+	return ev
+}
+
+func TestTracker_Tenants(t *testing.T) {
+	t.Parallel()
+
+	tr := tenants.New("tenant_id")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for range 6 {
+		tr.Observe(query("acme", 10*time.Millisecond), now)
+	}
+	for range 2 {
+		tr.Observe(query("globex", time.Millisecond), now)
+	}
+	tr.Observe(query("", time.Millisecond), now)
+	tr.Observe(proxy.Event{Op: proxy.OpBegin, Query: "BEGIN", Fields: map[string]string{"tenant_id": "acme"}}, now)
+
+	got := tr.Tenants(now)
+	if len(got) != 2 {
+		t.Fatalf("got %d tenants, want 2: %+v", len(got), got)
+	}
+	if got[0].Value != "acme" || got[0].Count != 6 || got[0].P50 != 10*time.Millisecond {
+		t.Errorf("first tenant = %+v, want acme with 6 queries at 10ms", got[0])
+	}
+	if want := 6.0 / 9; got[0].Share != want {
+		t.Errorf("acme share = %v, want %v of all 9 queries", got[0].Share, want)
+	}
+	if got[1].Value != "globex" || got[1].Count != 2 {
+		t.Errorf("second tenant = %+v, want globex with 2 queries", got[1])
+	}
+
+	if got := tr.Tenants(now.Add(tenants.Window + time.Second)); len(got) != 0 {
+		t.Errorf("expected no tenants after the window, got %+v", got)
+	}
+}
+
+func TestTracker_Quota(t *testing.T) {
+	t.Parallel()
+
+	tr := tenants.New("tenant_id", tenants.WithMaxShare(0.5), tenants.WithMinCalls(10), tenants.WithQuotaWindow(time.Minute))
+	start := time.Date(2026, 1, 2, 3, 4, 0, 0, time.UTC)
+
+	// window sends calls per tenant in the minute starting at at and returns
+	// the advisories for the minute before.
+	window := func(at time.Time, calls map[string]int) []proxy.Event {
+		var out []proxy.Event
+		for tenant, n := range calls {
+			for range n {
+				out = append(out, tr.Observe(query(tenant, time.Millisecond), at)...)
+			}
+		}
+		return out
+	}
+
+	window(start, map[string]int{"acme": 8, "globex": 4})
+	advs := window(start.Add(time.Minute), map[string]int{"acme": 8, "globex": 4})
+	if len(advs) != 1 {
+		t.Fatalf("got %d advisories, want 1: %+v", len(advs), advs)
+	}
+	q := advs[0].Quota
+	if advs[0].Op != proxy.OpAdvisory || q == nil || q.Value != "acme" || q.Calls != 8 || q.Total != 12 || q.Field != "tenant_id" {
+		t.Fatalf("unexpected advisory: %+v (quota %+v)", advs[0], q)
+	}
+	if advs[0].Fields["tenant_id"] != "acme" || !advs[0].StartTime.Equal(start.Add(time.Minute)) {
+		t.Errorf("advisory fields %v at %v", advs[0].Fields, advs[0].StartTime)
+	}
+
+	// acme still over in the second minute: not reported again.
+	if advs := window(start.Add(2*time.Minute), map[string]int{"acme": 2, "globex": 8}); len(advs) != 0 {
+		t.Errorf("expected no advisory for a tenant still over, got %+v", advs)
+	}
+	// globex went over in the third minute.
+	advs = window(start.Add(3*time.Minute), map[string]int{"acme": 9, "globex": 1})
+	if len(advs) != 1 || advs[0].Quota.Value != "globex" {
+		t.Fatalf("expected an advisory for globex, got %+v", advs)
+	}
+	// acme went over again in the fourth.
+	advs = window(start.Add(4*time.Minute), map[string]int{"acme": 1})
+	if len(advs) != 1 || advs[0].Quota.Value != "acme" {
+		t.Fatalf("expected an advisory for acme, got %+v", advs)
+	}
+	// The fifth had too few queries to check.
+	if advs := window(start.Add(5*time.Minute), map[string]int{"acme": 1}); len(advs) != 0 {
+		t.Errorf("expected no advisory below min calls, got %+v", advs)
+	}
+}
@@ -0,0 +1,238 @@
+// Package traffic reports drastic changes in how often each query runs: a
+// fingerprint that appears for the first time once the baseline has
+// settled, or one whose call rate rises or falls by a large factor. Such
+// shifts usually follow a deploy that changed the application's queries.
+//
+// Calls are counted per fingerprint in fixed windows. When a window closes,
+// each fingerprint's count is compared with an exponentially weighted
+// average of its previous windows, and changes are returned as advisory
+// events to publish alongside the captured ones.
+package traffic
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/tagger"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Tag is added to advisory events.
+const Tag = "traffic"
+
+// Defs returns the traffic tag with its TUI color.
+func Defs() []tagger.Def {
+	return []tagger.Def{{Name: Tag, Color: "45"}}
+}
+
+// Defaults for the Detector options.
+const (
+	DefaultWindow = time.Minute
+	DefaultFactor = 10.0
+	// DefaultWarmup is how many windows the detector, and each fingerprint,
+	// must observe before changes are reported; until then everything is
+	// new.
+	DefaultWarmup = 5
+	// DefaultMinCalls ignores changes too small to matter, so a query going
+	// from one call a minute to three is not reported.
+	DefaultMinCalls = 10
+	// DefaultMaxFingerprints bounds memory on workloads with unbounded
+	// distinct queries.
+	DefaultMaxFingerprints = 10000
+)
+
+// alpha weights the latest window in a fingerprint's baseline.
+const alpha = 0.3
+
+// maxIdleWindows bounds the catch-up work after a long pause in traffic;
+// every baseline has decayed to almost nothing well before then.
+const maxIdleWindows = 64
+
+// Option configures a Detector.
+type Option func(*Detector)
+
+// WithWindow sets the length of the windows calls are counted in.
+func WithWindow(d time.Duration) Option {
+	return func(t *Detector) {
+		t.window = d
+	}
+}
+
+// WithFactor sets how many times above or below its baseline a
+// fingerprint's call count must move to be reported.
+func WithFactor(f float64) Option {
+	return func(t *Detector) {
+		t.factor = f
+	}
+}
+
+// WithWarmup sets how many windows must pass before changes are reported.
+func WithWarmup(n int) Option {
+	return func(t *Detector) {
+		t.warmup = n
+	}
+}
+
+// WithMinCalls sets the smallest call count, before or after a change, worth
+// reporting.
+func WithMinCalls(n int) Option {
+	return func(t *Detector) {
+		t.minCalls = n
+	}
+}
+
+type key struct {
+	upstream, fingerprint string
+}
+
+type stat struct {
+	baseline float64 // weighted average calls per window
+	windows  int     // closed windows since the fingerprint first ran
+	calls    int     // in the current window
+}
+
+// Detector tracks per-fingerprint call rates. It is not safe for concurrent
+// use.
+type Detector struct {
+	window   time.Duration
+	factor   float64
+	warmup   int
+	minCalls int
+
+	start   time.Time // of the current window
+	windows int       // closed since the first event
+	total   int       // calls in the current window
+	stats   map[key]*stat
+	nextID  uint64
+}
+
+// New returns a Detector with the default settings, adjusted by opts.
+func New(opts ...Option) *Detector {
+	d := &Detector{
+		window:   DefaultWindow,
+		factor:   DefaultFactor,
+		warmup:   DefaultWarmup,
+		minCalls: DefaultMinCalls,
+		stats:    make(map[key]*stat),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Observe counts ev, received at now, and returns the advisory events for
+// the changes it reveals: those of any windows that closed before now, then
+// one for ev's fingerprint if it has never run before. Statements without a
+// query and transaction control are not counted.
+func (d *Detector) Observe(ev proxy.Event, now time.Time) []proxy.Event {
+	var out []proxy.Event
+	if d.start.IsZero() {
+		d.start = now.Truncate(d.window)
+	}
+	for n := 0; !now.Before(d.start.Add(d.window)); n++ {
+		if n == maxIdleWindows {
+			d.start = now.Truncate(d.window)
+			break
+		}
+		out = d.closeWindow(out)
+		d.start = d.start.Add(d.window)
+	}
+	if adv, ok := d.count(ev, now); ok {
+		out = append(out, adv)
+	}
+	for i := range out {
+		d.nextID++
+		out[i].ID = "traffic-" + strconv.FormatUint(d.nextID, 10)
+	}
+	return out
+}
+
+// count adds ev to its fingerprint's calls, returning an advisory when the
+// fingerprint is new.
+func (d *Detector) count(ev proxy.Event, now time.Time) (proxy.Event, bool) {
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	default:
+		return proxy.Event{}, false
+	}
+	if ev.Query == "" {
+		return proxy.Event{}, false
+	}
+	fp := ev.Fingerprint
+	if fp == "" {
+		fp = query.Fingerprint(ev.Query)
+	}
+	k := key{upstream: ev.Upstream, fingerprint: fp}
+	st, ok := d.stats[k]
+	if !ok {
+		if len(d.stats) >= DefaultMaxFingerprints {
+			for k := range d.stats { // evict an arbitrary fingerprint
+				delete(d.stats, k)
+				break
+			}
+		}
+		st = &stat{}
+		d.stats[k] = st
+	}
+	st.calls++
+	d.total++
+	if ok || d.windows < d.warmup {
+		return proxy.Event{}, false
+	}
+	return advisory(k, proxy.TrafficChange{Kind: proxy.TrafficNew, Window: d.window}, now), true
+}
+
+// closeWindow compares each fingerprint's calls in the window that just
+// ended with its baseline, appends advisories for drastic changes to out,
+// and folds the window into the baselines.
+func (d *Detector) closeWindow(out []proxy.Event) []proxy.Event {
+	end := d.start.Add(d.window)
+	first := len(out)
+	for k, st := range d.stats {
+		calls := float64(st.calls)
+		if st.windows == 0 {
+			// The first window is usually partial; it seeds the baseline
+			// without being judged.
+			st.baseline = calls
+		} else {
+			if st.windows >= d.warmup {
+				change := proxy.TrafficChange{Calls: st.calls, Baseline: st.baseline, Window: d.window}
+				switch {
+				case st.baseline > 0 && calls >= d.factor*st.baseline && st.calls >= d.minCalls:
+					change.Kind = proxy.TrafficRise
+				// A pause in all traffic is not a change in any one query.
+				case d.total > 0 && calls*d.factor <= st.baseline && st.baseline >= float64(d.minCalls):
+					change.Kind = proxy.TrafficDrop
+				}
+				if change.Kind != 0 {
+					out = append(out, advisory(k, change, end))
+				}
+			}
+			st.baseline += alpha * (calls - st.baseline)
+		}
+		st.windows++
+		st.calls = 0
+	}
+	slices.SortFunc(out[first:], func(a, b proxy.Event) int {
+		return cmp.Or(cmp.Compare(a.Upstream, b.Upstream), cmp.Compare(a.Query, b.Query))
+	})
+	d.windows++
+	d.total = 0
+	return out
+}
+
+func advisory(k key, change proxy.TrafficChange, at time.Time) proxy.Event {
+	return proxy.Event{
+		Upstream:    k.upstream,
+		Op:          proxy.OpAdvisory,
+		Query:       k.fingerprint,
+		Fingerprint: k.fingerprint,
+		StartTime:   at,
+		Tags:        []string{Tag},
+		Traffic:     &change,
+	}
+}
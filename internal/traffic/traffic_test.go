@@ -0,0 +1,112 @@
+package traffic_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/traffic"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+var base = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// feed runs calls[q] events of each query in window w, spread across it, and
+// returns the advisories.
+func feed(d *traffic.Detector, w int, calls map[string]int) []proxy.Event {
+	var out []proxy.Event
+	start := base.Add(time.Duration(w) * time.Minute)
+	for q, n := range calls {
+		for i := range n {
+			at := start.Add(time.Duration(i) * time.Minute / time.Duration(n))
+			out = append(out, d.Observe(proxy.Event{Op: proxy.OpQuery, Query: q}, at)...)
+		}
+	}
+	return out
+}
+
+// steady runs windows [from, to) of the same calls and fails on any
+// advisory.
+func steady(t *testing.T, d *traffic.Detector, from, to int, calls map[string]int) {
+	t.Helper()
+
+	for w := from; w < to; w++ {
+		if advs := feed(d, w, calls); len(advs) > 0 {
+			t.Fatalf("window %d: unexpected advisories: %+v", w, advs)
+		}
+	}
+}
+
+func TestDetector_Changes(t *testing.T) {
+	t.Parallel()
+
+	const (
+		users  = "SELECT * FROM users WHERE id = ?"
+		orders = "SELECT * FROM orders WHERE user_id = ?"
+	)
+	tests := []struct {
+		name  string
+		next  map[string]int
+		query string
+		want  proxy.TrafficKind
+	}{
+		{name: "rise", next: map[string]int{users: 400, orders: 20}, query: users, want: proxy.TrafficRise},
+		{name: "drop", next: map[string]int{users: 2, orders: 20}, query: users, want: proxy.TrafficDrop},
+		{name: "new", next: map[string]int{users: 30, orders: 20, "DELETE FROM carts": 1}, query: "DELETE FROM carts", want: proxy.TrafficNew},
+		{name: "modest change", next: map[string]int{users: 90, orders: 20}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			d := traffic.New()
+			steady(t, d, 0, 10, map[string]int{users: 30, orders: 20})
+			advs := feed(d, 10, tt.next)
+			// Close window 10.
+			advs = append(advs, d.Observe(proxy.Event{Op: proxy.OpQuery, Query: orders}, base.Add(11*time.Minute))...)
+
+			if tt.want == 0 {
+				if len(advs) > 0 {
+					t.Fatalf("unexpected advisories: %+v", advs)
+				}
+				return
+			}
+			if len(advs) != 1 {
+				t.Fatalf("expected 1 advisory, got %+v", advs)
+			}
+			adv := advs[0]
+			if adv.Op != proxy.OpAdvisory || adv.Query != tt.query || adv.Traffic == nil || adv.Traffic.Kind != tt.want {
+				t.Fatalf("unexpected advisory: %+v (%+v)", adv, adv.Traffic)
+			}
+			if len(adv.Tags) != 1 || adv.Tags[0] != traffic.Tag || adv.ID == "" {
+				t.Errorf("unexpected tags or id: %v %q", adv.Tags, adv.ID)
+			}
+		})
+	}
+}
+
+func TestDetector_Warmup(t *testing.T) {
+	t.Parallel()
+
+	// Everything is new at first.
+	d := traffic.New(traffic.WithWarmup(3))
+	steady(t, d, 0, 3, map[string]int{"SELECT * FROM a": 20, "SELECT * FROM b": 20})
+
+	// A query's first windows set its baseline rather than being judged
+	// against one.
+	advs := feed(d, 3, map[string]int{"SELECT * FROM a": 20, "SELECT * FROM b": 20, "SELECT * FROM c": 1})
+	if len(advs) != 1 || advs[0].Traffic.Kind != proxy.TrafficNew {
+		t.Fatalf("expected a new query advisory, got %+v", advs)
+	}
+	steady(t, d, 4, 6, map[string]int{"SELECT * FROM a": 20, "SELECT * FROM b": 20, "SELECT * FROM c": 500})
+}
+
+func TestDetector_PauseIsNotADrop(t *testing.T) {
+	t.Parallel()
+
+	d := traffic.New()
+	steady(t, d, 0, 10, map[string]int{"SELECT * FROM a": 50})
+	// Nothing runs in windows 10 through 12.
+	if advs := d.Observe(proxy.Event{Op: proxy.OpQuery, Query: "SELECT * FROM a"}, base.Add(13*time.Minute)); len(advs) > 0 {
+		t.Fatalf("unexpected advisories: %+v", advs)
+	}
+}
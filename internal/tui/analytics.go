@@ -3,6 +3,8 @@ package tui
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -10,7 +12,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
-	"github.com/mickamy/sql-tap/clipboard"
+	"github.com/mickamy/sql-tap/internal/clipboard"
 	"github.com/mickamy/sql-tap/proxy"
 )
 
@@ -53,6 +55,16 @@ type analyticsRow struct {
 	avgDuration   time.Duration
 }
 
+// untaggedGroup is the analytics group for events without tags when grouping by tag.
+const untaggedGroup = "(untagged)"
+
+// unsetFieldGroup is the analytics group for events without a value when
+// grouping by an extracted field.
+const unsetFieldGroup = "(unset)"
+
+// buildAnalyticsRows aggregates events per query, per tag when
+// analyticsByTag is set, or per value of analyticsField. An event with
+// several tags counts toward each.
 func (m Model) buildAnalyticsRows() []analyticsRow {
 	type agg struct {
 		count    int
@@ -62,7 +74,8 @@ func (m Model) buildAnalyticsRows() []analyticsRow {
 
 	for _, ev := range m.events {
 		switch proxy.Op(ev.GetOp()) {
-		case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpBind, proxy.OpPrepare:
+		case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpBind, proxy.OpPrepare, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice,
+			proxy.OpConnect, proxy.OpDisconnect:
 			continue
 		case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
 		}
@@ -72,13 +85,28 @@ func (m Model) buildAnalyticsRows() []analyticsRow {
 			continue
 		}
 
-		g, ok := groups[q]
-		if !ok {
-			g = &agg{}
-			groups[q] = g
+		keys := []string{q}
+		switch {
+		case m.analyticsField != "":
+			keys = []string{unsetFieldGroup}
+			if v, ok := ev.GetFields()[m.analyticsField]; ok {
+				keys = []string{v}
+			}
+		case m.analyticsByTag:
+			keys = ev.GetTags()
+			if len(keys) == 0 {
+				keys = []string{untaggedGroup}
+			}
+		}
+		for _, k := range keys {
+			g, ok := groups[k]
+			if !ok {
+				g = &agg{}
+				groups[k] = g
+			}
+			g.count++
+			g.totalDur += ev.GetDuration().AsDuration()
 		}
-		g.count++
-		g.totalDur += ev.GetDuration().AsDuration()
 	}
 
 	rows := make([]analyticsRow, 0, len(groups))
@@ -110,10 +138,7 @@ func sortAnalyticsRows(rows []analyticsRow, mode analyticsSortMode) {
 func (m Model) updateAnalytics(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "ctrl+c":
-		if m.conn != nil {
-			_ = m.conn.Close()
-		}
-		return m, tea.Quit
+		return m.quit()
 	case "q":
 		m.view = viewList
 		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
@@ -157,6 +182,13 @@ func (m Model) updateAnalytics(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		sortAnalyticsRows(m.analyticsRows, m.analyticsSortMode)
 		m.analyticsCursor = 0
 		return m, nil
+	case "g":
+		m = m.nextAnalyticsGroup()
+		m.analyticsRows = m.buildAnalyticsRows()
+		sortAnalyticsRows(m.analyticsRows, m.analyticsSortMode)
+		m.analyticsCursor = 0
+		m.analyticsHScroll = 0
+		return m, nil
 	case "c":
 		if m.analyticsCursor >= 0 && m.analyticsCursor < len(m.analyticsRows) {
 			_ = clipboard.Copy(context.Background(), m.analyticsRows[m.analyticsCursor].query)
@@ -166,6 +198,34 @@ func (m Model) updateAnalytics(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// nextAnalyticsGroup cycles the analytics grouping: query, tag, then each
+// extracted field the received events carry, in name order.
+func (m Model) nextAnalyticsGroup() Model {
+	names := make(map[string]bool)
+	for _, ev := range m.events {
+		for name := range ev.GetFields() {
+			names[name] = true
+		}
+	}
+	fields := slices.Sorted(maps.Keys(names))
+	switch {
+	case !m.analyticsByTag && m.analyticsField == "":
+		m.analyticsByTag = true
+	case m.analyticsField == "":
+		m.analyticsByTag = false
+		if len(fields) > 0 {
+			m.analyticsField = fields[0]
+		}
+	default:
+		next := ""
+		if i := slices.Index(fields, m.analyticsField); i >= 0 && i+1 < len(fields) {
+			next = fields[i+1]
+		}
+		m.analyticsField = next
+	}
+	return m
+}
+
 const (
 	analyticsColMarker = 2  // "▶ " or "  "
 	analyticsColCount  = 7  // "  Count" right-aligned
@@ -192,7 +252,14 @@ func (m Model) renderAnalytics() string {
 	innerWidth := max(m.width-4, 20)
 	visibleRows := m.analyticsVisibleRows()
 
-	title := fmt.Sprintf(" Analytics (%d templates) [sort: %s] ", len(m.analyticsRows), m.analyticsSortMode)
+	groupLabel, groupTitle := "templates", "Query"
+	switch {
+	case m.analyticsField != "":
+		groupLabel, groupTitle = m.analyticsField+" values", m.analyticsField
+	case m.analyticsByTag:
+		groupLabel, groupTitle = "tags", "Tag"
+	}
+	title := fmt.Sprintf(" Analytics (%d %s) [sort: %s] ", len(m.analyticsRows), groupLabel, m.analyticsSortMode)
 
 	colQuery := max(innerWidth-analyticsColMarker-analyticsColCount-analyticsColAvg-analyticsColTotal-3, 10)
 
@@ -200,7 +267,7 @@ func (m Model) renderAnalytics() string {
 		analyticsColCount, "Count",
 		analyticsColAvg, "Avg",
 		analyticsColTotal, "Total",
-		"Query",
+		groupTitle,
 	)
 
 	dataRows := max(visibleRows-1, 1) // -1 for header
@@ -267,7 +334,7 @@ func (m Model) renderAnalytics() string {
 
 	if n := len(boxLines); n > 0 {
 		borderFg := lipgloss.NewStyle().Foreground(borderColor)
-		help := " q: back  j/k: scroll  h/l: pan  s: sort  c: copy "
+		help := " q: back  j/k: scroll  h/l: pan  s: sort  g: group by query/tag/field  c: copy "
 		dashes := max(innerWidth-len([]rune(help)), 0)
 		boxLines[n-1] = borderFg.Render("╰") +
 			lipgloss.NewStyle().Faint(true).Render(help) +
@@ -0,0 +1,90 @@
+package tui
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+)
+
+// annotationMsg carries an annotation someone (possibly this TUI) made, from
+// the Watch stream.
+type annotationMsg struct{ annotation *tapv1.Annotation }
+
+// presenceMsg carries the collaborating watchers, from the Watch stream.
+type presenceMsg struct{ clients []string }
+
+// annotateResultMsg carries the result of an Annotate call. The annotation
+// itself arrives on the Watch stream like everyone else's.
+type annotateResultMsg struct{ err error }
+
+// startNote opens the note editor for the event at the cursor, prefilled
+// with its current note.
+func (m Model) startNote() Model {
+	ev := m.cursorEvent()
+	if ev == nil || ev.GetId() == "" {
+		return m
+	}
+	m.noteMode = true
+	m.noteEventID = ev.GetId()
+	m.noteText = m.annotations[ev.GetId()].GetText()
+	return m
+}
+
+func (m Model) updateNote(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.noteMode = false
+		return m, annotate(m.client, m.noteEventID, m.noteText)
+	case "esc":
+		m.noteMode = false
+		return m, nil
+	case "backspace":
+		if len(m.noteText) > 0 {
+			_, size := utf8.DecodeLastRuneInString(m.noteText)
+			m.noteText = m.noteText[:len(m.noteText)-size]
+		}
+		return m, nil
+	case "ctrl+c":
+		return m.quit()
+	}
+	m.noteText += string(msg.Runes)
+	return m, nil
+}
+
+func annotate(client tapv1.TapServiceClient, eventID, text string) tea.Cmd {
+	return func() tea.Msg {
+		_, err := client.Annotate(context.Background(), &tapv1.AnnotateRequest{
+			EventId: eventID,
+			Text:    strings.TrimSpace(text),
+			Client:  auth.Identity(),
+		})
+		return annotateResultMsg{err: err}
+	}
+}
+
+// applyAnnotation records or clears an event's note.
+func (m Model) applyAnnotation(a *tapv1.Annotation) Model {
+	if m.annotations == nil {
+		m.annotations = make(map[string]*tapv1.Annotation)
+	}
+	if a.GetText() == "" {
+		delete(m.annotations, a.GetEventId())
+	} else {
+		m.annotations[a.GetEventId()] = a
+	}
+	return m
+}
+
+// noteLines renders an event's shared note for the preview and inspector.
+func (m Model) noteLines(ev *tapv1.QueryEvent) []string {
+	a, ok := m.annotations[ev.GetId()]
+	if !ok {
+		return nil
+	}
+	return []string{"Note:     " + a.GetText() + " — " + a.GetAuthor()}
+}
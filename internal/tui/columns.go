@@ -0,0 +1,221 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mickamy/sql-tap/internal/highlight"
+)
+
+type columnID int
+
+const (
+	columnOp columnID = iota
+	columnQuery
+	columnRows
+	columnDuration
+	columnTime
+	columnTags
+	columnBytes
+)
+
+// column is one configurable column of the list view. The query column has no
+// fixed width; it takes whatever space the other visible columns leave.
+type column struct {
+	id      columnID
+	name    string
+	title   string
+	width   int
+	visible bool
+	right   bool // right-aligned
+}
+
+const (
+	minColumnWidth = 4
+	maxColumnWidth = 40
+)
+
+func defaultColumns() []column {
+	return []column{
+		{id: columnOp, name: "op", title: "Op", width: colOp, visible: true},
+		{id: columnQuery, name: "query", title: "Query", visible: true},
+		{id: columnRows, name: "rows", title: "Rows", width: colRows, right: true},
+		{id: columnBytes, name: "bytes", title: "Bytes", width: colBytes, right: true},
+		{id: columnDuration, name: "duration", title: "Duration", width: colDuration, visible: true, right: true},
+		{id: columnTime, name: "time", title: "Time", width: colTime, visible: true, right: true},
+		{id: columnTags, name: "tags", title: "Tags", width: colTags},
+	}
+}
+
+// cell is the rendered content of one column in a row. style, when set,
+// overrides the default (bold on the cursor row) for this cell.
+type cell struct {
+	text  string
+	style *lipgloss.Style
+	sql   bool // highlighted as SQL, except on the cursor row
+}
+
+// queryColumnWidth returns the width left for the query column after the
+// marker and all other visible columns.
+func (m Model) queryColumnWidth(innerWidth int) int {
+	used := colMarker
+	for _, c := range m.columns {
+		if !c.visible || c.id == columnQuery {
+			continue
+		}
+		used += c.width + 1 // +1 for the separating space
+	}
+	return max(innerWidth-used, 10)
+}
+
+// renderColumns lays out cells for the visible columns after prefix.
+// queryWidth is the width of the query column for this row.
+func (m Model) renderColumns(prefix string, cells map[columnID]cell, queryWidth int, isCursor bool) string {
+	bold := lipgloss.NewStyle().Bold(true)
+	parts := make([]string, 0, len(m.columns))
+	for _, c := range m.columns {
+		if !c.visible {
+			continue
+		}
+		width := c.width
+		if c.id == columnQuery {
+			width = queryWidth
+		}
+
+		cl := cells[c.id]
+		text := truncate(cl.text, width)
+		switch {
+		case cl.sql && !isCursor:
+			text = highlight.SQL(text)
+		case cl.style != nil && isCursor:
+			text = cl.style.Bold(true).Render(text)
+		case cl.style != nil:
+			text = cl.style.Render(text)
+		case isCursor:
+			text = bold.Render(text)
+		}
+
+		if c.right {
+			parts = append(parts, padLeft(text, width))
+		} else {
+			parts = append(parts, padRight(text, width))
+		}
+	}
+	return prefix + strings.Join(parts, " ")
+}
+
+func (m Model) renderHeader(queryWidth int) string {
+	cells := make(map[columnID]cell, len(m.columns))
+	for _, c := range m.columns {
+		cells[c.id] = cell{text: c.title}
+	}
+	return lipgloss.NewStyle().Bold(true).Render(m.renderColumns("    ", cells, queryWidth, false))
+}
+
+// updateColumns handles keys in column edit mode: h/l select a column,
+// space toggles it, +/- resize it, H/L move it.
+func (m Model) updateColumns(key string) Model {
+	m.columns = append([]column(nil), m.columns...) // keep edits off the previous model's slice
+	sel := &m.columns[m.columnCursor]
+	switch key {
+	case "esc", "o", "enter":
+		m.columnMode = false
+	case "h", "left":
+		m.columnCursor = max(m.columnCursor-1, 0)
+	case "l", "right":
+		m.columnCursor = min(m.columnCursor+1, len(m.columns)-1)
+	case " ":
+		if sel.id != columnQuery {
+			sel.visible = !sel.visible
+		}
+	case "+", "=":
+		if sel.id != columnQuery {
+			sel.width = min(sel.width+1, maxColumnWidth)
+		}
+	case "-":
+		if sel.id != columnQuery {
+			sel.width = max(sel.width-1, minColumnWidth)
+		}
+	case "H":
+		if m.columnCursor > 0 {
+			m.columns[m.columnCursor-1], m.columns[m.columnCursor] = m.columns[m.columnCursor], m.columns[m.columnCursor-1]
+			m.columnCursor--
+		}
+	case "L":
+		if m.columnCursor < len(m.columns)-1 {
+			m.columns[m.columnCursor+1], m.columns[m.columnCursor] = m.columns[m.columnCursor], m.columns[m.columnCursor+1]
+			m.columnCursor++
+		}
+	}
+	return m
+}
+
+// columnFooter describes the column being edited in column edit mode.
+func (m Model) columnFooter() string {
+	var names []string
+	for i, c := range m.columns {
+		name := c.name
+		if !c.visible {
+			name = "(" + name + ")"
+		}
+		if i == m.columnCursor {
+			name = "[" + name + "]"
+		}
+		names = append(names, name)
+	}
+	sel := m.columns[m.columnCursor]
+	width := "auto"
+	if sel.id != columnQuery {
+		width = fmt.Sprintf("%d", sel.width)
+	}
+	return fmt.Sprintf("  columns: %s  width: %s  h/l: select  space: show/hide  +/-: resize  H/L: move  esc: done",
+		strings.Join(names, " "), width)
+}
+
+// columnState is the persisted form of a column.
+type columnState struct {
+	Name    string `json:"name"`
+	Width   int    `json:"width,omitempty"`
+	Visible bool   `json:"visible"`
+}
+
+func (m Model) columnStates() []columnState {
+	out := make([]columnState, len(m.columns))
+	for i, c := range m.columns {
+		out[i] = columnState{Name: c.name, Width: c.width, Visible: c.visible}
+	}
+	return out
+}
+
+// applyColumnStates restores column order, widths, and visibility. Unknown
+// names are ignored and columns missing from states keep their defaults,
+// appended at the end.
+func applyColumnStates(states []columnState) []column {
+	defaults := defaultColumns()
+	byName := make(map[string]column, len(defaults))
+	for _, c := range defaults {
+		byName[c.name] = c
+	}
+
+	out := make([]column, 0, len(defaults))
+	for _, st := range states {
+		c, ok := byName[st.Name]
+		if !ok {
+			continue
+		}
+		delete(byName, st.Name)
+		c.visible = st.Visible || c.id == columnQuery
+		if c.id != columnQuery && st.Width > 0 {
+			c.width = min(max(st.Width, minColumnWidth), maxColumnWidth)
+		}
+		out = append(out, c)
+	}
+	for _, c := range defaults {
+		if _, ok := byName[c.name]; ok {
+			out = append(out, c)
+		}
+	}
+	return out
+}
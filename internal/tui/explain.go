@@ -2,25 +2,23 @@ package tui
 
 import (
 	"context"
+	"slices"
 	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/ansi"
 
-	"github.com/mickamy/sql-tap/clipboard"
 	"github.com/mickamy/sql-tap/explain"
 	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
-	"github.com/mickamy/sql-tap/highlight"
+	"github.com/mickamy/sql-tap/internal/clipboard"
+	"github.com/mickamy/sql-tap/internal/highlight"
 )
 
 func (m Model) updateExplain(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "ctrl+c":
-		if m.conn != nil {
-			_ = m.conn.Close()
-		}
-		return m, tea.Quit
+		return m.quit()
 	case "q":
 		m.view = viewList
 		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
@@ -46,7 +44,7 @@ func (m Model) updateExplain(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 	case "l", "right":
-		innerWidth := max(m.width-4, 20)
+		innerWidth := m.explainInnerWidth()
 		maxW := m.explainMaxLineWidth()
 		maxHScroll := max(maxW-innerWidth, 0)
 		if m.explainHScroll < maxHScroll {
@@ -68,10 +66,43 @@ func (m Model) updateExplain(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			mode = explain.Analyze
 		}
 		return m, openEditor(m.explainQuery, m.explainArgs, mode)
+	case "x":
+		if m.explainQuery == "" {
+			return m, nil
+		}
+		mode := explain.Analyze
+		if m.explainMode == explain.Analyze {
+			mode = explain.Explain
+		}
+		m.explainPlan = ""
+		m.explainAdvisories = nil
+		m.explainIndexes = nil
+		m.explainErr = nil
+		m.explainScroll = 0
+		m.explainHScroll = 0
+		m.explainMode = mode
+		return m, runExplain(m.client, mode, m.explainUpstream, m.explainQuery, m.explainArgs)
 	}
 	return m, nil
 }
 
+// explainSidePaneMinWidth is the terminal width from which the plan is shown
+// beside the query list instead of full screen.
+const explainSidePaneMinWidth = 140
+
+// explainListWidth returns the width of the query list shown to the left of
+// the plan, or 0 when the terminal is too narrow for a side pane.
+func (m Model) explainListWidth() int {
+	if m.width < explainSidePaneMinWidth {
+		return 0
+	}
+	return m.width * 2 / 5
+}
+
+func (m Model) explainInnerWidth() int {
+	return max(m.width-m.explainListWidth()-4, 20)
+}
+
 func (m Model) explainLines() []string {
 	if m.explainErr != nil {
 		return []string{"Error: " + m.explainErr.Error()}
@@ -79,7 +110,7 @@ func (m Model) explainLines() []string {
 	if m.explainPlan == "" {
 		return []string{"Running " + m.explainMode.String() + "..."}
 	}
-	return strings.Split(m.explainPlan, "\n")
+	return append(strings.Split(m.explainPlan, "\n"), indexLines(m.explainIndexes)...)
 }
 
 func (m Model) explainMaxLineWidth() int {
@@ -97,7 +128,19 @@ func (m Model) explainVisibleRows() int {
 }
 
 func (m Model) renderExplain() string {
-	innerWidth := max(m.width-4, 20)
+	plan := m.renderExplainPlan()
+	listWidth := m.explainListWidth()
+	if listWidth == 0 {
+		return plan
+	}
+
+	left := m
+	left.width = listWidth
+	return lipgloss.JoinHorizontal(lipgloss.Top, left.renderList(m.explainVisibleRows()), plan)
+}
+
+func (m Model) renderExplainPlan() string {
+	innerWidth := m.explainInnerWidth()
 	visibleRows := m.explainVisibleRows()
 
 	lines := m.explainLines()
@@ -111,8 +154,17 @@ func (m Model) renderExplain() string {
 	visible := lines[m.explainScroll:end]
 
 	// Highlight full lines first, then ANSI-aware slice for horizontal scroll.
+	table := m.explainTable && m.explainPlan != "" && m.explainErr == nil
 	for i, line := range visible {
-		visible[i] = ansi.Cut(highlight.Plan(line), m.explainHScroll, m.explainHScroll+innerWidth)
+		switch row := m.explainScroll + i; {
+		case !table:
+			line = highlight.Plan(line)
+		case row == 0: // header
+			line = lipgloss.NewStyle().Bold(true).Render(line)
+		case row == 1: // rule under the header
+			line = lipgloss.NewStyle().Faint(true).Render(line)
+		}
+		visible[i] = ansi.Cut(line, m.explainHScroll, m.explainHScroll+innerWidth)
 	}
 	content := strings.Join(visible, "\n")
 
@@ -128,6 +180,9 @@ func (m Model) renderExplain() string {
 		borderFg := lipgloss.NewStyle().Foreground(borderColor)
 		titleStyle := lipgloss.NewStyle().Bold(true)
 		title := " " + m.explainMode.String() + " "
+		if len(m.explainAdvisories) > 0 && m.explainErr == nil {
+			title += "· " + strings.Join(m.explainAdvisories, ", ") + " "
+		}
 		dashes := max(innerWidth-len([]rune(title)), 0)
 		boxLines[0] = borderFg.Render("╭") +
 			titleStyle.Render(title) +
@@ -136,7 +191,7 @@ func (m Model) renderExplain() string {
 
 	if n := len(boxLines); n > 0 {
 		borderFg := lipgloss.NewStyle().Foreground(borderColor)
-		help := " q: back  j/k/h/l: scroll  c: copy  e/E: edit+explain "
+		help := " q: back  j/k/h/l: scroll  x: toggle analyze  c: copy  e/E: edit+explain "
 		dashes := max(innerWidth-len([]rune(help)), 0)
 		boxLines[n-1] = borderFg.Render("╰") +
 			lipgloss.NewStyle().Faint(true).Render(help) +
@@ -146,16 +201,51 @@ func (m Model) renderExplain() string {
 	return strings.Join(boxLines, "\n")
 }
 
-func runExplain(client tapv1.TapServiceClient, mode explain.Mode, query string, args []string) tea.Cmd {
+func runExplain(client tapv1.TapServiceClient, mode explain.Mode, upstream, query string, args []string) tea.Cmd {
 	return func() tea.Msg {
 		resp, err := client.Explain(context.Background(), &tapv1.ExplainRequest{
-			Query:   query,
-			Args:    args,
-			Analyze: mode == explain.Analyze,
+			Query:    query,
+			Args:     args,
+			Analyze:  mode == explain.Analyze,
+			Upstream: upstream,
 		})
 		if err != nil {
-			return explainResultMsg{err: err}
+			return explainResultMsg{mode: mode, query: query, err: err}
+		}
+		msg := explainResultMsg{
+			mode:       mode,
+			query:      query,
+			plan:       resp.GetPlan(),
+			advisories: resp.GetAdvisories(),
+			indexes:    resp.GetIndexes(),
+		}
+		if cols := resp.GetColumns(); len(cols) > 0 {
+			rows := make([][]string, len(resp.GetRows()))
+			for i, r := range resp.GetRows() {
+				rows[i] = r.GetValues()
+			}
+			msg.plan = explain.FormatTable(cols, rows)
+			msg.table = true
+		}
+		return msg
+	}
+}
+
+// tagAdvisories adds the advisory tags an EXPLAIN plan warranted to the
+// captured events for the explained query, so they show in the list and can
+// be filtered on.
+func (m Model) tagAdvisories(query string, advisories []string) {
+	if len(advisories) == 0 {
+		return
+	}
+	for _, ev := range m.events {
+		if ev.GetQuery() != query {
+			continue
+		}
+		for _, a := range advisories {
+			if !slices.Contains(ev.GetTags(), a) {
+				ev.Tags = append(slices.Clip(ev.GetTags()), a)
+			}
 		}
-		return explainResultMsg{plan: resp.GetPlan()}
 	}
 }
@@ -0,0 +1,95 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/report"
+)
+
+// exportEvents writes the events matching the current filter to a timestamped
+// file in the working directory and reports the result in the footer.
+func (m Model) exportEvents(format export.Format) Model {
+	events := m.filteredEvents()
+	path := "sql-tap-" + time.Now().Format("20060102-150405") + format.Ext()
+	if err := writeExport(path, format, events); err != nil {
+		m.status = "export: " + err.Error()
+		return m
+	}
+	m.status = fmt.Sprintf("exported %d queries to %s", len(events), path)
+	return m
+}
+
+// snapshotReport writes a report of the events matching the current filter,
+// their statistics, and the last EXPLAIN plan to a timestamped file in the
+// working directory, for attaching to a bug ticket.
+func (m Model) snapshotReport(format report.Format) Model {
+	now := time.Now()
+	snap := report.Snapshot{
+		Source: m.target,
+		Taken:  now,
+		Filter: m.searchQuery,
+		Events: m.filteredEvents(),
+	}
+	if m.explainPlan != "" && m.explainErr == nil {
+		plan := m.explainPlan
+		if len(m.explainIndexes) > 0 {
+			plan += "\n\nSuggested indexes:\n" + strings.Join(m.explainIndexes, "\n")
+		}
+		snap.Plans = append(snap.Plans, report.Plan{Query: m.explainQuery, Plan: plan, Note: m.explainMode.String()})
+	}
+
+	path := "sql-tap-" + now.Format("20060102-150405") + format.Ext()
+	if err := writeReport(path, format, snap); err != nil {
+		m.status = "report: " + err.Error()
+		return m
+	}
+	m.status = fmt.Sprintf("saved a report of %d events to %s", len(snap.Events), path)
+	return m
+}
+
+// filteredEvents returns the events matching the current filter.
+func (m Model) filteredEvents() []*tapv1.QueryEvent {
+	matched := matchingEvents(m.events, m.searchQuery)
+	events := make([]*tapv1.QueryEvent, 0, len(matched))
+	for i, ev := range m.events {
+		if matched[i] {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func writeReport(path string, format report.Format, snap report.Snapshot) error {
+	f, err := os.Create(path) //nolint:gosec // path is generated, not user input
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := report.Write(f, format, snap); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeExport(path string, format export.Format, events []*tapv1.QueryEvent) error {
+	f, err := os.Create(path) //nolint:gosec // path is generated, not user input
+	if err != nil {
+		return fmt.Errorf("create %s: %w", path, err)
+	}
+	if err := export.WriteAll(f, format, events); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", path, err)
+	}
+	return nil
+}
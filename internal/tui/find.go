@@ -0,0 +1,117 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// startFind starts typing a find query. Find moves the cursor between the
+// rows matching a query, in the filter's syntax, without hiding the others,
+// so each match is seen in context.
+func (m Model) startFind() Model {
+	m.findMode = true
+	m.findQuery = ""
+	m.findFrom = m.cursor
+	return m
+}
+
+func (m Model) updateFind(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.findMode = false
+		return m, nil
+	case "esc":
+		m.findMode = false
+		m.findQuery = ""
+		m.cursor = min(m.findFrom, max(len(m.displayRows)-1, 0))
+		return m, nil
+	case "backspace":
+		if len(m.findQuery) > 0 {
+			_, size := utf8.DecodeLastRuneInString(m.findQuery)
+			m.findQuery = m.findQuery[:len(m.findQuery)-size]
+			return m.findFirst(), nil
+		}
+		return m, nil
+	case "ctrl+c":
+		return m.quit()
+	}
+
+	// Ignore non-printable keys.
+	r := msg.Runes
+	if len(r) == 0 {
+		return m, nil
+	}
+	m.findQuery += string(r)
+	return m.findFirst(), nil
+}
+
+// findFirst moves the cursor to the first match at or after where find
+// started, wrapping around, or back there when nothing matches.
+func (m Model) findFirst() Model {
+	matches := m.findMatches()
+	if len(matches) == 0 {
+		m.cursor = min(m.findFrom, max(len(m.displayRows)-1, 0))
+		return m
+	}
+	i, _ := slices.BinarySearch(matches, m.findFrom)
+	m.cursor = matches[i%len(matches)]
+	m.follow = false
+	return m
+}
+
+// jumpMatch moves the cursor to the next match in dir, 1 or -1, wrapping
+// around.
+func (m Model) jumpMatch(dir int) Model {
+	matches := m.findMatches()
+	if len(matches) == 0 {
+		return m
+	}
+	i, found := slices.BinarySearch(matches, m.cursor)
+	switch {
+	case dir > 0 && found:
+		i++
+	case dir < 0:
+		i--
+	}
+	m.cursor = matches[(i+len(matches))%len(matches)]
+	m.follow = false
+	return m
+}
+
+// findMatches returns the indices of the display rows matching findQuery,
+// in order. A transaction summary matches when any of its events does.
+func (m Model) findMatches() []int {
+	if m.findQuery == "" {
+		return nil
+	}
+	matched := matchingEvents(m.events, m.findQuery)
+	var rows []int
+	for i, dr := range m.displayRows {
+		switch dr.kind {
+		case rowEvent:
+			if matched[dr.eventIdx] {
+				rows = append(rows, i)
+			}
+		case rowTxSummary:
+			if slices.ContainsFunc(dr.events, func(idx int) bool { return matched[idx] }) {
+				rows = append(rows, i)
+			}
+		}
+	}
+	return rows
+}
+
+// findLabel describes where the cursor is among the matches.
+func (m Model) findLabel() string {
+	matches := m.findMatches()
+	if i, ok := slices.BinarySearch(matches, m.cursor); ok {
+		return fmt.Sprintf("match %d/%d", i+1, len(matches))
+	}
+	if len(matches) == 1 {
+		return "1 match"
+	}
+	return fmt.Sprintf("%d matches", len(matches))
+}
@@ -0,0 +1,612 @@
+package tui
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func formatTimeFull(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return "-"
+	}
+	return t.AsTime().In(time.Local).Format("15:04:05") //nolint:gosmopolitan // TUI displays local time
+}
+
+func opString(op int32) string {
+	return proxy.Op(op).String()
+}
+
+func padRight(s string, width int) string {
+	w := lipgloss.Width(s)
+	if w >= width {
+		return s
+	}
+	return s + strings.Repeat(" ", width-w)
+}
+
+func padLeft(s string, width int) string {
+	w := lipgloss.Width(s)
+	if w >= width {
+		return s
+	}
+	return strings.Repeat(" ", width-w) + s
+}
+
+var reSpaces = regexp.MustCompile(`\s+`)
+
+func truncate(s string, maxLen int) string {
+	s = strings.TrimSpace(reSpaces.ReplaceAllString(s, " "))
+	if len(s) <= maxLen {
+		return s
+	}
+	if maxLen <= 1 {
+		return s[:maxLen]
+	}
+	return s[:maxLen-1] + "…"
+}
+
+func formatDuration(d *durationpb.Duration) string {
+	if d == nil {
+		return "-"
+	}
+	return formatDurationValue(d.AsDuration())
+}
+
+func formatDurationValue(dur time.Duration) string {
+	switch {
+	case dur < time.Millisecond:
+		us := float64(dur.Microseconds())
+		return fmt.Sprintf("%.0fµs", us)
+	case dur < time.Second:
+		ms := float64(dur.Microseconds()) / 1000
+		return fmt.Sprintf("%.1fms", ms)
+	}
+	return fmt.Sprintf("%.2fs", dur.Seconds())
+}
+
+func formatTime(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return "-"
+	}
+	return t.AsTime().In(time.Local).Format("15:04:05.000") //nolint:gosmopolitan // TUI displays local time
+}
+
+// formatBytes renders n with a binary unit, e.g. "512 B" or "3.4 MiB".
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for q := n / unit; q >= unit; q /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// formatWire returns "<request> request, <response> response" for events with
+// byte counts, or "" for events without.
+func formatWire(ev *tapv1.QueryEvent) string {
+	if ev.GetRequestBytes() == 0 && ev.GetResponseBytes() == 0 {
+		return ""
+	}
+	return formatBytes(ev.GetRequestBytes()) + " request, " + formatBytes(ev.GetResponseBytes()) + " response"
+}
+
+// formatLatency returns the parts of an event's duration, e.g. "1.2ms
+// execute, 30ms fetch", or "" for events without them.
+func formatLatency(ev *tapv1.QueryEvent) string {
+	var parts []string
+	if d := ev.GetQueueLatency(); d != nil {
+		parts = append(parts, formatDuration(d)+" queued")
+	}
+	if d := ev.GetExecLatency(); d != nil {
+		parts = append(parts, formatDuration(d)+" execute")
+	}
+	if d := ev.GetFetchLatency(); d != nil {
+		parts = append(parts, formatDuration(d)+" fetch")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// callerLines returns the "Caller:" lines of an event's call stack, one
+// frame per line, or nil for events without one.
+func callerLines(caller []string) []string {
+	lines := make([]string, 0, len(caller))
+	for i, f := range caller {
+		label := "          "
+		if i == 0 {
+			label = "Caller:   "
+		}
+		lines = append(lines, label+f)
+	}
+	return lines
+}
+
+// formatTLS returns "<version> <cipher>" for TLS connections, or "" for plaintext.
+func formatTLS(ev *tapv1.QueryEvent) string {
+	if ev.GetTlsVersion() == "" {
+		return ""
+	}
+	return strings.TrimSpace(ev.GetTlsVersion() + " " + ev.GetTlsCipher())
+}
+
+// formatClient returns "<user>@<database> from <addr> via <listener>",
+// leaving out the parts the event does not carry.
+func formatClient(ev *tapv1.QueryEvent) string {
+	s := ev.GetUser()
+	if db := ev.GetDatabase(); db != "" {
+		s += "@" + db
+	}
+	if addr := ev.GetClientAddr(); addr != "" {
+		s = strings.TrimSpace(s + " from " + addr)
+	}
+	if l := ev.GetListener(); l != "" {
+		s = strings.TrimSpace(s + " via " + l)
+	}
+	return s
+}
+
+// formatAuth returns "<method> in <duration>" for connections whose
+// authentication exchange was captured, or "".
+func formatAuth(ev *tapv1.QueryEvent) string {
+	if ev.GetAuthMethod() == "" {
+		return ""
+	}
+	if ev.GetAuthDuration() == nil {
+		return ev.GetAuthMethod()
+	}
+	return ev.GetAuthMethod() + " in " + formatDuration(ev.GetAuthDuration())
+}
+
+// formatServer summarizes the server parameters reported at startup, e.g.
+// "17.2, TimeZone UTC", or returns "".
+func formatServer(ev *tapv1.QueryEvent) string {
+	params := ev.GetServerParams()
+	var parts []string
+	if v := params["server_version"]; v != "" {
+		parts = append(parts, v)
+	}
+	if tz := params["TimeZone"]; tz != "" {
+		parts = append(parts, "TimeZone "+tz)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// connectEvent returns the Connect event of ev's connection, which may be
+// ev itself, or nil if the TUI has not received it.
+func (m Model) connectEvent(ev *tapv1.QueryEvent) *tapv1.QueryEvent {
+	if proxy.Op(ev.GetOp()) == proxy.OpConnect {
+		return ev
+	}
+	if ev.GetConnId() == "" {
+		return nil
+	}
+	for _, c := range m.events {
+		if proxy.Op(c.GetOp()) == proxy.OpConnect && c.GetConnId() == ev.GetConnId() && c.GetUpstream() == ev.GetUpstream() {
+			return c
+		}
+	}
+	return nil
+}
+
+// startupLines renders what the client sent when it connected, from its
+// Connect event: the StartupMessage parameters other than user and
+// database, which the Client line shows, and how it negotiated SSL.
+func startupLines(connect *tapv1.QueryEvent) []string {
+	params := connect.GetStartupParams()
+	if len(params) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(params)) {
+		if name == "user" || name == "database" {
+			continue
+		}
+		label := "          "
+		if len(lines) == 0 {
+			label = "Startup:  "
+		}
+		lines = append(lines, label+name+"="+params[name])
+	}
+	switch {
+	case connect.GetTlsVersion() != "": // the TLS line shows it
+	case connect.GetSslRequested():
+		lines = append(lines, "SSL:      requested, declined by the proxy")
+	default:
+		lines = append(lines, "SSL:      not requested")
+	}
+	return lines
+}
+
+// formatRouting returns "<primary|replica> (<reason>)" for events from a
+// proxy in replica routing mode, or "".
+func formatRouting(ev *tapv1.QueryEvent) string {
+	r := ev.GetRouting()
+	if r == nil {
+		return ""
+	}
+	target := "primary"
+	if r.GetReplica() {
+		target = "replica"
+	}
+	return target + " (" + r.GetReason() + ")"
+}
+
+// formatViolation returns "blocked by <rule>", or "<rule> (dry run)" for a
+// statement that was forwarded anyway, or "" if ev broke no policy rule.
+func formatViolation(ev *tapv1.QueryEvent) string {
+	v := ev.GetViolation()
+	if v == nil {
+		return ""
+	}
+	if !v.GetBlocked() {
+		return v.GetRule() + " (dry run)"
+	}
+	return "blocked by " + v.GetRule()
+}
+
+// formatBatch describes the pipelined batch ev belongs to or summarizes, or
+// returns "" if none.
+func formatBatch(ev *tapv1.QueryEvent) string {
+	id := ev.GetBatchId()
+	if id == "" || proxy.Op(ev.GetOp()) != proxy.OpBatch {
+		return id
+	}
+	return fmt.Sprintf("%s (%s)", id, batchLabel(ev))
+}
+
+// batchLabel counts the statements of a batch summary.
+func batchLabel(ev *tapv1.QueryEvent) string {
+	if n := ev.GetBatchSize(); n != 1 {
+		return fmt.Sprintf("%d statements", n)
+	}
+	return "1 statement"
+}
+
+// anomalyLines explains an anomaly flag for the preview and inspector.
+func anomalyLines(ev *tapv1.QueryEvent) []string {
+	a := ev.GetAnomaly()
+	if a == nil {
+		return nil
+	}
+	score := fmt.Sprintf("%.1fσ", a.GetScore())
+	if a.GetScore() >= 1000 {
+		score = "over 1000σ" // the baseline had almost no variance
+	}
+	return []string{fmt.Sprintf("Anomaly:  %s slower than usual (baseline %s)",
+		score, formatDuration(a.GetBaseline()))}
+}
+
+// nPlusOneLines explains an N+1 flag for the preview and inspector.
+func nPlusOneLines(ev *tapv1.QueryEvent) []string {
+	n := ev.GetNPlusOne()
+	if n == nil {
+		return nil
+	}
+	where := "on its connection"
+	if n.GetInTx() {
+		where = "in its transaction"
+	}
+	return []string{fmt.Sprintf("N+1:      call %d of this query %s within %s",
+		n.GetCalls(), where, formatDuration(n.GetSpan()))}
+}
+
+// trafficSummary describes a traffic advisory in a few words for the list.
+func trafficSummary(c *tapv1.TrafficChange) string {
+	switch c.GetKind() {
+	case tapv1.TrafficKind_TRAFFIC_KIND_NEW:
+		return "new query"
+	case tapv1.TrafficKind_TRAFFIC_KIND_RISE:
+		return fmt.Sprintf("calls up %.0fx", float64(c.GetCalls())/c.GetBaseline())
+	case tapv1.TrafficKind_TRAFFIC_KIND_DROP:
+		if c.GetCalls() == 0 {
+			return "calls stopped"
+		}
+		return fmt.Sprintf("calls down %.0fx", c.GetBaseline()/float64(c.GetCalls()))
+	case tapv1.TrafficKind_TRAFFIC_KIND_UNSPECIFIED:
+	}
+	return "traffic change"
+}
+
+// trafficLines explains a traffic advisory for the preview and inspector.
+func trafficLines(ev *tapv1.QueryEvent) []string {
+	c := ev.GetTraffic()
+	if c == nil {
+		return nil
+	}
+	window := c.GetWindow().AsDuration().String()
+	if c.GetKind() == tapv1.TrafficKind_TRAFFIC_KIND_NEW {
+		return []string{"Traffic:  new query, first seen after traffic had settled"}
+	}
+	return []string{fmt.Sprintf("Traffic:  %s: %d calls in %s, usually %.1f",
+		trafficSummary(c), c.GetCalls(), window, c.GetBaseline())}
+}
+
+// quotaSummary describes a tenant quota advisory in a few words for the list.
+func quotaSummary(q *tapv1.TenantQuota) string {
+	return fmt.Sprintf("tenant at %.0f%%", 100*quotaShare(q))
+}
+
+// quotaLines explains a tenant quota advisory for the preview and inspector.
+func quotaLines(ev *tapv1.QueryEvent) []string {
+	q := ev.GetQuota()
+	if q == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf("Quota:    %s=%s ran %d of %d queries (%.0f%%) in %s, over the %.0f%% share",
+		q.GetField(), q.GetValue(), q.GetCalls(), q.GetTotal(), 100*quotaShare(q),
+		q.GetWindow().AsDuration(), 100*q.GetMaxShare())}
+}
+
+func quotaShare(q *tapv1.TenantQuota) float64 {
+	if q.GetTotal() == 0 {
+		return 0
+	}
+	return float64(q.GetCalls()) / float64(q.GetTotal())
+}
+
+// panicLines describes a recovered panic for the preview and, with its
+// stack when withStack is set, the inspector.
+func panicLines(ev *tapv1.QueryEvent, withStack bool) []string {
+	p := ev.GetPanic()
+	if p == nil {
+		return nil
+	}
+	lines := []string{"Panic:    in " + p.GetWhere() + ": " + p.GetValue()}
+	if withStack {
+		for l := range strings.SplitSeq(strings.TrimRight(p.GetStack(), "\n"), "\n") {
+			lines = append(lines, "  "+l)
+		}
+	}
+	return lines
+}
+
+// diagnosticLines describes a connection error a proxy logged.
+func diagnosticLines(ev *tapv1.QueryEvent) []string {
+	d := ev.GetDiagnostic()
+	if d == nil {
+		return nil
+	}
+	return []string{"Log:      " + d.GetLevel() + " " + d.GetMessage()}
+}
+
+// planSummary names the EXPLAIN an auto-explain advisory ran, for the list.
+func planSummary(p *tapv1.AutoPlan) string {
+	if p.GetAnalyze() {
+		return "EXPLAIN ANALYZE"
+	}
+	return "EXPLAIN"
+}
+
+// planLines renders the plan auto-explain ran for ev, or for an earlier
+// slow run of ev's query on the same upstream, showing at most limit lines of
+// it (all when limit is 0).
+func (m Model) planLines(ev *tapv1.QueryEvent, limit int) []string {
+	p := ev.GetPlan()
+	if p == nil && ev.GetFingerprint() != "" && proxy.Op(ev.GetOp()) != proxy.OpAdvisory {
+		for i := len(m.events) - 1; i >= 0; i-- {
+			e := m.events[i]
+			if e.GetPlan() != nil && e.GetFingerprint() == ev.GetFingerprint() && e.GetUpstream() == ev.GetUpstream() {
+				p = e.GetPlan()
+				break
+			}
+		}
+	}
+	if p == nil {
+		return nil
+	}
+	head := "Plan:     " + planSummary(p)
+	if p.GetForId() != "" && p.GetForId() != ev.GetId() {
+		head += ", run for event " + p.GetForId()
+	}
+	lines := []string{head}
+	plan := strings.Split(p.GetPlan(), "\n")
+	if limit > 0 && len(plan) > limit {
+		plan = append(plan[:limit], fmt.Sprintf("... %d more lines", len(plan)-limit))
+	}
+	for _, l := range plan {
+		lines = append(lines, "  "+l)
+	}
+	return append(lines, indexLines(p.GetIndexes())...)
+}
+
+// indexLines renders the indexes suggested for a plan, after a blank line.
+func indexLines(indexes []string) []string {
+	if len(indexes) == 0 {
+		return nil
+	}
+	lines := []string{"", "Suggested indexes:"}
+	for _, ix := range indexes {
+		lines = append(lines, "  "+ix)
+	}
+	return lines
+}
+
+// errorLines renders a failed event's error for the inspector and preview:
+// the message, then the SQLSTATE, severity, and position, detail, and hint
+// when the server reported them.
+func errorLines(ev *tapv1.QueryEvent) []string {
+	if ev.GetError() == "" {
+		return nil
+	}
+	lines := []string{"Error:    " + ev.GetError()}
+	d := ev.GetErrorDetail()
+	var meta []string
+	if d.GetCode() != "" {
+		meta = append(meta, "SQLSTATE "+d.GetCode())
+	}
+	if d.GetSeverity() != "" {
+		meta = append(meta, d.GetSeverity())
+	}
+	if d.GetPosition() > 0 {
+		meta = append(meta, fmt.Sprintf("at position %d", d.GetPosition()))
+	}
+	if len(meta) > 0 {
+		lines = append(lines, "          "+strings.Join(meta, " · "))
+	}
+	if d.GetDetail() != "" {
+		lines = append(lines, "Detail:   "+d.GetDetail())
+	}
+	if d.GetHint() != "" {
+		lines = append(lines, "Hint:     "+d.GetHint())
+	}
+	return lines
+}
+
+// connSummary renders a connect or disconnect event for the list: the
+// client, and for a disconnect the statements it ran or for a failed
+// connect its error.
+func connSummary(ev *tapv1.QueryEvent) string {
+	s := formatClient(ev)
+	switch {
+	case ev.GetError() != "":
+		s += ": " + ev.GetError()
+	case proxy.Op(ev.GetOp()) == proxy.OpDisconnect:
+		s += fmt.Sprintf(" after %d queries", ev.GetQueries())
+	}
+	return s
+}
+
+// queriesLines renders the statements a disconnected connection ran, for
+// the inspector and preview.
+func queriesLines(ev *tapv1.QueryEvent) []string {
+	if proxy.Op(ev.GetOp()) != proxy.OpDisconnect {
+		return nil
+	}
+	return []string{fmt.Sprintf("Queries:  %d", ev.GetQueries())}
+}
+
+// noticeSummary renders a notice event for the list: its severity and
+// message.
+func noticeSummary(ev *tapv1.QueryEvent) string {
+	n := ev.GetNotice()
+	return n.GetSeverity() + ": " + n.GetMessage()
+}
+
+// noticeLines renders a notice event's message for the inspector and
+// preview, with its SQLSTATE, detail, hint, and the statement it came from.
+func noticeLines(ev *tapv1.QueryEvent) []string {
+	n := ev.GetNotice()
+	if n == nil {
+		return nil
+	}
+	lines := []string{"Notice:   " + noticeSummary(ev)}
+	if n.GetCode() != "" {
+		lines = append(lines, "          SQLSTATE "+n.GetCode())
+	}
+	if n.GetDetail() != "" {
+		lines = append(lines, "Detail:   "+n.GetDetail())
+	}
+	if n.GetHint() != "" {
+		lines = append(lines, "Hint:     "+n.GetHint())
+	}
+	if ev.GetNoticeFor() != "" {
+		lines = append(lines, "From:     statement "+ev.GetNoticeFor())
+	}
+	return lines
+}
+
+// raisedNoticeLines lists the notices the statement ev raised, for the
+// inspector and preview.
+func (m Model) raisedNoticeLines(ev *tapv1.QueryEvent) []string {
+	if ev.GetId() == "" || proxy.Op(ev.GetOp()) == proxy.OpNotice {
+		return nil
+	}
+	var lines []string
+	for _, n := range m.events {
+		if n.GetNoticeFor() != ev.GetId() || n.GetConnId() != ev.GetConnId() || n.GetUpstream() != ev.GetUpstream() {
+			continue
+		}
+		label := "          "
+		if len(lines) == 0 {
+			label = "Notices:  "
+		}
+		lines = append(lines, label+noticeSummary(n))
+	}
+	return lines
+}
+
+// formatConn returns the connection ID, marked when detailed capture is on.
+func formatConn(connID string, verbose bool) string {
+	if verbose {
+		return connID + " (verbose)"
+	}
+	return connID
+}
+
+// certExpiryWarning returns a short header warning when the TLS certificate
+// is expired or expiring within window, or "" otherwise.
+func certExpiryWarning(notAfter time.Time, window time.Duration, now time.Time) string {
+	if notAfter.IsZero() {
+		return ""
+	}
+	remaining := notAfter.Sub(now)
+	switch {
+	case remaining <= 0:
+		return "TLS cert expired"
+	case remaining < 24*time.Hour:
+		return "TLS cert expires in <1d"
+	case remaining < window:
+		return fmt.Sprintf("TLS cert expires in %dd", int(remaining/(24*time.Hour)))
+	}
+	return ""
+}
+
+func friendlyError(err error, width int) string {
+	msg := err.Error()
+
+	var text string
+	switch {
+	case strings.Contains(msg, "connection refused"),
+		strings.Contains(msg, "Unavailable"):
+		text = "Could not connect to sql-tapd.\n" +
+			"Is sql-tapd running?\n\n" +
+			"Error: " + msg
+	}
+	if text == "" {
+		text = "Error: " + msg
+	}
+
+	return lipgloss.NewStyle().Width(width).Render(text)
+}
+
+// formatTags renders tags separated by spaces, each in its configured color.
+func (m Model) formatTags(tags []string) string {
+	parts := make([]string, len(tags))
+	for i, t := range tags {
+		if c, ok := m.tagColors[t]; ok {
+			parts[i] = lipgloss.NewStyle().Foreground(c).Render(t)
+		} else {
+			parts[i] = t
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// formatFields renders ev's extracted fields as name=value pairs in name
+// order, the form the search filter takes after "field:".
+func formatFields(ev *tapv1.QueryEvent) string {
+	return formatPairs(ev.GetFields())
+}
+
+// formatPairs returns "name=value" pairs sorted by name, space-separated.
+func formatPairs(m map[string]string) string {
+	pairs := make([]string, 0, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		pairs = append(pairs, name+"="+m[name])
+	}
+	return strings.Join(pairs, " ")
+}
@@ -0,0 +1,110 @@
+package tui
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// DefaultMaxMemory is the default cap on the memory retained events take.
+const DefaultMaxMemory = 512 << 20
+
+// eventOverhead approximates what an event costs beyond its encoded size:
+// the decoded message's structs and pointers, and the TUI's bookkeeping.
+const eventOverhead = 1 << 10
+
+// evictSlack is the fraction of a cap left free after eviction, so that
+// eviction, which reindexes the retained events, runs once per many events
+// received rather than on every one.
+const evictSlack = 10 // percent
+
+// WithMaxEvents caps the history to the newest n events; 0 keeps them all.
+func WithMaxEvents(n int) Option {
+	return func(m *Model) {
+		m.maxEvents = n
+	}
+}
+
+// WithMaxMemory caps the memory the history takes, approximately, to bytes;
+// 0 removes the cap.
+func WithMaxMemory(bytes int64) Option {
+	return func(m *Model) {
+		m.maxMemory = bytes
+	}
+}
+
+// evictedNote is appended to the event count in the list title once events
+// have been evicted.
+func (m Model) evictedNote() string {
+	if m.evicted == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %d evicted", m.evicted)
+}
+
+func eventSize(ev *tapv1.QueryEvent) int64 {
+	return int64(proto.Size(ev)) + eventOverhead
+}
+
+// overCap reports whether the history exceeds a cap.
+func (m Model) overCap() bool {
+	return (m.maxEvents > 0 && len(m.events) > m.maxEvents) || (m.maxMemory > 0 && m.eventBytes > m.maxMemory)
+}
+
+// retain evicts the oldest events once the history exceeds a cap, down to
+// evictSlack below it, always keeping the newest event. Indexes into the
+// history are shifted, and the display rows rebuilt with the cursor kept on
+// its row while that is retained.
+func (m Model) retain() Model {
+	if !m.overCap() {
+		return m
+	}
+	keepEvents := m.maxEvents - m.maxEvents*evictSlack/100
+	keepBytes := m.maxMemory - m.maxMemory*evictSlack/100
+	n, bytes := 0, m.eventBytes
+	for n < len(m.events)-1 &&
+		((m.maxEvents > 0 && len(m.events)-n > keepEvents) || (m.maxMemory > 0 && bytes > keepBytes)) {
+		bytes -= eventSize(m.events[n])
+		delete(m.serverLogs, m.events[n].GetId())
+		n++
+	}
+	if n == 0 {
+		return m
+	}
+
+	key, ok := m.cursorRowKey()
+	copied := copy(m.events, m.events[n:])
+	clear(m.events[copied:])
+	m.events = m.events[:copied]
+	m.eventBytes = bytes
+	m.evicted += n
+
+	for _, s := range m.topStats {
+		kept := s.examples[:0]
+		for _, i := range s.examples {
+			if i >= n {
+				kept = append(kept, i-n)
+			}
+		}
+		s.examples = kept
+	}
+
+	m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+	switch {
+	case m.follow && m.view == viewList:
+		m.cursor = max(len(m.displayRows)-1, 0)
+	case ok && (key.kind == rowTxSummary || key.eventIdx >= n):
+		key.eventIdx -= n
+		m.cursor = m.findRow(key)
+	default:
+		m.cursor = 0
+		if m.view == viewInspect {
+			m.view, m.inspectReturnTop = viewList, false
+			m.status = "the inspected event was evicted from history"
+		}
+	}
+	m.findFrom = min(m.findFrom, m.cursor)
+	return m
+}
@@ -8,20 +8,23 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
-	"github.com/mickamy/sql-tap/clipboard"
 	"github.com/mickamy/sql-tap/explain"
-	"github.com/mickamy/sql-tap/highlight"
-	"github.com/mickamy/sql-tap/query"
+	"github.com/mickamy/sql-tap/internal/clipboard"
+	"github.com/mickamy/sql-tap/internal/highlight"
+	"github.com/mickamy/sql-tap/internal/query"
 )
 
 func (m Model) updateInspect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.killMode {
+		return m.updateKill(msg)
+	}
 	switch msg.String() {
 	case "ctrl+c":
-		if m.conn != nil {
-			_ = m.conn.Close()
-		}
-		return m, tea.Quit
+		return m.quit()
 	case "q":
+		if m.inspectReturnTop {
+			return m.returnToTop()
+		}
 		m.view = viewList
 		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
 		if m.follow {
@@ -46,6 +49,10 @@ func (m Model) updateInspect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		_ = clipboard.Copy(context.Background(), query.Bind(ev.GetQuery(), ev.GetArgs()))
 		return m, nil
+	case "v":
+		return m.toggleVerbose()
+	case "K":
+		return m.startKill(), nil
 	case "e":
 		return m.startEditExplain(explain.Explain)
 	case "E":
@@ -125,7 +132,10 @@ func (m Model) renderInspector() string {
 	// Replace bottom border with help
 	if n := len(boxLines); n > 0 {
 		borderFg := lipgloss.NewStyle().Foreground(borderColor)
-		help := " q: back  j/k: scroll  c: copy query  C: copy with args  x/X: explain/analyze  e/E: edit+explain "
+		help := " q: back  j/k: scroll  c: copy query  C: copy with args  x/X: explain/analyze  e/E: edit+explain  v: verbose  K: kill "
+		if m.killMode {
+			help = " " + m.killPrompt() + " "
+		}
 		dashes := max(innerWidth-len([]rune(help)), 0)
 		boxLines[n-1] = borderFg.Render("╰") +
 			lipgloss.NewStyle().Faint(true).Render(help) +
@@ -175,9 +185,16 @@ func (m Model) inspectorEventLines(dr displayRow) []string {
 	lines = append(lines, "Op:       "+opString(ev.GetOp()))
 
 	if q := ev.GetQuery(); q != "" {
+		// Single-line statements, as ORMs send them, are laid out a clause
+		// per line; others keep their author's lines, unindented.
 		lines = append(lines, "Query:")
-		for l := range strings.SplitSeq(q, "\n") {
-			lines = append(lines, "  "+highlight.SQL(strings.TrimSpace(l)))
+		pretty := query.Pretty(q)
+		laidOut := pretty != strings.TrimSpace(q)
+		for l := range strings.SplitSeq(pretty, "\n") {
+			if !laidOut {
+				l = strings.TrimSpace(l)
+			}
+			lines = append(lines, "  "+highlight.SQL(l))
 		}
 	}
 
@@ -187,19 +204,137 @@ func (m Model) inspectorEventLines(dr displayRow) []string {
 	}
 
 	lines = append(lines, "Duration: "+formatDuration(ev.GetDuration()))
+	if lat := formatLatency(ev); lat != "" {
+		lines = append(lines, "Latency:  "+lat)
+	}
+	lines = append(lines, anomalyLines(ev)...)
+	lines = append(lines, nPlusOneLines(ev)...)
+	lines = append(lines, trafficLines(ev)...)
+	lines = append(lines, quotaLines(ev)...)
 	lines = append(lines, "Time:     "+formatTimeFull(ev.GetStartTime()))
 
 	if ev.GetRowsAffected() > 0 {
 		lines = append(lines, fmt.Sprintf("Rows:     %d", ev.GetRowsAffected()))
 	}
+	lines = append(lines, queriesLines(ev)...)
+
+	if wire := formatWire(ev); wire != "" {
+		lines = append(lines, "Bytes:    "+wire)
+	}
+
+	lines = append(lines, errorLines(ev)...)
+	lines = append(lines, panicLines(ev, true)...)
+	lines = append(lines, diagnosticLines(ev)...)
+	lines = append(lines, noticeLines(ev)...)
+	lines = append(lines, m.raisedNoticeLines(ev)...)
+	lines = append(lines, m.planLines(ev, 0)...)
+	lines = append(lines, m.noteLines(ev)...)
+
+	if len(ev.GetTags()) > 0 {
+		lines = append(lines, "Tags:     "+m.formatTags(ev.GetTags()))
+	}
+
+	if fields := formatFields(ev); fields != "" {
+		lines = append(lines, "Fields:   "+fields)
+	}
+
+	if ext := formatPairs(ev.GetExtensions()); ext != "" {
+		lines = append(lines, "Meta:     "+ext)
+	}
 
-	if ev.GetError() != "" {
-		lines = append(lines, "Error:    "+ev.GetError())
+	if ev.GetUpstream() != "" {
+		lines = append(lines, "Upstream: "+ev.GetUpstream())
 	}
 
 	if ev.GetTxId() != "" {
 		lines = append(lines, "Tx:       "+ev.GetTxId())
 	}
 
+	if batch := formatBatch(ev); batch != "" {
+		lines = append(lines, "Batch:    "+batch)
+	}
+
+	if ev.GetCursor() != "" {
+		lines = append(lines, fmt.Sprintf("Cursor:   %s (%d fetches)", ev.GetCursor(), ev.GetFetches()))
+	}
+
+	if ev.GetGlobalTxId() != "" {
+		lines = append(lines, "Global:   "+ev.GetGlobalTxId())
+	}
+
+	if ev.GetTraceId() != "" {
+		lines = append(lines, "Trace:    "+ev.GetTraceId()+" (span "+ev.GetSpanId()+")")
+	}
+
+	if ev.GetRoute() != "" {
+		lines = append(lines, "Route:    "+ev.GetRoute())
+	}
+
+	lines = append(lines, callerLines(ev.GetCaller())...)
+
+	if ev.GetConnId() != "" {
+		lines = append(lines, "Conn:     "+formatConn(ev.GetConnId(), m.verboseConns[ev.GetConnId()]))
+	}
+
+	if ev.GetSession() > 0 {
+		lines = append(lines, fmt.Sprintf("Session:  %d", ev.GetSession()))
+	}
+
+	if ev.GetBackendPid() != 0 {
+		lines = append(lines, "Backend:  "+fmt.Sprint(ev.GetBackendPid()))
+	}
+
+	if routing := formatRouting(ev); routing != "" {
+		lines = append(lines, "Routing:  "+routing)
+	}
+	if violation := formatViolation(ev); violation != "" {
+		lines = append(lines, "Policy:   "+violation)
+	}
+
+	if client := formatClient(ev); client != "" {
+		lines = append(lines, "Client:   "+client)
+	}
+
+	if tls := formatTLS(ev); tls != "" {
+		lines = append(lines, "TLS:      "+tls)
+	}
+
+	if auth := formatAuth(ev); auth != "" {
+		lines = append(lines, "Auth:     "+auth)
+	}
+
+	if server := formatServer(ev); server != "" {
+		lines = append(lines, "Server:   "+server)
+	}
+
+	lines = append(lines, startupLines(m.connectEvent(ev))...)
+
+	if phases := ev.GetPhases(); len(phases) > 0 {
+		lines = append(lines, "", "Phases:")
+		for _, ph := range phases {
+			lines = append(lines, fmt.Sprintf("  %-8s %s", ph.GetName(), formatDuration(ph.GetDuration())))
+		}
+	}
+
+	if cols := ev.GetColumns(); len(cols) > 0 {
+		width := 0
+		for _, c := range cols {
+			width = max(width, lipgloss.Width(c.GetName()))
+		}
+		lines = append(lines, "", "Columns:")
+		for _, c := range cols {
+			lines = append(lines, fmt.Sprintf("  %s  %s", padRight(c.GetName(), width), c.GetType()))
+		}
+	}
+
+	if samples := ev.GetRowSamples(); len(samples) > 0 {
+		lines = append(lines, "", "Row samples:")
+		for _, row := range samples {
+			lines = append(lines, fmt.Sprintf("  [%s]", strings.Join(row.GetValues(), ", ")))
+		}
+	}
+
+	lines = append(lines, m.serverLogLines(ev)...)
+
 	return lines
 }
@@ -0,0 +1,72 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// killResultMsg carries the result of a Kill call.
+type killResultMsg struct {
+	pid       uint32
+	terminate bool
+	err       error
+}
+
+// startKill asks which way to stop the backend serving the event at the
+// cursor's connection.
+func (m Model) startKill() Model {
+	ev := m.cursorEvent()
+	if ev == nil || ev.GetBackendPid() == 0 || m.client == nil {
+		return m
+	}
+	m.killMode = true
+	m.killPID = ev.GetBackendPid()
+	m.killUpstream = ev.GetUpstream()
+	return m
+}
+
+func (m Model) updateKill(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "c":
+		m.killMode = false
+		return m, kill(m.client, m.killPID, m.killUpstream, false)
+	case "T":
+		m.killMode = false
+		return m, kill(m.client, m.killPID, m.killUpstream, true)
+	case "ctrl+c":
+		return m.quit()
+	}
+	m.killMode = false
+	return m, nil
+}
+
+func (m Model) killPrompt() string {
+	return fmt.Sprintf("kill backend %d:  c: cancel its query  T: terminate its connection  any other key: abort", m.killPID)
+}
+
+func kill(client tapv1.TapServiceClient, pid uint32, upstream string, terminate bool) tea.Cmd {
+	return func() tea.Msg {
+		_, err := client.Kill(context.Background(), &tapv1.KillRequest{
+			BackendPid: pid,
+			Terminate:  terminate,
+			Upstream:   upstream,
+		})
+		return killResultMsg{pid: pid, terminate: terminate, err: err}
+	}
+}
+
+func (m Model) applyKillResult(msg killResultMsg) Model {
+	switch {
+	case msg.err != nil:
+		m.status = "kill: " + msg.err.Error()
+	case msg.terminate:
+		m.status = fmt.Sprintf("terminated backend %d", msg.pid)
+	default:
+		m.status = fmt.Sprintf("cancelled the query on backend %d", msg.pid)
+	}
+	return m
+}
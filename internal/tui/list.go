@@ -0,0 +1,439 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mickamy/sql-tap/internal/highlight"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Column widths.
+const (
+	colMarker   = 4 // "▶ " or "▾ " (2) + indent/space (2)
+	colOp       = 10
+	colRows     = 6
+	colBytes    = 10
+	colDuration = 10
+	colTime     = 12
+	colTags     = 14
+)
+
+// txColors is a palette for coloring transaction rows.
+var txColors = []lipgloss.Color{"6", "3", "5", "2", "4", "1"}
+
+func (m Model) renderList(maxRows int) string {
+	innerWidth := max(m.width-4, 20)
+	colQuery := m.queryColumnWidth(innerWidth)
+
+	var title string
+	if m.searchQuery != "" {
+		matched := 0
+		for _, dr := range m.displayRows {
+			if dr.kind == rowEvent {
+				matched++
+			}
+		}
+		// The filter stays in view while it narrows the live stream.
+		title = fmt.Sprintf(" sql-tap (%d/%d queries%s) [filter: %s] ", matched, len(m.events), m.evictedNote(), truncate(m.searchQuery, 40))
+	} else {
+		title = fmt.Sprintf(" sql-tap (%d queries%s) ", len(m.events), m.evictedNote())
+	}
+	switch m.sortMode {
+	case sortDuration:
+		title += "[slow] "
+	case sortRows:
+		title += "[rows] "
+	case sortBytes:
+		title += "[bytes] "
+	case sortChronological:
+	}
+	if warn := certExpiryWarning(m.tlsCertNotAfter, m.tlsCertWarn, time.Now()); warn != "" {
+		title += "[" + warn + "] "
+	}
+
+	border := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth)
+
+	dataRows := max(maxRows-1, 1) // -1 for header row
+
+	start := 0
+	if len(m.displayRows) > dataRows {
+		start = max(m.cursor-dataRows/2, 0)
+		if start+dataRows > len(m.displayRows) {
+			start = len(m.displayRows) - dataRows
+		}
+	}
+	end := min(start+dataRows, len(m.displayRows))
+
+	var rows []string
+	rows = append(rows, m.renderHeader(colQuery))
+	for i := start; i < end; i++ {
+		dr := m.displayRows[i]
+		isCursor := i == m.cursor
+
+		switch dr.kind {
+		case rowTxSummary:
+			rows = append(rows, m.renderTxSummaryRow(dr, isCursor, colQuery))
+		case rowEvent:
+			rows = append(rows, m.renderEventRow(dr, i, isCursor, colQuery))
+		}
+	}
+
+	borderColor := lipgloss.Color("240")
+	border = border.BorderForeground(borderColor)
+	content := strings.Join(rows, "\n")
+
+	box := border.Render(content)
+	lines := strings.Split(box, "\n")
+	if len(lines) > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		titleStyle := lipgloss.NewStyle().Bold(true)
+		dashes := max(innerWidth-len([]rune(title)), 0)
+		lines[0] = borderFg.Render("╭") +
+			titleStyle.Render(title) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╮")
+		box = strings.Join(lines, "\n")
+	}
+
+	return box
+}
+
+func (m Model) renderTxSummaryRow(dr displayRow, isCursor bool, colQuery int) string {
+	marker := "  "
+	if isCursor {
+		marker = "▶ "
+	}
+
+	chevron := "▾ "
+	if m.collapsed[dr.txID] {
+		chevron = "▸ "
+	}
+
+	nq := m.txQueryCount(dr.events)
+	label := fmt.Sprintf("%d queries", nq)
+	if nq == 1 {
+		label = "1 query"
+	}
+
+	dur := formatDurationValue(m.txWallDuration(dr.events))
+	t := formatTime(m.events[dr.events[0]].GetStartTime())
+
+	styled := lipgloss.NewStyle().Foreground(m.txColorMap[dr.txID])
+	prefix := marker + styled.Render(chevron)
+	if isCursor {
+		prefix = lipgloss.NewStyle().Bold(true).Render(marker) + styled.Bold(true).Render(chevron)
+	}
+
+	var rowsAffected, responseBytes int64
+	for _, idx := range dr.events {
+		rowsAffected += m.events[idx].GetRowsAffected()
+		responseBytes += m.events[idx].GetResponseBytes()
+	}
+
+	return m.renderColumns(prefix, map[columnID]cell{
+		columnOp:       {text: "Tx", style: &styled},
+		columnQuery:    {text: label},
+		columnRows:     {text: fmt.Sprintf("%d", rowsAffected)},
+		columnBytes:    {text: formatBytes(responseBytes)},
+		columnDuration: {text: dur},
+		columnTime:     {text: t},
+	}, colQuery, isCursor)
+}
+
+func (m Model) renderEventRow(dr displayRow, drIdx int, isCursor bool, colQuery int) string {
+	ev := m.events[dr.eventIdx]
+	marker := "  "
+	if _, ok := m.annotations[ev.GetId()]; ok {
+		marker = "✎ "
+	}
+	if isCursor {
+		marker = "▶ "
+	}
+
+	indent := "  " // non-tx: align with chevron space
+	cq := colQuery
+	if m.isTxChild(drIdx) {
+		indent = "    " // tx child: extra indent
+		cq = max(colQuery-2, 1)
+	}
+	switch {
+	case dr.nested:
+		indent += "  " // batch statement: indented under its summary
+		cq = max(cq-2, 1)
+	case dr.batch:
+		chevron := "▾ "
+		if m.collapsed[ev.GetBatchId()] {
+			chevron = "▸ "
+		}
+		indent = indent[:len(indent)-2] + chevron
+	}
+
+	q := ev.GetQuery()
+	if proxy.Op(ev.GetOp()) == proxy.OpBatch {
+		q = batchLabel(ev)
+	}
+	if proxy.Op(ev.GetOp()) == proxy.OpNotice {
+		q = noticeSummary(ev)
+	}
+	if op := proxy.Op(ev.GetOp()); op == proxy.OpConnect || op == proxy.OpDisconnect {
+		q = connSummary(ev)
+	}
+	if strings.TrimSpace(q) == "" {
+		q = "-"
+	}
+	if ev.GetTraffic() != nil {
+		q = trafficSummary(ev.GetTraffic()) + ": " + q
+	}
+	if pn := ev.GetPanic(); pn != nil {
+		if q == "-" {
+			q = pn.GetValue()
+		}
+		q = "panic in " + pn.GetWhere() + ": " + q
+	}
+	if d := ev.GetDiagnostic(); d != nil {
+		q = d.GetMessage() + ": " + ev.GetError()
+	}
+	if p := ev.GetPlan(); p != nil {
+		q = planSummary(p) + ": " + q
+	}
+	if qt := ev.GetQuota(); qt != nil {
+		q = quotaSummary(qt) + ": " + q
+	}
+	if n := ev.GetNPlusOne(); n != nil {
+		q = fmt.Sprintf("N+1 x%d: %s", n.GetCalls(), q)
+	}
+
+	// Statements are highlighted as SQL, unless a summary was prepended.
+	queryCell := cell{text: q}
+	switch proxy.Op(ev.GetOp()) {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpPrepare, proxy.OpExecute:
+		queryCell.sql = q == ev.GetQuery()
+	case proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch,
+		proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+	}
+
+	prefix := marker + indent
+	if isCursor {
+		prefix = lipgloss.NewStyle().Bold(true).Render(prefix)
+	}
+
+	tagsCell := cell{text: strings.Join(ev.GetTags(), ",")}
+	if len(ev.GetTags()) > 0 {
+		if c, ok := m.tagColors[ev.GetTags()[0]]; ok {
+			styled := lipgloss.NewStyle().Foreground(c)
+			tagsCell.style = &styled
+		}
+	}
+
+	opCell := cell{text: opString(ev.GetOp())}
+	switch {
+	case m.isTxChild(drIdx):
+		styled := lipgloss.NewStyle().Foreground(m.txColorMap[ev.GetTxId()])
+		opCell.style = &styled
+	case proxy.Op(ev.GetOp()) == proxy.OpNotice:
+		styled := lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+		opCell.style = &styled
+	case proxy.Op(ev.GetOp()) == proxy.OpConnect, proxy.Op(ev.GetOp()) == proxy.OpDisconnect:
+		styled := lipgloss.NewStyle().Foreground(lipgloss.Color("8"))
+		opCell.style = &styled
+	}
+
+	// Anomalies are relative to the query's own history, so they get their
+	// own highlight rather than a tag color meant for absolute thresholds.
+	durCell := cell{text: formatDuration(ev.GetDuration())}
+	if ev.GetAnomaly() != nil {
+		styled := lipgloss.NewStyle().Foreground(lipgloss.Color("201")).Bold(true)
+		durCell.style = &styled
+	}
+
+	return m.renderColumns(prefix, map[columnID]cell{
+		columnOp:       opCell,
+		columnQuery:    queryCell,
+		columnRows:     {text: fmt.Sprintf("%d", ev.GetRowsAffected())},
+		columnBytes:    {text: formatBytes(ev.GetResponseBytes())},
+		columnDuration: durCell,
+		columnTime:     {text: formatTime(ev.GetStartTime())},
+		columnTags:     tagsCell,
+	}, cq, isCursor)
+}
+
+func (m Model) renderPreview() string {
+	innerWidth := max(m.width-4, 20)
+
+	if m.cursor < 0 || m.cursor >= len(m.displayRows) {
+		return ""
+	}
+
+	dr := m.displayRows[m.cursor]
+
+	switch dr.kind {
+	case rowTxSummary:
+		return m.renderTxPreview(dr, innerWidth)
+	case rowEvent:
+		return m.renderEventPreview(dr, innerWidth)
+	}
+
+	return ""
+}
+
+func (m Model) renderTxPreview(dr displayRow, innerWidth int) string {
+	nq := m.txQueryCount(dr.events)
+	dur := m.txWallDuration(dr.events)
+
+	var lines []string
+	lines = append(lines, "Type:     Transaction")
+
+	label := fmt.Sprintf("%d queries", nq)
+	if nq == 1 {
+		label = "1 query"
+	}
+	lines = append(lines, "Queries:  "+label)
+	lines = append(lines, "Duration: "+formatDurationValue(dur))
+	lines = append(lines, "Tx:       "+dr.txID)
+
+	maxQueryLen := max(innerWidth-14, 20) // 14 = len("  Query   ") + padding
+	for _, idx := range dr.events {
+		ev := m.events[idx]
+		op := proxy.Op(ev.GetOp())
+		switch op {
+		case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpBind, proxy.OpPrepare, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice,
+			proxy.OpConnect, proxy.OpDisconnect:
+		case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+			q := truncate(ev.GetQuery(), maxQueryLen)
+			lines = append(lines, fmt.Sprintf("  %-8s %s", op.String(), highlight.SQL(q)))
+		}
+	}
+
+	content := strings.Join(lines, "\n")
+
+	border := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(lipgloss.Color("240"))
+
+	return border.Render(content)
+}
+
+// previewPlanLines is how much of an auto-explain plan the preview shows;
+// the inspector shows all of it.
+const previewPlanLines = 5
+
+func (m Model) renderEventPreview(dr displayRow, innerWidth int) string {
+	ev := m.events[dr.eventIdx]
+
+	var lines []string
+	lines = append(lines, "Op:       "+opString(ev.GetOp()))
+
+	if q := ev.GetQuery(); q != "" {
+		maxQueryLen := max(innerWidth-10, 20) // 10 = len("Query:    ")
+		lines = append(lines, "Query:    "+highlight.SQL(truncate(q, maxQueryLen)))
+	}
+
+	if len(ev.GetArgs()) > 0 {
+		lines = append(lines, fmt.Sprintf("Args:     [%s]", strings.Join(ev.GetArgs(), ", ")))
+	}
+
+	lines = append(lines, "Duration: "+formatDuration(ev.GetDuration()))
+	lines = append(lines, anomalyLines(ev)...)
+	lines = append(lines, nPlusOneLines(ev)...)
+	lines = append(lines, trafficLines(ev)...)
+	lines = append(lines, quotaLines(ev)...)
+	lines = append(lines, queriesLines(ev)...)
+
+	if wire := formatWire(ev); wire != "" {
+		lines = append(lines, "Bytes:    "+wire)
+	}
+
+	lines = append(lines, errorLines(ev)...)
+	lines = append(lines, panicLines(ev, false)...)
+	lines = append(lines, diagnosticLines(ev)...)
+	lines = append(lines, noticeLines(ev)...)
+	lines = append(lines, m.raisedNoticeLines(ev)...)
+	lines = append(lines, m.planLines(ev, previewPlanLines)...)
+	lines = append(lines, m.noteLines(ev)...)
+
+	if len(ev.GetTags()) > 0 {
+		lines = append(lines, "Tags:     "+m.formatTags(ev.GetTags()))
+	}
+
+	if fields := formatFields(ev); fields != "" {
+		lines = append(lines, "Fields:   "+fields)
+	}
+
+	if ev.GetUpstream() != "" {
+		lines = append(lines, "Upstream: "+ev.GetUpstream())
+	}
+
+	if ev.GetTxId() != "" {
+		lines = append(lines, "Tx:       "+ev.GetTxId())
+	}
+
+	if batch := formatBatch(ev); batch != "" {
+		lines = append(lines, "Batch:    "+batch)
+	}
+
+	if ev.GetCursor() != "" {
+		lines = append(lines, fmt.Sprintf("Cursor:   %s (%d fetches)", ev.GetCursor(), ev.GetFetches()))
+	}
+
+	if ev.GetGlobalTxId() != "" {
+		lines = append(lines, "Global:   "+ev.GetGlobalTxId())
+	}
+
+	if ev.GetTraceId() != "" {
+		lines = append(lines, "Trace:    "+ev.GetTraceId()+" (span "+ev.GetSpanId()+")")
+	}
+
+	if ev.GetRoute() != "" {
+		lines = append(lines, "Route:    "+ev.GetRoute())
+	}
+
+	if caller := ev.GetCaller(); len(caller) > 0 {
+		lines = append(lines, "Caller:   "+caller[0])
+	}
+
+	if ev.GetConnId() != "" {
+		lines = append(lines, "Conn:     "+formatConn(ev.GetConnId(), m.verboseConns[ev.GetConnId()]))
+	}
+
+	if ev.GetBackendPid() != 0 {
+		lines = append(lines, "Backend:  "+fmt.Sprint(ev.GetBackendPid()))
+	}
+
+	if routing := formatRouting(ev); routing != "" {
+		lines = append(lines, "Routing:  "+routing)
+	}
+	if violation := formatViolation(ev); violation != "" {
+		lines = append(lines, "Policy:   "+violation)
+	}
+
+	if client := formatClient(ev); client != "" {
+		lines = append(lines, "Client:   "+client)
+	}
+
+	if tls := formatTLS(ev); tls != "" {
+		lines = append(lines, "TLS:      "+tls)
+	}
+
+	if auth := formatAuth(ev); auth != "" {
+		lines = append(lines, "Auth:     "+auth)
+	}
+
+	if server := formatServer(ev); server != "" {
+		lines = append(lines, "Server:   "+server)
+	}
+
+	content := strings.Join(lines, "\n")
+
+	border := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(lipgloss.Color("240"))
+
+	return border.Render(content)
+}
@@ -0,0 +1,1422 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mickamy/sql-tap/client"
+	"github.com/mickamy/sql-tap/explain"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/clipboard"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/pglog"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/report"
+	"github.com/mickamy/sql-tap/internal/rwsplit"
+	"github.com/mickamy/sql-tap/internal/sample"
+	"github.com/mickamy/sql-tap/internal/stats"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+type viewMode int
+
+const (
+	viewList viewMode = iota
+	viewInspect
+	viewExplain
+	viewAnalytics
+	viewTransactions
+	viewStats
+	viewRoutes
+	viewTimeline
+	viewTop
+)
+
+type sortMode int
+
+const (
+	sortChronological sortMode = iota
+	sortDuration
+	sortRows
+	sortBytes
+)
+
+func (s sortMode) String() string {
+	switch s {
+	case sortChronological:
+		return "time"
+	case sortDuration:
+		return "duration"
+	case sortRows:
+		return "rows"
+	case sortBytes:
+		return "bytes"
+	}
+	return "time"
+}
+
+type rowKind int
+
+const (
+	rowEvent rowKind = iota
+	rowTxSummary
+)
+
+type displayRow struct {
+	kind     rowKind
+	eventIdx int    // rowEvent: index into Model.events
+	txID     string // rowTxSummary: transaction ID
+	events   []int  // rowTxSummary: indices of all events in this tx (order preserved)
+	batch    bool   // rowEvent: a batch summary with its statements grouped under it
+	nested   bool   // rowEvent: a statement grouped under its batch summary
+}
+
+// Model is the Bubble Tea model for the sql-tap TUI.
+type Model struct {
+	target string
+	client tapv1.TapServiceClient
+	conn   *client.Client
+	stream tapv1.TapService_WatchClient
+
+	events      []*tapv1.QueryEvent
+	eventBytes  int64 // approximate memory events take, against maxMemory
+	maxEvents   int   // history caps; 0 is unlimited
+	maxMemory   int64
+	evicted     int // oldest events dropped to stay under the caps
+	cursor      int // index into displayRows
+	follow      bool
+	width       int
+	height      int
+	err         error
+	view        viewMode
+	collapsed   map[string]bool
+	displayRows []displayRow
+	txColorMap  map[string]lipgloss.Color
+
+	searchMode  bool
+	searchQuery string
+	sortMode    sortMode
+
+	findMode  bool   // typing findQuery
+	findQuery string // rows matching it are jumped between, not filtered
+	findFrom  int    // cursor when find started, where matches are looked for from
+
+	annotations map[string]*tapv1.Annotation // shared notes, keyed by event ID
+	noteMode    bool                         // editing the note on noteEventID
+	noteEventID string
+	noteText    string
+
+	killMode     bool // choosing how to stop killPID
+	killPID      uint32
+	killUpstream string
+
+	columns      []column
+	columnMode   bool // editing columns from the list view
+	columnCursor int
+
+	inspectScroll     int
+	explainPlan       string
+	explainTable      bool // explainPlan is a header, a rule, and aligned rows
+	explainAdvisories []string
+	explainIndexes    []string // suggested CREATE INDEX statements
+	explainErr        error
+	explainScroll     int
+	explainHScroll    int
+	explainMode       explain.Mode
+	explainQuery      string
+	explainArgs       []string
+	explainUpstream   string
+
+	analyticsRows     []analyticsRow
+	analyticsCursor   int
+	analyticsHScroll  int
+	analyticsSortMode analyticsSortMode
+	analyticsByTag    bool
+	analyticsField    string // extracted field the analytics view groups by; overrides analyticsByTag
+
+	txs        []*tapv1.Transaction // from the Transactions RPC, newest first
+	txCursor   int
+	txExpanded map[string]bool
+	txLoading  bool
+	txErr      error
+
+	routes        []*tapv1.RouteStats // from the Routes RPC, over-budget first
+	routesBudget  int32
+	routesCursor  int
+	routesLoading bool
+	routesErr     error
+
+	timelineSpan   time.Duration
+	timelineEnd    time.Time // zero follows the newest events
+	timelineCursor int
+
+	statsAgg     *stats.Aggregator // rolling latency, QPS, and error rate of received events
+	statsOverall stats.Summary     // snapshot shown by the stats view, refreshed every statsRefresh
+	statsKeys    []stats.KeySummary
+	statsCursor  int
+	statsGen     int              // bumped on entering and leaving the stats view to retire old ticks
+	statsByConn  bool             // the table lists each connection's read/write split instead of fingerprints
+	rwSplit      *rwsplit.Tracker // rolling read/write split of received events
+	rwOverall    rwsplit.Split    // snapshots shown by the stats view, refreshed with statsOverall
+	rwConns      []rwsplit.ConnSplit
+
+	serverStmts        map[string]serverStmt // pg_stat_statements totals per fingerprint, summed across upstreams
+	serverStmtsAt      time.Time             // when the daemon last polled them; zero when not available
+	serverStmtsErr     string                // an upstream's polling error, if any
+	serverStmtsFetched time.Time             // when the stats view last asked for them
+
+	topStats         map[string]*topStat // cumulative totals per fingerprint of received events
+	topRows          []topStat           // snapshot shown by the top view, refreshed every topRefresh
+	topTotal         time.Duration       // total time across topRows, for the %Time column
+	topCursor        int
+	topSortMode      topSortMode
+	topGen           int    // bumped on entering and leaving the top view to retire old ticks
+	topDrill         string // fingerprint whose recent events are shown; empty shows the table
+	topExampleCursor int    // into the drilled fingerprint's events, newest first
+	inspectReturnTop bool   // the inspector was opened from the top view and returns to it
+
+	tlsCertNotAfter time.Time                 // zero when the server does not terminate TLS
+	tlsCertWarn     time.Duration             // how far ahead of tlsCertNotAfter to warn, from the Info RPC
+	tagColors       map[string]lipgloss.Color // configured tag colors, from the Info RPC
+
+	serverLog  *pglog.Correlator          // nil unless -pg-log is set
+	serverLogs map[string]serverLogResult // matched server log entries, keyed by event ID
+
+	verboseConns map[string]bool      // connections with detailed capture enabled
+	status       string               // transient message shown in the list footer
+	dropped      uint64               // events the daemon dropped for this TUI, from the Stats RPC
+	watchers     []string             // identities of everyone watching the daemon, this TUI included
+	sampledOut   uint64               // events the daemon's own sampling discarded, from the Stats RPC
+	cancels      *tapv1.Cancellations // upstream statements cancelled, by cause, from the Stats RPC
+	panics       uint64               // panics the daemon recovered from, from the Stats RPC
+	diagnostics  uint64               // connection errors the proxies logged, from the Stats RPC
+	presence     bool                 // watchers comes from the Watch stream, not Stats
+
+	reconnect reconnectState // set while the Watch stream is down
+	lastEnd   time.Time      // end of the newest event received, where a new stream resumes
+
+	delivery  tapv1.Delivery
+	token     string        // bearer token for daemons with auth enabled
+	sampling  sample.Config // sampling rules sent with Watch
+	statePath string        // session state file; empty disables persistence
+	restore   *sessionState // saved cursors to re-apply until the first key press
+}
+
+// eventMsg carries a received QueryEvent from the gRPC stream.
+type eventMsg struct{ Event *tapv1.QueryEvent }
+
+// errMsg carries an error from the gRPC connection or stream.
+type errMsg struct{ Err error }
+
+// verboseResultMsg carries the result of a SetVerbose call.
+type verboseResultMsg struct {
+	connIDs []string
+	err     error
+}
+
+// statsMsg carries the count of events dropped for this TUI and the active
+// watchers from a Stats call.
+type statsMsg struct {
+	client      tapv1.TapServiceClient // the connection polled, to retire polls of a closed one
+	dropped     uint64
+	sampledOut  uint64
+	cancels     *tapv1.Cancellations
+	panics      uint64
+	diagnostics uint64
+	watchers    []string
+	err         error
+}
+
+type explainResultMsg struct {
+	mode       explain.Mode
+	query      string
+	plan       string
+	table      bool     // plan is a table rendered by explain.FormatTable
+	advisories []string // advisory tags the plan warrants
+	indexes    []string // suggested CREATE INDEX statements
+	err        error
+}
+
+// connectedMsg is sent after successfully establishing the gRPC Watch stream.
+type connectedMsg struct {
+	client          tapv1.TapServiceClient
+	conn            *client.Client
+	stream          tapv1.TapService_WatchClient
+	tlsCertNotAfter time.Time
+	tlsCertWarn     time.Duration
+	tagDefs         []*tapv1.TagDef
+}
+
+// Option configures a Model.
+type Option func(*Model)
+
+// WithLossless asks the daemon to stall publishing instead of dropping events
+// when this TUI falls behind.
+func WithLossless() Option {
+	return func(m *Model) {
+		m.delivery = tapv1.Delivery_DELIVERY_BLOCK
+	}
+}
+
+// WithSpill asks the daemon to queue events on its disk instead of dropping
+// them when this TUI falls behind.
+func WithSpill() Option {
+	return func(m *Model) {
+		m.delivery = tapv1.Delivery_DELIVERY_SPILL
+	}
+}
+
+// WithToken authenticates to the daemon with a bearer token.
+func WithToken(token string) Option {
+	return func(m *Model) {
+		m.token = token
+	}
+}
+
+// WithSampling asks the daemon to thin out this TUI's events.
+func WithSampling(c sample.Config) Option {
+	return func(m *Model) {
+		m.sampling = c
+	}
+}
+
+// New creates a new Model targeting the given tapd server address.
+func New(target string, opts ...Option) Model {
+	m := Model{
+		target:       target,
+		follow:       true,
+		collapsed:    make(map[string]bool),
+		verboseConns: make(map[string]bool),
+		txExpanded:   make(map[string]bool),
+		columns:      defaultColumns(),
+		statsAgg:     stats.New(stats.DefaultResolution, stats.DefaultBuckets),
+		rwSplit:      rwsplit.New(stats.DefaultResolution, stats.DefaultBuckets),
+		topStats:     make(map[string]*topStat),
+		maxMemory:    DefaultMaxMemory,
+	}
+	for _, opt := range opts {
+		opt(&m)
+	}
+	return m
+}
+
+// Init starts the gRPC connection.
+func (m Model) Init() tea.Cmd {
+	return connect(m.target, m.delivery, m.sampling, m.token, nil)
+}
+
+func connect(target string, delivery tapv1.Delivery, sampling sample.Config, token string, resumeAfter *timestamppb.Timestamp) tea.Cmd {
+	return func() tea.Msg {
+		c, err := client.Dial(target, client.WithToken(token))
+		if err != nil {
+			return errMsg{Err: err}
+		}
+		stream, err := c.Watch(context.Background(), &tapv1.WatchRequest{
+			Delivery:    delivery,
+			Client:      auth.Identity(),
+			Collaborate: true,
+			Sampling:    sampling.Proto(),
+			ResumeAfter: resumeAfter,
+		})
+		if err != nil {
+			_ = c.Close()
+			return errMsg{Err: fmt.Errorf("watch %s: %w", target, err)}
+		}
+		msg := connectedMsg{client: c, conn: c, stream: stream}
+		// Info is best-effort: older servers do not implement it.
+		if info, err := c.Info(context.Background(), &tapv1.InfoRequest{}); err == nil {
+			if info.GetTlsCertNotAfter() != nil {
+				msg.tlsCertNotAfter = info.GetTlsCertNotAfter().AsTime()
+				msg.tlsCertWarn = info.GetTlsCertExpiryWarning().AsDuration()
+			}
+			msg.tagDefs = info.GetTags()
+		}
+		return msg
+	}
+}
+
+func recvEvent(stream tapv1.TapService_WatchClient) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := stream.Recv()
+		if err != nil {
+			return disconnectedMsg{err: err}
+		}
+		switch {
+		case resp.GetAnnotation() != nil:
+			return annotationMsg{annotation: resp.GetAnnotation()}
+		case resp.GetPresence() != nil:
+			return presenceMsg{clients: resp.GetPresence().GetClients()}
+		}
+		return eventMsg{Event: resp.GetEvent()}
+	}
+}
+
+// statsInterval is how often the TUI polls the daemon for dropped-event counts.
+const statsInterval = 2 * time.Second
+
+func pollStats(client tapv1.TapServiceClient) tea.Cmd {
+	return tea.Tick(statsInterval, func(time.Time) tea.Msg {
+		resp, err := client.Stats(context.Background(), &tapv1.StatsRequest{})
+		if err != nil {
+			return statsMsg{client: client, err: err}
+		}
+		// Events the proxies dropped are lost to every watcher; of the
+		// subscribers' drops, only this TUI's own stream counts.
+		dropped := resp.GetProxyDropped()
+		var watchers []string
+		for _, sub := range resp.GetSubscribers() {
+			if sub.GetOwn() {
+				dropped += sub.GetDropped()
+			}
+			if c := sub.GetClient(); c != "" {
+				watchers = append(watchers, c)
+			}
+		}
+		return statsMsg{
+			client:      client,
+			dropped:     dropped,
+			sampledOut:  resp.GetSampledOut(),
+			cancels:     resp.GetCancellations(),
+			panics:      resp.GetPanics(),
+			diagnostics: resp.GetDiagnostics(),
+			watchers:    watchers,
+		}
+	})
+}
+
+// maxWatcherNames is how many watcher identities the footer lists before
+// falling back to a count.
+const maxWatcherNames = 3
+
+// watchersLabel summarizes who else is watching, or returns "" when this TUI
+// is the only watcher.
+func watchersLabel(watchers []string) string {
+	if len(watchers) < 2 {
+		return ""
+	}
+	if len(watchers) > maxWatcherNames {
+		return fmt.Sprintf("watchers: %d", len(watchers))
+	}
+	return "watchers: " + strings.Join(watchers, ", ")
+}
+
+// cancelsLabel lists the nonzero cancellation counts, or returns "" when
+// there are none.
+func cancelsLabel(c *tapv1.Cancellations) string {
+	var parts []string
+	for _, n := range []struct {
+		count uint64
+		cause string
+	}{
+		{c.GetRelayed(), "by client"},
+		{c.GetKilled(), "killed"},
+		{c.GetTimedOut(), "timed out"},
+		{c.GetDisconnected(), "client gone"},
+	} {
+		if n.count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", n.count, n.cause))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "cancelled: " + strings.Join(parts, ", ")
+}
+
+// Update handles incoming messages.
+func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case connectedMsg:
+		if m.reconnect.attempt > 0 {
+			m.reconnect = reconnectState{}
+			m.status = "reconnected"
+		}
+		m.client = msg.client
+		m.conn = msg.conn
+		m.stream = msg.stream
+		m.tlsCertNotAfter = msg.tlsCertNotAfter
+		m.tlsCertWarn = msg.tlsCertWarn
+		m.tagColors = make(map[string]lipgloss.Color, len(msg.tagDefs))
+		for _, d := range msg.tagDefs {
+			if d.GetColor() != "" {
+				m.tagColors[d.GetName()] = lipgloss.Color(d.GetColor())
+			}
+		}
+		return m, tea.Batch(recvEvent(msg.stream), pollStats(msg.client))
+
+	case statsMsg:
+		if msg.client != m.client || m.reconnect.attempt > 0 {
+			return m, nil // the connection polled is gone; its replacement polls anew
+		}
+		if msg.err != nil {
+			return m, nil // older servers do not implement Stats; stop polling
+		}
+		m.dropped = msg.dropped
+		m.sampledOut = msg.sampledOut
+		m.cancels = msg.cancels
+		m.panics = msg.panics
+		m.diagnostics = msg.diagnostics
+		if !m.presence {
+			m.watchers = msg.watchers
+		}
+		return m, pollStats(m.client)
+
+	case annotationMsg:
+		return m.applyAnnotation(msg.annotation), recvEvent(m.stream)
+
+	case presenceMsg:
+		m.watchers = msg.clients
+		m.presence = true
+		return m, recvEvent(m.stream)
+
+	case annotateResultMsg:
+		if msg.err != nil {
+			m.status = "note: " + msg.err.Error()
+		}
+		return m, nil
+
+	case eventMsg:
+		m.events = append(m.events, msg.Event)
+		m.eventBytes += eventSize(msg.Event)
+		m.noteEnd(msg.Event)
+		m.observeStats(msg.Event)
+		m.observeTop(len(m.events) - 1)
+		m = m.retain()
+		if m.restore != nil {
+			m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+			if m.follow {
+				m.cursor = max(len(m.displayRows)-1, 0)
+			}
+			return m.restoreCursors(), recvEvent(m.stream)
+		}
+		if m.view != viewList {
+			return m, recvEvent(m.stream)
+		}
+		if m.follow {
+			m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+			m.cursor = max(len(m.displayRows)-1, 0)
+			return m, recvEvent(m.stream)
+		}
+		// Rows may reorder under a sort; keep the cursor on the same row.
+		key, ok := m.cursorRowKey()
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		if ok {
+			m.cursor = m.findRow(key)
+		}
+		return m, recvEvent(m.stream)
+
+	case statsTickMsg:
+		if msg.gen != m.statsGen || m.view != viewStats {
+			return m, nil
+		}
+		m = m.refreshStats()
+		if m.client != nil && time.Since(m.serverStmtsFetched) >= statementsRefresh {
+			m.serverStmtsFetched = time.Now()
+			return m, tea.Batch(statsTick(m.statsGen), fetchStatements(m.client))
+		}
+		return m, statsTick(m.statsGen)
+
+	case topTickMsg:
+		if msg.gen != m.topGen || m.view != viewTop {
+			return m, nil
+		}
+		return m.refreshTop(), topTick(m.topGen)
+
+	case errMsg:
+		if m.reconnect.attempt > 0 {
+			return m.disconnected(msg.Err)
+		}
+		m.err = msg.Err
+		return m, nil
+
+	case disconnectedMsg:
+		return m.disconnected(msg.err)
+
+	case reconnectMsg:
+		return m, connect(m.target, m.delivery, m.sampling, m.token, m.resumeAfter())
+
+	case routesResultMsg:
+		return m.applyRoutes(msg), nil
+
+	case statementsResultMsg:
+		return m.applyStatements(msg), nil
+
+	case serverLogMsg:
+		m.serverLogs[msg.eventID] = serverLogResult{entries: msg.entries, err: msg.err}
+		return m, nil
+
+	case killResultMsg:
+		return m.applyKillResult(msg), nil
+
+	case verboseResultMsg:
+		if msg.err != nil {
+			m.status = "verbose: " + msg.err.Error()
+			return m, nil
+		}
+		m.verboseConns = make(map[string]bool, len(msg.connIDs))
+		for _, id := range msg.connIDs {
+			m.verboseConns[id] = true
+		}
+		m.status = fmt.Sprintf("detailed capture on %d connection(s)", len(msg.connIDs))
+		return m, nil
+
+	case txResultMsg:
+		m.txLoading = false
+		m.txErr = msg.err
+		if msg.err == nil {
+			m.txs = msg.txs
+			m.txCursor = min(m.txCursor, max(len(m.txs)-1, 0))
+		}
+		return m, nil
+
+	case explainResultMsg:
+		if msg.mode != m.explainMode {
+			return m, nil // superseded by a mode toggle
+		}
+		m.explainPlan = msg.plan
+		m.explainTable = msg.table
+		m.explainAdvisories = msg.advisories
+		m.explainIndexes = msg.indexes
+		m.explainErr = msg.err
+		m.tagAdvisories(msg.query, msg.advisories)
+		return m, nil
+
+	case editorResultMsg:
+		if msg.err != nil {
+			m.view = viewExplain
+			m.explainPlan = ""
+			m.explainAdvisories = nil
+			m.explainIndexes = nil
+			m.explainErr = msg.err
+			m.explainScroll = 0
+			m.explainHScroll = 0
+			m.explainMode = msg.mode
+			return m, nil
+		}
+		if msg.query == "" {
+			return m, nil // cancelled
+		}
+		m.view = viewExplain
+		m.explainPlan = ""
+		m.explainAdvisories = nil
+		m.explainIndexes = nil
+		m.explainErr = nil
+		m.explainScroll = 0
+		m.explainHScroll = 0
+		m.explainMode = msg.mode
+		m.explainQuery = msg.query
+		m.explainArgs = msg.args
+		return m, runExplain(m.client, msg.mode, m.explainUpstream, msg.query, msg.args)
+
+	case tea.KeyMsg:
+		m.restore = nil
+		switch m.view {
+		case viewInspect:
+			return m.updateInspect(msg)
+		case viewExplain:
+			return m.updateExplain(msg)
+		case viewAnalytics:
+			return m.updateAnalytics(msg)
+		case viewTransactions:
+			return m.updateTransactions(msg)
+		case viewStats:
+			return m.updateStats(msg)
+		case viewRoutes:
+			return m.updateRoutes(msg)
+		case viewTimeline:
+			return m.updateTimeline(msg)
+		case viewTop:
+			return m.updateTop(msg)
+		case viewList:
+			return m.updateList(msg)
+		}
+
+	case tea.WindowSizeMsg:
+		m.width = msg.Width
+		m.height = msg.Height
+		return m, nil
+	}
+	return m, nil
+}
+
+// View renders the TUI, with a banner below while reconnecting.
+func (m Model) View() string {
+	v := m.render()
+	if banner := m.reconnectBanner(); banner != "" && v != "" {
+		v += "\n" + banner
+	}
+	return v
+}
+
+func (m Model) render() string {
+	if m.width == 0 {
+		return ""
+	}
+
+	if m.err != nil {
+		return friendlyError(m.err, m.width)
+	}
+
+	if len(m.events) == 0 {
+		return "Waiting for queries..."
+	}
+
+	switch m.view {
+	case viewInspect:
+		return m.renderInspector()
+	case viewExplain:
+		return m.renderExplain()
+	case viewAnalytics:
+		return m.renderAnalytics()
+	case viewTransactions:
+		return m.renderTransactions()
+	case viewStats:
+		return m.renderStats()
+	case viewRoutes:
+		return m.renderRoutes()
+	case viewTimeline:
+		return m.renderTimeline()
+	case viewTop:
+		return m.renderTop()
+	case viewList:
+	}
+
+	listHeight := m.listHeight()
+
+	var footer string
+	switch {
+	case m.searchMode:
+		footer = fmt.Sprintf("  / %s█", m.searchQuery)
+	case m.findMode:
+		footer = fmt.Sprintf("  ? %s█  %s", m.findQuery, m.findLabel())
+	case m.noteMode:
+		footer = fmt.Sprintf("  note: %s█  (enter: share, empty clears  esc: cancel)", m.noteText)
+	case m.killMode:
+		footer = "  " + m.killPrompt()
+	case m.columnMode:
+		footer = m.columnFooter()
+	default:
+		footer = "  q: quit  j/k: navigate  space: toggle tx  enter: inspect  a: analytics  t: transactions  p: stats  T: top  r: routes" +
+			"  c/C: copy/with args  x/X: explain/analyze  e/E: edit+explain" +
+			"  n: note  /: filter  ?: find  s: sort  o: columns  v: verbose conn  K: kill backend  w/W: export json/csv  m/M: report md/html"
+		if m.searchQuery != "" {
+			footer += "  esc: clear filter"
+		}
+		if m.findQuery != "" {
+			footer += "  ]/[: next/prev match  [" + m.findLabel() + "]"
+		}
+		if m.sortMode != sortChronological {
+			footer += "  [sorted: " + m.sortMode.String() + "]"
+		}
+		if m.dropped > 0 {
+			footer += fmt.Sprintf("  [dropped: %d]", m.dropped)
+		}
+		if m.sampling.Enabled() {
+			footer += "  [sampled: " + m.sampling.String() + "]"
+		}
+		if m.sampledOut > 0 {
+			footer += fmt.Sprintf("  [sampled out by daemon: %d]", m.sampledOut)
+		}
+		if c := cancelsLabel(m.cancels); c != "" {
+			footer += "  [" + c + "]"
+		}
+		if m.panics > 0 {
+			footer += fmt.Sprintf("  [panics recovered: %d]", m.panics)
+		}
+		if m.diagnostics > 0 {
+			footer += fmt.Sprintf("  [connection errors: %d]", m.diagnostics)
+		}
+		if w := watchersLabel(m.watchers); w != "" {
+			footer += "  [" + w + "]"
+		}
+		if m.status != "" {
+			footer += "  [" + m.status + "]"
+		}
+	}
+
+	return strings.Join([]string{
+		m.renderList(listHeight),
+		m.renderPreview(),
+		footer,
+	}, "\n")
+}
+
+func (m Model) listHeight() int {
+	if m.reconnect.attempt > 0 {
+		return max(m.height-13, 3) // room for the banner
+	}
+	return max(m.height-12, 3)
+}
+
+func (m Model) rebuildDisplayRows() ([]displayRow, map[string]lipgloss.Color) {
+	matchedEvents := matchingEvents(m.events, m.searchQuery)
+
+	// When filtering or sorting, show flat list (no tx grouping).
+	if m.searchQuery != "" || m.sortMode != sortChronological {
+		var rows []displayRow
+		colorMap := make(map[string]lipgloss.Color)
+		txCount := 0
+		for i, ev := range m.events {
+			if !matchedEvents[i] {
+				continue
+			}
+			if txID := ev.GetTxId(); txID != "" {
+				if _, ok := colorMap[txID]; !ok {
+					colorMap[txID] = txColors[txCount%len(txColors)]
+					txCount++
+				}
+			}
+			rows = append(rows, displayRow{
+				kind:     rowEvent,
+				eventIdx: i,
+			})
+		}
+		switch m.sortMode {
+		case sortDuration:
+			sort.SliceStable(rows, func(a, b int) bool {
+				da := m.events[rows[a].eventIdx].GetDuration().AsDuration()
+				db := m.events[rows[b].eventIdx].GetDuration().AsDuration()
+				return da > db // slowest first
+			})
+		case sortRows:
+			sort.SliceStable(rows, func(a, b int) bool {
+				ra := m.events[rows[a].eventIdx].GetRowsAffected()
+				rb := m.events[rows[b].eventIdx].GetRowsAffected()
+				return ra > rb // most rows first
+			})
+		case sortBytes:
+			sort.SliceStable(rows, func(a, b int) bool {
+				ba := m.events[rows[a].eventIdx].GetResponseBytes()
+				bb := m.events[rows[b].eventIdx].GetResponseBytes()
+				return ba > bb // largest responses first
+			})
+		case sortChronological:
+		}
+		return rows, colorMap
+	}
+
+	var rows []displayRow
+	seenTx := make(map[string]bool)
+	colorMap := make(map[string]lipgloss.Color)
+	txCount := 0
+	batches := m.batchGroups()
+
+	for i := range m.events {
+		ev := m.events[i]
+		txID := ev.GetTxId()
+
+		switch {
+		case txID != "" && proxy.Op(ev.GetOp()) == proxy.OpBegin && !seenTx[txID]:
+			seenTx[txID] = true
+			colorMap[txID] = txColors[txCount%len(txColors)]
+			txCount++
+			// Collect all events with this txID.
+			var indices []int
+			for j := range m.events {
+				if m.events[j].GetTxId() == txID {
+					indices = append(indices, j)
+				}
+			}
+			rows = append(rows, displayRow{
+				kind:   rowTxSummary,
+				txID:   txID,
+				events: indices,
+			})
+			if !m.collapsed[txID] {
+				for _, j := range indices {
+					rows = m.appendEventRow(rows, j, batches)
+				}
+			}
+		case txID != "" && seenTx[txID]:
+			// Already handled by summary — skip.
+		default:
+			// Non-tx event.
+			rows = m.appendEventRow(rows, i, batches)
+		}
+	}
+
+	return rows, colorMap
+}
+
+// batchGroup is a pipelined batch whose summary event has arrived.
+type batchGroup struct {
+	summary int   // index of the OpBatch event
+	members []int // indices of its statements, in arrival order
+}
+
+// batchGroups returns the batches to group in the list, by batch ID.
+func (m Model) batchGroups() map[string]*batchGroup {
+	groups := make(map[string]*batchGroup)
+	for i, ev := range m.events {
+		id := ev.GetBatchId()
+		if id == "" {
+			continue
+		}
+		g, ok := groups[id]
+		if !ok {
+			g = &batchGroup{summary: -1}
+			groups[id] = g
+		}
+		if proxy.Op(ev.GetOp()) == proxy.OpBatch {
+			g.summary = i
+		} else {
+			g.members = append(g.members, i)
+		}
+	}
+	maps.DeleteFunc(groups, func(_ string, g *batchGroup) bool { return g.summary < 0 })
+	return groups
+}
+
+// appendEventRow appends the row for event i. A batch is listed where its
+// first statement arrived: its summary, then, unless collapsed, its
+// statements; the rest of its events add nothing.
+func (m Model) appendEventRow(rows []displayRow, i int, batches map[string]*batchGroup) []displayRow {
+	id := m.events[i].GetBatchId()
+	g, ok := batches[id]
+	if !ok {
+		return append(rows, displayRow{kind: rowEvent, eventIdx: i})
+	}
+	first := g.summary
+	if len(g.members) > 0 {
+		first = g.members[0]
+	}
+	if i != first {
+		return rows
+	}
+	rows = append(rows, displayRow{kind: rowEvent, eventIdx: g.summary, batch: true})
+	if !m.collapsed[id] {
+		for _, j := range g.members {
+			rows = append(rows, displayRow{kind: rowEvent, eventIdx: j, nested: true})
+		}
+	}
+	return rows
+}
+
+// matchingEvents returns a set of event indices whose query, fingerprint, tx ID, or error
+// contains the filter's text (case-insensitive). "tag:<name>" terms in the filter require the event to carry that tag instead,
+// "field:<name>=<value>" terms require that extracted field value, and "db:<name>"
+// and "user:<name>" terms require the connection's database or user to be one of them.
+// If filter is empty, all events match.
+func matchingEvents(events []*tapv1.QueryEvent, filter string) map[int]bool {
+	matched := make(map[int]bool, len(events))
+	if filter == "" {
+		for i := range events {
+			matched[i] = true
+		}
+		return matched
+	}
+
+	f := parseFilter(filter)
+	lower := strings.ToLower(f.text)
+	for i, ev := range events {
+		if !containsText(ev, lower) {
+			continue
+		}
+		if slices.ContainsFunc(f.tags, func(t string) bool { return !slices.Contains(ev.GetTags(), t) }) {
+			continue
+		}
+		if !hasFields(ev, f.fields) {
+			continue
+		}
+		if len(f.databases) > 0 && !slices.Contains(f.databases, ev.GetDatabase()) {
+			continue
+		}
+		if len(f.users) > 0 && !slices.Contains(f.users, ev.GetUser()) {
+			continue
+		}
+		matched[i] = true
+	}
+	return matched
+}
+
+// containsText reports whether ev's query, fingerprint, tx ID, or error
+// contains lower, which is lower case.
+func containsText(ev *tapv1.QueryEvent, lower string) bool {
+	if lower == "" {
+		return true
+	}
+	for _, s := range []string{ev.GetQuery(), ev.GetFingerprint(), ev.GetTxId(), ev.GetError()} {
+		if strings.Contains(strings.ToLower(s), lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasFields reports whether ev carries every name=value pair in fields.
+func hasFields(ev *tapv1.QueryEvent, fields map[string]string) bool {
+	for name, want := range fields {
+		if v, ok := ev.GetFields()[name]; !ok || v != want {
+			return false
+		}
+	}
+	return true
+}
+
+// filterTerms is a search filter split into its parts.
+type filterTerms struct {
+	text      string
+	tags      []string
+	fields    map[string]string
+	databases []string // any of
+	users     []string // any of
+}
+
+// filterPrefixes are the term prefixes parseFilter recognizes.
+var filterPrefixes = []string{"tag:", "field:", "db:", "user:"}
+
+// parseFilter splits a search filter into its free text, "tag:" terms,
+// "field:name=value" terms, and "db:" and "user:" terms.
+func parseFilter(filter string) filterTerms {
+	if !slices.ContainsFunc(filterPrefixes, func(p string) bool { return strings.Contains(filter, p) }) {
+		return filterTerms{text: filter}
+	}
+	var f filterTerms
+	var words []string
+	for _, w := range strings.Fields(filter) {
+		if t, ok := strings.CutPrefix(w, "tag:"); ok && t != "" {
+			f.tags = append(f.tags, t)
+			continue
+		}
+		if d, ok := strings.CutPrefix(w, "db:"); ok && d != "" {
+			f.databases = append(f.databases, d)
+			continue
+		}
+		if u, ok := strings.CutPrefix(w, "user:"); ok && u != "" {
+			f.users = append(f.users, u)
+			continue
+		}
+		if fv, ok := strings.CutPrefix(w, "field:"); ok {
+			if name, value, ok := strings.Cut(fv, "="); ok && name != "" {
+				if f.fields == nil {
+					f.fields = make(map[string]string)
+				}
+				f.fields[name] = value
+				continue
+			}
+		}
+		words = append(words, w)
+	}
+	f.text = strings.Join(words, " ")
+	return f
+}
+
+// txQueryCount returns the number of non-lifecycle events in a tx.
+// Lifecycle ops (Begin, Commit, Rollback, Bind, Prepare) are skipped.
+func (m Model) txQueryCount(indices []int) int {
+	n := 0
+	for _, idx := range indices {
+		switch proxy.Op(m.events[idx].GetOp()) {
+		case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpBind, proxy.OpPrepare, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice,
+			proxy.OpConnect, proxy.OpDisconnect:
+		case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+			n++
+		}
+	}
+	return n
+}
+
+// txWallDuration returns the wall-clock duration from the first event's StartTime
+// to the last event's StartTime + Duration.
+func (m Model) txWallDuration(indices []int) time.Duration {
+	if len(indices) == 0 {
+		return 0
+	}
+	first := m.events[indices[0]]
+	last := m.events[indices[len(indices)-1]]
+
+	start := first.GetStartTime().AsTime()
+	end := last.GetStartTime().AsTime().Add(last.GetDuration().AsDuration())
+	return end.Sub(start)
+}
+
+// cursorTxID returns the tx ID for the current cursor row, or "" if not tx-related.
+func (m Model) cursorTxID() string {
+	if m.cursor < 0 || m.cursor >= len(m.displayRows) {
+		return ""
+	}
+	dr := m.displayRows[m.cursor]
+	switch dr.kind {
+	case rowTxSummary:
+		return dr.txID
+	case rowEvent:
+		return m.events[dr.eventIdx].GetTxId()
+	}
+	return ""
+}
+
+// cursorBatchID returns the batch ID for the current cursor row when it is
+// a grouped batch summary or one of its statements, or "".
+func (m Model) cursorBatchID() string {
+	if m.cursor < 0 || m.cursor >= len(m.displayRows) {
+		return ""
+	}
+	dr := m.displayRows[m.cursor]
+	if dr.kind != rowEvent || !dr.batch && !dr.nested {
+		return ""
+	}
+	return m.events[dr.eventIdx].GetBatchId()
+}
+
+// isTxChild returns true if the display row at index i is an event that belongs
+// to a tx summary (i.e. the preceding summary row exists).
+func (m Model) isTxChild(drIdx int) bool {
+	if drIdx < 0 || drIdx >= len(m.displayRows) {
+		return false
+	}
+	dr := m.displayRows[drIdx]
+	if dr.kind != rowEvent {
+		return false
+	}
+	ev := m.events[dr.eventIdx]
+	return ev.GetTxId() != ""
+}
+
+// cursorEvent returns the QueryEvent at the cursor, or nil for tx summary rows.
+func (m Model) cursorEvent() *tapv1.QueryEvent {
+	if m.cursor < 0 || m.cursor >= len(m.displayRows) {
+		return nil
+	}
+	dr := m.displayRows[m.cursor]
+	if dr.kind != rowEvent {
+		return nil
+	}
+	return m.events[dr.eventIdx]
+}
+
+func (m Model) updateList(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.searchMode {
+		return m.updateSearch(msg)
+	}
+	if m.findMode {
+		return m.updateFind(msg)
+	}
+	if m.noteMode {
+		return m.updateNote(msg)
+	}
+	if m.killMode {
+		return m.updateKill(msg)
+	}
+	if m.columnMode {
+		if msg.String() == "ctrl+c" {
+			return m.quit()
+		}
+		return m.updateColumns(msg.String()), nil
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m.quit()
+	case "enter":
+		if len(m.displayRows) > 0 {
+			m.view = viewInspect
+			m.inspectScroll = 0
+			m.inspectReturnTop = false
+		}
+		return m, m.lookupServerLog()
+	case "x", "X":
+		return m.startExplain(explainModeFromKey(msg.String()))
+	case "e", "E":
+		return m.startEditExplain(explainModeFromKey(msg.String()))
+	case "c", "C":
+		return m.copyQuery(msg.String() == "C"), nil
+	case "n":
+		return m.startNote(), nil
+	case "K":
+		return m.startKill(), nil
+	case "/":
+		m.searchMode = true
+		m.searchQuery = ""
+		return m, nil
+	case "?":
+		return m.startFind(), nil
+	case "]":
+		return m.jumpMatch(1), nil
+	case "[":
+		return m.jumpMatch(-1), nil
+	case "s":
+		return m.toggleSort(), nil
+	case "a":
+		return m.enterAnalytics(), nil
+	case "t":
+		return m.enterTransactions()
+	case "p":
+		return m.enterStats()
+	case "r":
+		return m.enterRoutes()
+	case "T":
+		return m.enterTop()
+	case "g":
+		return m.enterTimeline(), nil
+	case "v":
+		return m.toggleVerbose()
+	case "o":
+		m.columnMode = true
+		m.columnCursor = min(m.columnCursor, len(m.columns)-1)
+		return m, nil
+	case "w":
+		return m.exportEvents(export.NDJSON), nil
+	case "W":
+		return m.exportEvents(export.CSV), nil
+	case "m":
+		return m.snapshotReport(report.Markdown), nil
+	case "M":
+		return m.snapshotReport(report.HTML), nil
+	case "esc":
+		return m.clearFilter(), nil
+	case " ":
+		if id := m.cursorBatchID(); id != "" {
+			m.collapsed[id] = !m.collapsed[id]
+			m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+			for i, r := range m.displayRows {
+				if r.batch && m.events[r.eventIdx].GetBatchId() == id {
+					m.cursor = i
+					break
+				}
+			}
+			return m, nil
+		}
+		if txID := m.cursorTxID(); txID != "" {
+			m.collapsed[txID] = !m.collapsed[txID]
+			m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+			for i, r := range m.displayRows {
+				if r.kind == rowTxSummary && r.txID == txID {
+					m.cursor = i
+					break
+				}
+			}
+		}
+		return m, nil
+	case "j", "down":
+		if len(m.displayRows) > 0 && m.cursor < len(m.displayRows)-1 {
+			m.cursor++
+		}
+		if len(m.displayRows) > 0 && m.cursor == len(m.displayRows)-1 {
+			m.follow = true
+		}
+		return m, nil
+	case "k", "up":
+		if m.cursor > 0 {
+			m.cursor--
+			m.follow = false
+		}
+		return m, nil
+	case "ctrl+d", "pgdown":
+		half := max(m.listHeight()/2, 1)
+		m.cursor = min(m.cursor+half, max(len(m.displayRows)-1, 0))
+		if len(m.displayRows) > 0 && m.cursor == len(m.displayRows)-1 {
+			m.follow = true
+		}
+		return m, nil
+	case "ctrl+u", "pgup":
+		half := max(m.listHeight()/2, 1)
+		m.cursor = max(m.cursor-half, 0)
+		m.follow = false
+		return m, nil
+	}
+	return m, nil
+}
+
+func (m Model) updateSearch(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "enter":
+		m.searchMode = false
+		return m, nil
+	case "esc":
+		m.searchMode = false
+		m.searchQuery = ""
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		m.cursor = min(m.cursor, max(len(m.displayRows)-1, 0))
+		return m, nil
+	case "backspace":
+		if len(m.searchQuery) > 0 {
+			_, size := utf8.DecodeLastRuneInString(m.searchQuery)
+			m.searchQuery = m.searchQuery[:len(m.searchQuery)-size]
+			m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+			m.cursor = min(m.cursor, max(len(m.displayRows)-1, 0))
+		}
+		return m, nil
+	case "ctrl+c":
+		return m.quit()
+	case "up", "down":
+		return m.navigateCursor(msg.String()), nil
+	}
+
+	// Ignore non-printable keys.
+	r := msg.Runes
+	if len(r) == 0 {
+		return m, nil
+	}
+
+	m.searchQuery += string(r)
+	m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+	m.cursor = min(m.cursor, max(len(m.displayRows)-1, 0))
+	return m, nil
+}
+
+func (m Model) navigateCursor(key string) Model {
+	switch key {
+	case "up":
+		if m.cursor > 0 {
+			m.cursor--
+			m.follow = false
+		}
+	case "down":
+		if len(m.displayRows) > 0 && m.cursor < len(m.displayRows)-1 {
+			m.cursor++
+		}
+		if len(m.displayRows) > 0 && m.cursor == len(m.displayRows)-1 {
+			m.follow = true
+		}
+	}
+	return m
+}
+
+func (m Model) copyQuery(withArgs bool) Model {
+	ev := m.cursorEvent()
+	if ev == nil || ev.GetQuery() == "" {
+		return m
+	}
+	text := ev.GetQuery()
+	if withArgs {
+		text = query.Bind(text, ev.GetArgs())
+	}
+	_ = clipboard.Copy(context.Background(), text)
+	return m
+}
+
+func (m Model) toggleSort() Model {
+	switch m.sortMode {
+	case sortChronological:
+		m.sortMode = sortDuration
+		m.follow = false
+	case sortDuration:
+		m.sortMode = sortRows
+	case sortRows:
+		m.sortMode = sortBytes
+	case sortBytes:
+		m.sortMode = sortChronological
+	}
+	m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+	m.cursor = 0
+	return m
+}
+
+// cursorRowKey identifies the row under the cursor independently of its position.
+func (m Model) cursorRowKey() (displayRow, bool) {
+	if m.cursor < 0 || m.cursor >= len(m.displayRows) {
+		return displayRow{}, false
+	}
+	return m.displayRows[m.cursor], true
+}
+
+// findRow returns the index of the row matching key, or the clamped cursor if it is gone.
+func (m Model) findRow(key displayRow) int {
+	for i, dr := range m.displayRows {
+		if dr.kind != key.kind {
+			continue
+		}
+		if (dr.kind == rowEvent && dr.eventIdx == key.eventIdx) || (dr.kind == rowTxSummary && dr.txID == key.txID) {
+			return i
+		}
+	}
+	return min(m.cursor, max(len(m.displayRows)-1, 0))
+}
+
+func (m Model) enterAnalytics() Model {
+	m.analyticsRows = m.buildAnalyticsRows()
+	sortAnalyticsRows(m.analyticsRows, m.analyticsSortMode)
+	m.analyticsCursor = 0
+	m.analyticsHScroll = 0
+	m.view = viewAnalytics
+	return m
+}
+
+func (m Model) clearFilter() Model {
+	if m.searchQuery != "" {
+		m.searchQuery = ""
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		m.cursor = min(m.cursor, max(len(m.displayRows)-1, 0))
+	}
+	return m
+}
+
+// toggleVerbose flips detailed capture for the connection of the event at the cursor.
+func (m Model) toggleVerbose() (tea.Model, tea.Cmd) {
+	ev := m.cursorEvent()
+	if ev == nil || ev.GetConnId() == "" || m.client == nil {
+		return m, nil
+	}
+	return m, setVerbose(m.client, ev.GetConnId(), !m.verboseConns[ev.GetConnId()])
+}
+
+func setVerbose(client tapv1.TapServiceClient, connID string, verbose bool) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.SetVerbose(context.Background(), &tapv1.SetVerboseRequest{
+			ConnId:  connID,
+			Verbose: verbose,
+		})
+		if err != nil {
+			return verboseResultMsg{err: err}
+		}
+		return verboseResultMsg{connIDs: resp.GetVerboseConnIds()}
+	}
+}
+
+func explainModeFromKey(key string) explain.Mode {
+	switch key {
+	case "X", "E":
+		return explain.Analyze
+	}
+	return explain.Explain
+}
+
+func (m Model) startEditExplain(mode explain.Mode) (tea.Model, tea.Cmd) {
+	ev := m.cursorEvent()
+	if ev == nil || ev.GetQuery() == "" || isLifecycleOp(ev) {
+		return m, nil
+	}
+
+	m.explainUpstream = ev.GetUpstream()
+	return m, openEditor(ev.GetQuery(), ev.GetArgs(), mode)
+}
+
+func isLifecycleOp(ev *tapv1.QueryEvent) bool {
+	switch proxy.Op(ev.GetOp()) {
+	case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpAdvisory, proxy.OpBatch, proxy.OpConnect, proxy.OpDisconnect:
+		return true
+	case proxy.OpQuery, proxy.OpExec, proxy.OpPrepare, proxy.OpBind, proxy.OpExecute, proxy.OpCancel, proxy.OpNotice:
+	}
+	return false
+}
+
+func (m Model) startExplain(mode explain.Mode) (tea.Model, tea.Cmd) {
+	ev := m.cursorEvent()
+	if ev == nil || ev.GetQuery() == "" || isLifecycleOp(ev) {
+		return m, nil
+	}
+
+	m.view = viewExplain
+	m.explainPlan = ""
+	m.explainAdvisories = nil
+	m.explainIndexes = nil
+	m.explainErr = nil
+	m.explainScroll = 0
+	m.explainHScroll = 0
+	m.explainMode = mode
+	m.explainQuery = ev.GetQuery()
+	m.explainArgs = ev.GetArgs()
+	m.explainUpstream = ev.GetUpstream()
+	return m, runExplain(m.client, mode, m.explainUpstream, ev.GetQuery(), ev.GetArgs())
+}
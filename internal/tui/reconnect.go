@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// Bounds on the wait between reconnection attempts, which doubles after
+// each failure.
+const (
+	reconnectMin = 500 * time.Millisecond
+	reconnectMax = 30 * time.Second
+)
+
+// disconnectedMsg reports that the Watch stream broke.
+type disconnectedMsg struct{ err error }
+
+// reconnectMsg fires when the next reconnection attempt is due.
+type reconnectMsg struct{}
+
+// reconnectState tracks recovery from a broken Watch stream.
+type reconnectState struct {
+	attempt int   // attempts since the stream broke; 0 while connected
+	err     error // why the stream, or the last attempt, failed
+}
+
+// reconnectDelay returns the wait before attempt n, counting from 1, with
+// up to a fifth shaved off so TUIs cut off together do not retry in step.
+func reconnectDelay(n int) time.Duration {
+	d := reconnectMax
+	if n-1 < 6 { // 500ms << 6 already exceeds the cap
+		d = min(reconnectMin<<(n-1), reconnectMax)
+	}
+	return d - rand.N(d/5) //nolint:gosec // jitter needs no cryptographic randomness
+}
+
+// permanent reports whether err will recur on every attempt, such as a
+// rejected token, so reconnecting is pointless.
+func permanent(err error) bool {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied, codes.InvalidArgument, codes.Unimplemented:
+		return true
+	}
+	return false
+}
+
+// disconnected closes the broken connection and schedules the next attempt
+// to reconnect, or shows err if retrying cannot help.
+func (m Model) disconnected(err error) (tea.Model, tea.Cmd) {
+	if permanent(err) {
+		m.err = err
+		return m, nil
+	}
+	if m.conn != nil {
+		_ = m.conn.Close()
+	}
+	m.reconnect.attempt++
+	m.reconnect.err = err
+	return m, tea.Tick(reconnectDelay(m.reconnect.attempt), func(time.Time) tea.Msg { return reconnectMsg{} })
+}
+
+// resumeAfter is where a new Watch stream picks up: the end of the newest
+// event received, or nil before the first.
+func (m Model) resumeAfter() *timestamppb.Timestamp {
+	if m.lastEnd.IsZero() {
+		return nil
+	}
+	return timestamppb.New(m.lastEnd)
+}
+
+// noteEnd advances the resume point past ev.
+func (m *Model) noteEnd(ev *tapv1.QueryEvent) {
+	end := ev.GetStartTime().AsTime().Add(ev.GetDuration().AsDuration())
+	if end.After(m.lastEnd) {
+		m.lastEnd = end
+	}
+}
+
+// reconnectBanner describes the lost connection while reconnecting, or
+// returns "".
+func (m Model) reconnectBanner() string {
+	r := m.reconnect
+	if r.attempt == 0 {
+		return ""
+	}
+	text := fmt.Sprintf(" disconnected from %s: %v; reconnecting (attempt %d)...", m.target, r.err, r.attempt)
+	return lipgloss.NewStyle().
+		Width(m.width).
+		Foreground(lipgloss.Color("15")).
+		Background(lipgloss.Color("1")).
+		Render(truncate(text, m.width))
+}
@@ -0,0 +1,183 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/highlight"
+)
+
+// routesResultMsg carries the result of a Routes call.
+type routesResultMsg struct {
+	resp *tapv1.RoutesResponse
+	err  error
+}
+
+func fetchRoutes(client tapv1.TapServiceClient) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.Routes(context.Background(), &tapv1.RoutesRequest{})
+		return routesResultMsg{resp: resp, err: err}
+	}
+}
+
+// sortRoutes puts routes with over-budget requests first, most first, and
+// keeps the daemon's busiest-first order otherwise.
+func sortRoutes(rs []*tapv1.RouteStats) []*tapv1.RouteStats {
+	rs = slices.Clone(rs)
+	slices.SortStableFunc(rs, func(a, b *tapv1.RouteStats) int {
+		return cmp.Compare(b.GetOverBudget(), a.GetOverBudget())
+	})
+	return rs
+}
+
+func (m Model) enterRoutes() (tea.Model, tea.Cmd) {
+	if m.client == nil {
+		return m, nil
+	}
+	m.view = viewRoutes
+	m.routesLoading = true
+	m.routesErr = nil
+	m.routesCursor = 0
+	return m, fetchRoutes(m.client)
+}
+
+func (m Model) applyRoutes(msg routesResultMsg) Model {
+	m.routesLoading = false
+	m.routesErr = msg.err
+	if msg.err == nil {
+		m.routes = sortRoutes(msg.resp.GetRoutes())
+		m.routesBudget = msg.resp.GetQueryBudget()
+		m.routesCursor = min(m.routesCursor, max(len(m.routes)-1, 0))
+	}
+	return m
+}
+
+func (m Model) updateRoutes(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "q":
+		m.view = viewList
+		return m, nil
+	case "j", "down":
+		if m.routesCursor < len(m.routes)-1 {
+			m.routesCursor++
+		}
+		return m, nil
+	case "k", "up":
+		if m.routesCursor > 0 {
+			m.routesCursor--
+		}
+		return m, nil
+	case "r":
+		m.routesLoading = true
+		return m, fetchRoutes(m.client)
+	}
+	return m, nil
+}
+
+// routeLines renders the route table and returns the line index of the
+// cursor's route. Routes with over-budget requests get a second line with
+// the query their heaviest request repeated most.
+func (m Model) routeLines(innerWidth int) ([]string, int) {
+	if m.routesErr != nil {
+		return []string{"Error: " + m.routesErr.Error()}, 0
+	}
+	if m.routesLoading && len(m.routes) == 0 {
+		return []string{"Loading routes..."}, 0
+	}
+	if len(m.routes) == 0 {
+		return []string{"No routed queries yet. Tag queries with the sqlcomment middleware to attribute them to routes."}, 0
+	}
+
+	const colRoute = 28
+	header := fmt.Sprintf("  %-*s %8s %7s %6s %*s %8s %14s %6s",
+		colRoute, "Route", "Queries", "QPS", "Errors", colDuration, "p95", "Requests", "Q/req p50/p95", "Max")
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(header)}
+	cursorLine := 0
+	bold := lipgloss.NewStyle().Bold(true)
+	warn := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+
+	for i, r := range m.routes {
+		marker := "  "
+		if i == m.routesCursor {
+			marker = "▶ "
+			cursorLine = len(lines)
+		}
+		perReq := "-"
+		maxReq := "-"
+		if r.GetRequests() > 0 {
+			perReq = fmt.Sprintf("%d/%d", r.GetQueriesPerRequestP50(), r.GetQueriesPerRequestP95())
+			maxReq = fmt.Sprintf("%d", r.GetQueriesPerRequestMax())
+		}
+		row := fmt.Sprintf("%s%-*s %8d %7.1f %6d %*s %8d %14s %6s",
+			marker, colRoute, truncate(r.GetRoute(), colRoute),
+			r.GetCount(), r.GetQps(), r.GetErrors(),
+			colDuration, formatDuration(r.GetP95()),
+			r.GetRequests(), perReq, maxReq)
+		if i == m.routesCursor {
+			row = bold.Render(row)
+		}
+		lines = append(lines, row)
+
+		if r.GetOverBudget() == 0 {
+			continue
+		}
+		flag := fmt.Sprintf("    ⚠ %d request(s) over %d queries; the heaviest ran %dx: ",
+			r.GetOverBudget(), m.routesBudget, r.GetTopFingerprintCalls())
+		q := truncate(r.GetTopFingerprint(), max(innerWidth-len([]rune(flag)), 10))
+		lines = append(lines, warn.Render(flag)+highlight.SQL(q))
+	}
+	return lines, cursorLine
+}
+
+func (m Model) renderRoutes() string {
+	innerWidth := max(m.width-4, 20)
+	visibleRows := max(m.height-2, 3) // -2 for top/bottom border
+
+	lines, cursorLine := m.routeLines(innerWidth)
+	start := 0
+	if len(lines) > visibleRows {
+		start = min(max(cursorLine-visibleRows/2, 0), len(lines)-visibleRows)
+	}
+	end := min(start+visibleRows, len(lines))
+	content := strings.Join(lines[start:end], "\n")
+
+	borderColor := lipgloss.Color("240")
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(borderColor).
+		Render(content)
+
+	boxLines := strings.Split(box, "\n")
+	if len(boxLines) > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		title := fmt.Sprintf(" Routes (%d) ", len(m.routes))
+		if m.routesLoading {
+			title += "[loading] "
+		}
+		dashes := max(innerWidth-len([]rune(title)), 0)
+		boxLines[0] = borderFg.Render("╭") +
+			lipgloss.NewStyle().Bold(true).Render(title) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╮")
+	}
+
+	if n := len(boxLines); n > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		help := " q: back  j/k: move  r: refresh "
+		dashes := max(innerWidth-len([]rune(help)), 0)
+		boxLines[n-1] = borderFg.Render("╰") +
+			lipgloss.NewStyle().Faint(true).Render(help) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╯")
+	}
+
+	return strings.Join(boxLines, "\n")
+}
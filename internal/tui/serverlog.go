@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/pglog"
+)
+
+// serverLogMsg carries the server log entries matched to an event.
+type serverLogMsg struct {
+	eventID string
+	entries []pglog.Entry
+	err     error
+}
+
+// serverLogResult is a finished lookup for one event.
+type serverLogResult struct {
+	entries []pglog.Entry
+	err     error
+}
+
+// WithServerLog matches inspected events against PostgreSQL csvlog entries
+// read by c.
+func WithServerLog(c *pglog.Correlator) Option {
+	return func(m *Model) {
+		m.serverLog = c
+		m.serverLogs = make(map[string]serverLogResult)
+	}
+}
+
+// lookupServerLog matches the event at the cursor against the server log.
+// It runs each time the inspector opens, since the server may have logged
+// more about the event since the last look.
+func (m Model) lookupServerLog() tea.Cmd {
+	ev := m.cursorEvent()
+	if m.serverLog == nil || ev == nil {
+		return nil
+	}
+	c := m.serverLog
+	target := pglog.Target{
+		PID:      ev.GetBackendPid(),
+		Start:    ev.GetStartTime().AsTime(),
+		Duration: ev.GetDuration().AsDuration(),
+		Query:    ev.GetQuery(),
+	}
+	return func() tea.Msg {
+		entries, err := c.Match(target)
+		return serverLogMsg{eventID: ev.GetId(), entries: entries, err: err}
+	}
+}
+
+// serverLogLines renders the server log entries matched to ev.
+func (m Model) serverLogLines(ev *tapv1.QueryEvent) []string {
+	if m.serverLog == nil {
+		return nil
+	}
+	res, ok := m.serverLogs[ev.GetId()]
+	switch {
+	case !ok:
+		return []string{"", "Server log: matching..."}
+	case res.err != nil:
+		return []string{"", "Server log: " + res.err.Error()}
+	case len(res.entries) == 0:
+		return []string{"", "Server log: no matching entries"}
+	}
+	lines := []string{"", "Server log:"}
+	for _, e := range res.entries {
+		ts := e.Time.In(time.Local).Format("15:04:05.000") //nolint:gosmopolitan // TUI displays local time
+		head := fmt.Sprintf("  %s %s", ts, e.Severity)
+		if e.SQLState != "" && e.SQLState != "00000" {
+			head += " " + e.SQLState
+		}
+		if e.BackendType != "" && e.BackendType != "client backend" {
+			head += fmt.Sprintf(" [%s %d]", e.BackendType, e.PID)
+		}
+		lines = append(lines, head+" "+e.Message)
+		if e.Detail != "" {
+			lines = append(lines, "    Detail: "+e.Detail)
+		}
+		if e.Hint != "" {
+			lines = append(lines, "    Hint:   "+e.Hint)
+		}
+		if e.Context != "" {
+			lines = append(lines, "    Where:  "+e.Context)
+		}
+	}
+	return lines
+}
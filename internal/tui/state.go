@@ -0,0 +1,136 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// sessionState is the part of the Model persisted between runs.
+// Views tied to a single captured event (inspector, explain) are restored as the list.
+type sessionState struct {
+	SearchQuery     string `json:"search_query,omitempty"`
+	Sort            string `json:"sort,omitempty"` // "time", "duration", or "rows"
+	AnalyticsSort   string `json:"analytics_sort,omitempty"`
+	View            string `json:"view,omitempty"` // "list" or "analytics"
+	Follow          bool   `json:"follow"`
+	Cursor          int    `json:"cursor,omitempty"`
+	AnalyticsCursor int    `json:"analytics_cursor,omitempty"`
+
+	Columns []columnState `json:"columns,omitempty"`
+}
+
+// DefaultStatePath returns the default location of the session state file.
+func DefaultStatePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sql-tap", "state.json")
+}
+
+// WithStateFile restores session state from path, if it exists, and saves it
+// there on quit.
+func WithStateFile(path string) Option {
+	return func(m *Model) {
+		m.statePath = path
+		st, err := loadState(path)
+		if err != nil {
+			return // missing or unreadable state starts a fresh session
+		}
+		m.applyState(st)
+	}
+}
+
+func loadState(path string) (sessionState, error) {
+	var st sessionState
+	data, err := os.ReadFile(path) //nolint:gosec // path comes from the user's own flag
+	if err != nil {
+		return st, fmt.Errorf("tui: read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("tui: parse state: %w", err)
+	}
+	return st, nil
+}
+
+func saveState(path string, st sessionState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("tui: encode state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("tui: create state dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("tui: write state: %w", err)
+	}
+	return nil
+}
+
+func (m Model) sessionState() sessionState {
+	st := sessionState{
+		SearchQuery:     m.searchQuery,
+		Sort:            m.sortMode.String(),
+		AnalyticsSort:   m.analyticsSortMode.String(),
+		View:            "list",
+		Follow:          m.follow,
+		Cursor:          m.cursor,
+		AnalyticsCursor: m.analyticsCursor,
+		Columns:         m.columnStates(),
+	}
+	if m.view == viewAnalytics {
+		st.View = "analytics"
+	}
+	return st
+}
+
+func (m *Model) applyState(st sessionState) {
+	m.searchQuery = st.SearchQuery
+	for _, s := range []sortMode{sortChronological, sortDuration, sortRows, sortBytes} {
+		if s.String() == st.Sort {
+			m.sortMode = s
+		}
+	}
+	if len(st.Columns) > 0 {
+		m.columns = applyColumnStates(st.Columns)
+	}
+	for _, s := range []analyticsSortMode{analyticsSortTotalDuration, analyticsSortCount, analyticsSortAvgDuration} {
+		if s.String() == st.AnalyticsSort {
+			m.analyticsSortMode = s
+		}
+	}
+	if st.View == "analytics" {
+		m.view = viewAnalytics
+	}
+	m.follow = st.Follow
+	m.restore = &st
+}
+
+// restoreCursors re-applies saved cursor positions as events arrive, until the
+// user presses a key.
+func (m Model) restoreCursors() Model {
+	if m.view == viewAnalytics {
+		m.analyticsRows = m.buildAnalyticsRows()
+		sortAnalyticsRows(m.analyticsRows, m.analyticsSortMode)
+		m.analyticsCursor = min(m.restore.AnalyticsCursor, max(len(m.analyticsRows)-1, 0))
+	}
+	if !m.follow {
+		m.cursor = min(m.restore.Cursor, max(len(m.displayRows)-1, 0))
+	}
+	return m
+}
+
+// quit closes the connection, saves session state, and exits.
+func (m Model) quit() (tea.Model, tea.Cmd) {
+	if m.conn != nil {
+		_ = m.conn.Close()
+	}
+	if m.statePath != "" {
+		_ = saveState(m.statePath, m.sessionState())
+	}
+	return m, tea.Quit
+}
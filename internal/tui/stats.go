@@ -0,0 +1,453 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/clipboard"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/internal/stats"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// statsRefresh is how often the stats view recomputes its summaries.
+const statsRefresh = time.Second
+
+// statsTickMsg refreshes the stats view; ticks from an earlier visit (gen
+// mismatch) are ignored.
+type statsTickMsg struct{ gen int }
+
+func statsTick(gen int) tea.Cmd {
+	return tea.Tick(statsRefresh, func(time.Time) tea.Msg { return statsTickMsg{gen: gen} })
+}
+
+// observeStats feeds a received event into the rolling stats, keyed by its
+// query fingerprint, and into the read/write split. Events are bucketed by
+// arrival time, so a daemon with a skewed clock does not distort rates.
+func (m Model) observeStats(ev *tapv1.QueryEvent) {
+	now := time.Now()
+	m.rwSplit.Observe(ev, now)
+	switch proxy.Op(ev.GetOp()) {
+	case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpBind, proxy.OpPrepare, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice,
+		proxy.OpConnect, proxy.OpDisconnect:
+		return
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	}
+	if ev.GetQuery() == "" {
+		return
+	}
+	fp := ev.GetFingerprint()
+	if fp == "" { // from a daemon that predates fingerprinting
+		fp = query.Fingerprint(ev.GetQuery())
+	}
+	m.statsAgg.Observe(fp, now, ev.GetDuration().AsDuration(), ev.GetError() != "")
+}
+
+func (m Model) enterStats() (tea.Model, tea.Cmd) {
+	m.view = viewStats
+	m.statsGen++
+	m.statsCursor = 0
+	m = m.refreshStats()
+	if m.client == nil {
+		return m, statsTick(m.statsGen)
+	}
+	m.serverStmtsFetched = time.Now()
+	return m, tea.Batch(statsTick(m.statsGen), fetchStatements(m.client))
+}
+
+// statementsRefresh is how often the stats view asks the daemon for its
+// pg_stat_statements totals, which it polls less often still.
+const statementsRefresh = 10 * time.Second
+
+// serverStmt is a fingerprint's pg_stat_statements totals.
+type serverStmt struct {
+	calls int64
+	total time.Duration
+}
+
+func (s serverStmt) mean() time.Duration {
+	if s.calls == 0 {
+		return 0
+	}
+	return s.total / time.Duration(s.calls)
+}
+
+// statementsResultMsg carries the result of a Statements call.
+type statementsResultMsg struct {
+	resp *tapv1.StatementsResponse
+	err  error
+}
+
+func fetchStatements(client tapv1.TapServiceClient) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.Statements(context.Background(), &tapv1.StatementsRequest{})
+		return statementsResultMsg{resp: resp, err: err}
+	}
+}
+
+// applyStatements keeps the daemon's pg_stat_statements totals, or none when
+// it does not poll them.
+func (m Model) applyStatements(msg statementsResultMsg) Model {
+	m.serverStmts, m.serverStmtsAt, m.serverStmtsErr = nil, time.Time{}, ""
+	if msg.err != nil || msg.resp.GetPolledAt() == nil {
+		return m
+	}
+	m.serverStmts = make(map[string]serverStmt)
+	for _, st := range msg.resp.GetStatements() {
+		s := m.serverStmts[st.GetFingerprint()]
+		s.calls += st.GetCalls()
+		s.total += st.GetTotal().AsDuration()
+		m.serverStmts[st.GetFingerprint()] = s
+	}
+	m.serverStmtsAt = msg.resp.GetPolledAt().AsTime()
+	if errs := msg.resp.GetErrors(); len(errs) > 0 {
+		upstream := slices.Min(slices.Collect(maps.Keys(errs)))
+		m.serverStmtsErr = errs[upstream]
+		if upstream != "" {
+			m.serverStmtsErr = upstream + ": " + m.serverStmtsErr
+		}
+	}
+	return m
+}
+
+func (m Model) refreshStats() Model {
+	now := time.Now()
+	m.statsOverall = m.statsAgg.Overall(now)
+	m.statsKeys = m.statsAgg.Keys(now)
+	m.rwOverall = m.rwSplit.Overall(now)
+	m.rwConns = nil
+	if m.statsByConn {
+		m.rwConns = m.rwSplit.Conns(now)
+	}
+	m.statsCursor = min(m.statsCursor, max(m.statsRows()-1, 0))
+	return m
+}
+
+// statsRows returns the length of the table the stats view shows.
+func (m Model) statsRows() int {
+	if m.statsByConn {
+		return len(m.rwConns)
+	}
+	return len(m.statsKeys)
+}
+
+func (m Model) updateStats(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "q":
+		m.view = viewList
+		m.statsGen++ // stop the refresh ticks
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		if m.follow {
+			m.cursor = max(len(m.displayRows)-1, 0)
+		}
+		return m, nil
+	case "j", "down":
+		if m.statsCursor < m.statsRows()-1 {
+			m.statsCursor++
+		}
+		return m, nil
+	case "k", "up":
+		if m.statsCursor > 0 {
+			m.statsCursor--
+		}
+		return m, nil
+	case "c":
+		switch {
+		case m.statsByConn && m.statsCursor < len(m.rwConns):
+			_ = clipboard.Copy(context.Background(), m.rwConns[m.statsCursor].ConnID)
+		case !m.statsByConn && m.statsCursor < len(m.statsKeys):
+			_ = clipboard.Copy(context.Background(), m.statsKeys[m.statsCursor].Key)
+		}
+		return m, nil
+	case "w":
+		m.statsByConn = !m.statsByConn
+		m.statsCursor = 0
+		return m.refreshStats(), nil
+	}
+	return m, nil
+}
+
+const (
+	statsColRate   = 8 // QPS and error rate
+	statsColLat    = 9 // p50, p95, p99
+	statsColTrend  = 20
+	statsColServer = 10 // pg_stat_statements calls and mean
+)
+
+// sparkBlocks are the sparkline levels, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline renders the last width values scaled to their maximum.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		values = values[len(values)-width:]
+	}
+	peak := slices.Max(append([]float64{0}, values...))
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if peak > 0 {
+			i = int(v / peak * float64(len(sparkBlocks)-1))
+		}
+		b.WriteRune(sparkBlocks[i])
+	}
+	return b.String()
+}
+
+func intsToFloats(vs []int) []float64 {
+	out := make([]float64, len(vs))
+	for i, v := range vs {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+func durationsToFloats(vs []time.Duration) []float64 {
+	out := make([]float64, len(vs))
+	for i, v := range vs {
+		out[i] = float64(v)
+	}
+	return out
+}
+
+func formatRate(f float64) string {
+	if f >= 100 {
+		return fmt.Sprintf("%.0f", f)
+	}
+	return fmt.Sprintf("%.1f", f)
+}
+
+// gauge renders a bar of width cells, share (0 to 1) of them filled.
+func gauge(share float64, width int) string {
+	filled := min(int(share*float64(width)+0.5), width)
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("75")).Render(strings.Repeat("█", filled)) +
+		lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(strings.Repeat("░", width-filled))
+}
+
+// replicaShare formats the share of n statements routed to the replica.
+func replicaShare(replica, n int) string {
+	if n == 0 {
+		return ""
+	}
+	return formatRate(100*float64(replica)/float64(n)) + "%"
+}
+
+func (m Model) renderStats() string {
+	innerWidth := max(m.width-4, 20)
+	s := m.statsOverall
+	window := time.Duration(stats.DefaultBuckets) * stats.DefaultResolution
+	title := fmt.Sprintf(" Stats (last %s, %d fingerprints) ", window, len(m.statsKeys))
+	if m.statsByConn {
+		title = fmt.Sprintf(" Stats (last %s, %d connections) ", window, len(m.rwConns))
+	}
+
+	sparkWidth := max(innerWidth-20, 10)
+	label := lipgloss.NewStyle().Bold(true)
+	rw := m.rwOverall
+	split := fmt.Sprintf("%d reads  p50 %s  p99 %s    %d writes  p50 %s  p99 %s",
+		rw.Reads.Count, formatDurationValue(rw.Reads.P50), formatDurationValue(rw.Reads.P99),
+		rw.Writes.Count, formatDurationValue(rw.Writes.P50), formatDurationValue(rw.Writes.P99))
+	if replica := rw.ReplicaReads + rw.ReplicaWrites; replica > 0 {
+		split += fmt.Sprintf("    replica: %s of reads, %d writes", replicaShare(rw.ReplicaReads, rw.Reads.Count), rw.ReplicaWrites)
+	}
+	rows := []string{
+		fmt.Sprintf("%s %8s/s  %s", label.Render("QPS   "), formatRate(s.QPS), sparkline(intsToFloats(s.Counts), sparkWidth)),
+		fmt.Sprintf("%s %10s  %s", label.Render("p99   "), formatDurationValue(s.P99), sparkline(durationsToFloats(s.P99s), sparkWidth)),
+		fmt.Sprintf("%s %9s%%  %s", label.Render("Errors"), formatRate(100*s.ErrorRate), sparkline(intsToFloats(s.ErrorCounts), sparkWidth)),
+		fmt.Sprintf("%s %9s%%  %s", label.Render("Reads "), formatRate(100*rw.ReadShare()), gauge(rw.ReadShare(), min(sparkWidth, stats.DefaultBuckets))),
+		fmt.Sprintf("%d queries  p50 %s  p95 %s  p99 %s",
+			s.Count, formatDurationValue(s.P50), formatDurationValue(s.P95), formatDurationValue(s.P99)),
+		split,
+	}
+	server := !m.serverStmtsAt.IsZero()
+	if server && !m.statsByConn {
+		line := fmt.Sprintf("pg_stat_statements at %s: calls and mean since the last reset",
+			m.serverStmtsAt.Local().Format("15:04:05"))
+		if m.serverStmtsErr != "" {
+			line += "  " + lipgloss.NewStyle().Foreground(lipgloss.Color("167")).Render(m.serverStmtsErr)
+		}
+		rows = append(rows, line)
+	}
+	rows = append(rows, "")
+
+	height := max(m.height-3-len(rows), 1) // less the table header
+	if m.statsByConn {
+		rows = append(rows, m.statsConnRows(innerWidth, height)...)
+	} else {
+		rows = append(rows, m.statsKeyRows(innerWidth, height, server)...)
+	}
+
+	borderColor := lipgloss.Color("240")
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(borderColor).
+		Render(strings.Join(rows, "\n"))
+
+	boxLines := strings.Split(box, "\n")
+	if len(boxLines) > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		dashes := max(innerWidth-len([]rune(title)), 0)
+		boxLines[0] = borderFg.Render("╭") +
+			lipgloss.NewStyle().Bold(true).Render(title) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╮")
+	}
+	if n := len(boxLines); n > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		help := " q: back  j/k: navigate  c: copy fingerprint  w: connections "
+		if m.statsByConn {
+			help = " q: back  j/k: navigate  c: copy connection ID  w: fingerprints "
+		}
+		dashes := max(innerWidth-len([]rune(help)), 0)
+		boxLines[n-1] = borderFg.Render("╰") +
+			lipgloss.NewStyle().Faint(true).Render(help) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╯")
+	}
+	return strings.Join(boxLines, "\n")
+}
+
+// statsPage returns the range of an n-row table to show in height rows,
+// keeping the cursor in view.
+func (m Model) statsPage(n, height int) (int, int) {
+	start := 0
+	if n > height {
+		start = max(m.statsCursor-height/2, 0)
+		start = min(start, n-height)
+	}
+	return start, min(start+height, n)
+}
+
+func (m Model) statsMarker(i int) string {
+	if i == m.statsCursor {
+		return "▶ "
+	}
+	return "  "
+}
+
+func truncateRunes(s string, width int) string {
+	r := []rune(s)
+	if len(r) > width {
+		r = append(r[:width-1], '…')
+	}
+	return string(r)
+}
+
+// statsKeyRows renders the fingerprint table, header first, in at most
+// height data rows.
+func (m Model) statsKeyRows(innerWidth, height int, server bool) []string {
+	colQuery := max(innerWidth-2-2*statsColRate-3*statsColLat-statsColTrend-7, 10)
+	var serverHeader string
+	if server {
+		colQuery = max(colQuery-2*statsColServer-2, 10)
+		serverHeader = fmt.Sprintf(" %*s %*s", statsColServer, "Srv calls", statsColServer, "Srv mean")
+	}
+	header := fmt.Sprintf("  %*s %*s %*s %*s %*s%s  %-*s  %s",
+		statsColRate, "QPS",
+		statsColLat, "p50",
+		statsColLat, "p95",
+		statsColLat, "p99",
+		statsColRate, "Err%",
+		serverHeader,
+		statsColTrend, "Trend",
+		"Fingerprint",
+	)
+	rows := []string{lipgloss.NewStyle().Bold(true).Render(header)}
+
+	start, end := m.statsPage(len(m.statsKeys), height)
+	for i := start; i < end; i++ {
+		k := m.statsKeys[i]
+		var errRate string
+		if k.Errors > 0 {
+			errRate = formatRate(100 * k.ErrorRate)
+		}
+		var serverCols string
+		if server {
+			var calls, mean string
+			if st, ok := m.serverStmts[k.Key]; ok {
+				calls, mean = strconv.FormatInt(st.calls, 10), formatDurationValue(st.mean())
+			}
+			serverCols = fmt.Sprintf(" %*s %*s", statsColServer, calls, statsColServer, mean)
+		}
+		rows = append(rows, fmt.Sprintf("%s%*s %*s %*s %*s %*s%s  %-*s  %s",
+			m.statsMarker(i),
+			statsColRate, formatRate(k.QPS),
+			statsColLat, formatDurationValue(k.P50),
+			statsColLat, formatDurationValue(k.P95),
+			statsColLat, formatDurationValue(k.P99),
+			statsColRate, errRate,
+			serverCols,
+			statsColTrend, sparkline(intsToFloats(k.Counts), statsColTrend),
+			truncateRunes(k.Key, colQuery),
+		))
+	}
+	return rows
+}
+
+// statsConnRows renders the per-connection read/write table, header first,
+// in at most height data rows. The replica columns appear once the proxy has
+// routed a statement to a replica.
+func (m Model) statsConnRows(innerWidth, height int) []string {
+	replica := m.rwOverall.ReplicaReads+m.rwOverall.ReplicaWrites > 0
+	colConn := max(innerWidth-2-3*statsColRate-4*statsColLat-statsColTrend-9, 10)
+	var replicaHeader string
+	if replica {
+		colConn = max(colConn-2*statsColRate-2, 10)
+		replicaHeader = fmt.Sprintf(" %*s %*s", statsColRate, "Rep rd%", statsColRate, "Rep wr")
+	}
+	header := fmt.Sprintf("  %*s %*s %*s %*s %*s %*s %*s%s  %-*s  %s",
+		statsColRate, "Reads",
+		statsColRate, "Writes",
+		statsColRate, "Read%",
+		statsColLat, "Rd p50",
+		statsColLat, "Rd p99",
+		statsColLat, "Wr p50",
+		statsColLat, "Wr p99",
+		replicaHeader,
+		statsColTrend, "Split",
+		"Connection",
+	)
+	rows := []string{lipgloss.NewStyle().Bold(true).Render(header)}
+
+	start, end := m.statsPage(len(m.rwConns), height)
+	for i := start; i < end; i++ {
+		c := m.rwConns[i]
+		var replicaCols string
+		if replica {
+			replicaCols = fmt.Sprintf(" %*s %*d", statsColRate, replicaShare(c.ReplicaReads, c.Reads.Count), statsColRate, c.ReplicaWrites)
+		}
+		rows = append(rows, fmt.Sprintf("%s%*d %*d %*s %*s %*s %*s %*s%s  %s  %s",
+			m.statsMarker(i),
+			statsColRate, c.Reads.Count,
+			statsColRate, c.Writes.Count,
+			statsColRate, formatRate(100*c.ReadShare()),
+			statsColLat, classDuration(c.Reads.Count, c.Reads.P50),
+			statsColLat, classDuration(c.Reads.Count, c.Reads.P99),
+			statsColLat, classDuration(c.Writes.Count, c.Writes.P50),
+			statsColLat, classDuration(c.Writes.Count, c.Writes.P99),
+			replicaCols,
+			gauge(c.ReadShare(), statsColTrend),
+			truncateRunes(c.ConnID, colConn),
+		))
+	}
+	return rows
+}
+
+// classDuration formats a latency of a class that saw n statements, blank
+// when it saw none.
+func classDuration(n int, d time.Duration) string {
+	if n == 0 {
+		return ""
+	}
+	return formatDurationValue(d)
+}
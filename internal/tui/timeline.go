@@ -0,0 +1,362 @@
+package tui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Bounds and default of the timeline's window, which +/- halve and double.
+const (
+	timelineDefaultSpan = 5 * time.Second
+	timelineMinSpan     = time.Millisecond
+	timelineMaxSpan     = time.Hour
+)
+
+// timelineLabel is the width of the connection column.
+const timelineLabel = 20
+
+// timelineRow is one connection's statements within the window.
+type timelineRow struct {
+	label  string
+	events []*tapv1.QueryEvent
+	first  time.Time // earliest start, for ordering
+}
+
+// enterTimeline opens the timeline on the window around the list's cursor
+// event, or following the newest events when the list follows them.
+func (m Model) enterTimeline() Model {
+	m.view = viewTimeline
+	m.timelineSpan = timelineDefaultSpan
+	m.timelineEnd = time.Time{}
+	m.timelineCursor = 0
+	if ev := m.cursorEvent(); ev != nil && !m.follow {
+		start := ev.GetStartTime().AsTime()
+		dur := ev.GetDuration().AsDuration()
+		m.timelineSpan = min(max(timelineDefaultSpan, 2*dur), timelineMaxSpan)
+		m.timelineEnd = start.Add(dur/2 + m.timelineSpan/2)
+	}
+	return m
+}
+
+func (m Model) updateTimeline(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "q":
+		m.view = viewList
+		return m, nil
+	case "j", "down":
+		end := m.timelineWindowEnd()
+		if n := len(m.timelineRows(end.Add(-m.timelineSpan), end)); m.timelineCursor < n-1 {
+			m.timelineCursor++
+		}
+		return m, nil
+	case "k", "up":
+		if m.timelineCursor > 0 {
+			m.timelineCursor-- // may still exceed the rows, clamped when rendering
+		}
+		return m, nil
+	case "+", "=":
+		m = m.zoomTimeline(m.timelineSpan / 2)
+		return m, nil
+	case "-":
+		m = m.zoomTimeline(m.timelineSpan * 2)
+		return m, nil
+	case "h", "left":
+		m.timelineEnd = m.timelineWindowEnd().Add(-m.timelineSpan / 4)
+		return m, nil
+	case "l", "right":
+		m.timelineEnd = m.timelineWindowEnd().Add(m.timelineSpan / 4)
+		return m, nil
+	case "f":
+		m.timelineEnd = time.Time{}
+		return m, nil
+	}
+	return m, nil
+}
+
+// zoomTimeline changes the window's span, keeping its center in place
+// unless it follows the newest events.
+func (m Model) zoomTimeline(span time.Duration) Model {
+	span = min(max(span, timelineMinSpan), timelineMaxSpan)
+	if !m.timelineEnd.IsZero() {
+		m.timelineEnd = m.timelineEnd.Add((span - m.timelineSpan) / 2)
+	}
+	m.timelineSpan = span
+	return m
+}
+
+// timelineWindowEnd is the end of the window: fixed once panned, otherwise
+// the end of the newest event the filter matches.
+func (m Model) timelineWindowEnd() time.Time {
+	if !m.timelineEnd.IsZero() {
+		return m.timelineEnd
+	}
+	var end time.Time
+	matched := matchingEvents(m.events, m.searchQuery)
+	for i, ev := range m.events {
+		if !matched[i] {
+			continue
+		}
+		if e := eventEnd(ev); e.After(end) {
+			end = e
+		}
+	}
+	return end
+}
+
+func eventEnd(ev *tapv1.QueryEvent) time.Time {
+	return ev.GetStartTime().AsTime().Add(ev.GetDuration().AsDuration())
+}
+
+// timelineRows groups the statements the filter matches that overlap
+// [from, to) by connection, ordered by when each connection first ran one.
+func (m Model) timelineRows(from, to time.Time) []timelineRow {
+	matched := matchingEvents(m.events, m.searchQuery)
+	byConn := make(map[string]int)
+	var rows []timelineRow
+	for i, ev := range m.events {
+		if !matched[i] || ev.GetConnId() == "" {
+			continue
+		}
+		switch proxy.Op(ev.GetOp()) {
+		case proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect: // not statements of the connection
+			continue
+		case proxy.OpQuery, proxy.OpExec, proxy.OpPrepare, proxy.OpBind, proxy.OpExecute,
+			proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel:
+		}
+		start := ev.GetStartTime().AsTime()
+		if !start.Before(to) || eventEnd(ev).Before(from) {
+			continue
+		}
+		key := ev.GetUpstream() + "\x00" + ev.GetConnId()
+		k, ok := byConn[key]
+		if !ok {
+			k = len(rows)
+			byConn[key] = k
+			rows = append(rows, timelineRow{label: connLabel(ev), first: start})
+		}
+		r := &rows[k]
+		r.events = append(r.events, ev)
+		if start.Before(r.first) {
+			r.first = start
+		}
+	}
+	slices.SortStableFunc(rows, func(a, b timelineRow) int { return a.first.Compare(b.first) })
+	return rows
+}
+
+// connLabel names ev's connection in the timeline: its upstream, when not
+// the default, its ID, and its backend PID when known.
+func connLabel(ev *tapv1.QueryEvent) string {
+	label := "#" + ev.GetConnId()
+	if u := ev.GetUpstream(); u != "" {
+		label = u + " " + label
+	}
+	if pid := ev.GetBackendPid(); pid != 0 {
+		label += fmt.Sprintf(" pid %d", pid)
+	}
+	return label
+}
+
+// timelineCell is what one column of a connection's bar shows.
+type timelineCell struct {
+	glyph rune
+	color lipgloss.Color
+}
+
+// timelineBar draws r's statements over width cells covering [from, from+span).
+// Statements are solid blocks, failed ones shaded red, and the stretch of a
+// transaction between its statements a thin line, so a connection holding
+// a transaction open while others wait stands out.
+func (m Model) timelineBar(r timelineRow, from time.Time, span time.Duration, width int) string {
+	cells := make([]timelineCell, width)
+	for i := range cells {
+		cells[i] = timelineCell{glyph: ' '}
+	}
+	cellOf := func(t time.Time) int {
+		return int(int64(t.Sub(from)) * int64(width) / int64(span))
+	}
+	fill := func(start, end time.Time, c timelineCell) {
+		i0 := max(cellOf(start), 0)
+		i1 := min(cellOf(end), width-1)
+		if i1 < i0 && i0 < width {
+			i1 = i0 // too short for a cell: still one
+		}
+		for i := i0; i <= i1; i++ {
+			cells[i] = c
+		}
+	}
+
+	txSpans := make(map[string][2]time.Time)
+	var txOrder []string
+	for _, ev := range r.events {
+		tx := ev.GetTxId()
+		if tx == "" {
+			continue
+		}
+		start, end := ev.GetStartTime().AsTime(), eventEnd(ev)
+		s, ok := txSpans[tx]
+		if !ok {
+			txOrder = append(txOrder, tx)
+			s = [2]time.Time{start, end}
+		}
+		if start.Before(s[0]) {
+			s[0] = start
+		}
+		if end.After(s[1]) {
+			s[1] = end
+		}
+		txSpans[tx] = s
+	}
+	for _, tx := range txOrder {
+		s := txSpans[tx]
+		fill(s[0], s[1], timelineCell{glyph: '─', color: m.timelineColor(tx)})
+	}
+	for _, ev := range r.events {
+		c := timelineCell{glyph: '█', color: m.timelineColor(ev.GetTxId())}
+		if ev.GetError() != "" {
+			c = timelineCell{glyph: '▓', color: lipgloss.Color("1")}
+		}
+		fill(ev.GetStartTime().AsTime(), eventEnd(ev), c)
+	}
+
+	// Style runs of equal cells together.
+	var b strings.Builder
+	for i := 0; i < width; {
+		j := i
+		var run strings.Builder
+		for ; j < width && cells[j] == cells[i]; j++ {
+			run.WriteRune(cells[j].glyph)
+		}
+		if cells[i].glyph == ' ' {
+			b.WriteString(run.String())
+		} else {
+			b.WriteString(lipgloss.NewStyle().Foreground(cells[i].color).Render(run.String()))
+		}
+		i = j
+	}
+	return b.String()
+}
+
+// timelineColor is the list's color for transaction tx, or the default for
+// statements outside one.
+func (m Model) timelineColor(tx string) lipgloss.Color {
+	if c, ok := m.txColorMap[tx]; ok && tx != "" {
+		return c
+	}
+	return lipgloss.Color("6")
+}
+
+// timelineSummary describes r's activity within the window.
+func timelineSummary(r timelineRow, from, to time.Time) string {
+	var busy, longest time.Duration
+	var failed int
+	for _, ev := range r.events {
+		start, end := ev.GetStartTime().AsTime(), eventEnd(ev)
+		busy += minTime(end, to).Sub(maxTime(start, from))
+		longest = max(longest, ev.GetDuration().AsDuration())
+		if ev.GetError() != "" {
+			failed++
+		}
+	}
+	pct := min(float64(busy)/float64(to.Sub(from))*100, 100)
+	s := fmt.Sprintf("%s: %d statement(s), busy %.0f%% of the window, longest %s",
+		r.label, len(r.events), pct, formatDurationValue(longest))
+	if failed > 0 {
+		s += fmt.Sprintf(", %d failed", failed)
+	}
+	return s
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (m Model) renderTimeline() string {
+	innerWidth := max(m.width-4, 40)
+	visibleRows := max(m.height-5, 3) // borders, axis, and summary
+	barWidth := innerWidth - timelineLabel - 1
+
+	end := m.timelineWindowEnd()
+	from := end.Add(-m.timelineSpan)
+	rows := m.timelineRows(from, end)
+	cursor := min(m.timelineCursor, max(len(rows)-1, 0))
+
+	startClock := from.In(time.Local).Format("15:04:05.000") //nolint:gosmopolitan // TUI displays local time
+	endClock := end.In(time.Local).Format("15:04:05.000")    //nolint:gosmopolitan // TUI displays local time
+	spanText := "← " + formatDurationValue(m.timelineSpan) + " →"
+	gap := max(barWidth-len(startClock)-len(endClock)-len([]rune(spanText)), 2)
+	axis := fmt.Sprintf("  %-*s %s%s%s%s%s", timelineLabel-2, "Connection",
+		startClock, strings.Repeat(" ", gap/2), spanText, strings.Repeat(" ", gap-gap/2), endClock)
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(axis)}
+
+	start := 0
+	if len(rows) > visibleRows {
+		start = min(max(cursor-visibleRows/2, 0), len(rows)-visibleRows)
+	}
+	bold := lipgloss.NewStyle().Bold(true)
+	for i := start; i < min(start+visibleRows, len(rows)); i++ {
+		label := "  " + fmt.Sprintf("%-*s", timelineLabel-2, truncate(rows[i].label, timelineLabel-2))
+		if i == cursor {
+			label = bold.Render("▶ " + label[2:])
+		}
+		lines = append(lines, label+" "+m.timelineBar(rows[i], from, m.timelineSpan, barWidth))
+	}
+	switch {
+	case len(rows) == 0:
+		lines = append(lines, "No statements in this window. Press f to follow the newest, or - to widen it.")
+	default:
+		lines = append(lines, lipgloss.NewStyle().Faint(true).Render(truncate(timelineSummary(rows[cursor], from, end), innerWidth)))
+	}
+	content := strings.Join(lines, "\n")
+
+	borderColor := lipgloss.Color("240")
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(borderColor).
+		Render(content)
+
+	boxLines := strings.Split(box, "\n")
+	if len(boxLines) > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		title := fmt.Sprintf(" Timeline (%d connections) ", len(rows))
+		if m.timelineEnd.IsZero() {
+			title += "[following] "
+		}
+		dashes := max(innerWidth-len([]rune(title)), 0)
+		boxLines[0] = borderFg.Render("╭") +
+			lipgloss.NewStyle().Bold(true).Render(title) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╮")
+	}
+
+	if n := len(boxLines); n > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		help := " q: back  j/k: move  h/l: pan  +/-: zoom  f: follow "
+		dashes := max(innerWidth-len([]rune(help)), 0)
+		boxLines[n-1] = borderFg.Render("╰") +
+			lipgloss.NewStyle().Faint(true).Render(help) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╯")
+	}
+
+	return strings.Join(boxLines, "\n")
+}
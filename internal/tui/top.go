@@ -0,0 +1,425 @@
+package tui
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/mickamy/sql-tap/internal/clipboard"
+	"github.com/mickamy/sql-tap/internal/highlight"
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// topRefresh is how often the top view re-sorts its rows.
+const topRefresh = time.Second
+
+// topExamples is how many recent events the top view keeps per fingerprint
+// to drill into.
+const topExamples = 20
+
+type topSortMode int
+
+const (
+	topSortTotal topSortMode = iota
+	topSortMean
+	topSortCalls
+	topSortRows
+)
+
+func (s topSortMode) String() string {
+	switch s {
+	case topSortTotal:
+		return "total"
+	case topSortMean:
+		return "mean"
+	case topSortCalls:
+		return "calls"
+	case topSortRows:
+		return "rows"
+	}
+	return "total"
+}
+
+func (s topSortMode) next() topSortMode {
+	switch s {
+	case topSortTotal:
+		return topSortMean
+	case topSortMean:
+		return topSortCalls
+	case topSortCalls:
+		return topSortRows
+	case topSortRows:
+		return topSortTotal
+	}
+	return topSortTotal
+}
+
+// topStat is what the top view keeps per fingerprint, much as
+// pg_stat_statements does per statement, over every event received.
+type topStat struct {
+	fingerprint string
+	calls       int
+	total       time.Duration
+	max         time.Duration
+	rows        int64
+	errors      int
+	examples    []int // indexes into Model.events, oldest first
+}
+
+func (s topStat) mean() time.Duration {
+	if s.calls == 0 {
+		return 0
+	}
+	return s.total / time.Duration(s.calls)
+}
+
+// topTickMsg refreshes the top view; ticks from an earlier visit (gen
+// mismatch) are ignored.
+type topTickMsg struct{ gen int }
+
+func topTick(gen int) tea.Cmd {
+	return tea.Tick(topRefresh, func(time.Time) tea.Msg { return topTickMsg{gen: gen} })
+}
+
+// observeTop adds the event at index i of m.events to its fingerprint's
+// totals.
+func (m Model) observeTop(i int) {
+	ev := m.events[i]
+	switch proxy.Op(ev.GetOp()) {
+	case proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpBind, proxy.OpPrepare, proxy.OpCancel, proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice,
+		proxy.OpConnect, proxy.OpDisconnect:
+		return
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+	}
+	if ev.GetQuery() == "" {
+		return
+	}
+	fp := ev.GetFingerprint()
+	if fp == "" { // from a daemon that predates fingerprinting
+		fp = query.Fingerprint(ev.GetQuery())
+	}
+	s, ok := m.topStats[fp]
+	if !ok {
+		s = &topStat{fingerprint: fp}
+		m.topStats[fp] = s
+	}
+	dur := ev.GetDuration().AsDuration()
+	s.calls++
+	s.total += dur
+	s.max = max(s.max, dur)
+	s.rows += ev.GetRowsAffected()
+	if ev.GetError() != "" {
+		s.errors++
+	}
+	s.examples = append(s.examples, i)
+	if len(s.examples) > topExamples {
+		s.examples = s.examples[1:]
+	}
+}
+
+func (m Model) enterTop() (tea.Model, tea.Cmd) {
+	m.view = viewTop
+	m.topGen++
+	m.topCursor = 0
+	m.topDrill = ""
+	m = m.refreshTop()
+	return m, topTick(m.topGen)
+}
+
+// refreshTop snapshots and sorts the totals, keeping the cursor on the
+// fingerprint it was on.
+func (m Model) refreshTop() Model {
+	var current string
+	if m.topCursor < len(m.topRows) {
+		current = m.topRows[m.topCursor].fingerprint
+	}
+	rows := make([]topStat, 0, len(m.topStats))
+	m.topTotal = 0
+	for _, s := range m.topStats {
+		rows = append(rows, *s)
+		m.topTotal += s.total
+	}
+	sortTopRows(rows, m.topSortMode)
+	m.topRows = rows
+	if i := slices.IndexFunc(rows, func(s topStat) bool { return s.fingerprint == current }); i >= 0 {
+		m.topCursor = i
+	}
+	m.topCursor = min(m.topCursor, max(len(m.topRows)-1, 0))
+	return m
+}
+
+func sortTopRows(rows []topStat, mode topSortMode) {
+	slices.SortFunc(rows, func(a, b topStat) int {
+		var c int
+		switch mode {
+		case topSortTotal:
+			c = cmp.Compare(b.total, a.total)
+		case topSortMean:
+			c = cmp.Compare(b.mean(), a.mean())
+		case topSortCalls:
+			c = cmp.Compare(b.calls, a.calls)
+		case topSortRows:
+			c = cmp.Compare(b.rows, a.rows)
+		}
+		return cmp.Or(c, cmp.Compare(b.total, a.total), strings.Compare(a.fingerprint, b.fingerprint))
+	})
+}
+
+// topDrilled returns the totals of the fingerprint being drilled into.
+func (m Model) topDrilled() (topStat, bool) {
+	s, ok := m.topStats[m.topDrill]
+	if !ok {
+		return topStat{}, false
+	}
+	return *s, true
+}
+
+func (m Model) updateTop(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if m.topDrill != "" {
+		return m.updateTopExamples(msg)
+	}
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "q":
+		m.view = viewList
+		m.topGen++ // stop the refresh ticks
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		if m.follow {
+			m.cursor = max(len(m.displayRows)-1, 0)
+		}
+		return m, nil
+	case "j", "down":
+		if m.topCursor < len(m.topRows)-1 {
+			m.topCursor++
+		}
+		return m, nil
+	case "k", "up":
+		if m.topCursor > 0 {
+			m.topCursor--
+		}
+		return m, nil
+	case "s":
+		m.topSortMode = m.topSortMode.next()
+		sortTopRows(m.topRows, m.topSortMode)
+		m.topCursor = 0
+		return m, nil
+	case "enter":
+		if m.topCursor < len(m.topRows) {
+			m.topDrill = m.topRows[m.topCursor].fingerprint
+			m.topExampleCursor = 0
+		}
+		return m, nil
+	case "c":
+		if m.topCursor < len(m.topRows) {
+			_ = clipboard.Copy(context.Background(), m.topRows[m.topCursor].fingerprint)
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+// updateTopExamples handles keys while the recent events of one
+// fingerprint are shown, newest first.
+func (m Model) updateTopExamples(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s, _ := m.topDrilled()
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "q", "esc":
+		m.topDrill = ""
+		return m, nil
+	case "j", "down":
+		if m.topExampleCursor < len(s.examples)-1 {
+			m.topExampleCursor++
+		}
+		return m, nil
+	case "k", "up":
+		if m.topExampleCursor > 0 {
+			m.topExampleCursor--
+		}
+		return m, nil
+	case "enter":
+		if i := len(s.examples) - 1 - m.topExampleCursor; i >= 0 {
+			return m.inspectFromTop(s.examples[i])
+		}
+		return m, nil
+	case "c":
+		if i := len(s.examples) - 1 - m.topExampleCursor; i >= 0 {
+			ev := m.events[s.examples[i]]
+			_ = clipboard.Copy(context.Background(), query.Bind(ev.GetQuery(), ev.GetArgs()))
+		}
+		return m, nil
+	}
+	return m, nil
+}
+
+// inspectFromTop opens the inspector on event i, moving the list cursor to
+// it: its transaction or batch is expanded and, if the search filter hides
+// it, the filter is cleared. Leaving the inspector comes back to the top
+// view.
+func (m Model) inspectFromTop(i int) (tea.Model, tea.Cmd) {
+	ev := m.events[i]
+	for _, id := range []string{ev.GetTxId(), ev.GetBatchId()} {
+		delete(m.collapsed, id)
+	}
+	m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+	row := slices.IndexFunc(m.displayRows, func(r displayRow) bool { return r.kind == rowEvent && r.eventIdx == i })
+	if row < 0 && m.searchQuery != "" {
+		m.searchQuery = ""
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		row = slices.IndexFunc(m.displayRows, func(r displayRow) bool { return r.kind == rowEvent && r.eventIdx == i })
+	}
+	if row < 0 {
+		return m, nil
+	}
+	m.cursor = row
+	m.follow = false
+	m.view = viewInspect
+	m.inspectScroll = 0
+	m.inspectReturnTop = true
+	m.topGen++ // stop the refresh ticks until the inspector returns
+	return m, m.lookupServerLog()
+}
+
+// returnToTop reopens the top view where inspectFromTop left it.
+func (m Model) returnToTop() (tea.Model, tea.Cmd) {
+	m.inspectReturnTop = false
+	m.view = viewTop
+	m.topGen++
+	m = m.refreshTop()
+	return m, topTick(m.topGen)
+}
+
+const (
+	topColCalls = 8
+	topColRows  = 10
+	topColShare = 6
+)
+
+func (m Model) topLines(innerWidth int) ([]string, int) {
+	if len(m.topRows) == 0 {
+		return []string{"No queries yet."}, 0
+	}
+	header := fmt.Sprintf("  %*s %*s %*s %*s %*s %*s  %s",
+		topColCalls, "Calls",
+		colDuration, "Total",
+		colDuration, "Mean",
+		colDuration, "Max",
+		topColRows, "Rows",
+		topColShare, "%Time",
+		"Fingerprint",
+	)
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(header)}
+	colQuery := max(innerWidth-2-topColCalls-3*colDuration-topColRows-topColShare-7, 10)
+	cursorLine := 0
+	for i, s := range m.topRows {
+		marker := "  "
+		if i == m.topCursor {
+			marker = "▶ "
+			cursorLine = len(lines)
+		}
+		share := "-"
+		if m.topTotal > 0 {
+			share = fmt.Sprintf("%.1f", 100*float64(s.total)/float64(m.topTotal))
+		}
+		row := fmt.Sprintf("%s%*d %*s %*s %*s %*d %*s  ",
+			marker,
+			topColCalls, s.calls,
+			colDuration, formatDurationValue(s.total),
+			colDuration, formatDurationValue(s.mean()),
+			colDuration, formatDurationValue(s.max),
+			topColRows, s.rows,
+			topColShare, share,
+		)
+		lines = append(lines, row+highlight.SQL(truncate(s.fingerprint, colQuery)))
+	}
+	return lines, cursorLine
+}
+
+// topExampleLines renders the drilled fingerprint's totals and its recent
+// events, newest first.
+func (m Model) topExampleLines(innerWidth int) ([]string, int) {
+	s, ok := m.topDrilled()
+	if !ok {
+		return []string{"No queries yet."}, 0
+	}
+	bold := lipgloss.NewStyle().Bold(true)
+	lines := []string{
+		highlight.SQL(truncate(s.fingerprint, innerWidth)),
+		fmt.Sprintf("%d calls  total %s  mean %s  max %s  %d rows  %d errors",
+			s.calls, formatDurationValue(s.total), formatDurationValue(s.mean()), formatDurationValue(s.max), s.rows, s.errors),
+		"",
+		bold.Render(fmt.Sprintf("  %-12s %*s %*s  %s", "Time", colDuration, "Duration", topColRows, "Rows", "Args")),
+	}
+	cursorLine := len(lines)
+	colArgs := max(innerWidth-2-12-colDuration-topColRows-4, 10)
+	errStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	for k := range s.examples {
+		ev := m.events[s.examples[len(s.examples)-1-k]]
+		marker := "  "
+		if k == m.topExampleCursor {
+			marker = "▶ "
+			cursorLine = len(lines)
+		}
+		detail := truncate(strings.Join(ev.GetArgs(), ", "), colArgs)
+		if e := ev.GetError(); e != "" {
+			detail = errStyle.Render(truncate("✗ "+e, colArgs))
+		}
+		lines = append(lines, fmt.Sprintf("%s%-12s %*s %*d  %s",
+			marker, formatTime(ev.GetStartTime()), colDuration, formatDuration(ev.GetDuration()),
+			topColRows, ev.GetRowsAffected(), detail))
+	}
+	return lines, cursorLine
+}
+
+func (m Model) renderTop() string {
+	innerWidth := max(m.width-4, 20)
+	visibleRows := max(m.height-2, 3) // -2 for top/bottom border
+
+	lines, cursorLine := m.topLines(innerWidth)
+	title := fmt.Sprintf(" Top (%d fingerprints) [sort: %s] ", len(m.topRows), m.topSortMode)
+	help := " q: back  j/k: move  s: sort  enter: recent events  c: copy fingerprint "
+	if m.topDrill != "" {
+		s, _ := m.topDrilled()
+		lines, cursorLine = m.topExampleLines(innerWidth)
+		title = fmt.Sprintf(" Top › recent events (%d) ", len(s.examples))
+		help = " q/esc: back  j/k: move  enter: inspect  c: copy with args "
+	}
+	start := 0
+	if len(lines) > visibleRows {
+		start = min(max(cursorLine-visibleRows/2, 0), len(lines)-visibleRows)
+	}
+	end := min(start+visibleRows, len(lines))
+
+	borderColor := lipgloss.Color("240")
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(borderColor).
+		Render(strings.Join(lines[start:end], "\n"))
+
+	boxLines := strings.Split(box, "\n")
+	if len(boxLines) > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		dashes := max(innerWidth-len([]rune(title)), 0)
+		boxLines[0] = borderFg.Render("╭") +
+			lipgloss.NewStyle().Bold(true).Render(title) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╮")
+	}
+	if n := len(boxLines); n > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		dashes := max(innerWidth-len([]rune(help)), 0)
+		boxLines[n-1] = borderFg.Render("╰") +
+			lipgloss.NewStyle().Faint(true).Render(help) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╯")
+	}
+	return strings.Join(boxLines, "\n")
+}
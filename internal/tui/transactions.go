@@ -0,0 +1,277 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/highlight"
+)
+
+// txListLimit is how many transactions the transactions view requests.
+const txListLimit = 500
+
+// txResultMsg carries the result of a Transactions call.
+type txResultMsg struct {
+	txs []*tapv1.Transaction
+	err error
+}
+
+func fetchTransactions(client tapv1.TapServiceClient) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := client.Transactions(context.Background(), &tapv1.TransactionsRequest{Limit: txListLimit})
+		if err != nil {
+			return txResultMsg{err: err}
+		}
+		return txResultMsg{txs: groupBranches(resp.GetTransactions())}
+	}
+}
+
+// groupBranches moves the branches of each distributed transaction next to
+// its newest branch, keeping the newest-first order otherwise.
+func groupBranches(txs []*tapv1.Transaction) []*tapv1.Transaction {
+	byGlobal := make(map[string][]*tapv1.Transaction)
+	for _, tx := range txs {
+		if gid := tx.GetGlobalTxId(); gid != "" {
+			byGlobal[gid] = append(byGlobal[gid], tx)
+		}
+	}
+	out := make([]*tapv1.Transaction, 0, len(txs))
+	for _, tx := range txs {
+		gid := tx.GetGlobalTxId()
+		if gid == "" {
+			out = append(out, tx)
+			continue
+		}
+		if branches, ok := byGlobal[gid]; ok {
+			out = append(out, branches...)
+			delete(byGlobal, gid)
+		}
+	}
+	return out
+}
+
+func (m Model) enterTransactions() (tea.Model, tea.Cmd) {
+	if m.client == nil {
+		return m, nil
+	}
+	m.view = viewTransactions
+	m.txLoading = true
+	m.txErr = nil
+	m.txCursor = 0
+	return m, fetchTransactions(m.client)
+}
+
+func (m Model) updateTransactions(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m.quit()
+	case "q":
+		m.view = viewList
+		m.displayRows, m.txColorMap = m.rebuildDisplayRows()
+		if m.follow {
+			m.cursor = max(len(m.displayRows)-1, 0)
+		}
+		return m, nil
+	case "j", "down":
+		if m.txCursor < len(m.txs)-1 {
+			m.txCursor++
+		}
+		return m, nil
+	case "k", "up":
+		if m.txCursor > 0 {
+			m.txCursor--
+		}
+		return m, nil
+	case "enter", " ":
+		if m.txCursor < len(m.txs) {
+			id := m.txs[m.txCursor].GetTxId()
+			m.txExpanded[id] = !m.txExpanded[id]
+		}
+		return m, nil
+	case "r":
+		m.txLoading = true
+		return m, fetchTransactions(m.client)
+	}
+	return m, nil
+}
+
+func (m Model) txVisibleRows() int {
+	return max(m.height-2, 3) // -2 for top/bottom border
+}
+
+// txLines renders the transaction list and returns the line index of the
+// cursor's transaction.
+func (m Model) txLines(innerWidth int) ([]string, int) {
+	if m.txErr != nil {
+		return []string{"Error: " + m.txErr.Error()}, 0
+	}
+	if m.txLoading && len(m.txs) == 0 {
+		return []string{"Loading transactions..."}, 0
+	}
+	if len(m.txs) == 0 {
+		return []string{"No transactions captured yet."}, 0
+	}
+
+	header := fmt.Sprintf("    %-11s %*s %*s %7s  %s",
+		"Status", colDuration, "Duration", colTime, "Start", "Queries", "Conn")
+	lines := []string{lipgloss.NewStyle().Bold(true).Render(header)}
+	cursorLine := 0
+	bold := lipgloss.NewStyle().Bold(true)
+
+	for i, tx := range m.txs {
+		if gid := tx.GetGlobalTxId(); gid != "" && (i == 0 || m.txs[i-1].GetGlobalTxId() != gid) {
+			lines = append(lines, m.globalTxHeader(i))
+		}
+
+		marker := "  "
+		if i == m.txCursor {
+			marker = "▶ "
+			cursorLine = len(lines)
+		}
+		chevron := "▸ "
+		if m.txExpanded[tx.GetTxId()] {
+			chevron = "▾ "
+		}
+
+		conn := tx.GetConnId()
+		if tx.GetUpstream() != "" {
+			conn = tx.GetUpstream() + "/" + conn
+		}
+		queries := fmt.Sprintf("%d", len(tx.GetEvents()))
+		if tx.GetTruncated() {
+			queries += "+"
+		}
+
+		status := padRight(m.txStatusStyle(tx.GetStatus()).Render(txStatusString(tx.GetStatus())), 11)
+		row := fmt.Sprintf("%s%s%s %*s %*s %7s  %s",
+			marker, chevron, status,
+			colDuration, formatDuration(tx.GetDuration()),
+			colTime, formatTime(tx.GetStartTime()),
+			queries, conn)
+		if i == m.txCursor {
+			row = bold.Render(row)
+		}
+		lines = append(lines, row)
+
+		if !m.txExpanded[tx.GetTxId()] {
+			continue
+		}
+		start := tx.GetStartTime().AsTime()
+		maxQueryLen := max(innerWidth-34, 10)
+		for _, ev := range tx.GetEvents() {
+			offset := ev.GetStartTime().AsTime().Sub(start)
+			q := ev.GetQuery()
+			if q == "" {
+				q = "-"
+			}
+			lines = append(lines, fmt.Sprintf("      +%-9s %-8s %*s  %s",
+				formatDurationValue(offset),
+				opString(ev.GetOp()),
+				colDuration, formatDuration(ev.GetDuration()),
+				highlight.SQL(truncate(q, maxQueryLen))))
+		}
+	}
+	return lines, cursorLine
+}
+
+// globalTxHeader summarizes the distributed transaction whose branches start
+// at m.txs[i].
+func (m Model) globalTxHeader(i int) string {
+	gid := m.txs[i].GetGlobalTxId()
+	status := m.txs[i].GetStatus()
+	n := 0
+	for _, tx := range m.txs[i:] {
+		if tx.GetGlobalTxId() != gid {
+			break
+		}
+		if tx.GetStatus() != status {
+			status = tapv1.TxStatus_TX_STATUS_UNSPECIFIED
+		}
+		n++
+	}
+	summary := "mixed"
+	if status != tapv1.TxStatus_TX_STATUS_UNSPECIFIED {
+		summary = m.txStatusStyle(status).Render(txStatusString(status))
+	}
+	return lipgloss.NewStyle().Foreground(lipgloss.Color("6")).Render(fmt.Sprintf("  ⇄ global %s", gid)) +
+		fmt.Sprintf(" · %d branch(es) · %s", n, summary)
+}
+
+func txStatusString(s tapv1.TxStatus) string {
+	switch s {
+	case tapv1.TxStatus_TX_STATUS_OPEN:
+		return "open"
+	case tapv1.TxStatus_TX_STATUS_COMMITTED:
+		return "committed"
+	case tapv1.TxStatus_TX_STATUS_ROLLED_BACK:
+		return "rolled back"
+	case tapv1.TxStatus_TX_STATUS_PREPARED:
+		return "prepared"
+	case tapv1.TxStatus_TX_STATUS_UNSPECIFIED:
+	}
+	return "unknown"
+}
+
+func (m Model) txStatusStyle(s tapv1.TxStatus) lipgloss.Style {
+	switch s {
+	case tapv1.TxStatus_TX_STATUS_OPEN:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	case tapv1.TxStatus_TX_STATUS_COMMITTED:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	case tapv1.TxStatus_TX_STATUS_ROLLED_BACK:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+	case tapv1.TxStatus_TX_STATUS_PREPARED:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("5"))
+	case tapv1.TxStatus_TX_STATUS_UNSPECIFIED:
+	}
+	return lipgloss.NewStyle()
+}
+
+func (m Model) renderTransactions() string {
+	innerWidth := max(m.width-4, 20)
+	visibleRows := m.txVisibleRows()
+
+	lines, cursorLine := m.txLines(innerWidth)
+	start := 0
+	if len(lines) > visibleRows {
+		start = min(max(cursorLine-visibleRows/2, 0), len(lines)-visibleRows)
+	}
+	end := min(start+visibleRows, len(lines))
+	content := strings.Join(lines[start:end], "\n")
+
+	borderColor := lipgloss.Color("240")
+	box := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		Width(innerWidth).
+		BorderForeground(borderColor).
+		Render(content)
+
+	boxLines := strings.Split(box, "\n")
+	if len(boxLines) > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		title := fmt.Sprintf(" Transactions (%d) ", len(m.txs))
+		if m.txLoading {
+			title += "[loading] "
+		}
+		dashes := max(innerWidth-len([]rune(title)), 0)
+		boxLines[0] = borderFg.Render("╭") +
+			lipgloss.NewStyle().Bold(true).Render(title) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╮")
+	}
+
+	if n := len(boxLines); n > 0 {
+		borderFg := lipgloss.NewStyle().Foreground(borderColor)
+		help := " q: back  j/k: move  enter/space: expand  r: refresh "
+		dashes := max(innerWidth-len([]rune(help)), 0)
+		boxLines[n-1] = borderFg.Render("╰") +
+			lipgloss.NewStyle().Faint(true).Render(help) +
+			borderFg.Render(strings.Repeat("─", dashes)+"╯")
+	}
+
+	return strings.Join(boxLines, "\n")
+}
@@ -0,0 +1,243 @@
+package txtrack
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// Status is the lifecycle state of a tracked transaction.
+type Status int
+
+const (
+	StatusOpen Status = iota
+	StatusCommitted
+	StatusRolledBack
+	StatusPrepared // two-phase commit branch awaiting COMMIT/ROLLBACK PREPARED
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOpen:
+		return "open"
+	case StatusCommitted:
+		return "committed"
+	case StatusRolledBack:
+		return "rolled back"
+	case StatusPrepared:
+		return "prepared"
+	}
+	return "unknown"
+}
+
+// MaxEventsPerTx caps the events retained for a single transaction.
+const MaxEventsPerTx = 500
+
+// Tx is a transaction assembled from the events sharing its TxID.
+type Tx struct {
+	ID        string
+	GlobalID  string // distributed transaction id for two-phase commit branches
+	ConnID    string
+	Upstream  string
+	Status    Status
+	StartTime time.Time
+	EndTime   time.Time // zero while open
+	Events    []proxy.Event
+	Truncated bool // more than MaxEventsPerTx events were seen
+}
+
+// Duration returns the time from BEGIN to COMMIT/ROLLBACK, or to the last
+// seen event while the transaction is still open.
+func (t Tx) Duration() time.Duration {
+	end := t.EndTime
+	if end.IsZero() && len(t.Events) > 0 {
+		last := t.Events[len(t.Events)-1]
+		end = last.StartTime.Add(last.Duration)
+	}
+	if end.IsZero() {
+		return 0
+	}
+	return end.Sub(t.StartTime)
+}
+
+// Tracker groups events into transactions, retaining the most recent ones.
+type Tracker struct {
+	mu       sync.Mutex
+	capacity int
+	open     map[string]*Tx
+	prepared map[string]*Tx // keyed by preparedKey
+	done     []*Tx          // finished transactions, oldest first
+}
+
+// New creates a Tracker that keeps up to capacity finished transactions and
+// up to capacity open ones.
+func New(capacity int) *Tracker {
+	return &Tracker{
+		capacity: max(capacity, 1),
+		open:     make(map[string]*Tx),
+		prepared: make(map[string]*Tx),
+	}
+}
+
+// Observe feeds an event to the tracker. Events without a TxID are ignored,
+// except COMMIT/ROLLBACK PREPARED and XA COMMIT/ROLLBACK, which finish a
+// prepared branch seen earlier on the same upstream.
+func (t *Tracker) Observe(ev proxy.Event) {
+	var tp proxy.TwoPhase
+	if ev.GlobalTxID != "" {
+		tp, _ = proxy.ParseTwoPhase(ev.Query)
+	}
+	if ev.TxID == "" && tp.Kind != proxy.TwoPhaseCommit && tp.Kind != proxy.TwoPhaseRollback {
+		return
+	}
+	if ev.Op == proxy.OpBatch {
+		return // repeats the statements already added
+	}
+	if ev.Op == proxy.OpNotice {
+		return // a message about a statement, not one
+	}
+
+	// Row samples and result columns are for the inspector; transactions
+	// only need the summary.
+	ev.RowSamples, ev.Columns = nil, nil
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if ev.TxID == "" {
+		t.resolvePrepared(ev, tp)
+		return
+	}
+
+	tx, ok := t.open[ev.TxID]
+	if !ok {
+		tx = &Tx{
+			ID:        ev.TxID,
+			ConnID:    ev.ConnID,
+			Upstream:  ev.Upstream,
+			StartTime: ev.StartTime,
+		}
+		t.open[ev.TxID] = tx
+		evictOldest(t.open, t.capacity)
+	}
+
+	if ev.GlobalTxID != "" {
+		tx.GlobalID = ev.GlobalTxID
+	}
+	tx.add(ev)
+
+	if tp.Kind == proxy.TwoPhasePrepare {
+		delete(t.open, ev.TxID)
+		if ev.Error != "" {
+			// A failed PREPARE TRANSACTION rolls the transaction back.
+			t.finish(tx, StatusRolledBack, ev)
+			return
+		}
+		tx.Status = StatusPrepared
+		t.prepared[preparedKey(ev.Upstream, tp.XID)] = tx
+		evictOldest(t.prepared, t.capacity)
+		return
+	}
+
+	switch ev.Op {
+	case proxy.OpCommit, proxy.OpRollback, proxy.OpDisconnect:
+		delete(t.open, ev.TxID)
+		status := StatusCommitted
+		if ev.Op != proxy.OpCommit {
+			// The server rolls back a transaction its client disconnects from.
+			status = StatusRolledBack
+		}
+		t.finish(tx, status, ev)
+	case proxy.OpQuery, proxy.OpExec, proxy.OpPrepare, proxy.OpBind, proxy.OpExecute, proxy.OpBegin, proxy.OpCancel,
+		proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice, proxy.OpConnect:
+	}
+}
+
+// resolvePrepared finishes the prepared branch that ev commits or rolls back.
+func (t *Tracker) resolvePrepared(ev proxy.Event, tp proxy.TwoPhase) {
+	key := preparedKey(ev.Upstream, tp.XID)
+	tx, ok := t.prepared[key]
+	if !ok || ev.Error != "" {
+		return
+	}
+	delete(t.prepared, key)
+	tx.add(ev)
+	status := StatusCommitted
+	if tp.Kind == proxy.TwoPhaseRollback {
+		status = StatusRolledBack
+	}
+	t.finish(tx, status, ev)
+}
+
+// finish moves tx, already removed from open or prepared, to the done ring.
+func (t *Tracker) finish(tx *Tx, status Status, last proxy.Event) {
+	tx.Status = status
+	tx.EndTime = last.StartTime.Add(last.Duration)
+	t.done = append(t.done, tx)
+	if len(t.done) > t.capacity {
+		t.done = slices.Delete(t.done, 0, len(t.done)-t.capacity)
+	}
+}
+
+func (tx *Tx) add(ev proxy.Event) {
+	if len(tx.Events) < MaxEventsPerTx {
+		tx.Events = append(tx.Events, ev)
+	} else {
+		tx.Truncated = true
+	}
+}
+
+// preparedKey identifies a prepared branch: xids are unique per upstream.
+func preparedKey(upstream, xid string) string {
+	return upstream + "\x00" + xid
+}
+
+// evictOldest drops the oldest transaction from txs once it holds more than
+// capacity, so connections that vanish mid-transaction and branches that are
+// never resolved do not leak.
+func evictOldest(txs map[string]*Tx, capacity int) {
+	if len(txs) <= capacity {
+		return
+	}
+	var oldestKey string
+	var oldest *Tx
+	for k, tx := range txs {
+		if oldest == nil || tx.StartTime.Before(oldest.StartTime) {
+			oldestKey, oldest = k, tx
+		}
+	}
+	delete(txs, oldestKey)
+}
+
+// Transactions returns up to limit transactions, newest first. A limit of 0
+// or less returns all of them. The returned values are copies.
+func (t *Tracker) Transactions(limit int) []Tx {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Tx, 0, len(t.open)+len(t.prepared)+len(t.done))
+	for _, tx := range t.open {
+		out = append(out, copyTx(tx))
+	}
+	for _, tx := range t.prepared {
+		out = append(out, copyTx(tx))
+	}
+	for _, tx := range t.done {
+		out = append(out, copyTx(tx))
+	}
+	slices.SortStableFunc(out, func(a, b Tx) int {
+		return b.StartTime.Compare(a.StartTime)
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out
+}
+
+func copyTx(tx *Tx) Tx {
+	c := *tx
+	c.Events = slices.Clone(tx.Events)
+	return c
+}
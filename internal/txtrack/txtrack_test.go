@@ -0,0 +1,180 @@
+package txtrack_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/txtrack"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+var base = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func at(ms int) time.Time { return base.Add(time.Duration(ms) * time.Millisecond) }
+
+func TestTracker_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(10)
+	tr.Observe(proxy.Event{ID: "0", Op: proxy.OpQuery, Query: "SELECT 1", StartTime: at(0)}) // no tx
+	tr.Observe(proxy.Event{ID: "1", TxID: "a", ConnID: "7", Op: proxy.OpBegin, StartTime: at(10)})
+	tr.Observe(proxy.Event{ID: "2", TxID: "a", Op: proxy.OpExec, Query: "UPDATE t", StartTime: at(12), Duration: 3 * time.Millisecond})
+	tr.Observe(proxy.Event{ID: "2s", TxID: "a", Op: proxy.OpBatch, BatchID: "x", StartTime: at(12)}) // repeats 2
+	tr.Observe(proxy.Event{ID: "3", TxID: "b", Op: proxy.OpBegin, StartTime: at(20)})
+	tr.Observe(proxy.Event{ID: "4", TxID: "a", Op: proxy.OpCommit, StartTime: at(30), Duration: time.Millisecond})
+
+	got := tr.Transactions(0)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(got))
+	}
+
+	open, committed := got[0], got[1] // newest first
+	if open.ID != "b" || open.Status != txtrack.StatusOpen || !open.EndTime.IsZero() {
+		t.Errorf("unexpected open tx: %+v", open)
+	}
+	if committed.ID != "a" || committed.Status != txtrack.StatusCommitted || committed.ConnID != "7" {
+		t.Errorf("unexpected committed tx: %+v", committed)
+	}
+	if len(committed.Events) != 3 {
+		t.Errorf("expected 3 events in tx a, got %d", len(committed.Events))
+	}
+	if d := committed.Duration(); d != 21*time.Millisecond {
+		t.Errorf("Duration() = %v, want 21ms", d)
+	}
+}
+
+func TestTracker_Rollback(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(10)
+	tr.Observe(proxy.Event{TxID: "a", Op: proxy.OpBegin, StartTime: at(0)})
+	tr.Observe(proxy.Event{TxID: "a", Op: proxy.OpRollback, StartTime: at(5)})
+
+	got := tr.Transactions(0)
+	if len(got) != 1 || got[0].Status != txtrack.StatusRolledBack {
+		t.Fatalf("unexpected transactions: %+v", got)
+	}
+	if s := got[0].Status.String(); s != "rolled back" {
+		t.Errorf("Status.String() = %q", s)
+	}
+}
+
+func TestTracker_Disconnect(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(10)
+	tr.Observe(proxy.Event{TxID: "a", Op: proxy.OpBegin, StartTime: at(10)})
+	tr.Observe(proxy.Event{Op: proxy.OpDisconnect, StartTime: at(0), Duration: 20 * time.Millisecond}) // another conn
+	tr.Observe(proxy.Event{TxID: "a", Op: proxy.OpDisconnect, StartTime: at(0), Duration: 30 * time.Millisecond})
+
+	got := tr.Transactions(0)
+	if len(got) != 1 || got[0].Status != txtrack.StatusRolledBack {
+		t.Fatalf("unexpected transactions: %+v", got)
+	}
+	if !got[0].EndTime.Equal(at(30)) {
+		t.Errorf("EndTime = %v, want the disconnect at %v", got[0].EndTime, at(30))
+	}
+}
+
+func TestTracker_Capacity(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(2)
+	for i, id := range []string{"a", "b", "c"} {
+		tr.Observe(proxy.Event{TxID: id, Op: proxy.OpBegin, StartTime: at(i * 10)})
+		tr.Observe(proxy.Event{TxID: id, Op: proxy.OpCommit, StartTime: at(i*10 + 1)})
+	}
+	for i, id := range []string{"x", "y", "z"} {
+		tr.Observe(proxy.Event{TxID: id, Op: proxy.OpBegin, StartTime: at(100 + i)})
+	}
+
+	got := tr.Transactions(0)
+	ids := make([]string, len(got))
+	for i, tx := range got {
+		ids[i] = tx.ID
+	}
+	want := []string{"z", "y", "c", "b"}
+	if len(ids) != len(want) {
+		t.Fatalf("ids = %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("ids = %v, want %v", ids, want)
+		}
+	}
+
+	if got := tr.Transactions(1); len(got) != 1 || got[0].ID != "z" {
+		t.Fatalf("limit 1: got %+v", got)
+	}
+}
+
+func TestTracker_Truncated(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(1)
+	tr.Observe(proxy.Event{TxID: "a", Op: proxy.OpBegin, StartTime: at(0)})
+	for range txtrack.MaxEventsPerTx {
+		tr.Observe(proxy.Event{TxID: "a", Op: proxy.OpExec, StartTime: at(1)})
+	}
+
+	got := tr.Transactions(0)[0]
+	if !got.Truncated || len(got.Events) != txtrack.MaxEventsPerTx {
+		t.Fatalf("expected truncated tx with %d events, got %d (truncated=%v)", txtrack.MaxEventsPerTx, len(got.Events), got.Truncated)
+	}
+}
+
+func TestTracker_TwoPhase(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(10)
+	for i, up := range []string{"orders", "billing"} {
+		txID := up + "-tx"
+		tr.Observe(proxy.Event{TxID: txID, Upstream: up, Op: proxy.OpBegin, Query: "BEGIN", StartTime: at(i)})
+		tr.Observe(proxy.Event{TxID: txID, Upstream: up, Op: proxy.OpExec, Query: "UPDATE t", StartTime: at(i + 2)})
+		tr.Observe(proxy.Event{
+			TxID: txID, GlobalTxID: "g1", Upstream: up, Op: proxy.OpQuery,
+			Query: "PREPARE TRANSACTION 'g1'", StartTime: at(i + 4),
+		})
+	}
+
+	for _, tx := range tr.Transactions(0) {
+		if tx.Status != txtrack.StatusPrepared || tx.GlobalID != "g1" {
+			t.Fatalf("expected prepared branch of g1, got %+v", tx)
+		}
+	}
+
+	// Resolved from other sessions, so without a TxID.
+	tr.Observe(proxy.Event{GlobalTxID: "g1", Upstream: "orders", Op: proxy.OpCommit, Query: "COMMIT PREPARED 'g1'", StartTime: at(50)})
+	tr.Observe(proxy.Event{GlobalTxID: "g1", Upstream: "billing", Op: proxy.OpRollback, Query: "ROLLBACK PREPARED 'g1'", StartTime: at(60)})
+	// Unknown xid: ignored.
+	tr.Observe(proxy.Event{GlobalTxID: "g2", Upstream: "orders", Op: proxy.OpCommit, Query: "COMMIT PREPARED 'g2'", StartTime: at(70)})
+
+	got := tr.Transactions(0)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(got))
+	}
+	want := map[string]txtrack.Status{"orders": txtrack.StatusCommitted, "billing": txtrack.StatusRolledBack}
+	for _, tx := range got {
+		if tx.Status != want[tx.Upstream] {
+			t.Errorf("%s: expected %s, got %s", tx.Upstream, want[tx.Upstream], tx.Status)
+		}
+		if len(tx.Events) != 4 {
+			t.Errorf("%s: expected 4 events, got %d", tx.Upstream, len(tx.Events))
+		}
+	}
+}
+
+func TestTracker_XAOnePhase(t *testing.T) {
+	t.Parallel()
+
+	tr := txtrack.New(10)
+	tr.Observe(proxy.Event{TxID: "x", GlobalTxID: "g", Op: proxy.OpBegin, Query: "XA START 'g'", StartTime: at(0)})
+	tr.Observe(proxy.Event{TxID: "x", Op: proxy.OpExec, Query: "INSERT INTO t VALUES (1)", StartTime: at(1)})
+	tr.Observe(proxy.Event{TxID: "x", GlobalTxID: "g", Op: proxy.OpQuery, Query: "XA END 'g'", StartTime: at(2)})
+	tr.Observe(proxy.Event{TxID: "x", GlobalTxID: "g", Op: proxy.OpCommit, Query: "XA COMMIT 'g' ONE PHASE", StartTime: at(3)})
+
+	got := tr.Transactions(0)
+	if len(got) != 1 || got[0].Status != txtrack.StatusCommitted || got[0].GlobalID != "g" {
+		t.Fatalf("unexpected transactions: %+v", got)
+	}
+}
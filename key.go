@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/archive"
+	"github.com/mickamy/sql-tap/internal/encrypt"
+	"github.com/mickamy/sql-tap/internal/kms"
+)
+
+// kmsUsage is the usage of the -key-kms flag.
+const kmsUsage = "KMS key that wrapped the key in -key-env: aws-kms://<arn> or gcp-kms://<name>"
+
+// readKey returns the key held by the environment variable env, or nil if
+// it is not set. With kmsURI, the variable holds a data key wrapped by that
+// KMS key, as with sql-tapd's key_kms, and it is unwrapped.
+func readKey(env, kmsURI string) ([]byte, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	key, err := kms.Key(ctx, v, kmsURI)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", env, err)
+	}
+	return key, nil
+}
+
+// openArchive opens an archive as archive.Open does. An encrypted one that
+// ends without its final frame, as the day's file does while sql-tapd writes
+// it, reads up to its last frame, with a warning.
+func openArchive(path string, key []byte) (io.ReadCloser, error) {
+	rc, err := archive.Open(path, key)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // archive errors name the path
+	}
+	return &unfinishedReader{ReadCloser: rc, path: path}, nil
+}
+
+type unfinishedReader struct {
+	io.ReadCloser
+	path   string
+	warned bool
+}
+
+func (r *unfinishedReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if errors.Is(err, encrypt.ErrUnfinished) {
+		if !r.warned {
+			fmt.Fprintf(os.Stderr, "Warning: %s ends without its final frame: still being written, or truncated\n", r.path)
+			r.warned = true
+		}
+		err = io.EOF
+	}
+	return n, err //nolint:wrapcheck // passes the archive's errors through
+}
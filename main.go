@@ -7,21 +7,82 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 
-	"github.com/mickamy/sql-tap/tui"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/agent"
+	"github.com/mickamy/sql-tap/internal/pglog"
+	"github.com/mickamy/sql-tap/internal/sample"
+	"github.com/mickamy/sql-tap/internal/tui"
 )
 
 var version = "dev"
 
 func main() {
-	fs := flag.NewFlagSet("sql-tap", flag.ExitOnError)
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "watch":
+			watchCmd(os.Args[2:])
+			return
+		case "tail":
+			tailCmd(os.Args[2:])
+			return
+		case "agent":
+			agent.Main("sql-tap agent", version, os.Args[2:])
+			return
+		case "cat":
+			catCmd(os.Args[2:])
+			return
+		case "verify":
+			verifyCmd(os.Args[2:])
+			return
+		case "query":
+			queryCmd(os.Args[2:])
+			return
+		case "routes":
+			routesCmd(os.Args[2:])
+			return
+		case "tenants":
+			tenantsCmd(os.Args[2:])
+			return
+		case "databases":
+			databasesCmd(os.Args[2:])
+			return
+		case "config":
+			configCmd(os.Args[2:])
+			return
+		case "diff":
+			diffCmd(os.Args[2:])
+			return
+		case "replay":
+			replayCmd(os.Args[2:])
+			return
+		case "attach":
+			attachCmd("sql-tap attach", os.Args[2:])
+			return
+		}
+	}
+	attachCmd("sql-tap", os.Args[1:])
+}
+
+// attachCmd opens the TUI on the agent at the address in args.
+func attachCmd(prog string, args []string) {
+	fs := flag.NewFlagSet(prog, flag.ExitOnError)
 	fs.Usage = func() {
-		fmt.Fprintf(os.Stderr, "sql-tap — Watch SQL traffic in real-time\n\nUsage:\n  sql-tap [flags] <addr>\n\nFlags:\n")
+		fmt.Fprintf(os.Stderr, "sql-tap — Watch SQL traffic in real-time\n\nUsage:\n  sql-tap [flags] <addr>\n  sql-tap attach [flags] <addr>\n  sql-tap agent [flags]\n  sql-tap watch [flags] <addr>\n  sql-tap tail [flags] <addr>\n  sql-tap cat [flags] <file>...\n  sql-tap verify [flags] <file>...\n  sql-tap query [flags] <addr|store file>\n  sql-tap routes [flags] <addr>\n  sql-tap tenants [flags] <addr>\n  sql-tap databases [flags] <addr>\n  sql-tap config show [flags] <addr>\n  sql-tap diff [flags] <before> <after>\n  sql-tap replay [flags] <file>...\n\nFlags:\n")
 		fs.PrintDefaults()
 	}
 
+	lossless := fs.Bool("lossless", false, "stall event publishing instead of dropping events when the TUI falls behind")
+	spill := fs.Bool("spill", false, "queue events on the daemon's disk instead of dropping them when the TUI falls behind")
+	statePath := fs.String("state", tui.DefaultStatePath(), "session state file (filters, sort, view); empty disables")
+	tokenEnv := fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled")
+	pgLog := fs.String("pg-log", "", "glob of PostgreSQL csvlog files to match inspected events against (e.g. /var/lib/postgresql/data/log/*.csv)")
+	maxEvents := fs.Int("max-events", 0, "keep at most this many events in the TUI's history, evicting the oldest; 0 is unlimited")
+	maxMemory := fs.Int("max-memory", tui.DefaultMaxMemory>>20, "cap the memory of the TUI's history in MB, evicting the oldest events; 0 is unlimited")
+	sampleSpec := fs.String("sample", "", "ask the daemon to sample events: rate=<0..1>,per-fingerprint=<n>,max-per-second=<n> (any subset)")
+	noTUI := fs.Bool("no-tui", false, "print events to stdout as plain lines instead of opening the TUI, as sql-tap tail does")
 	showVersion := fs.Bool("version", false, "show version and exit")
 
-	_ = fs.Parse(os.Args[1:])
+	_ = fs.Parse(args)
 
 	if *showVersion {
 		fmt.Printf("sql-tap %s\n", version)
@@ -33,11 +94,58 @@ func main() {
 		os.Exit(1)
 	}
 
-	monitor(fs.Arg(0))
+	sampling, err := sample.Parse(*sampleSpec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	delivery, err := deliveryFlags(*lossless, *spill)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *noTUI {
+		if err := watch(fs.Arg(0), tailWriter(false, false), delivery, sampling, nil, os.Getenv(*tokenEnv)); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *maxEvents < 0 || *maxMemory < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -max-events and -max-memory must not be negative")
+		os.Exit(1)
+	}
+
+	opts := []tui.Option{tui.WithMaxEvents(*maxEvents), tui.WithMaxMemory(int64(*maxMemory) << 20)}
+	if sampling.Enabled() {
+		opts = append(opts, tui.WithSampling(sampling))
+	}
+	if *statePath != "" {
+		opts = append(opts, tui.WithStateFile(*statePath))
+	}
+	switch delivery {
+	case tapv1.Delivery_DELIVERY_UNSPECIFIED:
+	case tapv1.Delivery_DELIVERY_BLOCK:
+		opts = append(opts, tui.WithLossless())
+	case tapv1.Delivery_DELIVERY_SPILL:
+		opts = append(opts, tui.WithSpill())
+	}
+	if *pgLog != "" {
+		c, err := pglog.NewCorrelator(*pgLog)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts = append(opts, tui.WithServerLog(c))
+	}
+	if tok := os.Getenv(*tokenEnv); tok != "" {
+		opts = append(opts, tui.WithToken(tok))
+	}
+	monitor(fs.Arg(0), opts...)
 }
 
-func monitor(addr string) {
-	m := tui.New(addr)
+func monitor(addr string, opts ...tui.Option) {
+	m := tui.New(addr, opts...)
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// Diagnostic is a problem a proxy logged while handling a connection, such
+// as a failed relay or a cancel that could not be sent, reported on an
+// OpAdvisory event so that it reaches the TUI rather than only the log.
+type Diagnostic struct {
+	Level   slog.Level
+	Message string // the log message, e.g. "relay failed"; the event's Error is the cause
+}
+
+var diagnostics atomic.Uint64
+
+// Diagnostics returns how many diagnostics Diagnose has reported.
+func Diagnostics() uint64 {
+	return diagnostics.Load()
+}
+
+// Diagnose logs msg at level through logger, with err and the connection ev
+// identifies, and returns ev as an OpAdvisory event carrying them.
+func Diagnose(logger *slog.Logger, level slog.Level, msg string, err error, ev Event) Event {
+	n := diagnostics.Add(1)
+	logger.Log(context.Background(), level, msg, "conn_id", ev.ConnID, "client", ev.ClientAddr, "err", err)
+
+	ev.ID = "diag-" + strconv.FormatUint(n, 10)
+	ev.Op = OpAdvisory
+	ev.StartTime = time.Now()
+	ev.Duration = 0
+	ev.Error = err.Error()
+	ev.ErrorDetail = nil
+	ev.Diagnostic = &Diagnostic{Level: level, Message: msg}
+	return ev
+}
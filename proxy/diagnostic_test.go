@@ -0,0 +1,40 @@
+package proxy_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestDiagnose(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	before := proxy.Diagnostics()
+	ev := proxy.Diagnose(logger, slog.LevelWarn, "relay failed", errors.New("tls: bad certificate"), proxy.Event{
+		ID: "7", Op: proxy.OpQuery, ConnID: "c1", ClientAddr: "10.0.0.1:5000", User: "app",
+	})
+
+	if ev.Op != proxy.OpAdvisory || !strings.HasPrefix(ev.ID, "diag-") || ev.ConnID != "c1" || ev.User != "app" {
+		t.Fatalf("unexpected diagnostic: %+v", ev)
+	}
+	if ev.Error != "tls: bad certificate" {
+		t.Errorf("error = %q", ev.Error)
+	}
+	if d := ev.Diagnostic; d == nil || d.Level != slog.LevelWarn || d.Message != "relay failed" {
+		t.Fatalf("unexpected diagnostic: %+v", d)
+	}
+	for _, want := range []string{"level=WARN", `msg="relay failed"`, "conn_id=c1", "client=10.0.0.1:5000", `err="tls: bad certificate"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log %q does not contain %q", buf.String(), want)
+		}
+	}
+	if got := proxy.Diagnostics(); got <= before {
+		t.Errorf("Diagnostics() = %d, want more than %d", got, before)
+	}
+}
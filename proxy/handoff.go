@@ -0,0 +1,228 @@
+package proxy
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ListenFDsEnv names the environment variable through which a process
+// started by an upgrade inherits its predecessor's listeners: one "fd addr"
+// line per listener, as ListenerFiles returns them. Listen takes over an
+// inherited listener for the same addr instead of binding a new one, so
+// clients connecting meanwhile wait in its backlog rather than being
+// refused.
+const ListenFDsEnv = "SQL_TAP_LISTEN_FDS"
+
+// listeners are the listeners Listen returned that are still open.
+var listeners = &registry{open: make(map[*listener]struct{})}
+
+type registry struct {
+	mu      sync.Mutex
+	open    map[*listener]struct{}
+	conns   int           // accepted connections not yet closed
+	drained chan struct{} // made by Release, closed once conns is 0
+
+	inheritOnce sync.Once
+	inherited   map[string]*os.File // by listen address
+}
+
+// ListenerFiles returns duplicates of the open listeners' files, for a new
+// process to inherit as its file descriptors first, first+1, and so on, and
+// the ListenFDsEnv value naming them. The caller closes the files once the
+// process has started.
+func ListenerFiles(first int) ([]*os.File, string, error) {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+
+	open := make([]*listener, 0, len(listeners.open))
+	for l := range listeners.open {
+		open = append(open, l)
+	}
+	slices.SortFunc(open, func(a, b *listener) int { return strings.Compare(a.addr, b.addr) })
+
+	var (
+		files []*os.File
+		env   strings.Builder
+	)
+	for i, l := range open {
+		fl, ok := l.Listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			closeFiles(files)
+			return nil, "", fmt.Errorf("proxy: listener %s cannot be handed off", l.addr)
+		}
+		f, err := fl.File()
+		if err != nil {
+			closeFiles(files)
+			return nil, "", fmt.Errorf("proxy: listener %s: %w", l.addr, err)
+		}
+		files = append(files, f)
+		fmt.Fprintf(&env, "%d %s\n", first+i, l.addr)
+	}
+	return files, env.String(), nil
+}
+
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		_ = f.Close()
+	}
+}
+
+// Release stops the open listeners accepting, once a process that inherited
+// them serves their addresses. Unix socket files are left in place for it.
+// Accept on a released listener blocks until the listener is closed, so the
+// proxies keep serving the connections they have. The returned channel is
+// closed once every connection the listeners accepted has been closed.
+func Release() <-chan struct{} {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+
+	for l := range listeners.open {
+		l.release()
+	}
+	listeners.drained = make(chan struct{})
+	if listeners.conns == 0 {
+		close(listeners.drained)
+	}
+	return listeners.drained
+}
+
+// inheritedListener returns the listener inherited for addr, if any. Each is
+// returned once.
+func inheritedListener(addr string) (net.Listener, bool, error) {
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+
+	listeners.inheritOnce.Do(func() {
+		listeners.inherited = parseListenFDs(os.Getenv(ListenFDsEnv))
+		// Processes this one starts inherit only what it hands them.
+		_ = os.Unsetenv(ListenFDsEnv)
+	})
+	f, ok := listeners.inherited[addr]
+	if !ok {
+		return nil, false, nil
+	}
+	delete(listeners.inherited, addr)
+	defer func() { _ = f.Close() }()
+	lis, err := net.FileListener(f)
+	if err != nil {
+		return nil, true, fmt.Errorf("inherited listener %s: %w", addr, err)
+	}
+	// This process now owns the socket file, and removes it on close.
+	if u, ok := lis.(*net.UnixListener); ok {
+		u.SetUnlinkOnClose(true)
+	}
+	return lis, true, nil
+}
+
+func parseListenFDs(env string) map[string]*os.File {
+	files := make(map[string]*os.File)
+	for line := range strings.Lines(env) {
+		fd, addr, ok := strings.Cut(strings.TrimSuffix(line, "\n"), " ")
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseUint(fd, 10, 0)
+		if err != nil {
+			continue
+		}
+		files[addr] = os.NewFile(uintptr(n), addr)
+	}
+	return files
+}
+
+// track registers lis, opened for addr, with the listeners Release
+// releases.
+func track(addr string, lis net.Listener) net.Listener {
+	l := &listener{Listener: lis, addr: addr, released: make(chan struct{}), closed: make(chan struct{})}
+	listeners.mu.Lock()
+	defer listeners.mu.Unlock()
+	listeners.open[l] = struct{}{}
+	return l
+}
+
+type listener struct {
+	net.Listener
+
+	addr      string
+	released  chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		select {
+		case <-l.released:
+			<-l.closed
+			return nil, net.ErrClosed
+		default:
+		}
+		return nil, err //nolint:wrapcheck // as the wrapped listener reports it
+	}
+	listeners.mu.Lock()
+	listeners.conns++
+	listeners.mu.Unlock()
+	return &conn{Conn: c}, nil
+}
+
+func (l *listener) Close() error {
+	err := net.ErrClosed
+	l.closeOnce.Do(func() {
+		listeners.mu.Lock()
+		delete(listeners.open, l)
+		listeners.mu.Unlock()
+		close(l.closed)
+		select {
+		case <-l.released:
+			err = nil
+		default:
+			err = l.Listener.Close()
+		}
+	})
+	return err //nolint:wrapcheck // as the wrapped listener reports it
+}
+
+// release closes the socket without removing a unix socket file. Called with
+// listeners.mu held.
+func (l *listener) release() {
+	select {
+	case <-l.released:
+		return
+	default:
+	}
+	if u, ok := l.Listener.(*net.UnixListener); ok {
+		u.SetUnlinkOnClose(false)
+	}
+	close(l.released)
+	_ = l.Listener.Close()
+}
+
+type conn struct {
+	net.Conn
+
+	closeOnce sync.Once
+}
+
+func (c *conn) Close() error {
+	err := net.ErrClosed
+	c.closeOnce.Do(func() {
+		err = c.Conn.Close()
+		listeners.mu.Lock()
+		defer listeners.mu.Unlock()
+		listeners.conns--
+		if d := listeners.drained; d != nil && listeners.conns == 0 {
+			select {
+			case <-d:
+			default:
+				close(d)
+			}
+		}
+	})
+	return err //nolint:wrapcheck // as the wrapped connection reports it
+}
@@ -0,0 +1,115 @@
+package proxy_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+//nolint:paralleltest // releases every listener in the process
+func TestRelease(t *testing.T) {
+	dir, err := os.MkdirTemp("", "tap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "s.sock")
+	addr := "unix://" + path
+
+	lis, err := proxy.Listen(t.Context(), addr)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	accepted := make(chan net.Conn)
+	acceptErr := make(chan error, 1)
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				acceptErr <- err
+				return
+			}
+			accepted <- c
+		}
+	}()
+	client, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = client.Close() }()
+	open := <-accepted
+
+	// The successor's copy of the listener, as it would inherit it.
+	files, env, err := proxy.ListenerFiles(3)
+	if err != nil {
+		t.Fatalf("ListenerFiles: %v", err)
+	}
+	var inherited *os.File
+	for i, line := range strings.Split(strings.TrimSpace(env), "\n") {
+		if fd, a, _ := strings.Cut(line, " "); a == addr {
+			if fd != strconv.Itoa(3+i) {
+				t.Errorf("fd %s for listener %d, want %d", fd, i, 3+i)
+			}
+			inherited = files[i]
+		}
+	}
+	if inherited == nil {
+		t.Fatalf("%s not in %q", addr, env)
+	}
+	successor, err := net.FileListener(inherited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = successor.Close() }()
+	for _, f := range files {
+		_ = f.Close()
+	}
+
+	drained := proxy.Release()
+	select {
+	case <-drained:
+		t.Fatal("drained with a connection open")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// New clients reach the successor; the released listener accepts none.
+	late, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatalf("dial after release: %v", err)
+	}
+	defer func() { _ = late.Close() }()
+	if c, err := successor.Accept(); err != nil {
+		t.Fatalf("successor accept: %v", err)
+	} else {
+		_ = c.Close()
+	}
+	select {
+	case <-accepted:
+		t.Fatal("released listener accepted a connection")
+	case err := <-acceptErr:
+		t.Fatalf("released listener's Accept returned %v before Close", err)
+	default:
+	}
+
+	_ = open.Close()
+	select {
+	case <-drained:
+	case <-time.After(time.Second):
+		t.Fatal("not drained after the last connection closed")
+	}
+
+	_ = lis.Close()
+	if err := <-acceptErr; !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Accept after Close = %v, want net.ErrClosed", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("socket file removed by the released listener: %v", err)
+	}
+}
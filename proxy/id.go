@@ -0,0 +1,19 @@
+package proxy
+
+import "strconv"
+
+// IDGenerator returns the ID of an event on connection connID, where seq
+// numbers the connection's events from 1. IDs need only be unique within a
+// connection, as the default, seq in decimal, is; a generator can make them
+// unique across processes, e.g. by prefixing a host name, or match IDs from
+// elsewhere. Connections call it concurrently.
+type IDGenerator func(connID string, seq uint64) string
+
+// ID returns g's ID for event seq of connection connID, or seq in decimal
+// when g is nil.
+func (g IDGenerator) ID(connID string, seq uint64) string {
+	if g == nil {
+		return strconv.FormatUint(seq, 10)
+	}
+	return g(connID, seq)
+}
@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+)
+
+// ListenSpec is one address a proxy accepts clients on.
+type ListenSpec struct {
+	Addr  string         // see Network
+	Label string         // set as the Listener of its clients' events
+	Allow []netip.Prefix // TCP clients accepted; empty accepts any
+}
+
+// Allows reports whether a client at addr may connect through the listener.
+// Unix socket clients are always allowed, as the socket's directory controls
+// who can reach them.
+func (l ListenSpec) Allows(addr net.Addr) bool {
+	if len(l.Allow) == 0 || addr.Network() == "unix" {
+		return true
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	// A dual-stack listener sees IPv4 clients as IPv4-mapped IPv6 addresses.
+	ip := ap.Addr().Unmap()
+	for _, p := range l.Allow {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// String formats l the way ParseListenSpec reads it.
+func (l ListenSpec) String() string {
+	s := l.Addr
+	if l.Label != "" {
+		s += ";label=" + l.Label
+	}
+	if len(l.Allow) > 0 {
+		allow := make([]string, len(l.Allow))
+		for i, p := range l.Allow {
+			allow[i] = p.String()
+		}
+		s += ";allow=" + strings.Join(allow, "|")
+	}
+	return s
+}
+
+// ParseListenSpec parses "<addr>[;label=<name>][;allow=<cidr>|<cidr>...]",
+// e.g. "0.0.0.0:5433;label=lan;allow=10.0.0.0/8|192.168.0.0/16". An allowed
+// address without a prefix length stands for that address alone.
+func ParseListenSpec(s string) (ListenSpec, error) {
+	addr, opts, _ := strings.Cut(s, ";")
+	l := ListenSpec{Addr: strings.TrimSpace(addr)}
+	if l.Addr == "" {
+		return ListenSpec{}, fmt.Errorf("listen %q: address is required", s)
+	}
+	for opt := range strings.SplitSeq(opts, ";") {
+		if opt == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(strings.TrimSpace(opt), "=")
+		if !ok {
+			return ListenSpec{}, fmt.Errorf("listen %q: invalid option %q (want key=value)", s, opt)
+		}
+		switch key {
+		case "label":
+			l.Label = val
+		case "allow":
+			for cidr := range strings.SplitSeq(val, "|") {
+				p, err := parsePrefix(cidr)
+				if err != nil {
+					return ListenSpec{}, fmt.Errorf("listen %q: allow: %w", s, err)
+				}
+				l.Allow = append(l.Allow, p)
+			}
+		default:
+			return ListenSpec{}, fmt.Errorf("listen %q: unknown option %q", s, key)
+		}
+	}
+	if len(l.Allow) > 0 {
+		if network, _ := Network(l.Addr); network == "unix" {
+			return ListenSpec{}, fmt.Errorf("listen %q: allow applies to TCP listeners only", s)
+		}
+	}
+	return l, nil
+}
+
+func parsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, err //nolint:wrapcheck // callers add context
+		}
+		return netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()), nil
+	}
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, err //nolint:wrapcheck // callers add context
+	}
+	return p.Masked(), nil
+}
+
+// ListenAll listens on each of specs (see Listen), closing the listeners
+// already opened if one fails.
+func ListenAll(ctx context.Context, specs []ListenSpec) ([]net.Listener, error) {
+	lis := make([]net.Listener, 0, len(specs))
+	for _, spec := range specs {
+		l, err := Listen(ctx, spec.Addr)
+		if err != nil {
+			for _, opened := range lis {
+				_ = opened.Close()
+			}
+			return nil, err
+		}
+		lis = append(lis, l)
+	}
+	return lis, nil
+}
+
+// AcceptAll accepts clients on each of lis, opened for the same index of
+// specs, and passes them to handle with their listener's spec; handle runs
+// on the accepting goroutine and should hand the connection off. Once any
+// listener fails, AcceptAll closes them all and returns the first error.
+func AcceptAll(lis []net.Listener, specs []ListenSpec, handle func(net.Conn, ListenSpec)) error {
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for i, l := range lis {
+		wg.Go(func() {
+			for {
+				c, err := l.Accept()
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						for _, other := range lis {
+							_ = other.Close()
+						}
+					})
+					return
+				}
+				handle(c, specs[i])
+			}
+		})
+	}
+	wg.Wait()
+	if firstErr == nil {
+		return errors.New("no listeners")
+	}
+	return firstErr //nolint:wrapcheck // callers add context
+}
+
+// CloseAll closes each of lis, returning their errors joined.
+func CloseAll(lis []net.Listener) error {
+	var errs []error
+	for _, l := range lis {
+		if err := l.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package proxy_test
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestParseListenSpec(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		spec    string
+		want    proxy.ListenSpec
+		wantErr bool
+	}{
+		{spec: "127.0.0.1:5433", want: proxy.ListenSpec{Addr: "127.0.0.1:5433"}},
+		{spec: "[::1]:5433;label=v6", want: proxy.ListenSpec{Addr: "[::1]:5433", Label: "v6"}},
+		{
+			spec: "0.0.0.0:5433;label=lan;allow=10.0.0.0/8|192.168.1.7",
+			want: proxy.ListenSpec{
+				Addr:  "0.0.0.0:5433",
+				Label: "lan",
+				Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("192.168.1.7/32")},
+			},
+		},
+		{spec: ":5433;allow=fd00::1/8", want: proxy.ListenSpec{Addr: ":5433", Allow: []netip.Prefix{netip.MustParsePrefix("fd00::/8")}}},
+		{spec: "/tmp/s.sock;label=local", want: proxy.ListenSpec{Addr: "/tmp/s.sock", Label: "local"}},
+		{spec: "", wantErr: true},
+		{spec: ";label=x", wantErr: true},
+		{spec: ":5433;label", wantErr: true},
+		{spec: ":5433;port=1", wantErr: true},
+		{spec: ":5433;allow=10.0.0.0/33", wantErr: true},
+		{spec: ":5433;allow=localhost", wantErr: true},
+		{spec: "/tmp/s.sock;allow=10.0.0.0/8", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := proxy.ParseListenSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseListenSpec(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Addr != tt.want.Addr || got.Label != tt.want.Label || !slices.Equal(got.Allow, tt.want.Allow) {
+				t.Errorf("ParseListenSpec(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+			again, err := proxy.ParseListenSpec(got.String())
+			if err != nil || again.String() != got.String() {
+				t.Errorf("String() = %q does not round-trip: %+v, %v", got.String(), again, err)
+			}
+		})
+	}
+}
+
+func TestListenSpec_Allows(t *testing.T) {
+	t.Parallel()
+
+	spec, err := proxy.ParseListenSpec("[::]:5433;allow=10.0.0.0/8|fd00::/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		addr net.Addr
+		want bool
+	}{
+		{name: "ipv4 inside", addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 50000}, want: true},
+		{name: "ipv4-mapped inside", addr: &net.TCPAddr{IP: net.ParseIP("::ffff:10.1.2.3"), Port: 50000}, want: true},
+		{name: "ipv6 inside", addr: &net.TCPAddr{IP: net.ParseIP("fd12::5"), Port: 50000}, want: true},
+		{name: "ipv4 outside", addr: &net.TCPAddr{IP: net.ParseIP("192.168.0.1"), Port: 50000}},
+		{name: "ipv6 outside", addr: &net.TCPAddr{IP: net.ParseIP("::1"), Port: 50000}},
+		{name: "unix", addr: &net.UnixAddr{Name: "@", Net: "unix"}, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			if got := spec.Allows(tt.addr); got != tt.want {
+				t.Errorf("Allows(%v) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+
+	if open := (proxy.ListenSpec{Addr: ":5433"}); !open.Allows(&net.TCPAddr{IP: net.ParseIP("192.168.0.1")}) {
+		t.Error("a listener without an allow list should accept any client")
+	}
+}
+
+func TestAcceptAll(t *testing.T) {
+	t.Parallel()
+
+	specs := []proxy.ListenSpec{{Addr: "127.0.0.1:0", Label: "a"}, {Addr: "127.0.0.1:0", Label: "b"}}
+	lis, err := proxy.ListenAll(t.Context(), specs)
+	if err != nil {
+		t.Fatalf("ListenAll: %v", err)
+	}
+
+	accepted := make(chan string, len(specs))
+	done := make(chan error, 1)
+	go func() {
+		done <- proxy.AcceptAll(lis, specs, func(c net.Conn, spec proxy.ListenSpec) {
+			_ = c.Close()
+			accepted <- spec.Label
+		})
+	}()
+
+	var got []string
+	for _, l := range lis {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial: %v", err)
+		}
+		_ = c.Close()
+		got = append(got, <-accepted)
+	}
+	if !slices.Equal(got, []string{"a", "b"}) {
+		t.Errorf("accepted through %v, want [a b]", got)
+	}
+
+	// Closing one listener stops them all.
+	_ = lis[0].Close()
+	if err := <-done; err == nil {
+		t.Error("AcceptAll returned nil after a listener closed")
+	}
+	if _, err := net.Dial("tcp", lis[1].Addr().String()); err == nil {
+		t.Error("the other listener is still accepting")
+	}
+}
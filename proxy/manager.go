@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+var _ Proxy = (*Manager)(nil)
+
+var connIDs atomic.Uint64
+
+// NewConnID returns a process-wide unique connection ID, so connections from
+// different proxies never collide.
+func NewConnID() string {
+	return strconv.FormatUint(connIDs.Add(1), 10)
+}
+
+type managedProxy struct {
+	name  string
+	proxy Proxy
+}
+
+// Manager runs several proxies as one, merging their events into a single
+// channel and stamping each event with the name of the upstream it came from.
+type Manager struct {
+	proxies []managedProxy
+	events  chan Event
+}
+
+// NewManager creates an empty Manager. Add proxies before calling ListenAndServe.
+func NewManager() *Manager {
+	return &Manager{events: make(chan Event, 256)}
+}
+
+// Add registers a proxy under the given upstream name.
+func (m *Manager) Add(name string, p Proxy) {
+	m.proxies = append(m.proxies, managedProxy{name: name, proxy: p})
+}
+
+// Events returns the merged channel of captured events.
+func (m *Manager) Events() <-chan Event {
+	return m.events
+}
+
+// ListenAndServe runs all proxies until ctx is done or one of them fails,
+// in which case the others are stopped and the first error is returned.
+func (m *Manager) ListenAndServe(ctx context.Context) error {
+	if len(m.proxies) == 0 {
+		return errors.New("proxy: manager: no proxies configured")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var forwarders sync.WaitGroup
+	errCh := make(chan error, len(m.proxies))
+	for _, mp := range m.proxies {
+		forwarders.Go(func() { m.forward(ctx, mp) })
+		go func() {
+			if err := mp.proxy.ListenAndServe(ctx); err != nil {
+				errCh <- fmt.Errorf("proxy: %s: %w", mp.name, err)
+				return
+			}
+			errCh <- nil
+		}()
+	}
+
+	var first error
+	for range m.proxies {
+		if err := <-errCh; err != nil && first == nil {
+			first = err
+		}
+		cancel()
+	}
+	forwarders.Wait()
+	return first
+}
+
+func (m *Manager) forward(ctx context.Context, mp managedProxy) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-mp.proxy.Events():
+			if !ok {
+				return
+			}
+			ev.Upstream = mp.name
+			select {
+			case m.events <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// Close closes all proxies, returning the first error encountered.
+func (m *Manager) Close() error {
+	var first error
+	for _, mp := range m.proxies {
+		if err := mp.proxy.Close(); err != nil && first == nil {
+			first = fmt.Errorf("proxy: %s: %w", mp.name, err)
+		}
+	}
+	return first
+}
@@ -0,0 +1,114 @@
+package proxy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+type fakeProxy struct {
+	events chan proxy.Event
+	err    error
+}
+
+func newFakeProxy(err error) *fakeProxy {
+	return &fakeProxy{events: make(chan proxy.Event, 8), err: err}
+}
+
+func (f *fakeProxy) ListenAndServe(ctx context.Context) error {
+	if f.err != nil {
+		return f.err
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func (f *fakeProxy) Events() <-chan proxy.Event { return f.events }
+
+func (f *fakeProxy) Close() error { return nil }
+
+func TestManager_StampsUpstream(t *testing.T) {
+	t.Parallel()
+
+	primary := newFakeProxy(nil)
+	replica := newFakeProxy(nil)
+
+	m := proxy.NewManager()
+	m.Add("primary", primary)
+	m.Add("replica", replica)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() { done <- m.ListenAndServe(ctx) }()
+
+	primary.events <- proxy.Event{ID: "1"}
+	replica.events <- proxy.Event{ID: "2"}
+
+	got := make(map[string]string)
+	for range 2 {
+		select {
+		case ev := <-m.Events():
+			got[ev.ID] = ev.Upstream
+		case <-time.After(time.Second):
+			t.Fatal("timeout waiting for event")
+		}
+	}
+	if got["1"] != "primary" || got["2"] != "replica" {
+		t.Fatalf("unexpected upstreams: %v", got)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("ListenAndServe: %v", err)
+	}
+}
+
+func TestManager_FirstErrorStopsAll(t *testing.T) {
+	t.Parallel()
+
+	boom := errors.New("boom")
+	m := proxy.NewManager()
+	m.Add("ok", newFakeProxy(nil))
+	m.Add("bad", newFakeProxy(boom))
+
+	select {
+	case err := <-runManager(t, m):
+		if !errors.Is(err, boom) {
+			t.Fatalf("err = %v, want %v", err, boom)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("manager did not stop after proxy failure")
+	}
+}
+
+func TestManager_NoProxies(t *testing.T) {
+	t.Parallel()
+
+	if err := proxy.NewManager().ListenAndServe(t.Context()); err == nil {
+		t.Fatal("expected error for empty manager")
+	}
+}
+
+func TestNewConnID_Unique(t *testing.T) {
+	t.Parallel()
+
+	seen := make(map[string]bool)
+	for range 100 {
+		id := proxy.NewConnID()
+		if seen[id] {
+			t.Fatalf("duplicate conn id %q", id)
+		}
+		seen[id] = true
+	}
+}
+
+func runManager(t *testing.T, m *proxy.Manager) <-chan error {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() { done <- m.ListenAndServe(t.Context()) }()
+	return done
+}
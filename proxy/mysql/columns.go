@@ -0,0 +1,117 @@
+package mysql
+
+import (
+	"strconv"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// binaryCharset is the character set number of binary strings, which tells
+// BLOB from TEXT and VARBINARY from VARCHAR.
+const binaryCharset = 63
+
+// typeNames names the field types of column definitions; the string and
+// blob types are refined by character set in columnType.
+var typeNames = map[byte]string{
+	0x00: "DECIMAL",
+	0x01: "TINYINT",
+	0x02: "SMALLINT",
+	0x03: "INT",
+	0x04: "FLOAT",
+	0x05: "DOUBLE",
+	0x06: "NULL",
+	0x07: "TIMESTAMP",
+	0x08: "BIGINT",
+	0x09: "MEDIUMINT",
+	0x0a: "DATE",
+	0x0b: "TIME",
+	0x0c: "DATETIME",
+	0x0d: "YEAR",
+	0x0e: "DATE",
+	0x0f: "VARCHAR",
+	0x10: "BIT",
+	0x11: "TIMESTAMP",
+	0x12: "DATETIME",
+	0x13: "TIME",
+	0xf3: "VECTOR",
+	0xf5: "JSON",
+	0xf6: "DECIMAL",
+	0xf7: "ENUM",
+	0xf8: "SET",
+	0xf9: "TINYTEXT",
+	0xfa: "MEDIUMTEXT",
+	0xfb: "LONGTEXT",
+	0xfc: "TEXT",
+	0xfd: "VARCHAR",
+	0xfe: "CHAR",
+	0xff: "GEOMETRY",
+}
+
+// binaryTypeNames are the names of the string and blob types in the binary
+// character set.
+var binaryTypeNames = map[byte]string{
+	0x0f: "VARBINARY",
+	0xf9: "TINYBLOB",
+	0xfa: "MEDIUMBLOB",
+	0xfb: "LONGBLOB",
+	0xfc: "BLOB",
+	0xfd: "VARBINARY",
+	0xfe: "BINARY",
+}
+
+func columnType(typ byte, charset uint16) string {
+	if charset == binaryCharset {
+		if name, ok := binaryTypeNames[typ]; ok {
+			return name
+		}
+	}
+	if name, ok := typeNames[typ]; ok {
+		return name
+	}
+	return "0x" + strconv.FormatUint(uint64(typ), 16)
+}
+
+// parseColumnDef decodes a ColumnDefinition41 payload: the length-encoded
+// catalog, schema, table, original table, name, and original name, then the
+// length of the fixed fields, the character set (2 bytes), column length (4),
+// and type (1). It returns false for a payload too short to hold them.
+func parseColumnDef(payload []byte) (proxy.Column, bool) {
+	var name string
+	off := 0
+	for i := range 6 {
+		length, n := readLenEncInt(payload, off)
+		if n == 0 {
+			return proxy.Column{}, false
+		}
+		off += n
+		end := off + int(length) //nolint:gosec // practically won't overflow
+		if end > len(payload) {
+			return proxy.Column{}, false
+		}
+		if i == 4 {
+			name = string(payload[off:end])
+		}
+		off = end
+	}
+	_, n := readLenEncInt(payload, off)
+	if n == 0 || off+n+7 > len(payload) {
+		return proxy.Column{}, false
+	}
+	off += n
+	charset := uint16(payload[off]) | uint16(payload[off+1])<<8
+	return proxy.Column{Name: name, Type: columnType(payload[off+6], charset)}, true
+}
+
+// describeColumn adds the column a column definition packet describes to
+// the pending event.
+func (c *conn) describeColumn(pkt []byte) {
+	col, ok := parseColumnDef(pkt[4:])
+	if !ok {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != nil {
+		c.pending.Columns = append(c.pending.Columns, col)
+	}
+}
@@ -7,11 +7,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"math"
 	"net"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -41,6 +43,7 @@ const (
 type preparedStmt struct {
 	query     string
 	numParams int
+	violation *proxy.Violation // the policy's verdict on query, if it matched
 }
 
 // MySQL command bytes.
@@ -60,13 +63,35 @@ const (
 
 // MySQL capability flags.
 const (
+	clientMySQL               uint32 = 1 << 0 // CLIENT_LONG_PASSWORD; MariaDB clears it to signal extended capabilities
+	clientConnectWithDB       uint32 = 1 << 3
 	clientCompress            uint32 = 1 << 5
 	clientSSL                 uint32 = 1 << 11
+	clientSecureConnection    uint32 = 1 << 15
+	clientAuthLenEncData      uint32 = 1 << 21
 	clientDeprecateEOF        uint32 = 1 << 24
 	clientZstdCompressionAlgo uint32 = 1 << 26
 	clientQueryAttributes     uint32 = 1 << 27
 )
 
+// Auth phase packet indicators and caching_sha2_password AuthMoreData
+// statuses.
+const (
+	authMoreData      byte = 0x01
+	authSwitchRequest byte = 0xFE
+	fastAuthSuccess   byte = 0x03
+)
+
+// MariaDB extended capability flags, sent in otherwise reserved bytes of the
+// greeting and handshake response when clientMySQL is clear.
+const (
+	// Progress reports arrive as error packets in the middle of a result.
+	mariadbClientProgress uint32 = 1 << 0
+	// Cached metadata lets the server omit column definitions from binary
+	// result sets.
+	mariadbClientCacheMetadata uint32 = 1 << 4
+)
+
 // responseState tracks where we are in parsing a server response sequence.
 type responseState int
 
@@ -80,37 +105,75 @@ const (
 
 // conn manages bidirectional relay and protocol parsing for a single MySQL connection.
 type conn struct {
+	id           string
 	clientConn   net.Conn
 	upstreamConn net.Conn
 	events       chan<- proxy.Event
+	logger       *slog.Logger
+
+	// reported records that a Connect or Disconnect event carries the
+	// relay's failure, so it needs no diagnostic; relay goroutine only.
+	reported bool
+
+	// Connection metadata from the handshake, stamped on every event.
+	clientAddr   string
+	listener     string // label of the listener the client connected through
+	user         string
+	database     string
+	connectionID uint32 // server's thread ID, from the greeting
 
 	preparedStmts map[uint32]preparedStmt
 	lastCommand   byte
 	lastQuery     string
 	lastStmtID    uint32
 
+	// Statement policy; policy is nil when disabled. violation is its
+	// verdict on the packet being captured, lastViolation on lastQuery.
+	policy        proxy.Policy
+	violation     *proxy.Violation
+	lastViolation *proxy.Violation
+
 	activeTxID string
 	nextID     uint64
+	newID      proxy.IDGenerator
+	queries    atomic.Int64 // Query, Exec, and Execute events emitted
 
 	state       responseState
 	skipPackets int // remaining param/column def packets to skip after StmtPrepareOK
 
-	mu      sync.Mutex
-	pending *proxy.Event
+	// Detailed capture, toggled per connection at runtime.
+	verbosity *proxy.Verbosity
+
+	// Idle timeout; idleTimeout is 0 when disabled. active is when either
+	// side last sent a packet, in Unix nanoseconds, and idled records that
+	// the timeout closed the connection.
+	idleTimeout time.Duration
+	idleTimer   *time.Timer // under mu
+	active      atomic.Int64
+	idled       atomic.Bool
+
+	mu       sync.Mutex
+	pending  *proxy.Event
+	closed   bool      // the relay has ended; the idle timeout no longer fires
+	verbose  bool      // detailed capture enabled for the current query
+	firstRow time.Time // when the result set header of pending arrived
 }
 
-func newConn(clientConn, upstreamConn net.Conn, events chan<- proxy.Event) *conn {
+func newConn(id string, clientConn, upstreamConn net.Conn, events chan<- proxy.Event, verbosity *proxy.Verbosity) *conn {
 	return &conn{
+		id:            id,
 		clientConn:    clientConn,
 		upstreamConn:  upstreamConn,
 		events:        events,
+		clientAddr:    clientConn.RemoteAddr().String(),
+		verbosity:     verbosity,
 		preparedStmts: make(map[uint32]preparedStmt),
 	}
 }
 
 func (c *conn) generateID() string {
 	c.nextID++
-	return strconv.FormatUint(c.nextID, 10)
+	return c.newID.ID(c.id, c.nextID)
 }
 
 // ---------------- packet I/O ----------------
@@ -219,10 +282,40 @@ func clearCapabilityBits(pkt []byte, bits uint32) {
 	upper := binary.LittleEndian.Uint16(payload[upperOff : upperOff+2])
 	upper &^= uint16(bits >> 16)
 	binary.LittleEndian.PutUint16(payload[upperOff:upperOff+2], upper)
+
+	// MariaDB: +20 auth data length (1 byte), +21 reserved (6 bytes), then
+	// +27 extended capability flags (4 bytes).
+	extOff := base + 27
+	if lower&uint16(clientMySQL) != 0 || extOff+4 > len(payload) {
+		return
+	}
+	ext := binary.LittleEndian.Uint32(payload[extOff : extOff+4])
+	ext &^= mariadbStripCaps
+	binary.LittleEndian.PutUint32(payload[extOff:extOff+4], ext)
+}
+
+// parseConnectionID returns the connection_id from a server greeting, the
+// thread ID that KILL takes, or 0 when the greeting is truncated.
+func parseConnectionID(pkt []byte) uint32 {
+	payload := pkt[4:]
+	if len(payload) < 2 {
+		return 0
+	}
+	nulIdx := bytes.IndexByte(payload[1:], 0x00)
+	if nulIdx < 0 {
+		return 0
+	}
+	off := 1 + nulIdx + 1
+	if off+4 > len(payload) {
+		return 0
+	}
+	return binary.LittleEndian.Uint32(payload[off : off+4])
 }
 
 // clearClientCapabilityBits clears the given capability bits in a client handshake response.
-// The capability flags are the first 4 bytes of the payload.
+// The capability flags are the first 4 bytes of the payload. MariaDB clients
+// send their extended flags in the last 4 bytes of the 23-byte filler that
+// follows max_packet_size and charset.
 func clearClientCapabilityBits(pkt []byte, bits uint32) {
 	payload := pkt[4:]
 	if len(payload) < 4 {
@@ -231,24 +324,96 @@ func clearClientCapabilityBits(pkt []byte, bits uint32) {
 	caps := binary.LittleEndian.Uint32(payload[0:4])
 	caps &^= bits
 	binary.LittleEndian.PutUint32(payload[0:4], caps)
+
+	if caps&clientMySQL != 0 || len(payload) < 32 {
+		return
+	}
+	ext := binary.LittleEndian.Uint32(payload[28:32])
+	ext &^= mariadbStripCaps
+	binary.LittleEndian.PutUint32(payload[28:32], ext)
+}
+
+// parseHandshakeResponse returns the user and database from a client
+// HandshakeResponse41 packet. The layout after the capability flags is:
+//
+//	+4  max_packet_size (4 bytes)
+//	+8  charset         (1 byte)
+//	+9  filler          (23 bytes)
+//	+32 username        (NUL-terminated)
+//	    auth_response   (length-encoded, 1-byte length, or NUL-terminated by capability)
+//	    database        (NUL-terminated, with CLIENT_CONNECT_WITH_DB)
+func parseHandshakeResponse(pkt []byte) (user, database string) {
+	payload := pkt[4:]
+	if len(payload) < 32 {
+		return "", ""
+	}
+	caps := binary.LittleEndian.Uint32(payload[0:4])
+	rest := payload[32:]
+	end := bytes.IndexByte(rest, 0x00)
+	if end < 0 {
+		return "", ""
+	}
+	user = string(rest[:end])
+	rest = rest[end+1:]
+
+	switch {
+	case caps&clientAuthLenEncData != 0:
+		n, size := readLenEncInt(rest, 0)
+		if size == 0 || uint64(len(rest)-size) < n {
+			return user, ""
+		}
+		rest = rest[size+int(n):] //nolint:gosec // bounded by len(rest) above
+	case caps&clientSecureConnection != 0:
+		if len(rest) < 1 || len(rest) < 1+int(rest[0]) {
+			return user, ""
+		}
+		rest = rest[1+int(rest[0]):]
+	default:
+		end := bytes.IndexByte(rest, 0x00)
+		if end < 0 {
+			return user, ""
+		}
+		rest = rest[end+1:]
+	}
+
+	if caps&clientConnectWithDB == 0 {
+		return user, ""
+	}
+	if end := bytes.IndexByte(rest, 0x00); end >= 0 {
+		rest = rest[:end]
+	}
+	return user, string(rest)
 }
 
 // ---------------- handshake ----------------
 
-// relayStartup handles the MySQL handshake/auth phase.
-func (c *conn) relayStartup() error {
-	// Capabilities the proxy must disable because it inspects raw packets.
-	const stripCaps = clientSSL |
+// Capabilities the proxy must disable because it inspects raw packets.
+const (
+	stripCaps = clientSSL |
 		clientCompress |
 		clientDeprecateEOF |
 		clientZstdCompressionAlgo |
 		clientQueryAttributes
+	mariadbStripCaps = mariadbClientProgress | mariadbClientCacheMetadata
+)
 
-	// 1. Read server greeting, strip unsupported capabilities.
+// relayStartup handles the MySQL handshake/auth phase. The auth exchange is
+// relayed packet by packet without interpreting the plugin, so
+// mysql_native_password, caching_sha2_password (fast and full auth), MariaDB's
+// ed25519, and auth switches to any of them all pass through.
+func (c *conn) relayStartup() error {
+	// 1. Read server greeting, strip unsupported capabilities. A server
+	// refusing the connection (too many connections, host blocked) sends an
+	// error packet instead.
 	greeting, err := readPacket(c.upstreamConn)
 	if err != nil {
 		return fmt.Errorf("mysql: read greeting: %w", err)
 	}
+	if payloadByte(greeting) == iERR {
+		_ = writePacket(c.clientConn, greeting)
+		return errors.New("mysql: upstream refused connection")
+	}
+	c.connectionID = parseConnectionID(greeting)
 	clearCapabilityBits(greeting, stripCaps)
 	if err := writePacket(c.clientConn, greeting); err != nil {
 		return fmt.Errorf("mysql: send greeting: %w", err)
@@ -259,6 +424,7 @@ func (c *conn) relayStartup() error {
 	if err != nil {
 		return fmt.Errorf("mysql: read handshake response: %w", err)
 	}
+	c.user, c.database = parseHandshakeResponse(resp)
 	clearClientCapabilityBits(resp, stripCaps)
 	if err := writePacket(c.upstreamConn, resp); err != nil {
 		return fmt.Errorf("mysql: send handshake response: %w", err)
@@ -279,14 +445,19 @@ func (c *conn) relayStartup() error {
 			return nil
 		case iERR:
 			return errors.New("mysql: auth error from upstream")
-		case 0x01: // AuthMoreData
+		case authMoreData:
 			// caching_sha2_password fast auth success: server sends [0x01, 0x03],
-			// then follows with OK. No client response needed.
+			// then follows with OK. No client response needed. Anything else
+			// (0x04 to request full auth, or the server's RSA public key) waits
+			// for the client.
 			payload := pkt[4:]
-			if len(payload) >= 2 && payload[1] == 0x03 {
+			if len(payload) == 2 && payload[1] == fastAuthSuccess {
 				continue
 			}
-			// Full auth needed (e.g. 0x04): fall through to read client response.
+		case authSwitchRequest:
+			// The server wants a different plugin, e.g. Aurora or MariaDB
+			// switching a caching_sha2_password client to
+			// mysql_native_password; the client answers with the new scramble.
 		}
 
 		// Auth switch or other auth continuation: read client response and forward.
@@ -302,23 +473,74 @@ func (c *conn) relayStartup() error {
 
 // ---------------- relay ----------------
 
-func (c *conn) relay(ctx context.Context) error {
+func (c *conn) relay(ctx context.Context) (err error) {
+	start := time.Now()
 	if err := c.relayStartup(); err != nil {
+		if c.user != "" {
+			c.emitConnect(start, err)
+		}
 		return fmt.Errorf("mysql: startup: %w", err)
 	}
+	c.emitConnect(start, nil)
+	defer func() { c.emitDisconnect(start, err) }()
+	c.watchIdle()
 
-	errCh := make(chan error, 2)
-	go func() { errCh <- c.relayClientToUpstream(ctx) }()
-	go func() { errCh <- c.relayUpstreamToClient(ctx) }()
+	clientCh := make(chan error, 1)
+	upstreamCh := make(chan error, 1)
+	go func() { clientCh <- c.guard("client relay", func() error { return c.relayClientToUpstream(ctx) }) }()
+	go func() { upstreamCh <- c.guard("upstream relay", func() error { return c.relayUpstreamToClient(ctx) }) }()
 
-	err := <-errCh
+	rest := clientCh
+	select {
+	case err = <-clientCh:
+		c.clientLeft()
+		rest = upstreamCh
+	case err = <-upstreamCh:
+	}
 	_ = c.clientConn.Close()
 	_ = c.upstreamConn.Close()
-	<-errCh
+	<-rest
+	c.mu.Lock()
+	c.closed = true
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	c.mu.Unlock()
 
 	return err
 }
 
+// guard runs fn, one of the connection's goroutines, turning a panic in it
+// into an error after reporting it, so a bug handling one connection closes
+// only that connection.
+func (c *conn) guard(where string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			diag, rerr := proxy.Recovered("mysql: "+where, v, proxy.Event{
+				ConnID:     c.id,
+				ClientAddr: c.clientAddr,
+				Listener:   c.listener,
+				User:       c.user,
+				Database:   c.database,
+				BackendPID: c.connectionID,
+			})
+			proxy.Emit(c.events, diag)
+			err = rerr
+		}
+	}()
+	return fn()
+}
+
+// clientLeft counts the statement the server is running, if any, when the
+// client disconnects without waiting for it.
+func (c *conn) clientLeft() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending != nil {
+		proxy.CountCancel(proxy.CancelDisconnect)
+	}
+}
+
 func (c *conn) relayClientToUpstream(ctx context.Context) error {
 	for {
 		if ctx.Err() != nil {
@@ -333,6 +555,19 @@ func (c *conn) relayClientToUpstream(ctx context.Context) error {
 			return fmt.Errorf("mysql: receive from client: %w", err)
 		}
 
+		if c.policy != nil {
+			blocked, err := c.enforce(pkt)
+			if err != nil {
+				if isClosedErr(err) {
+					return nil
+				}
+				return fmt.Errorf("mysql: send to client: %w", err)
+			}
+			if blocked {
+				continue
+			}
+		}
+
 		c.captureClientPacket(pkt)
 
 		if err := writePacket(c.upstreamConn, pkt); err != nil {
@@ -372,6 +607,7 @@ func (c *conn) relayUpstreamToClient(ctx context.Context) error {
 // ---------------- client capture ----------------
 
 func (c *conn) captureClientPacket(pkt []byte) {
+	c.touch()
 	if payloadLen(pkt) < 1 {
 		return
 	}
@@ -383,24 +619,28 @@ func (c *conn) captureClientPacket(pkt []byte) {
 		q := string(payload[1:])
 		c.lastCommand = comQuery
 		c.lastQuery = q
+		c.lastViolation = c.violation
 		c.state = stateFirstResp
 
 		r := c.detectTx(q, proxy.OpQuery)
 		ev := proxy.Event{
-			ID:        c.generateID(),
-			Op:        r.op,
-			Query:     q,
-			StartTime: time.Now(),
-			TxID:      r.txID,
+			ID:           c.generateID(),
+			ConnID:       c.id,
+			Op:           r.op,
+			Query:        q,
+			StartTime:    time.Now(),
+			TxID:         r.txID,
+			GlobalTxID:   r.globalTxID,
+			RequestBytes: int64(len(pkt)),
+			Violation:    c.violation,
 		}
-		c.mu.Lock()
-		c.pending = &ev
-		c.mu.Unlock()
+		c.setPending(&ev)
 
 	case comStmtPrepare:
 		q := string(payload[1:])
 		c.lastCommand = comStmtPrepare
 		c.lastQuery = q
+		c.lastViolation = c.violation
 		c.state = stateFirstResp
 
 	case comStmtExecute:
@@ -417,16 +657,18 @@ func (c *conn) captureClientPacket(pkt []byte) {
 
 			r := c.detectTx(stmt.query, proxy.OpExecute)
 			ev := proxy.Event{
-				ID:        c.generateID(),
-				Op:        r.op,
-				Query:     stmt.query,
-				Args:      args,
-				StartTime: time.Now(),
-				TxID:      r.txID,
+				ID:           c.generateID(),
+				ConnID:       c.id,
+				Op:           r.op,
+				Query:        stmt.query,
+				Args:         args,
+				StartTime:    time.Now(),
+				TxID:         r.txID,
+				GlobalTxID:   r.globalTxID,
+				RequestBytes: int64(len(pkt)),
+				Violation:    stmt.violation,
 			}
-			c.mu.Lock()
-			c.pending = &ev
-			c.mu.Unlock()
+			c.setPending(&ev)
 		}
 
 	case comStmtClose:
@@ -437,9 +679,28 @@ func (c *conn) captureClientPacket(pkt []byte) {
 	}
 }
 
+// setPending installs ev as the event awaiting an upstream response and
+// decides whether it gets detailed capture.
+func (c *conn) setPending(ev *proxy.Event) {
+	verbose := c.verbosity.Verbose(c.id)
+
+	c.mu.Lock()
+	c.pending = ev
+	c.verbose = verbose
+	c.firstRow = time.Time{}
+	c.mu.Unlock()
+}
+
 // ---------------- upstream capture (state machine) ----------------
 
 func (c *conn) captureUpstreamPacket(pkt []byte) {
+	c.touch()
+	c.mu.Lock()
+	if c.pending != nil {
+		c.pending.ResponseBytes += int64(len(pkt))
+	}
+	c.mu.Unlock()
+
 	switch c.state {
 	case stateIdle:
 		return
@@ -450,15 +711,20 @@ func (c *conn) captureUpstreamPacket(pkt []byte) {
 	case stateColumnDefs:
 		if isEOFPacket(pkt) {
 			c.state = stateRowData
+			return
 		}
+		c.describeColumn(pkt)
 
 	case stateRowData:
-		if isEOFPacket(pkt) {
+		switch {
+		case isEOFPacket(pkt):
 			c.finalizeResultSet(pkt)
 			c.state = stateIdle
-		} else if payloadByte(pkt) == iERR {
+		case payloadByte(pkt) == iERR:
 			c.finalizeError(pkt)
 			c.state = stateIdle
+		default:
+			c.sampleRow(pkt)
 		}
 
 	case stateSkipPrepare:
@@ -489,7 +755,76 @@ func (c *conn) handleFirstResponse(pkt []byte) {
 	default:
 		// Column count packet: transition to reading column definitions.
 		c.state = stateColumnDefs
+		c.markFirstRow()
+	}
+}
+
+// markFirstRow records the execute phase when a result set starts arriving.
+func (c *conn) markFirstRow() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil || !c.verbose {
+		return
 	}
+	c.firstRow = time.Now()
+	c.pending.Phases = append(c.pending.Phases, proxy.Phase{
+		Name:     "execute",
+		Duration: c.firstRow.Sub(c.pending.StartTime),
+	})
+}
+
+// sampleRow records a result row under detailed capture. Only text-protocol
+// rows (COM_QUERY) are decoded; binary rows from COM_STMT_EXECUTE are skipped.
+func (c *conn) sampleRow(pkt []byte) {
+	if c.lastCommand != comQuery {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.pending == nil || !c.verbose || len(c.pending.RowSamples) >= proxy.MaxRowSamples {
+		return
+	}
+	c.pending.RowSamples = append(c.pending.RowSamples, parseTextRow(pkt[4:]))
+}
+
+// parseTextRow decodes a text-protocol result row: a sequence of
+// length-encoded strings, where 0xFB marks NULL.
+func parseTextRow(payload []byte) []string {
+	var row []string
+	for off := 0; off < len(payload); {
+		if payload[off] == 0xFB {
+			row = append(row, proxy.SampleValue(nil))
+			off++
+			continue
+		}
+		length, n := readLenEncInt(payload, off)
+		if n == 0 {
+			break
+		}
+		off += n
+		end := off + int(length) //nolint:gosec // practically won't overflow
+		if end > len(payload) {
+			break
+		}
+		row = append(row, proxy.SampleValue(payload[off:end]))
+		off = end
+	}
+	return row
+}
+
+// finishPhases closes the execute/fetch phases of a verbose event. Caller holds mu.
+func (c *conn) finishPhases(ev *proxy.Event) {
+	if !c.verbose {
+		return
+	}
+	if c.firstRow.IsZero() {
+		ev.Phases = append(ev.Phases, proxy.Phase{Name: "execute", Duration: ev.Duration})
+		return
+	}
+	ev.Phases = append(ev.Phases, proxy.Phase{Name: "fetch", Duration: time.Since(c.firstRow)})
 }
 
 func (c *conn) handleStmtPrepareOK(pkt []byte) {
@@ -504,7 +839,7 @@ func (c *conn) handleStmtPrepareOK(pkt []byte) {
 	numColumns := binary.LittleEndian.Uint16(payload[5:7])
 	numParams := binary.LittleEndian.Uint16(payload[7:9])
 
-	c.preparedStmts[stmtID] = preparedStmt{query: c.lastQuery, numParams: int(numParams)}
+	c.preparedStmts[stmtID] = preparedStmt{query: c.lastQuery, numParams: int(numParams), violation: c.lastViolation}
 
 	// We need to skip param defs + EOF + column defs + EOF.
 	skip := 0
@@ -526,11 +861,14 @@ func (c *conn) finalizeOK(pkt []byte) {
 	c.mu.Lock()
 	ev := c.pending
 	c.pending = nil
+	if ev != nil {
+		ev.Duration = time.Since(ev.StartTime)
+		c.finishPhases(ev)
+	}
 	c.mu.Unlock()
 	if ev == nil {
 		return
 	}
-	ev.Duration = time.Since(ev.StartTime)
 
 	// Parse affected_rows from OK packet.
 	payload := pkt[4:]
@@ -546,18 +884,23 @@ func (c *conn) finalizeError(pkt []byte) {
 	c.mu.Lock()
 	ev := c.pending
 	c.pending = nil
+	if ev != nil {
+		ev.Duration = time.Since(ev.StartTime)
+		c.finishPhases(ev)
+	}
 	c.mu.Unlock()
 	if ev == nil {
 		return
 	}
-	ev.Duration = time.Since(ev.StartTime)
 
 	// Parse error message: ERR_Packet = 0xFF + errno(2) + '#' + sqlstate(5) + message
 	payload := pkt[4:]
 	if len(payload) > 9 && payload[3] == '#' {
 		ev.Error = string(payload[9:])
+		ev.ErrorDetail = &proxy.ErrorDetail{Code: string(payload[4:9]), Message: ev.Error}
 	} else if len(payload) > 3 {
 		ev.Error = string(payload[3:])
+		ev.ErrorDetail = &proxy.ErrorDetail{Message: ev.Error}
 	}
 
 	c.emitEvent(*ev)
@@ -567,11 +910,14 @@ func (c *conn) finalizeResultSet(_ []byte) {
 	c.mu.Lock()
 	ev := c.pending
 	c.pending = nil
+	if ev != nil {
+		ev.Duration = time.Since(ev.StartTime)
+		c.finishPhases(ev)
+	}
 	c.mu.Unlock()
 	if ev == nil {
 		return
 	}
-	ev.Duration = time.Since(ev.StartTime)
 
 	// Parse affected_rows from EOF packet (which has status flags but no row count).
 	// For SELECT, rows affected is typically 0.
@@ -720,11 +1066,16 @@ func readBinaryValue(data []byte, off int, typ byte) (string, int) {
 // ---------------- transaction detection ----------------
 
 type txDetectResult struct {
-	txID string
-	op   proxy.Op
+	txID       string
+	op         proxy.Op
+	globalTxID string // gtrid of an XA statement
 }
 
 func (c *conn) detectTx(query string, defaultOp proxy.Op) txDetectResult {
+	if tp, ok := proxy.ParseTwoPhase(query); ok {
+		return c.detectXA(tp, defaultOp)
+	}
+
 	upper := strings.ToUpper(strings.TrimSpace(query))
 	switch {
 	case strings.HasPrefix(upper, "BEGIN"), strings.HasPrefix(upper, "START TRANSACTION"):
@@ -742,10 +1093,103 @@ func (c *conn) detectTx(query string, defaultOp proxy.Op) txDetectResult {
 	return txDetectResult{txID: c.activeTxID, op: defaultOp}
 }
 
+// detectXA tracks an XA branch like a local transaction from XA START to
+// XA PREPARE, or to XA COMMIT ... ONE PHASE / XA ROLLBACK on the same session.
+// XA COMMIT/ROLLBACK of a prepared branch carries no TxID of its own.
+func (c *conn) detectXA(tp proxy.TwoPhase, defaultOp proxy.Op) txDetectResult {
+	switch tp.Kind {
+	case proxy.TwoPhaseStart:
+		c.activeTxID = uuid.New().String()
+		return txDetectResult{txID: c.activeTxID, op: proxy.OpBegin, globalTxID: tp.GlobalID}
+	case proxy.TwoPhaseEnd:
+		return txDetectResult{txID: c.activeTxID, op: defaultOp, globalTxID: tp.GlobalID}
+	case proxy.TwoPhasePrepare, proxy.TwoPhaseCommit, proxy.TwoPhaseRollback:
+	}
+	prev := c.activeTxID
+	c.activeTxID = ""
+	op := defaultOp
+	switch tp.Kind {
+	case proxy.TwoPhaseCommit:
+		op = proxy.OpCommit
+	case proxy.TwoPhaseRollback:
+		op = proxy.OpRollback
+	case proxy.TwoPhaseStart, proxy.TwoPhaseEnd, proxy.TwoPhasePrepare:
+	}
+	return txDetectResult{txID: prev, op: op, globalTxID: tp.GlobalID}
+}
+
 func (c *conn) emitEvent(ev proxy.Event) {
-	select {
-	case c.events <- ev:
+	ev.ClientAddr = c.clientAddr
+	ev.Listener = c.listener
+	ev.User = c.user
+	ev.Database = c.database
+	ev.BackendPID = c.connectionID
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+		c.queries.Add(1)
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel,
+		proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+	}
+	proxy.Emit(c.events, ev)
+}
+
+// emitConnect reports the connection's handshake, begun at start, as an
+// OpConnect event; err is the reason it failed, if it did.
+func (c *conn) emitConnect(start time.Time, err error) {
+	ev := proxy.Event{
+		ID:        c.generateID(),
+		ConnID:    c.id,
+		Op:        proxy.OpConnect,
+		StartTime: start,
+		Duration:  time.Since(start),
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		c.reported = true
+	}
+	c.emitEvent(ev)
+}
+
+// emitDisconnect reports the end of the connection opened at start as an
+// OpDisconnect event lasting its lifetime; err is the relay's failure, if
+// it did not end cleanly.
+func (c *conn) emitDisconnect(start time.Time, err error) {
+	ev := proxy.Event{
+		ID:        c.generateID(),
+		ConnID:    c.id,
+		Op:        proxy.OpDisconnect,
+		StartTime: start,
+		Duration:  time.Since(start),
+		Queries:   c.queries.Load(),
+		TxID:      c.activeTxID,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		c.reported = true
+	} else if c.idled.Load() {
+		ev.Error = fmt.Sprintf("mysql: closed after %s idle", c.idleTimeout)
+	}
+	c.emitEvent(ev)
+}
+
+// relayFailed logs err, the relay's failure, reporting it as a diagnostic
+// unless an event already records it. A client that left before its
+// handshake, as health checks do, is logged at debug level only.
+func (c *conn) relayFailed(err error) {
+	switch {
+	case c.reported || errors.Is(err, proxy.ErrPanic):
+		c.logger.Warn("relay failed", "conn_id", c.id, "client", c.clientAddr, "err", err)
+	case isClosedErr(err):
+		c.logger.Debug("client left before handshake", "conn_id", c.id, "client", c.clientAddr, "err", err)
 	default:
+		proxy.Emit(c.events, proxy.Diagnose(c.logger, slog.LevelWarn, "relay failed", err, proxy.Event{
+			ConnID:     c.id,
+			ClientAddr: c.clientAddr,
+			Listener:   c.listener,
+			User:       c.user,
+			Database:   c.database,
+			BackendPID: c.connectionID,
+		}))
 	}
 }
 
@@ -0,0 +1,129 @@
+package mysql
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// refuseTimeout bounds how long a refused client has to read the answer.
+const refuseTimeout = 5 * time.Second
+
+// admit takes one of the WithMaxConns slots for a new client connection,
+// reporting false when all are taken.
+func (p *Proxy) admit() bool {
+	if p.maxConns <= 0 {
+		return true
+	}
+	if p.open.Add(1) > int64(p.maxConns) {
+		p.open.Add(-1)
+		return false
+	}
+	return true
+}
+
+// leave frees the slot admit took.
+func (p *Proxy) leave() {
+	if p.maxConns > 0 {
+		p.open.Add(-1)
+	}
+}
+
+// refuse answers a client connection, accepted through spec, that the
+// proxy will not relay with an ERR packet in place of the server greeting,
+// as the server does when it cannot take a connection, and reports the
+// refusal as an OpConnect event failed with reason.
+func (p *Proxy) refuse(clientConn net.Conn, spec proxy.ListenSpec, connID string, code uint16, state, message string, reason error) {
+	c := newConn(connID, clientConn, nil, p.events, p.verbosity)
+	c.listener = spec.Label
+	c.newID = p.newID
+	start := time.Now()
+	_ = clientConn.SetDeadline(start.Add(refuseTimeout))
+	_ = writePacket(clientConn, errPacket(0, code, state, message))
+	c.emitConnect(start, reason)
+}
+
+// refuseFull turns away a client connection over the WithMaxConns limit
+// with the error the server gives past max_connections.
+func (p *Proxy) refuseFull(clientConn net.Conn, spec proxy.ListenSpec) {
+	defer func() { _ = clientConn.Close() }()
+	reason := fmt.Errorf("mysql: too many connections (limit %d)", p.maxConns)
+	p.refuse(clientConn, spec, proxy.NewConnID(), 1040, "08004", "Too many connections", reason)
+}
+
+// refuseClient turns away a client connection from outside spec's Allow
+// list with the error the server gives a host no account matches.
+func (p *Proxy) refuseClient(clientConn net.Conn, spec proxy.ListenSpec) {
+	defer func() { _ = clientConn.Close() }()
+	addr := clientConn.RemoteAddr().String()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	reason := fmt.Errorf("mysql: client %s is not allowed on %s", addr, spec.Addr)
+	p.refuse(clientConn, spec, proxy.NewConnID(), 1130, "HY000",
+		"Host '"+host+"' is not allowed to connect to this MySQL server", reason)
+}
+
+// errPacket builds an ERR packet with sequence ID seq.
+func errPacket(seq byte, code uint16, state, message string) []byte {
+	payload := make([]byte, 0, 9+len(message))
+	payload = append(payload, iERR)
+	payload = binary.LittleEndian.AppendUint16(payload, code)
+	payload = append(payload, '#')
+	payload = append(payload, state...)
+	payload = append(payload, message...)
+	pkt := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}
+	return append(pkt, payload...)
+}
+
+// watchIdle starts the idle timeout, which closes the connection once
+// neither side has sent a packet for c.idleTimeout while no statement is
+// running.
+func (c *conn) watchIdle() {
+	if c.idleTimeout <= 0 {
+		return
+	}
+	c.touch()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idleTimer = time.AfterFunc(c.idleTimeout, c.checkIdle)
+}
+
+// touch records traffic on the connection for the idle timeout.
+func (c *conn) touch() {
+	if c.idleTimeout > 0 {
+		c.active.Store(time.Now().UnixNano())
+	}
+}
+
+// checkIdle closes the connection if it has been idle for the timeout and
+// otherwise waits for the next time it could be.
+func (c *conn) checkIdle() {
+	c.mu.Lock()
+	idle := time.Since(time.Unix(0, c.active.Load()))
+	switch {
+	case c.closed:
+		c.mu.Unlock()
+		return
+	case c.pending != nil:
+		c.idleTimer.Reset(c.idleTimeout)
+		c.mu.Unlock()
+		return
+	case idle < c.idleTimeout:
+		c.idleTimer.Reset(c.idleTimeout - idle)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	// The error the server sends when wait_timeout closes a connection.
+	c.idled.Store(true)
+	_ = writePacket(c.clientConn, errPacket(0, 4031, "HY000",
+		"The client was disconnected by the server because of inactivity."))
+	_ = c.clientConn.Close()
+	_ = c.upstreamConn.Close()
+}
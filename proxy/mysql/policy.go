@@ -0,0 +1,61 @@
+package mysql
+
+import (
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// enforce checks the statement of a COM_QUERY or COM_STMT_PREPARE against
+// the policy, leaving its verdict in c.violation for the capture. A blocked
+// statement is not forwarded: enforce answers it with an ERR packet, 1227
+// (ER_SPECIFIC_ACCESS_DENIED_ERROR), reports it as a failed event, and
+// returns true. The server is idle while a client sends a command, so the
+// answer cannot cross one of its responses.
+func (c *conn) enforce(pkt []byte) (bool, error) {
+	c.violation = nil
+	if payloadLen(pkt) < 1 {
+		return false, nil
+	}
+	op := proxy.OpQuery
+	switch payloadByte(pkt) {
+	case comQuery:
+	case comStmtPrepare:
+		op = proxy.OpPrepare
+	default:
+		return false, nil
+	}
+	q := string(pkt[5:])
+	v := c.policy.Check(proxy.Event{
+		ConnID:     c.id,
+		ClientAddr: c.clientAddr,
+		Listener:   c.listener,
+		User:       c.user,
+		Database:   c.database,
+		Op:         op,
+		Query:      q,
+	})
+	c.violation = v
+	if v == nil || !v.Blocked {
+		return false, nil
+	}
+
+	c.touch()
+	c.logger.Warn("policy violation", "conn_id", c.id, "client", c.clientAddr, "user", c.user, "rule", v.Rule)
+	ev := proxy.Event{
+		ID:           c.generateID(),
+		ConnID:       c.id,
+		Op:           op,
+		Query:        q,
+		StartTime:    time.Now(),
+		TxID:         c.activeTxID,
+		RequestBytes: int64(len(pkt)),
+		Error:        v.Message,
+		ErrorDetail:  &proxy.ErrorDetail{Code: "42000", Message: v.Message},
+		Violation:    v,
+	}
+	resp := errPacket(pkt[3]+1, 1227, "42000", v.Message)
+	ev.ResponseBytes = int64(len(resp))
+	c.emitEvent(ev)
+	return true, writePacket(c.clientConn, resp)
+}
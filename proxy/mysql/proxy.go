@@ -1,34 +1,147 @@
 package mysql
 
 import (
+	"cmp"
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mickamy/sql-tap/proxy"
 )
 
 var _ proxy.Proxy = (*Proxy)(nil)
 
-// Proxy is a TCP proxy that sits between a MySQL client and server,
+// Proxy is a proxy that sits between a MySQL client and server,
 // capturing query events from the wire protocol.
 type Proxy struct {
-	listenAddr   string
+	listens      []proxy.ListenSpec
 	upstreamAddr string
+	verbosity    *proxy.Verbosity
+	dialer       proxy.Dialer
+	newID        proxy.IDGenerator
+	maxConns     int
+	open         atomic.Int64 // client connections holding a maxConns slot
+	idleTimeout  time.Duration
+	policy       proxy.Policy
+	logger       *slog.Logger
 	events       chan proxy.Event
-	listener     net.Listener
+	listeners    []net.Listener
 	wg           sync.WaitGroup
 }
 
-// New creates a new MySQL proxy.
-func New(listenAddr, upstreamAddr string) *Proxy {
-	return &Proxy{
-		listenAddr:   listenAddr,
+// Option configures a Proxy.
+type Option func(*Proxy)
+
+// WithListeners accepts clients on each of specs instead of the listen
+// address given to New, e.g. on both 127.0.0.1:3307 and [::1]:3307. Each
+// event carries the Label of the listener its client connected through, and
+// a client outside a listener's Allow list gets the error the server gives
+// a host no account matches, 1130, with an OpConnect event recording the
+// refusal.
+func WithListeners(specs ...proxy.ListenSpec) Option {
+	return func(p *Proxy) {
+		p.listens = specs
+	}
+}
+
+// WithVerbosity enables detailed capture for connections marked verbose in v.
+func WithVerbosity(v *proxy.Verbosity) Option {
+	return func(p *Proxy) {
+		p.verbosity = v
+	}
+}
+
+// WithDialTimeout bounds how long each client connection waits for its
+// upstream connection (default proxy.DefaultDialTimeout). A negative value
+// leaves only the proxy's context to end the dial. A client whose upstream
+// cannot be reached gets an ERR packet, and an OpConnect event records the
+// failure.
+func WithDialTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.dialer.Timeout = d
+	}
+}
+
+// WithKeepAlive sets the TCP keepalive period of upstream connections. A
+// negative value disables keepalives.
+func WithKeepAlive(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.dialer.KeepAlive = d
+	}
+}
+
+// WithLocalAddr dials TCP upstreams from addr, an IP or IP:port, e.g. to
+// pick the interface or source address a firewall allows.
+func WithLocalAddr(addr string) Option {
+	return func(p *Proxy) {
+		p.dialer.LocalAddr = addr
+	}
+}
+
+// WithIDGenerator sets how event IDs are made (see proxy.IDGenerator).
+func WithIDGenerator(g proxy.IDGenerator) Option {
+	return func(p *Proxy) {
+		p.newID = g
+	}
+}
+
+// WithMaxConns limits the proxy to n client connections at a time. Past
+// it, clients get the error the server gives past max_connections, 1040
+// "Too many connections", and an OpConnect event records each refusal.
+// Zero, the default, leaves connections unlimited.
+func WithMaxConns(n int) Option {
+	return func(p *Proxy) {
+		p.maxConns = n
+	}
+}
+
+// WithIdleTimeout closes client connections on which neither side has sent
+// anything for d while no statement runs, telling the client with the
+// error the server sends when wait_timeout closes a connection, 4031. The
+// connection's OpDisconnect event records the timeout. Zero, the default,
+// leaves idle connections open.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.idleTimeout = d
+	}
+}
+
+// WithPolicy checks each COM_QUERY and COM_STMT_PREPARE against pol before
+// forwarding it. The proxy answers a statement pol blocks itself, with
+// error 1227 (SQLSTATE 42000) and the violation's message, and the
+// statement's event carries the Violation; the server never sees it.
+func WithPolicy(pol proxy.Policy) Option {
+	return func(p *Proxy) {
+		p.policy = pol
+	}
+}
+
+// WithLogger sets the logger for the proxy's connection errors; by default
+// they go to slog.Default(). Errors that no Connect or Disconnect event
+// records are also emitted as OpAdvisory events carrying a
+// proxy.Diagnostic.
+func WithLogger(l *slog.Logger) Option {
+	return func(p *Proxy) {
+		p.logger = l
+	}
+}
+
+// New creates a new MySQL proxy. Either address may be a unix socket
+// path (see proxy.Network); WithListeners replaces listenAddr.
+func New(listenAddr, upstreamAddr string, opts ...Option) *Proxy {
+	p := &Proxy{
+		listens:      []proxy.ListenSpec{{Addr: listenAddr}},
 		upstreamAddr: upstreamAddr,
 		events:       make(chan proxy.Event, 256),
 	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
 }
 
 // Events returns the channel of captured events.
@@ -38,57 +151,74 @@ func (p *Proxy) Events() <-chan proxy.Event {
 
 // ListenAndServe starts accepting client connections and relaying them to MySQL.
 func (p *Proxy) ListenAndServe(ctx context.Context) error {
-	var lc net.ListenConfig
-	lis, err := lc.Listen(ctx, "tcp", p.listenAddr)
+	lis, err := proxy.ListenAll(ctx, p.listens)
 	if err != nil {
 		return fmt.Errorf("mysql: listen: %w", err)
 	}
-	p.listener = lis
+	p.listeners = lis
 
 	go func() {
 		<-ctx.Done()
-		_ = lis.Close()
+		_ = proxy.CloseAll(lis)
 	}()
 
-	for {
-		clientConn, err := lis.Accept()
-		if err != nil {
-			if ctx.Err() != nil {
-				return fmt.Errorf("mysql: accept: %w", ctx.Err())
-			}
-			return fmt.Errorf("mysql: accept: %w", err)
-		}
-
+	err = proxy.AcceptAll(lis, p.listens, func(clientConn net.Conn, spec proxy.ListenSpec) {
 		p.wg.Go(func() {
-			p.handleConn(ctx, clientConn)
+			if !spec.Allows(clientConn.RemoteAddr()) {
+				p.refuseClient(clientConn, spec)
+				return
+			}
+			if !p.admit() {
+				p.refuseFull(clientConn, spec)
+				return
+			}
+			defer p.leave()
+			p.handleConn(ctx, clientConn, spec)
 		})
+	})
+	if ctx.Err() != nil {
+		return fmt.Errorf("mysql: accept: %w", ctx.Err())
 	}
+	return fmt.Errorf("mysql: accept: %w", err)
 }
 
 // Close stops the proxy and waits for all connections to finish.
 func (p *Proxy) Close() error {
-	if p.listener != nil {
-		if err := p.listener.Close(); err != nil {
-			return fmt.Errorf("mysql: close listener: %w", err)
-		}
+	if err := proxy.CloseAll(p.listeners); err != nil {
+		return fmt.Errorf("mysql: close listener: %w", err)
 	}
 	p.wg.Wait()
 	return nil
 }
 
-func (p *Proxy) handleConn(ctx context.Context, clientConn net.Conn) {
+func (p *Proxy) handleConn(ctx context.Context, clientConn net.Conn, spec proxy.ListenSpec) {
 	defer func() { _ = clientConn.Close() }()
 
-	var d net.Dialer
-	upstreamConn, err := d.DialContext(ctx, "tcp", p.upstreamAddr)
+	connID := proxy.NewConnID()
+	upstreamConn, err := p.dialer.DialContext(ctx, p.upstreamAddr)
 	if err != nil {
-		log.Printf("mysql: dial upstream %s: %v", p.upstreamAddr, err)
+		p.log().Warn("dial upstream failed", "conn_id", connID, "client", clientConn.RemoteAddr().String(),
+			"upstream", p.upstreamAddr, "err", err)
+		p.refuse(clientConn, spec, connID, 2003, "HY000", "sql-tap: could not connect to the server",
+			fmt.Errorf("mysql: dial upstream: %w", err))
 		return
 	}
 	defer func() { _ = upstreamConn.Close() }()
+	p.verbosity.Open(connID)
+	defer p.verbosity.Close(connID)
 
-	c := newConn(clientConn, upstreamConn, p.events)
-	if err := c.relay(ctx); err != nil {
-		log.Printf("mysql: relay %s: %v", clientConn.RemoteAddr(), err)
+	c := newConn(connID, clientConn, upstreamConn, p.events, p.verbosity)
+	c.listener = spec.Label
+	c.newID = p.newID
+	c.logger = p.log()
+	c.idleTimeout = p.idleTimeout
+	c.policy = p.policy
+	if err := c.guard("relay", func() error { return c.relay(ctx) }); err != nil {
+		c.relayFailed(err)
 	}
 }
+
+// log returns the logger set by WithLogger, or the default.
+func (p *Proxy) log() *slog.Logger {
+	return cmp.Or(p.logger, slog.Default())
+}
@@ -3,13 +3,18 @@ package mysql_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/modules/mysql"
+	"github.com/testcontainers/testcontainers-go/wait"
 
 	"github.com/mickamy/sql-tap/proxy"
 	mproxy "github.com/mickamy/sql-tap/proxy/mysql"
@@ -24,13 +29,27 @@ const (
 // startMySQL launches a MySQL container and returns its host:port address.
 func startMySQL(t *testing.T) string {
 	t.Helper()
+	return startServer(t, "mysql:8")
+}
+
+// startMariaDB launches a MariaDB container, whose server advertises
+// extended capabilities and defaults to mysql_native_password.
+func startMariaDB(t *testing.T) string {
+	t.Helper()
+	return startServer(t, "mariadb:11",
+		testcontainers.WithWaitStrategy(wait.ForLog("port: 3306  mariadb.org binary distribution")))
+}
+
+func startServer(t *testing.T, image string, opts ...testcontainers.ContainerCustomizer) string {
+	t.Helper()
 
 	ctx := t.Context()
-	ctr, err := mysql.Run(ctx, "mysql:8",
+	opts = append([]testcontainers.ContainerCustomizer{
 		mysql.WithDatabase(testDB),
 		mysql.WithUsername(testUser),
 		mysql.WithPassword(testPassword),
-	)
+	}, opts...)
+	ctr, err := mysql.Run(ctx, image, opts...)
 	if err != nil {
 		t.Fatalf("start mysql container: %v", err)
 	}
@@ -51,7 +70,7 @@ func startMySQL(t *testing.T) string {
 	return fmt.Sprintf("%s:%s", host, port.Port())
 }
 
-func startProxy(t *testing.T, upstream string) (*mproxy.Proxy, string) {
+func startProxy(t *testing.T, upstream string, opts ...mproxy.Option) (*mproxy.Proxy, string) {
 	t.Helper()
 
 	// Find an available port.
@@ -63,7 +82,7 @@ func startProxy(t *testing.T, upstream string) (*mproxy.Proxy, string) {
 	addr := lis.Addr().String()
 	_ = lis.Close()
 
-	p := mproxy.New(addr, upstream)
+	p := mproxy.New(addr, upstream, opts...)
 	ctx, cancel := context.WithCancel(t.Context())
 
 	go func() {
@@ -104,7 +123,18 @@ func openDB(t *testing.T, addr string) *sql.DB {
 	return db
 }
 
+// waitEvent returns the next event that is not a connect or disconnect,
+// which most tests do not look at.
 func waitEvent(t *testing.T, ch <-chan proxy.Event) proxy.Event {
+	t.Helper()
+	for {
+		if ev := waitAny(t, ch); ev.Op != proxy.OpConnect && ev.Op != proxy.OpDisconnect {
+			return ev
+		}
+	}
+}
+
+func waitAny(t *testing.T, ch <-chan proxy.Event) proxy.Event {
 	t.Helper()
 	select {
 	case ev := <-ch:
@@ -115,6 +145,33 @@ func waitEvent(t *testing.T, ch <-chan proxy.Event) proxy.Event {
 	}
 }
 
+func TestConnectionEvents(t *testing.T) {
+	t.Parallel()
+	upstream := startMySQL(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+	db.SetMaxOpenConns(1)
+
+	for range 2 {
+		if _, err := db.ExecContext(t.Context(), "SELECT 1"); err != nil {
+			t.Fatalf("exec: %v", err)
+		}
+	}
+	_ = db.Close()
+
+	connect := waitAny(t, p.Events())
+	if connect.Op != proxy.OpConnect || connect.User != testUser || connect.Database != testDB || connect.Error != "" {
+		t.Fatalf("expected a clean connect by %s@%s, got %+v", testUser, testDB, connect)
+	}
+	for range 2 {
+		waitEvent(t, p.Events())
+	}
+	disconnect := waitAny(t, p.Events())
+	if disconnect.Op != proxy.OpDisconnect || disconnect.ConnID != connect.ConnID || disconnect.Queries != 2 {
+		t.Fatalf("expected a disconnect of conn %s after 2 queries, got %+v", connect.ConnID, disconnect)
+	}
+}
+
 func TestSimpleQuery(t *testing.T) {
 	t.Parallel()
 	upstream := startMySQL(t)
@@ -133,6 +190,34 @@ func TestSimpleQuery(t *testing.T) {
 	if ev.Error != "" {
 		t.Errorf("unexpected error: %q", ev.Error)
 	}
+	if ev.BackendPID == 0 {
+		t.Error("expected the backend PID to be captured")
+	}
+}
+
+func TestMariaDB(t *testing.T) {
+	t.Parallel()
+	upstream := startMariaDB(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+
+	if _, err := db.ExecContext(t.Context(), "SELECT 1"); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	ev := waitEvent(t, p.Events())
+	if ev.Error != "" || ev.User != testUser || ev.Database != testDB {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+
+	// Binary result sets still carry column definitions.
+	var n int
+	if err := db.QueryRowContext(t.Context(), "SELECT ? + 1", 41).Scan(&n); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	ev = waitEvent(t, p.Events())
+	if n != 42 || ev.Op != proxy.OpExecute || len(ev.Args) != 1 || ev.Args[0] != "41" {
+		t.Errorf("unexpected result %d or event: %+v", n, ev)
+	}
 }
 
 func TestSelectRows(t *testing.T) {
@@ -166,6 +251,12 @@ func TestSelectRows(t *testing.T) {
 	if ev.Query != "SELECT 1 UNION SELECT 2 UNION SELECT 3" {
 		t.Errorf("unexpected query: %q", ev.Query)
 	}
+	if want := []proxy.Column{{Name: "1", Type: "BIGINT"}}; !slices.Equal(ev.Columns, want) {
+		t.Errorf("columns = %v, want %v", ev.Columns, want)
+	}
+	if ev.RequestBytes == 0 || ev.ResponseBytes == 0 {
+		t.Errorf("byte counts = %d/%d, want both counted", ev.RequestBytes, ev.ResponseBytes)
+	}
 }
 
 func TestExecDDL(t *testing.T) {
@@ -186,6 +277,67 @@ func TestExecDDL(t *testing.T) {
 	}
 }
 
+func TestErrorDetail(t *testing.T) {
+	t.Parallel()
+	upstream := startMySQL(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+
+	if _, err := db.ExecContext(t.Context(), "SELECT * FROM _sql_tap_missing"); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+
+	ev := waitEvent(t, p.Events())
+	d := ev.ErrorDetail
+	if d == nil {
+		t.Fatalf("expected error detail, got error %q", ev.Error)
+	}
+	if d.Code != "42S02" {
+		t.Errorf("expected SQLSTATE 42S02, got %q", d.Code)
+	}
+	if d.Message != ev.Error {
+		t.Errorf("expected message %q, got %q", ev.Error, d.Message)
+	}
+}
+
+// policyFunc adapts a function to proxy.Policy.
+type policyFunc func(proxy.Event) *proxy.Violation
+
+func (f policyFunc) Check(ev proxy.Event) *proxy.Violation { return f(ev) }
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+	upstream := startMySQL(t)
+	noDrop := policyFunc(func(ev proxy.Event) *proxy.Violation {
+		if !strings.HasPrefix(ev.Query, "DROP") {
+			return nil
+		}
+		return &proxy.Violation{Rule: "no-drop", Message: "no drops", Blocked: true}
+	})
+	p, addr := startProxy(t, upstream, mproxy.WithPolicy(noDrop))
+	db := openDB(t, addr)
+
+	if _, err := db.ExecContext(t.Context(), "CREATE TABLE _sql_tap_policy (id INT)"); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	waitEvent(t, p.Events())
+
+	_, err := db.ExecContext(t.Context(), "DROP TABLE _sql_tap_policy")
+	var myErr *mysqldriver.MySQLError
+	if !errors.As(err, &myErr) || myErr.Number != 1227 || myErr.Message != "no drops" {
+		t.Fatalf("drop: err = %v, want the policy's error", err)
+	}
+	ev := waitEvent(t, p.Events())
+	if ev.Query != "DROP TABLE _sql_tap_policy" || ev.Error != "no drops" || ev.Violation == nil || !ev.Violation.Blocked {
+		t.Errorf("drop event = %+v", ev)
+	}
+
+	// The connection is still in step with the server, and the table is there.
+	if _, err := db.ExecContext(t.Context(), "SELECT * FROM _sql_tap_policy"); err != nil {
+		t.Errorf("select after the blocked drop: %v", err)
+	}
+}
+
 func TestInsertAffectedRows(t *testing.T) {
 	t.Parallel()
 	upstream := startMySQL(t)
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// SocketMode is the permission mode of listening unix sockets. Like the
+// PostgreSQL and MySQL servers' own sockets, anyone who can reach the socket's
+// directory may connect; restrict access through the directory.
+const SocketMode fs.FileMode = 0o777
+
+// Network splits addr into a network and address for net.Dial and
+// net.Listen: "unix:///path" or "/path" is a unix socket, anything else TCP.
+func Network(addr string) (network, address string) {
+	if path, ok := strings.CutPrefix(addr, "unix://"); ok {
+		return "unix", path
+	}
+	if strings.HasPrefix(addr, "/") {
+		return "unix", addr
+	}
+	return "tcp", addr
+}
+
+// Listen listens on addr (see Network), taking over the listener inherited
+// for addr through ListenFDsEnv if there is one. For a unix socket, a stale
+// socket file left by a previous run is removed first, the socket is made
+// accessible per SocketMode, and closing the listener removes the file.
+func Listen(ctx context.Context, addr string) (net.Listener, error) {
+	if lis, ok, err := inheritedListener(addr); ok {
+		if err != nil {
+			return nil, err
+		}
+		return track(addr, lis), nil
+	}
+	network, address := Network(addr)
+	if network == "unix" {
+		if err := removeStaleSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	var lc net.ListenConfig
+	lis, err := lc.Listen(ctx, network, address)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // callers add context
+	}
+	if network == "unix" {
+		if err := os.Chmod(address, SocketMode); err != nil {
+			_ = lis.Close()
+			return nil, fmt.Errorf("chmod %s: %w", address, err)
+		}
+	}
+	return track(addr, lis), nil
+}
+
+// removeStaleSocket removes the socket at path if nothing is listening on it.
+// Paths that are not sockets are left alone, so a typo cannot delete a file.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("stat %s: %w", path, err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and is not a socket", path)
+	}
+	if c, err := net.Dial("unix", path); err == nil {
+		_ = c.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("remove stale socket %s: %w", path, err)
+	}
+	return nil
+}
+
+// DefaultDialTimeout bounds how long a client connection waits for its
+// upstream connection, so an unreachable upstream fails the client quickly
+// instead of after the operating system's connect timeout.
+const DefaultDialTimeout = 10 * time.Second
+
+// Dialer holds the settings for upstream connections. The zero value uses
+// DefaultDialTimeout and the system's defaults otherwise.
+type Dialer struct {
+	// Timeout bounds each dial; 0 uses DefaultDialTimeout and a negative
+	// value leaves only the context's deadline.
+	Timeout time.Duration
+	// KeepAlive is the TCP keepalive period; 0 uses Go's default (15s) and
+	// a negative value disables keepalives.
+	KeepAlive time.Duration
+	// LocalAddr is the local IP, or IP and port, that TCP upstreams are
+	// dialed from; empty lets the system choose.
+	LocalAddr string
+}
+
+// DialContext connects to addr (see Network).
+func (d Dialer) DialContext(ctx context.Context, addr string) (net.Conn, error) {
+	network, address := Network(addr)
+	nd := net.Dialer{Timeout: d.Timeout, KeepAlive: d.KeepAlive}
+	switch {
+	case d.Timeout == 0:
+		nd.Timeout = DefaultDialTimeout
+	case d.Timeout < 0:
+		nd.Timeout = 0
+	}
+	if d.LocalAddr != "" {
+		if network != "tcp" {
+			return nil, fmt.Errorf("local address %s applies to TCP upstreams only", d.LocalAddr)
+		}
+		local := d.LocalAddr
+		if _, _, err := net.SplitHostPort(local); err != nil {
+			local = net.JoinHostPort(local, "0")
+		}
+		la, err := net.ResolveTCPAddr("tcp", local)
+		if err != nil {
+			return nil, fmt.Errorf("local address %s: %w", d.LocalAddr, err)
+		}
+		nd.LocalAddr = la
+	}
+	return nd.DialContext(ctx, network, address) //nolint:wrapcheck // callers add context
+}
+
+// Dial connects to addr (see Network) with the zero Dialer.
+func Dial(ctx context.Context, addr string) (net.Conn, error) {
+	return Dialer{}.DialContext(ctx, addr)
+}
@@ -0,0 +1,175 @@
+package proxy_test
+
+import (
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestNetwork(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		addr        string
+		wantNetwork string
+		wantAddress string
+	}{
+		{addr: "localhost:5432", wantNetwork: "tcp", wantAddress: "localhost:5432"},
+		{addr: ":3306", wantNetwork: "tcp", wantAddress: ":3306"},
+		{addr: "/var/run/postgresql/.s.PGSQL.5432", wantNetwork: "unix", wantAddress: "/var/run/postgresql/.s.PGSQL.5432"},
+		{addr: "unix:///tmp/mysql.sock", wantNetwork: "unix", wantAddress: "/tmp/mysql.sock"},
+		{addr: "unix://mysql.sock", wantNetwork: "unix", wantAddress: "mysql.sock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			t.Parallel()
+			network, address := proxy.Network(tt.addr)
+			if network != tt.wantNetwork || address != tt.wantAddress {
+				t.Errorf("Network(%q) = %q, %q; want %q, %q", tt.addr, network, address, tt.wantNetwork, tt.wantAddress)
+			}
+		})
+	}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	t.Parallel()
+
+	// Unix socket paths are limited to ~100 bytes, too short for t.TempDir on
+	// some systems.
+	dir, err := os.MkdirTemp("", "tap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "s.sock")
+
+	lis, err := proxy.Listen(t.Context(), "unix://"+path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 || info.Mode().Perm() != proxy.SocketMode {
+		t.Errorf("socket mode = %v, want socket with %v", info.Mode(), proxy.SocketMode)
+	}
+
+	go func() {
+		if c, err := lis.Accept(); err == nil {
+			_ = c.Close()
+		}
+	}()
+	conn, err := proxy.Dial(t.Context(), path)
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	_ = conn.Close()
+
+	if _, err := proxy.Listen(t.Context(), path); err == nil {
+		t.Error("second Listen on a live socket succeeded")
+	}
+
+	if err := lis.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("socket still exists after Close: %v", err)
+	}
+}
+
+func TestListen_StaleSocket(t *testing.T) {
+	t.Parallel()
+
+	dir, err := os.MkdirTemp("", "tap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+	path := filepath.Join(dir, "s.sock")
+
+	// A crashed process leaves its socket file behind.
+	stale, err := proxy.Listen(t.Context(), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ul, ok := stale.(interface{ SetUnlinkOnClose(bool) }); ok {
+		ul.SetUnlinkOnClose(false)
+	}
+	_ = stale.Close()
+
+	lis, err := proxy.Listen(t.Context(), path)
+	if err != nil {
+		t.Fatalf("Listen over a stale socket: %v", err)
+	}
+	_ = lis.Close()
+}
+
+func TestListen_RefusesRegularFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(path, []byte("keep me"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := proxy.Listen(t.Context(), path); err == nil {
+		t.Fatal("Listen over a regular file succeeded")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("regular file was removed: %v", err)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	t.Parallel()
+
+	var lc net.ListenConfig
+	lis, err := lc.Listen(t.Context(), "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+
+	conn, err := proxy.Dialer{LocalAddr: "127.0.0.1", KeepAlive: -1}.DialContext(t.Context(), lis.Addr().String())
+	if err != nil {
+		t.Fatalf("DialContext: %v", err)
+	}
+	if ip := conn.LocalAddr().(*net.TCPAddr).IP; !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("local address = %v, want 127.0.0.1", conn.LocalAddr())
+	}
+	_ = conn.Close()
+
+	tests := []struct {
+		name   string
+		dialer proxy.Dialer
+		addr   string
+	}{
+		{name: "timeout", dialer: proxy.Dialer{Timeout: time.Nanosecond}, addr: lis.Addr().String()},
+		{name: "bad local address", dialer: proxy.Dialer{LocalAddr: "not an ip:x"}, addr: lis.Addr().String()},
+		{name: "local address on a unix socket", dialer: proxy.Dialer{LocalAddr: "127.0.0.1"}, addr: "/tmp/none.sock"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if c, err := tt.dialer.DialContext(t.Context(), tt.addr); err == nil {
+				_ = c.Close()
+				t.Fatal("DialContext succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// batch tracks the statements answered before one ReadyForQuery. When the
+// client pipelined two or more Executes before the Sync, as pgx SendBatch
+// and JDBC executeBatch do, the events share a BatchID and are summarized
+// in an OpBatch event once the Sync is answered.
+type batch struct {
+	id      string        // set once the segment is known to be a batch
+	done    int           // events completed
+	skipped int           // Executes the server skipped after an error
+	sum     proxy.Event   // totals for the summary
+	held    []proxy.Event // completed before the client sent the Sync
+}
+
+// emitCompleted emits the finished event p, stamped with its batch. While
+// the client has yet to send the Sync, a lone event might still be joined
+// by others, so it is held until that is known.
+func (c *conn) emitCompleted(p *inflight) {
+	c.mu.Lock()
+	b := &c.batch
+	b.done++
+	b.add(*p.ev)
+	queued := 0
+	for _, q := range c.pending {
+		if q.seg == p.seg {
+			queued++
+		}
+	}
+	var out []proxy.Event
+	switch {
+	case b.done+queued > 1:
+		if b.id == "" {
+			b.id = uuid.New().String()
+		}
+		out = append(b.held, *p.ev)
+		b.held = nil
+		for i := range out {
+			out[i].BatchID = b.id
+		}
+	case c.synced <= p.seg:
+		b.held = append(b.held, *p.ev)
+	default:
+		out = []proxy.Event{*p.ev}
+	}
+	c.mu.Unlock()
+
+	for _, ev := range out {
+		c.emitEvent(ev)
+	}
+}
+
+// endBatch returns the events to emit once the Sync segment is answered:
+// any still held and, for a batch, its summary. Caller holds mu.
+func (c *conn) endBatch() []proxy.Event {
+	b := c.batch
+	c.batch = batch{}
+	out := b.held
+	if b.id == "" {
+		return out
+	}
+	sum := b.sum
+	sum.ID = c.generateID()
+	sum.ConnID = c.id
+	sum.Op = proxy.OpBatch
+	sum.Duration = time.Since(sum.StartTime)
+	sum.BatchID = b.id
+	sum.BatchSize = b.done + b.skipped
+	sum.TLSVersion = c.tlsVersion
+	sum.TLSCipher = c.tlsCipher
+	return append(out, sum)
+}
+
+// add accounts for a statement of the batch in its summary.
+func (b *batch) add(ev proxy.Event) {
+	s := &b.sum
+	if s.StartTime.IsZero() {
+		s.StartTime = ev.StartTime
+		s.Routing = ev.Routing
+	}
+	if s.TxID == "" {
+		s.TxID = ev.TxID
+	}
+	s.RowsAffected += ev.RowsAffected
+	s.RequestBytes += ev.RequestBytes
+	s.ResponseBytes += ev.ResponseBytes
+	if s.Error == "" {
+		s.Error = ev.Error
+		s.ErrorDetail = ev.ErrorDetail
+	}
+}
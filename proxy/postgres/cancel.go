@@ -0,0 +1,149 @@
+package postgres
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+const cancelRequestCode = 80877102
+
+// cancelTimeout bounds the wait for the server to close a cancel connection.
+const cancelTimeout = 5 * time.Second
+
+// errCancelRequest ends a relay that carried a CancelRequest instead of a
+// session; it is not a failure.
+var errCancelRequest = errors.New("postgres: cancel request")
+
+// backendKey is the process ID and secret key a server sends in
+// BackendKeyData; clients quote both in a CancelRequest.
+type backendKey struct {
+	pid    uint32
+	secret uint32
+}
+
+// backends maps the backend keys of live connections to their conn, so a
+// CancelRequest can be attributed to the query it cancels.
+type backends struct {
+	mu    sync.Mutex
+	conns map[backendKey]*conn
+}
+
+func newBackends() *backends {
+	return &backends{conns: make(map[backendKey]*conn)}
+}
+
+func (b *backends) add(key backendKey, c *conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.conns[key] = c
+}
+
+func (b *backends) remove(key backendKey, c *conn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conns[key] == c {
+		delete(b.conns, key)
+	}
+}
+
+func (b *backends) lookup(key backendKey) *conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.conns[key]
+}
+
+// lookupPID returns the connection served by backend pid, or nil.
+func (b *backends) lookupPID(pid uint32) *conn {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, c := range b.conns {
+		if key.pid == pid {
+			return c
+		}
+	}
+	return nil
+}
+
+// cancelRequest encodes a CancelRequest quoting k.
+func (k backendKey) cancelRequest() []byte {
+	raw := make([]byte, 16)
+	binary.BigEndian.PutUint32(raw[0:4], 16)
+	binary.BigEndian.PutUint32(raw[4:8], cancelRequestCode)
+	binary.BigEndian.PutUint32(raw[8:12], k.pid)
+	binary.BigEndian.PutUint32(raw[12:16], k.secret)
+	return raw
+}
+
+// parseBackendKeyData reads a raw BackendKeyData ('K') message.
+func parseBackendKeyData(msg []byte) (backendKey, bool) {
+	if len(msg) < 13 {
+		return backendKey{}, false
+	}
+	return backendKey{
+		pid:    binary.BigEndian.Uint32(msg[5:9]),
+		secret: binary.BigEndian.Uint32(msg[9:13]),
+	}, true
+}
+
+// relayCancel forwards a raw CancelRequest on the connection's own upstream
+// connection, which the server closes without replying, and emits an
+// OpCancel event for the query running on the targeted connection.
+func (c *conn) relayCancel(raw []byte) error {
+	start := time.Now()
+	if _, err := c.upstreamConn.Write(raw); err != nil {
+		return fmt.Errorf("postgres: send cancel request: %w", err)
+	}
+	_ = c.upstreamConn.SetReadDeadline(time.Now().Add(cancelTimeout))
+	_, _ = io.Copy(io.Discard, c.upstreamConn)
+	proxy.CountCancel(proxy.CancelRelayed)
+
+	key := backendKey{
+		pid:    binary.BigEndian.Uint32(raw[8:12]),
+		secret: binary.BigEndian.Uint32(raw[12:16]),
+	}
+	ev := proxy.Event{
+		Op:         proxy.OpCancel,
+		StartTime:  start,
+		Duration:   time.Since(start),
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+	}
+
+	target := c.backends.lookup(key)
+	if target == nil {
+		ev.ID = c.generateID()
+		ev.ConnID = c.id
+		c.stampConn(&ev)
+		proxy.Emit(c.events, ev)
+		return errCancelRequest
+	}
+	if target.router != nil {
+		if err := target.router.cancelReplica(); err != nil {
+			target.diagnose("replica cancel failed", err)
+		}
+	}
+	target.attributeCancel(&ev)
+	proxy.Emit(c.events, ev)
+	return errCancelRequest
+}
+
+// attributeCancel fills in the OpCancel event ev for a cancel aimed at c:
+// c's connection metadata and the query it was running.
+func (c *conn) attributeCancel(ev *proxy.Event) {
+	ev.ID = c.generateID()
+	ev.ConnID = c.id
+	c.stampConn(ev)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p := c.current(); p != nil {
+		ev.Query = p.ev.Query
+		ev.Args = p.ev.Args
+		ev.TxID = p.ev.TxID
+	}
+}
@@ -1,19 +1,25 @@
 package postgres
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"net"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	pgproto "github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v5/pgtype"
 
 	"github.com/mickamy/sql-tap/proxy"
 )
@@ -25,39 +31,175 @@ type encoder interface {
 
 // conn manages bidirectional relay and protocol parsing for a single connection.
 type conn struct {
+	id       string
 	client   *pgproto.Backend  // reads FrontendMessages from client
 	upstream *pgproto.Frontend // reads BackendMessages from upstream
 
 	clientConn   net.Conn
 	upstreamConn net.Conn
 	events       chan<- proxy.Event
+	logger       *slog.Logger
 
-	// Extended query state.
-	preparedStmts map[string]string // stmt name -> query
-	lastParse     string            // query from most recent Parse
-	lastBindArgs  []string          // args from most recent Bind
-	lastBindStmt  string            // stmt name from most recent Bind
+	// reported records that a Connect or Disconnect event carries the
+	// relay's failure, so it needs no diagnostic; relay goroutine only.
+	reported bool
+
+	// Connection metadata from the StartupMessage, stamped on every event.
+	clientAddr string
+	listener   string // label of the listener the client connected through
+	user       string
+	database   string
+
+	// The StartupMessage's parameters, redacted, and whether the client
+	// asked for SSL first; reported on the OpConnect event only.
+	startupParams map[string]string
+	sslRequested  bool
+
+	// Label appended to the client's application_name; empty leaves the
+	// StartupMessage as sent.
+	appNameLabel string
+
+	// Startup metadata from the authentication exchange, stamped on every event.
+	authMethod   string
+	authDuration time.Duration
+	serverParams map[string]string
+
+	// Client-side TLS termination; tlsConfig is nil when disabled.
+	tlsConfig  *tls.Config
+	tlsVersion string
+	tlsCipher  string
+
+	// Extended query state. The unnamed statement and portal use the key "".
+	preparedStmts *lru[*statement] // stmt name -> statement
+	portals       *lru[portal]     // portal name -> bound statement
+	params        typeDecoder      // binary Bind parameters; client relay only
+
+	// Pooler mode (see WithPooler); pooler is empty when disabled. Changes
+	// to the statements wait in parses and closes for the server's answer,
+	// then in settled for the client relay to apply them; all under mu.
+	pooler  PoolMode
+	session atomic.Uint64 // the logical session being carried, from 1
+	parses  []stmtChange
+	closes  []stmtChange
+	settled []stmtChange
+
+	// DECLAREd cursors by name; touched by the upstream relay only.
+	cursors map[string]*cursor
 
 	// Transaction tracking.
 	activeTxID string
-	nextID     uint64
+	nextID     atomic.Uint64 // also advanced by cancel requests targeting this conn
+	newID      proxy.IDGenerator
+	queries    atomic.Int64 // Query, Exec, and Execute events emitted
+
+	// Backend key from BackendKeyData, registered in backends for
+	// CancelRequest attribution.
+	backends   *backends
+	backendKey backendKey
+	hasKey     bool
+
+	// Detailed capture, toggled per connection at runtime.
+	verbosity *proxy.Verbosity
+
+	// Wire bytes of client messages since the last Query or Execute; client
+	// relay only.
+	requestBytes int64
 
-	mu      sync.Mutex   // protects pending
-	pending *proxy.Event // event waiting for upstream response
+	// Replica routing; router is nil when disabled. routing is the decision
+	// for the request being captured, touched by the client relay only.
+	router  *router
+	routing *proxy.Routing
+
+	// Statement policy; policy is nil when disabled. Blocked statements are
+	// sent as stand-ins, numbered by blocks and mapped back in blocked,
+	// under mu; checked is the last verdict. blocks and checked are touched
+	// by the client relay only.
+	policy  proxy.Policy
+	blocks  uint64
+	blocked map[string]blockedStmt
+	checked verdict
+
+	// Query timeout; timeout is nil when disabled. It cancels the running
+	// statement through the proxy.
+	queryTimeout time.Duration
+	timeout      func()
+
+	// Idle timeout; idleTimeout is 0 when disabled. active is when either
+	// side last sent a message, in Unix nanoseconds, and idled records that
+	// the timeout closed the connection.
+	idleTimeout time.Duration
+	idleTimer   *time.Timer // under mu
+	active      atomic.Int64
+	idled       atomic.Bool
+
+	mu      sync.Mutex  // protects pending and the detailed capture state below
+	pending []*inflight // events waiting for upstream responses, in request order
+	synced  uint64      // Query, Sync, and FunctionCall messages sent, each answered by one ReadyForQuery
+	ready   uint64      // ReadyForQuery messages received
+	batch   batch       // the Sync segment being answered
+	closed  bool        // the relay has ended; timeouts no longer fire
+	done    time.Time   // when the last pending event completed
+
+	parseSent    time.Time     // when the last Parse was forwarded (verbose only)
+	bindSent     time.Time     // when the last Bind was forwarded (verbose only)
+	stagedPhases []proxy.Phase // phases completed before their event was created
+
+	// Statement type tracking. Describe 'S' requests are queued in order;
+	// each is answered by a ParameterDescription, then a RowDescription or
+	// NoData, which fill in the statement's types.
+	describes  []*statement // nil entries for statements not tracked
+	describing *statement   // statement whose RowDescription is next
+	inDescribe bool         // a ParameterDescription was seen; its RowDescription/NoData is next
+	rows       typeDecoder  // binary DataRow values; under mu
 }
 
-func newConn(clientConn, upstreamConn net.Conn, events chan<- proxy.Event) *conn {
+// inflight is a Query or Execute awaiting its upstream response. A pipelined
+// client has several outstanding, answered in order; seg is the number of
+// the Query or Sync whose ReadyForQuery ends the group it was sent in.
+type inflight struct {
+	ev       *proxy.Event
+	seg      uint64
+	columns  []column    // result columns, when known
+	verbose  bool        // detailed capture enabled
+	firstRow time.Time   // when the first DataRow arrived
+	release  string      // on pooled connections, a DEALLOCATE or DISCARD applied on completion
+	timer    *time.Timer // fires the query timeout, if any
+}
+
+func newConn(
+	id string,
+	clientConn, upstreamConn net.Conn,
+	events chan<- proxy.Event,
+	tlsConfig *tls.Config,
+	verbosity *proxy.Verbosity,
+	backends *backends,
+) *conn {
 	return &conn{
+		id:            id,
 		clientConn:    clientConn,
 		upstreamConn:  upstreamConn,
 		events:        events,
-		preparedStmts: make(map[string]string),
+		clientAddr:    clientConn.RemoteAddr().String(),
+		tlsConfig:     tlsConfig,
+		verbosity:     verbosity,
+		backends:      backends,
+		preparedStmts: newLRU[*statement](maxTrackedStatements),
+		portals:       newLRU[portal](maxTrackedStatements),
+		cursors:       make(map[string]*cursor),
 	}
 }
 
+// portal is a statement bound to parameters by Bind.
+type portal struct {
+	query     string
+	args      []string
+	columns   []column         // result types and formats, when the statement was described
+	results   []proxy.Column   // the statement's described result columns
+	violation *proxy.Violation // the statement's, when the policy matched it
+}
+
 func (c *conn) generateID() string {
-	c.nextID++
-	return strconv.FormatUint(c.nextID, 10)
+	return c.newID.ID(c.id, c.nextID.Add(1))
 }
 
 // encodeAndWrite encodes a protocol message and writes it to dst.
@@ -73,23 +215,67 @@ func encodeAndWrite(dst net.Conn, msg encoder) error {
 }
 
 // relay handles the startup phase and then enters bidirectional message relay.
-func (c *conn) relay(ctx context.Context) error {
-	if err := c.relayStartup(); err != nil {
+func (c *conn) relay(ctx context.Context) (err error) {
+	start := time.Now()
+	if err := c.relayStartup(ctx); err != nil {
+		if errors.Is(err, errCancelRequest) {
+			return nil
+		}
+		if c.user != "" {
+			c.emitConnect(start, err)
+		}
 		return fmt.Errorf("postgres: startup: %w", err)
 	}
+	c.emitConnect(start, nil)
+	defer func() { c.emitDisconnect(start, err) }()
+	defer func() {
+		if c.hasKey {
+			c.backends.remove(c.backendKey, c)
+		}
+	}()
+	c.watchIdle()
+	var primary *session
+	if c.router != nil {
+		c.router.start(c)
+		primary = c.router.primary
+	}
 
-	errCh := make(chan error, 2)
+	clientCh := make(chan error, 1)
+	upstreamCh := make(chan error, 1)
 
-	go func() { errCh <- c.relayClientToUpstream(ctx) }()
-	go func() { errCh <- c.relayUpstreamToClient(ctx) }()
+	go func() { clientCh <- c.guard("client relay", func() error { return c.relayClientToUpstream(ctx) }) }()
+	go func() {
+		upstreamCh <- c.guard("upstream relay", func() error { return c.relayUpstreamToClient(ctx, primary) })
+	}()
 
 	// Wait for the first goroutine to finish (connection closed or error).
-	err := <-errCh
+	rest := clientCh
+	select {
+	case err = <-clientCh:
+		c.clientLeft()
+		rest = upstreamCh
+	case err = <-upstreamCh:
+	}
 	// Close both sides to unblock the other goroutine.
 	_ = c.clientConn.Close()
 	_ = c.upstreamConn.Close()
 	// Wait for the second goroutine.
-	<-errCh
+	<-rest
+	c.mu.Lock()
+	c.closed = true
+	for _, p := range c.pending {
+		p.stopTimer()
+	}
+	if c.idleTimer != nil {
+		c.idleTimer.Stop()
+	}
+	c.mu.Unlock()
+	if c.router != nil {
+		c.router.close()
+	}
+
+	// Report cursors the session never closed.
+	c.flushCursors(func(*cursor) bool { return true })
 
 	return err
 }
@@ -98,43 +284,46 @@ const (
 	sslRequestCode    = 80877103
 	gssEncRequestCode = 80877104
 
-	authTypeOk        = 0
-	authTypeSASLFinal = 12
+	authTypeOk                = 0
+	authTypeKerberosV5        = 2
+	authTypeCleartextPassword = 3
+	authTypeMD5Password       = 5
+	authTypeGSS               = 7
+	authTypeSSPI              = 9
+	authTypeSASL              = 10
+	authTypeSASLFinal         = 12
 )
 
+// authMethodNames names the authentication requests that start an exchange.
+// SASL is replaced by the mechanism the client picks.
+var authMethodNames = map[uint32]string{
+	authTypeKerberosV5:        "kerberos",
+	authTypeCleartextPassword: "password",
+	authTypeMD5Password:       "md5",
+	authTypeGSS:               "gss",
+	authTypeSSPI:              "sspi",
+	authTypeSASL:              "sasl",
+}
+
 // relayStartup handles the startup/auth phase using raw byte relay to avoid
 // re-encoding issues with SCRAM and other auth mechanisms. Protocol parsers
 // (Backend/Frontend) are created only after auth completes.
-func (c *conn) relayStartup() error {
-	// Handle SSLRequest / GSSEncRequest, then forward the real StartupMessage.
-	for {
-		raw, err := readStartupRaw(c.clientConn)
-		if err != nil {
-			return fmt.Errorf("postgres: read startup: %w", err)
-		}
-
-		// SSLRequest and GSSEncRequest are 8-byte messages with a specific code.
-		if len(raw) == 8 {
-			code := binary.BigEndian.Uint32(raw[4:])
-			switch code {
-			case sslRequestCode:
-				if _, err := c.clientConn.Write([]byte{'N'}); err != nil {
-					return fmt.Errorf("postgres: decline ssl: %w", err)
-				}
-				continue
-			case gssEncRequestCode:
-				if _, err := c.clientConn.Write([]byte{'N'}); err != nil {
-					return fmt.Errorf("postgres: decline gss: %w", err)
-				}
-				continue
-			}
-		}
-
-		if _, err := c.upstreamConn.Write(raw); err != nil {
-			return fmt.Errorf("postgres: send startup: %w", err)
-		}
-		break
+func (c *conn) relayStartup(ctx context.Context) error {
+	raw, cancel, err := c.readStartup(ctx)
+	if err != nil {
+		return err
+	}
+	if cancel {
+		return c.relayCancel(raw)
+	}
+	if c.appNameLabel != "" {
+		raw = labelAppName(raw, c.appNameLabel)
 	}
+	if _, err := c.upstreamConn.Write(raw); err != nil {
+		return fmt.Errorf("postgres: send startup: %w", err)
+	}
+	start := time.Now()
+	params := make(map[string]string)
 
 	// Relay auth messages as raw bytes until ReadyForQuery.
 	for {
@@ -149,20 +338,44 @@ func (c *conn) relayStartup() error {
 
 		switch msg[0] {
 		case 'Z': // ReadyForQuery — auth complete.
+			c.serverParams = params
 			c.client = pgproto.NewBackend(pgproto.NewChunkReader(c.clientConn), c.clientConn)
 			c.upstream = pgproto.NewFrontend(pgproto.NewChunkReader(c.upstreamConn), c.upstreamConn)
 			return nil
+		case 'K': // BackendKeyData
+			if key, ok := parseBackendKeyData(msg); ok {
+				c.backendKey, c.hasKey = key, true
+				c.backends.add(key, c)
+			}
+		case 'S': // ParameterStatus
+			if name, value, ok := parseParameterStatus(msg); ok {
+				params[name] = value
+			}
 		case 'E': // ErrorResponse
 			return errors.New("postgres: auth error from upstream")
 		case 'R': // Authentication message
 			if len(msg) >= 9 {
 				authType := binary.BigEndian.Uint32(msg[5:9])
+				if name, ok := authMethodNames[authType]; ok && c.authMethod == "" {
+					c.authMethod = name
+				}
+				if authType == authTypeOk {
+					if c.authMethod == "" {
+						c.authMethod = "trust"
+					}
+					c.authDuration = time.Since(start)
+				}
 				// AuthenticationOk and AuthenticationSASLFinal require no client response.
 				if authType != authTypeOk && authType != authTypeSASLFinal {
 					resp, err := readMessageRaw(c.clientConn)
 					if err != nil {
 						return fmt.Errorf("postgres: receive auth response: %w", err)
 					}
+					if authType == authTypeSASL {
+						if mech := parseSASLMechanism(resp); mech != "" {
+							c.authMethod = mech
+						}
+					}
 					if _, err := c.upstreamConn.Write(resp); err != nil {
 						return fmt.Errorf("postgres: send auth response: %w", err)
 					}
@@ -172,6 +385,74 @@ func (c *conn) relayStartup() error {
 	}
 }
 
+// readStartup handles the client's SSLRequest or GSSEncRequest, if any, and
+// reads its StartupMessage, recording the connection metadata it carries.
+// A CancelRequest is returned instead with cancel set, for the caller to
+// forward.
+func (c *conn) readStartup(ctx context.Context) (raw []byte, cancel bool, err error) {
+	for {
+		raw, err := readStartupRaw(c.clientConn)
+		if err != nil {
+			return nil, false, fmt.Errorf("postgres: read startup: %w", err)
+		}
+
+		// CancelRequest is 16 bytes: code, backend process ID, and secret key.
+		if len(raw) == 16 && binary.BigEndian.Uint32(raw[4:8]) == cancelRequestCode {
+			return raw, true, nil
+		}
+
+		// SSLRequest and GSSEncRequest are 8-byte messages with a specific code.
+		if len(raw) == 8 {
+			code := binary.BigEndian.Uint32(raw[4:])
+			switch code {
+			case sslRequestCode:
+				c.sslRequested = true
+				if c.tlsConfig == nil {
+					if _, err := c.clientConn.Write([]byte{'N'}); err != nil {
+						return nil, false, fmt.Errorf("postgres: decline ssl: %w", err)
+					}
+					continue
+				}
+				if err := c.acceptTLS(ctx); err != nil {
+					return nil, false, err
+				}
+				continue
+			case gssEncRequestCode:
+				if _, err := c.clientConn.Write([]byte{'N'}); err != nil {
+					return nil, false, fmt.Errorf("postgres: decline gss: %w", err)
+				}
+				continue
+			}
+		}
+
+		c.startupParams = parseStartupParams(raw)
+		c.user, c.database = c.startupParams["user"], c.startupParams["database"]
+		// The database defaults to the user name, as on the server.
+		if c.database == "" {
+			c.database = c.user
+		}
+		redactStartupParams(c.startupParams)
+		return raw, false, nil
+	}
+}
+
+// acceptTLS accepts an SSLRequest and upgrades the client connection to TLS.
+// The upstream connection stays plaintext.
+func (c *conn) acceptTLS(ctx context.Context) error {
+	if _, err := c.clientConn.Write([]byte{'S'}); err != nil {
+		return fmt.Errorf("postgres: accept ssl: %w", err)
+	}
+	tlsConn := tls.Server(c.clientConn, c.tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return fmt.Errorf("postgres: tls handshake: %w", err)
+	}
+	st := tlsConn.ConnectionState()
+	c.clientConn = tlsConn
+	c.tlsVersion = tls.VersionName(st.Version)
+	c.tlsCipher = tls.CipherSuiteName(st.CipherSuite)
+	return nil
+}
+
 // readStartupRaw reads a startup-format message (no type byte): 4-byte length + payload.
 func readStartupRaw(r io.Reader) ([]byte, error) {
 	var hdr [4]byte
@@ -190,6 +471,160 @@ func readStartupRaw(r io.Reader) ([]byte, error) {
 	return buf, nil
 }
 
+// parseStartupParams returns every name/value pair in a raw StartupMessage:
+// length, protocol version, then NUL-terminated name/value pairs ending with
+// an empty name.
+func parseStartupParams(raw []byte) map[string]string {
+	params := make(map[string]string)
+	if len(raw) < 8 {
+		return params
+	}
+	fields := bytes.Split(raw[8:], []byte{0})
+	for i := 0; i+1 < len(fields) && len(fields[i]) > 0; i += 2 {
+		params[string(fields[i])] = string(fields[i+1])
+	}
+	return params
+}
+
+// redacted replaces the values of sensitive startup parameters.
+const redacted = "[redacted]"
+
+// sensitiveParam reports whether a parameter or setting named name may hold
+// a credential.
+func sensitiveParam(name string) bool {
+	name = strings.ToLower(name)
+	for _, s := range []string{"pass", "secret", "token", "key", "credential"} {
+		if strings.Contains(name, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactStartupParams replaces, in place, the values of parameters that
+// may hold credentials, including "-c name=value" settings in options.
+func redactStartupParams(params map[string]string) {
+	for name, value := range params {
+		switch {
+		case sensitiveParam(name):
+			params[name] = redacted
+		case name == "options":
+			params[name] = redactOptions(value)
+		}
+	}
+}
+
+// redactOptions redacts the sensitive settings in an options parameter,
+// command-line arguments separated by spaces not escaped with a backslash,
+// such as "-c search_path=app -c app.api_key=xyz".
+func redactOptions(options string) string {
+	var args []string
+	var arg strings.Builder
+	escaped := false
+	for _, r := range options {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ' ':
+			args = append(args, arg.String())
+			arg.Reset()
+			continue
+		}
+		arg.WriteRune(r)
+	}
+	args = append(args, arg.String())
+
+	for i, a := range args {
+		setting := strings.TrimPrefix(strings.TrimPrefix(a, "--"), "-c")
+		if setting == a && (i == 0 || args[i-1] != "-c") {
+			continue // not a setting
+		}
+		if name, _, ok := strings.Cut(setting, "="); ok && sensitiveParam(name) {
+			args[i] = strings.TrimSuffix(a, setting) + name + "=" + redacted
+		}
+	}
+	return strings.Join(args, " ")
+}
+
+// maxAppNameLen is the longest application_name the server keeps
+// (NAMEDATALEN - 1); longer values are truncated.
+const maxAppNameLen = 63
+
+// labelAppName returns the raw StartupMessage with "(sql-tap <label>)"
+// appended to its application_name, adding the parameter if the client sent
+// none. The client's name is cut short so the label survives the server's
+// truncation.
+func labelAppName(raw []byte, label string) []byte {
+	if len(raw) < 8 {
+		return raw
+	}
+	suffix := "(sql-tap " + label + ")"
+	var params [][]byte
+	found := false
+	fields := bytes.Split(raw[8:], []byte{0})
+	for i := 0; i+1 < len(fields) && len(fields[i]) > 0; i += 2 {
+		name, value := fields[i], fields[i+1]
+		if string(name) == "application_name" {
+			found = true
+			value = []byte(appendAppNameLabel(string(value), suffix))
+		}
+		params = append(params, name, value)
+	}
+	if !found {
+		params = append(params, []byte("application_name"), []byte(appendAppNameLabel("", suffix)))
+	}
+
+	out := make([]byte, 8, len(raw)+len(suffix)+len("application_name")+4)
+	copy(out[4:8], raw[4:8]) // protocol version
+	for _, f := range params {
+		out = append(out, f...)
+		out = append(out, 0)
+	}
+	out = append(out, 0)
+	binary.BigEndian.PutUint32(out[:4], uint32(len(out))) //nolint:gosec // startup messages are small
+	return out
+}
+
+// appendAppNameLabel joins name and suffix, trimming name to fit
+// maxAppNameLen.
+func appendAppNameLabel(name, suffix string) string {
+	if name == "" {
+		return suffix
+	}
+	if keep := maxAppNameLen - len(suffix) - 1; len(name) > keep {
+		name = strings.ToValidUTF8(name[:max(keep, 0)], "")
+	}
+	return name + " " + suffix
+}
+
+// parseParameterStatus reads a raw ParameterStatus ('S') message: the
+// parameter's name and value, each NUL-terminated.
+func parseParameterStatus(msg []byte) (name, value string, ok bool) {
+	if len(msg) < 5 {
+		return "", "", false
+	}
+	fields := bytes.SplitN(msg[5:], []byte{0}, 3)
+	if len(fields) < 3 || len(fields[0]) == 0 {
+		return "", "", false
+	}
+	return string(fields[0]), string(fields[1]), true
+}
+
+// parseSASLMechanism returns the mechanism a raw SASLInitialResponse ('p')
+// message selects, e.g. "SCRAM-SHA-256", or "" if msg is not one.
+func parseSASLMechanism(msg []byte) string {
+	if len(msg) < 5 || msg[0] != 'p' {
+		return ""
+	}
+	name, _, ok := bytes.Cut(msg[5:], []byte{0})
+	if !ok {
+		return ""
+	}
+	return string(name)
+}
+
 // readMessageRaw reads a regular protocol message: 1-byte type + 4-byte length + payload.
 func readMessageRaw(r io.Reader) ([]byte, error) {
 	var hdr [5]byte
@@ -220,9 +655,15 @@ func (c *conn) relayClientToUpstream(ctx context.Context) error {
 			return fmt.Errorf("postgres: receive from client: %w", err)
 		}
 
-		c.captureClientMsg(msg)
-
-		if err := encodeAndWrite(c.upstreamConn, msg); err != nil {
+		if c.policy != nil {
+			msg = c.enforce(msg)
+		}
+		if c.router != nil {
+			err = c.routeClientMsg(ctx, msg)
+		} else {
+			err = c.captureAndWrite(c.upstreamConn, msg)
+		}
+		if err != nil {
 			if isClosedErr(err) {
 				return nil
 			}
@@ -231,14 +672,20 @@ func (c *conn) relayClientToUpstream(ctx context.Context) error {
 	}
 }
 
-// relayUpstreamToClient reads messages from upstream, captures info, and forwards to client.
-func (c *conn) relayUpstreamToClient(ctx context.Context) error {
+// relayUpstreamToClient reads messages from upstream, captures info, and
+// forwards to client. In replica routing mode, s is the session to relay
+// from; otherwise it is nil and the relay reads the upstream connection.
+func (c *conn) relayUpstreamToClient(ctx context.Context, s *session) error {
+	upstream := c.upstream
+	if s != nil {
+		upstream = s.frontend
+	}
 	for {
 		if ctx.Err() != nil {
 			return fmt.Errorf("postgres: upstream relay: %w", ctx.Err())
 		}
 
-		msg, err := c.upstream.Receive()
+		msg, err := upstream.Receive()
 		if err != nil {
 			if isClosedErr(err) {
 				return nil
@@ -246,9 +693,19 @@ func (c *conn) relayUpstreamToClient(ctx context.Context) error {
 			return fmt.Errorf("postgres: receive from upstream: %w", err)
 		}
 
-		c.captureUpstreamMsg(msg)
+		if s != nil && c.router.received(s, msg) {
+			continue
+		}
+		if c.policy != nil {
+			c.rewriteBlocked(msg)
+		}
+		buf, err := msg.Encode(nil)
+		if err != nil {
+			return fmt.Errorf("postgres: encode: %w", err)
+		}
+		c.captureUpstreamMsg(msg, len(buf))
 
-		if err := encodeAndWrite(c.clientConn, msg); err != nil {
+		if _, err := c.clientConn.Write(buf); err != nil {
 			if isClosedErr(err) {
 				return nil
 			}
@@ -257,7 +714,28 @@ func (c *conn) relayUpstreamToClient(ctx context.Context) error {
 	}
 }
 
-func (c *conn) captureClientMsg(msg pgproto.FrontendMessage) {
+// captureAndWrite captures msg, n bytes on the wire, and writes it to dst.
+func (c *conn) captureAndWrite(dst net.Conn, msg pgproto.FrontendMessage) error {
+	buf, err := msg.Encode(nil)
+	if err != nil {
+		return fmt.Errorf("postgres: encode: %w", err)
+	}
+	c.captureClientMsg(msg, len(buf))
+	if _, err := dst.Write(buf); err != nil {
+		return fmt.Errorf("postgres: write: %w", err)
+	}
+	return nil
+}
+
+// captureClientMsg records msg, n bytes on the wire. Bytes count toward the
+// next Query or Execute, so an extended-protocol event includes its Parse
+// and Bind.
+func (c *conn) captureClientMsg(msg pgproto.FrontendMessage, n int) {
+	c.touch()
+	c.requestBytes += int64(n)
+	if c.pooler != "" {
+		c.settle()
+	}
 	switch m := msg.(type) {
 	case *pgproto.Query:
 		c.handleSimpleQuery(m)
@@ -265,53 +743,287 @@ func (c *conn) captureClientMsg(msg pgproto.FrontendMessage) {
 		c.handleParse(m)
 	case *pgproto.Bind:
 		c.handleBind(m)
+	case *pgproto.Describe:
+		c.handleDescribe(m)
 	case *pgproto.Execute:
-		c.handleExecute()
+		c.handleExecute(m)
+	case *pgproto.Close:
+		c.handleClose(m)
+	case *pgproto.Sync, *pgproto.FunctionCall:
+		c.endSegment()
 	}
 }
 
-func (c *conn) captureUpstreamMsg(msg pgproto.BackendMessage) {
+// captureUpstreamMsg records msg, n bytes on the wire, counting them
+// toward the pending event's response.
+func (c *conn) captureUpstreamMsg(msg pgproto.BackendMessage, n int) {
+	c.touch()
+	c.mu.Lock()
+	if p := c.current(); p != nil {
+		p.ev.ResponseBytes += int64(n)
+	}
+	c.mu.Unlock()
 	switch m := msg.(type) {
+	case *pgproto.ParseComplete:
+		c.recordPhase("parse", &c.parseSent)
+		c.mu.Lock()
+		c.confirm(&c.parses)
+		c.mu.Unlock()
+	case *pgproto.CloseComplete:
+		c.mu.Lock()
+		c.confirm(&c.closes)
+		c.mu.Unlock()
+	case *pgproto.BindComplete:
+		c.recordPhase("bind", &c.bindSent)
+	case *pgproto.ParameterDescription:
+		c.handleParameterDescription(m)
+	case *pgproto.RowDescription:
+		c.handleRowDescription(m)
+	case *pgproto.NoData:
+		c.mu.Lock()
+		c.describing, c.inDescribe = nil, false
+		c.mu.Unlock()
+	case *pgproto.DataRow:
+		c.handleDataRow(m)
 	case *pgproto.CommandComplete:
 		c.handleCommandComplete(m)
+	case *pgproto.PortalSuspended:
+		// Execute stopped at its row limit; a later Execute resumes the portal.
+		c.mu.Lock()
+		p := c.finish()
+		c.mu.Unlock()
+		if p != nil {
+			c.emitCompleted(p)
+		}
+	case *pgproto.EmptyQueryResponse:
+		c.mu.Lock()
+		c.finish()
+		c.mu.Unlock()
 	case *pgproto.ErrorResponse:
 		c.handleErrorResponse(m)
+	case *pgproto.NoticeResponse:
+		c.handleNoticeResponse(m)
+	case *pgproto.ReadyForQuery:
+		// Phases staged by a Parse/Bind that never reached Execute are stale
+		// now, as are Describes skipped after an error and anything left
+		// unanswered before this Sync.
+		c.mu.Lock()
+		c.stagedPhases = nil
+		c.describes, c.describing, c.inDescribe = nil, nil, false
+		c.dropSegment()
+		c.dropStaged()
+		done := c.endBatch()
+		c.ready++
+		c.forgetBlocked()
+		c.mu.Unlock()
+		for _, ev := range done {
+			c.emitEvent(ev)
+		}
+		if c.pooler == PoolTransaction && m.TxStatus == 'I' {
+			c.session.Add(1)
+		}
 	}
 }
 
 func (c *conn) handleSimpleQuery(m *pgproto.Query) {
-	q := m.String
-	r := c.detectTx(q, proxy.OpQuery)
+	q, v := c.violation(m.String, proxy.OpQuery)
+	r := c.detectAllowedTx(q, v, proxy.OpQuery)
+
+	// A simple Query destroys the unnamed statement and portal.
+	c.preparedStmts.remove("")
+	c.portals.remove("")
+	var release string
+	if c.pooler == "" {
+		c.handleDeallocate(q)
+	} else if name, all, _ := parseDeallocate(q); name != "" || all {
+		release = q
+	}
 
 	ev := proxy.Event{
-		ID:        c.generateID(),
-		Op:        r.op,
-		Query:     q,
-		StartTime: time.Now(),
-		TxID:      r.txID,
+		ID:         c.generateID(),
+		ConnID:     c.id,
+		Op:         r.op,
+		Query:      q,
+		StartTime:  time.Now(),
+		TxID:       r.txID,
+		GlobalTxID: r.globalTxID,
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+		Routing:    c.routing,
+		Violation:  v,
+	}
+	c.setPending(&ev, nil, release)
+	c.endSegment()
+}
+
+func (c *conn) handleParse(m *pgproto.Parse) {
+	q, v := c.violation(m.Query, proxy.OpPrepare)
+	st := &statement{query: q, paramOIDs: m.ParameterOIDs, violation: v}
+	if c.pooler != "" {
+		c.stageParse(m.Name, st)
+	} else {
+		c.preparedStmts.put(m.Name, st)
 	}
+	c.markSent(&c.parseSent)
+}
+
+// handleDescribe queues a statement Describe so its answer can be matched up.
+func (c *conn) handleDescribe(m *pgproto.Describe) {
+	if m.ObjectType != 'S' {
+		return
+	}
+	st, _ := c.lookupStatement(m.Name)
 	c.mu.Lock()
-	c.pending = &ev
+	c.describes = append(c.describes, st)
 	c.mu.Unlock()
 }
 
-func (c *conn) handleParse(m *pgproto.Parse) {
-	c.lastParse = m.Query
-	if m.Name != "" {
-		c.preparedStmts[m.Name] = m.Query
+func (c *conn) handleParameterDescription(m *pgproto.ParameterDescription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.describing, c.inDescribe = nil, true
+	if len(c.describes) == 0 {
+		return
+	}
+	c.describing = c.describes[0]
+	c.describes = c.describes[1:]
+	if c.describing != nil {
+		c.describing.paramOIDs = m.ParameterOIDs
 	}
 }
 
+func (c *conn) handleRowDescription(m *pgproto.RowDescription) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.inDescribe {
+		// Answer to a statement Describe: formats are not chosen until Bind.
+		if st := c.describing; st != nil {
+			st.resultOIDs = make([]uint32, len(m.Fields))
+			for i, f := range m.Fields {
+				st.resultOIDs[i] = f.DataTypeOID
+			}
+			st.results = c.describedColumns(m)
+		}
+		c.describing, c.inDescribe = nil, false
+		return
+	}
+	// Answer to a simple query or a portal Describe: formats are final.
+	p := c.current()
+	if p == nil {
+		return
+	}
+	p.columns = make([]column, len(m.Fields))
+	for i, f := range m.Fields {
+		p.columns[i] = column{oid: f.DataTypeOID, binary: f.Format == pgtype.BinaryFormatCode}
+	}
+	p.ev.Columns = c.describedColumns(m)
+}
+
+// describedColumns returns the result columns m describes. Caller holds mu.
+func (c *conn) describedColumns(m *pgproto.RowDescription) []proxy.Column {
+	cols := make([]proxy.Column, len(m.Fields))
+	for i, f := range m.Fields {
+		cols[i] = proxy.Column{Name: string(f.Name), Type: c.rows.typeName(f.DataTypeOID)}
+	}
+	return cols
+}
+
 func (c *conn) handleBind(m *pgproto.Bind) {
-	c.lastBindStmt = m.PreparedStatement
-	c.lastBindArgs = make([]string, len(m.Parameters))
+	c.markSent(&c.bindSent)
+	var query string
+	var paramOIDs, resultOIDs []uint32
+	var results []proxy.Column
+	var violation *proxy.Violation
+	if st, ok := c.lookupStatement(m.PreparedStatement); ok {
+		c.mu.Lock() // the upstream relay fills in the types
+		query, paramOIDs, resultOIDs, results = st.query, st.paramOIDs, st.resultOIDs, st.results
+		c.mu.Unlock()
+		violation = st.violation
+	}
+	args := make([]string, len(m.Parameters))
 	for i, p := range m.Parameters {
 		if isBinaryFormat(m.ParameterFormatCodes, i) {
-			c.lastBindArgs[i] = decodeBinaryParam(p)
+			var oid uint32
+			if i < len(paramOIDs) {
+				oid = paramOIDs[i]
+			}
+			args[i] = c.params.decode(oid, p)
 		} else {
-			c.lastBindArgs[i] = string(p)
+			args[i] = string(p)
+		}
+	}
+	var columns []column
+	if resultOIDs != nil {
+		columns = resultColumns(resultOIDs, m.ResultFormatCodes)
+	}
+	c.portals.put(m.DestinationPortal, portal{query: query, args: args, columns: columns, results: results, violation: violation})
+}
+
+// handleClose forgets a statement or portal the client closed.
+func (c *conn) handleClose(m *pgproto.Close) {
+	switch m.ObjectType {
+	case 'S':
+		if c.pooler != "" {
+			c.stageClose(stmtClose, m.Name)
+			return
+		}
+		c.preparedStmts.remove(m.Name)
+	case 'P':
+		c.portals.remove(m.Name)
+		if c.pooler != "" {
+			c.stageClose(stmtClosePortal, m.Name)
+		}
+	}
+}
+
+// handleDeallocate forgets statements released by DEALLOCATE or DISCARD,
+// which poolers such as PgBouncer send when handing a server connection to
+// another client.
+func (c *conn) handleDeallocate(query string) {
+	name, all, discard := parseDeallocate(query)
+	switch {
+	case discard:
+		c.preparedStmts.clear()
+		c.portals.clear()
+	case all:
+		c.preparedStmts.clear()
+	case name != "":
+		c.preparedStmts.remove(name)
+	}
+}
+
+// parseDeallocate returns the statement a DEALLOCATE releases, or all for
+// DEALLOCATE ALL and DISCARD ALL, which also drops portals.
+func parseDeallocate(query string) (name string, all, discard bool) {
+	fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if len(fields) < 2 {
+		return "", false, false
+	}
+	switch strings.ToUpper(fields[0]) {
+	case "DISCARD":
+		if strings.EqualFold(fields[1], "ALL") {
+			return "", true, true
+		}
+	case "DEALLOCATE":
+		name := fields[1]
+		if strings.EqualFold(name, "PREPARE") && len(fields) > 2 {
+			name = fields[2]
+		}
+		if strings.EqualFold(name, "ALL") {
+			return "", true, false
 		}
+		return unquoteIdent(name), false, false
 	}
+	return "", false, false
+}
+
+// unquoteIdent returns the name a SQL identifier refers to: quoted
+// identifiers keep their case, unquoted ones fold to lower case.
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return strings.ToLower(s)
 }
 
 // isBinaryFormat returns true if the i-th parameter uses binary format.
@@ -327,7 +1039,8 @@ func isBinaryFormat(codes []int16, i int) bool {
 }
 
 // decodeBinaryParam attempts to decode a binary-format parameter into a readable string.
-// Without type OID information, we use the byte length as a heuristic for common types.
+// It is the fallback when the parameter's type OID is unknown, using the byte length as a
+// heuristic for common types.
 func decodeBinaryParam(p []byte) string {
 	switch len(p) {
 	case 1:
@@ -349,62 +1062,319 @@ func decodeBinaryParam(p []byte) string {
 	return string(p)
 }
 
-func (c *conn) handleExecute() {
-	q := c.lastParse
-	if c.lastBindStmt != "" {
-		if stored, ok := c.preparedStmts[c.lastBindStmt]; ok {
-			q = stored
-		}
-	}
+func (c *conn) handleExecute(m *pgproto.Execute) {
+	p, _ := c.portals.get(m.Portal)
+	q := p.query
 
-	r := c.detectTx(q, proxy.OpExecute)
+	r := c.detectAllowedTx(q, p.violation, proxy.OpExecute)
 
 	ev := proxy.Event{
-		ID:        c.generateID(),
-		Op:        r.op,
-		Query:     q,
-		Args:      c.lastBindArgs,
-		StartTime: time.Now(),
-		TxID:      r.txID,
+		ID:         c.generateID(),
+		ConnID:     c.id,
+		Op:         r.op,
+		Query:      q,
+		Args:       p.args,
+		StartTime:  time.Now(),
+		TxID:       r.txID,
+		GlobalTxID: r.globalTxID,
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+		Routing:    c.routing,
+		Violation:  p.violation,
+		Columns:    p.results,
+	}
+	c.setPending(&ev, p.columns, "")
+}
+
+// setPending queues ev as an event awaiting an upstream response and
+// decides whether it gets detailed capture. columns describe its result rows
+// when known in advance; release is the DEALLOCATE or DISCARD a pooled
+// connection applies once ev completes.
+func (c *conn) setPending(ev *proxy.Event, columns []column, release string) {
+	verbose := c.verbosity.Verbose(c.id)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ev.RequestBytes, c.requestBytes = c.requestBytes, 0
+	if verbose {
+		ev.Phases = c.stagedPhases
 	}
+	c.stagedPhases = nil
+	p := &inflight{ev: ev, seg: c.synced, columns: columns, verbose: verbose, release: release}
+	if c.timeout != nil {
+		p.timer = time.AfterFunc(c.queryTimeout, func() { c.expire(p) })
+	}
+	c.pending = append(c.pending, p)
+}
+
+// timeoutRetry is how often a statement past the query timeout is checked
+// again while it waits behind an earlier one in a pipeline.
+const timeoutRetry = 100 * time.Millisecond
+
+// expire cancels p, which has passed the query timeout, if the server is
+// answering it.
+func (c *conn) expire(p *inflight) {
 	c.mu.Lock()
-	c.pending = &ev
+	running := !c.closed && c.current() == p
+	if !running && !c.closed && slices.Contains(c.pending, p) {
+		p.timer.Reset(timeoutRetry)
+	}
 	c.mu.Unlock()
+	if running {
+		c.timeout()
+	}
+}
+
+func (p *inflight) stopTimer() {
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
+
+// clientLeft counts the statement the server is running, if any, when the
+// client disconnects without waiting for it.
+func (c *conn) clientLeft() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current() != nil {
+		proxy.CountCancel(proxy.CancelDisconnect)
+	}
+}
+
+// current returns the pending event the upstream is answering, or nil when
+// there is none or the oldest waits behind an earlier Sync. Caller holds mu.
+func (c *conn) current() *inflight {
+	if len(c.pending) == 0 || c.pending[0].seg != c.ready {
+		return nil
+	}
+	return c.pending[0]
+}
+
+// endSegment counts a message the server answers with ReadyForQuery,
+// closing the group of requests sent before it.
+func (c *conn) endSegment() {
+	c.mu.Lock()
+	c.synced++
+	c.mu.Unlock()
+}
+
+// finish dequeues the current event, completing its timing, or returns nil
+// if there is none. Caller holds mu.
+func (c *conn) finish() *inflight {
+	p := c.current()
+	if p == nil {
+		return nil
+	}
+	c.pending = slices.Delete(c.pending, 0, 1)
+	p.stopTimer()
+	now := time.Now()
+	p.ev.Duration = now.Sub(p.ev.StartTime)
+	c.splitLatency(p, now)
+	finishPhases(p)
+	return p
+}
+
+// splitLatency divides the Duration of p, completed at now, into the time it
+// queued behind the statements pipelined before it, the time until its first
+// row, and the time streaming its rows. The server starts on a statement once
+// it has sent it and completed the one before. Caller holds mu.
+func (c *conn) splitLatency(p *inflight, now time.Time) {
+	ev := p.ev
+	begin := ev.StartTime
+	if c.done.After(begin) {
+		ev.QueueLatency = c.done.Sub(begin)
+		begin = c.done
+	}
+	c.done = now
+	if p.firstRow.IsZero() {
+		ev.ExecLatency = now.Sub(begin)
+		return
+	}
+	if p.firstRow.After(begin) {
+		ev.ExecLatency = p.firstRow.Sub(begin)
+	}
+	ev.FetchLatency = now.Sub(p.firstRow)
+}
+
+// dropSegment discards the pending events sent before the Sync being
+// answered, which the server skips after an error, and returns how many
+// there were. Caller holds mu.
+func (c *conn) dropSegment() int {
+	n := 0
+	for n < len(c.pending) && c.pending[n].seg <= c.ready {
+		c.pending[n].stopTimer()
+		n++
+	}
+	c.pending = slices.Delete(c.pending, 0, n)
+	return n
+}
+
+// markSent records the send time of a Parse or Bind for phase timing.
+func (c *conn) markSent(sent *time.Time) {
+	if !c.verbosity.Verbose(c.id) {
+		return
+	}
+	c.mu.Lock()
+	*sent = time.Now()
+	c.mu.Unlock()
+}
+
+// recordPhase completes a phase started by markSent. Parse and Bind responses
+// usually arrive after the pipelined Execute, so the phase is attached to the
+// pending event when there is one.
+func (c *conn) recordPhase(name string, sent *time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if sent.IsZero() {
+		return
+	}
+	ph := proxy.Phase{Name: name, Duration: time.Since(*sent)}
+	*sent = time.Time{}
+	if p := c.current(); p != nil && p.verbose {
+		p.ev.Phases = append(p.ev.Phases, ph)
+		return
+	}
+	c.stagedPhases = append(c.stagedPhases, ph)
+}
+
+func (c *conn) handleDataRow(m *pgproto.DataRow) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := c.current()
+	if p == nil {
+		return
+	}
+	first := p.firstRow.IsZero()
+	if first {
+		p.firstRow = time.Now()
+	}
+	if !p.verbose {
+		return
+	}
+	ev := p.ev
+	if first {
+		ev.Phases = append(ev.Phases, proxy.Phase{
+			Name:     "execute",
+			Duration: p.firstRow.Sub(ev.StartTime),
+		})
+	}
+	if len(ev.RowSamples) >= proxy.MaxRowSamples {
+		return
+	}
+	row := make([]string, len(m.Values))
+	for i, v := range m.Values {
+		if v != nil && i < len(p.columns) && p.columns[i].binary {
+			row[i] = proxy.SampleValue([]byte(c.rows.decode(p.columns[i].oid, v)))
+			continue
+		}
+		row[i] = proxy.SampleValue(v)
+	}
+	ev.RowSamples = append(ev.RowSamples, row)
+}
+
+// finishPhases closes the execute/fetch phases of a verbose event.
+func finishPhases(p *inflight) {
+	if !p.verbose {
+		return
+	}
+	ev := p.ev
+	if p.firstRow.IsZero() {
+		ev.Phases = append(ev.Phases, proxy.Phase{Name: "execute", Duration: ev.Duration})
+		return
+	}
+	ev.Phases = append(ev.Phases, proxy.Phase{Name: "fetch", Duration: time.Since(p.firstRow)})
 }
 
 func (c *conn) handleCommandComplete(m *pgproto.CommandComplete) {
 	c.mu.Lock()
-	ev := c.pending
-	c.pending = nil
+	p := c.finish()
 	c.mu.Unlock()
-	if ev == nil {
+	if p == nil {
 		return
 	}
-	ev.Duration = time.Since(ev.StartTime)
-	ev.RowsAffected = parseRowsAffected(string(m.CommandTag))
-	c.emitEvent(*ev)
+	p.ev.RowsAffected = parseRowsAffected(string(m.CommandTag))
+	c.emitCompleted(p)
+	c.released(p)
 }
 
+// handleErrorResponse fails the current event. After an error the server
+// skips the rest of the pipeline up to the next Sync, so the events queued
+// behind it are dropped; a batch counts them in its size.
 func (c *conn) handleErrorResponse(m *pgproto.ErrorResponse) {
 	c.mu.Lock()
-	ev := c.pending
-	c.pending = nil
+	p := c.finish()
 	c.mu.Unlock()
-	if ev == nil {
-		return
+	if p != nil {
+		failEvent(p.ev, m)
+		c.emitCompleted(p)
+	}
+	c.mu.Lock()
+	c.batch.skipped += c.dropSegment()
+	c.dropStaged()
+	c.mu.Unlock()
+}
+
+// handleNoticeResponse emits the notice m as an OpNotice event, linked to
+// the statement the server is answering, if any. It bypasses cursor
+// folding, which would swallow a notice raised by a cursor's statement.
+func (c *conn) handleNoticeResponse(m *pgproto.NoticeResponse) {
+	ev := proxy.Event{
+		ID:         c.generateID(),
+		ConnID:     c.id,
+		Op:         proxy.OpNotice,
+		StartTime:  time.Now(),
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+		Notice:     errorDetail((*pgproto.ErrorResponse)(m)),
 	}
-	ev.Duration = time.Since(ev.StartTime)
+	c.mu.Lock()
+	if p := c.current(); p != nil {
+		ev.NoticeFor = p.ev.ID
+		ev.Query = p.ev.Query
+		ev.TxID = p.ev.TxID
+	}
+	c.mu.Unlock()
+	c.stampConn(&ev)
+	proxy.Emit(c.events, ev)
+}
+
+// failEvent records the error m on ev.
+func failEvent(ev *proxy.Event, m *pgproto.ErrorResponse) {
 	ev.Error = m.Message
-	c.emitEvent(*ev)
+	ev.ErrorDetail = errorDetail(m)
+}
+
+// errorDetail returns the fields of an ErrorResponse or NoticeResponse.
+func errorDetail(m *pgproto.ErrorResponse) *proxy.ErrorDetail {
+	severity := m.SeverityUnlocalized
+	if severity == "" {
+		severity = m.Severity
+	}
+	return &proxy.ErrorDetail{
+		Code:     m.Code,
+		Severity: severity,
+		Message:  m.Message,
+		Detail:   m.Detail,
+		Hint:     m.Hint,
+		Position: int(m.Position),
+	}
 }
 
 type txDetectResult struct {
-	txID string
-	op   proxy.Op // overridden Op for BEGIN/COMMIT/ROLLBACK; zero means keep original
+	txID       string
+	op         proxy.Op // overridden Op for BEGIN/COMMIT/ROLLBACK; zero means keep original
+	globalTxID string   // gid of a two-phase commit statement
 }
 
 // detectTx updates transaction state and returns the txID and Op to use for the current event.
 func (c *conn) detectTx(query string, defaultOp proxy.Op) txDetectResult {
+	if tp, ok := proxy.ParseTwoPhase(query); ok && tp.Kind != proxy.TwoPhaseStart && tp.Kind != proxy.TwoPhaseEnd {
+		return c.detectTwoPhase(tp, defaultOp)
+	}
+
 	upper := strings.ToUpper(strings.TrimSpace(query))
 	switch {
 	case strings.HasPrefix(upper, "BEGIN"):
@@ -422,11 +1392,135 @@ func (c *conn) detectTx(query string, defaultOp proxy.Op) txDetectResult {
 	return txDetectResult{txID: c.activeTxID, op: defaultOp}
 }
 
+// detectTwoPhase handles PREPARE TRANSACTION, which ends the session's
+// transaction, and COMMIT/ROLLBACK PREPARED, which finish it later, usually
+// from another session and so with no TxID of their own.
+func (c *conn) detectTwoPhase(tp proxy.TwoPhase, defaultOp proxy.Op) txDetectResult {
+	prev := c.activeTxID
+	c.activeTxID = ""
+	op := defaultOp
+	switch tp.Kind {
+	case proxy.TwoPhaseCommit:
+		op = proxy.OpCommit
+	case proxy.TwoPhaseRollback:
+		op = proxy.OpRollback
+	case proxy.TwoPhaseStart, proxy.TwoPhaseEnd, proxy.TwoPhasePrepare:
+	}
+	return txDetectResult{txID: prev, op: op, globalTxID: tp.GlobalID}
+}
+
 func (c *conn) emitEvent(ev proxy.Event) {
-	select {
-	case c.events <- ev:
+	c.stampConn(&ev)
+	switch ev.Op {
+	case proxy.OpQuery, proxy.OpExec, proxy.OpExecute:
+		c.queries.Add(1)
+	case proxy.OpPrepare, proxy.OpBind, proxy.OpBegin, proxy.OpCommit, proxy.OpRollback, proxy.OpCancel,
+		proxy.OpAdvisory, proxy.OpBatch, proxy.OpNotice, proxy.OpConnect, proxy.OpDisconnect:
+	}
+	if c.foldCursor(ev) {
+		return
+	}
+	proxy.Emit(c.events, ev)
+}
+
+// emitConnect reports the connection's startup, begun at start, as an
+// OpConnect event; err is the reason it failed, if it did.
+func (c *conn) emitConnect(start time.Time, err error) {
+	ev := proxy.Event{
+		ID:         c.generateID(),
+		ConnID:     c.id,
+		Op:         proxy.OpConnect,
+		StartTime:  start,
+		Duration:   time.Since(start),
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+
+		StartupParams: c.startupParams,
+		SSLRequested:  c.sslRequested,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		c.reported = true
+	}
+	c.emitEvent(ev)
+}
+
+// emitDisconnect reports the end of the connection opened at start as an
+// OpDisconnect event lasting its lifetime; err is the relay's failure, if
+// it did not end cleanly.
+func (c *conn) emitDisconnect(start time.Time, err error) {
+	ev := proxy.Event{
+		ID:         c.generateID(),
+		ConnID:     c.id,
+		Op:         proxy.OpDisconnect,
+		StartTime:  start,
+		Duration:   time.Since(start),
+		Queries:    c.queries.Load(),
+		TxID:       c.activeTxID,
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+	}
+	if err != nil {
+		ev.Error = err.Error()
+		c.reported = true
+	} else if c.idled.Load() {
+		ev.Error = fmt.Sprintf("postgres: closed after %s idle", c.idleTimeout)
+	}
+	c.emitEvent(ev)
+}
+
+// guard runs fn, one of the connection's goroutines, turning a panic in it
+// into an error after reporting it, so a bug handling one connection closes
+// only that connection.
+func (c *conn) guard(where string, fn func() error) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			ev := proxy.Event{ConnID: c.id}
+			c.stampConn(&ev)
+			diag, rerr := proxy.Recovered("postgres: "+where, v, ev)
+			proxy.Emit(c.events, diag)
+			err = rerr
+		}
+	}()
+	return fn()
+}
+
+// diagnose logs err, a failure handling the connection described by msg,
+// and reports it as a diagnostic event.
+func (c *conn) diagnose(msg string, err error) {
+	ev := proxy.Event{ConnID: c.id}
+	c.stampConn(&ev)
+	proxy.Emit(c.events, proxy.Diagnose(c.logger, slog.LevelWarn, msg, err, ev))
+}
+
+// relayFailed logs err, the relay's failure, reporting it as a diagnostic
+// unless an event already records it. A client that left before its startup,
+// as health checks do, is logged at debug level only.
+func (c *conn) relayFailed(err error) {
+	switch {
+	case c.reported || errors.Is(err, proxy.ErrPanic):
+		c.logger.Warn("relay failed", "conn_id", c.id, "client", c.clientAddr, "err", err)
+	case isClosedErr(err):
+		c.logger.Debug("client left before startup", "conn_id", c.id, "client", c.clientAddr, "err", err)
 	default:
-		// channel full; drop
+		c.diagnose("relay failed", err)
+	}
+}
+
+// stampConn copies the connection metadata onto ev.
+func (c *conn) stampConn(ev *proxy.Event) {
+	ev.ClientAddr = c.clientAddr
+	ev.Listener = c.listener
+	ev.User = c.user
+	ev.Database = c.database
+	ev.AuthMethod = c.authMethod
+	ev.AuthDuration = c.authDuration
+	ev.ServerParams = c.serverParams
+	if c.hasKey {
+		ev.BackendPID = c.backendKey.pid
+	}
+	if c.pooler != "" {
+		ev.Session = c.session.Load()
 	}
 }
 
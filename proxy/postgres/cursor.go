@@ -0,0 +1,218 @@
+package postgres
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// cursor accumulates the statements run against a DECLAREd cursor so they
+// are reported as one event for the query the cursor was declared for.
+type cursor struct {
+	ev   proxy.Event // summary event, seeded from the DECLARE
+	hold bool        // WITH HOLD: survives the end of its transaction
+}
+
+type cursorOp int
+
+const (
+	cursorDeclare  cursorOp = iota + 1
+	cursorFetch             // FETCH or MOVE
+	cursorClose             // CLOSE name
+	cursorCloseAll          // CLOSE ALL or DISCARD ALL
+)
+
+type cursorStmt struct {
+	op    cursorOp
+	name  string
+	query string // DECLARE only: the query after FOR
+	hold  bool   // DECLARE only
+	fetch bool   // FETCH, as opposed to MOVE: the command tag counts rows returned
+}
+
+// parseCursorStmt recognizes DECLARE ... CURSOR, FETCH, MOVE, CLOSE, and
+// DISCARD ALL.
+func parseCursorStmt(query string) (cursorStmt, bool) {
+	q := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	word, rest := nextWord(q)
+	switch strings.ToUpper(word) {
+	case "DECLARE":
+		return parseDeclare(rest)
+	case "FETCH", "MOVE":
+		// FETCH [direction [count]] [FROM | IN] name: the name comes last.
+		words := strings.Fields(rest)
+		if len(words) == 0 {
+			return cursorStmt{}, false
+		}
+		return cursorStmt{
+			op:    cursorFetch,
+			name:  unquoteIdent(words[len(words)-1]),
+			fetch: strings.EqualFold(word, "FETCH"),
+		}, true
+	case "CLOSE":
+		name, _ := nextWord(rest)
+		if name == "" {
+			return cursorStmt{}, false
+		}
+		if strings.EqualFold(name, "ALL") {
+			return cursorStmt{op: cursorCloseAll}, true
+		}
+		return cursorStmt{op: cursorClose, name: unquoteIdent(name)}, true
+	case "DISCARD":
+		if what, _ := nextWord(rest); strings.EqualFold(what, "ALL") {
+			return cursorStmt{op: cursorCloseAll}, true
+		}
+	}
+	return cursorStmt{}, false
+}
+
+// parseDeclare parses the part of
+// DECLARE name [BINARY] [ASENSITIVE | INSENSITIVE] [[NO] SCROLL] CURSOR [{WITH | WITHOUT} HOLD] FOR query
+// after DECLARE.
+func parseDeclare(s string) (cursorStmt, bool) {
+	name, rest := nextWord(s)
+	if name == "" {
+		return cursorStmt{}, false
+	}
+	st := cursorStmt{op: cursorDeclare, name: unquoteIdent(name)}
+	sawCursor := false
+	for {
+		word, after := nextWord(rest)
+		switch strings.ToUpper(word) {
+		case "":
+			return cursorStmt{}, false
+		case "CURSOR":
+			sawCursor = true
+		case "WITH":
+			st.hold = true
+		case "FOR":
+			if !sawCursor {
+				return cursorStmt{}, false
+			}
+			st.query = strings.TrimSpace(after)
+			return st, st.query != ""
+		}
+		rest = after
+	}
+}
+
+// nextWord splits off the first whitespace-separated word of s, keeping a
+// double-quoted identifier whole.
+func nextWord(s string) (string, string) {
+	s = strings.TrimLeft(s, " \t\r\n")
+	if strings.HasPrefix(s, `"`) {
+		for i := 1; i < len(s); i++ {
+			if s[i] != '"' {
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == '"' {
+				i++
+				continue
+			}
+			return s[:i+1], s[i+1:]
+		}
+		return s, ""
+	}
+	if i := strings.IndexAny(s, " \t\r\n"); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// foldCursor folds ev into an open cursor's summary instead of emitting it,
+// and emits summaries for cursors that ev closes. It reports whether ev was
+// consumed. Called from the upstream relay goroutine only.
+func (c *conn) foldCursor(ev proxy.Event) bool {
+	st, ok := parseCursorStmt(ev.Query)
+	if !ok {
+		if ev.Op == proxy.OpCommit || ev.Op == proxy.OpRollback {
+			c.endTxCursors()
+		}
+		return false
+	}
+
+	switch st.op {
+	case cursorDeclare:
+		if ev.Error != "" {
+			return false
+		}
+		sum := ev
+		sum.Op = proxy.OpQuery
+		sum.Query = st.query
+		sum.RowsAffected = 0
+		sum.Cursor = st.name
+		c.cursors[st.name] = &cursor{ev: sum, hold: st.hold}
+		return true
+	case cursorFetch:
+		cur, ok := c.cursors[st.name]
+		if !ok {
+			return false
+		}
+		cur.add(ev)
+		cur.ev.Fetches++
+		if st.fetch {
+			cur.ev.RowsAffected += ev.RowsAffected
+		}
+		return true
+	case cursorClose:
+		cur, ok := c.cursors[st.name]
+		if !ok {
+			return false
+		}
+		cur.add(ev)
+		cur.ev.TxID = ev.TxID
+		delete(c.cursors, st.name)
+		proxy.Emit(c.events, cur.ev)
+		return true
+	case cursorCloseAll:
+		c.flushCursors(func(*cursor) bool { return true })
+	}
+	return false
+}
+
+// add accounts for a statement run against the cursor.
+func (cur *cursor) add(ev proxy.Event) {
+	cur.ev.Duration += ev.Duration
+	cur.ev.QueueLatency += ev.QueueLatency
+	cur.ev.ExecLatency += ev.ExecLatency
+	cur.ev.FetchLatency += ev.FetchLatency
+	cur.ev.RequestBytes += ev.RequestBytes
+	cur.ev.ResponseBytes += ev.ResponseBytes
+	if cur.ev.Error == "" {
+		cur.ev.Error = ev.Error
+		cur.ev.ErrorDetail = ev.ErrorDetail
+	}
+	for _, row := range ev.RowSamples {
+		if len(cur.ev.RowSamples) >= proxy.MaxRowSamples {
+			break
+		}
+		cur.ev.RowSamples = append(cur.ev.RowSamples, row)
+	}
+}
+
+// endTxCursors runs before a COMMIT or ROLLBACK is emitted: cursors without
+// HOLD end with their transaction, and held ones no longer belong to it.
+func (c *conn) endTxCursors() {
+	c.flushCursors(func(cur *cursor) bool { return !cur.hold })
+	for _, cur := range c.cursors {
+		cur.ev.TxID = ""
+	}
+}
+
+// flushCursors emits and forgets the cursors matching drop, oldest first.
+func (c *conn) flushCursors(drop func(*cursor) bool) {
+	var flushed []*cursor
+	for name, cur := range c.cursors {
+		if drop(cur) {
+			delete(c.cursors, name)
+			flushed = append(flushed, cur)
+		}
+	}
+	slices.SortFunc(flushed, func(a, b *cursor) int {
+		return a.ev.StartTime.Compare(b.ev.StartTime)
+	})
+	for _, cur := range flushed {
+		proxy.Emit(c.events, cur.ev)
+	}
+}
@@ -0,0 +1,148 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	pgproto "github.com/jackc/pgproto3/v2"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// refuseTimeout bounds how long a refused client has to send its
+// StartupMessage and read the answer.
+const refuseTimeout = 5 * time.Second
+
+// admit takes one of the WithMaxConns slots for a new client connection,
+// reporting false when all are taken.
+func (p *Proxy) admit() bool {
+	if p.maxConns <= 0 {
+		return true
+	}
+	if p.open.Add(1) > int64(p.maxConns) {
+		p.open.Add(-1)
+		return false
+	}
+	return true
+}
+
+// leave frees the slot admit took.
+func (p *Proxy) leave() {
+	if p.maxConns > 0 {
+		p.open.Add(-1)
+	}
+}
+
+// refuseFull turns away a client connection over the WithMaxConns limit
+// with the error the server gives past max_connections. A CancelRequest
+// is forwarded instead, as it takes no slot on the server either.
+func (p *Proxy) refuseFull(ctx context.Context, clientConn net.Conn, spec proxy.ListenSpec) {
+	defer func() { _ = clientConn.Close() }()
+
+	c := p.newConn(proxy.NewConnID(), clientConn, nil, spec)
+	reason := fmt.Errorf("postgres: too many connections (limit %d)", p.maxConns)
+	raw, err := c.refuse(ctx, "53300", "sorry, too many clients already", reason)
+	if err != nil || raw == nil {
+		return
+	}
+	upstreamConn, err := p.dialer.DialContext(ctx, p.upstreamAddr)
+	if err != nil {
+		c.diagnose("dial upstream for cancel failed", err)
+		return
+	}
+	defer func() { _ = upstreamConn.Close() }()
+	c.upstreamConn = upstreamConn
+	if err := c.relayCancel(raw); err != nil && !errors.Is(err, errCancelRequest) {
+		c.diagnose("relay failed", err)
+	}
+}
+
+// refuseClient turns away a client connection from outside spec's Allow
+// list with the error the server gives a client no pg_hba.conf entry
+// admits. Its cancel requests are dropped too.
+func (p *Proxy) refuseClient(ctx context.Context, clientConn net.Conn, spec proxy.ListenSpec) {
+	defer func() { _ = clientConn.Close() }()
+
+	c := p.newConn(proxy.NewConnID(), clientConn, nil, spec)
+	reason := fmt.Errorf("postgres: client %s is not allowed on %s", c.clientAddr, spec.Addr)
+	_, _ = c.refuse(ctx, "28000", "sql-tap: connections from host \""+clientHost(clientConn.RemoteAddr())+"\" are not allowed", reason)
+}
+
+// refuse reads the client's startup and answers its StartupMessage with a
+// FATAL ErrorResponse carrying code and message, reporting the refusal as
+// an OpConnect event failed with reason. A CancelRequest is returned
+// unanswered for the caller to forward.
+func (c *conn) refuse(ctx context.Context, code, message string, reason error) (cancel []byte, err error) {
+	start := time.Now()
+	_ = c.clientConn.SetDeadline(start.Add(refuseTimeout))
+	raw, isCancel, err := c.readStartup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if isCancel {
+		_ = c.clientConn.SetDeadline(time.Time{})
+		return raw, nil
+	}
+	c.emitConnect(start, reason)
+	return nil, encodeAndWrite(c.clientConn, fatal(code, message))
+}
+
+// fatal returns the FATAL ErrorResponse the server ends a session with.
+func fatal(code, message string) *pgproto.ErrorResponse {
+	return &pgproto.ErrorResponse{
+		Severity:            "FATAL",
+		SeverityUnlocalized: "FATAL",
+		Code:                code,
+		Message:             message,
+	}
+}
+
+// watchIdle starts the idle timeout, which closes the connection once
+// neither side has sent a message for c.idleTimeout while no statement is
+// running.
+func (c *conn) watchIdle() {
+	if c.idleTimeout <= 0 {
+		return
+	}
+	c.touch()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.idleTimer = time.AfterFunc(c.idleTimeout, c.checkIdle)
+}
+
+// touch records traffic on the connection for the idle timeout.
+func (c *conn) touch() {
+	if c.idleTimeout > 0 {
+		c.active.Store(time.Now().UnixNano())
+	}
+}
+
+// checkIdle closes the connection if it has been idle for the timeout and
+// otherwise waits for the next time it could be.
+func (c *conn) checkIdle() {
+	c.mu.Lock()
+	idle := time.Since(time.Unix(0, c.active.Load()))
+	switch {
+	case c.closed:
+		c.mu.Unlock()
+		return
+	case len(c.pending) > 0:
+		c.idleTimer.Reset(c.idleTimeout)
+		c.mu.Unlock()
+		return
+	case idle < c.idleTimeout:
+		c.idleTimer.Reset(c.idleTimeout - idle)
+		c.mu.Unlock()
+		return
+	}
+	c.mu.Unlock()
+
+	// The server's message for its own idle_session_timeout.
+	c.idled.Store(true)
+	_ = encodeAndWrite(c.clientConn, fatal("57P05", "terminating connection due to idle-session timeout"))
+	_ = c.clientConn.Close()
+	_ = c.upstreamConn.Close()
+}
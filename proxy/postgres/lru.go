@@ -0,0 +1,66 @@
+package postgres
+
+import "container/list"
+
+// maxTrackedStatements bounds the prepared statements, and separately the
+// portals, remembered per connection. A pooler keeps server connections open
+// far longer than any client session, so without a bound they would grow for
+// as long as the pooler keeps preparing statements.
+const maxTrackedStatements = 1024
+
+// lru is a string-keyed map that evicts its least recently used entry once it
+// holds more than max entries. It is not safe for concurrent use.
+type lru[V any] struct {
+	max   int
+	order *list.List // front is most recently used
+	items map[string]*list.Element
+}
+
+type lruEntry[V any] struct {
+	key   string
+	value V
+}
+
+func newLRU[V any](maxEntries int) *lru[V] {
+	return &lru[V]{
+		max:   maxEntries,
+		order: list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+func (l *lru[V]) get(key string) (V, bool) {
+	el, ok := l.items[key]
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	l.order.MoveToFront(el)
+	return el.Value.(*lruEntry[V]).value, true //nolint:forcetypeassert // only lruEntry values are stored
+}
+
+func (l *lru[V]) put(key string, value V) {
+	if el, ok := l.items[key]; ok {
+		el.Value.(*lruEntry[V]).value = value //nolint:forcetypeassert // only lruEntry values are stored
+		l.order.MoveToFront(el)
+		return
+	}
+	l.items[key] = l.order.PushFront(&lruEntry[V]{key: key, value: value})
+	if l.order.Len() > l.max {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruEntry[V]).key) //nolint:forcetypeassert // only lruEntry values are stored
+	}
+}
+
+func (l *lru[V]) remove(key string) {
+	if el, ok := l.items[key]; ok {
+		l.order.Remove(el)
+		delete(l.items, key)
+	}
+}
+
+func (l *lru[V]) clear() {
+	l.order.Init()
+	clear(l.items)
+}
@@ -0,0 +1,167 @@
+package postgres
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	pgproto "github.com/jackc/pgproto3/v2"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// blockedPrefix starts the stand-ins sent in place of blocked statements. A
+// stand-in is a lone identifier, which the server rejects with a syntax
+// error naming it.
+const blockedPrefix = "sql_tap_blocked_"
+
+// blockedStmt is a statement the policy blocked, replaced by a stand-in; seg
+// is the segment the stand-in was sent in.
+type blockedStmt struct {
+	query     string
+	violation *proxy.Violation
+	seg       uint64
+}
+
+// verdict is the policy's answer for one query, kept by the client relay so
+// that capturing the query does not check it again.
+type verdict struct {
+	query     string
+	violation *proxy.Violation
+}
+
+// enforce checks the statement of a Query or Parse against the policy and
+// returns the message to send in its place: msg itself, or, when the
+// statement is blocked, one carrying a stand-in. The server answers the
+// stand-in with an error in the statement's place in the pipeline, aborting
+// an open transaction as a failed statement would, and the upstream relay
+// rewrites that error into the policy's. Client relay only.
+func (c *conn) enforce(msg pgproto.FrontendMessage) pgproto.FrontendMessage {
+	var q string
+	op := proxy.OpQuery
+	switch m := msg.(type) {
+	case *pgproto.Query:
+		q = m.String
+	case *pgproto.Parse:
+		q, op = m.Query, proxy.OpPrepare
+	default:
+		return msg
+	}
+	v := c.check(q, op)
+	if v == nil || !v.Blocked {
+		c.checked = verdict{query: q, violation: v}
+		return msg
+	}
+
+	c.blocks++
+	standIn := blockedPrefix + strconv.FormatUint(c.blocks, 10)
+	c.mu.Lock()
+	c.blocked[standIn] = blockedStmt{query: q, violation: v, seg: c.synced}
+	c.mu.Unlock()
+	c.logger.Warn("policy violation", "conn_id", c.id, "client", c.clientAddr, "user", c.user, "rule", v.Rule)
+
+	if m, ok := msg.(*pgproto.Parse); ok {
+		return &pgproto.Parse{Name: m.Name, Query: standIn, ParameterOIDs: m.ParameterOIDs}
+	}
+	return &pgproto.Query{String: standIn}
+}
+
+// violation returns the statement a captured query, sent as op, stands for
+// and the policy's verdict on it: the blocked statement for a stand-in, else
+// q. Client relay only.
+func (c *conn) violation(q string, op proxy.Op) (string, *proxy.Violation) {
+	if c.policy == nil {
+		return q, nil
+	}
+	if strings.HasPrefix(q, blockedPrefix) {
+		c.mu.Lock()
+		b, ok := c.blocked[q]
+		c.mu.Unlock()
+		if ok {
+			return b.query, b.violation
+		}
+	}
+	if c.checked.query == q {
+		return q, c.checked.violation
+	}
+	return q, c.check(q, op)
+}
+
+// check asks the policy about query q, sent as op.
+func (c *conn) check(q string, op proxy.Op) *proxy.Violation {
+	return c.policy.Check(proxy.Event{
+		ConnID:     c.id,
+		ClientAddr: c.clientAddr,
+		Listener:   c.listener,
+		User:       c.user,
+		Database:   c.database,
+		Op:         op,
+		Query:      q,
+	})
+}
+
+// detectAllowedTx is detectTx for a statement the policy may have blocked,
+// which the server never runs: it leaves the transaction state alone.
+func (c *conn) detectAllowedTx(q string, v *proxy.Violation, defaultOp proxy.Op) txDetectResult {
+	if v != nil && v.Blocked {
+		return txDetectResult{txID: c.activeTxID, op: defaultOp}
+	}
+	return c.detectTx(q, defaultOp)
+}
+
+// rewriteBlocked turns the server's rejection of a stand-in into the
+// policy's error, SQLSTATE 42501 (insufficient_privilege). When no event
+// awaits the error, as for a Parse never executed, it emits one recording
+// the blocked statement.
+func (c *conn) rewriteBlocked(msg pgproto.BackendMessage) {
+	m, ok := msg.(*pgproto.ErrorResponse)
+	if !ok {
+		return
+	}
+	i := strings.Index(m.Message, blockedPrefix)
+	if i < 0 {
+		return
+	}
+	standIn := m.Message[i:]
+	if j := strings.IndexFunc(standIn[len(blockedPrefix):], func(r rune) bool { return r < '0' || r > '9' }); j >= 0 {
+		standIn = standIn[:len(blockedPrefix)+j]
+	}
+	c.mu.Lock()
+	b, ok := c.blocked[standIn]
+	unanswered := c.current() == nil
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+	*m = pgproto.ErrorResponse{
+		Severity:            "ERROR",
+		SeverityUnlocalized: "ERROR",
+		Code:                "42501",
+		Message:             b.violation.Message,
+	}
+	if !unanswered {
+		return
+	}
+	ev := proxy.Event{
+		ID:         c.generateID(),
+		ConnID:     c.id,
+		Op:         proxy.OpPrepare,
+		Query:      b.query,
+		StartTime:  time.Now(),
+		TLSVersion: c.tlsVersion,
+		TLSCipher:  c.tlsCipher,
+		Violation:  b.violation,
+	}
+	failEvent(&ev, m)
+	c.emitEvent(ev)
+}
+
+// forgetBlocked drops the stand-ins of segments the server has answered.
+// Caller holds mu.
+func (c *conn) forgetBlocked() {
+	for standIn, b := range c.blocked {
+		if b.seg < c.ready {
+			delete(c.blocked, standIn)
+		}
+	}
+}
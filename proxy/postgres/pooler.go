@@ -0,0 +1,126 @@
+package postgres
+
+// PoolMode is the pooling mode of a connection pooler, such as PgBouncer,
+// whose server connections are the proxy's clients (see WithPooler).
+type PoolMode string
+
+const (
+	// PoolSession is a pooler that hands a server connection to another
+	// client after resetting it with DISCARD ALL.
+	PoolSession PoolMode = "session"
+	// PoolTransaction is a pooler that may hand a server connection to
+	// another client after every transaction.
+	PoolTransaction PoolMode = "transaction"
+)
+
+// stmtChange is a change to the statements of a pooled connection, applied
+// once the server has confirmed it. The logical sessions sharing a server
+// connection reuse statement names, so a Parse that fails because another
+// session prepared the name first must not replace that session's query.
+type stmtChange struct {
+	kind  stmtChangeKind
+	name  string     // statement name, for stmtParse and stmtClose
+	st    *statement // for stmtParse
+	query string     // the DEALLOCATE or DISCARD, for stmtRelease
+	seg   uint64     // as inflight.seg
+}
+
+type stmtChangeKind int
+
+const (
+	stmtParse       stmtChangeKind = iota // Parse, confirmed by ParseComplete
+	stmtClose                             // Close of a statement, confirmed by CloseComplete
+	stmtClosePortal                       // Close of a portal, applied when sent; queued to keep CloseCompletes in step
+	stmtRelease                           // DEALLOCATE or DISCARD, confirmed by CommandComplete
+)
+
+// stageParse queues a pooled connection's Parse until the server answers it.
+func (c *conn) stageParse(name string, st *statement) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.parses = append(c.parses, stmtChange{kind: stmtParse, name: name, st: st, seg: c.synced})
+}
+
+// stageClose queues a pooled connection's Close until the server answers it.
+func (c *conn) stageClose(kind stmtChangeKind, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closes = append(c.closes, stmtChange{kind: kind, name: name, seg: c.synced})
+}
+
+// confirm moves the oldest change in *queue, answered by the server, to
+// the changes the client relay applies. Caller holds mu.
+func (c *conn) confirm(queue *[]stmtChange) {
+	if len(*queue) == 0 {
+		return
+	}
+	c.settled = append(c.settled, (*queue)[0])
+	*queue = (*queue)[1:]
+}
+
+// dropStaged discards the changes sent before the Sync being answered,
+// which the server skips after an error or has not confirmed by the time
+// it is ready. Caller holds mu.
+func (c *conn) dropStaged() {
+	for _, queue := range []*[]stmtChange{&c.parses, &c.closes} {
+		n := 0
+		for n < len(*queue) && (*queue)[n].seg <= c.ready {
+			n++
+		}
+		*queue = (*queue)[n:]
+	}
+}
+
+// settle applies the changes the server has confirmed, in the order it
+// confirmed them. Only the client relay touches the statements and portals.
+func (c *conn) settle() {
+	c.mu.Lock()
+	changes := c.settled
+	c.settled = nil
+	c.mu.Unlock()
+	for _, ch := range changes {
+		switch ch.kind {
+		case stmtParse:
+			c.preparedStmts.put(ch.name, ch.st)
+		case stmtClose:
+			c.preparedStmts.remove(ch.name)
+		case stmtClosePortal:
+		case stmtRelease:
+			c.handleDeallocate(ch.query)
+		}
+	}
+}
+
+// lookupStatement returns the statement a Bind or Describe refers to. On a
+// pooled connection the newest Parse of the name still awaiting its answer
+// is what the client means; the server skips the Bind if that Parse fails.
+func (c *conn) lookupStatement(name string) (*statement, bool) {
+	if c.pooler != "" {
+		c.settle()
+		c.mu.Lock()
+		for i := len(c.parses) - 1; i >= 0; i-- {
+			if c.parses[i].name == name {
+				st := c.parses[i].st
+				c.mu.Unlock()
+				return st, true
+			}
+		}
+		c.mu.Unlock()
+	}
+	return c.preparedStmts.get(name)
+}
+
+// released records that p, a DEALLOCATE or DISCARD on a pooled connection,
+// completed, and starts a new logical session when a session pooler reset
+// the connection with DISCARD ALL.
+func (c *conn) released(p *inflight) {
+	if p.release == "" {
+		return
+	}
+	c.mu.Lock()
+	c.settled = append(c.settled, stmtChange{kind: stmtRelease, query: p.release})
+	c.mu.Unlock()
+	if _, _, discard := parseDeallocate(p.release); discard && c.pooler == PoolSession {
+		c.session.Add(1)
+	}
+}
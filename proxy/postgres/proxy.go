@@ -1,34 +1,234 @@
 package postgres
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
 
 	"github.com/mickamy/sql-tap/proxy"
 )
 
-var _ proxy.Proxy = (*Proxy)(nil)
+var (
+	_ proxy.Proxy    = (*Proxy)(nil)
+	_ proxy.Canceler = (*Proxy)(nil)
+)
 
-// Proxy is a TCP proxy that sits between a PostgreSQL client and server,
+// Proxy is a proxy that sits between a PostgreSQL client and server,
 // capturing query events from the wire protocol.
 type Proxy struct {
-	listenAddr   string
+	listens      []proxy.ListenSpec
 	upstreamAddr string
+	tlsConfig    *tls.Config
+	verbosity    *proxy.Verbosity
+	dialer       proxy.Dialer
+	replicaDSN   string
+	replica      *pgconn.Config
+	appNameLabel AppNameLabel
+	queryTimeout time.Duration
+	policy       proxy.Policy
+	newID        proxy.IDGenerator
+	pooler       PoolMode
+	maxConns     int
+	open         atomic.Int64 // client connections holding a maxConns slot
+	idleTimeout  time.Duration
+	logger       *slog.Logger
 	events       chan proxy.Event
-	listener     net.Listener
+	backends     *backends
+	listeners    []net.Listener
 	wg           sync.WaitGroup
 }
 
-// New creates a new PostgreSQL proxy.
-func New(listenAddr, upstreamAddr string) *Proxy {
-	return &Proxy{
-		listenAddr:   listenAddr,
+// Option configures a Proxy.
+type Option func(*Proxy)
+
+// WithTLSConfig enables TLS termination for clients that send an SSLRequest.
+// Without it, SSLRequests are declined and clients fall back to plaintext.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(p *Proxy) {
+		p.tlsConfig = cfg
+	}
+}
+
+// WithListeners accepts clients on each of specs instead of the listen
+// address given to New, e.g. on both 127.0.0.1:5433 and [::1]:5433. Each
+// event carries the Label of the listener its client connected through, and
+// a client outside a listener's Allow list gets the FATAL ErrorResponse of
+// a missing pg_hba.conf entry, SQLSTATE 28000, with an OpConnect event
+// recording the refusal.
+func WithListeners(specs ...proxy.ListenSpec) Option {
+	return func(p *Proxy) {
+		p.listens = specs
+	}
+}
+
+// WithVerbosity enables detailed capture for connections marked verbose in v.
+func WithVerbosity(v *proxy.Verbosity) Option {
+	return func(p *Proxy) {
+		p.verbosity = v
+	}
+}
+
+// WithDialTimeout bounds how long each client connection waits for its
+// upstream connection (default proxy.DefaultDialTimeout). A negative value
+// leaves only the proxy's context to end the dial. A client whose upstream
+// cannot be reached gets a FATAL ErrorResponse, SQLSTATE 08006, and an
+// OpConnect event records the failure.
+func WithDialTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.dialer.Timeout = d
+	}
+}
+
+// WithKeepAlive sets the TCP keepalive period of upstream connections. A
+// negative value disables keepalives.
+func WithKeepAlive(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.dialer.KeepAlive = d
+	}
+}
+
+// WithLocalAddr dials TCP upstreams from addr, an IP or IP:port, e.g. to
+// pick the interface or source address a firewall allows.
+func WithLocalAddr(addr string) Option {
+	return func(p *Proxy) {
+		p.dialer.LocalAddr = addr
+	}
+}
+
+// WithQueryTimeout cancels statements still running d after they were
+// sent, the way a client's CancelRequest does, reporting an OpCancel event
+// for each. Zero, the default, lets statements run.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.queryTimeout = d
+	}
+}
+
+// WithPolicy checks each Query and Parse against pol before forwarding it.
+// The server never sees a statement pol blocks: it is given a stand-in to
+// reject in the statement's place, so the client gets the error in order
+// with the rest of a pipeline and an open transaction is aborted as after
+// any failed statement. The client's error is SQLSTATE 42501 with the
+// violation's message, and the statement's event carries the Violation.
+// The server logs the stand-in's rejection as a syntax error.
+func WithPolicy(pol proxy.Policy) Option {
+	return func(p *Proxy) {
+		p.policy = pol
+	}
+}
+
+// WithReplica enables experimental replica routing: each client connection
+// also opens a session on the read replica at dsn, and statements that
+// cannot write (see the README) run there when the connection has no
+// transaction open, while everything else goes to the upstream. Each
+// event's Routing records the decision. The replica session runs as dsn's
+// user and database, and session state such as SET, temporary tables, and
+// SQL-level PREPARE stays on the upstream.
+func WithReplica(dsn string) Option {
+	return func(p *Proxy) {
+		p.replicaDSN = dsn
+	}
+}
+
+// WithIDGenerator sets how event IDs are made (see proxy.IDGenerator).
+func WithIDGenerator(g proxy.IDGenerator) Option {
+	return func(p *Proxy) {
+		p.newID = g
+	}
+}
+
+// WithPooler tells the proxy that its clients are the server connections of
+// a pooler such as PgBouncer, running in mode, so each carries the logical
+// sessions of many application clients in turn. Statements then follow
+// what the server confirmed: a Parse, Close, DEALLOCATE, or DISCARD takes
+// effect once it succeeds, so a session's failed attempt to prepare a name
+// another session already holds does not change the query that name runs.
+// Each event's Session numbers the logical session it ran in: a new one
+// starts after every transaction in PoolTransaction mode, and after the
+// pooler's DISCARD ALL in PoolSession mode. A proxy in front of a pooler
+// needs no option, as each of its clients is one session.
+func WithPooler(mode PoolMode) Option {
+	return func(p *Proxy) {
+		p.pooler = mode
+	}
+}
+
+// WithMaxConns limits the proxy to n client connections at a time. Past
+// it, clients get the error the server gives past max_connections, SQLSTATE
+// 53300, and an OpConnect event records each refusal, so a misbehaving
+// application cannot use up the server's connections. Cancel requests are
+// still relayed. Zero, the default, leaves connections unlimited.
+func WithMaxConns(n int) Option {
+	return func(p *Proxy) {
+		p.maxConns = n
+	}
+}
+
+// WithIdleTimeout closes client connections on which neither side has sent
+// anything for d while no statement runs, telling the client with the
+// error of the server's idle_session_timeout, SQLSTATE 57P05. The
+// connection's OpDisconnect event records the timeout. Zero, the default,
+// leaves idle connections open.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(p *Proxy) {
+		p.idleTimeout = d
+	}
+}
+
+// WithLogger sets the logger for the proxy's connection errors; by default
+// they go to slog.Default(). Errors that no Connect or Disconnect event
+// records, such as a failed relay before startup or a cancel that could not
+// be sent, are also emitted as OpAdvisory events carrying a
+// proxy.Diagnostic.
+func WithLogger(l *slog.Logger) Option {
+	return func(p *Proxy) {
+		p.logger = l
+	}
+}
+
+// AppNameLabel selects the per-connection label WithAppNameLabel appends
+// to application_name.
+type AppNameLabel string
+
+const (
+	// AppNameConnID labels connections with their event ConnID.
+	AppNameConnID AppNameLabel = "conn-id"
+	// AppNameClientHost labels connections with the client's host.
+	AppNameClientHost AppNameLabel = "client-host"
+)
+
+// WithAppNameLabel appends a label to the application_name of each relayed
+// StartupMessage, e.g. "myapp (sql-tap 42)", so pg_stat_activity and server
+// logs can be matched to captured connections. The client's name is
+// shortened if needed to keep the label within the server's 63-byte limit.
+func WithAppNameLabel(l AppNameLabel) Option {
+	return func(p *Proxy) {
+		p.appNameLabel = l
+	}
+}
+
+// New creates a new PostgreSQL proxy. Either address may be a unix socket
+// path (see proxy.Network); WithListeners replaces listenAddr.
+func New(listenAddr, upstreamAddr string, opts ...Option) *Proxy {
+	p := &Proxy{
+		listens:      []proxy.ListenSpec{{Addr: listenAddr}},
 		upstreamAddr: upstreamAddr,
 		events:       make(chan proxy.Event, 256),
+		backends:     newBackends(),
+	}
+	for _, opt := range opts {
+		opt(p)
 	}
+	return p
 }
 
 // Events returns the channel of captured events.
@@ -38,57 +238,170 @@ func (p *Proxy) Events() <-chan proxy.Event {
 
 // ListenAndServe starts accepting client connections and relaying them to PostgreSQL.
 func (p *Proxy) ListenAndServe(ctx context.Context) error {
-	var lc net.ListenConfig
-	lis, err := lc.Listen(ctx, "tcp", p.listenAddr)
+	if p.replicaDSN != "" {
+		cfg, err := pgconn.ParseConfig(p.replicaDSN)
+		if err != nil {
+			return fmt.Errorf("postgres: replica: %w", err)
+		}
+		if cfg.ConnectTimeout == 0 && p.dialer.Timeout >= 0 {
+			cfg.ConnectTimeout = cmp.Or(p.dialer.Timeout, proxy.DefaultDialTimeout)
+		}
+		p.replica = cfg
+	}
+	lis, err := proxy.ListenAll(ctx, p.listens)
 	if err != nil {
 		return fmt.Errorf("postgres: listen: %w", err)
 	}
-	p.listener = lis
+	p.listeners = lis
 
 	go func() {
 		<-ctx.Done()
-		_ = lis.Close()
+		_ = proxy.CloseAll(lis)
 	}()
 
-	for {
-		clientConn, err := lis.Accept()
-		if err != nil {
-			if ctx.Err() != nil {
-				return fmt.Errorf("postgres: accept: %w", ctx.Err())
-			}
-			return fmt.Errorf("postgres: accept: %w", err)
-		}
-
+	err = proxy.AcceptAll(lis, p.listens, func(clientConn net.Conn, spec proxy.ListenSpec) {
 		p.wg.Go(func() {
-			p.handleConn(ctx, clientConn)
+			if !spec.Allows(clientConn.RemoteAddr()) {
+				p.refuseClient(ctx, clientConn, spec)
+				return
+			}
+			if !p.admit() {
+				p.refuseFull(ctx, clientConn, spec)
+				return
+			}
+			defer p.leave()
+			p.handleConn(ctx, clientConn, spec)
 		})
+	})
+	if ctx.Err() != nil {
+		return fmt.Errorf("postgres: accept: %w", ctx.Err())
+	}
+	return fmt.Errorf("postgres: accept: %w", err)
+}
+
+// Cancel cancels the statement running on backend pid the way a client's
+// CancelRequest does, quoting the secret key the server gave that
+// connection, so it needs no database login or permission to signal other
+// sessions. pid must serve one of the proxy's open connections; otherwise
+// the error wraps proxy.ErrUnknownBackend. Like a client's cancel, it is
+// reported as an OpCancel event.
+func (p *Proxy) Cancel(ctx context.Context, pid uint32) error {
+	target := p.backends.lookupPID(pid)
+	if target == nil {
+		return fmt.Errorf("postgres: cancel backend %d: %w", pid, proxy.ErrUnknownBackend)
+	}
+	if err := p.cancel(ctx, target); err != nil {
+		return fmt.Errorf("postgres: cancel backend %d: %w", pid, err)
+	}
+	return nil
+}
+
+// timeout cancels the statement c is running, which has passed the query
+// timeout.
+func (p *Proxy) timeout(ctx context.Context, c *conn) {
+	if err := p.cancel(ctx, c); err != nil {
+		c.diagnose("query timeout: cancel failed", fmt.Errorf("postgres: cancel backend %d: %w", c.backendKey.pid, err))
+		return
+	}
+	proxy.CountCancel(proxy.CancelTimeout)
+}
+
+// cancel sends a CancelRequest for target's backend on a new upstream
+// connection and emits an OpCancel event for it.
+func (p *Proxy) cancel(ctx context.Context, target *conn) error {
+	start := time.Now()
+	conn, err := p.dialer.DialContext(ctx, p.upstreamAddr)
+	if err != nil {
+		return fmt.Errorf("dial: %w", err)
 	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write(target.backendKey.cancelRequest()); err != nil {
+		return fmt.Errorf("write: %w", err)
+	}
+	// The server closes the connection once it has read the request.
+	_ = conn.SetReadDeadline(time.Now().Add(cancelTimeout))
+	_, _ = io.Copy(io.Discard, conn)
+	if target.router != nil {
+		if err := target.router.cancelReplica(); err != nil {
+			target.diagnose("replica cancel failed", err)
+		}
+	}
+
+	ev := proxy.Event{Op: proxy.OpCancel, StartTime: start, Duration: time.Since(start)}
+	target.attributeCancel(&ev)
+	proxy.Emit(p.events, ev)
+	return nil
 }
 
 // Close stops the proxy and waits for all connections to finish.
 func (p *Proxy) Close() error {
-	if p.listener != nil {
-		if err := p.listener.Close(); err != nil {
-			return fmt.Errorf("postgres: close listener: %w", err)
-		}
+	if err := proxy.CloseAll(p.listeners); err != nil {
+		return fmt.Errorf("postgres: close listener: %w", err)
 	}
 	p.wg.Wait()
 	return nil
 }
 
-func (p *Proxy) handleConn(ctx context.Context, clientConn net.Conn) {
+func (p *Proxy) handleConn(ctx context.Context, clientConn net.Conn, spec proxy.ListenSpec) {
 	defer func() { _ = clientConn.Close() }()
 
-	var d net.Dialer
-	upstreamConn, err := d.DialContext(ctx, "tcp", p.upstreamAddr)
+	connID := proxy.NewConnID()
+	upstreamConn, err := p.dialer.DialContext(ctx, p.upstreamAddr)
 	if err != nil {
-		log.Printf("postgres: dial upstream %s: %v", p.upstreamAddr, err)
+		c := p.newConn(connID, clientConn, nil, spec)
+		c.logger.Warn("dial upstream failed", "conn_id", connID, "client", c.clientAddr, "upstream", p.upstreamAddr, "err", err)
+		_, _ = c.refuse(ctx, "08006", "sql-tap: could not connect to the server", fmt.Errorf("postgres: dial upstream: %w", err))
 		return
 	}
 	defer func() { _ = upstreamConn.Close() }()
+	p.verbosity.Open(connID)
+	defer p.verbosity.Close(connID)
 
-	c := newConn(clientConn, upstreamConn, p.events)
-	if err := c.relay(ctx); err != nil {
-		log.Printf("postgres: relay %s: %v", clientConn.RemoteAddr(), err)
+	c := p.newConn(connID, clientConn, upstreamConn, spec)
+	c.router = newRouter(p.replica)
+	c.idleTimeout = p.idleTimeout
+	if p.pooler != "" {
+		c.pooler = p.pooler
+		c.session.Store(1)
+	}
+	if p.policy != nil {
+		c.policy = p.policy
+		c.blocked = make(map[string]blockedStmt)
+	}
+	if p.queryTimeout > 0 {
+		c.queryTimeout = p.queryTimeout
+		c.timeout = func() { p.timeout(ctx, c) }
+	}
+	switch p.appNameLabel {
+	case AppNameConnID:
+		c.appNameLabel = connID
+	case AppNameClientHost:
+		c.appNameLabel = clientHost(clientConn.RemoteAddr())
+	}
+	if err := c.guard("relay", func() error { return c.relay(ctx) }); err != nil {
+		c.relayFailed(err)
+	}
+}
+
+// newConn returns a conn for clientConn, accepted through spec and relayed
+// to upstreamConn, with the proxy's event IDs and logger.
+func (p *Proxy) newConn(connID string, clientConn, upstreamConn net.Conn, spec proxy.ListenSpec) *conn {
+	c := newConn(connID, clientConn, upstreamConn, p.events, p.tlsConfig, p.verbosity, p.backends)
+	c.listener = spec.Label
+	c.newID = p.newID
+	c.logger = cmp.Or(p.logger, slog.Default())
+	return c
+}
+
+// clientHost returns the host part of a client address, or "local" for
+// unix socket clients.
+func clientHost(addr net.Addr) string {
+	if addr.Network() == "unix" {
+		return "local"
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
 	}
+	return host
 }
@@ -3,11 +3,17 @@ package postgres_test
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	_ "github.com/jackc/pgx/v5/stdlib"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -58,9 +64,15 @@ func startPostgres(t *testing.T) string {
 	return "127.0.0.1:" + port.Port()
 }
 
-func startProxy(t *testing.T, upstream string) (*pproxy.Proxy, string) {
+func startProxy(t *testing.T, upstream string, opts ...pproxy.Option) (*pproxy.Proxy, string) {
 	t.Helper()
+	addr := freeAddr(t)
+	return startProxyOn(t, addr, upstream, opts...), addr
+}
 
+// freeAddr returns a loopback address with a port nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
 	var lc net.ListenConfig
 	lis, err := lc.Listen(t.Context(), "tcp", "127.0.0.1:0")
 	if err != nil {
@@ -68,8 +80,15 @@ func startProxy(t *testing.T, upstream string) (*pproxy.Proxy, string) {
 	}
 	addr := lis.Addr().String()
 	_ = lis.Close()
+	return addr
+}
 
-	p := pproxy.New(addr, upstream)
+// startProxyOn starts a proxy listening on addr, returning once it accepts
+// connections there.
+func startProxyOn(t *testing.T, addr, upstream string, opts ...pproxy.Option) *pproxy.Proxy {
+	t.Helper()
+
+	p := pproxy.New(addr, upstream, opts...)
 	ctx, cancel := context.WithCancel(t.Context())
 
 	go func() {
@@ -95,7 +114,7 @@ func startProxy(t *testing.T, upstream string) (*pproxy.Proxy, string) {
 		_ = p.Close()
 	})
 
-	return p, addr
+	return p
 }
 
 func openDB(t *testing.T, addr string) *sql.DB {
@@ -109,7 +128,18 @@ func openDB(t *testing.T, addr string) *sql.DB {
 	return db
 }
 
+// waitEvent returns the next event that is not a connect or disconnect,
+// which most tests do not look at.
 func waitEvent(t *testing.T, ch <-chan proxy.Event) proxy.Event {
+	t.Helper()
+	for {
+		if ev := waitAny(t, ch); ev.Op != proxy.OpConnect && ev.Op != proxy.OpDisconnect {
+			return ev
+		}
+	}
+}
+
+func waitAny(t *testing.T, ch <-chan proxy.Event) proxy.Event {
 	t.Helper()
 	select {
 	case ev := <-ch:
@@ -138,6 +168,40 @@ func TestSimpleQuery(t *testing.T) {
 	if ev.Error != "" {
 		t.Errorf("unexpected error: %q", ev.Error)
 	}
+	if ev.BackendPID == 0 {
+		t.Error("expected the backend PID to be captured")
+	}
+	if ev.AuthMethod != "SCRAM-SHA-256" {
+		t.Errorf("auth method = %q, want SCRAM-SHA-256", ev.AuthMethod)
+	}
+	if ev.AuthDuration <= 0 {
+		t.Errorf("auth duration = %v, want > 0", ev.AuthDuration)
+	}
+	if ev.ServerParams["server_version"] == "" || ev.ServerParams["TimeZone"] == "" {
+		t.Errorf("unexpected server params: %v", ev.ServerParams)
+	}
+}
+
+func TestAppNameLabel(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream, pproxy.WithAppNameLabel(pproxy.AppNameConnID))
+
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable&application_name=myapp", testUser, testPassword, addr, testDB)
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	var name string
+	if err := db.QueryRowContext(t.Context(), "SELECT current_setting('application_name')").Scan(&name); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	ev := waitEvent(t, p.Events())
+	if want := "myapp (sql-tap " + ev.ConnID + ")"; name != want {
+		t.Errorf("application_name = %q, want %q", name, want)
+	}
 }
 
 func TestSelectRows(t *testing.T) {
@@ -171,6 +235,16 @@ func TestSelectRows(t *testing.T) {
 	if ev.Query != "SELECT generate_series(1,3)" {
 		t.Errorf("unexpected query: %q", ev.Query)
 	}
+	if want := []proxy.Column{{Name: "generate_series", Type: "int4"}}; !slices.Equal(ev.Columns, want) {
+		t.Errorf("columns = %v, want %v", ev.Columns, want)
+	}
+	if ev.RequestBytes == 0 || ev.ResponseBytes == 0 {
+		t.Errorf("byte counts = %d/%d, want both counted", ev.RequestBytes, ev.ResponseBytes)
+	}
+	if ev.ExecLatency <= 0 || ev.FetchLatency < 0 || ev.QueueLatency+ev.ExecLatency+ev.FetchLatency > ev.Duration {
+		t.Errorf("latency = %v queued, %v execute, %v fetch, want parts of %v",
+			ev.QueueLatency, ev.ExecLatency, ev.FetchLatency, ev.Duration)
+	}
 }
 
 func TestExecDDL(t *testing.T) {
@@ -190,6 +264,62 @@ func TestExecDDL(t *testing.T) {
 	}
 }
 
+func TestErrorDetail(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+
+	if _, err := db.ExecContext(t.Context(), "SELECT * FROM _sql_tap_missing"); err == nil {
+		t.Fatal("expected error for missing table")
+	}
+
+	ev := waitEvent(t, p.Events())
+	d := ev.ErrorDetail
+	if d == nil {
+		t.Fatalf("expected error detail, got error %q", ev.Error)
+	}
+	if d.Code != "42P01" {
+		t.Errorf("expected SQLSTATE 42P01, got %q", d.Code)
+	}
+	if d.Severity != "ERROR" {
+		t.Errorf("expected severity ERROR, got %q", d.Severity)
+	}
+	if d.Position != 15 {
+		t.Errorf("expected position 15, got %d", d.Position)
+	}
+	if d.Message != ev.Error {
+		t.Errorf("expected message %q, got %q", ev.Error, d.Message)
+	}
+}
+
+func TestNotice(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+
+	const q = "DO $$ BEGIN RAISE NOTICE 'hello %', 42; END $$"
+	if _, err := db.ExecContext(t.Context(), q); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+
+	notice := waitEvent(t, p.Events())
+	stmt := waitEvent(t, p.Events())
+	if notice.Op != proxy.OpNotice {
+		t.Fatalf("expected OpNotice before the statement, got %v", notice.Op)
+	}
+	if n := notice.Notice; n == nil || n.Severity != "NOTICE" || n.Message != "hello 42" {
+		t.Errorf("expected NOTICE \"hello 42\", got %+v", notice.Notice)
+	}
+	if notice.NoticeFor != stmt.ID || notice.Query != q {
+		t.Errorf("expected notice linked to statement %s (%q), got %s (%q)", stmt.ID, q, notice.NoticeFor, notice.Query)
+	}
+	if stmt.Error != "" {
+		t.Errorf("expected the statement to succeed, got %q", stmt.Error)
+	}
+}
+
 func TestInsertAffectedRows(t *testing.T) {
 	t.Parallel()
 	upstream := startPostgres(t)
@@ -242,6 +372,9 @@ func TestPreparedStatement(t *testing.T) {
 	if ev.Query != "SELECT $1::int + $2::int" {
 		t.Errorf("unexpected query: %q", ev.Query)
 	}
+	if want := []proxy.Column{{Name: "?column?", Type: "int4"}}; !slices.Equal(ev.Columns, want) {
+		t.Errorf("columns = %v, want %v", ev.Columns, want)
+	}
 	if len(ev.Args) != 2 {
 		t.Fatalf("expected 2 args, got %d", len(ev.Args))
 	}
@@ -253,6 +386,86 @@ func TestPreparedStatement(t *testing.T) {
 	}
 }
 
+func TestPreparedStatementLifecycle(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	if _, err := conn.Prepare(ctx, "named", "SELECT 1 AS named", nil); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+
+	// The unnamed statement is executed after a named Parse.
+	if _, err := conn.ExecParams(ctx, "SELECT $1::int", [][]byte{[]byte("5")}, nil, nil, nil).Close(); err != nil {
+		t.Fatalf("exec params: %v", err)
+	}
+	if ev := waitEvent(t, p.Events()); ev.Query != "SELECT $1::int" || len(ev.Args) != 1 || ev.Args[0] != "5" {
+		t.Errorf("unnamed: unexpected event: query=%q args=%v", ev.Query, ev.Args)
+	}
+
+	if _, err := conn.ExecPrepared(ctx, "named", nil, nil, nil).Close(); err != nil {
+		t.Fatalf("exec prepared: %v", err)
+	}
+	if ev := waitEvent(t, p.Events()); ev.Query != "SELECT 1 AS named" {
+		t.Errorf("named: unexpected query: %q", ev.Query)
+	}
+
+	// Re-preparing a closed name must not report the old text.
+	if err := conn.Deallocate(ctx, "named"); err != nil {
+		t.Fatalf("deallocate: %v", err)
+	}
+	if _, err := conn.Prepare(ctx, "named", "SELECT 2 AS renamed", nil); err != nil {
+		t.Fatalf("prepare again: %v", err)
+	}
+	if _, err := conn.ExecPrepared(ctx, "named", nil, nil, nil).Close(); err != nil {
+		t.Fatalf("exec prepared again: %v", err)
+	}
+	if ev := waitEvent(t, p.Events()); ev.Query != "SELECT 2 AS renamed" {
+		t.Errorf("re-prepared: unexpected query: %q", ev.Query)
+	}
+}
+
+func TestPooler(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream, pproxy.WithPooler(pproxy.PoolTransaction))
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	// One logical session prepares s1; the next one, handed the same server
+	// connection by the pooler, fails to prepare the name again.
+	if _, err := conn.Prepare(ctx, "s1", "SELECT 1::int", nil); err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	if _, err := conn.Prepare(ctx, "s1", "SELECT 2::int", nil); err == nil {
+		t.Fatal("expected preparing s1 twice to fail")
+	}
+	if _, err := conn.ExecPrepared(ctx, "s1", nil, nil, nil).Close(); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	ev := waitEvent(t, p.Events())
+	if ev.Query != "SELECT 1::int" {
+		t.Errorf("query = %q, want the statement the server holds", ev.Query)
+	}
+	if ev.Session != 3 {
+		t.Errorf("session = %d, want 3 after two transactions", ev.Session)
+	}
+}
+
 func TestPreparedStatementStringArgs(t *testing.T) {
 	t.Parallel()
 	upstream := startPostgres(t)
@@ -286,6 +499,110 @@ func TestPreparedStatementStringArgs(t *testing.T) {
 	}
 }
 
+func TestPreparedStatementTypedArgs(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+
+	ctx := t.Context()
+	stmt, err := db.PrepareContext(ctx, "SELECT $1::timestamptz, $2::uuid, $3::float8, $4::bool, $5::date")
+	if err != nil {
+		t.Fatalf("prepare: %v", err)
+	}
+	defer func() { _ = stmt.Close() }()
+
+	ts := time.Date(2026, 3, 1, 12, 30, 0, 0, time.UTC)
+	day := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	id := "6ba7b810-9dad-11d1-80b4-00c04fd430c8"
+	var (
+		gotTS  time.Time
+		gotID  string
+		gotF   float64
+		gotB   bool
+		gotDay time.Time
+	)
+	if err := stmt.QueryRowContext(ctx, ts, id, 1.5, true, day).Scan(&gotTS, &gotID, &gotF, &gotB, &gotDay); err != nil {
+		t.Fatalf("query row: %v", err)
+	}
+
+	ev := waitEvent(t, p.Events())
+	want := []string{"2026-03-01 12:30:00Z", id, "1.5", "t", "2026-03-01"}
+	if len(ev.Args) != len(want) {
+		t.Fatalf("expected %d args, got %v", len(want), ev.Args)
+	}
+	for i, w := range want {
+		if ev.Args[i] != w {
+			t.Errorf("expected arg[%d]=%q, got %q", i, w, ev.Args[i])
+		}
+	}
+}
+
+func TestPipelinedBatch(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	// One Sync for the whole batch: every Parse/Bind/Execute is sent before
+	// the first response arrives.
+	batch := &pgconn.Batch{}
+	batch.ExecParams("SELECT $1::int", [][]byte{[]byte("1")}, nil, nil, nil)
+	batch.ExecParams("SELECT $1::text, $2::text", [][]byte{[]byte("a"), []byte("b")}, nil, nil, nil)
+	batch.ExecParams("SELECT 1 / $1::int", [][]byte{[]byte("0")}, nil, nil, nil)
+	batch.ExecParams("SELECT $1::int", [][]byte{[]byte("4")}, nil, nil, nil) // skipped after the error
+	if _, err := conn.ExecBatch(ctx, batch).ReadAll(); err == nil {
+		t.Fatal("expected the division by zero to fail the batch")
+	}
+
+	want := []struct {
+		query string
+		args  []string
+		err   bool
+	}{
+		{query: "SELECT $1::int", args: []string{"1"}},
+		{query: "SELECT $1::text, $2::text", args: []string{"a", "b"}},
+		{query: "SELECT 1 / $1::int", args: []string{"0"}, err: true},
+	}
+	var batchID string
+	for i, w := range want {
+		ev := waitEvent(t, p.Events())
+		if ev.Query != w.query || !slices.Equal(ev.Args, w.args) || (ev.Error != "") != w.err {
+			t.Errorf("event %d = %q %v (error %q), want %q %v", i, ev.Query, ev.Args, ev.Error, w.query, w.args)
+		}
+		if i == 0 {
+			batchID = ev.BatchID
+		}
+		if ev.BatchID == "" || ev.BatchID != batchID {
+			t.Errorf("event %d batch = %q, want the batch's shared ID", i, ev.BatchID)
+		}
+	}
+
+	sum := waitEvent(t, p.Events())
+	if sum.Op != proxy.OpBatch || sum.BatchID != batchID || sum.BatchSize != 4 || sum.Error == "" {
+		t.Errorf("summary = %v %q x%d (error %q), want a failed batch of 4", sum.Op, sum.BatchID, sum.BatchSize, sum.Error)
+	}
+	if sum.RowsAffected != 2 {
+		t.Errorf("summary rows = %d, want 2", sum.RowsAffected)
+	}
+
+	// The skipped Execute must not take the next query's response.
+	if _, err := conn.Exec(ctx, "SELECT 5").ReadAll(); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	if ev := waitEvent(t, p.Events()); ev.Query != "SELECT 5" || ev.BatchID != "" {
+		t.Errorf("expected the next event to be %q outside any batch, got %q %v (batch %q)",
+			"SELECT 5", ev.Query, ev.Args, ev.BatchID)
+	}
+}
+
 func TestTransactionDetection(t *testing.T) {
 	t.Parallel()
 	upstream := startPostgres(t)
@@ -330,6 +647,402 @@ func TestTransactionDetection(t *testing.T) {
 	}
 }
 
+func TestCursorSummary(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+	db := openDB(t, addr)
+
+	ctx := t.Context()
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	begin := waitEvent(t, p.Events())
+
+	const query = "SELECT g FROM generate_series(1, 25) AS g"
+	for _, q := range []string{
+		"DECLARE c CURSOR FOR " + query,
+		"FETCH 10 FROM c",
+		"FETCH 10 FROM c",
+		"FETCH 10 FROM c", // 5 rows left
+		"CLOSE c",
+	} {
+		if _, err := tx.ExecContext(ctx, q); err != nil {
+			t.Fatalf("%s: %v", q, err)
+		}
+	}
+
+	// DECLARE, FETCH, and CLOSE are reported as one event.
+	ev := waitEvent(t, p.Events())
+	if ev.Cursor != "c" || ev.Query != query {
+		t.Fatalf("expected summary of cursor c, got cursor=%q query=%q", ev.Cursor, ev.Query)
+	}
+	if ev.Fetches != 3 || ev.RowsAffected != 25 {
+		t.Errorf("expected 3 fetches and 25 rows, got %d and %d", ev.Fetches, ev.RowsAffected)
+	}
+	if ev.TxID != begin.TxID {
+		t.Errorf("expected TxID %q, got %q", begin.TxID, ev.TxID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	if ev := waitEvent(t, p.Events()); ev.Op != proxy.OpCommit {
+		t.Errorf("expected OpCommit, got %v", ev.Op)
+	}
+}
+
+func TestConnectionEvents(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	for range 2 {
+		if _, err := conn.Exec(ctx, "SELECT 1").ReadAll(); err != nil {
+			t.Fatalf("exec: %v", err)
+		}
+	}
+	if err := conn.Close(ctx); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	connect := waitAny(t, p.Events())
+	if connect.Op != proxy.OpConnect || connect.User != testUser || connect.Database != testDB || connect.Error != "" {
+		t.Fatalf("expected a clean connect by %s@%s, got %+v", testUser, testDB, connect)
+	}
+	if connect.ClientAddr == "" || connect.BackendPID == 0 || connect.Duration <= 0 {
+		t.Errorf("expected the connect to carry the client, backend, and startup time, got %+v", connect)
+	}
+	for range 2 {
+		waitEvent(t, p.Events())
+	}
+	disconnect := waitAny(t, p.Events())
+	if disconnect.Op != proxy.OpDisconnect || disconnect.ConnID != connect.ConnID {
+		t.Fatalf("expected a disconnect of conn %s, got %+v", connect.ConnID, disconnect)
+	}
+	if disconnect.Queries != 2 || !disconnect.StartTime.Equal(connect.StartTime) || disconnect.Duration < connect.Duration {
+		t.Errorf("expected 2 queries over the connection's lifetime, got %d over %s", disconnect.Queries, disconnect.Duration)
+	}
+
+	bad := fmt.Sprintf("postgres://%s:wrong@%s/%s?sslmode=disable", testUser, addr, testDB)
+	if _, err := pgconn.Connect(ctx, bad); err == nil {
+		t.Fatal("expected a wrong password to fail")
+	}
+	if ev := waitAny(t, p.Events()); ev.Op != proxy.OpConnect || ev.Error == "" {
+		t.Errorf("expected a failed connect, got %+v", ev)
+	}
+}
+
+func TestStartupParams(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable&search_path=public&options=%s",
+		testUser, testPassword, addr, testDB, url.QueryEscape("-c app.api_key=hunter2 -c work_mem=8MB"))
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	ev := waitAny(t, p.Events())
+	if ev.Op != proxy.OpConnect {
+		t.Fatalf("expected a connect, got %v", ev.Op)
+	}
+	if got := ev.StartupParams["search_path"]; got != "public" {
+		t.Errorf("search_path = %q, want public", got)
+	}
+	if got, want := ev.StartupParams["options"], "-c app.api_key=[redacted] -c work_mem=8MB"; got != want {
+		t.Errorf("options = %q, want %q", got, want)
+	}
+	if ev.SSLRequested {
+		t.Error("expected no SSL request with sslmode=disable")
+	}
+}
+
+func TestCancelRequest(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	const query = "SELECT pg_sleep(30)"
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, query).ReadAll()
+		errCh <- err
+	}()
+	time.Sleep(200 * time.Millisecond) // let the query start
+
+	if err := conn.CancelRequest(ctx); err != nil {
+		t.Fatalf("cancel request: %v", err)
+	}
+
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected the query to be canceled")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("query was not canceled")
+	}
+
+	var cancel, canceled proxy.Event
+	for range 2 {
+		ev := waitEvent(t, p.Events())
+		if ev.Op == proxy.OpCancel {
+			cancel = ev
+		} else {
+			canceled = ev
+		}
+	}
+	if cancel.Query != query {
+		t.Errorf("expected cancel event for %q, got %q", query, cancel.Query)
+	}
+	if cancel.ConnID == "" || cancel.ConnID != canceled.ConnID {
+		t.Errorf("expected cancel on conn %q, got %q", canceled.ConnID, cancel.ConnID)
+	}
+	if canceled.Error == "" {
+		t.Error("expected the canceled query to carry an error")
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream, pproxy.WithQueryTimeout(300*time.Millisecond))
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	before := proxy.CancelCounts().TimedOut
+	if _, err := conn.Exec(ctx, "SELECT 1").ReadAll(); err != nil {
+		t.Fatalf("fast query: %v", err)
+	}
+	waitEvent(t, p.Events())
+
+	const query = "SELECT pg_sleep(30)"
+	start := time.Now()
+	if _, err := conn.Exec(ctx, query).ReadAll(); err == nil {
+		t.Fatal("expected the query to be canceled")
+	}
+	if d := time.Since(start); d > 10*time.Second {
+		t.Fatalf("query ran %s", d)
+	}
+
+	var cancel proxy.Event
+	for range 2 {
+		if ev := waitEvent(t, p.Events()); ev.Op == proxy.OpCancel {
+			cancel = ev
+		}
+	}
+	if cancel.Query != query {
+		t.Errorf("expected cancel event for %q, got %q", query, cancel.Query)
+	}
+	if got := proxy.CancelCounts().TimedOut - before; got != 1 {
+		t.Errorf("timed out = %d, want 1", got)
+	}
+}
+
+func TestListeners(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+
+	addr, deniedAddr := freeAddr(t), freeAddr(t)
+	denied := proxy.ListenSpec{Addr: deniedAddr, Label: "lan", Allow: []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}}
+	p := startProxyOn(t, addr, upstream, pproxy.WithListeners(proxy.ListenSpec{Addr: addr, Label: "local"}, denied))
+
+	ctx := t.Context()
+	dsn := func(addr string) string {
+		return fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	}
+	conn, err := pgconn.Connect(ctx, dsn(addr))
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+	if ev := waitAny(t, p.Events()); ev.Op != proxy.OpConnect || ev.Listener != "local" {
+		t.Errorf("expected a connect through local, got %+v", ev)
+	}
+
+	_, err = pgconn.Connect(ctx, dsn(deniedAddr))
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28000" {
+		t.Fatalf("expected the connection through lan to be refused with 28000, got %v", err)
+	}
+	if ev := waitAny(t, p.Events()); ev.Op != proxy.OpConnect || ev.Listener != "lan" ||
+		!strings.Contains(ev.Error, "not allowed") {
+		t.Errorf("expected a refused connect through lan, got %+v", ev)
+	}
+}
+
+func TestConnLimits(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream, pproxy.WithMaxConns(1), pproxy.WithIdleTimeout(500*time.Millisecond))
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+	connect := waitAny(t, p.Events())
+
+	_, err = pgconn.Connect(ctx, dsn)
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "53300" {
+		t.Fatalf("expected the second connection to be refused with 53300, got %v", err)
+	}
+	if ev := waitAny(t, p.Events()); ev.Op != proxy.OpConnect || !strings.Contains(ev.Error, "too many connections") ||
+		ev.User != testUser {
+		t.Errorf("expected a refused connect by %s, got %+v", testUser, ev)
+	}
+
+	// A running statement keeps the connection open past the timeout.
+	if _, err := conn.Exec(ctx, "SELECT pg_sleep(1)").ReadAll(); err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+	waitEvent(t, p.Events())
+
+	disconnect := waitAny(t, p.Events())
+	if disconnect.Op != proxy.OpDisconnect || disconnect.ConnID != connect.ConnID ||
+		!strings.Contains(disconnect.Error, "idle") {
+		t.Fatalf("expected an idle disconnect of conn %s, got %+v", connect.ConnID, disconnect)
+	}
+	if _, err := conn.Exec(ctx, "SELECT 1").ReadAll(); err == nil {
+		t.Error("expected the idle connection to be closed")
+	}
+
+	again, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("expected the freed slot to admit a connection: %v", err)
+	}
+	_ = again.Close(ctx)
+}
+
+func TestProxyCancel(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	p, addr := startProxy(t, upstream)
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	if err := p.Cancel(ctx, conn.PID()+1); !errors.Is(err, proxy.ErrUnknownBackend) {
+		t.Errorf("Cancel(unknown pid) = %v, want ErrUnknownBackend", err)
+	}
+
+	const query = "SELECT pg_sleep(30)"
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := conn.Exec(ctx, query).ReadAll()
+		errCh <- err
+	}()
+	time.Sleep(200 * time.Millisecond) // let the query start
+
+	if err := p.Cancel(ctx, conn.PID()); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	select {
+	case err := <-errCh:
+		if err == nil {
+			t.Fatal("expected the query to be canceled")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("query was not canceled")
+	}
+
+	for range 2 {
+		if ev := waitEvent(t, p.Events()); ev.Op == proxy.OpCancel && ev.Query != query {
+			t.Errorf("expected cancel event for %q, got %q", query, ev.Query)
+		}
+	}
+}
+
+// policyFunc adapts a function to proxy.Policy.
+type policyFunc func(proxy.Event) *proxy.Violation
+
+func (f policyFunc) Check(ev proxy.Event) *proxy.Violation { return f(ev) }
+
+func TestPolicy(t *testing.T) {
+	t.Parallel()
+	upstream := startPostgres(t)
+	noDelete := policyFunc(func(ev proxy.Event) *proxy.Violation {
+		if !strings.HasPrefix(ev.Query, "DELETE") {
+			return nil
+		}
+		return &proxy.Violation{Rule: "no-delete", Message: "no deletes", Blocked: true}
+	})
+	p, addr := startProxy(t, upstream, pproxy.WithPolicy(noDelete))
+
+	ctx := t.Context()
+	dsn := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, addr, testDB)
+	conn, err := pgconn.Connect(ctx, dsn)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close(context.Background()) })
+
+	if _, err := conn.Exec(ctx, "CREATE TABLE _sql_tap_policy (id int); INSERT INTO _sql_tap_policy VALUES (1)").ReadAll(); err != nil {
+		t.Fatalf("setup: %v", err)
+	}
+	waitEvent(t, p.Events())
+
+	var pgErr *pgconn.PgError
+	if _, err := conn.Exec(ctx, "DELETE FROM _sql_tap_policy").ReadAll(); !errors.As(err, &pgErr) || pgErr.Code != "42501" || pgErr.Message != "no deletes" {
+		t.Fatalf("simple delete: err = %v, want the policy's error", err)
+	}
+	ev := waitEvent(t, p.Events())
+	if ev.Query != "DELETE FROM _sql_tap_policy" || ev.Error != "no deletes" || ev.Violation == nil || ev.Violation.Rule != "no-delete" {
+		t.Errorf("simple delete event = %+v", ev)
+	}
+
+	res := conn.ExecParams(ctx, "DELETE FROM _sql_tap_policy WHERE id = $1", [][]byte{[]byte("1")}, nil, nil, nil).Read()
+	if !errors.As(res.Err, &pgErr) || pgErr.Code != "42501" {
+		t.Fatalf("extended delete: err = %v, want the policy's error", res.Err)
+	}
+	ev = waitEvent(t, p.Events())
+	if ev.Op != proxy.OpExecute || ev.Violation == nil || !slices.Equal(ev.Args, []string{"1"}) {
+		t.Errorf("extended delete event = %+v", ev)
+	}
+
+	rows, err := conn.Exec(ctx, "SELECT count(*) FROM _sql_tap_policy").ReadAll()
+	if err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if got := string(rows[0].Rows[0][0]); got != "1" {
+		t.Errorf("rows left = %s, want the blocked deletes not to have run", got)
+	}
+}
+
 func TestErrorCapture(t *testing.T) {
 	t.Parallel()
 	upstream := startPostgres(t)
@@ -346,3 +1059,75 @@ func TestErrorCapture(t *testing.T) {
 		t.Error("expected non-empty error")
 	}
 }
+
+func TestReplicaRouting(t *testing.T) {
+	t.Parallel()
+	primary := startPostgres(t)
+	replica := startPostgres(t)
+	replicaDSN := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", testUser, testPassword, replica, testDB)
+
+	// Each server names itself, so the proxy's choice shows in the results.
+	for name, addr := range map[string]string{"primary": primary, "replica": replica} {
+		db := openDB(t, addr)
+		if _, err := db.ExecContext(t.Context(), "CREATE TABLE whoami (name text)"); err != nil {
+			t.Fatalf("create on %s: %v", name, err)
+		}
+		if _, err := db.ExecContext(t.Context(), "INSERT INTO whoami VALUES ($1)", name); err != nil {
+			t.Fatalf("insert on %s: %v", name, err)
+		}
+	}
+
+	p, addr := startProxy(t, primary, pproxy.WithReplica(replicaDSN))
+	db := openDB(t, addr)
+	db.SetMaxOpenConns(1)
+
+	whoami := func(q querier) string {
+		t.Helper()
+		var name string
+		if err := q.QueryRowContext(t.Context(), "SELECT name FROM whoami LIMIT 1").Scan(&name); err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		return name
+	}
+	wantRouting := func(replica bool, reason string) {
+		t.Helper()
+		ev := waitEvent(t, p.Events())
+		if ev.Routing == nil || ev.Routing.Replica != replica || ev.Routing.Reason != reason {
+			t.Errorf("%q routing = %+v, want replica=%v %s", ev.Query, ev.Routing, replica, reason)
+		}
+	}
+
+	if got := whoami(db); got != "replica" {
+		t.Errorf("read-only select ran on %s", got)
+	}
+	wantRouting(true, "read-only")
+
+	if _, err := db.ExecContext(t.Context(), "UPDATE whoami SET name = name"); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	wantRouting(false, "write")
+
+	tx, err := db.BeginTx(t.Context(), nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	wantRouting(false, "write")
+	// The cached statement prepared on the replica is prepared again here.
+	if got := whoami(tx); got != "primary" {
+		t.Errorf("select in a transaction ran on %s", got)
+	}
+	wantRouting(false, "transaction")
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+	wantRouting(false, "transaction")
+
+	if got := whoami(db); got != "replica" {
+		t.Errorf("select after the transaction ran on %s", got)
+	}
+	wantRouting(true, "read-only")
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
@@ -0,0 +1,494 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+
+	pgproto "github.com/jackc/pgproto3/v2"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/mickamy/sql-tap/internal/query"
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// maxBatch bounds the extended-protocol messages held back before a routing
+// decision; a longer pipeline is routed on what it has shown so far.
+const maxBatch = 256
+
+// Routing reasons, see proxy.Routing.
+const (
+	reasonReadOnly    = "read-only"
+	reasonWrite       = "write"
+	reasonTransaction = "transaction"
+	reasonPipelined   = "pipelined"
+	reasonUnavailable = "replica unavailable"
+)
+
+// session is one of the server sessions a routed client connection relays
+// to: the primary, which the client authenticated against, or the replica,
+// opened with the replica DSN on first use.
+type session struct {
+	replica  bool
+	conn     net.Conn
+	frontend *pgproto.Frontend
+	key      backendKey
+
+	// Client relay only.
+	stmts map[string]*pgproto.Parse // statements prepared on this session
+	stale map[string]bool           // closed by the client on the other session, still prepared here
+
+	// Under router.mu.
+	txStatus byte    // from the last ReadyForQuery
+	unsynced bool    // extended-protocol messages sent since the last Sync
+	building drops   // responses to drop before the next Sync
+	queue    []drops // per Query or Sync awaiting ReadyForQuery
+}
+
+// drops counts the answers to Parse and Close messages the router injected,
+// which the client must not see.
+type drops struct {
+	parse int
+	close int
+}
+
+func newSession(replica bool, c net.Conn, fe *pgproto.Frontend, key backendKey, txStatus byte) *session {
+	return &session{
+		replica:  replica,
+		conn:     c,
+		frontend: fe,
+		key:      key,
+		stmts:    make(map[string]*pgproto.Parse),
+		stale:    make(map[string]bool),
+		txStatus: txStatus,
+	}
+}
+
+// router splits a client connection's traffic between the primary and a
+// read replica. Statements that cannot write go to the replica when the
+// connection is idle, with no transaction open and no responses
+// outstanding; everything else stays on the session it is on. Prepared
+// statements are re-prepared on the other session when a routed Bind or
+// Describe needs them.
+type router struct {
+	cfg *pgconn.Config
+
+	// Client relay only.
+	replica  *session // nil until first routed to
+	failed   bool     // the replica could not be reached
+	batching bool
+	batch    []pgproto.FrontendMessage // held until Sync, Flush, or maxBatch
+
+	mu      sync.Mutex
+	primary *session
+	current *session
+
+	wg sync.WaitGroup // the replica relay
+}
+
+func newRouter(cfg *pgconn.Config) *router {
+	if cfg == nil {
+		return nil
+	}
+	return &router{cfg: cfg}
+}
+
+// start attaches the primary session once the client has authenticated.
+func (r *router) start(c *conn) {
+	r.primary = newSession(false, c.upstreamConn, c.upstream, c.backendKey, 'I')
+	r.current = r.primary
+}
+
+// close ends the replica session and waits for its relay.
+func (r *router) close() {
+	if r.replica != nil {
+		_ = r.replica.conn.Close()
+	}
+	r.wg.Wait()
+}
+
+// routeClientMsg captures msg and sends it to the session the router picks.
+func (c *conn) routeClientMsg(ctx context.Context, msg pgproto.FrontendMessage) error {
+	r := c.router
+	switch msg.(type) {
+	case *pgproto.Parse, *pgproto.Bind, *pgproto.Describe, *pgproto.Execute, *pgproto.Close:
+		if !r.batching && !r.idle() {
+			var s *session
+			s, c.routing = r.choose(ctx, c, nil)
+			return r.forward(c, s, msg)
+		}
+		held, err := cloneFrontendMessage(msg)
+		if err != nil {
+			return err
+		}
+		r.batching = true
+		r.batch = append(r.batch, held)
+		if len(r.batch) < maxBatch {
+			return nil
+		}
+		_, err = c.flushBatch(ctx)
+		return err
+	}
+
+	// Anything else ends a held batch, which goes first.
+	s, err := c.flushBatch(ctx)
+	if err != nil {
+		return err
+	}
+	switch m := msg.(type) {
+	case *pgproto.Query:
+		s, c.routing = r.choose(ctx, c, []string{m.String})
+	case *pgproto.Terminate:
+		if r.replica != nil {
+			_ = encodeAndWrite(r.replica.conn, m)
+		}
+		return encodeAndWrite(c.upstreamConn, m)
+	}
+	return r.forward(c, s, msg)
+}
+
+// flushBatch routes the held messages and returns the session they went to.
+func (c *conn) flushBatch(ctx context.Context) (*session, error) {
+	r := c.router
+	if !r.batching {
+		return r.currentSession(), nil
+	}
+	var s *session
+	s, c.routing = r.choose(ctx, c, c.batchQueries(r.batch))
+	batch := r.batch
+	r.batch, r.batching = nil, false
+	for _, msg := range batch {
+		if err := r.forward(c, s, msg); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// batchQueries returns the queries a batch of extended-protocol messages
+// executes.
+func (c *conn) batchQueries(batch []pgproto.FrontendMessage) []string {
+	stmts := make(map[string]string)
+	portals := make(map[string]string)
+	var out []string
+	for _, msg := range batch {
+		switch m := msg.(type) {
+		case *pgproto.Parse:
+			stmts[m.Name] = m.Query
+		case *pgproto.Bind:
+			q, ok := stmts[m.PreparedStatement]
+			if !ok {
+				if st, found := c.preparedStmts.get(m.PreparedStatement); found {
+					q = st.query
+				}
+			}
+			portals[m.DestinationPortal] = q
+		case *pgproto.Execute:
+			q, ok := portals[m.Portal]
+			if !ok {
+				p, _ := c.portals.get(m.Portal)
+				q = p.query
+			}
+			out = append(out, q)
+		}
+	}
+	return out
+}
+
+func (r *router) currentSession() *session {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// idle reports whether the connection may switch sessions.
+func (r *router) idle() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.current
+	return s.txStatus == 'I' && !s.unsynced && len(s.queue) == 0
+}
+
+// choose picks the session for a request executing queries and makes it
+// current.
+func (r *router) choose(ctx context.Context, c *conn, queries []string) (*session, *proxy.Routing) {
+	r.mu.Lock()
+	cur := r.current
+	idle := cur.txStatus == 'I' && !cur.unsynced && len(cur.queue) == 0
+	r.mu.Unlock()
+	if !idle {
+		reason := reasonPipelined
+		if cur.txStatus != 'I' {
+			reason = reasonTransaction
+		}
+		return cur, &proxy.Routing{Replica: cur.replica, Reason: reason}
+	}
+
+	readOnly := len(queries) > 0
+	for _, q := range queries {
+		readOnly = readOnly && query.ReadOnly(q)
+	}
+	s, reason := r.primary, reasonWrite
+	if readOnly {
+		s, reason = r.replicaSession(ctx, c), reasonReadOnly
+		if s == nil {
+			s, reason = r.primary, reasonUnavailable
+		}
+	}
+	r.mu.Lock()
+	r.current = s
+	r.mu.Unlock()
+	return s, &proxy.Routing{Replica: s.replica, Reason: reason}
+}
+
+// replicaSession returns the replica session, connecting on first use. It
+// returns nil once a connection attempt has failed.
+func (r *router) replicaSession(ctx context.Context, c *conn) *session {
+	if r.replica != nil || r.failed {
+		return r.replica
+	}
+	s, err := r.connect(ctx)
+	if err != nil {
+		c.diagnose("replica unavailable", err)
+		r.failed = true
+		return nil
+	}
+	r.replica = s
+	r.wg.Go(func() {
+		if err := c.relayUpstreamToClient(ctx, s); err != nil {
+			c.diagnose("replica relay failed", err)
+		}
+		// The client cannot continue without the session it is routed to.
+		_ = c.clientConn.Close()
+	})
+	return s
+}
+
+func (r *router) connect(ctx context.Context) (*session, error) {
+	pc, err := pgconn.ConnectConfig(ctx, r.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("connect: %w", err)
+	}
+	if err := pc.SyncConn(ctx); err != nil {
+		_ = pc.Close(ctx)
+		return nil, fmt.Errorf("sync: %w", err)
+	}
+	hc, err := pc.Hijack()
+	if err != nil {
+		_ = pc.Close(ctx)
+		return nil, fmt.Errorf("hijack: %w", err)
+	}
+	fe := pgproto.NewFrontend(pgproto.NewChunkReader(hc.Conn), hc.Conn)
+	return newSession(true, hc.Conn, fe, backendKey{pid: hc.PID, secret: hc.SecretKey}, hc.TxStatus), nil
+}
+
+// forward captures msg and writes it to s, first preparing any statement
+// msg needs that s does not have.
+func (r *router) forward(c *conn, s *session, msg pgproto.FrontendMessage) error {
+	if err := r.prepare(s, msg); err != nil {
+		return err
+	}
+	r.track(s, msg)
+	return c.captureAndWrite(s.conn, msg)
+}
+
+// prepare injects the Close and Parse messages s needs before msg.
+func (r *router) prepare(s *session, msg pgproto.FrontendMessage) error {
+	var name string
+	switch m := msg.(type) {
+	case *pgproto.Parse:
+		if m.Name != "" && s.stale[m.Name] {
+			return r.inject(s, &pgproto.Close{ObjectType: 'S', Name: m.Name})
+		}
+		return nil
+	case *pgproto.Bind:
+		name = m.PreparedStatement
+	case *pgproto.Describe:
+		if m.ObjectType != 'S' {
+			return nil
+		}
+		name = m.Name
+	default:
+		return nil
+	}
+	if _, ok := s.stmts[name]; ok {
+		return nil
+	}
+	other := r.primary
+	if s == r.primary {
+		other = r.replica
+	}
+	if other == nil {
+		return nil
+	}
+	parse, ok := other.stmts[name]
+	if !ok {
+		return nil // let the server report the unknown statement
+	}
+	if s.stale[name] {
+		if err := r.inject(s, &pgproto.Close{ObjectType: 'S', Name: name}); err != nil {
+			return err
+		}
+	}
+	if err := r.inject(s, parse); err != nil {
+		return err
+	}
+	s.stmts[name] = parse
+	return nil
+}
+
+// inject sends msg to s on the router's behalf and drops its answer.
+func (r *router) inject(s *session, msg pgproto.FrontendMessage) error {
+	r.mu.Lock()
+	switch m := msg.(type) {
+	case *pgproto.Parse:
+		s.building.parse++
+	case *pgproto.Close:
+		s.building.close++
+		delete(s.stale, m.Name)
+	}
+	s.unsynced = true
+	r.mu.Unlock()
+	return encodeAndWrite(s.conn, msg)
+}
+
+// track records what msg does to the state of s and the other session.
+func (r *router) track(s *session, msg pgproto.FrontendMessage) {
+	other := r.primary
+	if s == r.primary {
+		other = r.replica
+	}
+	switch m := msg.(type) {
+	case *pgproto.Query:
+		// A simple Query destroys the unnamed statement.
+		delete(s.stmts, "")
+		if name, all, _ := parseDeallocate(m.String); name != "" || all {
+			r.closeStmts(s, other, name, all)
+		}
+	case *pgproto.Parse:
+		s.stmts[m.Name] = &pgproto.Parse{Name: m.Name, Query: m.Query, ParameterOIDs: slices.Clone(m.ParameterOIDs)}
+		if m.Name == "" && other != nil {
+			delete(other.stmts, "")
+		}
+	case *pgproto.Close:
+		if m.ObjectType == 'S' {
+			r.closeStmts(s, other, m.Name, false)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch msg.(type) {
+	case *pgproto.Query:
+		s.queue = append(s.queue, drops{})
+	case *pgproto.Sync:
+		s.queue = append(s.queue, s.building)
+		s.building, s.unsynced = drops{}, false
+	case *pgproto.Parse, *pgproto.Bind, *pgproto.Describe, *pgproto.Execute, *pgproto.Close:
+		s.unsynced = true
+	}
+}
+
+// closeStmts forgets statements closed on s; the other session keeps its
+// copies until they are closed there before reuse.
+func (r *router) closeStmts(s, other *session, name string, all bool) {
+	for _, sess := range []*session{s, other} {
+		if sess == nil {
+			continue
+		}
+		for n := range sess.stmts {
+			if (all || n == name) && n != "" {
+				delete(sess.stmts, n)
+				if sess != s {
+					sess.stale[n] = true
+				}
+			}
+		}
+	}
+	if all {
+		clear(s.stale)
+	} else {
+		delete(s.stale, name)
+	}
+}
+
+// received updates the state of s for a message from its server and
+// reports whether the message answers an injected one and must be dropped.
+func (r *router) received(s *session, msg pgproto.BackendMessage) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seg := &s.building
+	if len(s.queue) > 0 {
+		seg = &s.queue[0]
+	}
+	switch m := msg.(type) {
+	case *pgproto.ParseComplete:
+		if seg.parse > 0 {
+			seg.parse--
+			return true
+		}
+	case *pgproto.CloseComplete:
+		if seg.close > 0 {
+			seg.close--
+			return true
+		}
+	case *pgproto.ReadyForQuery:
+		if len(s.queue) > 0 {
+			s.queue = slices.Delete(s.queue, 0, 1)
+		}
+		s.txStatus = m.TxStatus
+	}
+	return false
+}
+
+// cancelReplica forwards a CancelRequest to the replica when the connection
+// is routed there, since the primary is not running its query.
+func (r *router) cancelReplica() error {
+	r.mu.Lock()
+	s := r.current
+	r.mu.Unlock()
+	if s == nil || !s.replica {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelTimeout)
+	defer cancel()
+	conn, err := proxy.Dial(ctx, s.conn.RemoteAddr().String())
+	if err != nil {
+		return fmt.Errorf("postgres: replica cancel: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write(s.key.cancelRequest()); err != nil {
+		return fmt.Errorf("postgres: replica cancel: %w", err)
+	}
+	return nil
+}
+
+// cloneFrontendMessage copies msg, which the client reader reuses on its
+// next Receive.
+func cloneFrontendMessage(msg pgproto.FrontendMessage) (pgproto.FrontendMessage, error) {
+	buf, err := msg.Encode(nil)
+	if err != nil {
+		return nil, fmt.Errorf("postgres: encode: %w", err)
+	}
+	var dst pgproto.FrontendMessage
+	switch msg.(type) {
+	case *pgproto.Parse:
+		dst = &pgproto.Parse{}
+	case *pgproto.Bind:
+		dst = &pgproto.Bind{}
+	case *pgproto.Describe:
+		dst = &pgproto.Describe{}
+	case *pgproto.Execute:
+		dst = &pgproto.Execute{}
+	case *pgproto.Close:
+		dst = &pgproto.Close{}
+	default:
+		return msg, nil
+	}
+	if err := dst.Decode(buf[5:]); err != nil {
+		return nil, fmt.Errorf("postgres: decode: %w", err)
+	}
+	return dst, nil
+}
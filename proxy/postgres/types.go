@@ -0,0 +1,75 @@
+package postgres
+
+import (
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgtype"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+// statement is a prepared statement and the types the server reported for
+// it. Type OIDs come from Parse (when the client specifies them) and from the
+// ParameterDescription and RowDescription answering a Describe; zero means
+// unknown. results names the result columns the Describe reported.
+// violation is the policy's verdict on the query, if it matched.
+type statement struct {
+	query      string
+	paramOIDs  []uint32
+	resultOIDs []uint32
+	results    []proxy.Column
+	violation  *proxy.Violation
+}
+
+// column describes how one result column is encoded on the wire.
+type column struct {
+	oid    uint32
+	binary bool
+}
+
+// resultColumns pairs a statement's result types with a Bind's result format
+// codes.
+func resultColumns(oids []uint32, formats []int16) []column {
+	cols := make([]column, len(oids))
+	for i, oid := range oids {
+		cols[i] = column{oid: oid, binary: isBinaryFormat(formats, i)}
+	}
+	return cols
+}
+
+// typeDecoder renders binary-format values as text. It is not safe for
+// concurrent use.
+type typeDecoder struct {
+	types *pgtype.Map // created on first use; building it is not free
+}
+
+// typeName returns the name of type oid, e.g. "int4", or the OID itself for
+// types pgx does not know, such as enums and other user-defined types.
+func (d *typeDecoder) typeName(oid uint32) string {
+	if d.types == nil {
+		d.types = pgtype.NewMap()
+	}
+	if t, ok := d.types.TypeForOID(oid); ok {
+		return t.Name
+	}
+	return strconv.FormatUint(uint64(oid), 10)
+}
+
+// decode renders a binary value of type oid in the type's text format, the
+// way psql shows it, falling back to a guess from its length when the type
+// is unknown or the value does not decode.
+func (d *typeDecoder) decode(oid uint32, src []byte) string {
+	if oid != 0 {
+		if d.types == nil {
+			d.types = pgtype.NewMap()
+		}
+		if t, ok := d.types.TypeForOID(oid); ok {
+			if v, err := t.Codec.DecodeValue(d.types, oid, pgtype.BinaryFormatCode, src); err == nil {
+				if text, err := d.types.Encode(oid, pgtype.TextFormatCode, v, nil); err == nil {
+					return string(text)
+				}
+			}
+		}
+	}
+	return decodeBinaryParam(src)
+}
@@ -2,22 +2,33 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync/atomic"
 	"time"
+
+	"github.com/mickamy/sql-tap/internal/query"
 )
 
 // Op represents the type of database operation captured.
 type Op int32
 
 const (
-	OpQuery    Op = iota // Simple query or extended-query execute
-	OpExec               // Non-query execution
-	OpPrepare            // Prepared statement parse
-	OpBind               // Parameter binding
-	OpExecute            // Extended-protocol execute
-	OpBegin              // Transaction begin
-	OpCommit             // Transaction commit
-	OpRollback           // Transaction rollback
+	OpQuery      Op = iota // Simple query or extended-query execute
+	OpExec                 // Non-query execution
+	OpPrepare              // Prepared statement parse
+	OpBind                 // Parameter binding
+	OpExecute              // Extended-protocol execute
+	OpBegin                // Transaction begin
+	OpCommit               // Transaction commit
+	OpRollback             // Transaction rollback
+	OpCancel               // Cancel request for a running query
+	OpAdvisory             // Synthetic event from the daemon, e.g. a traffic change or a recovered panic
+	OpBatch                // Summary of statements pipelined before one Sync (PostgreSQL)
+	OpNotice               // NOTICE, WARNING, or other non-error message from the server (PostgreSQL)
+	OpConnect              // A client connection's startup and authentication
+	OpDisconnect           // The end of a client connection, lasting its lifetime
 )
 
 func (o Op) String() string {
@@ -38,21 +49,199 @@ func (o Op) String() string {
 		return "Commit"
 	case OpRollback:
 		return "Rollback"
+	case OpCancel:
+		return "Cancel"
+	case OpAdvisory:
+		return "Advisory"
+	case OpBatch:
+		return "Batch"
+	case OpNotice:
+		return "Notice"
+	case OpConnect:
+		return "Connect"
+	case OpDisconnect:
+		return "Disconnect"
 	}
 	return fmt.Sprintf("UnknownOp(%d)", o)
 }
 
+// ParseOp returns the Op whose String is s.
+func ParseOp(s string) (Op, bool) {
+	for o := OpQuery; o <= OpDisconnect; o++ {
+		if o.String() == s {
+			return o, true
+		}
+	}
+	return 0, false
+}
+
+// MaxRowSamples is the maximum number of result rows sampled per event
+// when detailed capture is enabled for a connection.
+const MaxRowSamples = 5
+
+// MaxSampleValueLen is the maximum length of a single sampled column value.
+const MaxSampleValueLen = 256
+
+// Phase is a named portion of a query's lifecycle, recorded under detailed capture.
+type Phase struct {
+	Name     string // e.g. "parse", "bind", "execute", "fetch"
+	Duration time.Duration
+}
+
+// ErrorDetail is the structured error the database returned for a failed
+// query, or the notice it sent on an OpNotice event. Postgres reports every
+// field; MySQL only Code and Message.
+type ErrorDetail struct {
+	Code     string // SQLSTATE
+	Severity string // e.g. "ERROR", "FATAL"
+	Message  string
+	Detail   string
+	Hint     string
+	Position int // 1-based character offset into the query; 0 when unknown
+}
+
+// Anomaly marks an event that ran much slower than its query's recent
+// baseline.
+type Anomaly struct {
+	Score    float64       // standard deviations above the baseline mean
+	Baseline time.Duration // the baseline mean latency
+}
+
+// NPlusOne marks an event in a burst of calls of its query from one
+// connection, a probable N+1 pattern.
+type NPlusOne struct {
+	Calls int           // calls in the burst so far, including this one
+	Span  time.Duration // from the burst's first call to this one
+	InTx  bool          // the burst is within a transaction rather than a time window
+}
+
+// Routing records where a proxy in replica routing mode sent a query, and
+// why.
+type Routing struct {
+	Replica bool   // sent to the read replica rather than the primary
+	Reason  string // "read-only", "write", "transaction", "pipelined", or "replica unavailable"
+}
+
+// Column is a result column of a statement, as the server described it.
+type Column struct {
+	Name string
+	Type string // e.g. "int4" (PostgreSQL) or "VARCHAR" (MySQL)
+}
+
+// Violation records that a statement broke a Policy rule.
+type Violation struct {
+	Rule    string // name of the rule that matched
+	Message string // the error the client gets in place of the statement's result
+	Blocked bool   // false when the policy only reports violations, forwarding the statement
+}
+
+// TrafficKind classifies a TrafficChange.
+type TrafficKind int
+
+const (
+	TrafficNew  TrafficKind = iota + 1 // a fingerprint ran for the first time
+	TrafficRise                        // calls rose by the detector's factor or more
+	TrafficDrop                        // calls fell by the detector's factor or more
+)
+
+// TrafficChange describes a drastic shift in how often a query runs.
+type TrafficChange struct {
+	Kind     TrafficKind
+	Calls    int           // in the window that revealed the change; 0 for TrafficNew
+	Baseline float64       // average calls per window before it
+	Window   time.Duration // the detector's window length
+}
+
+// Quota describes a tenant taking more than its share of the queries.
+type Quota struct {
+	Field    string        // the extracted field naming tenants, e.g. tenant_id
+	Value    string        // the tenant
+	Calls    int           // the tenant's queries in the window
+	Total    int           // all queries in the window
+	MaxShare float64       // the share of Total the tenant exceeded
+	Window   time.Duration // the tracker's window length
+}
+
+// Plan is an EXPLAIN plan the daemon ran on its own for a slow statement.
+type Plan struct {
+	ForID   string   // ID of the slow event the plan was run for
+	Analyze bool     // EXPLAIN ANALYZE rather than EXPLAIN
+	Text    string   // the plan; tabular plans rendered as a table
+	Indexes []string // suggested CREATE INDEX statements
+}
+
 // Event represents a captured database query event.
 type Event struct {
-	ID           string
-	Op           Op
-	Query        string
-	Args         []string
-	StartTime    time.Time
-	Duration     time.Duration
-	RowsAffected int64
-	Error        string
-	TxID         string
+	ID            string
+	ConnID        string
+	ClientAddr    string            // remote address of the client connection
+	Listener      string            // Label of the ListenSpec the client connected through
+	User          string            // database user the client authenticated as
+	Database      string            // database selected when the client connected
+	BackendPID    uint32            // server process (PostgreSQL) or connection (MySQL) ID serving the connection
+	Session       uint64            // logical session on a pooler's server connection, from 1; PostgreSQL with WithPooler only
+	AuthMethod    string            // e.g. "SCRAM-SHA-256", "md5", or "trust"; PostgreSQL only
+	AuthDuration  time.Duration     // from the StartupMessage to AuthenticationOk; PostgreSQL only
+	ServerParams  map[string]string // ParameterStatus values from startup, e.g. server_version; read-only
+	StartupParams map[string]string // on OpConnect events, the client's StartupMessage, credentials redacted; PostgreSQL only
+	SSLRequested  bool              // on OpConnect events, the client asked for SSL (declined unless TLSVersion is set); PostgreSQL only
+	Upstream      string            // upstream name when running several proxies via Manager
+	Op            Op
+	Query         string
+	Fingerprint   string // Query with literals and placeholders normalized to ?, set by Emit
+	Args          []string
+	StartTime     time.Time
+	Duration      time.Duration
+	QueueLatency  time.Duration // of Duration, waiting behind earlier pipelined statements; PostgreSQL only
+	ExecLatency   time.Duration // of Duration, until the first row, or completion without rows; PostgreSQL only
+	FetchLatency  time.Duration // of Duration, from the first row to completion; PostgreSQL only
+	RowsAffected  int64
+	RequestBytes  int64 // wire size of the client messages that issued the query
+	ResponseBytes int64 // wire size of the server's reply, up to the statement's completion
+	Error         string
+	ErrorDetail   *ErrorDetail // structured form of Error, when the server sent one
+	Notice        *ErrorDetail // set on OpNotice events; Severity is e.g. "NOTICE" or "WARNING"
+	NoticeFor     string       // on OpNotice events, ID of the statement that raised the notice
+	Queries       int64        // on OpDisconnect events, Query, Exec, and Execute events the connection produced
+	TxID          string
+	GlobalTxID    string            // distributed transaction id, set on two-phase commit statements only
+	TLSVersion    string            // negotiated client-side TLS version; empty for plaintext connections
+	TLSCipher     string            // negotiated client-side TLS cipher suite
+	Phases        []Phase           // detailed capture only
+	RowSamples    [][]string        // detailed capture only; at most MaxRowSamples rows
+	Columns       []Column          // result columns the server described; read-only
+	Tags          []string          // labels from tagging rules, applied by the daemon
+	Fields        map[string]string // values pulled from the query by the daemon's extraction rules, e.g. tenant_id
+	Cursor        string            // set when the event summarizes a DECLAREd cursor
+	Fetches       int               // FETCH/MOVE statements folded into a cursor summary
+	BatchID       string            // shared by the statements of a pipelined batch and its OpBatch summary
+	BatchSize     int               // statements in the batch, on its OpBatch summary
+	TraceID       string            // W3C trace ID from the query's sqlcommenter traceparent
+	SpanID        string            // the caller's span ID from the same traceparent
+	Route         string            // HTTP route from the query's sqlcommenter route key
+	RequestID     string            // HTTP request from the same comment's request_id key
+	Caller        []string          // call stack from the same comment's caller key, innermost frame first
+	Extensions    map[string]string // integrators' own metadata, carried to watchers, the store, and exports
+	Anomaly       *Anomaly          // set by the daemon's anomaly detector
+	NPlusOne      *NPlusOne         // set by the daemon's N+1 detector
+	Traffic       *TrafficChange    // set on OpAdvisory events from the traffic detector
+	Quota         *Quota            // set on OpAdvisory events from the tenant tracker
+	Plan          *Plan             // set on OpAdvisory events from the daemon's auto-explain
+	Panic         *Panic            // set on OpAdvisory events reporting a recovered panic
+	Diagnostic    *Diagnostic       // set on OpAdvisory events reporting a logged connection error
+	Routing       *Routing          // set in replica routing mode (PostgreSQL only)
+	Violation     *Violation        // set on statements a proxy's Policy matched
+}
+
+// SampleValue truncates a column value for inclusion in RowSamples.
+func SampleValue(b []byte) string {
+	if b == nil {
+		return "NULL"
+	}
+	if len(b) > MaxSampleValueLen {
+		return string(b[:MaxSampleValueLen]) + "…"
+	}
+	return string(b)
 }
 
 // Proxy is the common interface for DB protocol proxies.
@@ -64,3 +253,105 @@ type Proxy interface {
 	// Close stops the proxy.
 	Close() error
 }
+
+// Canceler is implemented by proxies that can cancel the statement running
+// on one of their own connections' backends without a database login.
+type Canceler interface {
+	// Cancel cancels the statement running on backend pid.
+	Cancel(ctx context.Context, pid uint32) error
+}
+
+// Policy decides which statements a proxy forwards. Proxies check each
+// query a client sends before forwarding it, and answer a blocked one with
+// an error of their own; the server never runs it.
+type Policy interface {
+	// Check returns the violation a statement commits, or nil if it may
+	// run. ev carries the query, the operation it was sent as, and the
+	// connection's metadata.
+	Check(ev Event) *Violation
+}
+
+// ErrUnknownBackend is returned by Canceler.Cancel for a backend that does
+// not serve one of the proxy's connections.
+var ErrUnknownBackend = errors.New("proxy: unknown backend")
+
+var droppedEvents atomic.Uint64
+
+// Emit delivers ev on events without blocking, after fingerprinting its
+// query and extracting its trace context, route, request ID, and caller.
+// When the channel is full the event is discarded and counted in
+// DroppedEvents.
+func Emit(events chan<- Event, ev Event) {
+	if ev.Fingerprint == "" && ev.Query != "" {
+		ev.Fingerprint = query.Fingerprint(ev.Query)
+	}
+	if tags := SQLComment(ev.Query); tags != nil {
+		if ev.TraceID == "" {
+			ev.TraceID, ev.SpanID = parseTraceparent(tags["traceparent"])
+		}
+		if ev.Route == "" {
+			ev.Route = tags["route"]
+		}
+		if ev.RequestID == "" {
+			ev.RequestID = tags["request_id"]
+		}
+		if ev.Caller == nil && tags["caller"] != "" {
+			ev.Caller = strings.Split(tags["caller"], "\n")
+		}
+	}
+	select {
+	case events <- ev:
+	default:
+		droppedEvents.Add(1)
+	}
+}
+
+// DroppedEvents returns how many events proxies have discarded because their
+// event channel was full.
+func DroppedEvents() uint64 {
+	return droppedEvents.Load()
+}
+
+// CancelCause is why a statement running upstream was cancelled or
+// abandoned.
+type CancelCause int
+
+const (
+	// CancelRelayed is a client's own cancel request, relayed upstream.
+	CancelRelayed CancelCause = iota
+	// CancelKilled is a statement cancelled, or session ended, by the
+	// daemon's Kill RPC.
+	CancelKilled
+	// CancelTimeout is a cancel the proxy sent because the statement ran
+	// past its query timeout.
+	CancelTimeout
+	// CancelDisconnect is a statement still running when its client
+	// disconnected, which the server abandons.
+	CancelDisconnect
+)
+
+var cancellations [CancelDisconnect + 1]atomic.Uint64
+
+// CountCancel records one cancellation with cause c.
+func CountCancel(c CancelCause) {
+	cancellations[c].Add(1)
+}
+
+// Cancellations counts the statements proxies have cancelled, or seen
+// abandoned, by cause.
+type Cancellations struct {
+	Relayed      uint64
+	Killed       uint64
+	TimedOut     uint64
+	Disconnected uint64
+}
+
+// CancelCounts returns the cancellations recorded by CountCancel.
+func CancelCounts() Cancellations {
+	return Cancellations{
+		Relayed:      cancellations[CancelRelayed].Load(),
+		Killed:       cancellations[CancelKilled].Load(),
+		TimedOut:     cancellations[CancelTimeout].Load(),
+		Disconnected: cancellations[CancelDisconnect].Load(),
+	}
+}
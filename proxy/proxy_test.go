@@ -0,0 +1,133 @@
+package proxy_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestEmit_CountsDrops(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan proxy.Event, 1)
+	before := proxy.DroppedEvents()
+
+	proxy.Emit(events, proxy.Event{ID: "1"})
+	proxy.Emit(events, proxy.Event{ID: "2"}) // channel full
+
+	if got := (<-events).ID; got != "1" {
+		t.Fatalf("expected event 1, got %q", got)
+	}
+	if got := proxy.DroppedEvents() - before; got != 1 {
+		t.Fatalf("expected 1 dropped event, got %d", got)
+	}
+}
+
+func TestCountCancel(t *testing.T) {
+	t.Parallel()
+
+	before := proxy.CancelCounts()
+	proxy.CountCancel(proxy.CancelRelayed)
+	proxy.CountCancel(proxy.CancelTimeout)
+	proxy.CountCancel(proxy.CancelTimeout)
+	proxy.CountCancel(proxy.CancelDisconnect)
+	got := proxy.CancelCounts()
+
+	want := proxy.Cancellations{Relayed: 1, TimedOut: 2, Disconnected: 1}
+	delta := proxy.Cancellations{
+		Relayed:      got.Relayed - before.Relayed,
+		Killed:       got.Killed - before.Killed,
+		TimedOut:     got.TimedOut - before.TimedOut,
+		Disconnected: got.Disconnected - before.Disconnected,
+	}
+	if delta != want {
+		t.Errorf("counted %+v, want %+v", delta, want)
+	}
+}
+
+func TestEmit_TraceContext(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan proxy.Event, 1)
+	proxy.Emit(events, proxy.Event{Query: "SELECT 1 /*traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/"})
+
+	ev := <-events
+	if ev.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || ev.SpanID != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected trace context: %q %q", ev.TraceID, ev.SpanID)
+	}
+}
+
+func TestEmit_Fingerprint(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan proxy.Event, 1)
+	proxy.Emit(events, proxy.Event{Query: "SELECT * FROM users WHERE id = 42"})
+
+	if got := (<-events).Fingerprint; got != "SELECT * FROM users WHERE id = ?" {
+		t.Fatalf("unexpected fingerprint: %q", got)
+	}
+}
+
+func TestEmit_Route(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan proxy.Event, 1)
+	proxy.Emit(events, proxy.Event{Query: "SELECT * FROM users WHERE id = 42 /*request_id='r1',route='GET+%2Fusers%2F%7Bid%7D'*/"})
+
+	ev := <-events
+	if ev.Route != "GET /users/{id}" || ev.RequestID != "r1" {
+		t.Fatalf("unexpected route or request: %q %q", ev.Route, ev.RequestID)
+	}
+	if ev.Fingerprint != "SELECT * FROM users WHERE id = ?" {
+		t.Fatalf("the comment should not be part of the fingerprint: %q", ev.Fingerprint)
+	}
+}
+
+func TestEmit_Caller(t *testing.T) {
+	t.Parallel()
+
+	events := make(chan proxy.Event, 1)
+	proxy.Emit(events, proxy.Event{Query: "SELECT 1 /*caller='store.Find+users.go%3A42%0Amain.handler+main.go%3A7'*/"})
+
+	want := []string{"store.Find users.go:42", "main.handler main.go:7"}
+	if got := (<-events).Caller; !slices.Equal(got, want) {
+		t.Fatalf("caller = %q, want %q", got, want)
+	}
+}
+
+func TestIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		gen  proxy.IDGenerator
+		want string
+	}{
+		{name: "default", want: "7"},
+		{name: "custom", gen: func(connID string, seq uint64) string { return fmt.Sprintf("web-1/%s/%d", connID, seq) }, want: "web-1/3/7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.gen.ID("3", 7); got != tt.want {
+				t.Errorf("ID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOp(t *testing.T) {
+	t.Parallel()
+
+	for o := proxy.OpQuery; o <= proxy.OpDisconnect; o++ {
+		if got, ok := proxy.ParseOp(o.String()); !ok || got != o {
+			t.Errorf("ParseOp(%q) = %v, %v", o.String(), got, ok)
+		}
+	}
+	if _, ok := proxy.ParseOp("query"); ok {
+		t.Error("ParseOp accepted a lowercase name")
+	}
+}
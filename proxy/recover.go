@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// ErrPanic is wrapped by the errors Recovered returns.
+var ErrPanic = errors.New("panic")
+
+// Panic is a panic recovered from a connection's handling or a pipeline
+// stage, reported on an OpAdvisory event.
+type Panic struct {
+	Where string // the code that panicked, e.g. "postgres: client relay"
+	Value string // what it panicked with
+	Stack string // the panicking goroutine's stack
+}
+
+var panics atomic.Uint64
+
+// Panics returns how many panics Recovered has reported.
+func Panics() uint64 {
+	return panics.Load()
+}
+
+// Recovered reports value, recovered from a panic in where, so that a bug
+// handling one connection closes only that connection instead of the
+// process. It logs the panic with its stack and returns ev, which identifies
+// the connection or event being handled, as an OpAdvisory event carrying
+// them, along with an error wrapping ErrPanic for the code that ends the
+// connection. Call it from the deferred function that recovered value.
+func Recovered(where string, value any, ev Event) (Event, error) {
+	stack := string(debug.Stack())
+	n := panics.Add(1)
+	slog.Error("panic recovered", "where", where, "value", value, "stack", stack)
+
+	ev.ID = "panic-" + strconv.FormatUint(n, 10)
+	ev.Op = OpAdvisory
+	ev.StartTime = time.Now()
+	ev.Duration = 0
+	ev.Error = fmt.Sprintf("panic in %s: %v", where, value)
+	ev.ErrorDetail = nil
+	ev.Panic = &Panic{Where: where, Value: fmt.Sprint(value), Stack: stack}
+	return ev, fmt.Errorf("%s: %w: %v", where, ErrPanic, value)
+}
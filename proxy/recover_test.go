@@ -0,0 +1,42 @@
+package proxy_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestRecovered(t *testing.T) {
+	t.Parallel()
+
+	before := proxy.Panics()
+	ev, err := func() (ev proxy.Event, err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				ev, err = proxy.Recovered("postgres: relay", v, proxy.Event{
+					ID: "7", Op: proxy.OpQuery, ConnID: "c1", Query: "SELECT 1", Error: "earlier",
+				})
+			}
+		}()
+		panic("index out of range")
+	}()
+
+	if !errors.Is(err, proxy.ErrPanic) || !strings.Contains(err.Error(), "postgres: relay") {
+		t.Fatalf("err = %v, want one wrapping ErrPanic naming the stage", err)
+	}
+	if ev.Op != proxy.OpAdvisory || !strings.HasPrefix(ev.ID, "panic-") || ev.ConnID != "c1" || ev.Query != "SELECT 1" {
+		t.Fatalf("unexpected diagnostic: %+v", ev)
+	}
+	if ev.Error != "panic in postgres: relay: index out of range" {
+		t.Errorf("error = %q", ev.Error)
+	}
+	p := ev.Panic
+	if p == nil || p.Where != "postgres: relay" || p.Value != "index out of range" || !strings.Contains(p.Stack, "TestRecovered") {
+		t.Fatalf("unexpected panic: %+v", p)
+	}
+	if got := proxy.Panics(); got <= before {
+		t.Errorf("Panics() = %d, want more than %d", got, before)
+	}
+}
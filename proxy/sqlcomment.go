@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SQLComment parses the sqlcommenter comment at the end of query, e.g.
+// /*action='list',traceparent='00-...-01'*/, into its key/value pairs. It
+// returns nil when the query does not end with such a comment.
+func SQLComment(query string) map[string]string {
+	q := strings.TrimRight(query, " \t\r\n;")
+	body, ok := strings.CutSuffix(q, "*/")
+	if !ok {
+		return nil
+	}
+	start := strings.LastIndex(body, "/*")
+	if start < 0 {
+		return nil
+	}
+	body = body[start+2:]
+
+	var pairs map[string]string
+	for body != "" {
+		key, rest, ok := strings.Cut(body, "='")
+		if !ok {
+			return nil
+		}
+		val, rest, ok := cutCommentValue(rest)
+		if !ok {
+			return nil
+		}
+		k, kerr := url.QueryUnescape(strings.TrimSpace(key))
+		v, verr := url.QueryUnescape(val)
+		if kerr != nil || verr != nil {
+			return nil
+		}
+		if pairs == nil {
+			pairs = make(map[string]string)
+		}
+		pairs[k] = v
+		body = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return pairs
+}
+
+// cutCommentValue splits s after the closing quote of a sqlcommenter value,
+// undoing the \' escape.
+func cutCommentValue(s string) (string, string, bool) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				b.WriteByte(s[i])
+			}
+		case '\'':
+			return b.String(), s[i+1:], true
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", false
+}
+
+// TraceContext extracts the W3C trace and parent span IDs from the
+// traceparent key of query's sqlcommenter comment. Both are empty when the
+// query carries no valid traceparent.
+func TraceContext(query string) (traceID, spanID string) {
+	if !strings.Contains(query, "traceparent") {
+		return "", ""
+	}
+	return parseTraceparent(SQLComment(query)["traceparent"])
+}
+
+// parseTraceparent splits a traceparent value,
+// version-traceid-parentid-flags, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func parseTraceparent(v string) (traceID, spanID string) {
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" ||
+		!isTraceHex(parts[1], 32) || !isTraceHex(parts[2], 16) {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+// isTraceHex reports whether s is n lowercase hex digits, not all zero.
+func isTraceHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	zero := true
+	for i := range len(s) {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+		zero = zero && c == '0'
+	}
+	return !zero
+}
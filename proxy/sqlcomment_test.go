@@ -0,0 +1,84 @@
+package proxy_test
+
+import (
+	"maps"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestSQLComment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		want  map[string]string
+	}{
+		{name: "no comment", query: "SELECT 1", want: nil},
+		{
+			name:  "sqlcommenter",
+			query: "SELECT * FROM users /*action='list',controller='users',framework='rails'*/",
+			want:  map[string]string{"action": "list", "controller": "users", "framework": "rails"},
+		},
+		{
+			name:  "url encoded and escaped",
+			query: `SELECT 1 /*route='%2Fusers%2F%3Aid',note='it\'s'*/;`,
+			want:  map[string]string{"route": "/users/:id", "note": "it's"},
+		},
+		{name: "plain comment", query: "SELECT 1 /* hand-written */", want: nil},
+		{name: "comment not at end", query: "/*action='list'*/ SELECT 1", want: nil},
+		{name: "unterminated value", query: "SELECT 1 /*action='list*/", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := proxy.SQLComment(tt.query); !maps.Equal(got, tt.want) {
+				t.Errorf("SQLComment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		query     string
+		wantTrace string
+		wantSpan  string
+	}{
+		{
+			name:      "traceparent",
+			query:     "SELECT 1 /*traceparent='" + traceparent + "'*/",
+			wantTrace: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpan:  "00f067aa0ba902b7",
+		},
+		{
+			name:      "with other keys",
+			query:     "SELECT 1 /*db_driver='pgx',traceparent='" + traceparent + "',tracestate='congo%3Dt61rcWkgMzE'*/",
+			wantTrace: "4bf92f3577b34da6a3ce929d0e0e4736",
+			wantSpan:  "00f067aa0ba902b7",
+		},
+		{name: "none", query: "SELECT 1"},
+		{name: "zero trace id", query: "SELECT 1 /*traceparent='00-00000000000000000000000000000000-00f067aa0ba902b7-01'*/"},
+		{name: "invalid version", query: "SELECT 1 /*traceparent='ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01'*/"},
+		{name: "short span id", query: "SELECT 1 /*traceparent='00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01'*/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			trace, span := proxy.TraceContext(tt.query)
+			if trace != tt.wantTrace || span != tt.wantSpan {
+				t.Errorf("TraceContext() = (%q, %q), want (%q, %q)", trace, span, tt.wantTrace, tt.wantSpan)
+			}
+		})
+	}
+}
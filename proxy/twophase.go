@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"strings"
+)
+
+// TwoPhaseKind is the role of a two-phase commit statement.
+type TwoPhaseKind int
+
+const (
+	TwoPhaseStart    TwoPhaseKind = iota + 1 // XA START / XA BEGIN
+	TwoPhaseEnd                              // XA END
+	TwoPhasePrepare                          // PREPARE TRANSACTION / XA PREPARE
+	TwoPhaseCommit                           // COMMIT PREPARED / XA COMMIT
+	TwoPhaseRollback                         // ROLLBACK PREPARED / XA ROLLBACK
+)
+
+// TwoPhase describes a parsed two-phase commit statement.
+type TwoPhase struct {
+	Kind TwoPhaseKind
+	// XID identifies the branch on its upstream: the Postgres gid, or the
+	// MySQL xid normalized to "gtrid,bqual,formatID".
+	XID string
+	// GlobalID identifies the distributed transaction across upstreams: the
+	// Postgres gid, or the MySQL gtrid.
+	GlobalID string
+	// OnePhase is set for XA COMMIT ... ONE PHASE, which commits a branch
+	// that was never prepared.
+	OnePhase bool
+}
+
+var (
+	pgTwoPhase = []struct {
+		keywords string
+		kind     TwoPhaseKind
+	}{
+		{"PREPARE TRANSACTION", TwoPhasePrepare},
+		{"COMMIT PREPARED", TwoPhaseCommit},
+		{"ROLLBACK PREPARED", TwoPhaseRollback},
+	}
+	xaTwoPhase = []struct {
+		keywords string
+		kind     TwoPhaseKind
+	}{
+		{"XA START", TwoPhaseStart},
+		{"XA BEGIN", TwoPhaseStart},
+		{"XA END", TwoPhaseEnd},
+		{"XA PREPARE", TwoPhasePrepare},
+		{"XA COMMIT", TwoPhaseCommit},
+		{"XA ROLLBACK", TwoPhaseRollback},
+	}
+)
+
+// ParseTwoPhase recognizes Postgres two-phase commit statements (PREPARE
+// TRANSACTION, COMMIT PREPARED, ROLLBACK PREPARED) and MySQL XA statements.
+func ParseTwoPhase(query string) (TwoPhase, bool) {
+	q := strings.TrimSpace(query)
+
+	for _, p := range pgTwoPhase {
+		rest, ok := cutKeywords(q, p.keywords)
+		if !ok {
+			continue
+		}
+		gid, _, ok := cutLiteral(rest)
+		if !ok {
+			return TwoPhase{}, false
+		}
+		return TwoPhase{Kind: p.kind, XID: gid, GlobalID: gid}, true
+	}
+
+	for _, p := range xaTwoPhase {
+		rest, ok := cutKeywords(q, p.keywords)
+		if !ok {
+			continue
+		}
+		// xid: gtrid [, bqual [, formatID]]
+		parts := []string{"", "", "1"}
+		for i := range parts {
+			if i > 0 {
+				after, ok := strings.CutPrefix(strings.TrimSpace(rest), ",")
+				if !ok {
+					break
+				}
+				rest = after
+			}
+			v, after, ok := cutLiteral(rest)
+			if !ok {
+				return TwoPhase{}, false
+			}
+			parts[i], rest = v, after
+		}
+		tp := TwoPhase{Kind: p.kind, XID: strings.Join(parts, ","), GlobalID: parts[0]}
+		if p.kind == TwoPhaseCommit {
+			_, tp.OnePhase = cutKeywords(strings.TrimSpace(rest), "ONE PHASE")
+		}
+		return tp, true
+	}
+	return TwoPhase{}, false
+}
+
+// cutKeywords reports whether q starts with the space-separated keywords,
+// matched case-insensitively, and returns the remainder.
+func cutKeywords(q, keywords string) (string, bool) {
+	for _, kw := range strings.Fields(keywords) {
+		q = strings.TrimLeft(q, " \t\r\n")
+		if len(q) < len(kw) || !strings.EqualFold(q[:len(kw)], kw) {
+			return "", false
+		}
+		q = q[len(kw):]
+		if q != "" && isIdentByte(q[0]) {
+			return "", false // e.g. "XA STARTED"
+		}
+	}
+	return q, true
+}
+
+// cutLiteral parses a leading string literal ('..' or "..", with doubled
+// quotes as escapes), hex literal (X'..' or 0x..), or bare number, and
+// returns its text and what follows. Quoted values are unquoted; hex literals
+// are kept as written.
+func cutLiteral(s string) (string, string, bool) {
+	s = strings.TrimLeft(s, " \t\r\n")
+	if s == "" {
+		return "", "", false
+	}
+
+	switch quote := s[0]; {
+	case quote == '\'' || quote == '"':
+		var b strings.Builder
+		for i := 1; i < len(s); i++ {
+			if s[i] != quote {
+				b.WriteByte(s[i])
+				continue
+			}
+			if i+1 < len(s) && s[i+1] == quote {
+				b.WriteByte(quote)
+				i++
+				continue
+			}
+			return b.String(), s[i+1:], true
+		}
+		return "", "", false
+	case len(s) > 1 && (s[0] == 'x' || s[0] == 'X') && s[1] == '\'':
+		end := strings.IndexByte(s[2:], '\'')
+		if end < 0 {
+			return "", "", false
+		}
+		return s[:end+3], s[end+3:], true
+	}
+
+	end := 0
+	for end < len(s) && isIdentByte(s[end]) {
+		end++
+	}
+	if end == 0 {
+		return "", "", false
+	}
+	return s[:end], s[end:], true
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || ('0' <= c && c <= '9') || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
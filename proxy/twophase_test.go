@@ -0,0 +1,92 @@
+package proxy_test
+
+import (
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestParseTwoPhase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		query string
+		want  proxy.TwoPhase
+		ok    bool
+	}{
+		{
+			name:  "pg prepare",
+			query: "PREPARE TRANSACTION 'order-42'",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhasePrepare, XID: "order-42", GlobalID: "order-42"},
+			ok:    true,
+		},
+		{
+			name:  "pg commit prepared lowercase with semicolon",
+			query: "  commit prepared 'order-42';",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseCommit, XID: "order-42", GlobalID: "order-42"},
+			ok:    true,
+		},
+		{
+			name:  "pg rollback prepared escaped quote",
+			query: "ROLLBACK PREPARED 'it''s'",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseRollback, XID: "it's", GlobalID: "it's"},
+			ok:    true,
+		},
+		{
+			name:  "xa start gtrid only",
+			query: "XA START 'g1'",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseStart, XID: "g1,,1", GlobalID: "g1"},
+			ok:    true,
+		},
+		{
+			name:  "xa begin with bqual and format",
+			query: "xa begin 'g1', 'b2', 3",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseStart, XID: "g1,b2,3", GlobalID: "g1"},
+			ok:    true,
+		},
+		{
+			name:  "xa end suspend",
+			query: "XA END 'g1','b2' SUSPEND",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseEnd, XID: "g1,b2,1", GlobalID: "g1"},
+			ok:    true,
+		},
+		{
+			name:  "xa prepare",
+			query: "XA PREPARE 'g1'",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhasePrepare, XID: "g1,,1", GlobalID: "g1"},
+			ok:    true,
+		},
+		{
+			name:  "xa commit one phase",
+			query: "XA COMMIT 'g1' ONE PHASE",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseCommit, XID: "g1,,1", GlobalID: "g1", OnePhase: true},
+			ok:    true,
+		},
+		{
+			name:  "xa rollback hex",
+			query: "XA ROLLBACK X'6731'",
+			want:  proxy.TwoPhase{Kind: proxy.TwoPhaseRollback, XID: "X'6731',,1", GlobalID: "X'6731'"},
+			ok:    true,
+		},
+		{name: "plain commit", query: "COMMIT"},
+		{name: "prepared statement", query: "PREPARE transaction_stmt AS SELECT 1"},
+		{name: "xa recover", query: "XA RECOVER"},
+		{name: "missing gid", query: "PREPARE TRANSACTION"},
+		{name: "unterminated gid", query: "COMMIT PREPARED 'abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := proxy.ParseTwoPhase(tt.query)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"errors"
+	"slices"
+	"sync"
+)
+
+// ErrUnknownConn is returned by Verbosity.Set for a connection that is not
+// open.
+var ErrUnknownConn = errors.New("proxy: unknown connection")
+
+// Verbosity tracks which connections have detailed capture enabled.
+// Detailed capture adds row samples and phase timings to events; it is off by
+// default to keep capture cheap. Proxies register each connection with Open
+// and Close, so only open connections can be made verbose and a closed one
+// leaves nothing behind. A nil *Verbosity reports every connection as
+// non-verbose.
+type Verbosity struct {
+	mu   sync.RWMutex
+	open map[string]bool // open connections; true if verbose
+}
+
+// NewVerbosity creates an empty Verbosity set.
+func NewVerbosity() *Verbosity {
+	return &Verbosity{open: make(map[string]bool)}
+}
+
+// Open registers a connection, with detailed capture off.
+func (v *Verbosity) Open(connID string) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.open[connID] = false
+}
+
+// Close forgets a connection, and with it whether it was verbose.
+func (v *Verbosity) Close(connID string) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	delete(v.open, connID)
+}
+
+// Set enables or disables detailed capture for an open connection, or
+// returns ErrUnknownConn.
+func (v *Verbosity) Set(connID string, verbose bool) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, ok := v.open[connID]; !ok {
+		return ErrUnknownConn
+	}
+	v.open[connID] = verbose
+	return nil
+}
+
+// Verbose reports whether detailed capture is enabled for a connection.
+func (v *Verbosity) Verbose(connID string) bool {
+	if v == nil {
+		return false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	return v.open[connID]
+}
+
+// List returns the IDs of all connections with detailed capture enabled, sorted.
+func (v *Verbosity) List() []string {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	var ids []string
+	for id, verbose := range v.open {
+		if verbose {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,38 @@
+package proxy_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+)
+
+func TestVerbosity(t *testing.T) {
+	t.Parallel()
+
+	v := proxy.NewVerbosity()
+	if err := v.Set("1", true); !errors.Is(err, proxy.ErrUnknownConn) {
+		t.Fatalf("Set(unopened) = %v, want ErrUnknownConn", err)
+	}
+
+	v.Open("1")
+	v.Open("2")
+	if err := v.Set("2", true); err != nil {
+		t.Fatal(err)
+	}
+	if v.Verbose("1") || !v.Verbose("2") {
+		t.Fatalf("Verbose(1, 2) = %v, %v, want false, true", v.Verbose("1"), v.Verbose("2"))
+	}
+	if got := v.List(); !slices.Equal(got, []string{"2"}) {
+		t.Fatalf("List() = %v, want [2]", got)
+	}
+
+	v.Close("2")
+	if v.Verbose("2") || len(v.List()) != 0 {
+		t.Fatalf("closed connection still verbose: %v", v.List())
+	}
+	if err := v.Set("2", true); !errors.Is(err, proxy.ErrUnknownConn) {
+		t.Fatalf("Set(closed) = %v, want ErrUnknownConn", err)
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/store"
+)
+
+// queryCmd searches stored events, either through a daemon's Query RPC or
+// directly in a store file after the daemon has stopped.
+func queryCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap query", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap query — Search stored events\n\nUsage:\n  sql-tap query [flags] <addr|store file>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	since := fs.String("since", "", "only events that started after this: a duration ago (e.g. 2h) or an RFC 3339 time")
+	until := fs.String("until", "", "only events that started before this: a duration ago or an RFC 3339 time")
+	sql := fs.String("sql", "", "only statements with the same fingerprint as this SQL")
+	tx := fs.String("tx", "", "only events of this transaction ID")
+	grep := fs.String("grep", "", "only queries containing this text (case-insensitive)")
+	minDuration := fs.Duration("min-duration", 0, "only events at least this slow")
+	errorsOnly := fs.Bool("errors", false, "only failed queries")
+	limit := fs.Int("limit", 0, "return only the most recent N matches (default: 1000 from a daemon, all from a file)")
+	output := fs.String("output", "json", "output format: json (NDJSON) or csv")
+	tokenEnv := fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled")
+	keyEnv := fs.String("key-env", "SQL_TAP_STORE_KEY", "environment variable holding the key of an encrypted store file")
+	keyKMS := fs.String("key-kms", "", kmsUsage)
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	format, err := export.ParseFormat(*output)
+	if err != nil {
+		fail(err)
+	}
+	now := time.Now()
+	req := &tapv1.QueryRequest{
+		Query:      *sql,
+		TxId:       *tx,
+		Contains:   *grep,
+		ErrorsOnly: *errorsOnly,
+		Limit:      int32(min(max(*limit, 0), 1<<30)), //nolint:gosec // clamped
+	}
+	if *minDuration > 0 {
+		req.MinDuration = durationpb.New(*minDuration)
+	}
+	if *since != "" {
+		t, err := parseWhen(*since, now)
+		if err != nil {
+			fail(fmt.Errorf("-since: %w", err))
+		}
+		req.Since = timestamppb.New(t)
+	}
+	if *until != "" {
+		t, err := parseWhen(*until, now)
+		if err != nil {
+			fail(fmt.Errorf("-until: %w", err))
+		}
+		req.Until = timestamppb.New(t)
+	}
+
+	var events []*tapv1.QueryEvent
+	target := fs.Arg(0)
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		key, err := readKey(*keyEnv, *keyKMS)
+		if err != nil {
+			fail(err)
+		}
+		events, err = queryFile(target, key, req)
+		if err != nil {
+			fail(err)
+		}
+	} else {
+		events, err = queryDaemon(target, req, os.Getenv(*tokenEnv))
+		if err != nil {
+			fail(err)
+		}
+	}
+	if err := writeEvents(os.Stdout, format, events); err != nil {
+		fail(err)
+	}
+}
+
+// parseWhen parses a duration before now ("90m") or an RFC 3339 time.
+func parseWhen(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither a duration nor an RFC 3339 time", s)
+	}
+	return t, nil
+}
+
+func queryFile(path string, key []byte, req *tapv1.QueryRequest) ([]*tapv1.QueryEvent, error) {
+	opts := []store.Option{store.WithReadOnly()}
+	if key != nil {
+		opts = append(opts, store.WithKey(key))
+	}
+	st, err := store.Open(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = st.Close() }()
+
+	return st.Query(store.FilterFromRequest(req))
+}
+
+// queryMaxRecvSize leaves room for a full page of large events.
+const queryMaxRecvSize = 64 << 20
+
+func queryDaemon(addr string, req *tapv1.QueryRequest, token string) ([]*tapv1.QueryEvent, error) {
+	c, err := client.Dial(addr,
+		client.WithToken(token),
+		client.WithDialOptions(grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(queryMaxRecvSize))))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the address
+	}
+	defer func() { _ = c.Close() }()
+
+	resp, err := c.Query(context.Background(), req)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", addr, err)
+	}
+	return resp.GetEvents(), nil
+}
+
+func writeEvents(out io.Writer, format export.Format, events []*tapv1.QueryEvent) error {
+	w := export.NewWriter(out, format)
+	for _, ev := range events {
+		if err := w.Write(ev); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+
+	"github.com/mickamy/sql-tap/dsn"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/replay"
+)
+
+// replayCmd re-runs recorded sessions against a database and reports the
+// statements whose outcome differs from the recording.
+func replayCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap replay — Re-run recorded statements and compare them with the recording\n\nUsage:\n  sql-tap replay [flags] <file>...\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	dsnFlag := fs.String("dsn", "", "DSN of the database to replay against (default: the value of -dsn-env)")
+	dsnEnv := fs.String("dsn-env", "DATABASE_URL", "environment variable holding the DSN of the database to replay against")
+	execute := fs.Bool("execute", false, "execute every statement, writes and transaction boundaries included (default: read-only statements only)")
+	connID := fs.String("conn", "", "replay only the statements of this captured connection ID")
+	var renames renameFlags
+	fs.Var(&renames, "rename", "rename before replaying: schema:<from>=<to> for a schema or database, prefix:<from>=<to> for a table prefix (repeatable)")
+	var synths synthFlags
+	fs.Var(&synths, "synthesize", "send synthetic values for arguments bound to a column: <column>=<keep|shape|email|uuid|null>, column * for the rest (repeatable)")
+	seed := fs.String("seed", "", "seed for -synthesize; the same seed always gives the same values")
+	assert := fs.Bool("assert", false, "exit with status 1 when any statement diverges from the recording")
+	timeout := fs.Duration("timeout", replay.DefaultTimeout, "limit on each replayed statement")
+	output := fs.String("output", "text", "report format: text or json")
+	keyEnv := fs.String("key-env", "SQL_TAP_ARCHIVE_KEY", "environment variable holding the key for encrypted (.enc) archives")
+	keyKMS := fs.String("key-kms", "", kmsUsage)
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	fail := func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *output != "text" && *output != "json" {
+		fail(fmt.Errorf("unknown output %q (want text or json)", *output))
+	}
+
+	key, err := readKey(*keyEnv, *keyKMS)
+	if err != nil {
+		fail(err)
+	}
+	var recs []export.Record
+	for _, path := range fs.Args() {
+		r, err := readRecords(path, key)
+		if err != nil {
+			fail(err)
+		}
+		recs = append(recs, r...)
+	}
+
+	raw := *dsnFlag
+	if raw == "" {
+		if raw = os.Getenv(*dsnEnv); raw == "" {
+			fail(fmt.Errorf("set -dsn or %s", *dsnEnv))
+		}
+	}
+	driver, err := dsn.DetectDriver(raw)
+	if err != nil {
+		fail(err)
+	}
+	db, err := dsn.Open(raw)
+	if err != nil {
+		fail(err)
+	}
+	defer func() { _ = db.Close() }()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		fail(fmt.Errorf("connect: %w", err))
+	}
+	defer func() { _ = conn.Close() }()
+
+	opts := []replay.Option{replay.WithTimeout(*timeout)}
+	if *execute {
+		opts = append(opts, replay.WithWrites())
+	}
+	if *connID != "" {
+		opts = append(opts, replay.WithConn(*connID))
+	}
+	if len(synths) > 0 {
+		opts = append(opts, replay.WithSynthetic(*seed, synths...))
+	}
+	if len(renames) > 0 {
+		opts = append(opts, replay.WithRewrite(renames...))
+	}
+	if driver == "mysql" {
+		opts = append(opts, replay.WithNullArg("NULL"))
+	}
+	report, err := replay.Run(ctx, replay.SQL(conn), recs, opts...)
+	if err != nil {
+		fail(err)
+	}
+
+	if *output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(report)
+	} else {
+		err = writeReplay(os.Stdout, report)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if *assert && len(report.Divergences) > 0 {
+		os.Exit(1)
+	}
+}
+
+// renameFlags collects repeated -rename flags.
+type renameFlags []replay.Rule
+
+func (f *renameFlags) String() string {
+	rules := make([]string, len(*f))
+	for i, r := range *f {
+		rules[i] = r.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+// Set parses "schema:<from>=<to>" or "prefix:<from>=<to>".
+func (f *renameFlags) Set(v string) error {
+	r, err := replay.ParseRule(v)
+	if err != nil {
+		return err //nolint:wrapcheck // flag reports the value
+	}
+	*f = append(*f, r)
+	return nil
+}
+
+// synthFlags collects repeated -synthesize flags.
+type synthFlags []replay.Synth
+
+func (f *synthFlags) String() string {
+	rules := make([]string, len(*f))
+	for i, s := range *f {
+		rules[i] = s.String()
+	}
+	return strings.Join(rules, ",")
+}
+
+// Set parses "<column>=<generator>".
+func (f *synthFlags) Set(v string) error {
+	s, err := replay.ParseSynth(v)
+	if err != nil {
+		return err //nolint:wrapcheck // flag reports the value
+	}
+	*f = append(*f, s)
+	return nil
+}
+
+func readRecords(path string, key []byte) ([]export.Record, error) {
+	rc, err := openArchive(path, key)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // archive errors name the path
+	}
+	defer func() { _ = rc.Close() }()
+	recs, err := replay.Read(rc)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return recs, nil
+}
+
+func writeReplay(out io.Writer, r replay.Report) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if len(r.Divergences) > 0 {
+		fmt.Fprintf(w, "Divergences (%d)\n", len(r.Divergences))
+		fmt.Fprintln(w, "  ID\tRECORDED\tREPLAYED\tQUERY")
+		for _, d := range r.Divergences {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", d.Record.ID, outcome(d.Record.RowsAffected, d.Record.Error),
+				outcome(d.Rows, d.Error), d.Record.Query)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d statements replayed, %d diverged, %d skipped\n", r.Replayed, len(r.Divergences), r.Skipped)
+	return w.Flush() //nolint:wrapcheck // stdout write error
+}
+
+// outcome summarizes a statement's result for the report.
+func outcome(rows int64, errMsg string) string {
+	if errMsg != "" {
+		return "error: " + truncateOutcome(errMsg, 60)
+	}
+	return fmt.Sprintf("%d rows", rows)
+}
+
+func truncateOutcome(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// routesCmd prints a daemon's per-route query statistics.
+func routesCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap routes", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap routes — Show query statistics per HTTP route\n\nUsage:\n  sql-tap routes [flags] <addr>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	tokenEnv := fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled")
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	resp, err := routesDaemon(fs.Arg(0), os.Getenv(*tokenEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeRoutes(os.Stdout, resp); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func routesDaemon(addr, token string) (*tapv1.RoutesResponse, error) {
+	c, err := client.Dial(addr, client.WithToken(token))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the address
+	}
+	defer func() { _ = c.Close() }()
+
+	resp, err := c.Routes(context.Background(), &tapv1.RoutesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("routes %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func writeRoutes(out io.Writer, resp *tapv1.RoutesResponse) error {
+	if len(resp.GetRoutes()) == 0 {
+		_, err := fmt.Fprintf(out, "no routed queries in the last %s\n", resp.GetWindow().AsDuration())
+		return err //nolint:wrapcheck // stdout write error
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ROUTE\tQUERIES\tQPS\tERRORS\tP50\tP95\tP99\tREQUESTS\tQ/REQ P50\tQ/REQ P95\tQ/REQ MAX\tOVER BUDGET")
+	for _, r := range resp.GetRoutes() {
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%d\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\n",
+			r.GetRoute(), r.GetCount(), r.GetQps(), r.GetErrors(),
+			roundLatency(r.GetP50()), roundLatency(r.GetP95()), roundLatency(r.GetP99()),
+			r.GetRequests(), r.GetQueriesPerRequestP50(), r.GetQueriesPerRequestP95(), r.GetQueriesPerRequestMax(),
+			r.GetOverBudget())
+	}
+	if err := w.Flush(); err != nil {
+		return err //nolint:wrapcheck // stdout write error
+	}
+
+	// Likely N+1 endpoints, with the query their heaviest request repeated.
+	for _, r := range resp.GetRoutes() {
+		if r.GetOverBudget() == 0 {
+			continue
+		}
+		fmt.Fprintf(out, "\n%s: %d request(s) over the budget of %d queries; the heaviest ran %dx\n  %s\n",
+			r.GetRoute(), r.GetOverBudget(), resp.GetQueryBudget(), r.GetTopFingerprintCalls(), r.GetTopFingerprint())
+	}
+	return nil
+}
+
+func roundLatency(d *durationpb.Duration) time.Duration {
+	return d.AsDuration().Round(10 * time.Microsecond)
+}
@@ -0,0 +1,84 @@
+package sqlcomment
+
+import (
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CallerKey is the key Caller comments statements with: the application
+// frames that issued the statement, innermost first, one per line, each as
+// "<package>.<function> <file>:<line>", e.g. "store.(*Users).Find users.go:42".
+const CallerKey = "caller"
+
+// Option configures Wrap and WrapConnector.
+type Option func(*options)
+
+type options struct {
+	frames int
+	skip   []string
+}
+
+// Caller comments each statement with up to frames frames of the call stack
+// that issued it, under CallerKey, so sql-tap shows which function ran the
+// query. Frames of the runtime, database/sql, this package, and functions
+// whose names start with any of skip are left out, which keeps the stack to
+// application code when a query builder or ORM sits in between:
+//
+//	sqlcomment.Wrap(&pq.Driver{}, sqlcomment.Caller(5, "gorm.io/"))
+//
+// A prepared statement carries the stack that prepared it. Capturing a
+// stack costs a few microseconds per statement, and statements issued from
+// different places no longer share a driver's cached prepared statement.
+func Caller(frames int, skip ...string) Option {
+	return func(o *options) {
+		o.frames = frames
+		o.skip = skip
+	}
+}
+
+// skipped are the function name prefixes Caller always leaves out.
+var skipped = []string{"runtime.", "database/sql.", "github.com/mickamy/sql-tap/sqlcomment."}
+
+// caller returns the application frames calling into the driver, formatted
+// for CallerKey, or "" when o does not capture them.
+func (o *options) caller() string {
+	if o.frames <= 0 {
+		return ""
+	}
+	pcs := make([]uintptr, 64)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	lines := make([]string, 0, o.frames)
+	for len(lines) < o.frames {
+		f, more := frames.Next()
+		if f.Function != "" && !o.skips(f.Function) {
+			lines = append(lines, shortFunction(f.Function)+" "+path.Base(f.File)+":"+strconv.Itoa(f.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (o *options) skips(function string) bool {
+	for _, prefixes := range [][]string{skipped, o.skip} {
+		for _, p := range prefixes {
+			if strings.HasPrefix(function, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shortFunction drops the import path of function's package but its last
+// element, e.g. "github.com/acme/app/store.(*Users).Find" becomes
+// "store.(*Users).Find".
+func shortFunction(function string) string {
+	if i := strings.LastIndexByte(function, '/'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
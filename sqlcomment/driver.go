@@ -0,0 +1,186 @@
+package sqlcomment
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+)
+
+// Wrap returns a driver that comments every statement with the tags of the
+// context it runs in. Register it under a new name and open that:
+//
+//	sql.Register("postgres-tap", sqlcomment.Wrap(&pq.Driver{}))
+//	db, err := sql.Open("postgres-tap", dsn)
+//
+// Statements run without a context, or with one that has no tags, are
+// passed through unchanged. Drivers that cache prepared statements by text
+// prepare each commented variant separately. Options add tags of their own,
+// such as the calling function with Caller.
+func Wrap(d driver.Driver, opts ...Option) driver.Driver {
+	return &wrappedDriver{d: d, o: newOptions(opts)}
+}
+
+// WrapConnector is Wrap for drivers opened through a Connector, for use
+// with sql.OpenDB.
+func WrapConnector(c driver.Connector, opts ...Option) driver.Connector {
+	return &connector{c: c, d: &wrappedDriver{d: c.Driver(), o: newOptions(opts)}}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+type wrappedDriver struct {
+	d driver.Driver
+	o *options
+}
+
+func (w *wrappedDriver) Open(name string) (driver.Conn, error) {
+	c, err := w.d.Open(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return &conn{c: c, o: w.o}, nil
+}
+
+func (w *wrappedDriver) OpenConnector(name string) (driver.Connector, error) {
+	dc, ok := w.d.(driver.DriverContext)
+	if !ok {
+		return &dsnConnector{name: name, d: w}, nil
+	}
+	c, err := dc.OpenConnector(name)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return &connector{c: c, d: w}, nil
+}
+
+type connector struct {
+	c driver.Connector
+	d *wrappedDriver
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	dc, err := c.c.Connect(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return &conn{c: dc, o: c.d.o}, nil
+}
+
+func (c *connector) Driver() driver.Driver { return c.d }
+
+// dsnConnector opens connections for drivers without DriverContext.
+type dsnConnector struct {
+	name string
+	d    *wrappedDriver
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) { return c.d.Open(c.name) }
+
+func (c *dsnConnector) Driver() driver.Driver { return c.d }
+
+// conn comments statements on their way to the wrapped connection. Optional
+// interfaces the wrapped connection lacks answer driver.ErrSkip or their
+// neutral value, so database/sql falls back as it would without the wrapper.
+type conn struct {
+	c driver.Conn
+	o *options
+}
+
+var (
+	_ driver.ConnPrepareContext = (*conn)(nil)
+	_ driver.ExecerContext      = (*conn)(nil)
+	_ driver.QueryerContext     = (*conn)(nil)
+	_ driver.ConnBeginTx        = (*conn)(nil)
+	_ driver.Pinger             = (*conn)(nil)
+	_ driver.SessionResetter    = (*conn)(nil)
+	_ driver.Validator          = (*conn)(nil)
+	_ driver.NamedValueChecker  = (*conn)(nil)
+)
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return c.c.Prepare(query) //nolint:wrapcheck // the driver's error, unchanged
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	query = c.comment(ctx, query)
+	if pc, ok := c.c.(driver.ConnPrepareContext); ok {
+		return pc.PrepareContext(ctx, query) //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return c.c.Prepare(query) //nolint:wrapcheck // the driver's error, unchanged
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := c.c.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return ec.ExecContext(ctx, c.comment(ctx, query), args) //nolint:wrapcheck // the driver's error, unchanged
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := c.c.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	return qc.QueryContext(ctx, c.comment(ctx, query), args) //nolint:wrapcheck // the driver's error, unchanged
+}
+
+// comment appends the tags of ctx, and those the options add, to query.
+func (c *conn) comment(ctx context.Context, query string) string {
+	if caller := c.o.caller(); caller != "" {
+		ctx = With(ctx, CallerKey, caller)
+	}
+	return Append(ctx, query)
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.c.Begin() //nolint:staticcheck,wrapcheck // required by driver.Conn
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if bc, ok := c.c.(driver.ConnBeginTx); ok {
+		return bc.BeginTx(ctx, opts) //nolint:wrapcheck // the driver's error, unchanged
+	}
+	if opts.Isolation != 0 || opts.ReadOnly {
+		return nil, errors.New("sqlcomment: driver does not support transaction options")
+	}
+	return c.c.Begin() //nolint:staticcheck,wrapcheck // fallback for drivers without BeginTx
+}
+
+func (c *conn) Close() error {
+	return c.c.Close() //nolint:wrapcheck // the driver's error, unchanged
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if p, ok := c.c.(driver.Pinger); ok {
+		return p.Ping(ctx) //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if r, ok := c.c.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx) //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if v, ok := c.c.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nc, ok := c.c.(driver.NamedValueChecker); ok {
+		return nc.CheckNamedValue(nv) //nolint:wrapcheck // the driver's error, unchanged
+	}
+	return driver.ErrSkip
+}
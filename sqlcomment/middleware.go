@@ -0,0 +1,40 @@
+package sqlcomment
+
+import (
+	"crypto/rand"
+	"net/http"
+)
+
+// Middleware returns net/http middleware that tags each request's context
+// with its route and a fresh request ID, so the queries its handler runs are
+// attributed to the endpoint and counted per request. route is called when a
+// statement is commented rather than when the request arrives, so it can
+// read what a router nested inside the middleware has matched by then. A nil
+// route uses the http.ServeMux pattern, e.g. "GET /users/{id}":
+//
+//	http.ListenAndServe(addr, sqlcomment.Middleware(nil)(mux))
+//
+// With chi, install it inside the router and read chi's route pattern:
+//
+//	r.Use(sqlcomment.Middleware(func(r *http.Request) string {
+//		return chi.RouteContext(r.Context()).RoutePattern()
+//	}))
+//
+// Handlers must pass the request's context to database/sql, e.g. with
+// QueryContext, for the route to reach the query.
+func Middleware(route func(*http.Request) string) func(http.Handler) http.Handler {
+	if route == nil {
+		route = func(r *http.Request) string { return r.Pattern }
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// ServeMux sets Pattern on the request it is handed, which is
+			// req, after this closure is created.
+			var req *http.Request
+			ctx := With(r.Context(), RequestIDKey, rand.Text())
+			ctx = withFunc(ctx, RouteKey, func() string { return route(req) })
+			req = r.WithContext(ctx)
+			next.ServeHTTP(w, req)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+// Package sqlcomment is the application-side companion to sql-tap. It
+// carries request attributes, such as the HTTP route being served, in a
+// context and appends them to SQL statements as a sqlcommenter comment:
+//
+//	SELECT * FROM users WHERE id = $1 /*route='GET+%2Fusers%2F%7Bid%7D'*/
+//
+// sql-tapd reads the comment back, so each captured query is attributed to
+// the endpoint that ran it. Wrap the database/sql driver with Wrap or
+// WrapConnector to comment every statement, and install Middleware to record
+// the route of each HTTP request. With the Caller option the wrapper also
+// records the function that issued each statement.
+package sqlcomment
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Keys the middleware sets.
+const (
+	RouteKey     = "route"      // the HTTP route being served
+	RequestIDKey = "request_id" // unique per HTTP request, to count its queries
+)
+
+type ctxKey struct{}
+
+// tag is one key/value pair in a context, linked to the pairs set before it.
+// value is evaluated when a statement is commented, so middleware can record
+// routing information that is only known after it runs.
+type tag struct {
+	parent *tag
+	key    string
+	value  func() string
+}
+
+// With returns a copy of ctx whose statements are commented with key=value.
+// A later With for the same key wins.
+func With(ctx context.Context, key, value string) context.Context {
+	return withFunc(ctx, key, func() string { return value })
+}
+
+// WithRoute is With for RouteKey.
+func WithRoute(ctx context.Context, route string) context.Context {
+	return With(ctx, RouteKey, route)
+}
+
+func withFunc(ctx context.Context, key string, value func() string) context.Context {
+	parent, _ := ctx.Value(ctxKey{}).(*tag)
+	return context.WithValue(ctx, ctxKey{}, &tag{parent: parent, key: key, value: value})
+}
+
+// Tags returns the key/value pairs set on ctx, omitting empty values.
+func Tags(ctx context.Context) map[string]string {
+	var tags map[string]string
+	seen := make(map[string]bool)
+	for t, _ := ctx.Value(ctxKey{}).(*tag); t != nil; t = t.parent {
+		if seen[t.key] {
+			continue
+		}
+		seen[t.key] = true
+		if v := t.value(); v != "" {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[t.key] = v
+		}
+	}
+	return tags
+}
+
+// Append returns query with the tags set on ctx appended as a sqlcommenter
+// comment. Keys are sorted and keys and values URL-encoded, per the
+// sqlcommenter specification. query is returned unchanged when ctx has no
+// tags or query already ends with a comment.
+func Append(ctx context.Context, query string) string {
+	tags := Tags(ctx)
+	if len(tags) == 0 {
+		return query
+	}
+	body := strings.TrimRight(query, " \t\r\n;")
+	if strings.HasSuffix(body, "*/") {
+		return query
+	}
+
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	b.WriteString(body)
+	b.WriteString(" /*")
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		// QueryEscape encodes ', *, and /, so a value cannot end the comment
+		// or its own quotes.
+		b.WriteString(url.QueryEscape(k))
+		b.WriteString("='")
+		b.WriteString(url.QueryEscape(tags[k]))
+		b.WriteByte('\'')
+	}
+	b.WriteString("*/")
+	b.WriteString(query[len(body):])
+	return b.String()
+}
@@ -0,0 +1,224 @@
+package sqlcomment_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mickamy/sql-tap/proxy"
+	"github.com/mickamy/sql-tap/sqlcomment"
+)
+
+func TestAppend(t *testing.T) {
+	t.Parallel()
+
+	tagged := sqlcomment.With(sqlcomment.WithRoute(context.Background(), "GET /users/{id}"), "note", "it's */ done")
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		query string
+		want  string
+	}{
+		{name: "no tags", ctx: context.Background(), query: "SELECT 1", want: "SELECT 1"},
+		{
+			name:  "sorted and encoded",
+			ctx:   tagged,
+			query: "SELECT 1",
+			want:  "SELECT 1 /*note='it%27s+%2A%2F+done',route='GET+%2Fusers%2F%7Bid%7D'*/",
+		},
+		{name: "before the semicolon", ctx: sqlcomment.WithRoute(context.Background(), "/a"), query: "SELECT 1;\n", want: "SELECT 1 /*route='%2Fa'*/;\n"},
+		{name: "already commented", ctx: tagged, query: "SELECT 1 /*action='x'*/", want: "SELECT 1 /*action='x'*/"},
+		{name: "later value wins", ctx: sqlcomment.WithRoute(sqlcomment.WithRoute(context.Background(), "/a"), "/b"), query: "SELECT 1", want: "SELECT 1 /*route='%2Fb'*/"},
+		{name: "empty value omitted", ctx: sqlcomment.WithRoute(context.Background(), ""), query: "SELECT 1", want: "SELECT 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := sqlcomment.Append(tt.ctx, tt.query); got != tt.want {
+				t.Errorf("Append() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// sql-tapd reads back what Append wrote.
+	got := proxy.SQLComment(sqlcomment.Append(tagged, "SELECT 1"))
+	if got["route"] != "GET /users/{id}" || got["note"] != "it's */ done" {
+		t.Errorf("round trip = %v", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Parallel()
+
+	var seen []map[string]string
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(_ http.ResponseWriter, r *http.Request) {
+		seen = append(seen, sqlcomment.Tags(r.Context()))
+	})
+	srv := httptest.NewServer(sqlcomment.Middleware(nil)(mux))
+	defer srv.Close()
+
+	for range 2 {
+		resp, err := srv.Client().Get(srv.URL + "/users/42")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+
+	if len(seen) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(seen))
+	}
+	if route := seen[0][sqlcomment.RouteKey]; route != "GET /users/{id}" {
+		t.Fatalf("route = %q, want the mux pattern", route)
+	}
+	if a, b := seen[0][sqlcomment.RequestIDKey], seen[1][sqlcomment.RequestIDKey]; a == "" || a == b {
+		t.Fatalf("expected distinct request IDs, got %q and %q", a, b)
+	}
+}
+
+// recorder is a minimal driver that records the statements it is sent.
+type recorder struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (r *recorder) Open(string) (driver.Conn, error) { return &recConn{r: r}, nil }
+
+func (r *recorder) record(q string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queries = append(r.queries, q)
+}
+
+type recConn struct{ r *recorder }
+
+func (c *recConn) Prepare(q string) (driver.Stmt, error) {
+	c.r.record(q)
+	return recStmt{}, nil
+}
+func (c *recConn) Close() error              { return nil }
+func (c *recConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *recConn) ExecContext(_ context.Context, q string, _ []driver.NamedValue) (driver.Result, error) {
+	c.r.record(q)
+	return driver.RowsAffected(1), nil
+}
+
+type recStmt struct{}
+
+func (recStmt) Close() error                               { return nil }
+func (recStmt) NumInput() int                              { return -1 }
+func (recStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (recStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, errors.New("not supported") }
+
+func TestWrap(t *testing.T) {
+	t.Parallel()
+
+	rec := &recorder{}
+	connector, err := sqlcomment.Wrap(rec).(driver.DriverContext).OpenConnector("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := sql.OpenDB(connector)
+	defer func() { _ = db.Close() }()
+
+	ctx := sqlcomment.WithRoute(t.Context(), "/orders")
+	if _, err := db.ExecContext(ctx, "DELETE FROM carts"); err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := db.PrepareContext(ctx, "UPDATE carts SET n = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = stmt.Close()
+	if _, err := db.ExecContext(t.Context(), "SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"DELETE FROM carts /*route='%2Forders'*/",
+		"UPDATE carts SET n = ? /*route='%2Forders'*/",
+		"SELECT 1",
+	}
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.queries) != len(want) {
+		t.Fatalf("queries = %q, want %q", rec.queries, want)
+	}
+	for i := range want {
+		if rec.queries[i] != want[i] {
+			t.Errorf("query %d = %q, want %q", i, rec.queries[i], want[i])
+		}
+	}
+}
+
+// deleteCarts is the function TestWrap_Caller expects in the stack.
+func deleteCarts(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "DELETE FROM carts")
+	return err
+}
+
+func TestWrap_Caller(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		opt  sqlcomment.Option
+		want []string // prefixes of the frames
+	}{
+		{
+			name: "innermost first",
+			opt:  sqlcomment.Caller(2),
+			want: []string{"sqlcomment_test.deleteCarts sqlcomment_test.go:", "sqlcomment_test.TestWrap_Caller.func1 sqlcomment_test.go:"},
+		},
+		{
+			name: "skipped packages",
+			opt:  sqlcomment.Caller(1, "github.com/mickamy/sql-tap/sqlcomment_test.deleteCarts"),
+			want: []string{"sqlcomment_test.TestWrap_Caller.func1 sqlcomment_test.go:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := &recorder{}
+			connector, err := sqlcomment.Wrap(rec, tt.opt).(driver.DriverContext).OpenConnector("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			db := sql.OpenDB(connector)
+			defer func() { _ = db.Close() }()
+
+			if err := deleteCarts(t.Context(), db); err != nil {
+				t.Fatal(err)
+			}
+			rec.mu.Lock()
+			defer rec.mu.Unlock()
+			if len(rec.queries) != 1 {
+				t.Fatalf("queries = %q, want 1", rec.queries)
+			}
+			// sql-tapd reads the stack back from the comment.
+			ev := make(chan proxy.Event, 1)
+			proxy.Emit(ev, proxy.Event{Query: rec.queries[0]})
+			got := (<-ev).Caller
+			if len(got) != len(tt.want) {
+				t.Fatalf("caller = %q, want %d frames", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.HasPrefix(got[i], w) {
+					t.Errorf("frame %d = %q, want %q...", i, got[i], w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mickamy/sql-tap/internal/export"
+)
+
+// tailCmd prints captured events to stdout as plain lines, one per event,
+// for reading over ssh or a dumb terminal and filtering with grep, or as
+// NDJSON with -json.
+func tailCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap tail", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap tail — Print captured queries as plain lines\n\nUsage:\n  sql-tap tail [flags] <addr>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	jsonOut := fs.Bool("json", false, "print NDJSON records, as sql-tap watch does, instead of lines")
+	utc := fs.Bool("utc", false, "print times in UTC instead of local time")
+	sf := addStreamFlags(fs, "output")
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	if err := sf.watch(fs.Arg(0), tailWriter(*jsonOut, *utc)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// tailWriter returns the Writer of sql-tap tail and sql-tap -no-tui.
+func tailWriter(jsonOut, utc bool) export.Writer {
+	if jsonOut {
+		return export.NewWriter(os.Stdout, export.NDJSON)
+	}
+	loc := time.Local
+	if utc {
+		loc = time.UTC
+	}
+	return export.NewLineWriter(os.Stdout, loc)
+}
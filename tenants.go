@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+)
+
+// tenantsCmd prints a daemon's per-tenant query statistics.
+func tenantsCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap tenants", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap tenants — Show query statistics per tenant\n\nUsage:\n  sql-tap tenants [flags] <addr>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	tokenEnv := fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled")
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	resp, err := tenantsDaemon(fs.Arg(0), os.Getenv(*tokenEnv))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := writeTenants(os.Stdout, resp); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func tenantsDaemon(addr, token string) (*tapv1.TenantsResponse, error) {
+	c, err := client.Dial(addr, client.WithToken(token))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // names the address
+	}
+	defer func() { _ = c.Close() }()
+
+	resp, err := c.Tenants(context.Background(), &tapv1.TenantsRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("tenants %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func writeTenants(out io.Writer, resp *tapv1.TenantsResponse) error {
+	if len(resp.GetTenants()) == 0 {
+		_, err := fmt.Fprintf(out, "no queries with %s in the last %s\n", resp.GetField(), resp.GetWindow().AsDuration())
+		return err //nolint:wrapcheck // stdout write error
+	}
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TENANT\tQUERIES\tQPS\tERRORS\tP50\tP95\tP99\tSHARE")
+	for _, t := range resp.GetTenants() {
+		over := ""
+		if t.GetShare() > resp.GetMaxShare() {
+			over = " (over)"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%.1f\t%d\t%s\t%s\t%s\t%.1f%%%s\n",
+			t.GetValue(), t.GetCount(), t.GetQps(), t.GetErrors(),
+			roundLatency(t.GetP50()), roundLatency(t.GetP95()), roundLatency(t.GetP99()),
+			100*t.GetShare(), over)
+	}
+	return w.Flush() //nolint:wrapcheck // stdout write error
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/mickamy/sql-tap/client"
+	tapv1 "github.com/mickamy/sql-tap/gen/tap/v1"
+	"github.com/mickamy/sql-tap/internal/auth"
+	"github.com/mickamy/sql-tap/internal/export"
+	"github.com/mickamy/sql-tap/internal/sample"
+)
+
+// watchCmd streams captured events to stdout instead of opening the TUI.
+func watchCmd(args []string) {
+	fs := flag.NewFlagSet("sql-tap watch", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "sql-tap watch — Stream captured queries to stdout\n\nUsage:\n  sql-tap watch [flags] <addr>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+
+	output := fs.String("output", "json", "output format: json (NDJSON) or csv")
+	tmpl := fs.String("template", "", "render each event with a Go text/template instead of -output, e.g. '{{.Duration}} {{.Query}}'")
+	sf := addStreamFlags(fs, "output")
+
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	format, err := export.ParseFormat(*output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	w := export.NewWriter(os.Stdout, format)
+	if *tmpl != "" {
+		if w, err = export.NewTemplateWriter(os.Stdout, *tmpl); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if err := sf.watch(fs.Arg(0), w); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// streamFlags are the flags of the commands that stream events to stdout.
+type streamFlags struct {
+	lossless   *bool
+	spill      *bool
+	tokenEnv   *string
+	sampleSpec *string
+	upstreams  *string
+	databases  *string
+	users      *string
+	ops        *string
+	fpPrefix   *string
+	fieldSpec  *string
+}
+
+// addStreamFlags defines the streaming flags on fs; consumer names what
+// falls behind in the -lossless help.
+func addStreamFlags(fs *flag.FlagSet, consumer string) *streamFlags {
+	return &streamFlags{
+		lossless:   fs.Bool("lossless", false, "stall event publishing instead of dropping events when "+consumer+" falls behind"),
+		spill:      fs.Bool("spill", false, "queue events on the daemon's disk instead of dropping them when "+consumer+" falls behind"),
+		tokenEnv:   fs.String("token-env", "SQL_TAP_TOKEN", "environment variable holding the bearer token for a daemon with auth enabled"),
+		sampleSpec: fs.String("sample", "", "ask the daemon to sample events: rate=<0..1>,per-fingerprint=<n>,max-per-second=<n> (any subset)"),
+		upstreams:  fs.String("upstream", "", "only events from these upstreams (comma-separated tap names)"),
+		databases:  fs.String("database", "", "only events on connections to these databases (comma-separated)"),
+		users:      fs.String("user", "", "only events on connections as these database users (comma-separated)"),
+		ops:        fs.String("op", "", "only events of these ops (comma-separated, e.g. Query,Execute)"),
+		fpPrefix:   fs.String("fingerprint-prefix", "", "only statements whose fingerprint starts with this (case-insensitive)"),
+		fieldSpec:  fs.String("field", "", "only events with these extracted field values (comma-separated name=value, e.g. tenant_id=42)"),
+	}
+}
+
+// watch streams the events the flags select from addr to w.
+func (f *streamFlags) watch(addr string, w export.Writer) error {
+	sampling, err := sample.Parse(*f.sampleSpec)
+	if err != nil {
+		return err //nolint:wrapcheck // sample errors name the option
+	}
+	fields, err := parseFields(*f.fieldSpec)
+	if err != nil {
+		return err
+	}
+	sel := &tapv1.Selector{
+		Upstreams:         splitList(*f.upstreams),
+		Databases:         splitList(*f.databases),
+		Users:             splitList(*f.users),
+		Ops:               splitList(*f.ops),
+		FingerprintPrefix: *f.fpPrefix,
+		Fields:            fields,
+	}
+	delivery, err := deliveryFlags(*f.lossless, *f.spill)
+	if err != nil {
+		return err
+	}
+	return watch(addr, w, delivery, sampling, sel, os.Getenv(*f.tokenEnv))
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(s string) []string {
+	var out []string
+	for item := range strings.SplitSeq(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// parseFields parses the -field flag's name=value pairs.
+func parseFields(s string) (map[string]string, error) {
+	var fields map[string]string
+	for _, item := range splitList(s) {
+		name, value, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("-field: %q is not name=value", item)
+		}
+		if fields == nil {
+			fields = make(map[string]string)
+		}
+		fields[name] = value
+	}
+	return fields, nil
+}
+
+// deliveryFlags returns the delivery -lossless and -spill ask for.
+func deliveryFlags(lossless, spill bool) (tapv1.Delivery, error) {
+	switch {
+	case lossless && spill:
+		return 0, errors.New("-lossless and -spill are exclusive")
+	case lossless:
+		return tapv1.Delivery_DELIVERY_BLOCK, nil
+	case spill:
+		return tapv1.Delivery_DELIVERY_SPILL, nil
+	}
+	return tapv1.Delivery_DELIVERY_UNSPECIFIED, nil
+}
+
+func watch(addr string, w export.Writer, delivery tapv1.Delivery, sampling sample.Config, sel *tapv1.Selector, token string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	c, err := client.Dial(addr, client.WithToken(token))
+	if err != nil {
+		return err //nolint:wrapcheck // names the address
+	}
+	defer func() { _ = c.Close() }()
+
+	req := &tapv1.WatchRequest{Client: auth.Identity(), Delivery: delivery, Sampling: sampling.Proto(), Selector: sel}
+	stream, err := c.Watch(ctx, req)
+	if err != nil {
+		return fmt.Errorf("watch %s: %w", addr, err)
+	}
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) || status.Code(err) == codes.Canceled {
+				return w.Flush()
+			}
+			_ = w.Flush()
+			return fmt.Errorf("watch %s: %w", addr, err)
+		}
+		if err := w.Write(resp.GetEvent()); err != nil {
+			return err
+		}
+		// Flush per event so downstream tools (jq, tail) see rows as they arrive.
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+}